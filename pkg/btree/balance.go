@@ -0,0 +1,226 @@
+// pkg/btree/balance.go
+package btree
+
+import (
+	"tur/pkg/pager"
+)
+
+// rebalanceChild fixes the underfull child of an interior node at childIdx
+// (-1 for the right child) together with an adjacent sibling. The two merge
+// when their cells fit on one page, freeing the emptied page; otherwise cells
+// move across so both end up about equally full.
+func (bt *BTree) rebalanceChild(page *pager.Page, node *Node, childIdx int) error {
+	count := node.CellCount()
+	if count == 0 {
+		// A lone child has no sibling to work with; the node itself is underfull
+		// and gets rebalanced (or collapsed, at the root) one level up
+		return nil
+	}
+
+	// Pair the child with its right sibling, or the rightmost child with its left one
+	sepIdx := childIdx
+	if childIdx == -1 {
+		sepIdx = count - 1
+	}
+	pageSize := node.PageSize()
+	sepCell := append([]byte(nil), node.rawCell(sepIdx)...)
+	_, leftPtr := node.GetCell(sepIdx)
+	leftPageNo := decodePageNo(leftPtr)
+	rightPageNo := node.RightChild()
+	if sepIdx+1 < count {
+		_, rightPtr := node.GetCell(sepIdx + 1)
+		rightPageNo = decodePageNo(rightPtr)
+	}
+
+	leftPage, err := bt.pager.Get(leftPageNo)
+	if err != nil {
+		return err
+	}
+	defer bt.pager.Release(leftPage)
+	rightPage, err := bt.pager.Get(rightPageNo)
+	if err != nil {
+		return err
+	}
+	left := LoadNode(leftPage.Data())
+	right := LoadNode(rightPage.Data())
+
+	// Gather the cells of both nodes in key order. Between interior siblings
+	// the separator comes down too, with any overflow chain of its key,
+	// pointing at the left node's right child.
+	isLeaf := left.IsLeaf()
+	cells := make([][]byte, 0, left.CellCount()+right.CellCount()+1)
+	for i := 0; i < left.CellCount(); i++ {
+		cells = append(cells, append([]byte(nil), left.rawCell(i)...))
+	}
+	if !isLeaf {
+		cells = append(cells, withChild(sepCell, pageSize, left.RightChild()))
+	}
+	for i := 0; i < right.CellCount(); i++ {
+		cells = append(cells, append([]byte(nil), right.rawCell(i)...))
+	}
+	rightChild := right.RightChild()
+
+	total := 0
+	for _, cell := range cells {
+		total += len(cell) + cellPointerSize
+	}
+
+	if total <= left.usableSpace() {
+		// Merge everything into the left node and free the right page
+		if err := left.rewrite(cells, rightChild); err != nil {
+			bt.pager.Release(rightPage)
+			return err
+		}
+		leftPage.SetDirty(true)
+		bt.pager.Release(rightPage)
+
+		// Drop the separator; whatever pointed at the right node now points
+		// left. Between leaves the separator only copies a key, so any overflow
+		// chain of its own goes too.
+		if isLeaf {
+			if err := bt.freeOverflow(node, sepIdx); err != nil {
+				return err
+			}
+			node.RefreshData(page.Data())
+		}
+		node.DeleteCell(sepIdx)
+		if sepIdx < node.CellCount() {
+			node.UpdateCellValue(sepIdx, encodePageNo(leftPageNo))
+		} else {
+			node.SetRightChild(leftPageNo)
+		}
+		page.SetDirty(true)
+		return bt.pager.Free(rightPageNo)
+	}
+	defer bt.pager.Release(rightPage)
+
+	// Redistribute around the most even split point that fits both nodes. In
+	// interior nodes the cell at the split point moves up as the new separator.
+	split := bt.balancedSplit(cells, isLeaf, left.usableSpace())
+	if split < 0 {
+		return nil
+	}
+	var newSep []byte
+	var newSepCell []byte
+	if isLeaf {
+		// Leaves keep their keys, so the separator copies the first key on the
+		// right. Its overflow chain, if any, is written once the parent is known
+		// to have room.
+		key, err := bt.rawCellKey(cells[split])
+		if err != nil {
+			return err
+		}
+		newSep = key
+		newSepCell = encodeCell(key[:localKeySize(pageSize, len(key))], len(key), encodePageNo(leftPageNo), 4, 0)
+	} else {
+		newSepCell = withChild(cells[split], pageSize, leftPageNo)
+	}
+
+	// The parent must have room for a longer separator; if it has not, the
+	// child is left underfull, which keeps the tree valid
+	if node.CellSpace()-len(sepCell)+len(newSepCell) > node.usableSpace() {
+		return nil
+	}
+
+	if isLeaf {
+		// Writing the overflow chain may remap the file under the nodes
+		var err error
+		if newSepCell, _, err = bt.buildCell(newSep, encodePageNo(leftPageNo)); err != nil {
+			return err
+		}
+		if err := bt.freeOverflow(node, sepIdx); err != nil {
+			return err
+		}
+		node.RefreshData(page.Data())
+		left.RefreshData(leftPage.Data())
+		right.RefreshData(rightPage.Data())
+	}
+
+	leftCells, rightCells := cells[:split], cells[split:]
+	leftRightChild := left.RightChild()
+	if !isLeaf {
+		_, _, child, _, _, _ := parseRawCell(cells[split], pageSize)
+		leftRightChild = decodePageNo(child)
+		rightCells = cells[split+1:]
+	}
+	if err := left.rewrite(leftCells, leftRightChild); err != nil {
+		return err
+	}
+	if err := right.rewrite(rightCells, rightChild); err != nil {
+		return err
+	}
+	leftPage.SetDirty(true)
+	rightPage.SetDirty(true)
+
+	node.DeleteCell(sepIdx)
+	node.defragment()
+	if err := node.insertRawCell(sepIdx, newSepCell); err != nil {
+		return err
+	}
+	page.SetDirty(true)
+	return nil
+}
+
+// balancedSplit returns the index at which to divide cells between two
+// siblings so both fit in usable bytes with the sizes as close as possible, or
+// -1 if no such index exists. For interior nodes the cell at the index becomes
+// the separator and belongs to neither side.
+func (bt *BTree) balancedSplit(cells [][]byte, isLeaf bool, usable int) int {
+	total := 0
+	for _, cell := range cells {
+		total += len(cell) + cellPointerSize
+	}
+
+	best, bestDiff := -1, 0
+	leftSize := 0
+	for k := 1; k < len(cells); k++ {
+		leftSize += len(cells[k-1]) + cellPointerSize
+		rightSize := total - leftSize
+		if !isLeaf {
+			rightSize -= len(cells[k]) + cellPointerSize
+		}
+		if leftSize > usable || rightSize > usable {
+			continue
+		}
+		diff := leftSize - rightSize
+		if diff < 0 {
+			diff = -diff
+		}
+		if best < 0 || diff < bestDiff {
+			best, bestDiff = k, diff
+		}
+	}
+	return best
+}
+
+// collapseRoot shrinks the tree by a level while the root is an interior node
+// without keys: its only child is copied onto the root page and freed, so the
+// root page number stays the same.
+func (bt *BTree) collapseRoot() error {
+	for {
+		page, err := bt.pager.Get(bt.rootPage)
+		if err != nil {
+			return err
+		}
+		node := LoadNode(page.Data())
+		if node.IsLeaf() || node.CellCount() > 0 {
+			bt.pager.Release(page)
+			return nil
+		}
+
+		childPageNo := node.RightChild()
+		child, err := bt.pager.Get(childPageNo)
+		if err != nil {
+			bt.pager.Release(page)
+			return err
+		}
+		copy(page.Data(), child.Data())
+		page.SetDirty(true)
+		bt.pager.Release(child)
+		bt.pager.Release(page)
+
+		if err := bt.pager.Free(childPageNo); err != nil {
+			return err
+		}
+	}
+}
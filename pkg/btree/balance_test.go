@@ -0,0 +1,248 @@
+// pkg/btree/balance_test.go
+package btree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkTree verifies the structure of the tree: keys are sorted and within the
+// bounds set by their parents' separators, all leaves are at the same depth, and
+// no page is reachable twice. It returns the number of keys.
+func checkTree(t *testing.T, bt *BTree) int {
+	t.Helper()
+	seen := make(map[uint32]bool)
+	leafDepth := -1
+	var keys int
+
+	var walk func(pageNo uint32, lo, hi []byte, depth int)
+	walk = func(pageNo uint32, lo, hi []byte, depth int) {
+		if seen[pageNo] {
+			t.Fatalf("page %d is reachable twice", pageNo)
+		}
+		seen[pageNo] = true
+
+		page, err := bt.pager.Get(pageNo)
+		if err != nil {
+			t.Fatalf("get page %d: %v", pageNo, err)
+		}
+		defer bt.pager.Release(page)
+		node := LoadNode(page.Data())
+
+		var prev []byte
+		for i := 0; i < node.CellCount(); i++ {
+			key, _ := node.GetCell(i)
+			if prev != nil && bytes.Compare(prev, key) >= 0 {
+				t.Fatalf("page %d: keys out of order at cell %d", pageNo, i)
+			}
+			if (lo != nil && bytes.Compare(key, lo) < 0) || (hi != nil && bytes.Compare(key, hi) >= 0) {
+				t.Fatalf("page %d: key %x outside parent bounds", pageNo, key)
+			}
+			prev = append([]byte(nil), key...)
+		}
+
+		if node.IsLeaf() {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if leafDepth != depth {
+				t.Fatalf("leaf page %d at depth %d, other leaves at %d", pageNo, depth, leafDepth)
+			}
+			keys += node.CellCount()
+			return
+		}
+
+		childLo := lo
+		for i := 0; i < node.CellCount(); i++ {
+			key, ptr := node.GetCell(i)
+			key = append([]byte(nil), key...)
+			walk(decodePageNo(ptr), childLo, key, depth+1)
+			childLo = key
+		}
+		walk(node.RightChild(), childLo, hi, depth+1)
+	}
+	walk(bt.rootPage, nil, nil, 1)
+	return keys
+}
+
+func rowKey(i int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(i))
+	return key
+}
+
+func TestBTreeRebalance_RandomWorkload(t *testing.T) {
+	for _, pageSize := range []int{512, 4096} {
+		t.Run(fmt.Sprintf("page%d", pageSize), func(t *testing.T) {
+			p := openTestPager(t, pageSize)
+			bt, err := Create(p)
+			if err != nil {
+				t.Fatalf("failed to create btree: %v", err)
+			}
+
+			rng := rand.New(rand.NewSource(int64(pageSize)))
+			model := make(map[int][]byte)
+			for step := 0; step < 20000; step++ {
+				k := rng.Intn(3000)
+				// Bias towards deletes in the second half so the tree shrinks
+				deleteBias := 40
+				if step > 10000 {
+					deleteBias = 70
+				}
+				if rng.Intn(100) < deleteBias {
+					err := bt.Delete(rowKey(k))
+					if _, ok := model[k]; ok {
+						if err != nil {
+							t.Fatalf("step %d: delete %d failed: %v", step, k, err)
+						}
+						delete(model, k)
+					} else if err != ErrKeyNotFound {
+						t.Fatalf("step %d: delete of missing %d returned %v", step, k, err)
+					}
+					continue
+				}
+				value := largeValue(k+step, 10+rng.Intn(60))
+				if rng.Intn(50) == 0 {
+					value = largeValue(k+step, pageSize+rng.Intn(3*pageSize))
+				}
+				if err := bt.Insert(rowKey(k), value); err != nil {
+					t.Fatalf("step %d: insert %d failed: %v", step, k, err)
+				}
+				model[k] = value
+
+				if step%2500 == 0 {
+					if n := checkTree(t, bt); n != len(model) {
+						t.Fatalf("step %d: tree holds %d keys, want %d", step, n, len(model))
+					}
+				}
+			}
+
+			if n := checkTree(t, bt); n != len(model) {
+				t.Fatalf("tree holds %d keys, want %d", n, len(model))
+			}
+			for k, want := range model {
+				got, err := bt.Get(rowKey(k))
+				if err != nil || !bytes.Equal(got, want) {
+					t.Fatalf("get %d: %d bytes, err %v", k, len(got), err)
+				}
+			}
+
+			// Cursor order matches the model
+			expected := make([]int, 0, len(model))
+			for k := range model {
+				expected = append(expected, k)
+			}
+			sort.Ints(expected)
+			cursor := bt.Cursor()
+			i := 0
+			for cursor.First(); cursor.Valid(); cursor.Next() {
+				if i >= len(expected) || !bytes.Equal(cursor.Key(), rowKey(expected[i])) {
+					t.Fatalf("cursor entry %d does not match", i)
+				}
+				i++
+			}
+			cursor.Close()
+			if i != len(expected) {
+				t.Fatalf("cursor visited %d entries, want %d", i, len(expected))
+			}
+
+			// Every page is either in use by the tree or on the freelist
+			used := len(bt.CollectPages())
+			if free := int(p.FreePageCount()); used+free+1 < int(p.PageCount()) {
+				t.Errorf("leaked pages: %d used + %d free of %d", used, free, p.PageCount())
+			}
+			if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+				t.Errorf("overflow errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestBTreeRebalance_DeleteAllFreesPages(t *testing.T) {
+	p := openTestPager(t, 512)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+	const n = 5000
+	for i := 0; i < n; i++ {
+		if err := bt.Insert(rowKey(i), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	if bt.Depth() < 3 {
+		t.Fatalf("expected a tree at least 3 levels deep, got %d", bt.Depth())
+	}
+	peak := len(bt.CollectPages())
+
+	rng := rand.New(rand.NewSource(1))
+	for _, i := range rng.Perm(n) {
+		if err := bt.Delete(rowKey(i)); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+
+	if depth := bt.Depth(); depth != 1 {
+		t.Errorf("expected the tree to collapse to a single leaf, depth is %d", depth)
+	}
+	if pages := bt.CollectPages(); len(pages) != 1 || pages[0] != bt.RootPage() {
+		t.Errorf("expected only the root page in use, got %v", pages)
+	}
+	if got := int(p.FreePageCount()); got < peak-1 {
+		t.Errorf("expected at least %d free pages, got %d", peak-1, got)
+	}
+
+	// The tree stays usable
+	if err := bt.Insert(rowKey(1), []byte("again")); err != nil {
+		t.Fatalf("insert after delete-all failed: %v", err)
+	}
+	if got, err := bt.Get(rowKey(1)); err != nil || string(got) != "again" {
+		t.Errorf("get after delete-all = %q, %v", got, err)
+	}
+}
+
+func TestBTreeRebalance_PurgeShrinksTree(t *testing.T) {
+	p := openTestPager(t, 4096)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	const n = 20000
+	for i := 0; i < n; i++ {
+		if err := bt.Insert(rowKey(i), largeValue(i, 40)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	peak := len(bt.CollectPages())
+
+	// Purge nine of every ten rows, as an expiry job would
+	for i := 0; i < n; i++ {
+		if i%10 != 0 {
+			if err := bt.Delete(rowKey(i)); err != nil {
+				t.Fatalf("delete %d failed: %v", i, err)
+			}
+		}
+	}
+
+	pages := len(bt.CollectPages())
+	if pages*4 > peak {
+		t.Errorf("expected the purge to shrink the tree well below %d pages, still uses %d", peak, pages)
+	}
+	if n := checkTree(t, bt); n != 2000 {
+		t.Errorf("expected 2000 keys after purge, got %d", n)
+	}
+
+	// Leaves other than the root are at least a third full
+	cursor := bt.Cursor()
+	defer cursor.Close()
+	for cursor.First(); cursor.Valid(); cursor.Next() {
+		leaf := cursor.stack[len(cursor.stack)-1]
+		if leaf.page.PageNo() != bt.RootPage() && leaf.node.IsUnderfull() {
+			t.Fatalf("leaf page %d is underfull after the purge", leaf.page.PageNo())
+		}
+	}
+}
@@ -89,11 +89,29 @@ func (bt *BTree) RootPage() uint32 {
 	return bt.rootPage
 }
 
-// Insert inserts or updates a key-value pair
+// ResetRootPage points the tree at rootPage again, after a pager rollback
+// restored the pages of an earlier state of the tree
+func (bt *BTree) ResetRootPage(rootPage uint32) {
+	bt.rootPage = rootPage
+}
+
+// Insert inserts or updates a key-value pair. Keys and values too large to keep
+// the cell within MaxLocal bytes continue on overflow pages; replacing or
+// deleting the key frees them again.
 func (bt *BTree) Insert(key, value []byte) error {
+	// Spill the value before descending: allocating overflow pages may remap
+	// the file under the nodes held during the descent
+	cell, overflowPage, err := bt.buildCell(key, value)
+	if err != nil {
+		return err
+	}
+
 	// Use insertRecursive which handles splits
-	_, newRootPage, err := bt.insertRecursive(bt.rootPage, key, value)
+	_, newRootPage, err := bt.insertRecursive(bt.rootPage, key, cell)
 	if err != nil {
+		for _, pageNo := range bt.overflowPages(overflowPage, len(key)+len(value)) {
+			bt.pager.Free(pageNo)
+		}
 		return err
 	}
 
@@ -107,12 +125,14 @@ func (bt *BTree) Insert(key, value []byte) error {
 
 // splitResult is returned when a node split occurs during insertion
 type splitResult struct {
-	promotedKey []byte // Key to promote to parent
-	rightPageNo uint32 // Page number of the new right sibling
+	promotedKey  []byte // Key to promote to parent
+	promotedCell []byte // Separator cell for promotedKey, owning its overflow chain
+	rightPageNo  uint32 // Page number of the new right sibling
 }
 
-// insertRecursive inserts into subtree, returns split info if split occurred
-func (bt *BTree) insertRecursive(pageNo uint32, key, value []byte) (*splitResult, uint32, error) {
+// insertRecursive inserts the encoded leaf cell for key into subtree, returns
+// split info if split occurred
+func (bt *BTree) insertRecursive(pageNo uint32, key, cell []byte) (*splitResult, uint32, error) {
 	page, err := bt.pager.Get(pageNo)
 	if err != nil {
 		return nil, 0, err
@@ -122,26 +142,34 @@ func (bt *BTree) insertRecursive(pageNo uint32, key, value []byte) (*splitResult
 	node := LoadNode(page.Data())
 
 	if node.IsLeaf() {
-		return bt.insertIntoLeaf(page, node, key, value)
+		return bt.insertIntoLeaf(page, node, key, cell)
 	}
-	return bt.insertIntoInterior(page, node, key, value)
+	return bt.insertIntoInterior(page, node, key, cell)
 }
 
 // insertIntoLeaf inserts into a leaf node, handling splits if necessary
-func (bt *BTree) insertIntoLeaf(page *pager.Page, node *Node, key, value []byte) (*splitResult, uint32, error) {
+func (bt *BTree) insertIntoLeaf(page *pager.Page, node *Node, key, cell []byte) (*splitResult, uint32, error) {
 	pos := bt.findPosition(node, key)
 
 	// Check for update (key exists)
 	if pos < node.CellCount() {
-		existingKey, _ := node.GetCell(pos)
-		if bytes.Equal(existingKey, key) {
+		if bt.compareCellKey(node, pos, key) == 0 {
+			if err := bt.freeOverflow(node, pos); err != nil {
+				return nil, 0, err
+			}
+			node.RefreshData(page.Data())
 			node.DeleteCell(pos)
 			pos = bt.findPosition(node, key)
 		}
 	}
 
 	// Try to insert
-	err := node.InsertCell(pos, key, value)
+	err := node.insertRawCell(pos, cell)
+	if err == ErrNodeFull && node.CellSpace()+len(cell)+cellPointerSize <= node.usableSpace() {
+		// Enough room once the space left by deleted cells is reclaimed
+		node.defragment()
+		err = node.insertRawCell(pos, cell)
+	}
 	if err == nil {
 		page.SetDirty(true)
 		return nil, 0, nil
@@ -152,11 +180,11 @@ func (bt *BTree) insertIntoLeaf(page *pager.Page, node *Node, key, value []byte)
 	}
 
 	// Need to split
-	return bt.splitLeaf(page, node, key, value, pos)
+	return bt.splitLeaf(page, node, key, cell)
 }
 
 // splitLeaf splits a full leaf node
-func (bt *BTree) splitLeaf(page *pager.Page, node *Node, key, value []byte, pos int) (*splitResult, uint32, error) {
+func (bt *BTree) splitLeaf(page *pager.Page, node *Node, key, cell []byte) (*splitResult, uint32, error) {
 	// Allocate new page for right sibling
 	// CRITICAL: Allocate() may trigger storage growth which invalidates existing
 	// memory mappings. The Page objects are updated by refreshCacheAfterGrow(),
@@ -171,30 +199,46 @@ func (bt *BTree) splitLeaf(page *pager.Page, node *Node, key, value []byte, pos
 	node.RefreshData(page.Data())
 
 	// Split the node
-	medianKey, rightNode := node.Split(rightPage.Data())
-	if medianKey == nil || rightNode == nil {
+	median, rightNode := node.Split(rightPage.Data())
+	if median == nil || rightNode == nil {
 		return nil, 0, errors.New("failed to split node: invalid data")
 	}
 	rightPage.SetDirty(true)
+	medianKey, err := bt.rawCellKey(median)
+	if err != nil {
+		return nil, 0, err
+	}
 	// Note: Don't use SetType here - the node header already contains type info
 
-	// Insert the new key into appropriate side
+	// Insert the new key into appropriate side. Split balances the halves by
+	// size and no cell exceeds a quarter of the page, so this always fits.
 	if bytes.Compare(key, medianKey) < 0 {
-		node.InsertCell(bt.findPosition(node, key), key, value)
+		err = node.insertRawCell(bt.findPosition(node, key), cell)
 	} else {
-		rightNode.InsertCell(bt.findPosition(rightNode, key), key, value)
+		err = rightNode.insertRawCell(bt.findPosition(rightNode, key), cell)
+	}
+	if err != nil {
+		return nil, 0, err
 	}
 	page.SetDirty(true)
 
+	// The median stays in the right leaf, so the separator needs its own copy
+	// of any overflow chain. Nodes are not touched after this allocation.
+	sepCell, _, err := bt.buildCell(medianKey, encodePageNo(0))
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Create split result
 	split := &splitResult{
-		promotedKey: medianKey,
-		rightPageNo: rightPage.PageNo(),
+		promotedKey:  medianKey,
+		promotedCell: sepCell,
+		rightPageNo:  rightPage.PageNo(),
 	}
 
 	// If this was the root, create a new root
 	if page.PageNo() == bt.rootPage {
-		newRoot, err := bt.createNewRoot(page.PageNo(), medianKey, rightPage.PageNo())
+		newRoot, err := bt.createNewRoot(page.PageNo(), sepCell, rightPage.PageNo())
 		if err != nil {
 			return nil, 0, err
 		}
@@ -205,14 +249,14 @@ func (bt *BTree) splitLeaf(page *pager.Page, node *Node, key, value []byte, pos
 }
 
 // insertIntoInterior inserts into an interior node by descending to the correct child
-func (bt *BTree) insertIntoInterior(page *pager.Page, node *Node, key, value []byte) (*splitResult, uint32, error) {
+func (bt *BTree) insertIntoInterior(page *pager.Page, node *Node, key, cell []byte) (*splitResult, uint32, error) {
 	// Find which child to descend into and track if it's the rightChild
 	childPageNo, childIdx := bt.findChildPageWithIndex(node, key)
 
 	// Recursively insert
 	// CRITICAL: This recursive call may trigger storage growth which invalidates
 	// memory mappings. After return, we must refresh node.data from page.Data().
-	split, newRootPage, err := bt.insertRecursive(childPageNo, key, value)
+	split, newRootPage, err := bt.insertRecursive(childPageNo, key, cell)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -241,9 +285,9 @@ func (bt *BTree) insertIntoInterior(page *pager.Page, node *Node, key, value []b
 		// Insert cell: (promotedKey, originalChild) - "keys < promotedKey go to originalChild"
 		// Update rightChild to point to newRightPage
 		pos := bt.findPosition(node, split.promotedKey)
-		leftChildPtr := encodePageNo(childPageNo)
+		sepCell := withChild(split.promotedCell, node.PageSize(), childPageNo)
 
-		err = node.InsertCell(pos, split.promotedKey, leftChildPtr)
+		err = node.insertRawCell(pos, sepCell)
 		if err == nil {
 			node.SetRightChild(split.rightPageNo)
 			page.SetDirty(true)
@@ -255,16 +299,16 @@ func (bt *BTree) insertIntoInterior(page *pager.Page, node *Node, key, value []b
 		}
 
 		// This interior node is also full, need to split it
-		return bt.splitInteriorFromRightChild(page, node, split.promotedKey, childPageNo, split.rightPageNo)
+		return bt.splitInteriorFromRightChild(page, node, split.promotedKey, sepCell, split.rightPageNo)
 	}
 
 	// The split happened in cell[childIdx].ptr
 	// Insert cell: (promotedKey, originalChild) before the existing cell
 	// Update cell[childIdx].ptr to point to newRightPage
 	pos := bt.findPosition(node, split.promotedKey)
-	leftChildPtr := encodePageNo(childPageNo)
+	sepCell := withChild(split.promotedCell, node.PageSize(), childPageNo)
 
-	err = node.InsertCell(pos, split.promotedKey, leftChildPtr)
+	err = node.insertRawCell(pos, sepCell)
 	if err == nil {
 		// Update the cell that was pointing to the original node
 		// Since we inserted before it, it's now at pos+1
@@ -278,11 +322,12 @@ func (bt *BTree) insertIntoInterior(page *pager.Page, node *Node, key, value []b
 	}
 
 	// This interior node is also full, need to split it
-	return bt.splitInteriorFromCell(page, node, split.promotedKey, childPageNo, split.rightPageNo, childIdx)
+	return bt.splitInteriorFromCell(page, node, split.promotedKey, sepCell, split.rightPageNo, childIdx)
 }
 
-// splitInteriorFromRightChild handles splitting an interior node when the split originated from rightChild
-func (bt *BTree) splitInteriorFromRightChild(page *pager.Page, node *Node, promotedKey []byte, leftChild, rightChild uint32) (*splitResult, uint32, error) {
+// splitInteriorFromRightChild handles splitting an interior node when the split originated from rightChild.
+// promotedCell is the separator for promotedKey, already pointing at the left child.
+func (bt *BTree) splitInteriorFromRightChild(page *pager.Page, node *Node, promotedKey, promotedCell []byte, rightChild uint32) (*splitResult, uint32, error) {
 	// Allocate new page for right sibling
 	// CRITICAL: Allocate() may trigger storage growth - see splitLeaf comment
 	newRightPage, err := bt.pager.Allocate()
@@ -295,31 +340,35 @@ func (bt *BTree) splitInteriorFromRightChild(page *pager.Page, node *Node, promo
 	node.RefreshData(page.Data())
 
 	// Split the interior node
-	medianKey, newRightNode := node.Split(newRightPage.Data())
-	if medianKey == nil || newRightNode == nil {
+	median, newRightNode := node.Split(newRightPage.Data())
+	if median == nil || newRightNode == nil {
 		return nil, 0, errors.New("failed to split interior node: invalid data")
 	}
 	newRightPage.SetDirty(true)
+	medianKey, err := bt.rawCellKey(median)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Now insert the promoted key into the appropriate side
 	// The promoted key points to leftChild, and rightChild becomes the new rightChild
 	if bytes.Compare(promotedKey, medianKey) < 0 {
 		// Insert into left (original) node
 		pos := bt.findPosition(node, promotedKey)
-		node.InsertCell(pos, promotedKey, encodePageNo(leftChild))
+		node.insertRawCell(pos, promotedCell)
 		// The original rightChild was moved during split, update it
 		node.SetRightChild(rightChild)
 	} else {
 		// Insert into right (new) node
 		pos := bt.findPosition(newRightNode, promotedKey)
-		newRightNode.InsertCell(pos, promotedKey, encodePageNo(leftChild))
+		newRightNode.insertRawCell(pos, promotedCell)
 		newRightNode.SetRightChild(rightChild)
 	}
 	page.SetDirty(true)
 
 	// If this was the root, create a new root
 	if page.PageNo() == bt.rootPage {
-		newRoot, err := bt.createNewRoot(page.PageNo(), medianKey, newRightPage.PageNo())
+		newRoot, err := bt.createNewRoot(page.PageNo(), median, newRightPage.PageNo())
 		if err != nil {
 			return nil, 0, err
 		}
@@ -327,14 +376,17 @@ func (bt *BTree) splitInteriorFromRightChild(page *pager.Page, node *Node, promo
 	}
 
 	// Return split result for parent to handle
+	// The median leaves this node, so its cell moves up with its overflow chain
 	return &splitResult{
-		promotedKey: medianKey,
-		rightPageNo: newRightPage.PageNo(),
+		promotedKey:  medianKey,
+		promotedCell: median,
+		rightPageNo:  newRightPage.PageNo(),
 	}, 0, nil
 }
 
-// splitInteriorFromCell handles splitting an interior node when the split originated from a cell pointer
-func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey []byte, leftChild, rightChild uint32, origCellIdx int) (*splitResult, uint32, error) {
+// splitInteriorFromCell handles splitting an interior node when the split originated from a cell pointer.
+// promotedCell is the separator for promotedKey, already pointing at the left child.
+func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey, promotedCell []byte, rightChild uint32, origCellIdx int) (*splitResult, uint32, error) {
 	// Allocate new page for right sibling
 	// CRITICAL: Allocate() may trigger storage growth - see splitLeaf comment
 	newRightPage, err := bt.pager.Allocate()
@@ -347,17 +399,21 @@ func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey
 	node.RefreshData(page.Data())
 
 	// Split the interior node
-	medianKey, newRightNode := node.Split(newRightPage.Data())
-	if medianKey == nil || newRightNode == nil {
+	median, newRightNode := node.Split(newRightPage.Data())
+	if median == nil || newRightNode == nil {
 		return nil, 0, errors.New("failed to split interior node from cell: invalid data")
 	}
 	newRightPage.SetDirty(true)
+	medianKey, err := bt.rawCellKey(median)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Insert the promoted key into the appropriate side
 	if bytes.Compare(promotedKey, medianKey) < 0 {
 		// Insert into left (original) node
 		pos := bt.findPosition(node, promotedKey)
-		node.InsertCell(pos, promotedKey, encodePageNo(leftChild))
+		node.insertRawCell(pos, promotedCell)
 		// Update the next cell to point to rightChild
 		if pos+1 < node.CellCount() {
 			node.UpdateCellValue(pos+1, encodePageNo(rightChild))
@@ -367,7 +423,7 @@ func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey
 	} else {
 		// Insert into right (new) node
 		pos := bt.findPosition(newRightNode, promotedKey)
-		newRightNode.InsertCell(pos, promotedKey, encodePageNo(leftChild))
+		newRightNode.insertRawCell(pos, promotedCell)
 		// Update the next cell to point to rightChild
 		if pos+1 < newRightNode.CellCount() {
 			newRightNode.UpdateCellValue(pos+1, encodePageNo(rightChild))
@@ -379,7 +435,7 @@ func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey
 
 	// If this was the root, create a new root
 	if page.PageNo() == bt.rootPage {
-		newRoot, err := bt.createNewRoot(page.PageNo(), medianKey, newRightPage.PageNo())
+		newRoot, err := bt.createNewRoot(page.PageNo(), median, newRightPage.PageNo())
 		if err != nil {
 			return nil, 0, err
 		}
@@ -387,14 +443,17 @@ func (bt *BTree) splitInteriorFromCell(page *pager.Page, node *Node, promotedKey
 	}
 
 	// Return split result for parent to handle
+	// The median leaves this node, so its cell moves up with its overflow chain
 	return &splitResult{
-		promotedKey: medianKey,
-		rightPageNo: newRightPage.PageNo(),
+		promotedKey:  medianKey,
+		promotedCell: median,
+		rightPageNo:  newRightPage.PageNo(),
 	}, 0, nil
 }
 
-// createNewRoot creates a new root node with the given left child, key, and right child
-func (bt *BTree) createNewRoot(leftPage uint32, key []byte, rightPage uint32) (uint32, error) {
+// createNewRoot creates a new root node with the given left child, separator
+// cell, and right child
+func (bt *BTree) createNewRoot(leftPage uint32, sepCell []byte, rightPage uint32) (uint32, error) {
 	newRootPage, err := bt.pager.Allocate()
 	if err != nil {
 		return 0, err
@@ -403,7 +462,7 @@ func (bt *BTree) createNewRoot(leftPage uint32, key []byte, rightPage uint32) (u
 
 	// Initialize as interior node
 	newRoot := NewNode(newRootPage.Data(), false)
-	newRoot.InsertCell(0, key, encodePageNo(leftPage))
+	newRoot.insertRawCell(0, withChild(sepCell, newRoot.PageSize(), leftPage))
 	newRoot.SetRightChild(rightPage)
 
 	newRootPage.SetDirty(true)
@@ -423,8 +482,8 @@ func (bt *BTree) findChildPage(node *Node, key []byte) uint32 {
 func (bt *BTree) findChildPageWithIndex(node *Node, key []byte) (uint32, int) {
 	count := node.CellCount()
 	for i := 0; i < count; i++ {
-		cellKey, cellValue := node.GetCell(i)
-		if bytes.Compare(key, cellKey) < 0 {
+		if bt.compareCellKey(node, i, key) > 0 {
+			_, cellValue := node.GetCell(i)
 			// Key is less than this cell's key, go to the child pointer in value
 			return decodePageNo(cellValue), i
 		}
@@ -465,12 +524,9 @@ func (bt *BTree) getRecursive(pageNo uint32, key []byte) ([]byte, error) {
 		// Binary search for key in leaf
 		pos := bt.findPosition(node, key)
 		if pos < node.CellCount() {
-			foundKey, value := node.GetCell(pos)
-			if bytes.Equal(foundKey, key) {
+			if bt.compareCellKey(node, pos, key) == 0 {
 				// Return a copy to avoid issues with mmap
-				result := make([]byte, len(value))
-				copy(result, value)
-				return result, nil
+				return bt.cellValue(node, pos)
 			}
 		}
 		return nil, ErrKeyNotFound
@@ -488,9 +544,7 @@ func (bt *BTree) findPosition(node *Node, key []byte) int {
 
 	for lo < hi {
 		mid := (lo + hi) / 2
-		midKey, _ := node.GetCell(mid)
-		cmp := bytes.Compare(midKey, key)
-		if cmp < 0 {
+		if bt.compareCellKey(node, mid, key) < 0 {
 			lo = mid + 1
 		} else {
 			hi = mid
@@ -522,16 +576,18 @@ func (bt *BTree) depthRecursive(pageNo uint32) int {
 	return 1 + bt.depthRecursive(childPage)
 }
 
-// Delete removes a key from the B-tree.
-// This implementation uses a simple approach that allows underflow without
-// aggressive rebalancing, similar to SQLite's lazy delete strategy.
-// The tree remains valid and searchable, though it may become less balanced.
+// Delete removes a key from the B-tree. Nodes left less than a third full are
+// merged with a sibling or take cells from one, and a root left with a single
+// child absorbs it, so pages emptied by deletes return to the pager's freelist.
 func (bt *BTree) Delete(key []byte) error {
-	return bt.deleteSimple(bt.rootPage, key)
+	if _, err := bt.deleteRecursive(bt.rootPage, key); err != nil {
+		return err
+	}
+	return bt.collapseRoot()
 }
 
-// CollectPages returns all page numbers used by this B-tree.
-// This is used for freeing pages when dropping a table or index.
+// CollectPages returns all page numbers used by this B-tree, including
+// overflow pages. This is used for freeing pages when dropping a table or index.
 func (bt *BTree) CollectPages() []uint32 {
 	var pages []uint32
 	bt.collectPagesRecursive(bt.rootPage, &pages)
@@ -549,6 +605,11 @@ func (bt *BTree) collectPagesRecursive(pageNo uint32, pages *[]uint32) {
 	*pages = append(*pages, pageNo)
 
 	node := LoadNode(page.Data())
+	for i := 0; i < node.CellCount(); i++ {
+		if spilled, firstPage := node.CellOverflow(i); firstPage != 0 {
+			*pages = append(*pages, bt.overflowPages(firstPage, spilled)...)
+		}
+	}
 	if !node.IsLeaf() {
 		// Interior nodes store child pointers:
 		// - Each cell's value contains a child page pointer (left child of that key)
@@ -569,12 +630,13 @@ func (bt *BTree) collectPagesRecursive(pageNo uint32, pages *[]uint32) {
 	}
 }
 
-// deleteSimple performs a straightforward delete without complex rebalancing.
-// This approach is used by many production B-tree implementations including SQLite.
-func (bt *BTree) deleteSimple(pageNo uint32, key []byte) error {
+// deleteRecursive removes key from the subtree at pageNo, rebalancing children
+// that underflow on the way back up. It reports whether the node at pageNo is
+// itself underfull afterwards.
+func (bt *BTree) deleteRecursive(pageNo uint32, key []byte) (bool, error) {
 	page, err := bt.pager.Get(pageNo)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer bt.pager.Release(page)
 
@@ -583,18 +645,29 @@ func (bt *BTree) deleteSimple(pageNo uint32, key []byte) error {
 	if node.IsLeaf() {
 		pos := bt.findPosition(node, key)
 		if pos >= node.CellCount() {
-			return ErrKeyNotFound
+			return false, ErrKeyNotFound
+		}
+		if bt.compareCellKey(node, pos, key) != 0 {
+			return false, ErrKeyNotFound
 		}
-		foundKey, _ := node.GetCell(pos)
-		if !bytes.Equal(foundKey, key) {
-			return ErrKeyNotFound
+		if err := bt.freeOverflow(node, pos); err != nil {
+			return false, err
 		}
+		node.RefreshData(page.Data())
 		node.DeleteCell(pos)
 		page.SetDirty(true)
-		return nil
+		return node.IsUnderfull(), nil
 	}
 
 	// Interior node: find child and recurse
-	childPage := bt.findChildPage(node, key)
-	return bt.deleteSimple(childPage, key)
+	childPage, childIdx := bt.findChildPageWithIndex(node, key)
+	underfull, err := bt.deleteRecursive(childPage, key)
+	if err != nil || !underfull {
+		return false, err
+	}
+
+	if err := bt.rebalanceChild(page, node, childIdx); err != nil {
+		return false, err
+	}
+	return node.IsUnderfull(), nil
 }
@@ -2,8 +2,6 @@
 package btree
 
 import (
-	"bytes"
-
 	"tur/pkg/pager"
 )
 
@@ -117,8 +115,7 @@ func (c *Cursor) Seek(key []byte) {
 
 			for lo < hi {
 				mid := (lo + hi) / 2
-				midKey, _ := node.GetCell(mid)
-				if bytes.Compare(midKey, key) < 0 {
+				if c.btree.compareCellKey(node, mid, key) < 0 {
 					lo = mid + 1
 				} else {
 					hi = mid
@@ -145,8 +142,8 @@ func (c *Cursor) Seek(key []byte) {
 
 		count := node.CellCount()
 		for i := 0; i < count; i++ {
-			cellKey, cellValue := node.GetCell(i)
-			if bytes.Compare(key, cellKey) < 0 {
+			if c.btree.compareCellKey(node, i, key) > 0 {
+				_, cellValue := node.GetCell(i)
 				frame.pos = i
 				pageNo = decodePageNo(cellValue)
 				goto descend
@@ -314,11 +311,12 @@ func (c *Cursor) Key() []byte {
 		return nil
 	}
 	leaf := c.stack[len(c.stack)-1]
-	key, _ := leaf.node.GetCell(leaf.pos)
-	// Return a copy
-	result := make([]byte, len(key))
-	copy(result, key)
-	return result
+	// Return a copy, reassembled from overflow pages if needed
+	key, err := c.btree.cellKey(leaf.node, leaf.pos)
+	if err != nil {
+		return nil
+	}
+	return key
 }
 
 // Value returns the current value (only valid if Valid() is true)
@@ -327,11 +325,12 @@ func (c *Cursor) Value() []byte {
 		return nil
 	}
 	leaf := c.stack[len(c.stack)-1]
-	_, value := leaf.node.GetCell(leaf.pos)
-	// Return a copy
-	result := make([]byte, len(value))
-	copy(result, value)
-	return result
+	// Return a copy, reassembled from overflow pages if needed
+	value, err := c.btree.cellValue(leaf.node, leaf.pos)
+	if err != nil {
+		return nil
+	}
+	return value
 }
 
 // Close releases resources held by the cursor
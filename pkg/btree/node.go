@@ -27,19 +27,134 @@ Node Page Layout (SQLite-inspired):
 | Cell Content     |
 | (grows upward)   |
 +------------------+
+
+Cell Layout:
+  key length (varint) | key | value length (varint) | value
+
+A payload too large to keep the cell within MaxLocal bytes spills onto a chain
+of overflow pages (SQLite-style). Its cell stores the full key and value lengths,
+only the first localKeySize bytes of the key and localValueSize bytes of the
+value, and then the first overflow page:
+  key length | key prefix | value length | value prefix | first overflow page (4)
+The chain holds the rest of the key followed by the rest of the value. Keys of
+up to MaxKeySize bytes are always stored whole; longer keys keep minLocal bytes
+in the cell, which also leaves an interior cell's child pointer in place.
+Whether a cell overflows follows from the key and value lengths and the page
+size alone, so no flag is stored.
 */
 
 const (
 	nodeHeaderSize       = 12
 	cellPointerSize      = 2
 	flagLeaf        byte = 0x01
+
+	maxVarintLen        = 9 // Largest encoded size of a cell length
+	overflowPointerSize = 4 // First overflow page number at the end of a spilled cell
 )
 
 var (
 	ErrNodeFull     = errors.New("node is full")
 	ErrCellNotFound = errors.New("cell not found")
+	ErrCellOverflow = errors.New("cell payload must be stored on overflow pages")
 )
 
+// MaxLocal returns the largest key plus value payload kept entirely inside a cell
+// on a page of the given size. Capping cells at a quarter of the page means any
+// node holds at least four cells, so a split always makes room for a new cell.
+func MaxLocal(pageSize int) int {
+	return (pageSize-nodeHeaderSize)/4 - cellPointerSize - 2*maxVarintLen
+}
+
+// MaxKeySize returns the largest key stored whole in a cell on a page of the
+// given size. Longer keys keep a prefix in the cell and spill the rest, so
+// comparing them may have to read their overflow chain.
+func MaxKeySize(pageSize int) int {
+	return MaxLocal(pageSize) - overflowPointerSize
+}
+
+// minLocal returns how much payload an overflowing cell keeps on its page
+func minLocal(pageSize int) int {
+	return (pageSize - nodeHeaderSize) / 16
+}
+
+// localKeySize returns how many bytes of a key are stored in its cell. It
+// depends on the key length alone, so a cell can be parsed front to back.
+func localKeySize(pageSize, keyLen int) int {
+	if keyLen > MaxKeySize(pageSize) {
+		return minLocal(pageSize)
+	}
+	return keyLen
+}
+
+// localValueSize returns how many bytes of a value are stored in its cell; any
+// remainder lives on overflow pages.
+func localValueSize(pageSize, keyLen, valueLen int) int {
+	if keyLen > MaxKeySize(pageSize) {
+		return min(valueLen, minLocal(pageSize))
+	}
+	if keyLen+valueLen <= MaxLocal(pageSize) {
+		return valueLen
+	}
+	local := minLocal(pageSize) - keyLen
+	if local < 0 {
+		local = 0
+	}
+	return local
+}
+
+// encodeCell builds a cell from the local parts of a key of keyLen bytes and a
+// value of valueLen bytes. overflowPage is the first overflow page when either
+// local part is shorter than its full length.
+func encodeCell(key []byte, keyLen int, local []byte, valueLen int, overflowPage uint32) []byte {
+	spilled := len(key) < keyLen || len(local) < valueLen
+	size := encoding.VarintLen(uint64(keyLen)) + len(key) + encoding.VarintLen(uint64(valueLen)) + len(local)
+	if spilled {
+		size += overflowPointerSize
+	}
+
+	cell := make([]byte, size)
+	offset := encoding.PutVarint(cell, uint64(keyLen))
+	offset += copy(cell[offset:], key)
+	offset += encoding.PutVarint(cell[offset:], uint64(valueLen))
+	offset += copy(cell[offset:], local)
+	if spilled {
+		binary.LittleEndian.PutUint32(cell[offset:], overflowPage)
+	}
+	return cell
+}
+
+// parseRawCell decodes an encoded cell from a page of the given size. key and
+// local are the parts of the key and value stored in the cell, and size is the
+// length of the encoding.
+func parseRawCell(cell []byte, pageSize int) (key []byte, keyLen int, local []byte, valueLen int, overflowPage uint32, size int) {
+	kLen, offset := encoding.GetVarint(cell)
+	keyLen = int(kLen)
+	keyLocal := localKeySize(pageSize, keyLen)
+	key = cell[offset : offset+keyLocal]
+	offset += keyLocal
+
+	vLen, sz := encoding.GetVarint(cell[offset:])
+	offset += sz
+	valueLen = int(vLen)
+	localLen := localValueSize(pageSize, keyLen, valueLen)
+	local = cell[offset : offset+localLen]
+	offset += localLen
+
+	if keyLocal < keyLen || localLen < valueLen {
+		overflowPage = binary.LittleEndian.Uint32(cell[offset:])
+		offset += overflowPointerSize
+	}
+	return key, keyLen, local, valueLen, overflowPage, offset
+}
+
+// withChild returns a copy of an encoded interior cell pointing at child
+func withChild(cell []byte, pageSize int, child uint32) []byte {
+	cell = append([]byte(nil), cell...)
+	_, _, ptr, _, _, _ := parseRawCell(cell, pageSize)
+	binary.LittleEndian.PutUint32(ptr, child)
+	return cell
+}
+
 // Node represents a B-tree node backed by a page
 type Node struct {
 	data []byte
@@ -142,11 +257,29 @@ func (n *Node) setCellOffset(i, offset int) {
 	binary.LittleEndian.PutUint16(n.data[ptrOffset:], uint16(offset))
 }
 
-// InsertCell inserts a key-value cell at position i
+// InsertCell inserts a key-value cell at position i. Keys or values that would
+// have to spill onto overflow pages are rejected with ErrCellOverflow; the
+// B-tree stores those with InsertOverflowCell.
 func (n *Node) InsertCell(i int, key, value []byte) error {
-	// Calculate cell size: key_len(varint) + key + value_len(varint) + value
-	cellSize := encoding.VarintLen(uint64(len(key))) + len(key) +
-		encoding.VarintLen(uint64(len(value))) + len(value)
+	if localKeySize(len(n.data), len(key)) < len(key) || localValueSize(len(n.data), len(key), len(value)) < len(value) {
+		return ErrCellOverflow
+	}
+	return n.insertRawCell(i, encodeCell(key, len(key), value, len(value), 0))
+}
+
+// InsertOverflowCell inserts a cell at position i whose value of valueLen bytes
+// keeps local in the cell and continues on the overflow chain starting at
+// overflowPage. The key must be short enough to be stored whole.
+func (n *Node) InsertOverflowCell(i int, key, local []byte, valueLen int, overflowPage uint32) error {
+	if localKeySize(len(n.data), len(key)) < len(key) || len(local) != localValueSize(len(n.data), len(key), valueLen) {
+		return ErrCellOverflow
+	}
+	return n.insertRawCell(i, encodeCell(key, len(key), local, valueLen, overflowPage))
+}
+
+// insertRawCell inserts an encoded cell at position i
+func (n *Node) insertRawCell(i int, cell []byte) error {
+	cellSize := len(cell)
 
 	// Check if we have enough space
 	spaceNeeded := cellSize + cellPointerSize
@@ -166,12 +299,7 @@ func (n *Node) InsertCell(i int, key, value []byte) error {
 	n.setFreeEnd(newFreeEnd)
 
 	// Write cell content
-	offset := newFreeEnd
-	offset += encoding.PutVarint(n.data[offset:], uint64(len(key)))
-	copy(n.data[offset:], key)
-	offset += len(key)
-	offset += encoding.PutVarint(n.data[offset:], uint64(len(value)))
-	copy(n.data[offset:], value)
+	copy(n.data[newFreeEnd:], cell)
 
 	// Set cell pointer
 	n.setCellOffset(i, newFreeEnd)
@@ -183,26 +311,41 @@ func (n *Node) InsertCell(i int, key, value []byte) error {
 	return nil
 }
 
-// GetCell returns the key and value at position i
+// GetCell returns the key and value at position i. For a cell that overflows,
+// key and value are only the parts stored on this page (see CellOverflow).
 func (n *Node) GetCell(i int) (key, value []byte) {
+	key, _, value, _, _ = n.parseCell(i)
+	return key, value
+}
+
+// CellOverflow returns how many bytes of the key and value of cell i live on
+// overflow pages and the first page of the chain, or 0 and 0 if the whole
+// cell is stored on this page.
+func (n *Node) CellOverflow(i int) (spilled int, overflowPage uint32) {
+	key, keyLen, local, valueLen, overflowPage := n.parseCell(i)
+	return keyLen - len(key) + valueLen - len(local), overflowPage
+}
+
+// parseCell decodes cell i
+func (n *Node) parseCell(i int) (key []byte, keyLen int, local []byte, valueLen int, overflowPage uint32) {
 	if i < 0 || i >= n.CellCount() {
-		return nil, nil
+		return nil, 0, nil, 0, 0
 	}
+	key, keyLen, local, valueLen, overflowPage, _ = parseRawCell(n.data[n.getCellOffset(i):], len(n.data))
+	return key, keyLen, local, valueLen, overflowPage
+}
 
+// rawCell returns the encoded bytes of cell i
+func (n *Node) rawCell(i int) []byte {
 	offset := n.getCellOffset(i)
+	_, _, _, _, _, size := parseRawCell(n.data[offset:], len(n.data))
+	return n.data[offset : offset+size]
+}
 
-	// Read key
-	keyLen, sz := encoding.GetVarint(n.data[offset:])
-	offset += sz
-	key = n.data[offset : offset+int(keyLen)]
-	offset += int(keyLen)
-
-	// Read value
-	valueLen, sz := encoding.GetVarint(n.data[offset:])
-	offset += sz
-	value = n.data[offset : offset+int(valueLen)]
-
-	return key, value
+// setCellOverflowPage changes the first overflow page of spilled cell i
+func (n *Node) setCellOverflowPage(i int, pageNo uint32) {
+	cell := n.rawCell(i)
+	binary.LittleEndian.PutUint32(cell[len(cell)-overflowPointerSize:], pageNo)
 }
 
 // SetRightChild sets the right child page number (interior nodes only)
@@ -215,8 +358,10 @@ func (n *Node) RightChild() uint32 {
 	return binary.LittleEndian.Uint32(n.data[8:12])
 }
 
-// Split splits the node at midpoint, moving upper half to rightNode
-// Returns the median key that should be promoted to the parent
+// Split splits the node at midpoint, moving upper half to rightNode.
+// Returns a copy of the median cell, whose key should be promoted to the
+// parent: a leaf keeps the median as the first cell of the right node, while
+// an interior node gives it up along with any overflow chain of its key.
 func (n *Node) Split(rightData []byte) ([]byte, *Node) {
 	// Validate inputs - only check for nil/empty data which would cause panics
 	if rightData == nil || len(rightData) < nodeHeaderSize {
@@ -233,7 +378,7 @@ func (n *Node) Split(rightData []byte) ([]byte, *Node) {
 	if count == 0 {
 		return nil, nil
 	}
-	mid := count / 2
+	mid := n.splitPoint()
 
 	// Create right node with same type (leaf or interior)
 	right := NewNode(rightData, n.IsLeaf())
@@ -246,14 +391,14 @@ func (n *Node) Split(rightData []byte) ([]byte, *Node) {
 	}
 
 	for i := startIdx; i < count; i++ {
-		key, value := n.GetCell(i)
-		right.InsertCell(right.CellCount(), key, value)
+		right.insertRawCell(right.CellCount(), n.rawCell(i))
 	}
 
-	// Get median key (will be promoted to parent)
-	medianKey, medianValue := n.GetCell(mid)
-	medianKeyCopy := make([]byte, len(medianKey))
-	copy(medianKeyCopy, medianKey)
+	// Get median cell (its key will be promoted to parent). Copy it, since
+	// truncating rewrites the cell area.
+	median := append([]byte(nil), n.rawCell(mid)...)
+	_, medianValue := n.GetCell(mid)
+	medianValue = append([]byte(nil), medianValue...)
 
 	// For interior nodes, the right child of the median becomes the leftmost child of right
 	if !n.IsLeaf() {
@@ -276,7 +421,29 @@ func (n *Node) Split(rightData []byte) ([]byte, *Node) {
 		}
 	}
 
-	return medianKeyCopy, right
+	return median, right
+}
+
+// splitPoint returns the index of the median cell: the first cell at which the
+// cells before it hold at least half of the node's cell bytes. Splitting by size
+// rather than by count keeps both halves at most about half full even when cell
+// sizes vary widely.
+func (n *Node) splitPoint() int {
+	count := n.CellCount()
+	total := 0
+	for i := 0; i < count; i++ {
+		total += len(n.rawCell(i))
+	}
+
+	mid, size := 0, 0
+	for mid < count-1 && size*2 < total {
+		size += len(n.rawCell(mid))
+		mid++
+	}
+	if mid == 0 && count > 1 {
+		mid = 1
+	}
+	return mid
 }
 
 // truncateTo keeps only the first n cells, resetting free space
@@ -285,41 +452,62 @@ func (n *Node) truncateTo(count int) {
 		return
 	}
 
-	// We need to rebuild the node with only the first count cells
-	// This is inefficient but correct - a production implementation
-	// would track cell boundaries more carefully
-
 	// Save cells we want to keep
-	cells := make([]struct{ key, value []byte }, count)
+	cells := make([][]byte, count)
 	for i := 0; i < count; i++ {
-		k, v := n.GetCell(i)
-		cells[i].key = make([]byte, len(k))
-		cells[i].value = make([]byte, len(v))
-		copy(cells[i].key, k)
-		copy(cells[i].value, v)
+		cells[i] = append([]byte(nil), n.rawCell(i)...)
 	}
 
-	// Save node properties
-	isLeaf := n.IsLeaf()
-	rightChild := n.RightChild()
-	pageSize := len(n.data)
+	n.rewrite(cells, n.RightChild())
+}
 
-	// Reinitialize node
-	if isLeaf {
-		n.data[0] = flagLeaf
-	} else {
-		n.data[0] = 0
+// defragment rewrites the cells contiguously, reclaiming the space that
+// DeleteCell leaves behind
+func (n *Node) defragment() {
+	cells := make([][]byte, n.CellCount())
+	for i := range cells {
+		cells[i] = append([]byte(nil), n.rawCell(i)...)
 	}
-	binary.LittleEndian.PutUint16(n.data[1:3], 0)
-	binary.LittleEndian.PutUint16(n.data[3:5], nodeHeaderSize)
-	binary.LittleEndian.PutUint16(n.data[5:7], uint16(pageSize))
-	n.data[7] = 0
+	n.rewrite(cells, n.RightChild())
+}
+
+// rewrite reinitializes the node to hold exactly the given encoded cells.
+// The cells must not alias the node's data.
+func (n *Node) rewrite(cells [][]byte, rightChild uint32) error {
+	// Reinitialize node, keeping its type
+	NewNode(n.data, n.IsLeaf())
 	n.SetRightChild(rightChild)
 
 	// Re-insert cells
 	for i, cell := range cells {
-		n.InsertCell(i, cell.key, cell.value)
+		if err := n.insertRawCell(i, cell); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CellSpace returns the bytes taken by the node's cells and cell pointers,
+// not counting space left fragmented by deletes
+func (n *Node) CellSpace() int {
+	count := n.CellCount()
+	size := count * cellPointerSize
+	for i := 0; i < count; i++ {
+		size += len(n.rawCell(i))
 	}
+	return size
+}
+
+// usableSpace returns the bytes available for cells and cell pointers
+func (n *Node) usableSpace() int {
+	return len(n.data) - nodeHeaderSize
+}
+
+// IsUnderfull reports whether a non-root node uses less than a third of its
+// space, in which case Delete merges it with a sibling or moves cells over
+// from one
+func (n *Node) IsUnderfull() bool {
+	return n.CellSpace() < n.usableSpace()/3
 }
 
 // DeleteCell removes the cell at position i
@@ -349,9 +537,9 @@ func (n *Node) UpdateCellValue(i int, newValue []byte) {
 
 	offset := n.getCellOffset(i)
 
-	// Skip key
+	// Skip key, or the local part of it
 	keyLen, sz := encoding.GetVarint(n.data[offset:])
-	offset += sz + int(keyLen)
+	offset += sz + localKeySize(len(n.data), int(keyLen))
 
 	// Read old value length
 	oldValueLen, sz := encoding.GetVarint(n.data[offset:])
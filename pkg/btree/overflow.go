@@ -0,0 +1,283 @@
+// pkg/btree/overflow.go
+package btree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"tur/pkg/pager"
+)
+
+/*
+Overflow Page Layout:
++------------------+
+| Type (1 byte)    | pager.PageTypeOverflow
+| Next Page (4)    | 0 on the last page of a chain
++------------------+
+| Payload          |
++------------------+
+*/
+
+const overflowHeaderSize = 5
+
+var ErrCorruptOverflow = errors.New("corrupt overflow chain")
+
+// writeOverflow stores data on a newly allocated chain of overflow pages and
+// returns its first page. Allocation may remap the file, so callers must
+// refresh any node they hold afterwards.
+func (bt *BTree) writeOverflow(data []byte) (uint32, error) {
+	chunkSize := bt.pager.PageSize() - overflowHeaderSize
+	var first, prev uint32
+	for start := 0; start < len(data); start += chunkSize {
+		end := start + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		page, err := bt.pager.Allocate()
+		if err != nil {
+			return 0, err
+		}
+		pageNo := page.PageNo()
+		buf := page.Data()
+		buf[0] = byte(pager.PageTypeOverflow)
+		binary.LittleEndian.PutUint32(buf[1:5], 0)
+		copy(buf[overflowHeaderSize:], data[start:end])
+		page.SetDirty(true)
+		bt.pager.Release(page)
+
+		if prev == 0 {
+			first = pageNo
+		} else {
+			prevPage, err := bt.pager.Get(prev)
+			if err != nil {
+				return 0, err
+			}
+			binary.LittleEndian.PutUint32(prevPage.Data()[1:5], pageNo)
+			prevPage.SetDirty(true)
+			bt.pager.Release(prevPage)
+		}
+		prev = pageNo
+	}
+	return first, nil
+}
+
+// readOverflow appends size bytes read from the chain starting at firstPage to
+// buf, after skipping the first skip bytes of the chain
+func (bt *BTree) readOverflow(buf []byte, firstPage uint32, skip, size int) ([]byte, error) {
+	for pageNo := firstPage; size > 0; {
+		if pageNo == 0 {
+			return nil, ErrCorruptOverflow
+		}
+		page, err := bt.pager.Get(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		data := page.Data()
+		if pager.PageType(data[0]) != pager.PageTypeOverflow {
+			bt.pager.Release(page)
+			return nil, ErrCorruptOverflow
+		}
+		payload := data[overflowHeaderSize:]
+		if skip >= len(payload) {
+			skip -= len(payload)
+		} else {
+			payload = payload[skip:]
+			skip = 0
+			n := min(size, len(payload))
+			buf = append(buf, payload[:n]...)
+			size -= n
+		}
+		pageNo = binary.LittleEndian.Uint32(data[1:5])
+		bt.pager.Release(page)
+	}
+	return buf, nil
+}
+
+// overflowPages returns the pages of the chain holding size bytes from firstPage
+func (bt *BTree) overflowPages(firstPage uint32, size int) []uint32 {
+	chunkSize := bt.pager.PageSize() - overflowHeaderSize
+	var pages []uint32
+	for pageNo := firstPage; pageNo != 0 && size > 0; size -= chunkSize {
+		page, err := bt.pager.Get(pageNo)
+		if err != nil {
+			break
+		}
+		pages = append(pages, pageNo)
+		pageNo = binary.LittleEndian.Uint32(page.Data()[1:5])
+		bt.pager.Release(page)
+	}
+	return pages
+}
+
+// freeOverflow returns the overflow pages of cell i, if any, to the freelist
+func (bt *BTree) freeOverflow(node *Node, i int) error {
+	spilled, firstPage := node.CellOverflow(i)
+	if firstPage == 0 {
+		return nil
+	}
+	key, _ := node.GetCell(i)
+	for _, pageNo := range bt.overflowPages(firstPage, spilled) {
+		if err := bt.pager.Free(pageNo); err != nil {
+			return fmt.Errorf("freeing overflow page %d of key %x: %w", pageNo, key, err)
+		}
+	}
+	return nil
+}
+
+// cellValue returns a copy of the full value of cell i, reading the overflow
+// chain when the value does not fit on the node page
+func (bt *BTree) cellValue(node *Node, i int) ([]byte, error) {
+	key, keyLen, local, valueLen, firstPage := node.parseCell(i)
+
+	result := make([]byte, len(local), valueLen)
+	copy(result, local)
+	if len(local) == valueLen {
+		return result, nil
+	}
+	return bt.readOverflow(result, firstPage, keyLen-len(key), valueLen-len(local))
+}
+
+// cellKey returns a copy of the full key of cell i, reading the overflow chain
+// when the key does not fit on the node page
+func (bt *BTree) cellKey(node *Node, i int) ([]byte, error) {
+	return bt.rawCellKey(node.rawCell(i))
+}
+
+// rawCellKey returns a copy of the full key of an encoded cell
+func (bt *BTree) rawCellKey(cell []byte) ([]byte, error) {
+	key, keyLen, _, _, firstPage, _ := parseRawCell(cell, bt.pager.PageSize())
+
+	result := make([]byte, len(key), keyLen)
+	copy(result, key)
+	if len(key) == keyLen {
+		return result, nil
+	}
+	return bt.readOverflow(result, firstPage, 0, keyLen-len(key))
+}
+
+// compareCellKey compares the key of cell i with key. The part of the key
+// stored in the cell decides unless key starts with all of it; only then is
+// the rest read from the overflow chain.
+func (bt *BTree) compareCellKey(node *Node, i int, key []byte) int {
+	local, keyLen, _, _, _ := node.parseCell(i)
+	if len(local) == keyLen || len(key) < len(local) {
+		return bytes.Compare(local, key)
+	}
+	if cmp := bytes.Compare(local, key[:len(local)]); cmp != 0 {
+		return cmp
+	}
+	full, err := bt.cellKey(node, i)
+	if err != nil {
+		// CheckOverflowPages reports the broken chain; order by what is left
+		return bytes.Compare(local, key)
+	}
+	return bytes.Compare(full, key)
+}
+
+// buildCell encodes key and value as a cell, first spilling the parts of the
+// key and value that do not fit in the cell onto overflow pages. It also
+// returns the first overflow page, or 0 if everything fits in the cell.
+func (bt *BTree) buildCell(key, value []byte) ([]byte, uint32, error) {
+	pageSize := bt.pager.PageSize()
+	keyLocal := localKeySize(pageSize, len(key))
+	local := localValueSize(pageSize, len(key), len(value))
+	if keyLocal == len(key) && local == len(value) {
+		return encodeCell(key, len(key), value, len(value), 0), 0, nil
+	}
+
+	spill := make([]byte, 0, len(key)-keyLocal+len(value)-local)
+	spill = append(append(spill, key[keyLocal:]...), value[local:]...)
+	firstPage, err := bt.writeOverflow(spill)
+	if err != nil {
+		return nil, 0, err
+	}
+	return encodeCell(key[:keyLocal], len(key), value[:local], len(value), firstPage), firstPage, nil
+}
+
+// CheckOverflowPages verifies the overflow chains of all cells, in leaves and
+// interior nodes: every page must be an overflow page, each chain must hold
+// exactly the spilled bytes of its cell, and no page may be shared between
+// chains or with the tree itself.
+func (bt *BTree) CheckOverflowPages() []error {
+	owner := make(map[uint32]string)
+	var errs []error
+	bt.checkOverflowRecursive(bt.rootPage, owner, &errs)
+	return errs
+}
+
+func (bt *BTree) checkOverflowRecursive(pageNo uint32, owner map[uint32]string, errs *[]error) {
+	page, err := bt.pager.Get(pageNo)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("page %d: %w", pageNo, err))
+		return
+	}
+	defer bt.pager.Release(page)
+
+	if prev, ok := owner[pageNo]; ok {
+		*errs = append(*errs, fmt.Errorf("page %d is referenced by %s and as a tree node", pageNo, prev))
+		return
+	}
+	owner[pageNo] = "the tree"
+
+	node := LoadNode(page.Data())
+	count := node.CellCount()
+	chunkSize := bt.pager.PageSize() - overflowHeaderSize
+	for i := 0; i < count; i++ {
+		spilled, firstPage := node.CellOverflow(i)
+		if firstPage == 0 {
+			continue
+		}
+		key, _ := node.GetCell(i)
+		name := fmt.Sprintf("key %x on page %d", key, pageNo)
+		remaining := spilled
+
+		for next := firstPage; next != 0; {
+			if prev, ok := owner[next]; ok {
+				*errs = append(*errs, fmt.Errorf("overflow page %d of %s is also used by %s", next, name, prev))
+				break
+			}
+			owner[next] = name
+
+			ovfl, err := bt.pager.Get(next)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("overflow page %d of %s: %w", next, name, err))
+				break
+			}
+			data := ovfl.Data()
+			pageType := pager.PageType(data[0])
+			following := binary.LittleEndian.Uint32(data[1:5])
+			bt.pager.Release(ovfl)
+
+			if pageType != pager.PageTypeOverflow {
+				*errs = append(*errs, fmt.Errorf("page %d in overflow chain of %s has type 0x%02x", next, name, byte(pageType)))
+				break
+			}
+			remaining -= chunkSize
+			if remaining <= 0 {
+				if following != 0 {
+					*errs = append(*errs, fmt.Errorf("overflow chain of %s continues past its %d bytes at page %d", name, spilled, following))
+				}
+				break
+			}
+			if following == 0 {
+				*errs = append(*errs, fmt.Errorf("overflow chain of %s ends %d bytes short", name, remaining))
+			}
+			next = following
+		}
+	}
+
+	if !node.IsLeaf() {
+		for i := 0; i < count; i++ {
+			_, childPtr := node.GetCell(i)
+			if child := decodePageNo(childPtr); child != 0 {
+				bt.checkOverflowRecursive(child, owner, errs)
+			}
+		}
+		if rightChild := node.RightChild(); rightChild != 0 {
+			bt.checkOverflowRecursive(rightChild, owner, errs)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// pkg/btree/overflow_test.go
+package btree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"tur/pkg/pager"
+)
+
+// largeValue returns a size-byte value whose content depends on seed
+func largeValue(seed, size int) []byte {
+	value := make([]byte, size)
+	for i := range value {
+		value[i] = byte(seed*31 + i*7)
+	}
+	return value
+}
+
+func openTestPager(t *testing.T, pageSize int) *pager.Pager {
+	t.Helper()
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{PageSize: pageSize})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestLocalValueSize(t *testing.T) {
+	pageSize := 4096
+	maxLocal := MaxLocal(pageSize)
+
+	if got := localValueSize(pageSize, 8, maxLocal-8); got != maxLocal-8 {
+		t.Errorf("value filling MaxLocal should stay local, got %d local bytes", got)
+	}
+	if got := localValueSize(pageSize, 8, maxLocal-7); got != minLocal(pageSize)-8 {
+		t.Errorf("overflowing value should keep minLocal-keyLen bytes, got %d", got)
+	}
+
+	// Keys longer than MaxKeySize keep minLocal bytes of key and value
+	longKey := MaxKeySize(pageSize) + 1
+	if got := localKeySize(pageSize, longKey); got != minLocal(pageSize) {
+		t.Errorf("long key should keep minLocal bytes, got %d", got)
+	}
+	if got := localValueSize(pageSize, longKey, 4); got != 4 {
+		t.Errorf("child pointer of a long key should stay local, got %d bytes", got)
+	}
+
+	// Four maximum-size cells must fit on an empty node
+	cell := encodeCell(make([]byte, 8), 8, make([]byte, maxLocal-8), maxLocal-8, 0)
+	node := NewNode(make([]byte, pageSize), true)
+	for i := 0; i < 4; i++ {
+		if err := node.insertRawCell(i, cell); err != nil {
+			t.Fatalf("cell %d of maximum size did not fit: %v", i, err)
+		}
+	}
+}
+
+func TestNodeInsertCellRejectsOverflow(t *testing.T) {
+	node := NewNode(make([]byte, 4096), true)
+	if err := node.InsertCell(0, []byte("key"), make([]byte, 4000)); !errors.Is(err, ErrCellOverflow) {
+		t.Errorf("expected ErrCellOverflow, got %v", err)
+	}
+}
+
+func TestBTreeOverflowInsertGet(t *testing.T) {
+	p := openTestPager(t, 4096)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	sizes := []int{100, 1000, 4096, 16 * 1024, 100 * 1024}
+	for i, size := range sizes {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if err := bt.Insert(key, largeValue(i, size)); err != nil {
+			t.Fatalf("insert of %d byte value failed: %v", size, err)
+		}
+	}
+
+	for i, size := range sizes {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		value, err := bt.Get(key)
+		if err != nil {
+			t.Fatalf("get %s failed: %v", key, err)
+		}
+		if !bytes.Equal(value, largeValue(i, size)) {
+			t.Errorf("value of %s: got %d bytes, want %d matching bytes", key, len(value), size)
+		}
+	}
+
+	// Cursor reassembles values too
+	cursor := bt.Cursor()
+	defer cursor.Close()
+	i := 0
+	for cursor.First(); cursor.Valid(); cursor.Next() {
+		if !bytes.Equal(cursor.Value(), largeValue(i, sizes[i])) {
+			t.Errorf("cursor value %d does not match", i)
+		}
+		i++
+	}
+	if i != len(sizes) {
+		t.Errorf("cursor visited %d entries, want %d", i, len(sizes))
+	}
+
+	if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+		t.Errorf("unexpected overflow errors: %v", errs)
+	}
+}
+
+func TestBTreeOverflowManyLargeRecords(t *testing.T) {
+	p := openTestPager(t, 512)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	// Enough records with spilled values to force several levels of splits
+	const n = 300
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%05d", i))
+		if err := bt.Insert(key, largeValue(i, 200+i*13%900)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	if bt.Depth() < 2 {
+		t.Errorf("expected the tree to split, depth is %d", bt.Depth())
+	}
+
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%05d", i))
+		value, err := bt.Get(key)
+		if err != nil {
+			t.Fatalf("get %d failed: %v", i, err)
+		}
+		if !bytes.Equal(value, largeValue(i, 200+i*13%900)) {
+			t.Fatalf("value %d does not match", i)
+		}
+	}
+	if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+		t.Errorf("unexpected overflow errors: %v", errs)
+	}
+}
+
+func TestBTreeOverflowFreedOnUpdateAndDelete(t *testing.T) {
+	p := openTestPager(t, 4096)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	key := []byte("big")
+	if err := bt.Insert(key, largeValue(1, 16*1024)); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	pageCount := p.PageCount()
+	if len(bt.CollectPages()) != 5 {
+		t.Errorf("expected the root and 4 overflow pages, got %v", bt.CollectPages())
+	}
+
+	// Replacing with a small value frees the chain
+	if err := bt.Insert(key, []byte("small")); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := p.FreePageCount(); got != 4 {
+		t.Errorf("expected 4 free pages after update, got %d", got)
+	}
+
+	// A new large value reuses the freed pages
+	if err := bt.Insert(key, largeValue(2, 16*1024)); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if p.PageCount() != pageCount {
+		t.Errorf("expected freed pages to be reused, page count grew from %d to %d", pageCount, p.PageCount())
+	}
+	value, err := bt.Get(key)
+	if err != nil || !bytes.Equal(value, largeValue(2, 16*1024)) {
+		t.Fatalf("get after update returned %d bytes, err %v", len(value), err)
+	}
+
+	if err := bt.Delete(key); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := p.FreePageCount(); got != 4 {
+		t.Errorf("expected 4 free pages after delete, got %d", got)
+	}
+	if pages := bt.CollectPages(); len(pages) != 1 {
+		t.Errorf("expected only the root page after delete, got %v", pages)
+	}
+}
+
+func TestBTreeOverflowLongKeys(t *testing.T) {
+	p := openTestPager(t, 4096)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	// Index-style keys: long shared prefixes that only differ near the end,
+	// so separators in interior nodes spill as well
+	prefix := bytes.Repeat([]byte("x"), 2000)
+	key := func(i int) []byte {
+		return append(append([]byte(nil), prefix...), fmt.Sprintf("%04d", i)...)
+	}
+	const n = 200
+	for i := 0; i < n; i++ {
+		if err := bt.Insert(key((i*37)%n), []byte(fmt.Sprintf("v%d", (i*37)%n))); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	if bt.Depth() < 3 {
+		t.Fatalf("expected interior nodes with long separators, depth is %d", bt.Depth())
+	}
+	if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+		t.Fatalf("overflow check failed: %v", errs)
+	}
+
+	for i := 0; i < n; i++ {
+		value, err := bt.Get(key(i))
+		if err != nil || string(value) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("get %d returned %q, err %v", i, value, err)
+		}
+	}
+
+	cursor := bt.Cursor()
+	cursor.Seek(key(150))
+	for i := 150; i < n; i++ {
+		if !cursor.Valid() || !bytes.Equal(cursor.Key(), key(i)) {
+			t.Fatalf("cursor at %d does not hold the full key", i)
+		}
+		cursor.Next()
+	}
+	if cursor.Valid() {
+		t.Errorf("cursor should be exhausted")
+	}
+	cursor.Close()
+
+	// Deleting everything rebalances through the long separators and must
+	// give back every overflow page
+	for i := 0; i < n; i++ {
+		if err := bt.Delete(key(i)); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+		if i%50 == 0 {
+			if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+				t.Fatalf("overflow check after delete %d failed: %v", i, errs)
+			}
+		}
+	}
+	if pages := bt.CollectPages(); len(pages) != 1 {
+		t.Errorf("expected only the root page after deleting all keys, got %d pages", len(pages))
+	}
+	if got, want := p.FreePageCount(), p.PageCount()-2; got != want {
+		t.Errorf("expected %d free pages, got %d", want, got)
+	}
+}
+
+func TestBTreeCheckOverflowPages_DetectsCorruption(t *testing.T) {
+	p := openTestPager(t, 4096)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+	if err := bt.Insert([]byte("big"), largeValue(0, 10000)); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// Overwrite the type of the second overflow page
+	pages := bt.CollectPages()
+	page, err := p.Get(pages[2])
+	if err != nil {
+		t.Fatalf("get page failed: %v", err)
+	}
+	page.Data()[0] = byte(pager.PageTypeBTreeLeaf)
+	page.SetDirty(true)
+	p.Release(page)
+
+	if errs := bt.CheckOverflowPages(); len(errs) != 1 {
+		t.Errorf("expected one overflow error, got %v", errs)
+	}
+	if _, err := bt.Get([]byte("big")); !errors.Is(err, ErrCorruptOverflow) {
+		t.Errorf("expected ErrCorruptOverflow from Get, got %v", err)
+	}
+}
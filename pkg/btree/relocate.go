@@ -0,0 +1,162 @@
+// pkg/btree/relocate.go
+package btree
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"tur/pkg/pager"
+)
+
+// RelocatePage repoints the tree from page from to page to, which must already
+// hold a copy of the content of from. from may be any page of the tree: the
+// root, another node or an overflow page. Used by incremental vacuum to move
+// pages towards the start of the file.
+func (bt *BTree) RelocatePage(from, to uint32) error {
+	if from == bt.rootPage {
+		bt.rootPage = to
+		return nil
+	}
+
+	page, err := bt.pager.Get(to)
+	if err != nil {
+		return err
+	}
+	var firstKey []byte
+	if data := page.Data(); pager.PageType(data[0]) != pager.PageTypeOverflow {
+		if node := LoadNode(data); node.CellCount() > 0 {
+			// A key that cannot be read just means walking the tree instead
+			firstKey, _ = bt.cellKey(node, 0)
+		}
+	}
+	bt.pager.Release(page)
+
+	// A node is found by descending with one of its keys; overflow pages (and
+	// empty nodes) need a walk of the whole tree
+	if firstKey != nil {
+		found, err := bt.relocateChild(firstKey, from, to)
+		if err != nil || found {
+			return err
+		}
+	}
+	found, err := bt.relocateRecursive(bt.rootPage, from, to)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("page %d is not part of the tree rooted at page %d", from, bt.rootPage)
+	}
+	return nil
+}
+
+// relocateChild descends towards key and repoints the child pointer to from
+// if it lies on the path
+func (bt *BTree) relocateChild(key []byte, from, to uint32) (bool, error) {
+	pageNo := bt.rootPage
+	for {
+		page, err := bt.pager.Get(pageNo)
+		if err != nil {
+			return false, err
+		}
+		node := LoadNode(page.Data())
+		if node.IsLeaf() {
+			bt.pager.Release(page)
+			return false, nil
+		}
+
+		child, idx := bt.findChildPageWithIndex(node, key)
+		if child == from {
+			if idx < 0 {
+				node.SetRightChild(to)
+			} else {
+				node.UpdateCellValue(idx, encodePageNo(to))
+			}
+			page.SetDirty(true)
+			bt.pager.Release(page)
+			return true, nil
+		}
+		bt.pager.Release(page)
+		pageNo = child
+	}
+}
+
+// relocateRecursive searches the subtree at pageNo for the pointer to from,
+// among child pointers, first overflow pages and overflow chain links, and
+// repoints it to to
+func (bt *BTree) relocateRecursive(pageNo, from, to uint32) (bool, error) {
+	page, err := bt.pager.Get(pageNo)
+	if err != nil {
+		return false, err
+	}
+	defer bt.pager.Release(page)
+	node := LoadNode(page.Data())
+	count := node.CellCount()
+
+	// Leaf cells and interior cells with long keys may have overflow chains
+	for i := 0; i < count; i++ {
+		_, firstPage := node.CellOverflow(i)
+		if firstPage == 0 {
+			continue
+		}
+		if firstPage == from {
+			node.setCellOverflowPage(i, to)
+			page.SetDirty(true)
+			return true, nil
+		}
+		if found, err := bt.relocateInChain(firstPage, from, to); err != nil || found {
+			return found, err
+		}
+	}
+
+	if !node.IsLeaf() {
+		children := make([]uint32, 0, count+1)
+		for i := 0; i < count; i++ {
+			_, childPtr := node.GetCell(i)
+			child := decodePageNo(childPtr)
+			if child == from {
+				node.UpdateCellValue(i, encodePageNo(to))
+				page.SetDirty(true)
+				return true, nil
+			}
+			children = append(children, child)
+		}
+		if node.RightChild() == from {
+			node.SetRightChild(to)
+			page.SetDirty(true)
+			return true, nil
+		}
+		children = append(children, node.RightChild())
+
+		for _, child := range children {
+			if child == 0 {
+				continue
+			}
+			if found, err := bt.relocateRecursive(child, from, to); err != nil || found {
+				return found, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// relocateInChain repoints the link to from in the overflow chain starting at
+// firstPage, if the chain contains from
+func (bt *BTree) relocateInChain(firstPage, from, to uint32) (bool, error) {
+	for pageNo := firstPage; pageNo != 0; {
+		page, err := bt.pager.Get(pageNo)
+		if err != nil {
+			return false, err
+		}
+		data := page.Data()
+		next := binary.LittleEndian.Uint32(data[1:5])
+		if next == from {
+			binary.LittleEndian.PutUint32(data[1:5], to)
+			page.SetDirty(true)
+			bt.pager.Release(page)
+			return true, nil
+		}
+		bt.pager.Release(page)
+		pageNo = next
+	}
+	return false, nil
+}
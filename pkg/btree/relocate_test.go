@@ -0,0 +1,109 @@
+// pkg/btree/relocate_test.go
+package btree
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBTreeRelocatePage_IncrementalVacuum(t *testing.T) {
+	p := openTestPager(t, 512)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+
+	const n = 3000
+	value := func(i int) []byte {
+		if i%50 == 0 {
+			return largeValue(i, 2000)
+		}
+		return largeValue(i, 30)
+	}
+	for i := 0; i < n; i++ {
+		if err := bt.Insert(rowKey(i), value(i)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	// Delete the first two thirds, leaving free pages below the rest of the tree
+	for i := 0; i < 2*n/3; i++ {
+		if err := bt.Delete(rowKey(i)); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+
+	before := p.PageCount()
+	free := int(p.FreePageCount())
+	removed, err := p.IncrementalVacuum(0, bt.RelocatePage)
+	if err != nil {
+		t.Fatalf("incremental vacuum failed: %v", err)
+	}
+	if removed != free {
+		t.Errorf("expected %d pages removed, got %d", free, removed)
+	}
+	if got := p.PageCount(); got != before-uint32(removed) {
+		t.Errorf("page count %d, want %d", got, before-uint32(removed))
+	}
+	if got := p.FreePageCount(); got != 0 {
+		t.Errorf("expected an empty freelist, %d pages free", got)
+	}
+	if used := len(bt.CollectPages()); used+1 != int(p.PageCount()) {
+		t.Errorf("tree uses %d pages of %d", used, p.PageCount())
+	}
+
+	if got := checkTree(t, bt); got != n-2*n/3 {
+		t.Errorf("tree holds %d keys, want %d", got, n-2*n/3)
+	}
+	if errs := bt.CheckOverflowPages(); len(errs) > 0 {
+		t.Errorf("overflow errors: %v", errs)
+	}
+	for i := 2 * n / 3; i < n; i++ {
+		got, err := bt.Get(rowKey(i))
+		if err != nil || !bytes.Equal(got, value(i)) {
+			t.Fatalf("get %d after vacuum: %d bytes, err %v", i, len(got), err)
+		}
+	}
+
+	// The tree keeps working and grows the file again
+	for i := 0; i < 500; i++ {
+		if err := bt.Insert(rowKey(i), value(i)); err != nil {
+			t.Fatalf("insert %d after vacuum failed: %v", i, err)
+		}
+	}
+	if got := checkTree(t, bt); got != n-2*n/3+500 {
+		t.Errorf("tree holds %d keys, want %d", got, n-2*n/3+500)
+	}
+}
+
+func TestBTreeRelocatePage_PartialVacuum(t *testing.T) {
+	p := openTestPager(t, 512)
+	bt, err := Create(p)
+	if err != nil {
+		t.Fatalf("failed to create btree: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		if err := bt.Insert(rowKey(i), largeValue(i, 40)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	for i := 0; i < 2000; i += 2 {
+		if err := bt.Delete(rowKey(i)); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+
+	before, free := p.PageCount(), p.FreePageCount()
+	if free < 10 {
+		t.Fatalf("expected at least 10 free pages, got %d", free)
+	}
+	removed, err := p.IncrementalVacuum(5, bt.RelocatePage)
+	if err != nil || removed != 5 {
+		t.Fatalf("incremental vacuum removed %d pages, err %v", removed, err)
+	}
+	if p.PageCount() != before-5 || p.FreePageCount() != free-5 {
+		t.Errorf("after vacuum: %d pages, %d free; want %d, %d", p.PageCount(), p.FreePageCount(), before-5, free-5)
+	}
+	if got := checkTree(t, bt); got != 1000 {
+		t.Errorf("tree holds %d keys, want 1000", got)
+	}
+}
@@ -327,6 +327,7 @@ func (r *REPL) showSchema(tableName string) {
 	}
 
 	fmt.Fprintln(r.output, generateCreateSQL(table))
+	r.showVectorIndexes(tableName)
 }
 
 // showAllSchemas shows CREATE statements for all tables.
@@ -341,6 +342,17 @@ func (r *REPL) showAllSchemas() {
 		table := catalog.GetTable(name)
 		if table != nil {
 			fmt.Fprintln(r.output, generateCreateSQL(table))
+			r.showVectorIndexes(name)
+		}
+	}
+}
+
+// showVectorIndexes shows CREATE INDEX statements for a table's HNSW indexes,
+// whose tuning parameters are otherwise not visible.
+func (r *REPL) showVectorIndexes(tableName string) {
+	for _, idx := range r.db.Catalog().GetIndexesForTable(tableName) {
+		if idx.Type == schema.IndexTypeHNSW {
+			fmt.Fprintln(r.output, generateCreateHNSWIndexSQL(idx))
 		}
 	}
 }
@@ -359,6 +371,9 @@ func generateCreateSQL(table *schema.TableDef) string {
 		sb.WriteString(col.Name)
 		sb.WriteString(" ")
 		sb.WriteString(valueTypeString(col.Type))
+		if col.Type == types.TypeVector {
+			fmt.Fprintf(&sb, "(%d)", col.VectorDim)
+		}
 
 		if col.PrimaryKey {
 			sb.WriteString(" PRIMARY KEY")
@@ -372,6 +387,17 @@ func generateCreateSQL(table *schema.TableDef) string {
 	return sb.String()
 }
 
+// generateCreateHNSWIndexSQL generates a CREATE INDEX ... USING HNSW statement from an IndexDef.
+func generateCreateHNSWIndexSQL(idx *schema.IndexDef) string {
+	params := idx.HNSWParams
+	if params == nil {
+		params = schema.DefaultHNSWParams()
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s USING HNSW (%s) WITH (m=%d, ef_construction=%d, metric='%s', heuristic=%t%s);",
+		idx.Name, idx.TableName, strings.Join(idx.Columns, ", "),
+		params.M, params.EfConstruction, params.DistanceMetric, params.UseHeuristic, params.QuantizationOptions())
+}
+
 // valueTypeString returns the SQL type name for a ValueType.
 func valueTypeString(vt types.ValueType) string {
 	switch vt {
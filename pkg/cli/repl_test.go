@@ -190,6 +190,36 @@ func TestREPL_MemoryDatabase(t *testing.T) {
 	}
 }
 
+func TestREPL_SchemaShowsHNSWIndex(t *testing.T) {
+	output := &bytes.Buffer{}
+	errOutput := &bytes.Buffer{}
+
+	repl, err := NewREPL(":memory:", output, errOutput)
+	if err != nil {
+		t.Fatalf("NewREPL failed: %v", err)
+	}
+	defer repl.Close()
+
+	if err := repl.ExecuteStatement("CREATE TABLE docs (id INT, embedding VECTOR(3));"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if err := repl.ExecuteStatement("CREATE INDEX emb_idx ON docs USING HNSW (embedding) WITH (m=32, metric='l2');"); err != nil {
+		t.Fatalf("CREATE INDEX failed: %v", err)
+	}
+
+	output.Reset()
+	repl.handleDotCommand(".schema docs")
+
+	if !strings.Contains(output.String(), "embedding VECTOR(3)") {
+		t.Errorf(".schema output should include the vector dimension, got: %s", output.String())
+	}
+
+	want := "CREATE INDEX emb_idx ON docs USING HNSW (embedding) WITH (m=32, ef_construction=200, metric='euclidean', heuristic=false);"
+	if !strings.Contains(output.String(), want) {
+		t.Errorf(".schema output should contain %q, got: %s", want, output.String())
+	}
+}
+
 func TestMain(m *testing.M) {
 	// Run tests
 	os.Exit(m.Run())
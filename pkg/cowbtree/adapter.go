@@ -4,6 +4,7 @@ package cowbtree
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"sync"
 
 	"tur/pkg/pager"
@@ -32,14 +33,29 @@ var cowTreeMagic = []byte("COWT")
 // [4:8]   - Version (uint32)
 // [8:16]  - Key count (uint64)
 // [16:24] - Node count (uint64)
-// [24:32] - Reserved
+// [24:28] - First overflow page (uint32, 0 if none)
+// [28:32] - Reserved
 // [32:]   - Serialized key-value pairs
+//
+// Each pair is serialized as key length (uint32), value length (uint32), key,
+// value. Pairs that do not fit on the root page continue on a chain of overflow
+// pages, with a pair free to straddle a page boundary:
+// [0]     - Page type (pager.PageTypeOverflow)
+// [1:5]   - Next overflow page (uint32, 0 on the last page)
+// [5:]    - Serialized key-value pairs, continued
+//
+// Version 1 trees kept everything on the root page and have no overflow chain.
 
 const (
-	cowTreeHeaderSize = 32
-	cowTreeVersion    = 1
+	cowTreeHeaderSize  = 32
+	cowTreeVersion     = 2
+	cowTreeVersionV1   = 1
+	overflowHeaderSize = 5
 )
 
+// ErrCorruptOverflow is returned when a tree's overflow chain is shorter than its data
+var ErrCorruptOverflow = errors.New("corrupt CoW tree overflow chain")
+
 // CreatePersistent creates a new persistent CoW B+ tree
 func CreatePersistent(p *pager.Pager) (*PersistentCowBTree, error) {
 	page, err := p.Allocate()
@@ -136,7 +152,7 @@ func (pt *PersistentCowBTree) load() error {
 
 	// Check version
 	version := binary.LittleEndian.Uint32(data[4:8])
-	if version != cowTreeVersion {
+	if version != cowTreeVersion && version != cowTreeVersionV1 {
 		return errors.New("unsupported CoW tree version")
 	}
 
@@ -146,12 +162,32 @@ func (pt *PersistentCowBTree) load() error {
 		return nil
 	}
 
-	// Read key-value pairs from subsequent pages if needed
-	// For now, we use a simple approach: all data on root page
-	offset := cowTreeHeaderSize
+	// Gather the serialized pairs from the root page and its overflow chain
+	buf := append([]byte(nil), data[cowTreeHeaderSize:]...)
+	if version == cowTreeVersion {
+		for pageNo := binary.LittleEndian.Uint32(data[24:28]); pageNo != 0; {
+			ovfl, err := pt.pager.Get(pageNo)
+			if err != nil {
+				return err
+			}
+			ovflData := ovfl.Data()
+			if pager.PageType(ovflData[0]) != pager.PageTypeOverflow {
+				pt.pager.Release(ovfl)
+				return ErrCorruptOverflow
+			}
+			buf = append(buf, ovflData[overflowHeaderSize:]...)
+			pageNo = binary.LittleEndian.Uint32(ovflData[1:5])
+			pt.pager.Release(ovfl)
+		}
+	}
+	data = buf
+
+	offset := 0
 	for i := uint64(0); i < keyCount; i++ {
 		if offset+8 > len(data) {
-			// Need to load from next page - simplified for now
+			if version == cowTreeVersion {
+				return ErrCorruptOverflow
+			}
 			break
 		}
 
@@ -161,6 +197,9 @@ func (pt *PersistentCowBTree) load() error {
 		offset += 4
 
 		if offset+int(keyLen)+int(valLen) > len(data) {
+			if version == cowTreeVersion {
+				return ErrCorruptOverflow
+			}
 			break
 		}
 
@@ -233,8 +272,78 @@ func (pt *PersistentCowBTree) Checkpoint() error {
 	return pt.save()
 }
 
-// save writes the tree data to pages
+// save writes the tree data to pages, growing or shrinking the overflow
+// chain to fit
 func (pt *PersistentCowBTree) save() error {
+	stats := pt.tree.Stats()
+
+	// Serialize key-value pairs
+	var buf []byte
+	var lens [8]byte
+	pt.tree.ForEach(func(key, value []byte) bool {
+		binary.LittleEndian.PutUint32(lens[0:4], uint32(len(key)))
+		binary.LittleEndian.PutUint32(lens[4:8], uint32(len(value)))
+		buf = append(buf, lens[:]...)
+		buf = append(buf, key...)
+		buf = append(buf, value...)
+		return true
+	})
+
+	// Reuse the existing overflow chain, allocating or freeing pages so that
+	// it holds exactly what does not fit on the root page
+	pageSize := pt.pager.PageSize()
+	rootCapacity := pageSize - cowTreeHeaderSize
+	chunkSize := pageSize - overflowHeaderSize
+	needed := 0
+	if len(buf) > rootCapacity {
+		needed = (len(buf) - rootCapacity + chunkSize - 1) / chunkSize
+	}
+
+	chain, err := pt.overflowChain()
+	if err != nil {
+		return err
+	}
+	for len(chain) > needed {
+		if err := pt.pager.Free(chain[len(chain)-1]); err != nil {
+			return err
+		}
+		chain = chain[:len(chain)-1]
+	}
+	for len(chain) < needed {
+		// Allocate may remap the file, so no page is held across it
+		page, err := pt.pager.Allocate()
+		if err != nil {
+			return err
+		}
+		chain = append(chain, page.PageNo())
+		pt.pager.Release(page)
+	}
+
+	// Write overflow pages
+	rest := buf
+	if len(rest) > rootCapacity {
+		rest = rest[rootCapacity:]
+	} else {
+		rest = nil
+	}
+	for i, pageNo := range chain {
+		page, err := pt.pager.Get(pageNo)
+		if err != nil {
+			return err
+		}
+		data := page.Data()
+		data[0] = byte(pager.PageTypeOverflow)
+		next := uint32(0)
+		if i+1 < len(chain) {
+			next = chain[i+1]
+		}
+		binary.LittleEndian.PutUint32(data[1:5], next)
+		n := copy(data[overflowHeaderSize:], rest)
+		rest = rest[n:]
+		page.SetDirty(true)
+		pt.pager.Release(page)
+	}
+
 	page, err := pt.pager.Get(pt.rootPage)
 	if err != nil {
 		return err
@@ -242,37 +351,106 @@ func (pt *PersistentCowBTree) save() error {
 	defer pt.pager.Release(page)
 
 	data := page.Data()
-	stats := pt.tree.Stats()
 
 	// Write header
 	copy(data[0:4], cowTreeMagic)
 	binary.LittleEndian.PutUint32(data[4:8], cowTreeVersion)
 	binary.LittleEndian.PutUint64(data[8:16], uint64(stats.KeyCount))
 	binary.LittleEndian.PutUint64(data[16:24], uint64(stats.NodeCount))
+	firstOverflow := uint32(0)
+	if len(chain) > 0 {
+		firstOverflow = chain[0]
+	}
+	binary.LittleEndian.PutUint32(data[24:28], firstOverflow)
 
-	// Write key-value pairs
-	offset := cowTreeHeaderSize
-	pt.tree.ForEach(func(key, value []byte) bool {
-		needed := 8 + len(key) + len(value)
-		if offset+needed > len(data) {
-			// TODO: Allocate overflow pages for large trees
-			return false
+	// Write the key-value pairs that fit on the root page
+	copy(data[cowTreeHeaderSize:], buf)
+
+	page.SetDirty(true)
+	pt.dirty = false
+	return nil
+}
+
+// overflowChain returns the overflow pages currently linked from the root page
+func (pt *PersistentCowBTree) overflowChain() ([]uint32, error) {
+	page, err := pt.pager.Get(pt.rootPage)
+	if err != nil {
+		return nil, err
+	}
+	data := page.Data()
+	var pageNo uint32
+	if string(data[0:4]) == string(cowTreeMagic) && binary.LittleEndian.Uint32(data[4:8]) == cowTreeVersion {
+		pageNo = binary.LittleEndian.Uint32(data[24:28])
+	}
+	pt.pager.Release(page)
+
+	var chain []uint32
+	seen := make(map[uint32]bool)
+	for pageNo != 0 {
+		if seen[pageNo] || pageNo == pt.rootPage {
+			return nil, fmt.Errorf("%w: page %d is linked twice", ErrCorruptOverflow, pageNo)
 		}
+		seen[pageNo] = true
 
-		binary.LittleEndian.PutUint32(data[offset:], uint32(len(key)))
-		offset += 4
-		binary.LittleEndian.PutUint32(data[offset:], uint32(len(value)))
-		offset += 4
-		copy(data[offset:], key)
-		offset += len(key)
-		copy(data[offset:], value)
-		offset += len(value)
+		page, err := pt.pager.Get(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		data := page.Data()
+		pageType := pager.PageType(data[0])
+		next := binary.LittleEndian.Uint32(data[1:5])
+		pt.pager.Release(page)
 
-		return true
-	})
+		if pageType != pager.PageTypeOverflow {
+			return nil, fmt.Errorf("%w: page %d has type 0x%02x", ErrCorruptOverflow, pageNo, byte(pageType))
+		}
+		chain = append(chain, pageNo)
+		pageNo = next
+	}
+	return chain, nil
+}
 
-	page.SetDirty(true)
-	pt.dirty = false
+// RelocatePage repoints the tree from page from, its root page or a page of
+// its overflow chain, to page to, which already holds a copy of its content
+func (pt *PersistentCowBTree) RelocatePage(from, to uint32) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if from == pt.rootPage {
+		pt.rootPage = to
+		return nil
+	}
+
+	chain, err := pt.overflowChain()
+	if err != nil {
+		return err
+	}
+	for i, pageNo := range chain {
+		if pageNo != from {
+			continue
+		}
+		// The link to the page is on the root page or the previous overflow page
+		linkPage, offset := pt.rootPage, 24
+		if i > 0 {
+			linkPage, offset = chain[i-1], 1
+		}
+		page, err := pt.pager.Get(linkPage)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(page.Data()[offset:offset+4], to)
+		page.SetDirty(true)
+		pt.pager.Release(page)
+		return nil
+	}
+	return fmt.Errorf("page %d is not part of the tree rooted at page %d", from, pt.rootPage)
+}
+
+// CheckIntegrity verifies the overflow chain holding the persisted pairs
+func (pt *PersistentCowBTree) CheckIntegrity() []error {
+	if _, err := pt.overflowChain(); err != nil {
+		return []error{err}
+	}
 	return nil
 }
 
@@ -307,9 +485,11 @@ func (pt *PersistentCowBTree) Snapshot() *CowBTreeSnapshot {
 
 // CollectPages returns all page numbers used by this tree
 func (pt *PersistentCowBTree) CollectPages() []uint32 {
-	// For CoW tree, we only use the root page for persistence
-	// The actual tree structure is in memory
-	return []uint32{pt.rootPage}
+	// For CoW tree, only the root page and its overflow chain are used for
+	// persistence. The actual tree structure is in memory
+	pages := []uint32{pt.rootPage}
+	chain, _ := pt.overflowChain()
+	return append(pages, chain...)
 }
 
 // IsDirty returns true if the tree has uncommitted changes
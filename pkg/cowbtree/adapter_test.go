@@ -0,0 +1,85 @@
+// pkg/cowbtree/adapter_test.go
+package cowbtree
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"tur/pkg/pager"
+)
+
+func TestPersistentCowBTree_OverflowAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	p, err := pager.Open(path, pager.Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+
+	pt, err := CreatePersistent(p)
+	if err != nil {
+		t.Fatalf("failed to create tree: %v", err)
+	}
+	rootPage := pt.RootPage()
+
+	// Far more data than fits on the root page, including values larger than a page
+	const n = 500
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 20+(i%7)*1000)
+	}
+	for i := 0; i < n; i++ {
+		if err := pt.Insert([]byte(fmt.Sprintf("key%04d", i)), value(i)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	if err := pt.Checkpoint(); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	if pages := pt.CollectPages(); len(pages) < 100 {
+		t.Errorf("expected an overflow chain of many pages, got %d pages", len(pages))
+	}
+	if errs := pt.CheckIntegrity(); len(errs) > 0 {
+		t.Errorf("unexpected integrity errors: %v", errs)
+	}
+	pt.Close()
+	if err := p.Close(); err != nil {
+		t.Fatalf("failed to close pager: %v", err)
+	}
+
+	p, err = pager.Open(path, pager.Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to reopen pager: %v", err)
+	}
+	defer p.Close()
+	pt, err = OpenPersistent(p, rootPage)
+	if err != nil {
+		t.Fatalf("failed to reopen tree: %v", err)
+	}
+	if pt.KeyCount() != n {
+		t.Fatalf("expected %d keys after reopen, got %d", n, pt.KeyCount())
+	}
+	for i := 0; i < n; i++ {
+		got, err := pt.Get([]byte(fmt.Sprintf("key%04d", i)))
+		if err != nil || !bytes.Equal(got, value(i)) {
+			t.Fatalf("key %d: got %d bytes, err %v", i, len(got), err)
+		}
+	}
+
+	// Shrinking the tree frees the pages it no longer needs
+	for i := 10; i < n; i++ {
+		if err := pt.Delete([]byte(fmt.Sprintf("key%04d", i))); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+	before := p.FreePageCount()
+	if err := pt.Checkpoint(); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	if p.FreePageCount() <= before {
+		t.Errorf("expected overflow pages to be freed, free count stayed at %d", before)
+	}
+	if pages := pt.CollectPages(); len(pages) > 10 {
+		t.Errorf("expected a short chain after deletes, got %d pages", len(pages))
+	}
+}
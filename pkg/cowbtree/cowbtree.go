@@ -440,19 +440,16 @@ func (t *CowBTree) Range(startKey, endKey []byte, fn func(key, value []byte) boo
 		return nil
 	}
 
-	// Find the leaf containing startKey
-	node := root
-	for !node.IsLeaf() {
-		childIdx := node.findChildIndex(startKey)
-		child := node.GetChild(childIdx)
-		if child == nil {
-			return nil
-		}
-		node = child
-	}
+	rangeScan(root, startKey, endKey, fn)
+	return nil
+}
 
-	// Scan leaves until we pass endKey
-	for node != nil {
+// rangeScan visits the keys of the subtree at node from startKey to endKey in
+// order, returning false once fn stops the scan or endKey is passed. It descends
+// through the interior nodes rather than following next-leaf links, because a
+// copied leaf leaves its predecessor in an older version linked to the original.
+func rangeScan(node *CowNode, startKey, endKey []byte, fn func(key, value []byte) bool) bool {
+	if node.IsLeaf() {
 		for i := 0; i < node.KeyCount(); i++ {
 			key := node.GetKey(i)
 
@@ -463,20 +460,35 @@ func (t *CowBTree) Range(startKey, endKey []byte, fn func(key, value []byte) boo
 
 			// Stop if we've passed endKey
 			if endKey != nil && bytes.Compare(key, endKey) > 0 {
-				return nil
+				return false
 			}
 
 			// Call the callback
 			if !fn(key, node.GetValue(i)) {
-				return nil
+				return false
 			}
 		}
-
-		// Move to next leaf
-		node = node.GetNextLeaf()
+		return true
 	}
 
-	return nil
+	first := 0
+	if startKey != nil {
+		first = node.findChildIndex(startKey)
+	}
+	for i := first; i <= node.KeyCount(); i++ {
+		// Children right of a separator beyond endKey hold only larger keys
+		if endKey != nil && i > 0 && bytes.Compare(node.GetKey(i-1), endKey) > 0 {
+			return false
+		}
+		child := node.GetChild(i)
+		if child == nil {
+			continue
+		}
+		if !rangeScan(child, startKey, endKey, fn) {
+			return false
+		}
+	}
+	return true
 }
 
 // RangeScan is an alias for Range with a more descriptive name
@@ -576,34 +588,7 @@ func (s *CowBTreeSnapshot) Range(startKey, endKey []byte, fn func(key, value []b
 		return nil
 	}
 
-	// Find starting leaf
-	node := s.root
-	for !node.IsLeaf() {
-		childIdx := node.findChildIndex(startKey)
-		child := node.GetChild(childIdx)
-		if child == nil {
-			return nil
-		}
-		node = child
-	}
-
-	// Scan
-	for node != nil {
-		for i := 0; i < node.KeyCount(); i++ {
-			key := node.GetKey(i)
-			if startKey != nil && bytes.Compare(key, startKey) < 0 {
-				continue
-			}
-			if endKey != nil && bytes.Compare(key, endKey) > 0 {
-				return nil
-			}
-			if !fn(key, node.GetValue(i)) {
-				return nil
-			}
-		}
-		node = node.GetNextLeaf()
-	}
-
+	rangeScan(s.root, startKey, endKey, fn)
 	return nil
 }
 
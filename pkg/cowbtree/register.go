@@ -24,6 +24,21 @@ func (w *cowTreeWrapper) Cursor() tree.Cursor {
 	return &cowCursorWrapper{w.PersistentCowBTree.Cursor()}
 }
 
+func (w *cowTreeWrapper) Get(key []byte) ([]byte, error) {
+	value, err := w.PersistentCowBTree.Get(key)
+	if err == ErrKeyNotFound {
+		return nil, tree.ErrKeyNotFound
+	}
+	return value, err
+}
+
+func (w *cowTreeWrapper) Delete(key []byte) error {
+	if err := w.PersistentCowBTree.Delete(key); err != ErrKeyNotFound {
+		return err
+	}
+	return tree.ErrKeyNotFound
+}
+
 // cowCursorWrapper wraps cowbtree.Cursor to implement tree.Cursor
 type cowCursorWrapper struct {
 	*Cursor
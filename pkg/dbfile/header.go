@@ -84,8 +84,8 @@ type Header struct {
 func NewHeader() *Header {
 	return &Header{
 		PageSize:           DefaultPageSize,
-		FormatWriteVersion: 1,
-		FormatReadVersion:  1,
+		FormatWriteVersion: CurrentFormatVersion,
+		FormatReadVersion:  CurrentFormatVersion,
 		ReservedPerPage:    0,
 		MaxPayloadFrac:     64,
 		MinPayloadFrac:     32,
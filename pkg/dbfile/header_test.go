@@ -31,11 +31,11 @@ func TestHeader_NewDefault(t *testing.T) {
 	if h.PageSize != DefaultPageSize {
 		t.Errorf("PageSize = %d, want %d", h.PageSize, DefaultPageSize)
 	}
-	if h.FormatWriteVersion != 1 {
-		t.Errorf("FormatWriteVersion = %d, want 1", h.FormatWriteVersion)
+	if h.FormatWriteVersion != CurrentFormatVersion {
+		t.Errorf("FormatWriteVersion = %d, want %d", h.FormatWriteVersion, CurrentFormatVersion)
 	}
-	if h.FormatReadVersion != 1 {
-		t.Errorf("FormatReadVersion = %d, want 1", h.FormatReadVersion)
+	if h.FormatReadVersion != CurrentFormatVersion {
+		t.Errorf("FormatReadVersion = %d, want %d", h.FormatReadVersion, CurrentFormatVersion)
 	}
 	if h.PageCount != 1 {
 		t.Errorf("PageCount = %d, want 1 (header page)", h.PageCount)
@@ -4,10 +4,13 @@ package dbfile
 
 import "errors"
 
-// Current supported version
+// Current supported version. Version 2 lets B-tree keys spill onto overflow
+// pages, which changed the cell layout, and version 3 ends index keys with a
+// fixed-width rowid, so older files cannot be read.
 const (
-	CurrentFormatVersion = 1
-	MaxSupportedVersion  = 1
+	CurrentFormatVersion = 3
+	MinSupportedVersion  = 3
+	MaxSupportedVersion  = 3
 )
 
 // Validation errors.
@@ -49,7 +52,7 @@ func ValidateHeader(h *Header) error {
 	}
 
 	// Check if we can read this version
-	if h.FormatReadVersion > MaxSupportedVersion {
+	if h.FormatReadVersion < MinSupportedVersion || h.FormatReadVersion > MaxSupportedVersion {
 		return ErrUnsupportedVersion
 	}
 
@@ -39,8 +39,8 @@ func TestValidation_PageSize(t *testing.T) {
 
 func TestValidation_Header_FormatVersion(t *testing.T) {
 	h := NewHeader()
-	h.FormatWriteVersion = 1
-	h.FormatReadVersion = 1
+	h.FormatWriteVersion = CurrentFormatVersion
+	h.FormatReadVersion = CurrentFormatVersion
 
 	err := ValidateHeader(h)
 	if err != nil {
@@ -160,3 +160,23 @@ func TestValidation_FutureVersion(t *testing.T) {
 		t.Errorf("Open() error = %v, want ErrUnsupportedVersion", err)
 	}
 }
+
+func TestValidation_OlderVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	// Version 1 files store B-tree cells in the layout before keys could spill
+	h := NewHeader()
+	h.FormatWriteVersion = 1
+	h.FormatReadVersion = 1
+	data := h.Encode()
+
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(dbPath, nil)
+	if err != ErrUnsupportedVersion {
+		t.Errorf("Open() error = %v, want ErrUnsupportedVersion", err)
+	}
+}
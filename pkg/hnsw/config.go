@@ -37,6 +37,18 @@ type Config struct {
 
 	// DistanceMetric is the distance function to use (default: Cosine)
 	DistanceMetric types.DistanceMetric
+
+	// Quantization selects how node vectors are stored (default: full precision).
+	// Quantized persistent indexes must be trained before the first insert.
+	Quantization Quantization
+
+	// PQSegments is the number of subvectors used by product quantization
+	// (0 selects DefaultPQSegments)
+	PQSegments int
+
+	// Rerank asks callers to re-rank quantized search results against the
+	// full-precision vectors, which the index itself does not keep
+	Rerank bool
 }
 
 // DefaultConfig returns a Config with sensible defaults
@@ -52,3 +64,12 @@ func DefaultConfig(dimension int) Config {
 		DistanceMetric: types.DistanceMetricCosine,
 	}
 }
+
+// WithM returns a copy of the config using m connections per node, with MMax0
+// and ML derived from m the same way as in DefaultConfig
+func (c Config) WithM(m int) Config {
+	c.M = m
+	c.MMax0 = m * 2
+	c.ML = 1.0 / math.Log(float64(m))
+	return c
+}
@@ -13,6 +13,20 @@ var (
 	ErrDimensionMismatch = errors.New("vector dimension mismatch")
 )
 
+// VectorIndex is the set of operations shared by the in-memory Index and the
+// pager-backed PersistentIndex
+type VectorIndex interface {
+	Insert(rowID int64, vector *types.Vector) error
+	Delete(rowID int64) bool
+	Update(rowID int64, newVector *types.Vector) (bool, error)
+	Contains(rowID int64) bool
+	SearchKNN(query *types.Vector, k int) ([]SearchResult, error)
+	SearchKNNWithEf(query *types.Vector, k int, ef int) ([]SearchResult, error)
+	SearchKNNFiltered(query *types.Vector, k int, ef int, filter Filter) ([]SearchResult, error)
+	Len() int
+	Config() Config
+}
+
 // Index is an HNSW index for approximate nearest neighbor search
 type Index struct {
 	mu         sync.RWMutex
@@ -98,6 +112,9 @@ func (idx *Index) Insert(rowID int64, vector *types.Vector) error {
 		ep = idx.searchLayerClosest(vector, ep, l)
 	}
 
+	// Store node before linking so neighbors can keep their back-link to it when pruned
+	idx.nodes[nodeID] = node
+
 	// Phase 2: Insert at each level from node's level down to 0
 	for l := min(level, currentLevel); l >= 0; l-- {
 		// Find neighbors at this level
@@ -126,9 +143,6 @@ func (idx *Index) Insert(rowID int64, vector *types.Vector) error {
 		}
 	}
 
-	// Store node
-	idx.nodes[nodeID] = node
-
 	// Update entry point if this node has higher level
 	if level > idx.maxLevel {
 		idx.entryPoint = nodeID
@@ -380,7 +394,6 @@ func (idx *Index) pruneConnections(node *HNSWNode, level int, maxConnections int
 	}
 	nds := make([]nd, 0, len(neighbors))
 	for _, nid := range neighbors {
-		// Skip if node doesn't exist yet (happens during insertion)
 		neighborNode := idx.nodes[nid]
 		if neighborNode == nil {
 			continue
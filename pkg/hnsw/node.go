@@ -8,10 +8,11 @@ import (
 // HNSWNode represents a node in the HNSW graph
 type HNSWNode struct {
 	id        uint64
-	rowID     int64          // Foreign key to B-tree row
-	vector    *types.Vector
-	level     int            // Maximum level this node exists at
-	neighbors [][]uint64     // neighbors[level] = list of neighbor IDs
+	rowID     int64         // Foreign key to B-tree row
+	vector    *types.Vector // nil for nodes of a quantized index
+	code      []byte        // quantized vector, nil unless the index is quantized
+	level     int           // Maximum level this node exists at
+	neighbors [][]uint64    // neighbors[level] = list of neighbor IDs
 }
 
 // NewHNSWNode creates a new HNSW node
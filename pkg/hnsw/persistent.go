@@ -15,6 +15,7 @@ import (
 var (
 	ErrInvalidMetaPage = errors.New("invalid HNSW meta page")
 	ErrNodeNotFound    = errors.New("HNSW node not found")
+	ErrPageNotOwned    = errors.New("page is not part of the HNSW index")
 )
 
 // PersistentIndex is an HNSW index backed by the pager for disk persistence
@@ -35,6 +36,29 @@ type PersistentIndex struct {
 
 	// In-memory cache of recently accessed nodes
 	nodeCache map[uint64]*HNSWNode
+
+	// Node page directory as stored on disk: dirNodes[slot] is the nodeID
+	// in that slot, dirSlots maps it back. Slots past the meta page live on
+	// a chain of directory pages (dirPages).
+	dirNodes []uint64
+	dirSlots map[uint64]int
+	dirPages []uint32
+
+	// nodeID -> first overflow page for vectors too large to fit on the node page
+	vecPages map[uint64]uint32
+
+	// rowID -> nodeID, built on first lookup by rowID
+	rowNodes map[int64]uint64
+
+	// Meta page format version; indexes created before quantization support
+	// keep the shorter version 1 header
+	version uint32
+
+	// Trained quantizer (nil for full-precision indexes) and the overflow
+	// chain holding its parameters
+	quantizer quantizer
+	quantPage uint32
+	quantSize uint32
 }
 
 // Meta page layout (stored on PageTypeHNSWMeta page):
@@ -51,13 +75,38 @@ type PersistentIndex struct {
 // [45-48] MaxLevel (4 bytes)
 // [49-56] NextID (8 bytes)
 // [57-64] NodeCount (8 bytes)
-// [65]    Flags (1 byte)
-// [66-71] Reserved
-// [72...] Node page directory (nodeID -> pageNo mappings)
+// [65]    Flags (1 byte: 0x01 heuristic, 0x02 extend candidates, 0x04 rerank)
+// [66]    DistanceMetric (1 byte)
+// [67-70] First directory page (4 bytes, 0 if the directory fits on this page)
+// [71]    Quantization (1 byte)
+// [72-73] PQ segments (2 bytes)
+// [74-77] First quantizer page (4 bytes, 0 until trained)
+// [78-81] Quantizer size in bytes (4 bytes)
+// [82-87] Reserved
+// [88...] Node page directory (nodeID -> pageNo mappings)
+//
+// Version 1 meta pages end the header at byte 71 and start the directory at 72.
+//
+// Directory pages (PageTypeOverflow) continue the node page directory:
+// [0]     PageType (1 byte) = 0x20
+// [1-4]   Next directory page (4 bytes, 0 if last)
+// [5...]  Node page directory entries
+//
+// Vector overflow pages (PageTypeOverflow) hold vectors that do not fit on
+// their node page, using the same [type][next] header followed by raw bytes.
+// The trained quantizer parameters are stored the same way.
+//
+// Nodes of a quantized index store their code in place of the vector bytes.
 
 const (
-	metaHeaderSize    = 72
-	nodePageEntrySize = 12 // 8 bytes nodeID + 4 bytes pageNo
+	metaHeaderSize     = 88
+	metaHeaderSizeV1   = 72
+	metaVersion        = 2
+	nodePageEntrySize  = 12 // 8 bytes nodeID + 4 bytes pageNo
+	overflowHeaderSize = 5  // 1 byte type + 4 bytes next page
+
+	// vecOverflowFlag marks a node's vector size field as pointing to an overflow chain
+	vecOverflowFlag uint32 = 0x80000000
 )
 
 // CreatePersistent creates a new persistent HNSW index
@@ -77,6 +126,9 @@ func CreatePersistent(p *pager.Pager, config Config) (*PersistentIndex, error) {
 		config:    config,
 		nodePages: make(map[uint64]uint32),
 		nodeCache: make(map[uint64]*HNSWNode),
+		dirSlots:  make(map[uint64]int),
+		vecPages:  make(map[uint64]uint32),
+		version:   metaVersion,
 	}
 
 	// Write initial metadata
@@ -94,6 +146,8 @@ func OpenPersistent(p *pager.Pager, metaPageNo uint32) (*PersistentIndex, error)
 		metaPage:  metaPageNo,
 		nodePages: make(map[uint64]uint32),
 		nodeCache: make(map[uint64]*HNSWNode),
+		dirSlots:  make(map[uint64]int),
+		vecPages:  make(map[uint64]uint32),
 	}
 
 	// Load metadata
@@ -114,6 +168,86 @@ func (idx *PersistentIndex) distance(a, b *types.Vector) float32 {
 	return a.Distance(b, idx.config.DistanceMetric)
 }
 
+// queryDistance computes the distance from a query vector to a node, on the
+// node's code when the index is quantized
+func (idx *PersistentIndex) queryDistance(query *types.Vector, node *HNSWNode) float32 {
+	if node.code != nil {
+		return idx.quantizer.distance(query, node.code)
+	}
+	return idx.distance(query, node.vector)
+}
+
+// nodeDistance computes the distance between two nodes
+func (idx *PersistentIndex) nodeDistance(a, b *HNSWNode) float32 {
+	if a.code != nil && b.code != nil {
+		return idx.quantizer.codeDistance(a.code, b.code)
+	}
+	return idx.distance(a.vector, b.vector)
+}
+
+// nodeVector returns a node's vector, reconstructed from its code when the index is quantized
+func (idx *PersistentIndex) nodeVector(node *HNSWNode) *types.Vector {
+	if node.code != nil {
+		return idx.quantizer.decode(node.code)
+	}
+	return node.vector
+}
+
+// headerSize returns the size of the meta page header for the index's format version
+func (idx *PersistentIndex) headerSize() int {
+	if idx.version < metaVersion {
+		return metaHeaderSizeV1
+	}
+	return metaHeaderSize
+}
+
+// Train fits the index's quantizer to a sample of the given vectors and stores it.
+// It must be called on a quantized index before the first insert; for a
+// full-precision index it does nothing.
+func (idx *PersistentIndex) Train(vectors []*types.Vector) error {
+	if idx.config.Quantization == QuantizationNone {
+		return nil
+	}
+	for _, v := range vectors {
+		if v.Dimension() != idx.config.Dimension {
+			return ErrDimensionMismatch
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.quantizer != nil || idx.nodeCount > 0 {
+		return ErrAlreadyTrained
+	}
+
+	q, err := trainQuantizer(idx.config, vectors)
+	if err != nil {
+		return err
+	}
+	data := q.marshal()
+	page, err := idx.writeVectorOverflow(data)
+	if err != nil {
+		return err
+	}
+
+	idx.quantizer = q
+	idx.quantPage = page
+	idx.quantSize = uint32(len(data))
+	if pq, ok := q.(*productQuantizer); ok {
+		idx.config.PQSegments = pq.segments
+	}
+	return idx.writeMeta()
+}
+
+// Trained reports whether the index can accept vectors: it is either
+// full-precision or its quantizer has been trained
+func (idx *PersistentIndex) Trained() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.config.Quantization == QuantizationNone || idx.quantizer != nil
+}
+
 // writeMeta writes the metadata to the meta page
 func (idx *PersistentIndex) writeMeta() error {
 	page, err := idx.pager.Get(idx.metaPage)
@@ -127,7 +261,7 @@ func (idx *PersistentIndex) writeMeta() error {
 	// Write header
 	data[0] = byte(pager.PageTypeHNSWMeta)
 	binary.LittleEndian.PutUint32(data[1:5], hnswMagic)
-	binary.LittleEndian.PutUint32(data[5:9], hnswVersion)
+	binary.LittleEndian.PutUint32(data[5:9], idx.version)
 	binary.LittleEndian.PutUint32(data[9:13], uint32(idx.config.M))
 	binary.LittleEndian.PutUint32(data[13:17], uint32(idx.config.MMax0))
 	binary.LittleEndian.PutUint32(data[17:21], uint32(idx.config.EfConstruction))
@@ -147,25 +281,124 @@ func (idx *PersistentIndex) writeMeta() error {
 	if idx.config.ExtendCandidates {
 		flags |= 0x02
 	}
+	if idx.config.Rerank {
+		flags |= 0x04
+	}
 	data[65] = flags
+	data[66] = byte(idx.config.DistanceMetric)
+
+	// The node page directory itself is written slot by slot (writeDirSlot)
+	var firstDirPage uint32
+	if len(idx.dirPages) > 0 {
+		firstDirPage = idx.dirPages[0]
+	}
+	binary.LittleEndian.PutUint32(data[67:71], firstDirPage)
+
+	if idx.version >= metaVersion {
+		data[71] = byte(idx.config.Quantization)
+		binary.LittleEndian.PutUint16(data[72:74], uint16(idx.config.PQSegments))
+		binary.LittleEndian.PutUint32(data[74:78], idx.quantPage)
+		binary.LittleEndian.PutUint32(data[78:82], idx.quantSize)
+	}
+
+	page.SetDirty(true)
+	return nil
+}
 
-	// Write node page directory
-	offset := metaHeaderSize
+// dirSlotsPerPage returns how many directory entries fit on the meta page
+// and on each directory page.
+func (idx *PersistentIndex) dirSlotsPerPage() (metaSlots, pageSlots int) {
 	pageSize := idx.pager.PageSize()
-	for nodeID, pageNo := range idx.nodePages {
-		if offset+nodePageEntrySize > pageSize {
-			// TODO: Implement overflow pages for large indexes
-			break
+	return (pageSize - idx.headerSize()) / nodePageEntrySize, (pageSize - overflowHeaderSize) / nodePageEntrySize
+}
+
+// dirSlotLocation returns the page and byte offset holding a directory slot.
+func (idx *PersistentIndex) dirSlotLocation(slot int) (uint32, int) {
+	metaSlots, pageSlots := idx.dirSlotsPerPage()
+	if slot < metaSlots {
+		return idx.metaPage, idx.headerSize() + slot*nodePageEntrySize
+	}
+	slot -= metaSlots
+	return idx.dirPages[slot/pageSlots], overflowHeaderSize + (slot%pageSlots)*nodePageEntrySize
+}
+
+// ensureDirCapacity allocates directory pages until n slots are addressable.
+func (idx *PersistentIndex) ensureDirCapacity(n int) error {
+	metaSlots, pageSlots := idx.dirSlotsPerPage()
+	for metaSlots+len(idx.dirPages)*pageSlots < n {
+		page, err := idx.pager.Allocate()
+		if err != nil {
+			return err
 		}
-		binary.LittleEndian.PutUint64(data[offset:offset+8], nodeID)
-		binary.LittleEndian.PutUint32(data[offset+8:offset+12], pageNo)
-		offset += nodePageEntrySize
+		page.SetType(pager.PageTypeOverflow)
+		page.SetDirty(true)
+		newPageNo := page.PageNo()
+		idx.pager.Release(page)
+
+		// Link from the previous directory page (the meta page links the first)
+		if len(idx.dirPages) > 0 {
+			prev, err := idx.pager.Get(idx.dirPages[len(idx.dirPages)-1])
+			if err != nil {
+				return err
+			}
+			binary.LittleEndian.PutUint32(prev.Data()[1:5], newPageNo)
+			prev.SetDirty(true)
+			idx.pager.Release(prev)
+		}
+		idx.dirPages = append(idx.dirPages, newPageNo)
 	}
+	return nil
+}
 
+// writeDirSlot writes a single directory entry to disk.
+func (idx *PersistentIndex) writeDirSlot(slot int) error {
+	pageNo, offset := idx.dirSlotLocation(slot)
+	page, err := idx.pager.Get(pageNo)
+	if err != nil {
+		return err
+	}
+	defer idx.pager.Release(page)
+
+	nodeID := idx.dirNodes[slot]
+	data := page.Data()
+	binary.LittleEndian.PutUint64(data[offset:offset+8], nodeID)
+	binary.LittleEndian.PutUint32(data[offset+8:offset+12], idx.nodePages[nodeID])
 	page.SetDirty(true)
 	return nil
 }
 
+// addDirEntry appends a node to the directory.
+func (idx *PersistentIndex) addDirEntry(nodeID uint64, pageNo uint32) error {
+	idx.nodePages[nodeID] = pageNo
+	if err := idx.ensureDirCapacity(len(idx.dirNodes) + 1); err != nil {
+		return err
+	}
+	idx.dirSlots[nodeID] = len(idx.dirNodes)
+	idx.dirNodes = append(idx.dirNodes, nodeID)
+	return idx.writeDirSlot(len(idx.dirNodes) - 1)
+}
+
+// removeDirEntry removes a node from the directory by moving the last entry into its slot.
+func (idx *PersistentIndex) removeDirEntry(nodeID uint64) error {
+	slot, ok := idx.dirSlots[nodeID]
+	if !ok {
+		return nil
+	}
+	last := len(idx.dirNodes) - 1
+	lastID := idx.dirNodes[last]
+
+	idx.dirNodes[slot] = lastID
+	idx.dirSlots[lastID] = slot
+	idx.dirNodes = idx.dirNodes[:last]
+	delete(idx.dirSlots, nodeID)
+	delete(idx.nodePages, nodeID)
+
+	if slot == last {
+		return nil
+	}
+	return idx.writeDirSlot(slot)
+}
+
 // loadMeta reads the metadata from the meta page
 func (idx *PersistentIndex) loadMeta() error {
 	page, err := idx.pager.Get(idx.metaPage)
@@ -187,6 +420,8 @@ func (idx *PersistentIndex) loadMeta() error {
 		return ErrInvalidMagic
 	}
 
+	idx.version = binary.LittleEndian.Uint32(data[5:9])
+
 	// Read config
 	flags := data[65]
 	idx.config = Config{
@@ -198,6 +433,24 @@ func (idx *PersistentIndex) loadMeta() error {
 		ML:               math.Float64frombits(binary.LittleEndian.Uint64(data[29:37])),
 		UseHeuristic:     flags&0x01 != 0,
 		ExtendCandidates: flags&0x02 != 0,
+		Rerank:           flags&0x04 != 0,
+		DistanceMetric:   types.DistanceMetric(data[66]),
+	}
+
+	if idx.version >= metaVersion {
+		idx.config.Quantization = Quantization(data[71])
+		idx.config.PQSegments = int(binary.LittleEndian.Uint16(data[72:74]))
+		idx.quantPage = binary.LittleEndian.Uint32(data[74:78])
+		idx.quantSize = binary.LittleEndian.Uint32(data[78:82])
+		if idx.quantPage != 0 {
+			quantData, err := idx.readVectorOverflow(idx.quantPage, int(idx.quantSize))
+			if err != nil {
+				return err
+			}
+			if idx.quantizer, err = unmarshalQuantizer(idx.config.Quantization, idx.config.DistanceMetric, quantData); err != nil {
+				return err
+			}
+		}
 	}
 
 	idx.entryPoint = binary.LittleEndian.Uint64(data[37:45])
@@ -205,16 +458,42 @@ func (idx *PersistentIndex) loadMeta() error {
 	idx.nextID = binary.LittleEndian.Uint64(data[49:57])
 	idx.nodeCount = binary.LittleEndian.Uint64(data[57:65])
 
-	// Read node page directory
-	offset := metaHeaderSize
-	pageSize := idx.pager.PageSize()
-	for i := uint64(0); i < idx.nodeCount && offset+nodePageEntrySize <= pageSize; i++ {
+	// Read node page directory, first from the meta page and then from the
+	// chain of directory pages
+	offset := idx.headerSize()
+	nextDirPage := binary.LittleEndian.Uint32(data[67:71])
+	for uint64(len(idx.dirNodes)) < idx.nodeCount {
+		if offset+nodePageEntrySize > len(data) {
+			if nextDirPage == 0 {
+				break
+			}
+			dirPage, err := idx.pager.Get(nextDirPage)
+			if err != nil {
+				return err
+			}
+			defer idx.pager.Release(dirPage)
+
+			data = dirPage.Data()
+			if pager.PageType(data[0]) != pager.PageTypeOverflow {
+				return ErrInvalidMetaPage
+			}
+			idx.dirPages = append(idx.dirPages, nextDirPage)
+			nextDirPage = binary.LittleEndian.Uint32(data[1:5])
+			offset = overflowHeaderSize
+			continue
+		}
+
 		nodeID := binary.LittleEndian.Uint64(data[offset : offset+8])
 		pageNo := binary.LittleEndian.Uint32(data[offset+8 : offset+12])
 		idx.nodePages[nodeID] = pageNo
+		idx.dirSlots[nodeID] = len(idx.dirNodes)
+		idx.dirNodes = append(idx.dirNodes, nodeID)
 		offset += nodePageEntrySize
 	}
 
+	// Older files kept only the entries that fit on the meta page
+	idx.nodeCount = uint64(len(idx.dirNodes))
+
 	return nil
 }
 
@@ -244,6 +523,10 @@ func (idx *PersistentIndex) Insert(rowID int64, vector *types.Vector) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	if idx.config.Quantization != QuantizationNone && idx.quantizer == nil {
+		return ErrNotTrained
+	}
+
 	// Assign node ID
 	nodeID := idx.nextID
 	idx.nextID++
@@ -251,8 +534,12 @@ func (idx *PersistentIndex) Insert(rowID int64, vector *types.Vector) error {
 	// Generate random level
 	level := idx.randomLevel()
 
-	// Create node
+	// Create node; a quantized index keeps only the code
 	node := NewHNSWNode(nodeID, rowID, vector, level)
+	if idx.quantizer != nil {
+		node.code = idx.quantizer.encode(vector)
+		node.vector = nil
+	}
 
 	// Allocate page for this node
 	// NOTE: Allocation may trigger mmap regrowth which invalidates cached pages
@@ -268,8 +555,13 @@ func (idx *PersistentIndex) Insert(rowID int64, vector *types.Vector) error {
 	idx.nodeCache = make(map[uint64]*HNSWNode)
 
 	// Store mapping
-	idx.nodePages[nodeID] = nodePageNo
+	if err := idx.addDirEntry(nodeID, nodePageNo); err != nil {
+		return err
+	}
 	idx.nodeCache[nodeID] = node
+	if idx.rowNodes != nil {
+		idx.rowNodes[rowID] = nodeID
+	}
 
 	// If this is the first node
 	if idx.nodeCount == 0 {
@@ -336,8 +628,110 @@ func (idx *PersistentIndex) Insert(rowID int64, vector *types.Vector) error {
 	return idx.writeMeta()
 }
 
+// needsVectorOverflow reports whether a node's vector must be stored on overflow
+// pages so that its page still has room for a full set of neighbor lists.
+func (idx *PersistentIndex) needsVectorOverflow(node *HNSWNode, vecBytes []byte) bool {
+	neighborSpace := 4*(node.level+1) + 8*(idx.config.MMax0+node.level*idx.config.M)
+	return 1+20+4+len(vecBytes)+neighborSpace > idx.pager.PageSize()
+}
+
+// writeVectorOverflow writes vector bytes to a newly allocated chain of overflow pages
+// and returns the first page number.
+func (idx *PersistentIndex) writeVectorOverflow(vecBytes []byte) (uint32, error) {
+	chunkSize := idx.pager.PageSize() - overflowHeaderSize
+	var first, prev uint32
+	for start := 0; start < len(vecBytes); start += chunkSize {
+		end := start + chunkSize
+		if end > len(vecBytes) {
+			end = len(vecBytes)
+		}
+
+		page, err := idx.pager.Allocate()
+		if err != nil {
+			return 0, err
+		}
+		pageNo := page.PageNo()
+		data := page.Data()
+		data[0] = byte(pager.PageTypeOverflow)
+		binary.LittleEndian.PutUint32(data[1:5], 0)
+		copy(data[overflowHeaderSize:], vecBytes[start:end])
+		page.SetDirty(true)
+		idx.pager.Release(page)
+
+		if prev == 0 {
+			first = pageNo
+		} else {
+			prevPage, err := idx.pager.Get(prev)
+			if err != nil {
+				return 0, err
+			}
+			binary.LittleEndian.PutUint32(prevPage.Data()[1:5], pageNo)
+			prevPage.SetDirty(true)
+			idx.pager.Release(prevPage)
+		}
+		prev = pageNo
+	}
+	return first, nil
+}
+
+// readVectorOverflow reads size bytes from an overflow chain.
+func (idx *PersistentIndex) readVectorOverflow(firstPage uint32, size int) ([]byte, error) {
+	buf := make([]byte, 0, size)
+	for pageNo := firstPage; pageNo != 0 && len(buf) < size; {
+		page, err := idx.pager.Get(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		data := page.Data()
+		if pager.PageType(data[0]) != pager.PageTypeOverflow {
+			idx.pager.Release(page)
+			return nil, ErrCorruptedData
+		}
+		n := size - len(buf)
+		if n > len(data)-overflowHeaderSize {
+			n = len(data) - overflowHeaderSize
+		}
+		buf = append(buf, data[overflowHeaderSize:overflowHeaderSize+n]...)
+		pageNo = binary.LittleEndian.Uint32(data[1:5])
+		idx.pager.Release(page)
+	}
+	if len(buf) < size {
+		return nil, ErrCorruptedData
+	}
+	return buf, nil
+}
+
+// overflowChain returns the pages of an overflow chain.
+func (idx *PersistentIndex) overflowChain(firstPage uint32) []uint32 {
+	var pages []uint32
+	for pageNo := firstPage; pageNo != 0; {
+		page, err := idx.pager.Get(pageNo)
+		if err != nil {
+			break
+		}
+		pages = append(pages, pageNo)
+		pageNo = binary.LittleEndian.Uint32(page.Data()[1:5])
+		idx.pager.Release(page)
+	}
+	return pages
+}
+
 // writeNode writes a node to its page
 func (idx *PersistentIndex) writeNode(node *HNSWNode, pageNo uint32) error {
+	// Spill large vectors before touching the node page, since allocation
+	// may remap the file
+	vecBytes := node.code
+	if vecBytes == nil {
+		vecBytes = node.vector.ToBytes()
+	}
+	if _, ok := idx.vecPages[node.id]; !ok && idx.needsVectorOverflow(node, vecBytes) {
+		vecPage, err := idx.writeVectorOverflow(vecBytes)
+		if err != nil {
+			return err
+		}
+		idx.vecPages[node.id] = vecPage
+	}
+
 	page, err := idx.pager.Get(pageNo)
 	if err != nil {
 		return err
@@ -363,15 +757,20 @@ func (idx *PersistentIndex) writeNode(node *HNSWNode, pageNo uint32) error {
 	binary.LittleEndian.PutUint32(data[offset:offset+4], uint32(node.level))
 	offset += 4
 
-	// Write vector
-	vecBytes := node.vector.ToBytes()
-	if offset+4+len(vecBytes) > pageSize {
-		return errors.New("page too small for vector data")
+	// Write vector, either inline or as a pointer to its overflow chain
+	if vecPage, ok := idx.vecPages[node.id]; ok {
+		binary.LittleEndian.PutUint32(data[offset:offset+4], uint32(len(vecBytes))|vecOverflowFlag)
+		binary.LittleEndian.PutUint32(data[offset+4:offset+8], vecPage)
+		offset += 8
+	} else {
+		if offset+4+len(vecBytes) > pageSize {
+			return errors.New("page too small for vector data")
+		}
+		binary.LittleEndian.PutUint32(data[offset:offset+4], uint32(len(vecBytes)))
+		offset += 4
+		copy(data[offset:offset+len(vecBytes)], vecBytes)
+		offset += len(vecBytes)
 	}
-	binary.LittleEndian.PutUint32(data[offset:offset+4], uint32(len(vecBytes)))
-	offset += 4
-	copy(data[offset:offset+len(vecBytes)], vecBytes)
-	offset += len(vecBytes)
 
 	// Write neighbors for each level
 	for l := 0; l <= node.level; l++ {
@@ -419,23 +818,34 @@ func (idx *PersistentIndex) readNode(pageNo uint32) (*HNSWNode, error) {
 	level := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
 	offset += 4
 
-	// Read vector
+	// Read vector, following the overflow chain if it was spilled
 	vecSize := binary.LittleEndian.Uint32(data[offset : offset+4])
 	offset += 4
-	vector, err := types.VectorFromBytes(data[offset : offset+int(vecSize)])
-	if err != nil {
-		return nil, err
+	var vecBytes []byte
+	if vecSize&vecOverflowFlag != 0 {
+		vecPage := binary.LittleEndian.Uint32(data[offset : offset+4])
+		offset += 4
+		if vecBytes, err = idx.readVectorOverflow(vecPage, int(vecSize&^vecOverflowFlag)); err != nil {
+			return nil, err
+		}
+		idx.vecPages[nodeID] = vecPage
+	} else {
+		vecBytes = data[offset : offset+int(vecSize)]
+		offset += int(vecSize)
 	}
-	offset += int(vecSize)
 
 	// Create node
 	node := &HNSWNode{
 		id:        nodeID,
 		rowID:     rowID,
-		vector:    vector,
 		level:     level,
 		neighbors: make([][]uint64, level+1),
 	}
+	if idx.quantizer != nil {
+		node.code = append([]byte(nil), vecBytes...)
+	} else if node.vector, err = types.VectorFromBytes(vecBytes); err != nil {
+		return nil, err
+	}
 
 	// Read neighbors
 	for l := 0; l <= level; l++ {
@@ -491,7 +901,7 @@ func (idx *PersistentIndex) searchLayerClosest(query *types.Vector, ep uint64, l
 	if currentNode == nil {
 		return ep
 	}
-	currentDist := idx.distance(query, currentNode.Vector())
+	currentDist := idx.queryDistance(query, currentNode)
 
 	for {
 		improved := false
@@ -504,7 +914,7 @@ func (idx *PersistentIndex) searchLayerClosest(query *types.Vector, ep uint64, l
 			if neighborNode == nil {
 				continue
 			}
-			dist := idx.distance(query, neighborNode.Vector())
+			dist := idx.queryDistance(query, neighborNode)
 			if dist < currentDist {
 				current = neighborID
 				currentDist = dist
@@ -529,7 +939,7 @@ func (idx *PersistentIndex) searchLayer(query *types.Vector, ep uint64, ef int,
 	visited := make(map[uint64]bool)
 	visited[ep] = true
 
-	candidates := []distNode{{id: ep, dist: idx.distance(query, epNode.Vector())}}
+	candidates := []distNode{{id: ep, dist: idx.queryDistance(query, epNode)}}
 	results := []distNode{{id: ep, dist: candidates[0].dist}}
 
 	for len(candidates) > 0 {
@@ -556,7 +966,7 @@ func (idx *PersistentIndex) searchLayer(query *types.Vector, ep uint64, ef int,
 				continue
 			}
 
-			dist := idx.distance(query, neighborNode.Vector())
+			dist := idx.queryDistance(query, neighborNode)
 
 			if len(results) < ef || dist < results[len(results)-1].dist {
 				results = insertSorted(results, distNode{id: neighborID, dist: dist})
@@ -621,7 +1031,7 @@ func (idx *PersistentIndex) selectNeighborsHeuristic(query *types.Vector, candid
 		if node == nil {
 			continue
 		}
-		dist := idx.distance(query, node.Vector())
+		dist := idx.queryDistance(query, node)
 		workQueue = append(workQueue, candDist{id: id, dist: dist})
 	}
 
@@ -652,7 +1062,7 @@ func (idx *PersistentIndex) selectNeighborsHeuristic(query *types.Vector, candid
 			if selNode == nil {
 				continue
 			}
-			distToNeighbor := idx.distance(candNode.Vector(), selNode.Vector())
+			distToNeighbor := idx.nodeDistance(candNode, selNode)
 			if distToNeighbor < cand.dist {
 				isGood = false
 				break
@@ -702,7 +1112,7 @@ func (idx *PersistentIndex) pruneConnections(node *HNSWNode, level int, maxConne
 		if neighborNode == nil {
 			continue
 		}
-		nds = append(nds, nd{id: nid, dist: idx.distance(node.Vector(), neighborNode.Vector())})
+		nds = append(nds, nd{id: nid, dist: idx.nodeDistance(node, neighborNode)})
 	}
 
 	for i := 0; i < len(nds)-1; i++ {
@@ -762,7 +1172,7 @@ func (idx *PersistentIndex) SearchKNNWithEf(query *types.Vector, k int, ef int)
 		}
 		results = append(results, SearchResult{
 			RowID:    node.RowID(),
-			Distance: idx.distance(query, node.Vector()),
+			Distance: idx.queryDistance(query, node),
 		})
 	}
 
@@ -778,28 +1188,102 @@ func (idx *PersistentIndex) SearchKNNWithEf(query *types.Vector, k int, ef int)
 	return results, nil
 }
 
-// Delete removes a node by rowID
-func (idx *PersistentIndex) Delete(rowID int64) bool {
+// SearchKNNFiltered finds the k nearest neighbors among the rows accepted by filter,
+// falling back to an exact scan of the matching rows when the filter is very selective.
+// See Index.SearchKNNFiltered.
+func (idx *PersistentIndex) SearchKNNFiltered(query *types.Vector, k int, ef int, filter Filter) ([]SearchResult, error) {
+	if filter == nil {
+		return idx.SearchKNNWithEf(query, k, ef)
+	}
+	if query.Dimension() != idx.config.Dimension {
+		return nil, ErrDimensionMismatch
+	}
+
+	// Full lock: the rowID map may be built on first use
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Find node with matching rowID
-	var nodeToDelete *HNSWNode
-	var nodeIDToDelete uint64
-	for nodeID := range idx.nodePages {
+	if idx.nodeCount == 0 {
+		return []SearchResult{}, nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	// Estimate selectivity from nodes spread evenly over the directory
+	step := len(idx.dirNodes)/filterSampleSize + 1
+	sampled, matched := 0, 0
+	for i := 0; i < len(idx.dirNodes); i += step {
+		node := idx.getNode(idx.dirNodes[i])
+		if node == nil {
+			continue
+		}
+		sampled++
+		if filter(node.RowID()) {
+			matched++
+		}
+	}
+	if sampled == 0 || float64(matched)/float64(sampled) < bruteForceSelectivity {
+		return idx.bruteForceSearch(query, k, filter), nil
+	}
+
+	ep := idx.entryPoint
+	for l := idx.maxLevel; l > 0; l-- {
+		ep = idx.searchLayerClosest(query, ep, l)
+	}
+
+	found := searchLayerFiltered(query, ep, ef, idx.queryDistance, idx.neighborsAt, filter)
+	if len(found) < k {
+		return idx.bruteForceSearch(query, k, filter), nil
+	}
+	return found[:k], nil
+}
+
+// bruteForceSearch computes the exact k nearest neighbors among the rows accepted by
+// filter. Only the nodes of matching rows are read.
+func (idx *PersistentIndex) bruteForceSearch(query *types.Vector, k int, filter Filter) []SearchResult {
+	idx.lookupRow(0) // make sure the rowID map is built
+	results := []SearchResult{}
+	for rowID, nodeID := range idx.rowNodes {
+		if !filter(rowID) {
+			continue
+		}
 		node := idx.getNode(nodeID)
-		if node != nil && node.rowID == rowID {
-			nodeToDelete = node
-			nodeIDToDelete = nodeID
-			break
+		if node == nil {
+			continue
 		}
+		results = insertTopK(results, SearchResult{RowID: rowID, Distance: idx.queryDistance(query, node)}, k)
 	}
+	return results
+}
 
+// neighborsAt returns a node and its neighbor list at the given level.
+func (idx *PersistentIndex) neighborsAt(nodeID uint64, level int) (*HNSWNode, []uint64) {
+	node := idx.getNode(nodeID)
+	if node == nil {
+		return nil, nil
+	}
+	return node, node.Neighbors(level)
+}
+
+// Delete removes a node by rowID
+func (idx *PersistentIndex) Delete(rowID int64) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Find node with matching rowID
+	nodeIDToDelete, ok := idx.lookupRow(rowID)
+	if !ok {
+		return false
+	}
+	nodeToDelete := idx.getNode(nodeIDToDelete)
 	if nodeToDelete == nil {
 		return false
 	}
+	nodePageNo := idx.nodePages[nodeIDToDelete]
 
-	// Remove from neighbors
+	// Remove from neighbors, reconnecting each of them to the deleted node's
+	// other neighbors so that deletes do not break the graph apart
 	for level := 0; level <= nodeToDelete.level; level++ {
 		neighbors := nodeToDelete.Neighbors(level)
 		for _, neighborID := range neighbors {
@@ -808,6 +1292,7 @@ func (idx *PersistentIndex) Delete(rowID int64) bool {
 				continue
 			}
 			neighbor.RemoveNeighbor(level, nodeIDToDelete)
+			idx.repairNeighborConnections(neighbor, level, neighbors)
 			if pageNo, ok := idx.nodePages[neighborID]; ok {
 				idx.writeNode(neighbor, pageNo)
 			}
@@ -815,8 +1300,11 @@ func (idx *PersistentIndex) Delete(rowID int64) bool {
 	}
 
 	// Remove from maps
-	delete(idx.nodePages, nodeIDToDelete)
+	if err := idx.removeDirEntry(nodeIDToDelete); err != nil {
+		return false
+	}
 	delete(idx.nodeCache, nodeIDToDelete)
+	delete(idx.rowNodes, rowID)
 	idx.nodeCount--
 
 	// Update entry point if needed
@@ -824,11 +1312,249 @@ func (idx *PersistentIndex) Delete(rowID int64) bool {
 		idx.updateEntryPoint()
 	}
 
-	// TODO: Mark the node page as free for reuse
+	// Return the node's pages to the freelist
+	if vecPage, ok := idx.vecPages[nodeIDToDelete]; ok {
+		for _, pageNo := range idx.overflowChain(vecPage) {
+			idx.pager.Free(pageNo)
+		}
+		delete(idx.vecPages, nodeIDToDelete)
+	}
+	idx.pager.Free(nodePageNo)
 
 	return idx.writeMeta() == nil
 }
 
+// repairNeighborConnections links node to the candidates it is not yet
+// connected to at level, the other neighbors of a deleted node, and then keeps
+// only the closest connections
+func (idx *PersistentIndex) repairNeighborConnections(node *HNSWNode, level int, candidates []uint64) {
+	maxNeighbors := idx.config.M
+	if level == 0 {
+		maxNeighbors = idx.config.MMax0
+	}
+
+	connected := make(map[uint64]bool)
+	for _, nid := range node.Neighbors(level) {
+		connected[nid] = true
+	}
+	for _, cid := range candidates {
+		if cid == node.id || connected[cid] {
+			continue
+		}
+		if cnode := idx.getNode(cid); cnode == nil || cnode.level < level {
+			continue
+		}
+		node.AddNeighbor(level, cid)
+		connected[cid] = true
+	}
+	idx.pruneConnections(node, level, maxNeighbors)
+}
+
+// lookupRow returns the nodeID holding rowID. The rowID map is built from
+// the node pages on first use and maintained by Insert and Delete afterwards.
+func (idx *PersistentIndex) lookupRow(rowID int64) (uint64, bool) {
+	if idx.rowNodes == nil {
+		idx.rowNodes = make(map[int64]uint64, len(idx.nodePages))
+		for nodeID := range idx.nodePages {
+			if node := idx.getNode(nodeID); node != nil {
+				idx.rowNodes[node.rowID] = nodeID
+			}
+		}
+	}
+	nodeID, ok := idx.rowNodes[rowID]
+	return nodeID, ok
+}
+
+// CollectPages returns every page owned by the index, including the meta page.
+func (idx *PersistentIndex) CollectPages() []uint32 {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	pages := []uint32{idx.metaPage}
+	pages = append(pages, idx.dirPages...)
+	for nodeID, pageNo := range idx.nodePages {
+		pages = append(pages, pageNo)
+		idx.getNode(nodeID) // loads the vector overflow page, if any
+		if vecPage, ok := idx.vecPages[nodeID]; ok {
+			pages = append(pages, idx.overflowChain(vecPage)...)
+		}
+	}
+	// The quantizer goes last so Clear can keep it
+	return append(pages, idx.overflowChain(idx.quantPage)...)
+}
+
+// CopyTo writes a copy of the index to pager p, with the same nodes, graph and
+// trained quantizer, and returns it. Used by VACUUM to rebuild the database.
+func (idx *PersistentIndex) CopyTo(p *pager.Pager) (*PersistentIndex, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	dst, err := CreatePersistent(p, idx.config)
+	if err != nil {
+		return nil, err
+	}
+	if idx.quantizer != nil {
+		data := idx.quantizer.marshal()
+		if dst.quantPage, err = dst.writeVectorOverflow(data); err != nil {
+			return nil, err
+		}
+		dst.quantizer = idx.quantizer
+		dst.quantSize = uint32(len(data))
+	}
+	dst.entryPoint = idx.entryPoint
+	dst.maxLevel = idx.maxLevel
+	dst.nextID = idx.nextID
+
+	for _, nodeID := range idx.dirNodes {
+		node := idx.getNode(nodeID)
+		if node == nil {
+			return nil, ErrNodeNotFound
+		}
+
+		page, err := p.Allocate()
+		if err != nil {
+			return nil, err
+		}
+		pageNo := page.PageNo()
+		p.Release(page)
+
+		if err := dst.addDirEntry(nodeID, pageNo); err != nil {
+			return nil, err
+		}
+		if err := dst.writeNode(node, pageNo); err != nil {
+			return nil, err
+		}
+		dst.nodeCount++
+	}
+
+	if err := dst.writeMeta(); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// RelocatePage repoints the index from page from, any of the pages returned by
+// CollectPages, to page to, which already holds a copy of its content. Used by
+// incremental vacuum; when the meta page moves, MetaPage changes.
+func (idx *PersistentIndex) RelocatePage(from, to uint32) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if from == idx.metaPage {
+		idx.metaPage = to
+		return nil
+	}
+
+	for i, pageNo := range idx.dirPages {
+		if pageNo != from {
+			continue
+		}
+		idx.dirPages[i] = to
+		if i == 0 {
+			return idx.writeMeta()
+		}
+		return idx.setNextPage(idx.dirPages[i-1], to)
+	}
+
+	for nodeID, pageNo := range idx.nodePages {
+		if pageNo == from {
+			idx.nodePages[nodeID] = to
+			return idx.writeDirSlot(idx.dirSlots[nodeID])
+		}
+	}
+
+	if idx.quantPage == from {
+		idx.quantPage = to
+		return idx.writeMeta()
+	}
+	if found, err := idx.relocateInChain(idx.quantPage, from, to); found || err != nil {
+		return err
+	}
+
+	for nodeID := range idx.nodePages {
+		idx.getNode(nodeID) // loads the vector overflow page, if any
+	}
+	for nodeID, vecPage := range idx.vecPages {
+		if vecPage == from {
+			idx.vecPages[nodeID] = to
+			page, err := idx.pager.Get(idx.nodePages[nodeID])
+			if err != nil {
+				return err
+			}
+			// The overflow page follows the node header and the vector size
+			binary.LittleEndian.PutUint32(page.Data()[25:29], to)
+			page.SetDirty(true)
+			idx.pager.Release(page)
+			return nil
+		}
+		if found, err := idx.relocateInChain(vecPage, from, to); found || err != nil {
+			return err
+		}
+	}
+	return ErrPageNotOwned
+}
+
+// relocateInChain repoints the link to from in the overflow chain starting at
+// firstPage, if the chain contains from
+func (idx *PersistentIndex) relocateInChain(firstPage, from, to uint32) (bool, error) {
+	for pageNo := firstPage; pageNo != 0; {
+		page, err := idx.pager.Get(pageNo)
+		if err != nil {
+			return false, err
+		}
+		next := binary.LittleEndian.Uint32(page.Data()[1:5])
+		idx.pager.Release(page)
+		if next == from {
+			return true, idx.setNextPage(pageNo, to)
+		}
+		pageNo = next
+	}
+	return false, nil
+}
+
+// setNextPage sets the next page link of a directory or overflow page
+func (idx *PersistentIndex) setNextPage(pageNo, next uint32) error {
+	page, err := idx.pager.Get(pageNo)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(page.Data()[1:5], next)
+	page.SetDirty(true)
+	idx.pager.Release(page)
+	return nil
+}
+
+// Clear removes all nodes, returning their pages to the freelist.
+// The meta page and the trained quantizer are kept so the index can be reused
+// at the same location.
+func (idx *PersistentIndex) Clear() error {
+	pages := idx.CollectPages()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	kept := len(idx.overflowChain(idx.quantPage))
+	for _, pageNo := range pages[1 : len(pages)-kept] {
+		if err := idx.pager.Free(pageNo); err != nil {
+			return err
+		}
+	}
+
+	idx.entryPoint = 0
+	idx.maxLevel = 0
+	idx.nextID = 0
+	idx.nodeCount = 0
+	idx.nodePages = make(map[uint64]uint32)
+	idx.nodeCache = make(map[uint64]*HNSWNode)
+	idx.dirNodes = nil
+	idx.dirSlots = make(map[uint64]int)
+	idx.dirPages = nil
+	idx.vecPages = make(map[uint64]uint32)
+	idx.rowNodes = nil
+
+	return idx.writeMeta()
+}
+
 // updateEntryPoint finds a new entry point after deletion
 func (idx *PersistentIndex) updateEntryPoint() {
 	if idx.nodeCount == 0 {
@@ -879,30 +1605,26 @@ func (idx *PersistentIndex) Update(rowID int64, newVector *types.Vector) (bool,
 // GetByRowID retrieves the vector for a given rowID
 // Returns nil if not found
 func (idx *PersistentIndex) GetByRowID(rowID int64) *types.Vector {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 
-	for nodeID := range idx.nodePages {
-		node := idx.getNode(nodeID)
-		if node != nil && node.rowID == rowID {
-			return node.vector
-		}
+	nodeID, ok := idx.lookupRow(rowID)
+	if !ok {
+		return nil
+	}
+	if node := idx.getNode(nodeID); node != nil {
+		return idx.nodeVector(node)
 	}
 	return nil
 }
 
 // Contains checks if a rowID exists in the index
 func (idx *PersistentIndex) Contains(rowID int64) bool {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 
-	for nodeID := range idx.nodePages {
-		node := idx.getNode(nodeID)
-		if node != nil && node.rowID == rowID {
-			return true
-		}
-	}
-	return false
+	_, ok := idx.lookupRow(rowID)
+	return ok
 }
 
 // randFloat returns a random float64 between 0 and 1
@@ -207,6 +207,52 @@ func TestPersistentDelete(t *testing.T) {
 	}
 }
 
+func TestPersistentDeleteKeepsGraphConnected(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	idx, err := CreatePersistent(p, DefaultConfig(3))
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+
+	// Normalized points along a line, which crowd together as i grows
+	const n = 1000
+	vector := func(i int) *types.Vector {
+		vec := types.NewVector([]float32{float32(i), 1, 0})
+		vec.Normalize()
+		return vec
+	}
+	for i := 1; i <= n; i++ {
+		if err := idx.Insert(int64(i), vector(i)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+
+	// Deleting nine rows in ten must not cut the survivors off from each
+	// other; check those still far enough apart to tell from their neighbors
+	for i := 1; i <= n; i++ {
+		if i%10 != 0 && !idx.Delete(int64(i)) {
+			t.Fatalf("delete %d failed", i)
+		}
+	}
+	for i := 10; i <= 100; i += 10 {
+		results, err := idx.SearchKNN(vector(i), 1)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].RowID != int64(i) {
+			t.Errorf("expected row %d to be its own nearest neighbor, got %v", i, results)
+		}
+	}
+}
+
 func TestPersistentLargeIndex(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -265,3 +311,371 @@ func TestPersistentLargeIndex(t *testing.T) {
 	}
 	t.Logf("Database file size: %d bytes", info.Size())
 }
+
+func TestPersistentReopenLargeDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	// More nodes than fit in the meta page directory of a 4KB page
+	const n = 800
+	var metaPage uint32
+
+	{
+		p, err := pager.Open(dbPath, pager.Options{})
+		if err != nil {
+			t.Fatalf("failed to open pager: %v", err)
+		}
+
+		config := DefaultConfig(4)
+		config.DistanceMetric = types.DistanceMetricEuclidean
+		idx, err := CreatePersistent(p, config)
+		if err != nil {
+			t.Fatalf("failed to create persistent index: %v", err)
+		}
+		metaPage = idx.MetaPage()
+
+		for i := 0; i < n; i++ {
+			vec := types.NewVector([]float32{float32(i), float32(i % 7), float32(i % 13), 1})
+			if err := idx.Insert(int64(i), vec); err != nil {
+				t.Fatalf("insert %d failed: %v", i, err)
+			}
+		}
+		// Deleting moves entries within the directory
+		for i := 0; i < n; i += 10 {
+			if !idx.Delete(int64(i)) {
+				t.Fatalf("delete %d failed", i)
+			}
+		}
+
+		if err := idx.Sync(); err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+		p.Close()
+	}
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to reopen pager: %v", err)
+	}
+	defer p.Close()
+
+	idx, err := OpenPersistent(p, metaPage)
+	if err != nil {
+		t.Fatalf("failed to open persistent index: %v", err)
+	}
+
+	if idx.Len() != n-n/10 {
+		t.Errorf("expected %d nodes after reopen, got %d", n-n/10, idx.Len())
+	}
+	if idx.Config().DistanceMetric != types.DistanceMetricEuclidean {
+		t.Errorf("expected euclidean metric after reopen, got %v", idx.Config().DistanceMetric)
+	}
+	if idx.Contains(10) {
+		t.Error("deleted rowID 10 should not be present")
+	}
+	if !idx.Contains(799) {
+		t.Error("rowID 799 should be present")
+	}
+
+	results, err := idx.SearchKNN(types.NewVector([]float32{799, 799 % 7, 799 % 13, 1}), 1)
+	if err != nil {
+		t.Fatalf("search after reopen failed: %v", err)
+	}
+	if len(results) != 1 || results[0].RowID != 799 {
+		t.Errorf("expected rowID 799, got %v", results)
+	}
+}
+
+func TestPersistentVectorOverflow(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	// 2048 dims = 8KB of vector data, larger than a 4KB page
+	const dim = 2048
+	idx, err := CreatePersistent(p, DefaultConfig(dim))
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(math.Sin(float64(i*dim + j)))
+		}
+		vec := types.NewVector(v)
+		vec.Normalize()
+		if err := idx.Insert(int64(i), vec); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+
+	reopened, err := OpenPersistent(p, idx.MetaPage())
+	if err != nil {
+		t.Fatalf("failed to open persistent index: %v", err)
+	}
+	want := idx.GetByRowID(3)
+	got := reopened.GetByRowID(3)
+	if got == nil || got.Dimension() != dim {
+		t.Fatalf("expected %d-dim vector for rowID 3, got %v", dim, got)
+	}
+	for i := 0; i < dim; i++ {
+		if got.Data()[i] != want.Data()[i] {
+			t.Fatalf("vector mismatch at %d: %f != %f", i, got.Data()[i], want.Data()[i])
+		}
+	}
+
+	// Deleting returns node and overflow pages to the freelist
+	freeBefore := p.FreePageCount()
+	if !reopened.Delete(3) {
+		t.Fatal("delete failed")
+	}
+	if p.FreePageCount() <= freeBefore+1 {
+		t.Errorf("expected node and overflow pages to be freed, free count %d -> %d", freeBefore, p.FreePageCount())
+	}
+}
+
+func TestPersistentClear(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	idx, err := CreatePersistent(p, DefaultConfig(3))
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := idx.Insert(int64(i), types.NewVector([]float32{float32(i), 1, 0})); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	if err := idx.Clear(); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if idx.Len() != 0 {
+		t.Errorf("expected empty index after clear, got %d", idx.Len())
+	}
+	if got := len(idx.CollectPages()); got != 1 {
+		t.Errorf("expected only the meta page after clear, got %d pages", got)
+	}
+
+	if err := idx.Insert(42, types.NewVector([]float32{1, 0, 0})); err != nil {
+		t.Fatalf("insert after clear failed: %v", err)
+	}
+	reopened, err := OpenPersistent(p, idx.MetaPage())
+	if err != nil {
+		t.Fatalf("failed to open persistent index: %v", err)
+	}
+	if reopened.Len() != 1 || !reopened.Contains(42) {
+		t.Errorf("expected only rowID 42 after clear and insert, got %d nodes", reopened.Len())
+	}
+}
+
+func TestPersistentSearchKNNFiltered(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	config := DefaultConfig(2)
+	config.DistanceMetric = types.DistanceMetricEuclidean
+	idx, err := CreatePersistent(p, config)
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := idx.Insert(int64(i), types.NewVector([]float32{float32(i), 0})); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	query := types.NewVector([]float32{50.2, 0})
+
+	// Half the rows match: graph search
+	results, err := idx.SearchKNNFiltered(query, 2, 50, func(rowID int64) bool { return rowID%2 == 1 })
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].RowID != 51 || results[1].RowID != 49 {
+		t.Errorf("expected [51 49], got %v", results)
+	}
+
+	// A single row matches: exact scan
+	results, err = idx.SearchKNNFiltered(query, 2, 50, func(rowID int64) bool { return rowID == 7 })
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].RowID != 7 {
+		t.Errorf("expected [7], got %v", results)
+	}
+}
+
+func TestPersistentRelocatePage(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	// Spilled vectors and more nodes than the meta page directory holds
+	const dim, n = 1100, 350
+	idx, err := CreatePersistent(p, DefaultConfig(dim))
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(math.Sin(float64(i*dim + j)))
+		}
+		if err := idx.Insert(int64(i), types.NewVector(v)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+
+	// Move every page of the index to a new one
+	oldPages := idx.CollectPages()
+	moved := make(map[uint32]bool)
+	for _, from := range oldPages {
+		page, err := p.Allocate()
+		if err != nil {
+			t.Fatalf("allocate failed: %v", err)
+		}
+		to := page.PageNo()
+		p.Release(page)
+
+		src, _ := p.Get(from)
+		dst, _ := p.Get(to)
+		copy(dst.Data(), src.Data())
+		dst.SetDirty(true)
+		p.Release(src)
+		p.Release(dst)
+
+		if err := idx.RelocatePage(from, to); err != nil {
+			t.Fatalf("relocate %d -> %d failed: %v", from, to, err)
+		}
+		moved[from] = true
+	}
+	for _, pageNo := range oldPages {
+		if err := p.Free(pageNo); err != nil {
+			t.Fatalf("free %d failed: %v", pageNo, err)
+		}
+	}
+	if err := idx.RelocatePage(oldPages[0], 1); err == nil {
+		t.Error("expected an error relocating a page the index no longer owns")
+	}
+
+	reopened, err := OpenPersistent(p, idx.MetaPage())
+	if err != nil {
+		t.Fatalf("failed to open relocated index: %v", err)
+	}
+	if reopened.Len() != n {
+		t.Fatalf("expected %d nodes, got %d", n, reopened.Len())
+	}
+	for _, pageNo := range reopened.CollectPages() {
+		if moved[pageNo] {
+			t.Fatalf("relocated index still uses page %d", pageNo)
+		}
+	}
+	for _, rowID := range []int64{0, 123, n - 1} {
+		want, got := idx.GetByRowID(rowID), reopened.GetByRowID(rowID)
+		if got == nil || got.Dimension() != dim {
+			t.Fatalf("expected %d-dim vector for rowID %d, got %v", dim, rowID, got)
+		}
+		for i := 0; i < dim; i++ {
+			if got.Data()[i] != want.Data()[i] {
+				t.Fatalf("rowID %d: vector mismatch at %d", rowID, i)
+			}
+		}
+	}
+	results, err := reopened.SearchKNN(reopened.GetByRowID(42), 1)
+	if err != nil || len(results) != 1 || results[0].RowID != 42 {
+		t.Errorf("expected rowID 42 as its own nearest neighbor, got %v, %v", results, err)
+	}
+}
+
+func TestPersistentCopyTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	src, err := pager.Open(filepath.Join(tmpDir, "src.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer src.Close()
+	dst, err := pager.Open(filepath.Join(tmpDir, "dst.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer dst.Close()
+
+	const dim = 16
+	config := DefaultConfig(dim)
+	config.Quantization = QuantizationInt8
+	idx, err := CreatePersistent(src, config)
+	if err != nil {
+		t.Fatalf("failed to create persistent index: %v", err)
+	}
+	vectors := make([]*types.Vector, 200)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(math.Sin(float64(i*dim + j)))
+		}
+		vectors[i] = types.NewVector(v)
+	}
+	if err := idx.Train(vectors); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	for i, v := range vectors {
+		if err := idx.Insert(int64(i), v); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	idx.Delete(7)
+
+	copied, err := idx.CopyTo(dst)
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	reopened, err := OpenPersistent(dst, copied.MetaPage())
+	if err != nil {
+		t.Fatalf("failed to open copied index: %v", err)
+	}
+	if reopened.Len() != idx.Len() || !reopened.Trained() {
+		t.Fatalf("copy has %d nodes (trained %v), want %d", reopened.Len(), reopened.Trained(), idx.Len())
+	}
+
+	// The copy has the same graph, so searches return the same results
+	for _, q := range []int{0, 50, 199} {
+		want, _ := idx.SearchKNN(vectors[q], 5)
+		got, err := reopened.SearchKNN(vectors[q], 5)
+		if err != nil || len(got) != len(want) {
+			t.Fatalf("search %d: got %v, %v; want %v", q, got, err, want)
+		}
+		for i := range want {
+			if got[i].RowID != want[i].RowID {
+				t.Errorf("search %d result %d: rowID %d, want %d", q, i, got[i].RowID, want[i].RowID)
+			}
+		}
+	}
+	if reopened.Contains(7) {
+		t.Error("deleted rowID 7 should not be in the copy")
+	}
+}
@@ -0,0 +1,449 @@
+// pkg/hnsw/quantize.go
+package hnsw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"tur/pkg/types"
+)
+
+var (
+	ErrNotTrained     = errors.New("HNSW quantizer has not been trained")
+	ErrAlreadyTrained = errors.New("HNSW quantizer must be trained before vectors are inserted")
+	ErrNoTrainingData = errors.New("HNSW quantizer needs at least one vector to train on")
+)
+
+// Quantization selects how an index stores node vectors
+type Quantization uint8
+
+const (
+	// QuantizationNone stores full-precision float32 vectors
+	QuantizationNone Quantization = iota
+	// QuantizationInt8 stores one byte per dimension, scaled to the per-dimension
+	// range seen during training (4x smaller than float32)
+	QuantizationInt8
+	// QuantizationPQ stores one byte per subvector, the index of the nearest
+	// centroid in a per-subvector codebook trained with k-means
+	QuantizationPQ
+)
+
+// String returns the name used for the quantization in SQL
+func (q Quantization) String() string {
+	switch q {
+	case QuantizationNone:
+		return "none"
+	case QuantizationInt8:
+		return "int8"
+	case QuantizationPQ:
+		return "pq"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseQuantization parses a quantization name: 'none', 'int8' (or 'sq8') or 'pq'
+func ParseQuantization(s string) (Quantization, error) {
+	switch strings.ToLower(s) {
+	case "none":
+		return QuantizationNone, nil
+	case "int8", "sq8":
+		return QuantizationInt8, nil
+	case "pq":
+		return QuantizationPQ, nil
+	default:
+		return 0, fmt.Errorf("unknown quantization: %q", s)
+	}
+}
+
+// DefaultPQSegments returns the number of PQ subvectors used when none is given:
+// subvectors of 8 dimensions, or the largest of 4, 2 or 1 that divides dimension
+func DefaultPQSegments(dimension int) int {
+	for _, subDim := range []int{8, 4, 2} {
+		if dimension%subDim == 0 {
+			return dimension / subDim
+		}
+	}
+	return dimension
+}
+
+const (
+	// MaxTrainingSamples bounds the number of vectors a quantizer is trained on.
+	// Larger inputs are sampled evenly.
+	MaxTrainingSamples = 10000
+
+	// pqCentroids is the number of centroids per subvector (one byte per code)
+	pqCentroids = 256
+
+	// pqIterations is the number of k-means iterations used to train a PQ codebook
+	pqIterations = 10
+)
+
+// quantizer compresses vectors into codes and computes distances on them
+type quantizer interface {
+	// encode compresses a vector into its code
+	encode(v *types.Vector) []byte
+	// decode reconstructs an approximation of the vector a code was made from
+	decode(code []byte) *types.Vector
+	// distance computes the distance from a full-precision query to a code
+	distance(query *types.Vector, code []byte) float32
+	// codeDistance computes the distance between two codes
+	codeDistance(a, b []byte) float32
+	// marshal serializes the trained parameters
+	marshal() []byte
+}
+
+// trainQuantizer trains a quantizer of the configured kind on samples
+func trainQuantizer(config Config, samples []*types.Vector) (quantizer, error) {
+	if len(samples) == 0 {
+		return nil, ErrNoTrainingData
+	}
+	samples = sampleVectors(samples, MaxTrainingSamples)
+
+	switch config.Quantization {
+	case QuantizationInt8:
+		return trainScalarQuantizer(config.Dimension, config.DistanceMetric, samples), nil
+	case QuantizationPQ:
+		segments := config.PQSegments
+		if segments == 0 {
+			segments = DefaultPQSegments(config.Dimension)
+		}
+		if segments < 1 || config.Dimension%segments != 0 {
+			return nil, fmt.Errorf("PQ segments (%d) must divide the vector dimension (%d)", segments, config.Dimension)
+		}
+		return trainProductQuantizer(config.Dimension, segments, config.DistanceMetric, samples), nil
+	default:
+		return nil, fmt.Errorf("cannot train quantization %s", config.Quantization)
+	}
+}
+
+// unmarshalQuantizer restores a quantizer written by marshal
+func unmarshalQuantizer(kind Quantization, metric types.DistanceMetric, data []byte) (quantizer, error) {
+	switch kind {
+	case QuantizationInt8:
+		return unmarshalScalarQuantizer(metric, data)
+	case QuantizationPQ:
+		return unmarshalProductQuantizer(metric, data)
+	default:
+		return nil, ErrCorruptedData
+	}
+}
+
+// sampleVectors returns at most n vectors spread evenly over vectors
+func sampleVectors(vectors []*types.Vector, n int) []*types.Vector {
+	if len(vectors) <= n {
+		return vectors
+	}
+	sample := make([]*types.Vector, n)
+	for i := range sample {
+		sample[i] = vectors[i*len(vectors)/n]
+	}
+	return sample
+}
+
+// accumulate adds the contribution of one dimension to a distance
+func accumulate(metric types.DistanceMetric, a, b float32) float32 {
+	switch metric {
+	case types.DistanceMetricEuclidean:
+		d := a - b
+		return d * d
+	case types.DistanceMetricManhattan:
+		return float32(math.Abs(float64(a - b)))
+	default:
+		return a * b
+	}
+}
+
+// finishDistance turns a sum of accumulate terms into the metric's distance,
+// matching types.Vector.Distance
+func finishDistance(metric types.DistanceMetric, sum float32) float32 {
+	switch metric {
+	case types.DistanceMetricEuclidean:
+		return float32(math.Sqrt(float64(sum)))
+	case types.DistanceMetricManhattan:
+		return sum
+	default:
+		return 1 - sum
+	}
+}
+
+// scalarQuantizer maps each dimension linearly onto 256 levels between the
+// minimum and maximum seen during training
+type scalarQuantizer struct {
+	metric types.DistanceMetric
+	min    []float32
+	scale  []float32
+}
+
+func trainScalarQuantizer(dimension int, metric types.DistanceMetric, samples []*types.Vector) *scalarQuantizer {
+	q := &scalarQuantizer{
+		metric: metric,
+		min:    make([]float32, dimension),
+		scale:  make([]float32, dimension),
+	}
+	max := make([]float32, dimension)
+	for i := range q.min {
+		q.min[i] = float32(math.Inf(1))
+		max[i] = float32(math.Inf(-1))
+	}
+	for _, v := range samples {
+		for i, x := range v.Data() {
+			if x < q.min[i] {
+				q.min[i] = x
+			}
+			if x > max[i] {
+				max[i] = x
+			}
+		}
+	}
+	for i := range q.scale {
+		q.scale[i] = (max[i] - q.min[i]) / 255
+	}
+	return q
+}
+
+func (q *scalarQuantizer) encode(v *types.Vector) []byte {
+	code := make([]byte, len(q.min))
+	for i, x := range v.Data() {
+		if q.scale[i] == 0 {
+			continue
+		}
+		level := math.Round(float64((x - q.min[i]) / q.scale[i]))
+		code[i] = byte(math.Max(0, math.Min(255, level)))
+	}
+	return code
+}
+
+func (q *scalarQuantizer) value(code []byte, i int) float32 {
+	return q.min[i] + float32(code[i])*q.scale[i]
+}
+
+func (q *scalarQuantizer) decode(code []byte) *types.Vector {
+	data := make([]float32, len(code))
+	for i := range code {
+		data[i] = q.value(code, i)
+	}
+	return types.NewVector(data)
+}
+
+func (q *scalarQuantizer) distance(query *types.Vector, code []byte) float32 {
+	var sum float32
+	for i, x := range query.Data() {
+		sum += accumulate(q.metric, x, q.value(code, i))
+	}
+	return finishDistance(q.metric, sum)
+}
+
+func (q *scalarQuantizer) codeDistance(a, b []byte) float32 {
+	var sum float32
+	for i := range a {
+		sum += accumulate(q.metric, q.value(a, i), q.value(b, i))
+	}
+	return finishDistance(q.metric, sum)
+}
+
+// marshal layout: [dimension u32][min f32 x dimension][scale f32 x dimension]
+func (q *scalarQuantizer) marshal() []byte {
+	data := make([]byte, 4+8*len(q.min))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(q.min)))
+	putFloats(data[4:], q.min)
+	putFloats(data[4+4*len(q.min):], q.scale)
+	return data
+}
+
+func unmarshalScalarQuantizer(metric types.DistanceMetric, data []byte) (*scalarQuantizer, error) {
+	if len(data) < 4 {
+		return nil, ErrCorruptedData
+	}
+	dimension := int(binary.LittleEndian.Uint32(data[0:4]))
+	if len(data) != 4+8*dimension {
+		return nil, ErrCorruptedData
+	}
+	return &scalarQuantizer{
+		metric: metric,
+		min:    getFloats(data[4:], dimension),
+		scale:  getFloats(data[4+4*dimension:], dimension),
+	}, nil
+}
+
+// productQuantizer splits vectors into equal subvectors and encodes each as
+// the nearest of up to 256 centroids trained for that subvector
+type productQuantizer struct {
+	metric    types.DistanceMetric
+	segments  int
+	subDim    int
+	centroids int
+	// codebook holds segments x centroids x subDim values
+	codebook []float32
+}
+
+func trainProductQuantizer(dimension, segments int, metric types.DistanceMetric, samples []*types.Vector) *productQuantizer {
+	q := &productQuantizer{
+		metric:    metric,
+		segments:  segments,
+		subDim:    dimension / segments,
+		centroids: pqCentroids,
+	}
+	if len(samples) < q.centroids {
+		q.centroids = len(samples)
+	}
+	q.codebook = make([]float32, segments*q.centroids*q.subDim)
+
+	assign := make([]int, len(samples))
+	sums := make([]float32, q.centroids*q.subDim)
+	counts := make([]int, q.centroids)
+	for s := 0; s < segments; s++ {
+		lo := s * q.subDim
+
+		// Seed centroids with samples spread over the training set
+		for c := 0; c < q.centroids; c++ {
+			copy(q.centroid(s, c), samples[c*len(samples)/q.centroids].Data()[lo:lo+q.subDim])
+		}
+
+		for iter := 0; iter < pqIterations; iter++ {
+			changed := false
+			for i, v := range samples {
+				c := q.nearest(s, v.Data()[lo:lo+q.subDim])
+				if iter == 0 || c != assign[i] {
+					assign[i] = c
+					changed = true
+				}
+			}
+			if !changed {
+				break
+			}
+
+			for i := range sums {
+				sums[i] = 0
+			}
+			for i := range counts {
+				counts[i] = 0
+			}
+			for i, v := range samples {
+				c := assign[i]
+				counts[c]++
+				for j, x := range v.Data()[lo : lo+q.subDim] {
+					sums[c*q.subDim+j] += x
+				}
+			}
+			// Empty clusters keep their previous centroid
+			for c := 0; c < q.centroids; c++ {
+				if counts[c] == 0 {
+					continue
+				}
+				centroid := q.centroid(s, c)
+				for j := range centroid {
+					centroid[j] = sums[c*q.subDim+j] / float32(counts[c])
+				}
+			}
+		}
+	}
+	return q
+}
+
+// centroid returns centroid c of segment s
+func (q *productQuantizer) centroid(s, c int) []float32 {
+	start := (s*q.centroids + c) * q.subDim
+	return q.codebook[start : start+q.subDim]
+}
+
+// nearest returns the centroid of segment s closest to sub in squared L2 distance
+func (q *productQuantizer) nearest(s int, sub []float32) int {
+	best, bestDist := 0, float32(math.Inf(1))
+	for c := 0; c < q.centroids; c++ {
+		var d float32
+		for j, x := range q.centroid(s, c) {
+			diff := sub[j] - x
+			d += diff * diff
+		}
+		if d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func (q *productQuantizer) encode(v *types.Vector) []byte {
+	data := v.Data()
+	code := make([]byte, q.segments)
+	for s := range code {
+		code[s] = byte(q.nearest(s, data[s*q.subDim:(s+1)*q.subDim]))
+	}
+	return code
+}
+
+func (q *productQuantizer) decode(code []byte) *types.Vector {
+	data := make([]float32, 0, q.segments*q.subDim)
+	for s, c := range code {
+		data = append(data, q.centroid(s, int(c))...)
+	}
+	return types.NewVector(data)
+}
+
+func (q *productQuantizer) distance(query *types.Vector, code []byte) float32 {
+	data := query.Data()
+	var sum float32
+	for s, c := range code {
+		sub := data[s*q.subDim:]
+		for j, x := range q.centroid(s, int(c)) {
+			sum += accumulate(q.metric, sub[j], x)
+		}
+	}
+	return finishDistance(q.metric, sum)
+}
+
+func (q *productQuantizer) codeDistance(a, b []byte) float32 {
+	var sum float32
+	for s := range a {
+		other := q.centroid(s, int(b[s]))
+		for j, x := range q.centroid(s, int(a[s])) {
+			sum += accumulate(q.metric, x, other[j])
+		}
+	}
+	return finishDistance(q.metric, sum)
+}
+
+// marshal layout: [segments u32][subDim u32][centroids u32][codebook f32...]
+func (q *productQuantizer) marshal() []byte {
+	data := make([]byte, 12+4*len(q.codebook))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(q.segments))
+	binary.LittleEndian.PutUint32(data[4:8], uint32(q.subDim))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(q.centroids))
+	putFloats(data[12:], q.codebook)
+	return data
+}
+
+func unmarshalProductQuantizer(metric types.DistanceMetric, data []byte) (*productQuantizer, error) {
+	if len(data) < 12 {
+		return nil, ErrCorruptedData
+	}
+	q := &productQuantizer{
+		metric:    metric,
+		segments:  int(binary.LittleEndian.Uint32(data[0:4])),
+		subDim:    int(binary.LittleEndian.Uint32(data[4:8])),
+		centroids: int(binary.LittleEndian.Uint32(data[8:12])),
+	}
+	n := q.segments * q.centroids * q.subDim
+	if len(data) != 12+4*n {
+		return nil, ErrCorruptedData
+	}
+	q.codebook = getFloats(data[12:], n)
+	return q, nil
+}
+
+func putFloats(dst []byte, values []float32) {
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(dst[4*i:], math.Float32bits(v))
+	}
+}
+
+func getFloats(src []byte, n int) []float32 {
+	values := make([]float32, n)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(src[4*i:]))
+	}
+	return values
+}
@@ -0,0 +1,311 @@
+// pkg/hnsw/quantize_test.go
+package hnsw
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"tur/pkg/pager"
+	"tur/pkg/types"
+)
+
+// clusteredVectors generates n vectors grouped around a few random centers
+func clusteredVectors(rng *rand.Rand, n, dim int) []*types.Vector {
+	centers := make([][]float32, 8)
+	for i := range centers {
+		centers[i] = make([]float32, dim)
+		for j := range centers[i] {
+			centers[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	vectors := make([]*types.Vector, n)
+	for i := range vectors {
+		data := make([]float32, dim)
+		for j, c := range centers[i%len(centers)] {
+			data[j] = c + float32(rng.NormFloat64()*0.1)
+		}
+		vectors[i] = types.NewVector(data)
+	}
+	return vectors
+}
+
+// exactNeighbors returns the rowIDs (index + 1) of the k vectors closest to query
+func exactNeighbors(vectors []*types.Vector, query *types.Vector, k int, metric types.DistanceMetric) []int64 {
+	ids := make([]int64, len(vectors))
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return query.Distance(vectors[ids[a]-1], metric) < query.Distance(vectors[ids[b]-1], metric)
+	})
+	return ids[:k]
+}
+
+// recallAt10 averages the fraction of true top-10 neighbors found over the queries
+func recallAt10(t *testing.T, idx VectorIndex, vectors, queries []*types.Vector, metric types.DistanceMetric) float64 {
+	t.Helper()
+	found := 0
+	for _, q := range queries {
+		results, err := idx.SearchKNNWithEf(q, 10, 100)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		truth := make(map[int64]bool)
+		for _, id := range exactNeighbors(vectors, q, 10, metric) {
+			truth[id] = true
+		}
+		for _, r := range results {
+			if truth[r.RowID] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(10*len(queries))
+}
+
+func buildQuantizedIndex(t *testing.T, p *pager.Pager, config Config, vectors []*types.Vector) *PersistentIndex {
+	t.Helper()
+	idx, err := CreatePersistent(p, config)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	if err := idx.Train(vectors); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	for i, v := range vectors {
+		if err := idx.Insert(int64(i+1), v); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	return idx
+}
+
+func TestParseQuantization(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Quantization
+	}{
+		{"none", QuantizationNone},
+		{"INT8", QuantizationInt8},
+		{"sq8", QuantizationInt8},
+		{"pq", QuantizationPQ},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuantization(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseQuantization(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseQuantization("fp16"); err == nil {
+		t.Error("expected error for unknown quantization")
+	}
+}
+
+func TestDefaultPQSegments(t *testing.T) {
+	for dim, want := range map[int]int{128: 16, 12: 3, 6: 3, 7: 7} {
+		if got := DefaultPQSegments(dim); got != want {
+			t.Errorf("DefaultPQSegments(%d) = %d, want %d", dim, got, want)
+		}
+	}
+}
+
+func TestQuantizedIndex_RequiresTraining(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	config := DefaultConfig(4)
+	config.Quantization = QuantizationInt8
+	idx, err := CreatePersistent(p, config)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	if idx.Trained() {
+		t.Error("new quantized index should not be trained")
+	}
+
+	vec := types.NewVector([]float32{1, 2, 3, 4})
+	if err := idx.Insert(1, vec); !errors.Is(err, ErrNotTrained) {
+		t.Fatalf("expected ErrNotTrained, got %v", err)
+	}
+	if err := idx.Train(nil); !errors.Is(err, ErrNoTrainingData) {
+		t.Fatalf("expected ErrNoTrainingData, got %v", err)
+	}
+	if err := idx.Train([]*types.Vector{vec}); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := idx.Insert(1, vec); err != nil {
+		t.Fatalf("insert after training failed: %v", err)
+	}
+	if err := idx.Train([]*types.Vector{vec}); !errors.Is(err, ErrAlreadyTrained) {
+		t.Fatalf("expected ErrAlreadyTrained, got %v", err)
+	}
+}
+
+func TestQuantizer_DistanceOnCodes(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := clusteredVectors(rng, 500, 16)
+	query := clusteredVectors(rng, 1, 16)[0]
+
+	tests := []struct {
+		quant    Quantization
+		metric   types.DistanceMetric
+		maxError float32
+	}{
+		{QuantizationInt8, types.DistanceMetricEuclidean, 0.02},
+		{QuantizationInt8, types.DistanceMetricManhattan, 0.05},
+		{QuantizationInt8, types.DistanceMetricCosine, 0.02},
+		{QuantizationPQ, types.DistanceMetricEuclidean, 0.3},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig(16)
+		config.Quantization = tt.quant
+		config.DistanceMetric = tt.metric
+		q, err := trainQuantizer(config, vectors)
+		if err != nil {
+			t.Fatalf("train %s failed: %v", tt.quant, err)
+		}
+
+		for i, v := range vectors[:50] {
+			code := q.encode(v)
+			want := query.Distance(v, tt.metric)
+			if got := q.distance(query, code); math.Abs(float64(got-want)) > float64(tt.maxError) {
+				t.Errorf("%s/%s vector %d: distance on code = %f, exact = %f", tt.quant, tt.metric, i, got, want)
+			}
+			// The distance on a code equals the distance to its reconstruction
+			decoded := query.Distance(q.decode(code), tt.metric)
+			if got := q.distance(query, code); math.Abs(float64(got-decoded)) > 1e-4 {
+				t.Errorf("%s/%s vector %d: distance on code = %f, to decoded vector = %f", tt.quant, tt.metric, i, got, decoded)
+			}
+		}
+
+		// Parameters survive serialization
+		restored, err := unmarshalQuantizer(tt.quant, tt.metric, q.marshal())
+		if err != nil {
+			t.Fatalf("unmarshal %s failed: %v", tt.quant, err)
+		}
+		code := q.encode(vectors[0])
+		if got, want := restored.distance(query, code), q.distance(query, code); got != want {
+			t.Errorf("%s: restored quantizer distance %f, want %f", tt.quant, got, want)
+		}
+	}
+}
+
+func TestQuantizedIndex_Recall(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := clusteredVectors(rng, 600, 32)
+	queries := clusteredVectors(rng, 20, 32)
+
+	tests := []struct {
+		name       string
+		quant      Quantization
+		metric     types.DistanceMetric
+		minRecall  float64
+		codeLength int
+	}{
+		{"int8_euclidean", QuantizationInt8, types.DistanceMetricEuclidean, 0.6, 32},
+		{"int8_manhattan", QuantizationInt8, types.DistanceMetricManhattan, 0.6, 32},
+		{"pq_euclidean", QuantizationPQ, types.DistanceMetricEuclidean, 0.4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+			if err != nil {
+				t.Fatalf("failed to open pager: %v", err)
+			}
+			defer p.Close()
+
+			config := DefaultConfig(32)
+			config.DistanceMetric = tt.metric
+			config.Quantization = tt.quant
+			idx := buildQuantizedIndex(t, p, config, vectors)
+
+			if recall := recallAt10(t, idx, vectors, queries, tt.metric); recall < tt.minRecall {
+				t.Errorf("recall@10 = %.2f, want >= %.2f", recall, tt.minRecall)
+			}
+
+			node := idx.getNode(idx.entryPoint)
+			if node.vector != nil || len(node.code) != tt.codeLength {
+				t.Errorf("expected only a %d byte code on the node, got vector=%v code=%d bytes",
+					tt.codeLength, node.vector != nil, len(node.code))
+			}
+		})
+	}
+}
+
+func TestQuantizedIndex_Reopen(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	vectors := clusteredVectors(rng, 300, 16)
+	query := clusteredVectors(rng, 1, 16)[0]
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	config := DefaultConfig(16)
+	config.DistanceMetric = types.DistanceMetricEuclidean
+	config.Quantization = QuantizationPQ
+	config.PQSegments = 8
+	config.Rerank = true
+	idx := buildQuantizedIndex(t, p, config, vectors)
+	before, err := idx.SearchKNN(query, 5)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	metaPage := idx.MetaPage()
+	if err := p.Close(); err != nil {
+		t.Fatalf("failed to close pager: %v", err)
+	}
+
+	p, err = pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to reopen pager: %v", err)
+	}
+	defer p.Close()
+	reopened, err := OpenPersistent(p, metaPage)
+	if err != nil {
+		t.Fatalf("failed to reopen index: %v", err)
+	}
+
+	got := reopened.Config()
+	if got.Quantization != QuantizationPQ || got.PQSegments != 8 || !got.Rerank {
+		t.Errorf("config not restored: %+v", got)
+	}
+	if !reopened.Trained() {
+		t.Error("reopened index should be trained")
+	}
+
+	after, err := reopened.SearchKNN(query, 5)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("expected %d results after reopen, got %d", len(before), len(after))
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Errorf("result %d: %+v before reopen, %+v after", i, before[i], after[i])
+		}
+	}
+
+	// Reconstructed vectors are close to the originals
+	approx := reopened.GetByRowID(1)
+	if approx == nil || approx.EuclideanDistance(vectors[0]) > 0.5 {
+		t.Errorf("GetByRowID(1) = %v, too far from %v", approx, vectors[0])
+	}
+
+	// Clearing keeps the quantizer so the index stays usable
+	if err := reopened.Clear(); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if err := reopened.Insert(1, vectors[0]); err != nil {
+		t.Fatalf("insert after clear failed: %v", err)
+	}
+}
@@ -69,3 +69,166 @@ func (idx *Index) SearchKNNWithEf(query *types.Vector, k int, ef int) ([]SearchR
 
 	return results, nil
 }
+
+// Filter reports whether a row may appear in the results of a filtered search.
+// Rows it rejects are still traversed so the graph stays navigable.
+type Filter func(rowID int64) bool
+
+const (
+	// filterSampleSize is the number of nodes checked to estimate how selective a filter is
+	filterSampleSize = 256
+
+	// bruteForceSelectivity is the estimated fraction of matching rows below which a
+	// filtered search scans the matching rows exactly instead of walking the graph
+	bruteForceSelectivity = 0.02
+)
+
+// SearchKNNFiltered finds the k nearest neighbors among the rows accepted by filter.
+// Non-matching nodes are used for navigation but never returned. When the filter
+// matches very few rows, or the graph walk cannot reach k matches, it falls back
+// to an exact scan of the matching rows. A nil filter behaves like SearchKNNWithEf.
+func (idx *Index) SearchKNNFiltered(query *types.Vector, k int, ef int, filter Filter) ([]SearchResult, error) {
+	if filter == nil {
+		return idx.SearchKNNWithEf(query, k, ef)
+	}
+	if query.Dimension() != idx.config.Dimension {
+		return nil, ErrDimensionMismatch
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.nodes) == 0 {
+		return []SearchResult{}, nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	// Estimate selectivity from a sample of nodes
+	sampled, matched := 0, 0
+	for _, node := range idx.nodes {
+		if sampled == filterSampleSize {
+			break
+		}
+		sampled++
+		if filter(node.RowID()) {
+			matched++
+		}
+	}
+	if float64(matched)/float64(sampled) < bruteForceSelectivity {
+		return idx.bruteForceSearch(query, k, filter), nil
+	}
+
+	ep := idx.entryPoint
+	for l := idx.maxLevel; l > 0; l-- {
+		ep = idx.searchLayerClosest(query, ep, l)
+	}
+
+	found := searchLayerFiltered(query, ep, ef, idx.queryDistance, idx.neighborsAt, filter)
+	if len(found) < k {
+		return idx.bruteForceSearch(query, k, filter), nil
+	}
+
+	return found[:k], nil
+}
+
+// bruteForceSearch computes the exact k nearest neighbors among the rows accepted by filter.
+func (idx *Index) bruteForceSearch(query *types.Vector, k int, filter Filter) []SearchResult {
+	results := []SearchResult{}
+	for _, node := range idx.nodes {
+		if !filter(node.RowID()) {
+			continue
+		}
+		results = insertTopK(results, SearchResult{RowID: node.RowID(), Distance: idx.distance(query, node.Vector())}, k)
+	}
+	return results
+}
+
+// queryDistance computes the distance from a query vector to a node.
+func (idx *Index) queryDistance(query *types.Vector, node *HNSWNode) float32 {
+	return idx.distance(query, node.Vector())
+}
+
+// neighborsAt returns a node and its neighbor list at the given level.
+func (idx *Index) neighborsAt(nodeID uint64, level int) (*HNSWNode, []uint64) {
+	node := idx.nodes[nodeID]
+	if node == nil {
+		return nil, nil
+	}
+	return node, node.Neighbors(level)
+}
+
+// searchLayerFiltered runs a layer search from ep that keeps only nodes accepted
+// by filter in its result set, while every reachable node remains a candidate for
+// expansion as long as it could lead to a better match. Returns up to ef matches
+// sorted by distance.
+func searchLayerFiltered(query *types.Vector, ep uint64, ef int,
+	distance func(query *types.Vector, node *HNSWNode) float32,
+	lookup func(nodeID uint64, level int) (*HNSWNode, []uint64),
+	filter Filter) []SearchResult {
+
+	epNode, _ := lookup(ep, 0)
+	if epNode == nil {
+		return nil
+	}
+
+	visited := map[uint64]bool{ep: true}
+	epDist := distance(query, epNode)
+	candidates := []distNode{{id: ep, dist: epDist}}
+	var results []SearchResult
+	if filter(epNode.RowID()) {
+		results = append(results, SearchResult{RowID: epNode.RowID(), Distance: epDist})
+	}
+
+	for len(candidates) > 0 {
+		closest := candidates[0]
+		candidates = candidates[1:]
+
+		if len(results) >= ef && closest.dist > results[len(results)-1].Distance {
+			break
+		}
+
+		_, neighbors := lookup(closest.id, 0)
+		for _, neighborID := range neighbors {
+			if visited[neighborID] {
+				continue
+			}
+			visited[neighborID] = true
+
+			neighborNode, _ := lookup(neighborID, 0)
+			if neighborNode == nil {
+				continue
+			}
+
+			dist := distance(query, neighborNode)
+			if len(results) >= ef && dist >= results[len(results)-1].Distance {
+				continue
+			}
+			candidates = insertSorted(candidates, distNode{id: neighborID, dist: dist})
+			if filter(neighborNode.RowID()) {
+				results = insertTopK(results, SearchResult{RowID: neighborNode.RowID(), Distance: dist}, ef)
+			}
+		}
+	}
+
+	return results
+}
+
+// insertTopK inserts r into results (sorted by distance, ascending), keeping at most k entries.
+func insertTopK(results []SearchResult, r SearchResult, k int) []SearchResult {
+	if len(results) >= k && r.Distance >= results[len(results)-1].Distance {
+		return results
+	}
+	i := 0
+	for i < len(results) && results[i].Distance <= r.Distance {
+		i++
+	}
+	results = append(results, SearchResult{})
+	copy(results[i+1:], results[i:])
+	results[i] = r
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -209,3 +209,74 @@ func TestConfig_DistanceMetricDefault(t *testing.T) {
 		t.Errorf("default DistanceMetric should be Cosine, got %v", config.DistanceMetric)
 	}
 }
+
+func TestSearchKNNFiltered(t *testing.T) {
+	config := DefaultConfig(2)
+	config.DistanceMetric = types.DistanceMetricEuclidean
+	idx := NewIndex(config)
+
+	// Points on a line: rowID i at (i, 0)
+	for i := 0; i < 500; i++ {
+		if err := idx.Insert(int64(i), types.NewVector([]float32{float32(i), 0})); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	// Only multiples of 3 are allowed; the nearest allowed to 100.4 are 99, 102, 96
+	query := types.NewVector([]float32{100.4, 0})
+	results, err := idx.SearchKNNFiltered(query, 3, 50, func(rowID int64) bool { return rowID%3 == 0 })
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	want := []int64{99, 102, 96}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, r := range results {
+		if r.RowID != want[i] {
+			t.Errorf("result %d: expected rowID %d, got %d", i, want[i], r.RowID)
+		}
+	}
+}
+
+func TestSearchKNNFiltered_LowSelectivity(t *testing.T) {
+	config := DefaultConfig(2)
+	config.DistanceMetric = types.DistanceMetricEuclidean
+	idx := NewIndex(config)
+
+	for i := 0; i < 500; i++ {
+		if err := idx.Insert(int64(i), types.NewVector([]float32{float32(i), 0})); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	// Only two far-away rows match, so the search falls back to an exact scan
+	allowed := map[int64]bool{3: true, 480: true}
+	query := types.NewVector([]float32{250, 0})
+	results, err := idx.SearchKNNFiltered(query, 5, 50, func(rowID int64) bool { return allowed[rowID] })
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].RowID != 480 || results[1].RowID != 3 {
+		t.Errorf("expected [480 3], got [%d %d]", results[0].RowID, results[1].RowID)
+	}
+}
+
+func TestSearchKNNFiltered_NilFilter(t *testing.T) {
+	idx := NewIndex(DefaultConfig(3))
+	idx.Insert(1, types.NewVector([]float32{1, 0, 0}))
+	idx.Insert(2, types.NewVector([]float32{0, 1, 0}))
+
+	results, err := idx.SearchKNNFiltered(types.NewVector([]float32{0, 1, 0}), 1, 10, nil)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].RowID != 2 {
+		t.Errorf("expected rowID 2, got %v", results)
+	}
+}
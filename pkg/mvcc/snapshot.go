@@ -0,0 +1,313 @@
+// pkg/mvcc/snapshot.go
+package mvcc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// frozenTxID is the creator of versions committed before every active
+// transaction began, which all transactions see. Transaction IDs start at 1.
+const frozenTxID = 0
+
+// WriteConflictError is returned when committing a transaction that wrote a
+// key also written by a transaction that committed after it began
+type WriteConflictError struct {
+	Space string
+	Key   []byte
+}
+
+// Error implements the error interface
+func (e *WriteConflictError) Error() string {
+	return fmt.Sprintf("%v on %s key %x", ErrWriteConflict, e.Space, e.Key)
+}
+
+// Unwrap lets errors.Is match ErrWriteConflict
+func (e *WriteConflictError) Unwrap() error {
+	return ErrWriteConflict
+}
+
+// SnapshotEntry is a key and the value a transaction sees for it
+type SnapshotEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// SnapshotChange is the last write of a transaction to a key
+type SnapshotChange struct {
+	Space   string
+	Key     []byte
+	Value   []byte
+	Deleted bool
+}
+
+// SnapshotStore keeps the versions of the keys written while transactions
+// are active, layered over trees that hold the latest committed value of
+// every key. Keys are grouped in spaces, one per tree.
+//
+// A key the store does not version has the same value for every active
+// transaction: the one in its tree. The first write to a key records that
+// value as a version all transactions see, below the version of the writer.
+// Versions that no active transaction needs any more are dropped by Collect.
+type SnapshotStore struct {
+	mu     sync.Mutex
+	mgr    *TransactionManager
+	chains map[string]map[string]*VersionChain
+	// writes lists, per transaction, the keys it wrote in each space; true
+	// if the write has not reached the tree yet
+	writes map[uint64]map[string]map[string]bool
+}
+
+// NewSnapshotStore creates a store for transactions of the given manager
+func NewSnapshotStore(mgr *TransactionManager) *SnapshotStore {
+	return &SnapshotStore{
+		mgr:    mgr,
+		chains: make(map[string]map[string]*VersionChain),
+		writes: make(map[uint64]map[string]map[string]bool),
+	}
+}
+
+// Tracked reports whether the store versions a key
+func (s *SnapshotStore) Tracked(space string, key []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chains[space][string(key)] != nil
+}
+
+// Track starts versioning a key whose latest committed value is value, or
+// which does not exist if exists is false. It does nothing if the key is
+// versioned already.
+func (s *SnapshotStore) Track(space string, key, value []byte, exists bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chains := s.chains[space]
+	if chains == nil {
+		chains = make(map[string]*VersionChain)
+		s.chains[space] = chains
+	}
+	if chains[string(key)] != nil {
+		return
+	}
+	chain := NewVersionChain(key)
+	if exists {
+		chain.AddVersion(NewRowVersion(value, frozenTxID))
+	}
+	chains[string(key)] = chain
+}
+
+// Put records a new value of a tracked key written by tx. pending is true if
+// the value is only in the store until the transaction commits.
+func (s *SnapshotStore) Put(tx *Transaction, space string, key, value []byte, pending bool) {
+	s.write(tx, space, key, NewRowVersion(value, tx.ID()), pending)
+}
+
+// Delete records the deletion of a tracked key by tx
+func (s *SnapshotStore) Delete(tx *Transaction, space string, key []byte, pending bool) {
+	s.write(tx, space, key, NewTombstone(tx.ID()), pending)
+}
+
+func (s *SnapshotStore) write(tx *Transaction, space string, key []byte, v *RowVersion, pending bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain := s.chains[space][string(key)]
+	if chain == nil {
+		panic("mvcc: write to untracked key")
+	}
+	chain.AddVersion(v)
+
+	spaces := s.writes[tx.ID()]
+	if spaces == nil {
+		spaces = make(map[string]map[string]bool)
+		s.writes[tx.ID()] = spaces
+	}
+	keys := spaces[space]
+	if keys == nil {
+		keys = make(map[string]bool)
+		spaces[space] = keys
+	}
+	keys[string(key)] = pending
+}
+
+// Lookup returns the value of a key visible to tx. ok is false if the store
+// does not version the key, which then has its tree value; deleted is true if
+// the key does not exist for tx.
+func (s *SnapshotStore) Lookup(tx *Transaction, space string, key []byte) (value []byte, deleted bool, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain := s.chains[space][string(key)]
+	if chain == nil {
+		return nil, false, false
+	}
+	v := s.visibleLocked(chain, tx)
+	if v == nil || v.IsTombstone() {
+		return nil, true, true
+	}
+	return v.Data(), false, true
+}
+
+// Scan returns the keys of a space the store versions, and, in key order,
+// the ones that exist for tx with their values
+func (s *SnapshotStore) Scan(tx *Transaction, space string) ([]SnapshotEntry, map[string]struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chains := s.chains[space]
+	tracked := make(map[string]struct{}, len(chains))
+	var entries []SnapshotEntry
+	for key, chain := range chains {
+		tracked[key] = struct{}{}
+		if v := s.visibleLocked(chain, tx); v != nil && !v.IsTombstone() {
+			entries = append(entries, SnapshotEntry{Key: []byte(key), Value: v.Data()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+	return entries, tracked
+}
+
+// visibleLocked returns the newest version of a chain visible to tx
+func (s *SnapshotStore) visibleLocked(chain *VersionChain, tx *Transaction) *RowVersion {
+	for v := chain.Head(); v != nil; v = v.Next() {
+		if v.CreatedBy() == frozenTxID || IsVersionVisible(v, tx, s.mgr) {
+			return v
+		}
+	}
+	return nil
+}
+
+// CheckConflicts returns a *WriteConflictError if a key written by tx was
+// also written by a transaction that committed after tx began. The first
+// transaction to commit wins; tx must be rolled back.
+func (s *SnapshotStore) CheckConflicts(tx *Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for space, keys := range s.writes[tx.ID()] {
+		for key := range keys {
+			for v := s.chains[space][key].Head(); v != nil; v = v.Next() {
+				if v.CreatedBy() == frozenTxID || v.CreatedBy() == tx.ID() {
+					continue
+				}
+				creator := s.mgr.GetTransaction(v.CreatedBy())
+				if creator != nil && creator.IsCommitted() && creator.CommitTS() > tx.StartTS() {
+					return &WriteConflictError{Space: space, Key: []byte(key)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Changes returns the writes of tx that have not reached the trees, the last
+// one for each key, ordered by space and key
+func (s *SnapshotStore) Changes(tx *Transaction) []SnapshotChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []SnapshotChange
+	for space, keys := range s.writes[tx.ID()] {
+		for key, pending := range keys {
+			if !pending {
+				continue
+			}
+			v := s.chains[space][key].FindVersionByCreator(tx.ID())
+			changes = append(changes, SnapshotChange{
+				Space:   space,
+				Key:     []byte(key),
+				Value:   v.Data(),
+				Deleted: v.IsTombstone(),
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Space != changes[j].Space {
+			return changes[i].Space < changes[j].Space
+		}
+		return bytes.Compare(changes[i].Key, changes[j].Key) < 0
+	})
+	return changes
+}
+
+// Discard removes the versions written by tx, which is rolled back
+func (s *SnapshotStore) Discard(tx *Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for space, keys := range s.writes[tx.ID()] {
+		for key := range keys {
+			chain := s.chains[space][key]
+			chain.mu.Lock()
+			var prev *RowVersion
+			for v := chain.head; v != nil; v = v.next {
+				if v.createdBy != tx.ID() {
+					prev = v
+				} else if prev == nil {
+					chain.head = v.next
+				} else {
+					prev.next = v.next
+				}
+			}
+			chain.mu.Unlock()
+		}
+	}
+	delete(s.writes, tx.ID())
+}
+
+// Collect drops the versions no active transaction can see and the keys whose
+// tree value all of them see again, then forgets the transactions that ended
+// before the oldest active one began. It returns the number of keys the store
+// still versions.
+func (s *SnapshotStore) Collect() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	minActiveTS := s.mgr.MinActiveTimestamp()
+	tracked := 0
+	for space, chains := range s.chains {
+		for key, chain := range chains {
+			if s.pruneLocked(chain, minActiveTS) {
+				delete(chains, key)
+			} else {
+				tracked++
+			}
+		}
+		if len(chains) == 0 {
+			delete(s.chains, space)
+		}
+	}
+	for txID := range s.writes {
+		if tx := s.mgr.GetTransaction(txID); tx == nil || !tx.IsActive() {
+			delete(s.writes, txID)
+		}
+	}
+	s.mgr.CleanupOldTransactions(minActiveTS)
+	return tracked
+}
+
+// pruneLocked freezes the newest version of a chain committed before every
+// active transaction began and drops the ones below it. It returns true if
+// the chain can go: no version is left above the frozen one, which then is
+// the tree value, or the chain is empty.
+func (s *SnapshotStore) pruneLocked(chain *VersionChain, minActiveTS uint64) bool {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	for v := chain.head; v != nil; v = v.next {
+		if v.createdBy != frozenTxID {
+			creator := s.mgr.GetTransaction(v.createdBy)
+			if creator == nil || !creator.IsCommitted() || creator.CommitTS() >= minActiveTS {
+				continue
+			}
+			v.createdBy = frozenTxID
+		}
+		v.next = nil
+		return v == chain.head
+	}
+	return chain.head == nil
+}
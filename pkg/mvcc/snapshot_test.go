@@ -0,0 +1,116 @@
+// pkg/mvcc/snapshot_test.go
+package mvcc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSnapshotStoreVisibility(t *testing.T) {
+	mgr := NewTransactionManager()
+	store := NewSnapshotStore(mgr)
+	key := []byte("k")
+
+	if _, _, ok := store.Lookup(mgr.Begin(), "t", key); ok {
+		t.Fatal("expected an untracked key to be read from the tree")
+	}
+
+	reader := mgr.Begin()
+	writer := mgr.Begin()
+	store.Track("t", key, []byte("old"), true)
+	store.Delete(writer, "t", key, true)
+
+	if _, deleted, ok := store.Lookup(writer, "t", key); !ok || !deleted {
+		t.Errorf("writer should see its deletion: deleted=%v ok=%v", deleted, ok)
+	}
+	if value, deleted, _ := store.Lookup(reader, "t", key); deleted || string(value) != "old" {
+		t.Errorf("reader should see the old value, got %q deleted=%v", value, deleted)
+	}
+
+	changes := store.Changes(writer)
+	if len(changes) != 1 || !changes[0].Deleted || changes[0].Space != "t" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if err := mgr.Commit(writer); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, _ := store.Lookup(reader, "t", key); string(value) != "old" {
+		t.Errorf("reader should keep its snapshot, got %q", value)
+	}
+	if _, deleted, _ := store.Lookup(mgr.Begin(), "t", key); !deleted {
+		t.Error("a later transaction should see the deletion")
+	}
+}
+
+func TestSnapshotStoreConflicts(t *testing.T) {
+	mgr := NewTransactionManager()
+	store := NewSnapshotStore(mgr)
+	key := []byte("k")
+
+	first := mgr.Begin()
+	second := mgr.Begin()
+	store.Track("t", key, nil, false)
+	store.Put(first, "t", key, []byte("first"), true)
+	store.Put(second, "t", key, []byte("second"), true)
+
+	// An uncommitted write does not conflict; the first commit wins
+	if err := store.CheckConflicts(first); err != nil {
+		t.Fatalf("unexpected conflict: %v", err)
+	}
+	mgr.Commit(first)
+	err := store.CheckConflicts(second)
+	var conflict *WriteConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected a WriteConflictError, got %v", err)
+	}
+	if conflict.Space != "t" || string(conflict.Key) != "k" {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+
+	// Transactions that begin after the commit do not conflict with it
+	third := mgr.Begin()
+	store.Put(third, "t", key, []byte("third"), true)
+	if err := store.CheckConflicts(third); err != nil {
+		t.Errorf("unexpected conflict: %v", err)
+	}
+}
+
+func TestSnapshotStoreCollect(t *testing.T) {
+	mgr := NewTransactionManager()
+	store := NewSnapshotStore(mgr)
+	key := []byte("k")
+
+	reader := mgr.Begin()
+	writer := mgr.Begin()
+	store.Track("t", key, []byte("v0"), true)
+	store.Put(writer, "t", key, []byte("v1"), true)
+	mgr.Commit(writer)
+
+	// The reader still needs the first value
+	if n := store.Collect(); n != 1 {
+		t.Fatalf("expected the key to stay versioned, %d keys", n)
+	}
+	if value, _, _ := store.Lookup(reader, "t", key); string(value) != "v0" {
+		t.Errorf("reader lost its version, got %q", value)
+	}
+
+	// A rolled back write leaves nothing behind
+	aborted := mgr.Begin()
+	store.Put(aborted, "t", key, []byte("v2"), true)
+	store.Discard(aborted)
+	mgr.Rollback(aborted)
+	if value, _, _ := store.Lookup(mgr.Begin(), "t", key); string(value) != "v1" {
+		t.Errorf("expected the committed value, got %q", value)
+	}
+
+	mgr.Rollback(reader)
+	for _, tx := range mgr.ActiveTransactions() {
+		mgr.Rollback(tx)
+	}
+	if n := store.Collect(); n != 0 {
+		t.Errorf("expected every version to be collected, %d keys left", n)
+	}
+	if n := len(mgr.transactions); n != 0 {
+		t.Errorf("expected the ended transactions to be forgotten, %d left", n)
+	}
+}
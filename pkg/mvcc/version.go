@@ -8,6 +8,7 @@ type RowVersion struct {
 	data      []byte      // The row data for this version
 	createdBy uint64      // Transaction ID that created this version
 	deletedBy uint64      // Transaction ID that deleted this version (0 = not deleted)
+	tombstone bool        // The version records the deletion of the row
 	next      *RowVersion // Pointer to the next (older) version
 }
 
@@ -25,6 +26,16 @@ func NewRowVersion(data []byte, createdBy uint64) *RowVersion {
 	}
 }
 
+// NewTombstone creates a version recording that the given transaction deleted
+// the row. Unlike MarkDeleted it is a version of its own, so transactions that
+// do not see it keep seeing the older versions.
+func NewTombstone(createdBy uint64) *RowVersion {
+	return &RowVersion{
+		createdBy: createdBy,
+		tombstone: true,
+	}
+}
+
 // Data returns a copy of the row data
 func (v *RowVersion) Data() []byte {
 	if v.data == nil {
@@ -60,6 +71,11 @@ func (v *RowVersion) IsDeleted() bool {
 	return v.deletedBy != 0
 }
 
+// IsTombstone returns true if this version records the deletion of the row
+func (v *RowVersion) IsTombstone() bool {
+	return v.tombstone
+}
+
 // MarkDeleted marks this version as deleted by the given transaction
 func (v *RowVersion) MarkDeleted(txID uint64) {
 	v.deletedBy = txID
@@ -0,0 +1,145 @@
+// pkg/pager/checkpoint.go
+package pager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultWALAutoCheckpoint is the number of WAL frames after which a commit
+// runs a passive checkpoint, as in SQLite
+const DefaultWALAutoCheckpoint = 1000
+
+// CheckpointMode selects how far a checkpoint goes. The modes follow SQLite's
+// wal_checkpoint pragma.
+type CheckpointMode int
+
+const (
+	// CheckpointPassive copies committed frames into the database file without
+	// waiting for anything, up to the oldest snapshot still being read. The
+	// WAL is started over by the next commit once all of it is copied.
+	CheckpointPassive CheckpointMode = iota
+	// CheckpointFull also requires that no write transaction is active and
+	// that every frame could be copied
+	CheckpointFull
+	// CheckpointRestart is CheckpointFull, then starts the WAL over at once
+	CheckpointRestart
+	// CheckpointTruncate is CheckpointRestart, then truncates the WAL file
+	CheckpointTruncate
+)
+
+// String returns the SQL name of the mode
+func (m CheckpointMode) String() string {
+	switch m {
+	case CheckpointPassive:
+		return "PASSIVE"
+	case CheckpointFull:
+		return "FULL"
+	case CheckpointRestart:
+		return "RESTART"
+	case CheckpointTruncate:
+		return "TRUNCATE"
+	default:
+		return fmt.Sprintf("CheckpointMode(%d)", int(m))
+	}
+}
+
+// ParseCheckpointMode parses PASSIVE, FULL, RESTART or TRUNCATE, in any case
+func ParseCheckpointMode(s string) (CheckpointMode, error) {
+	for m := CheckpointPassive; m <= CheckpointTruncate; m++ {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown checkpoint mode %q, expected PASSIVE, FULL, RESTART or TRUNCATE", s)
+}
+
+// CheckpointResult reports what a checkpoint did
+type CheckpointResult struct {
+	// Busy is set when the mode could not complete because a write
+	// transaction is active or a reader of another connection still needs
+	// frames that could therefore not be copied. The frames that could be were.
+	Busy bool
+	// FramesWritten is the number of frames copied into the database file
+	FramesWritten int
+	// FramesRemaining is the number of committed frames that are not in the
+	// database file yet
+	FramesRemaining int
+}
+
+// WALAutoCheckpoint returns the number of WAL frames after which a commit runs
+// a passive checkpoint; 0 means never
+func (p *Pager) WALAutoCheckpoint() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.walAutoCheckpoint
+}
+
+// SetWALAutoCheckpoint sets the number of WAL frames after which a commit runs
+// a passive checkpoint. n <= 0 turns automatic checkpoints off.
+func (p *Pager) SetWALAutoCheckpoint(n int) {
+	if n < 0 {
+		n = 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.walAutoCheckpoint = n
+}
+
+// Checkpoint copies the committed WAL frames into the database file and, for
+// the restart and truncate modes, empties the WAL. Until then, the pages
+// committed since the last checkpoint are read from the WAL. Databases
+// without a WAL have nothing to checkpoint.
+func (p *Pager) Checkpoint(mode CheckpointMode) (CheckpointResult, error) {
+	if mode < CheckpointPassive || mode > CheckpointTruncate {
+		return CheckpointResult{}, fmt.Errorf("unknown checkpoint mode %d", int(mode))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.readOnly {
+		return CheckpointResult{}, ErrReadOnly
+	}
+	return p.checkpointLocked(mode)
+}
+
+func (p *Pager) checkpointLocked(mode CheckpointMode) (CheckpointResult, error) {
+	var result CheckpointResult
+	if p.wal == nil {
+		return result, nil
+	}
+
+	// Frames are copied up to the oldest snapshot still being read: a
+	// newer version of a page would change it under that reader. An active
+	// write transaction has private copies of its pages and is not
+	// disturbed.
+	frames := p.wal.FrameCount()
+	limit := p.readLimitLocked()
+	if limit > p.walBackfilled {
+		if err := p.backfillLocked(limit); err != nil {
+			result.FramesRemaining = int(frames - p.walBackfilled)
+			return result, err
+		}
+		result.FramesWritten = int(limit - p.walBackfilled)
+		p.walBackfilled = limit
+	}
+	result.FramesRemaining = int(frames - p.walBackfilled)
+
+	if mode == CheckpointPassive {
+		return result, nil
+	}
+	if p.inTransaction || result.FramesRemaining > 0 {
+		result.Busy = true
+		return result, nil
+	}
+
+	if mode == CheckpointFull {
+		return result, nil
+	}
+	err := p.restartWALLocked(mode == CheckpointTruncate)
+	if err == ErrReadTxActive {
+		result.Busy = true
+		return result, nil
+	}
+	return result, err
+}
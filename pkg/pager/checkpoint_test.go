@@ -0,0 +1,202 @@
+// pkg/pager/checkpoint_test.go
+package pager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tur/pkg/wal"
+)
+
+// commitPage writes value into the first byte of page pageNo in a transaction
+// of its own, allocating the page if it does not exist yet
+func commitPage(t *testing.T, p *Pager, pageNo uint32, value byte) {
+	t.Helper()
+	tx, err := p.BeginWrite()
+	if err != nil {
+		t.Fatalf("BeginWrite failed: %v", err)
+	}
+	var page *Page
+	if pageNo < p.PageCount() {
+		page, err = p.Get(pageNo)
+	} else {
+		page, err = p.Allocate()
+	}
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("failed to get page %d: %v", pageNo, err)
+	}
+	page.Data()[0] = value
+	p.Release(page)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+}
+
+func TestPagerCheckpointModes(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	walPath := dbPath + "-wal"
+
+	p, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+	if p.WALAutoCheckpoint() != 0 {
+		t.Errorf("expected automatic checkpoints off, got %d", p.WALAutoCheckpoint())
+	}
+
+	for i := 1; i <= 3; i++ {
+		commitPage(t, p, uint32(i), byte(i))
+	}
+	frames := int(p.wal.FrameCount())
+	if frames == 0 {
+		t.Fatal("expected the commits to write WAL frames")
+	}
+
+	// Passive copies everything and leaves the WAL for the next commit to restart
+	result, err := p.Checkpoint(CheckpointPassive)
+	if err != nil {
+		t.Fatalf("passive checkpoint failed: %v", err)
+	}
+	if result.Busy || result.FramesWritten != frames || result.FramesRemaining != 0 {
+		t.Errorf("passive checkpoint: %+v, want %d frames written", result, frames)
+	}
+	if int(p.wal.FrameCount()) != frames {
+		t.Errorf("passive checkpoint changed the WAL to %d frames", p.wal.FrameCount())
+	}
+	if result, _ := p.Checkpoint(CheckpointPassive); result.FramesWritten != 0 {
+		t.Errorf("second checkpoint wrote %d frames again", result.FramesWritten)
+	}
+	commitPage(t, p, 1, 10)
+	if got := p.wal.FrameCount(); got == 0 || int(got) >= frames {
+		t.Errorf("expected the commit to restart the WAL, it has %d frames", got)
+	}
+
+	// Full and restart wait for the writer
+	tx, err := p.BeginWrite()
+	if err != nil {
+		t.Fatalf("BeginWrite failed: %v", err)
+	}
+	for _, mode := range []CheckpointMode{CheckpointFull, CheckpointRestart, CheckpointTruncate} {
+		result, err := p.Checkpoint(mode)
+		if err != nil || !result.Busy {
+			t.Errorf("%v checkpoint during a write transaction: %+v, %v", mode, result, err)
+		}
+	}
+	tx.Rollback()
+
+	// Restart empties the WAL but keeps the file; truncate shrinks it
+	commitPage(t, p, 2, 20)
+	if _, err := p.Checkpoint(CheckpointRestart); err != nil {
+		t.Fatalf("restart checkpoint failed: %v", err)
+	}
+	if p.wal.FrameCount() != 0 {
+		t.Errorf("expected an empty WAL after restart, %d frames", p.wal.FrameCount())
+	}
+	if info, err := os.Stat(walPath); err != nil || info.Size() <= wal.HeaderSize {
+		t.Errorf("expected restart to keep the WAL file: %v, %v", info, err)
+	}
+	commitPage(t, p, 3, 30)
+	if _, err := p.Checkpoint(CheckpointTruncate); err != nil {
+		t.Fatalf("truncate checkpoint failed: %v", err)
+	}
+	if info, err := os.Stat(walPath); err != nil || info.Size() != wal.HeaderSize {
+		t.Errorf("expected truncate to shrink the WAL file to its header: %v, %v", info, err)
+	}
+
+	// Everything is in the database file
+	p.Close()
+	p, err = Open(dbPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer p.Close()
+	for pageNo, want := range map[uint32]byte{1: 10, 2: 20, 3: 30} {
+		page, err := p.Get(pageNo)
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", pageNo, err)
+		}
+		if page.Data()[0] != want {
+			t.Errorf("page %d holds %d, want %d", pageNo, page.Data()[0], want)
+		}
+		p.Release(page)
+	}
+}
+
+func TestPagerCheckpointRestartedWALRecovers(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	p, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	for i := 1; i <= 5; i++ {
+		commitPage(t, p, uint32(i), byte(i))
+	}
+	if _, err := p.Checkpoint(CheckpointRestart); err != nil {
+		t.Fatalf("restart checkpoint failed: %v", err)
+	}
+
+	// The new transaction overwrites the first old frames; the rest no
+	// longer count
+	crashDir := t.TempDir()
+	crashPath := filepath.Join(crashDir, "crash.db")
+	copyFile(t, dbPath, crashPath)
+	commitPage(t, p, 1, 50)
+	copyFile(t, dbPath+"-wal", crashPath+"-wal")
+
+	cp, err := Open(crashPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open crash image: %v", err)
+	}
+	defer cp.Close()
+	for pageNo, want := range map[uint32]byte{1: 50, 2: 2, 5: 5} {
+		page, err := cp.Get(pageNo)
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", pageNo, err)
+		}
+		if page.Data()[0] != want {
+			t.Errorf("page %d holds %d after recovery, want %d", pageNo, page.Data()[0], want)
+		}
+		cp.Release(page)
+	}
+}
+
+func TestPagerAutoCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	p, err := Open(dbPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+	if p.WALAutoCheckpoint() != DefaultWALAutoCheckpoint {
+		t.Errorf("expected the default threshold, got %d", p.WALAutoCheckpoint())
+	}
+
+	const threshold = 8
+	p.SetWALAutoCheckpoint(threshold)
+	commitPage(t, p, 1, 0)
+	for i := 0; i < 100; i++ {
+		commitPage(t, p, 1, byte(i))
+		// Each commit writes at most the page and the header
+		if got := p.wal.FrameCount(); got > threshold+2 {
+			t.Fatalf("WAL grew to %d frames with a threshold of %d", got, threshold)
+		}
+	}
+
+	p.SetWALAutoCheckpoint(0)
+	for i := 0; i < 20; i++ {
+		commitPage(t, p, 1, byte(i))
+	}
+	if got := p.wal.FrameCount(); got < 20 {
+		t.Errorf("expected the WAL to grow without automatic checkpoints, %d frames", got)
+	}
+}
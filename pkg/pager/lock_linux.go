@@ -0,0 +1,49 @@
+//go:build linux
+
+// pkg/pager/lock_linux.go
+package pager
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockByte takes a shared or exclusive lock on one byte of f, replacing the
+// lock this connection already holds on it. Linux has open file description
+// locks, which belong to the open file rather than to the process, so two
+// connections of one process exclude each other like two processes do. If
+// wait is false and another connection holds a conflicting lock, it returns
+// errLockBusy.
+func lockByte(f *os.File, offset int64, exclusive, wait bool) error {
+	lock := unix.Flock_t{Type: unix.F_RDLCK, Whence: io.SeekStart, Start: offset, Len: 1}
+	if exclusive {
+		lock.Type = unix.F_WRLCK
+	}
+	cmd := unix.F_OFD_SETLK
+	if wait {
+		cmd = unix.F_OFD_SETLKW
+	}
+	for {
+		err := unix.FcntlFlock(f.Fd(), cmd, &lock)
+		switch err {
+		case unix.EINTR:
+			continue
+		case unix.EAGAIN, unix.EACCES:
+			return errLockBusy
+		}
+		return err
+	}
+}
+
+// unlockByte releases the lock on one byte of f
+func unlockByte(f *os.File, offset int64) error {
+	lock := unix.Flock_t{Type: unix.F_UNLCK, Whence: io.SeekStart, Start: offset, Len: 1}
+	return unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &lock)
+}
+
+// closeFile closes f, which releases its locks
+func closeFile(f *os.File) error {
+	return f.Close()
+}
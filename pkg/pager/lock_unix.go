@@ -0,0 +1,193 @@
+//go:build unix && !linux
+
+// pkg/pager/lock_unix.go
+package pager
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// POSIX record locks belong to the process: connections of one process do
+// not exclude each other, and closing any descriptor of a file releases every
+// lock the process holds on it. The locks of the connections of this process
+// are therefore tracked here, per file, and only what the process as a whole
+// holds is passed on to the system. A descriptor is closed once no other
+// connection of the process holds a lock on its file.
+
+// inode identifies a file whatever descriptor it is open with
+type inode struct {
+	dev, ino uint64
+}
+
+// inodeLocks holds the locks the connections of this process have on a file
+type inodeLocks struct {
+	// held maps each locked byte to the descriptors of the connections
+	// holding it, and whether they hold it exclusively
+	held map[int64]map[*os.File]bool
+	// pending lists descriptors closed while other connections still held
+	// locks, which closing them would have released
+	pending []*os.File
+}
+
+var (
+	inodeMu sync.Mutex
+	inodes  = make(map[inode]*inodeLocks)
+)
+
+// fileInode returns the file f is open on
+func fileInode(f *os.File) (inode, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(f.Fd()), &st); err != nil {
+		return inode{}, err
+	}
+	return inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+}
+
+// lockType returns the lock the process needs on a byte held by holders
+func lockType(holders map[*os.File]bool) int16 {
+	typ := int16(unix.F_UNLCK)
+	for _, exclusive := range holders {
+		if exclusive {
+			return unix.F_WRLCK
+		}
+		typ = unix.F_RDLCK
+	}
+	return typ
+}
+
+// setLock sets the lock of the process on one byte of f without waiting
+func setLock(f *os.File, offset int64, typ int16) error {
+	lock := unix.Flock_t{Type: typ, Whence: io.SeekStart, Start: offset, Len: 1}
+	for {
+		err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock)
+		switch err {
+		case unix.EINTR:
+			continue
+		case unix.EAGAIN, unix.EACCES:
+			return errLockBusy
+		}
+		return err
+	}
+}
+
+// lockByte takes a shared or exclusive lock on one byte of f, replacing the
+// lock this connection already holds on it. If wait is false and another
+// connection, of this process or another, holds a conflicting lock, it
+// returns errLockBusy.
+func lockByte(f *os.File, offset int64, exclusive, wait bool) error {
+	for {
+		err := tryLockByte(f, offset, exclusive)
+		if err != errLockBusy || !wait {
+			return err
+		}
+		// Waiting in the system would block the connections of this process
+		// that are to release the lock
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func tryLockByte(f *os.File, offset int64, exclusive bool) error {
+	key, err := fileInode(f)
+	if err != nil {
+		return err
+	}
+	inodeMu.Lock()
+	defer inodeMu.Unlock()
+
+	locks := inodes[key]
+	if locks == nil {
+		locks = &inodeLocks{held: make(map[int64]map[*os.File]bool)}
+	}
+	holders := locks.held[offset]
+	for other, otherExclusive := range holders {
+		if other != f && (exclusive || otherExclusive) {
+			return errLockBusy
+		}
+	}
+
+	before := lockType(holders)
+	updated := make(map[*os.File]bool, len(holders)+1)
+	for holder, holderExclusive := range holders {
+		updated[holder] = holderExclusive
+	}
+	updated[f] = exclusive
+	if after := lockType(updated); after != before {
+		if err := setLock(f, offset, after); err != nil {
+			return err
+		}
+	}
+	locks.held[offset] = updated
+	inodes[key] = locks
+	return nil
+}
+
+// unlockByte releases the lock on one byte of f
+func unlockByte(f *os.File, offset int64) error {
+	key, err := fileInode(f)
+	if err != nil {
+		return err
+	}
+	inodeMu.Lock()
+	defer inodeMu.Unlock()
+
+	if locks := inodes[key]; locks != nil {
+		return locks.release(f, offset)
+	}
+	return nil
+}
+
+// release drops the lock of f on one byte, passing on to the system what the
+// other connections still hold. The caller holds inodeMu.
+func (l *inodeLocks) release(f *os.File, offset int64) error {
+	holders := l.held[offset]
+	if _, ok := holders[f]; !ok {
+		return nil
+	}
+	before := lockType(holders)
+	delete(holders, f)
+	if len(holders) == 0 {
+		delete(l.held, offset)
+	}
+	if after := lockType(holders); after != before {
+		return setLock(f, offset, after)
+	}
+	return nil
+}
+
+// closeFile releases the locks of f and closes it, unless other connections
+// of this process still hold locks on the file. It is then closed together
+// with the descriptor of the last of them.
+func closeFile(f *os.File) error {
+	key, err := fileInode(f)
+	if err != nil {
+		return f.Close()
+	}
+	inodeMu.Lock()
+	defer inodeMu.Unlock()
+
+	locks := inodes[key]
+	if locks == nil {
+		return f.Close()
+	}
+	for offset := range locks.held {
+		_ = locks.release(f, offset)
+	}
+	if len(locks.held) > 0 {
+		locks.pending = append(locks.pending, f)
+		return nil
+	}
+
+	delete(inodes, key)
+	err = f.Close()
+	for _, pending := range locks.pending {
+		if closeErr := pending.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+//go:build windows
+
+// pkg/pager/lock_windows.go
+package pager
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockByte takes a shared or exclusive lock on one byte of f, replacing the
+// lock this connection already holds on it. Windows locks belong to the file
+// handle, so each connection has its own. They cannot be converted in place:
+// the old lock is released first, and if the new one cannot be had the shared
+// lock is taken back. If wait is false and another connection holds a
+// conflicting lock, it returns errLockBusy.
+func lockByte(f *os.File, offset int64, exclusive, wait bool) error {
+	converted := unlockByte(f, offset) == nil
+	err := lockFileEx(f, offset, exclusive, wait)
+	if err == errLockBusy && converted && exclusive {
+		_ = lockFileEx(f, offset, false, true)
+	}
+	return err
+}
+
+func lockFileEx(f *os.File, offset int64, exclusive, wait bool) error {
+	var flags uint32
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !wait {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	overlapped := windows.Overlapped{Offset: uint32(offset), OffsetHigh: uint32(offset >> 32)}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) || errors.Is(err, windows.ERROR_IO_PENDING) {
+		return errLockBusy
+	}
+	return err
+}
+
+// unlockByte releases the lock on one byte of f
+func unlockByte(f *os.File, offset int64) error {
+	overlapped := windows.Overlapped{Offset: uint32(offset), OffsetHigh: uint32(offset >> 32)}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &overlapped)
+}
@@ -7,6 +7,9 @@ type MmapFile struct {
 	file interface{} // *os.File on Unix, windows.Handle on Windows
 	data []byte
 	size int64
+	// readOnly is set for a file mapped by OpenMmapFileReadOnly, which is
+	// never written or resized
+	readOnly bool
 }
 
 // Size returns the current file size
@@ -58,8 +58,71 @@ func OpenMmapFile(path string, initialSize int64) (*MmapFile, error) {
 	}, nil
 }
 
+// OpenMmapFileReadOnly maps an existing file for reading, for a connection
+// that reads a database another one writes. The file must not be empty.
+func OpenMmapFileReadOnly(path string) (*MmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &MmapFile{file: f, readOnly: true}
+	if err := m.Remap(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if m.size == 0 {
+		f.Close()
+		return nil, errors.New("cannot mmap empty file")
+	}
+	return m, nil
+}
+
+// Remap maps the file at its current size, once another connection has grown
+// it. The file itself is not resized.
+func (m *MmapFile) Remap() error {
+	f := m.file.(*os.File)
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size == m.size {
+		return nil
+	}
+
+	if m.data != nil {
+		if err := syscall.Munmap(m.data); err != nil {
+			return err
+		}
+		m.data = nil
+		m.size = 0
+	}
+	if size == 0 {
+		return nil
+	}
+	prot := syscall.PROT_READ | syscall.PROT_WRITE
+	if m.readOnly {
+		prot = syscall.PROT_READ
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), prot, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	m.data = data
+	m.size = size
+	return nil
+}
+
+// osFile returns the mapped file
+func (m *MmapFile) osFile() *os.File {
+	return m.file.(*os.File)
+}
+
 // Sync flushes changes to disk
 func (m *MmapFile) Sync() error {
+	if m.readOnly {
+		return nil
+	}
 	return unix.Msync(m.data, unix.MS_SYNC)
 }
 
@@ -68,6 +131,22 @@ func (m *MmapFile) Grow(newSize int64) error {
 	if newSize <= m.size {
 		return nil
 	}
+	return m.resize(newSize)
+}
+
+// Truncate shrinks the file to newSize bytes and remaps it
+func (m *MmapFile) Truncate(newSize int64) error {
+	if newSize <= 0 || newSize >= m.size {
+		return nil
+	}
+	return m.resize(newSize)
+}
+
+// resize changes the file size and remaps it
+func (m *MmapFile) resize(newSize int64) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
 
 	// CRITICAL: Sync dirty pages to disk before unmapping.
 	// With MAP_SHARED, writes go to the kernel page cache but may not be
@@ -85,7 +164,7 @@ func (m *MmapFile) Grow(newSize int64) error {
 	// Get the file handle
 	f := m.file.(*os.File)
 
-	// Extend file
+	// Extend or shrink file
 	if err := f.Truncate(newSize); err != nil {
 		return err
 	}
@@ -115,7 +194,7 @@ func (m *MmapFile) Close() error {
 
 	if m.file != nil {
 		f := m.file.(*os.File)
-		if err := f.Close(); err != nil && firstErr == nil {
+		if err := closeFile(f); err != nil && firstErr == nil {
 			firstErr = err
 		}
 		m.file = nil
@@ -98,9 +98,97 @@ func OpenMmapFile(path string, initialSize int64) (*MmapFile, error) {
 	}, nil
 }
 
+// OpenMmapFileReadOnly maps an existing file for reading, for a connection
+// that reads a database another one writes. The file must not be empty.
+func OpenMmapFileReadOnly(path string) (*MmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &MmapFile{file: &mmapHandle{file: f}, readOnly: true}
+	if err := m.Remap(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if m.size == 0 {
+		f.Close()
+		return nil, errors.New("cannot mmap empty file")
+	}
+	return m, nil
+}
+
+// Remap maps the file at its current size, once another connection has grown
+// it. The file itself is not resized.
+func (m *MmapFile) Remap() error {
+	handle := m.file.(*mmapHandle)
+	stat, err := handle.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size == m.size {
+		return nil
+	}
+
+	if len(m.data) > 0 {
+		if err := windows.UnmapViewOfFile(uintptr(unsafe.Pointer(&m.data[0]))); err != nil {
+			return err
+		}
+		m.data = nil
+		m.size = 0
+	}
+	if handle.mapHandle != 0 {
+		if err := windows.CloseHandle(handle.mapHandle); err != nil {
+			return err
+		}
+		handle.mapHandle = 0
+	}
+	if size == 0 {
+		return nil
+	}
+
+	protect, access := uint32(windows.PAGE_READWRITE), uint32(windows.FILE_MAP_READ|windows.FILE_MAP_WRITE)
+	if m.readOnly {
+		protect, access = windows.PAGE_READONLY, windows.FILE_MAP_READ
+	}
+	mapHandle, err := windows.CreateFileMapping(
+		windows.Handle(handle.file.Fd()),
+		nil,
+		protect,
+		uint32(size>>32),
+		uint32(size&0xFFFFFFFF),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	addr, err := windows.MapViewOfFile(mapHandle, access, 0, 0, uintptr(size))
+	if err != nil {
+		windows.CloseHandle(mapHandle)
+		return err
+	}
+
+	var data []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	header.Data = addr
+	header.Len = int(size)
+	header.Cap = int(size)
+
+	handle.mapHandle = mapHandle
+	handle.mappedSize = size
+	m.data = data
+	m.size = size
+	return nil
+}
+
+// osFile returns the mapped file
+func (m *MmapFile) osFile() *os.File {
+	return m.file.(*mmapHandle).file
+}
+
 // Sync flushes changes to disk
 func (m *MmapFile) Sync() error {
-	if len(m.data) == 0 {
+	if m.readOnly || len(m.data) == 0 {
 		return nil
 	}
 	return windows.FlushViewOfFile(uintptr(unsafe.Pointer(&m.data[0])), uintptr(len(m.data)))
@@ -111,7 +199,22 @@ func (m *MmapFile) Grow(newSize int64) error {
 	if newSize <= m.size {
 		return nil
 	}
+	return m.resize(newSize)
+}
+
+// Truncate shrinks the file to newSize bytes and remaps it
+func (m *MmapFile) Truncate(newSize int64) error {
+	if newSize <= 0 || newSize >= m.size {
+		return nil
+	}
+	return m.resize(newSize)
+}
 
+// resize changes the file size and remaps it
+func (m *MmapFile) resize(newSize int64) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
 	handle := m.file.(*mmapHandle)
 
 	// Flush current mapping
@@ -133,7 +236,7 @@ func (m *MmapFile) Grow(newSize int64) error {
 		return err
 	}
 
-	// Extend file
+	// Extend or shrink file
 	if err := handle.file.Truncate(newSize); err != nil {
 		return err
 	}
@@ -2,10 +2,12 @@
 package pager
 
 import (
+	"bytes"
 	"container/list"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"tur/pkg/cache"
@@ -17,6 +19,19 @@ const (
 	headerSize      = 100
 	magicString     = "TurDB format 1\x00"
 	defaultPageSize = 4096
+
+	// offsetAutoVacuum holds the auto-vacuum mode (4 bytes), at the offset the
+	// dbfile header layout reserves for the incremental vacuum flag
+	offsetAutoVacuum = 64
+
+	// offsetFormatVersion holds the file format version (4 bytes), in the
+	// area the dbfile header layout reserves for expansion
+	offsetFormatVersion = 72
+
+	// formatVersion is the page format written by this version. Version 2
+	// lets B-tree keys spill onto overflow pages and version 3 ends index
+	// keys with a fixed-width rowid; older files are rejected.
+	formatVersion = 3
 )
 
 var (
@@ -24,13 +39,35 @@ var (
 	ErrPageNotFound    = errors.New("page not found")
 	ErrNoTransaction   = errors.New("no active transaction")
 	ErrTxAlreadyActive = errors.New("transaction already active")
+
+	// ErrUnsupportedFormat is returned when opening a database written in
+	// another file format version
+	ErrUnsupportedFormat = errors.New("unsupported database file format version")
+
+	// ErrReadOnly is returned by writes to a pager opened read-only
+	ErrReadOnly = errors.New("database is read-only")
+	// ErrDatabaseLocked is returned when opening a second read-write
+	// connection to a database file
+	ErrDatabaseLocked = errors.New("database is locked by another connection")
+	// ErrBusy is returned when an operation needs the database to itself
+	// while other connections use it, or when every read slot is taken
+	ErrBusy = errors.New("database is in use by another connection")
 )
 
 // Options configures the pager
 type Options struct {
-	PageSize  int  // Page size in bytes (default 4096)
-	CacheSize int  // Number of pages to cache (default 1000)
-	ReadOnly  bool // Open in read-only mode
+	PageSize  int // Page size in bytes (default 4096)
+	CacheSize int // Number of pages to cache (default 1000)
+
+	// ReadOnly opens a connection that only reads the database, alongside
+	// the read-write connection of another process, or of this one, that may
+	// be writing it. The database file must exist.
+	ReadOnly bool
+
+	// WALAutoCheckpoint is the number of WAL frames after which a commit
+	// runs a passive checkpoint (default DefaultWALAutoCheckpoint, negative
+	// turns it off)
+	WALAutoCheckpoint int
 }
 
 // cacheEntry holds a page and its LRU list element
@@ -54,16 +91,45 @@ type Pager struct {
 	// WAL support (nil for in-memory databases)
 	wal           *wal.WAL
 	inTransaction bool
-	dirtyPages    map[uint32][]byte // Page number -> original data (for rollback)
+	// txPages holds every page the active write transaction has touched,
+	// each backed by a private copy of its data; txOrig the committed data
+	// of those that were read from the WAL. Commit appends the pages to the
+	// WAL and leaves the storage alone.
+	txPages map[uint32]*Page
+	txOrig  map[uint32][]byte
+	// walIndex locates the committed pages in the WAL, which are read from
+	// there until a checkpoint copies them into the storage
+	walIndex walIndex
+	// walBackfilled is the number of WAL frames the synced database file
+	// already holds; walAutoCheckpoint the frame count that triggers a
+	// checkpoint on commit (0 for never)
+	walBackfilled     uint32
+	walAutoCheckpoint int
 
 	// Freelist support
 	freelist *Freelist
 
+	// autoVacuum is the auto-vacuum mode stored in the header
+	autoVacuum AutoVacuumMode
+
 	// Memory budget tracking
 	memoryBudget *cache.MemoryBudget
 
 	// inMemory indicates this is an in-memory database (no WAL, no persistence)
 	inMemory bool
+
+	// shm is the WAL index shared with the other connections to the database
+	// file (nil for in-memory databases), and shmStale is set when the
+	// writer has to publish all of its own index again
+	shm      *walShm
+	shmStale bool
+	// readOnly is set for a connection that reads what another one writes.
+	// snapshots counts its nested BeginSnapshot calls, and shmGeneration is
+	// the generation of the shared index its WAL index was built from.
+	readOnly      bool
+	snapshots     int
+	shmGeneration uint32
+	shmLoaded     bool
 }
 
 // Transaction represents an active write transaction
@@ -88,11 +154,32 @@ func OpenWithBudget(path string, opts Options, budget *cache.MemoryBudget) (*Pag
 		cacheSize = 1000
 	}
 
+	autoCheckpoint := opts.WALAutoCheckpoint
+	if autoCheckpoint == 0 {
+		autoCheckpoint = DefaultWALAutoCheckpoint
+	} else if autoCheckpoint < 0 {
+		autoCheckpoint = 0
+	}
+
+	if opts.ReadOnly {
+		return openReadOnly(path, opts, budget)
+	}
+
 	// Try to open existing file first
 	mf, err := OpenMmapFile(path, int64(pageSize))
 	if err != nil {
 		return nil, err
 	}
+	shm, err := openShm(path)
+	if err != nil {
+		mf.Close()
+		return nil, err
+	}
+	if err := shm.joinWriter(); err != nil {
+		shm.close()
+		mf.Close()
+		return nil, err
+	}
 
 	p := &Pager{
 		storage:      mf, // MmapFile implements Storage interface
@@ -102,10 +189,12 @@ func OpenWithBudget(path string, opts Options, budget *cache.MemoryBudget) (*Pag
 		cache:        make(map[uint32]*cacheEntry),
 		lru:          list.New(),
 		cacheSize:    cacheSize,
-		dirtyPages:   make(map[uint32][]byte),
 		freelist:     NewFreelist(pageSize),
 		memoryBudget: budget,
 		inMemory:     false,
+		shm:          shm,
+
+		walAutoCheckpoint: autoCheckpoint,
 	}
 
 	// Register with memory budget if provided
@@ -113,46 +202,65 @@ func OpenWithBudget(path string, opts Options, budget *cache.MemoryBudget) (*Pag
 		budget.RegisterComponent("page_cache")
 	}
 
-	// Check if this is a new file or existing database
-	header := mf.Slice(0, headerSize)
-	if string(header[0:len(magicString)]) == magicString {
-		// Existing database - read header
-		p.pageSize = int(binary.LittleEndian.Uint32(header[16:20]))
-		p.pageCount = binary.LittleEndian.Uint32(header[20:24])
-
-		// Load freelist from header
-		freelistHead := GetFreelistHead(header)
-		freePageCount := GetFreePageCount(header)
-		p.loadFreelist(freelistHead, freePageCount)
-	} else {
-		// New database - initialize header
-		p.pageCount = 1 // Header page is page 0
-		p.writeHeader()
-	}
-
 	// Open or create WAL file
 	walPath := path + "-wal"
 	w, err := wal.Open(walPath, wal.Options{PageSize: pageSize})
 	if err != nil {
+		shm.close()
 		mf.Close()
 		return nil, err
 	}
 
-	// If WAL has frames, recover them
-	if w.FrameCount() > 0 {
-		_, err = w.Recover(path)
-		if err != nil {
+	// Committed transactions in the WAL stay there: the header and every
+	// other page they changed are read from their frames
+	p.wal = w
+	header, err := p.loadHeaderLocked()
+	if err != nil {
+		w.Close()
+		shm.close()
+		mf.Close()
+		return nil, err
+	}
+
+	// Check if this is a new file or existing database
+	if string(header[0:len(magicString)]) == magicString {
+		// Existing database - read header
+		if err := checkFormatVersion(header); err != nil {
+			w.Close()
+			shm.close()
+			mf.Close()
+			return nil, err
+		}
+		p.pageSize = int(binary.LittleEndian.Uint32(header[16:20]))
+		if err := p.readHeaderLocked(); err != nil {
 			w.Close()
+			shm.close()
 			mf.Close()
 			return nil, err
 		}
+	} else {
+		// New database - initialize header
+		p.pageCount = 1 // Header page is page 0
+		p.writeHeader()
 	}
 
-	p.wal = w
+	// Readers connected to an earlier writer start over from this one's
+	// WAL index
+	p.shmStale = true
+	p.publishLocked(0, nil)
 
 	return p, nil
 }
 
+// loadHeaderLocked builds the WAL index and returns the latest committed
+// header page
+func (p *Pager) loadHeaderLocked() ([]byte, error) {
+	if err := p.loadWALIndexLocked(); err != nil {
+		return nil, err
+	}
+	return p.committedDataLocked(0)
+}
+
 // OpenWithStorage creates a pager using a custom storage backend.
 // This is used for in-memory databases where no disk I/O is needed.
 // For in-memory storage, no WAL is created since persistence is not required.
@@ -189,7 +297,6 @@ func OpenWithStorageAndBudget(storage Storage, opts Options, budget *cache.Memor
 		cache:        make(map[uint32]*cacheEntry),
 		lru:          list.New(),
 		cacheSize:    cacheSize,
-		dirtyPages:   make(map[uint32][]byte),
 		freelist:     NewFreelist(pageSize),
 		memoryBudget: budget,
 		inMemory:     isMemory,
@@ -204,8 +311,12 @@ func OpenWithStorageAndBudget(storage Storage, opts Options, budget *cache.Memor
 	header := storage.Slice(0, headerSize)
 	if header != nil && string(header[0:len(magicString)]) == magicString {
 		// Existing database - read header
+		if err := checkFormatVersion(header); err != nil {
+			return nil, err
+		}
 		p.pageSize = int(binary.LittleEndian.Uint32(header[16:20]))
 		p.pageCount = binary.LittleEndian.Uint32(header[20:24])
+		p.autoVacuum = AutoVacuumMode(binary.LittleEndian.Uint32(header[offsetAutoVacuum:]))
 
 		// Load freelist from header
 		freelistHead := GetFreelistHead(header)
@@ -217,11 +328,21 @@ func OpenWithStorageAndBudget(storage Storage, opts Options, budget *cache.Memor
 		p.writeHeaderToStorage()
 	}
 
-	// No WAL for in-memory databases - transactions use dirtyPages for rollback
+	// No WAL for in-memory databases - transactions keep private page copies
+	// until commit, like file databases, but skip the WAL
 
 	return p, nil
 }
 
+// checkFormatVersion rejects a database header written in another file
+// format version
+func checkFormatVersion(header []byte) error {
+	if version := binary.LittleEndian.Uint32(header[offsetFormatVersion:]); version != formatVersion {
+		return fmt.Errorf("%w %d (expected %d)", ErrUnsupportedFormat, version, formatVersion)
+	}
+	return nil
+}
+
 // HasWAL returns true if the pager has an active WAL.
 // In-memory databases do not use WAL.
 func (p *Pager) HasWAL() bool {
@@ -246,6 +367,8 @@ func (p *Pager) writeHeaderToStorage() {
 	copy(header[0:16], magicString)
 	binary.LittleEndian.PutUint32(header[16:20], uint32(p.pageSize))
 	binary.LittleEndian.PutUint32(header[20:24], p.pageCount)
+	binary.LittleEndian.PutUint32(header[offsetAutoVacuum:], uint32(p.autoVacuum))
+	binary.LittleEndian.PutUint32(header[offsetFormatVersion:], formatVersion)
 
 	// Write freelist info to header
 	if p.freelist != nil {
@@ -288,17 +411,15 @@ func (p *Pager) getStorage() Storage {
 
 // writeHeader writes the database header to page 0
 func (p *Pager) writeHeader() {
-	storage := p.getStorage()
-	if storage == nil {
-		return
-	}
-	header := storage.Slice(0, headerSize)
+	header := p.pageData(0)
 	if header == nil {
 		return
 	}
 	copy(header[0:16], magicString)
 	binary.LittleEndian.PutUint32(header[16:20], uint32(p.pageSize))
 	binary.LittleEndian.PutUint32(header[20:24], p.pageCount)
+	binary.LittleEndian.PutUint32(header[offsetAutoVacuum:], uint32(p.autoVacuum))
+	binary.LittleEndian.PutUint32(header[offsetFormatVersion:], formatVersion)
 
 	// Write freelist info to header
 	if p.freelist != nil {
@@ -307,6 +428,11 @@ func (p *Pager) writeHeader() {
 	}
 }
 
+// Path returns the database file path (empty for in-memory databases)
+func (p *Pager) Path() string {
+	return p.path
+}
+
 // PageSize returns the page size
 func (p *Pager) PageSize() int {
 	return p.pageSize
@@ -324,6 +450,10 @@ func (p *Pager) Allocate() (*Page, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if err := p.beginUntrackedLocked(); err != nil {
+		return nil, err
+	}
+
 	var pageNo uint32
 
 	// Try to allocate from freelist first
@@ -356,26 +486,32 @@ func (p *Pager) Allocate() (*Page, error) {
 			return nil, err
 		}
 		// After grow, cached page data slices point to unmapped memory (for mmap)
+		// or to the old buffer (for in-memory storage, which reallocates)
 		// CRITICAL: We must update all cached pages with new data slices
-		// to prevent SIGSEGV when accessing pinned pages
-		if !p.inMemory {
-			p.refreshCacheAfterGrow()
-		}
+		// to prevent SIGSEGV when accessing pinned pages and lost writes
+		p.refreshCacheAfterGrow()
 	}
 
 	// Update header with new page count
 	p.writeHeader()
 
-	// Create page backed by storage
-	offset := int(pageNo) * p.pageSize
-	data := storage.Slice(offset, p.pageSize)
-	page := NewPageWithData(pageNo, data)
-	page.Pin()
+	var page *Page
+	if p.inTransaction {
+		// Create page backed by a private buffer until the transaction commits
+		page = NewPage(pageNo, p.pageSize)
+		p.txPages[pageNo] = page
+	} else {
+		// Create page backed by storage
+		offset := int(pageNo) * p.pageSize
+		data := storage.Slice(offset, p.pageSize)
+		page = NewPageWithData(pageNo, data)
 
-	// Clear the page data (newly allocated pages should be zeroed)
-	for i := range data {
-		data[i] = 0
+		// Clear the page data (newly allocated pages should be zeroed)
+		for i := range data {
+			data[i] = 0
+		}
 	}
+	page.Pin()
 
 	// Add to cache with LRU tracking
 	elem := p.lru.PushFront(pageNo)
@@ -397,8 +533,7 @@ func (p *Pager) allocateFromFreelistPersistent() (uint32, bool) {
 		return 0, false
 	}
 
-	storage := p.getStorage()
-	if storage == nil {
+	if p.getStorage() == nil {
 		return 0, false
 	}
 
@@ -410,9 +545,7 @@ func (p *Pager) allocateFromFreelistPersistent() (uint32, bool) {
 		p.freelist.freeCount--
 
 		// Update trunk on storage
-		offset := int(currentHead) * p.pageSize
-		data := storage.Slice(offset, p.pageSize)
-		trunk.Encode(data)
+		trunk.Encode(p.pageData(currentHead))
 
 		// Update header
 		p.writeHeader()
@@ -431,9 +564,7 @@ func (p *Pager) allocateFromFreelistPersistent() (uint32, bool) {
 		p.freelist.headPage = nextTrunk
 	} else if nextTrunk != 0 {
 		// Load next trunk from storage
-		offset := int(nextTrunk) * p.pageSize
-		data := storage.Slice(offset, p.pageSize)
-		loadedTrunk := DecodeFreelistTrunkPage(data)
+		loadedTrunk := DecodeFreelistTrunkPage(p.pageData(nextTrunk))
 		p.freelist.trunks = []*FreelistTrunkPage{loadedTrunk}
 		p.freelist.headPage = nextTrunk
 	} else {
@@ -453,6 +584,27 @@ func (p *Pager) Get(pageNo uint32) (*Page, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// Pages of the active transaction may have been evicted from the cache
+	if page, ok := p.txPages[pageNo]; ok {
+		page.Pin()
+		return page, nil
+	}
+
+	page, err := p.loadLocked(pageNo)
+	if err != nil {
+		return nil, err
+	}
+	p.shadowLocked(page)
+	return page, nil
+}
+
+// loadLocked returns the cached page pageNo, pinned, loading the latest
+// committed version if it is not cached: from its last WAL frame into a
+// buffer of its own, or else backed by the storage. Changes made outside a
+// transaction to a page read from the WAL are lost; callers that write
+// outside of transactions call beginUntrackedLocked first, which empties the
+// WAL.
+func (p *Pager) loadLocked(pageNo uint32) (*Page, error) {
 	// Check cache first
 	if entry, ok := p.cache[pageNo]; ok {
 		entry.page.Pin()
@@ -468,18 +620,10 @@ func (p *Pager) Get(pageNo uint32) (*Page, error) {
 		return nil, ErrPageNotFound
 	}
 
-	storage := p.getStorage()
-	if storage == nil {
-		return nil, ErrPageNotFound
-	}
-
-	// Load from storage
-	offset := int(pageNo) * p.pageSize
-	data := storage.Slice(offset, p.pageSize)
-	if data == nil {
-		return nil, ErrPageNotFound
+	data, err := p.committedDataLocked(pageNo)
+	if err != nil {
+		return nil, err
 	}
-
 	page := NewPageWithData(pageNo, data)
 	page.Pin()
 
@@ -496,6 +640,50 @@ func (p *Pager) Get(pageNo uint32) (*Page, error) {
 	return page, nil
 }
 
+// shadowLocked gives a page touched by the active write transaction a
+// private copy of its data, so that its changes stay out of the storage
+// until the transaction commits. Outside a transaction it does nothing.
+func (p *Pager) shadowLocked(page *Page) {
+	if !p.inTransaction {
+		return
+	}
+	if _, ok := p.txPages[page.PageNo()]; ok {
+		return
+	}
+	if p.walIndex.latest(page.PageNo()) != 0 {
+		// The buffer read from the WAL is left unchanged from here on
+		p.txOrig[page.PageNo()] = page.Data()
+	}
+	data := make([]byte, p.pageSize)
+	copy(data, page.Data())
+	page.UpdateData(data)
+	p.txPages[page.PageNo()] = page
+}
+
+// pageData returns the data of a page to read or modify in place, such as
+// the header or a freelist trunk: the private copy inside a write
+// transaction, the cached page or the storage itself otherwise.
+func (p *Pager) pageData(pageNo uint32) []byte {
+	if page, ok := p.txPages[pageNo]; ok {
+		return page.Data()
+	}
+	if entry, ok := p.cache[pageNo]; ok {
+		p.shadowLocked(entry.page)
+		return entry.page.Data()
+	}
+	data, err := p.committedDataLocked(pageNo)
+	if err != nil {
+		return nil
+	}
+	if !p.inTransaction {
+		return data
+	}
+
+	page := NewPageWithData(pageNo, data)
+	p.shadowLocked(page)
+	return page.Data()
+}
+
 // refreshCacheAfterGrow updates all cached pages with new data slices after mmap regrowth.
 // This is necessary because the underlying memory region changes after remap.
 // CRITICAL: We must update pinned pages - they are actively being used by other code.
@@ -505,8 +693,15 @@ func (p *Pager) refreshCacheAfterGrow() {
 		return
 	}
 
-	// Update data slices for all cached pages
+	// Update data slices for all cached pages, except the private copies of
+	// the active transaction and the pages read from the WAL
 	for pageNo, entry := range p.cache {
+		if _, ok := p.txPages[pageNo]; ok {
+			continue
+		}
+		if p.walIndex.latest(pageNo) != 0 {
+			continue
+		}
 		offset := int(pageNo) * p.pageSize
 		newData := storage.Slice(offset, p.pageSize)
 		if newData != nil {
@@ -600,11 +795,15 @@ func (p *Pager) Release(page *Page) {
 	p.evictIfNeeded()
 }
 
-// Sync flushes all changes to storage
+// Sync flushes all changes to storage. Outside a transaction the WAL is
+// checkpointed first.
 func (p *Pager) Sync() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if err := p.beginUntrackedLocked(); err != nil {
+		return err
+	}
 	p.writeHeader()
 	storage := p.getStorage()
 	if storage == nil {
@@ -613,44 +812,74 @@ func (p *Pager) Sync() error {
 	return storage.Sync()
 }
 
-// Close closes the pager
+// Close closes the pager. An active transaction is rolled back.
 func (p *Pager) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Close WAL
-	if p.wal != nil {
-		p.wal.Close()
+	if p.inTransaction {
+		p.rollbackLocked()
 	}
-
-	// Write header before closing
-	p.writeHeader()
-
 	storage := p.getStorage()
 	if storage == nil {
+		if p.wal != nil {
+			p.wal.Close()
+		}
 		return nil
 	}
 
-	// Sync and close storage
-	if err := storage.Sync(); err != nil {
-		storage.Close()
-		return err
+	// Copy the WAL into the database file, so that it starts out empty next
+	// time, unless readers of other connections still need its frames
+	var err error
+	if !p.readOnly {
+		err = p.checkpointAllLocked()
+		if err == nil {
+			// Write header before closing
+			p.writeHeader()
+			err = storage.Sync()
+		} else if err == ErrReadTxActive {
+			err = nil
+		}
 	}
-
-	return storage.Close()
+	if p.wal != nil {
+		if closeErr := p.wal.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := storage.Close(); err == nil {
+		err = closeErr
+	}
+	if p.shm != nil {
+		if closeErr := p.shm.close(); err == nil {
+			err = closeErr
+		}
+		p.shm = nil
+	}
+	// Cached pages point into the closed storage
+	p.invalidateCache()
+	return err
 }
 
-// BeginWrite starts a write transaction
+// BeginWrite starts a write transaction. Until it commits, the pages it
+// touches are private copies and the database file is left unchanged.
 func (p *Pager) BeginWrite() (*Transaction, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
 	if p.inTransaction {
 		return nil, ErrTxAlreadyActive
 	}
+	// The file no longer changes outside of the WAL
+	if err := p.sharedLocked(); err != nil {
+		return nil, err
+	}
 
 	p.inTransaction = true
-	p.dirtyPages = make(map[uint32][]byte)
+	p.txPages = make(map[uint32]*Page)
+	p.txOrig = make(map[uint32][]byte)
 
 	return &Transaction{pager: p}, nil
 }
@@ -662,7 +891,14 @@ func (p *Pager) InTransaction() bool {
 	return p.inTransaction
 }
 
-// Commit commits the transaction, writing dirty pages to WAL (or just clearing dirty flags for in-memory)
+// Commit commits the transaction. The pages it changed are appended to the
+// WAL, the last one as the commit frame, and the WAL is synced, so crash
+// recovery finds either all of the transaction or none of it. The database
+// file is left alone: from then on the pages are read from the WAL until a
+// checkpoint copies them over. In-memory databases have no WAL and copy the
+// pages into the storage instead. If Commit fails, the transaction stays
+// active and should be rolled back. Once the WAL has grown by the
+// auto-checkpoint threshold, a passive checkpoint follows.
 func (tx *Transaction) Commit() error {
 	p := tx.pager
 	p.mu.Lock()
@@ -672,45 +908,91 @@ func (tx *Transaction) Commit() error {
 		return ErrNoTransaction
 	}
 
-	// For in-memory databases, we skip WAL - data is already in memory
-	// Just clear dirty flags
-	if p.inMemory || p.wal == nil {
-		for pageNo := range p.dirtyPages {
-			entry, ok := p.cache[pageNo]
-			if ok && entry.page.IsDirty() {
-				entry.page.SetDirty(false)
-			}
+	storage := p.getStorage()
+	if storage == nil {
+		return errors.New("no storage backend available")
+	}
+
+	// Only pages that differ from the committed image go to the WAL
+	changed := make([]uint32, 0, len(p.txPages))
+	for pageNo, page := range p.txPages {
+		committed, err := p.committedDataLocked(pageNo)
+		if err != nil {
+			return fmt.Errorf("page %d is beyond the end of the storage", pageNo)
+		}
+		if !bytes.Equal(committed, page.Data()) {
+			changed = append(changed, pageNo)
 		}
-		p.inTransaction = false
-		p.dirtyPages = make(map[uint32][]byte)
-		return nil
 	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
 
-	// Write all dirty pages to WAL
-	dirtyCount := 0
-	for pageNo := range p.dirtyPages {
-		entry, ok := p.cache[pageNo]
-		if !ok {
-			continue
+	if p.wal == nil {
+		for pageNo, page := range p.txPages {
+			data := storage.Slice(int(pageNo)*p.pageSize, p.pageSize)
+			copy(data, page.Data())
+			page.UpdateData(data)
+			page.SetDirty(false)
 		}
+		p.endTransactionLocked()
+		return nil
+	}
 
-		if entry.page.IsDirty() {
-			dirtyCount++
-			isCommit := dirtyCount == len(p.dirtyPages) // Last page is commit
-			if err := p.wal.WriteFrame(pageNo, entry.page.Data(), isCommit); err != nil {
+	if len(changed) > 0 {
+		// Once a checkpoint has copied every frame, the WAL starts over,
+		// unless readers of other connections still read frames
+		if p.walBackfilled > 0 && p.walBackfilled == p.wal.FrameCount() {
+			if err := p.restartWALLocked(false); err != nil && err != ErrReadTxActive {
 				return err
 			}
-			entry.page.SetDirty(false)
 		}
+
+		images := make([]wal.PageImage, len(changed))
+		for i, pageNo := range changed {
+			// WAL page numbers are 1-based, pager page numbers 0-based
+			images[i] = wal.PageImage{PageNo: pageNo + 1, Data: p.txPages[pageNo].Data()}
+		}
+		first := p.wal.FrameCount() + 1
+		if err := p.wal.WriteTransaction(images, p.pageCount); err != nil {
+			return err
+		}
+		for i, pageNo := range changed {
+			p.walIndex.add(pageNo, first+uint32(i))
+		}
+		p.publishLocked(first, changed)
 	}
 
-	// Clear transaction state
-	p.inTransaction = false
-	p.dirtyPages = make(map[uint32][]byte)
+	// Changed pages keep their buffers, which now hold their last frame;
+	// the others go back to their committed data
+	isChanged := make(map[uint32]bool, len(changed))
+	for _, pageNo := range changed {
+		isChanged[pageNo] = true
+	}
+	for pageNo, page := range p.txPages {
+		if !isChanged[pageNo] {
+			if committed, err := p.committedDataLocked(pageNo); err == nil {
+				page.UpdateData(committed)
+			}
+		}
+		page.SetDirty(false)
+	}
+	p.endTransactionLocked()
+
+	if p.walAutoCheckpoint > 0 && p.wal.FrameCount()-p.walBackfilled >= uint32(p.walAutoCheckpoint) {
+		// The transaction is committed either way; a checkpoint that fails
+		// is retried after the next commit
+		_, _ = p.checkpointLocked(CheckpointPassive)
+	}
 
 	return nil
 }
 
+// endTransactionLocked clears the state of the write transaction
+func (p *Pager) endTransactionLocked() {
+	p.inTransaction = false
+	p.txPages = nil
+	p.txOrig = nil
+}
+
 // Rollback aborts the transaction, restoring original page data
 func (tx *Transaction) Rollback() {
 	p := tx.pager
@@ -720,40 +1002,45 @@ func (tx *Transaction) Rollback() {
 	if !p.inTransaction {
 		return
 	}
+	p.rollbackLocked()
+}
 
-	// Restore original page data
-	for pageNo, originalData := range p.dirtyPages {
-		entry, ok := p.cache[pageNo]
-		if !ok {
-			continue
+// rollbackLocked drops the private page copies of the active transaction.
+// The WAL and the storage still hold the committed pages, header included,
+// so the page count and freelist are reread from them.
+func (p *Pager) rollbackLocked() {
+	for pageNo, page := range p.txPages {
+		if committed, err := p.committedDataLocked(pageNo); err == nil {
+			page.UpdateData(committed)
 		}
+		page.SetDirty(false)
+	}
+	p.endTransactionLocked()
 
-		// Restore the original data
-		copy(entry.page.Data(), originalData)
-		entry.page.SetDirty(false)
+	if p.getStorage() == nil {
+		return
 	}
+	// The header was readable when the transaction began
+	_ = p.readHeaderLocked()
 
-	// Clear transaction state
-	p.inTransaction = false
-	p.dirtyPages = make(map[uint32][]byte)
+	// Pages allocated by the transaction no longer exist
+	for pageNo, entry := range p.cache {
+		if pageNo >= p.pageCount {
+			p.releaseCacheMemory(pageNo)
+			p.lru.Remove(entry.element)
+			delete(p.cache, pageNo)
+		}
+	}
 }
 
-// MarkDirty records that a page has been modified in the current transaction
+// MarkDirty records that a page is about to be modified in the current
+// transaction. Pages fetched inside the transaction are tracked already, this
+// also covers pages fetched before it began.
 func (p *Pager) MarkDirty(page *Page) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if !p.inTransaction {
-		return
-	}
-
-	pageNo := page.PageNo()
-	if _, exists := p.dirtyPages[pageNo]; !exists {
-		// Save original data for potential rollback
-		original := make([]byte, p.pageSize)
-		copy(original, page.Data())
-		p.dirtyPages[pageNo] = original
-	}
+	p.shadowLocked(page)
 }
 
 // Free returns a page to the freelist for reuse
@@ -771,6 +1058,10 @@ func (p *Pager) Free(pageNo uint32) error {
 		return ErrPageNotFound
 	}
 
+	if err := p.beginUntrackedLocked(); err != nil {
+		return err
+	}
+
 	// Remove from cache if present
 	if entry, ok := p.cache[pageNo]; ok {
 		p.lru.Remove(entry.element)
@@ -791,8 +1082,7 @@ func (p *Pager) Free(pageNo uint32) error {
 // subsequent freed pages are added as leaf entries in that trunk.
 // When the trunk is full, we allocate a new trunk from the freelist itself.
 func (p *Pager) addToFreelistPersistent(pageNo uint32) {
-	storage := p.getStorage()
-	if storage == nil {
+	if p.getStorage() == nil {
 		return
 	}
 
@@ -807,9 +1097,7 @@ func (p *Pager) addToFreelistPersistent(pageNo uint32) {
 			LeafPages: []uint32{},
 		}
 		// Write trunk to the freed page
-		offset := int(pageNo) * p.pageSize
-		data := storage.Slice(offset, p.pageSize)
-		trunk.Encode(data)
+		trunk.Encode(p.pageData(pageNo))
 
 		// Update in-memory freelist - the trunk page itself is a free page
 		p.freelist.trunks = []*FreelistTrunkPage{trunk}
@@ -827,9 +1115,7 @@ func (p *Pager) addToFreelistPersistent(pageNo uint32) {
 			p.freelist.freeCount++
 
 			// Write updated trunk to storage
-			offset := int(currentHead) * p.pageSize
-			data := storage.Slice(offset, p.pageSize)
-			trunk.Encode(data)
+			trunk.Encode(p.pageData(currentHead))
 			return
 		}
 
@@ -842,9 +1128,7 @@ func (p *Pager) addToFreelistPersistent(pageNo uint32) {
 		}
 
 		// Write new trunk to the freed page
-		offset := int(pageNo) * p.pageSize
-		data := storage.Slice(offset, p.pageSize)
-		newTrunk.Encode(data)
+		newTrunk.Encode(p.pageData(pageNo))
 
 		// Update in-memory freelist
 		p.freelist.trunks = append([]*FreelistTrunkPage{newTrunk}, p.freelist.trunks...)
@@ -867,87 +1151,27 @@ func (p *Pager) FreePageCount() uint32 {
 // getPageLocked retrieves a page while already holding the lock.
 // Used internally by Allocate when reusing a freed page.
 func (p *Pager) getPageLocked(pageNo uint32) (*Page, error) {
-	// Check cache first
-	if entry, ok := p.cache[pageNo]; ok {
-		entry.page.Pin()
-		// Move to front of LRU
-		p.lru.MoveToFront(entry.element)
-		return entry.page, nil
-	}
-
-	// Check bounds
-	if pageNo >= p.pageCount {
-		return nil, ErrPageNotFound
-	}
-
-	storage := p.getStorage()
-	if storage == nil {
-		return nil, ErrPageNotFound
-	}
-
-	// Load from storage
-	offset := int(pageNo) * p.pageSize
-	data := storage.Slice(offset, p.pageSize)
-	if data == nil {
-		return nil, ErrPageNotFound
+	page, ok := p.txPages[pageNo]
+	if ok {
+		page.Pin()
+	} else {
+		var err error
+		page, err = p.loadLocked(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		p.shadowLocked(page)
 	}
 
-	page := NewPageWithData(pageNo, data)
-	page.Pin()
-
 	// Clear the page data (reused pages should be zeroed)
+	data := page.Data()
 	for i := range data {
 		data[i] = 0
 	}
 
-	// Add to cache with LRU tracking
-	elem := p.lru.PushFront(pageNo)
-	p.cache[pageNo] = &cacheEntry{page: page, element: elem}
-
-	// Evict if needed
-	p.evictIfNeeded()
-
 	return page, nil
 }
 
-// loadFreelist loads the freelist from storage on database open
-// This is used by file-based databases (uses mmap directly for backward compat)
-func (p *Pager) loadFreelist(headPage uint32, freeCount uint32) {
-	if headPage == 0 || freeCount == 0 {
-		// No freelist to load
-		return
-	}
-
-	// Load trunk pages directly into freelist structure
-	p.freelist.trunks = nil
-	p.freelist.headPage = headPage
-	p.freelist.freeCount = freeCount
-
-	// Walk the trunk page chain and load all trunks
-	currentTrunkPage := headPage
-
-	storage := p.getStorage()
-	if storage == nil {
-		return
-	}
-
-	for currentTrunkPage != 0 {
-		// Read trunk page data from storage
-		offset := int(currentTrunkPage) * p.pageSize
-		data := storage.Slice(offset, p.pageSize)
-		if data == nil {
-			break
-		}
-
-		// Decode the trunk page
-		trunk := DecodeFreelistTrunkPage(data)
-		p.freelist.trunks = append(p.freelist.trunks, trunk)
-
-		// Move to next trunk
-		currentTrunkPage = trunk.NextTrunk
-	}
-}
-
 // MemoryBudget returns the memory budget associated with this pager, if any
 func (p *Pager) MemoryBudget() *cache.MemoryBudget {
 	p.mu.RLock()
@@ -2,6 +2,8 @@
 package pager
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -108,6 +110,34 @@ func TestPagerHeader(t *testing.T) {
 	}
 }
 
+func TestPagerRejectsOlderFormat(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	p, err := Open(path, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	p.Close()
+
+	// Files written before the format version existed have zeros there
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	if _, err := f.WriteAt(make([]byte, 4), offsetFormatVersion); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	f.Close()
+
+	if _, err := Open(path, Options{}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := Open(path, Options{ReadOnly: true}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat for a read-only open, got %v", err)
+	}
+}
+
 func TestPagerLRUCache(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "test.db")
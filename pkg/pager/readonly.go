@@ -0,0 +1,244 @@
+// pkg/pager/readonly.go
+package pager
+
+import (
+	"container/list"
+	"encoding/binary"
+	"time"
+
+	"tur/pkg/cache"
+	"tur/pkg/wal"
+)
+
+// openReadOnly opens a connection that reads a database another connection,
+// in this process or another, may be writing. It maps the database file
+// read-only and learns of the writer's commits through the shared WAL index:
+// see BeginSnapshot.
+func openReadOnly(path string, opts Options, budget *cache.MemoryBudget) (*Pager, error) {
+	cacheSize := opts.CacheSize
+	if cacheSize == 0 {
+		cacheSize = 1000
+	}
+
+	mf, err := OpenMmapFileReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	// The page size never changes, so the header in the file has it right
+	header := mf.Slice(0, headerSize)
+	if header == nil || string(header[0:len(magicString)]) != magicString {
+		mf.Close()
+		return nil, ErrInvalidHeader
+	}
+	if err := checkFormatVersion(header); err != nil {
+		mf.Close()
+		return nil, err
+	}
+	pageSize := int(binary.LittleEndian.Uint32(header[16:20]))
+
+	shm, err := openShm(path)
+	if err != nil {
+		mf.Close()
+		return nil, err
+	}
+
+	p := &Pager{
+		storage:      mf,
+		mmap:         mf,
+		path:         path,
+		pageSize:     pageSize,
+		cache:        make(map[uint32]*cacheEntry),
+		lru:          list.New(),
+		cacheSize:    cacheSize,
+		freelist:     NewFreelist(pageSize),
+		memoryBudget: budget,
+		shm:          shm,
+		readOnly:     true,
+	}
+	if budget != nil {
+		budget.RegisterComponent("page_cache")
+	}
+
+	fail := func(err error) (*Pager, error) {
+		if p.wal != nil {
+			p.wal.Close()
+		}
+		shm.close()
+		mf.Close()
+		return nil, err
+	}
+	if p.wal, err = wal.OpenReader(path+"-wal", wal.Options{PageSize: pageSize}); err != nil {
+		return fail(err)
+	}
+	if err := p.joinReaderLocked(); err != nil {
+		return fail(err)
+	}
+	// Start out with the latest commit, without holding on to it
+	if _, err := p.refreshLocked(); err != nil {
+		return fail(err)
+	}
+	p.shm.releaseSlot()
+	return p, nil
+}
+
+// joinReaderLocked joins the connections to the database. The first one
+// rebuilds the shared WAL index from the WAL, where the last writer may have
+// left committed frames behind.
+func (p *Pager) joinReaderLocked() error {
+	s := p.shm
+	if s.lock(shmLockConnections, true) == nil {
+		// A writer that is opening holds the header lock; it publishes its
+		// own index
+		if s.lock(shmLockHeader, true) == nil {
+			err := p.rebuildShmLocked()
+			s.unlock(shmLockHeader)
+			if err != nil {
+				return err
+			}
+			return s.wait(shmLockConnections, false)
+		}
+		s.unlock(shmLockConnections)
+	}
+	return s.joinShared()
+}
+
+// rebuildShmLocked publishes the committed frames of the WAL under a new
+// generation
+func (p *Pager) rebuildShmLocked() error {
+	frames, err := p.wal.Rescan()
+	if err != nil {
+		return err
+	}
+	pages := make([]uint32, 0, frames)
+	err = p.wal.ForEachFrame(func(frame *wal.Frame) error {
+		// WAL page numbers are 1-based, pager page numbers 0-based
+		pages = append(pages, frame.PageNo-1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return p.shm.publish(1, pages, true)
+}
+
+// ReadOnly reports whether the pager was opened with Options.ReadOnly
+func (p *Pager) ReadOnly() bool {
+	return p.readOnly
+}
+
+// BeginSnapshot makes a read-only pager read the database as of the last
+// commit of its writer, and keep reading that state until the matching
+// EndSnapshot: the writer leaves alone the frames and the pages of the file
+// the snapshot reads. Snapshots nest; only the outermost one looks for new
+// commits. It reports whether the database changed since the previous
+// snapshot, in which case anything built from its pages is out of date.
+// Reads outside of a snapshot may fail once the writer checkpoints.
+//
+// For a read-write pager, which sees its own commits, it does nothing.
+func (p *Pager) BeginSnapshot() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.readOnly {
+		return false, nil
+	}
+	if p.snapshots > 0 {
+		p.snapshots++
+		return false, nil
+	}
+	changed, err := p.refreshLocked()
+	if err != nil {
+		return false, err
+	}
+	p.snapshots = 1
+	return changed, nil
+}
+
+// EndSnapshot ends a snapshot started by BeginSnapshot
+func (p *Pager) EndSnapshot() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.readOnly || p.snapshots == 0 {
+		return
+	}
+	p.snapshots--
+	if p.snapshots == 0 && p.shm != nil {
+		p.shm.releaseSlot()
+	}
+}
+
+// refreshLocked takes a read slot marking the frames the writer has
+// published, and brings the WAL index, the cache and the header up to them.
+// It reports whether anything changed.
+func (p *Pager) refreshLocked() (bool, error) {
+	s := p.shm
+	deadline := time.Now().Add(lockTimeout)
+	var generation, maxFrame uint32
+	for {
+		if err := s.wait(shmLockHeader, false); err != nil {
+			return false, err
+		}
+		generation, maxFrame, _ = s.header()
+		if s.acquireSlot(maxFrame) {
+			break
+		}
+		s.unlock(shmLockHeader)
+		if time.Now().After(deadline) {
+			return false, ErrBusy
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer s.unlock(shmLockHeader)
+
+	from := p.wal.FrameCount() + 1
+	if p.shmLoaded && generation == p.shmGeneration && maxFrame+1 == from {
+		return false, nil
+	}
+	if !p.shmLoaded || generation != p.shmGeneration || maxFrame+1 < from {
+		// The WAL started over or another writer took over
+		p.walIndex.reset()
+		p.invalidateCache()
+		from = 1
+	}
+
+	err := p.applyFramesLocked(from, maxFrame)
+	if err != nil {
+		s.releaseSlot()
+		p.shmLoaded = false
+		return false, err
+	}
+	p.shmGeneration = generation
+	p.shmLoaded = true
+	return true, nil
+}
+
+// applyFramesLocked indexes the frames from from to maxFrame, drops the
+// cached pages they hold newer versions of and rereads the header
+func (p *Pager) applyFramesLocked(from, maxFrame uint32) error {
+	pages, err := p.shm.framePages(from, maxFrame)
+	if err != nil {
+		return err
+	}
+	if err := p.wal.Refresh(maxFrame); err != nil {
+		return err
+	}
+	for i, pageNo := range pages {
+		p.walIndex.add(pageNo, from+uint32(i))
+		if entry, ok := p.cache[pageNo]; ok {
+			p.releaseCacheMemory(pageNo)
+			p.lru.Remove(entry.element)
+			delete(p.cache, pageNo)
+		}
+	}
+
+	// Checkpoints may have grown the file
+	size := p.mmap.Size()
+	if err := p.mmap.Remap(); err != nil {
+		return err
+	}
+	if p.mmap.Size() != size {
+		p.refreshCacheAfterGrow()
+	}
+	return p.readHeaderLocked()
+}
@@ -0,0 +1,326 @@
+// pkg/pager/shm.go
+package pager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The connections to a database file, in one process or in several, share
+// the WAL index through the "-shm" file next to it, which each of them maps
+// into memory. It starts with a header, followed by the pager page number
+// held by each committed WAL frame, so that a reader can index the frames
+// the writer appended without reading them.
+//
+// Byte-range locks on the file coordinate the connections:
+//
+//   - the writer lock is held exclusively by the read-write connection, so
+//     that there is only one
+//   - the connection lock is held shared by every connection. The first one
+//     to open the database holds it exclusively while it rebuilds the index
+//     from the WAL, and a writer changing the database file outside of the
+//     WAL, such as VACUUM, holds it exclusively so that nobody reads the file
+//   - the header lock is held exclusively by the writer while it publishes
+//     frames or starts the WAL over, and shared by readers while they pick up
+//     what it published
+//   - each read slot lock is held exclusively by a reader for as long as its
+//     snapshot lasts, after writing in the slot the number of frames it sees.
+//     The writer copies frames into the database file only up to the lowest
+//     mark of the held slots, and starts the WAL over only once none of them
+//     reads frames.
+const (
+	shmMagic   = 0x54555244 // "TURD"
+	shmVersion = 1
+
+	shmOffsetMagic      = 0
+	shmOffsetVersion    = 4
+	shmOffsetGeneration = 8
+	shmOffsetMaxFrame   = 12
+	shmOffsetMarks      = 16
+
+	shmReadSlots  = 16
+	shmHeaderSize = 128
+	shmChunkSize  = 32768
+
+	shmLockWriter      = 96
+	shmLockConnections = 97
+	shmLockHeader      = 98
+	shmLockSlots       = 99
+)
+
+// lockTimeout bounds how long opening a connection or starting a snapshot
+// waits for locks held by other connections
+const lockTimeout = 5 * time.Second
+
+// errLockBusy is returned by lockByte when another connection holds a
+// conflicting lock
+var errLockBusy = errors.New("lock held by another connection")
+
+// walShm is a connection's mapping of the shared WAL index
+type walShm struct {
+	mf *MmapFile
+	f  *os.File
+	// slot is the read slot this connection holds, or -1
+	slot int
+	// exclusive is set while this connection holds the connection lock
+	// exclusively
+	exclusive bool
+}
+
+// openShm opens or creates the shared WAL index of the database at path
+func openShm(path string) (*walShm, error) {
+	mf, err := OpenMmapFile(path+"-shm", shmChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return &walShm{mf: mf, f: mf.osFile(), slot: -1}, nil
+}
+
+// close unmaps and closes the file, which releases the locks of this
+// connection
+func (s *walShm) close() error {
+	return s.mf.Close()
+}
+
+// lock takes a lock without waiting, wait waits for it and lockRetry keeps
+// trying for up to lockTimeout
+func (s *walShm) lock(offset int64, exclusive bool) error {
+	return lockByte(s.f, offset, exclusive, false)
+}
+
+func (s *walShm) wait(offset int64, exclusive bool) error {
+	return lockByte(s.f, offset, exclusive, true)
+}
+
+func (s *walShm) lockRetry(offset int64, exclusive bool) error {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := s.lock(offset, exclusive)
+		if err != errLockBusy || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *walShm) unlock(offset int64) {
+	_ = unlockByte(s.f, offset)
+}
+
+func (s *walShm) get(offset int) uint32 {
+	return binary.LittleEndian.Uint32(s.mf.Slice(offset, 4))
+}
+
+func (s *walShm) put(offset int, v uint32) {
+	binary.LittleEndian.PutUint32(s.mf.Slice(offset, 4), v)
+}
+
+// header returns the generation of the index, which changes whenever the
+// WAL starts over or a new writer takes over, and the number of frames
+// published in it. ok is false for a file nobody has initialized.
+func (s *walShm) header() (generation, maxFrame uint32, ok bool) {
+	if s.get(shmOffsetMagic) != shmMagic || s.get(shmOffsetVersion) != shmVersion {
+		return 0, 0, false
+	}
+	return s.get(shmOffsetGeneration), s.get(shmOffsetMaxFrame), true
+}
+
+// publish records the pages held by the frames from first on, which ends the
+// index there, under a new generation if newGeneration is set. The caller
+// holds the header lock exclusively.
+func (s *walShm) publish(first uint32, pages []uint32, newGeneration bool) error {
+	maxFrame := first - 1 + uint32(len(pages))
+	if required := int64(shmHeaderSize) + 4*int64(maxFrame); required > s.mf.Size() {
+		size := (required + shmChunkSize - 1) / shmChunkSize * shmChunkSize
+		if err := s.mf.Grow(size); err != nil {
+			return err
+		}
+	}
+	for i, pageNo := range pages {
+		s.put(shmHeaderSize+4*int(first-1)+4*i, pageNo)
+	}
+
+	generation, _, ok := s.header()
+	if newGeneration || !ok {
+		s.put(shmOffsetMagic, shmMagic)
+		s.put(shmOffsetVersion, shmVersion)
+		s.put(shmOffsetGeneration, generation+1)
+	}
+	s.put(shmOffsetMaxFrame, maxFrame)
+	return nil
+}
+
+// framePages returns the pages held by frames from to to, 1-based. The
+// caller holds the header lock.
+func (s *walShm) framePages(from, to uint32) ([]uint32, error) {
+	if from > to {
+		return nil, nil
+	}
+	if required := int64(shmHeaderSize) + 4*int64(to); required > s.mf.Size() {
+		// The writer has grown the file since it was mapped
+		if err := s.mf.Remap(); err != nil {
+			return nil, err
+		}
+		if required > s.mf.Size() {
+			return nil, fmt.Errorf("shared WAL index is too short for %d frames", to)
+		}
+	}
+	pages := make([]uint32, 0, to-from+1)
+	for frame := from; frame <= to; frame++ {
+		pages = append(pages, s.get(shmHeaderSize+4*int(frame-1)))
+	}
+	return pages, nil
+}
+
+func slotLock(i int) int64 {
+	return shmLockSlots + int64(i)
+}
+
+func slotMark(i int) int {
+	return shmOffsetMarks + 4*i
+}
+
+// acquireSlot takes a free read slot and records mark in it. The caller
+// holds the header lock shared.
+func (s *walShm) acquireSlot(mark uint32) bool {
+	for i := 0; i < shmReadSlots; i++ {
+		if s.lock(slotLock(i), true) == nil {
+			s.put(slotMark(i), mark)
+			s.slot = i
+			return true
+		}
+	}
+	return false
+}
+
+// releaseSlot gives up the read slot this connection holds, if any
+func (s *walShm) releaseSlot() {
+	if s.slot >= 0 {
+		s.unlock(slotLock(s.slot))
+		s.slot = -1
+	}
+}
+
+// readLimit lowers limit to the lowest mark of the read slots other
+// connections hold
+func (s *walShm) readLimit(limit uint32) uint32 {
+	for i := 0; i < shmReadSlots; i++ {
+		if s.lock(slotLock(i), true) == nil {
+			s.unlock(slotLock(i))
+			continue
+		}
+		if mark := s.get(slotMark(i)); mark < limit {
+			limit = mark
+		}
+	}
+	return limit
+}
+
+// readersInWAL reports whether another connection holds a read slot whose
+// snapshot reads frames, and clears the marks of the free slots. The caller
+// holds the header lock exclusively, so no reader can take a slot meanwhile.
+func (s *walShm) readersInWAL() bool {
+	busy := false
+	for i := 0; i < shmReadSlots; i++ {
+		if s.lock(slotLock(i), true) == nil {
+			s.put(slotMark(i), 0)
+			s.unlock(slotLock(i))
+			continue
+		}
+		if s.get(slotMark(i)) > 0 {
+			busy = true
+		}
+	}
+	return busy
+}
+
+// joinWriter makes this connection the writer of the database and joins the
+// other connections
+func (s *walShm) joinWriter() error {
+	if err := s.lock(shmLockWriter, true); err != nil {
+		if err == errLockBusy {
+			return ErrDatabaseLocked
+		}
+		return err
+	}
+	return s.joinShared()
+}
+
+// joinShared takes the connection lock shared, waiting for a connection
+// that holds it exclusively
+func (s *walShm) joinShared() error {
+	err := s.lockRetry(shmLockConnections, false)
+	if err == errLockBusy {
+		return ErrDatabaseLocked
+	}
+	return err
+}
+
+// publishLocked shares the frames from first on, holding pages, with the
+// other connections. If an earlier publication failed, or shmStale was set
+// to make readers start over, the whole WAL index goes out under a new
+// generation instead. A failure leaves readers at the frames published
+// before, and the next publication retries.
+func (p *Pager) publishLocked(first uint32, pages []uint32) {
+	if p.shm == nil {
+		return
+	}
+	if err := p.shm.wait(shmLockHeader, true); err != nil {
+		p.shmStale = true
+		return
+	}
+	p.shareLocked(first, pages)
+	p.shm.unlock(shmLockHeader)
+}
+
+// shareLocked is publishLocked for a caller that holds the header lock
+func (p *Pager) shareLocked(first uint32, pages []uint32) {
+	var err error
+	if p.shmStale {
+		all := make([]uint32, p.wal.FrameCount())
+		for pageNo, frames := range p.walIndex.frames {
+			for _, frame := range frames {
+				all[frame-1] = pageNo
+			}
+		}
+		err = p.shm.publish(1, all, true)
+	} else {
+		err = p.shm.publish(first, pages, false)
+	}
+	p.shmStale = err != nil
+}
+
+// exclusiveLocked makes sure that no other connection uses the database,
+// before the writer changes the database file outside of the WAL. Nobody can
+// join until the next write transaction begins.
+func (p *Pager) exclusiveLocked() error {
+	if p.shm == nil || p.shm.exclusive {
+		return nil
+	}
+	if err := p.shm.lock(shmLockConnections, true); err != nil {
+		if err == errLockBusy {
+			return ErrBusy
+		}
+		return err
+	}
+	p.shm.exclusive = true
+	return nil
+}
+
+// sharedLocked lets other connections join again after exclusiveLocked. They
+// rebuild their WAL index from scratch.
+func (p *Pager) sharedLocked() error {
+	if p.shm == nil || !p.shm.exclusive {
+		return nil
+	}
+	p.shmStale = true
+	p.publishLocked(0, nil)
+	if err := p.shm.wait(shmLockConnections, false); err != nil {
+		return err
+	}
+	p.shm.exclusive = false
+	return nil
+}
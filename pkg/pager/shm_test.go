@@ -0,0 +1,235 @@
+// pkg/pager/shm_test.go
+package pager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pageByte returns the first byte of a page as the pager sees it
+func pageByte(t *testing.T, p *Pager, pageNo uint32) byte {
+	t.Helper()
+	page, err := p.Get(pageNo)
+	if err != nil {
+		t.Fatalf("Get %d failed: %v", pageNo, err)
+	}
+	defer p.Release(page)
+	return page.Data()[0]
+}
+
+// beginSnapshot starts a snapshot on a read-only pager and returns whether
+// the database changed since the previous one
+func beginSnapshot(t *testing.T, p *Pager) bool {
+	t.Helper()
+	changed, err := p.BeginSnapshot()
+	if err != nil {
+		t.Fatalf("BeginSnapshot failed: %v", err)
+	}
+	return changed
+}
+
+func TestReadOnlyPagerAlongsideWriter(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	writer, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open writer: %v", err)
+	}
+	defer writer.Close()
+	commitPage(t, writer, 1, 1)
+	if _, err := os.Stat(dbPath + "-shm"); err != nil {
+		t.Fatalf("expected a shared WAL index: %v", err)
+	}
+
+	// Only one connection writes
+	if _, err := Open(dbPath, Options{PageSize: 4096}); err != ErrDatabaseLocked {
+		t.Fatalf("expected a second writer to get ErrDatabaseLocked, got %v", err)
+	}
+
+	reader, err := Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	defer reader.Close()
+	if !reader.ReadOnly() || writer.ReadOnly() {
+		t.Fatal("ReadOnly does not match the options")
+	}
+	if reader.PageSize() != 4096 {
+		t.Errorf("reader page size %d, want the one of the file", reader.PageSize())
+	}
+	if _, err := reader.BeginWrite(); err != ErrReadOnly {
+		t.Errorf("expected BeginWrite on the reader to fail with ErrReadOnly, got %v", err)
+	}
+
+	// A snapshot sees the last commit and keeps seeing it
+	beginSnapshot(t, reader)
+	if got := pageByte(t, reader, 1); got != 1 {
+		t.Errorf("reader sees %d, want 1", got)
+	}
+	commitPage(t, writer, 1, 2)
+	commitPage(t, writer, 2, 3)
+	if got := pageByte(t, reader, 1); got != 1 {
+		t.Errorf("snapshot changed to %d while it lasted", got)
+	}
+	if reader.PageCount() != 2 {
+		t.Errorf("snapshot sees %d pages, want 2", reader.PageCount())
+	}
+	reader.EndSnapshot()
+
+	if !beginSnapshot(t, reader) {
+		t.Error("expected the next snapshot to report the commits")
+	}
+	if got := pageByte(t, reader, 1); got != 2 {
+		t.Errorf("reader sees %d after the commit, want 2", got)
+	}
+	if got := pageByte(t, reader, 2); got != 3 {
+		t.Errorf("reader sees %d on the new page, want 3", got)
+	}
+	reader.EndSnapshot()
+	if beginSnapshot(t, reader) {
+		t.Error("expected no change without commits")
+	}
+	reader.EndSnapshot()
+
+	// The writer cannot change the file outside of the WAL under a reader
+	if err := writer.SetAutoVacuum(AutoVacuumIncremental); err != ErrBusy {
+		t.Errorf("expected ErrBusy while a reader is connected, got %v", err)
+	}
+}
+
+func TestReadOnlyPagerHoldsBackCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	writer, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open writer: %v", err)
+	}
+	defer writer.Close()
+	commitPage(t, writer, 1, 1)
+
+	reader, err := Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	defer reader.Close()
+
+	beginSnapshot(t, reader)
+	commitPage(t, writer, 1, 2)
+
+	// The frames the reader does not see stay in the WAL, and the WAL does
+	// not start over
+	result, err := writer.Checkpoint(CheckpointPassive)
+	if err != nil {
+		t.Fatalf("passive checkpoint failed: %v", err)
+	}
+	if result.FramesRemaining == 0 {
+		t.Errorf("expected frames past the snapshot to remain, got %+v", result)
+	}
+	if result, err := writer.Checkpoint(CheckpointRestart); err != nil || !result.Busy {
+		t.Errorf("expected a restart to be busy, got %+v, %v", result, err)
+	}
+	commitPage(t, writer, 1, 3)
+	if got := pageByte(t, reader, 1); got != 1 {
+		t.Errorf("snapshot changed to %d after the checkpoints", got)
+	}
+	reader.EndSnapshot()
+
+	// Once the reader moves on, the WAL can start over under it
+	result, err = writer.Checkpoint(CheckpointTruncate)
+	if err != nil || result.Busy || result.FramesRemaining != 0 {
+		t.Fatalf("truncate checkpoint: %+v, %v", result, err)
+	}
+	commitPage(t, writer, 1, 4)
+	if !beginSnapshot(t, reader) {
+		t.Error("expected the snapshot to report the new WAL")
+	}
+	if got := pageByte(t, reader, 1); got != 4 {
+		t.Errorf("reader sees %d after the restart, want 4", got)
+	}
+	reader.EndSnapshot()
+}
+
+func TestClosedReaderLeavesWriterLocked(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	writer, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open writer: %v", err)
+	}
+	defer writer.Close()
+	commitPage(t, writer, 1, 1)
+
+	// Closing another connection of the process keeps the locks of the
+	// writer, so it is still the only one
+	reader, err := Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("failed to close reader: %v", err)
+	}
+	if _, err := Open(dbPath, Options{PageSize: 4096}); err != ErrDatabaseLocked {
+		t.Fatalf("expected a second writer to get ErrDatabaseLocked, got %v", err)
+	}
+
+	// The writer still holds back checkpoints under a new reader
+	reader, err = Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to reopen reader: %v", err)
+	}
+	defer reader.Close()
+	beginSnapshot(t, reader)
+	commitPage(t, writer, 1, 2)
+	if result, err := writer.Checkpoint(CheckpointRestart); err != nil || !result.Busy {
+		t.Errorf("expected a restart to be busy, got %+v, %v", result, err)
+	}
+	if got := pageByte(t, reader, 1); got != 1 {
+		t.Errorf("snapshot changed to %d", got)
+	}
+	reader.EndSnapshot()
+}
+
+func TestReadOnlyPagerRebuildsIndex(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	// A writer that goes away leaves its commits in the WAL
+	writer, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open writer: %v", err)
+	}
+	commitPage(t, writer, 1, 5)
+	reader, err := Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	defer reader.Close()
+	beginSnapshot(t, reader)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	if info, err := os.Stat(dbPath + "-wal"); err != nil || info.Size() == 0 {
+		t.Fatalf("expected the WAL to outlive the writer under a snapshot: %v", err)
+	}
+	reader.EndSnapshot()
+
+	os.Remove(dbPath + "-shm")
+	second, err := Open(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open a reader on its own: %v", err)
+	}
+	defer second.Close()
+	beginSnapshot(t, second)
+	if got := pageByte(t, second, 1); got != 5 {
+		t.Errorf("reader sees %d, want 5", got)
+	}
+	second.EndSnapshot()
+
+	if _, err := Open(filepath.Join(dir, "missing.db"), Options{ReadOnly: true}); err == nil {
+		t.Error("expected opening a missing database read-only to fail")
+	}
+}
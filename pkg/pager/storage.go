@@ -20,6 +20,10 @@ type Storage interface {
 	// If newSize is less than or equal to current size, this is a no-op.
 	Grow(newSize int64) error
 
+	// Truncate shrinks the storage to the specified size, discarding the data
+	// past it. If newSize is greater than or equal to current size, this is a no-op.
+	Truncate(newSize int64) error
+
 	// Close releases any resources associated with the storage.
 	Close() error
 }
@@ -79,6 +83,22 @@ func (m *MemoryStorage) Grow(newSize int64) error {
 	return nil
 }
 
+// Truncate shrinks the storage to the specified size.
+// If newSize is greater than or equal to current size, this is a no-op.
+// The data is copied to a smaller buffer so the memory is released.
+func (m *MemoryStorage) Truncate(newSize int64) error {
+	if newSize <= 0 || newSize >= m.size {
+		return nil
+	}
+
+	newData := make([]byte, newSize)
+	copy(newData, m.data)
+
+	m.data = newData
+	m.size = newSize
+	return nil
+}
+
 // Close releases the memory storage.
 // After Close, the storage should not be used.
 func (m *MemoryStorage) Close() error {
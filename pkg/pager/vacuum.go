@@ -0,0 +1,339 @@
+// pkg/pager/vacuum.go
+package pager
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// AutoVacuumMode controls how the database file gives free pages back to the
+// file system. The values follow SQLite's auto_vacuum pragma.
+type AutoVacuumMode uint32
+
+const (
+	// AutoVacuumNone keeps free pages in the file for reuse; only VACUUM shrinks it
+	AutoVacuumNone AutoVacuumMode = 0
+	// AutoVacuumIncremental shrinks the file on request (PRAGMA incremental_vacuum)
+	AutoVacuumIncremental AutoVacuumMode = 2
+)
+
+// ErrPageNotMovable is returned by an IncrementalVacuum move function for a page
+// whose owner cannot relocate it. The vacuum stops at that page.
+var ErrPageNotMovable = errors.New("page cannot be relocated")
+
+// AutoVacuum returns the auto-vacuum mode recorded in the header
+func (p *Pager) AutoVacuum() AutoVacuumMode {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.autoVacuum
+}
+
+// SetAutoVacuum records the auto-vacuum mode in the header. Inside a write
+// transaction the change commits with it.
+func (p *Pager) SetAutoVacuum(mode AutoVacuumMode) error {
+	if mode != AutoVacuumNone && mode != AutoVacuumIncremental {
+		return fmt.Errorf("unsupported auto-vacuum mode %d", mode)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.beginUntrackedLocked(); err != nil {
+		return err
+	}
+	p.autoVacuum = mode
+	p.writeHeader()
+	if p.inTransaction {
+		return nil
+	}
+	return p.syncUntrackedLocked()
+}
+
+// IncrementalVacuum gives up to n free pages (all of them when n <= 0) back to
+// the file system by truncating the file. Free pages at the end of the file are
+// dropped directly. An in-use page at the end is first copied to the lowest free
+// page, and move is then called so that its owner can repoint whatever referred
+// to the old page number. If move returns ErrPageNotMovable the vacuum stops
+// there and keeps what it has reclaimed so far.
+// Returns the number of pages removed from the file.
+func (p *Pager) IncrementalVacuum(n int, move func(from, to uint32) error) (int, error) {
+	p.mu.Lock()
+	if p.inTransaction {
+		p.mu.Unlock()
+		return 0, ErrTxAlreadyActive
+	}
+	if err := p.beginUntrackedLocked(); err != nil {
+		p.mu.Unlock()
+		return 0, err
+	}
+	free := p.freePagesLocked()
+	pageCount := p.pageCount
+	p.mu.Unlock()
+
+	if n <= 0 || n > len(free) {
+		n = len(free)
+	}
+	isFree := make(map[uint32]bool, len(free))
+	for _, pageNo := range free {
+		isFree[pageNo] = true
+	}
+
+	var moveErr error
+	removed, low := 0, 0
+	for removed < n {
+		last := pageCount - 1
+		if isFree[last] {
+			delete(isFree, last)
+			pageCount--
+			removed++
+			continue
+		}
+
+		// Move the last page into the lowest free page
+		for low < len(free) && !isFree[free[low]] {
+			low++
+		}
+		if low == len(free) || free[low] >= last {
+			break
+		}
+		dest := free[low]
+		if err := p.copyPage(last, dest); err != nil {
+			moveErr = err
+			break
+		}
+		if err := move(last, dest); err != nil {
+			if !errors.Is(err, ErrPageNotMovable) {
+				moveErr = err
+			}
+			break
+		}
+		delete(isFree, dest)
+		pageCount--
+		removed++
+	}
+
+	if removed == 0 {
+		return 0, moveErr
+	}
+
+	remaining := make([]uint32, 0, len(isFree))
+	for pageNo := range isFree {
+		remaining = append(remaining, pageNo)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.truncateLocked(pageCount, remaining); err != nil {
+		return 0, err
+	}
+	return removed, moveErr
+}
+
+// freePagesLocked returns every page on the freelist, trunk pages included, in
+// ascending order
+func (p *Pager) freePagesLocked() []uint32 {
+	storage := p.getStorage()
+	pages := make([]uint32, 0, p.freelist.FreeCount())
+	seen := make(map[uint32]bool)
+	for trunkPage := p.freelist.HeadPage(); trunkPage != 0 && trunkPage < p.pageCount && !seen[trunkPage]; {
+		seen[trunkPage] = true
+		data := storage.Slice(int(trunkPage)*p.pageSize, p.pageSize)
+		if data == nil {
+			break
+		}
+		trunk := DecodeFreelistTrunkPage(data)
+		pages = append(pages, trunkPage)
+		pages = append(pages, trunk.LeafPages...)
+		trunkPage = trunk.NextTrunk
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i] < pages[j]
+	})
+	return pages
+}
+
+// copyPage copies the content of page from onto page to
+func (p *Pager) copyPage(from, to uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	storage := p.getStorage()
+	src := storage.Slice(int(from)*p.pageSize, p.pageSize)
+	dst := storage.Slice(int(to)*p.pageSize, p.pageSize)
+	if src == nil || dst == nil {
+		return ErrPageNotFound
+	}
+	copy(dst, src)
+	return nil
+}
+
+// truncateLocked shrinks the database to newCount pages and rebuilds the
+// freelist from free, whose pages must all lie below newCount
+func (p *Pager) truncateLocked(newCount uint32, free []uint32) error {
+	// Drop cached pages past the new end of the file
+	for pageNo, entry := range p.cache {
+		if pageNo >= newCount {
+			p.releaseCacheMemory(pageNo)
+			p.lru.Remove(entry.element)
+			delete(p.cache, pageNo)
+		}
+	}
+
+	// Add the highest pages first, so the lowest are handed out first
+	sort.Slice(free, func(i, j int) bool {
+		return free[i] > free[j]
+	})
+	p.freelist = NewFreelist(p.pageSize)
+	for _, pageNo := range free {
+		p.addToFreelistPersistent(pageNo)
+	}
+
+	p.pageCount = newCount
+	p.writeHeader()
+
+	storage := p.getStorage()
+	if err := storage.Truncate(int64(newCount) * int64(p.pageSize)); err != nil {
+		return err
+	}
+	// Both file and memory storage move their data when resized
+	p.refreshCacheAfterGrow()
+	return p.syncUntrackedLocked()
+}
+
+// beginUntrackedLocked prepares for a change made outside of a write
+// transaction, which goes straight to the storage: the WAL is checkpointed and
+// emptied first, or the frames there would keep hiding the change. Other
+// connections would see the file change under them, so there must be none.
+func (p *Pager) beginUntrackedLocked() error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	if p.inTransaction {
+		return nil
+	}
+	if err := p.exclusiveLocked(); err != nil {
+		return err
+	}
+	return p.checkpointAllLocked()
+}
+
+// syncUntrackedLocked makes changes written outside of a transaction durable.
+// They never went through the WAL, so the storage is synced and the WAL
+// emptied, or recovery could replay older page images over them.
+func (p *Pager) syncUntrackedLocked() error {
+	if p.wal == nil {
+		return nil
+	}
+	if err := p.getStorage().Sync(); err != nil {
+		return err
+	}
+	p.walBackfilled = 0
+	return p.wal.Reset()
+}
+
+// Replace swaps the database contents for those of src, a database with the
+// same page size written by VACUUM. A file database takes over the file of src
+// by renaming it, which atomically replaces the old file on disk; the WAL is
+// emptied first so that none of its frames can be replayed onto the new
+// file. All cached pages are dropped, so callers must reopen any tree they
+// hold. src is closed by Replace and must not be used afterwards.
+func (p *Pager) Replace(src *Pager) error {
+	if src.pageSize != p.pageSize {
+		return fmt.Errorf("cannot replace a database with page size %d by one with page size %d", p.pageSize, src.pageSize)
+	}
+	if src.inMemory != p.inMemory {
+		return errors.New("cannot replace an in-memory database by a file database or the reverse")
+	}
+
+	// Detach the storage of src, which this pager takes over
+	src.mu.Lock()
+	src.writeHeader()
+	newStorage := src.getStorage()
+	srcPath := src.path
+	if src.wal != nil {
+		src.wal.Close()
+		src.wal = nil
+	}
+	if src.shm != nil {
+		src.shm.close()
+		src.shm = nil
+	}
+	src.storage = nil
+	src.mmap = nil
+	src.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inTransaction {
+		newStorage.Close()
+		return ErrTxAlreadyActive
+	}
+	if p.readOnly {
+		newStorage.Close()
+		return ErrReadOnly
+	}
+	if err := p.exclusiveLocked(); err != nil {
+		newStorage.Close()
+		return err
+	}
+
+	if p.inMemory {
+		p.storage.Close()
+		p.storage = newStorage
+		return p.reloadLocked()
+	}
+
+	if err := newStorage.Sync(); err != nil {
+		newStorage.Close()
+		return err
+	}
+	if err := newStorage.Close(); err != nil {
+		return err
+	}
+	os.Remove(srcPath + "-wal")
+	os.Remove(srcPath + "-shm")
+
+	if err := p.storage.Sync(); err != nil {
+		return err
+	}
+	if p.wal != nil {
+		if err := p.wal.Reset(); err != nil {
+			return err
+		}
+		p.walBackfilled = 0
+		p.walIndex.reset()
+	}
+	if err := p.storage.Close(); err != nil {
+		return err
+	}
+
+	// Whether or not the rename succeeds, reopen whatever file is now at path
+	renameErr := os.Rename(srcPath, p.path)
+	mf, err := OpenMmapFile(p.path, int64(p.pageSize))
+	if err != nil {
+		return err
+	}
+	p.storage = mf
+	p.mmap = mf
+	if err := p.reloadLocked(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+// reloadLocked drops every cached page and rereads the header and freelist
+// after the storage has been replaced
+func (p *Pager) reloadLocked() error {
+	for pageNo := range p.cache {
+		p.releaseCacheMemory(pageNo)
+	}
+	p.cache = make(map[uint32]*cacheEntry)
+	p.lru = list.New()
+	p.txPages = nil
+	p.txOrig = nil
+	return p.readHeaderLocked()
+}
@@ -2,6 +2,7 @@
 package pager
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -184,3 +185,180 @@ func TestPagerWALRecovery(t *testing.T) {
 	}
 	p2.Release(page2)
 }
+
+// copyFile copies src to dst, standing in for the state of a file at the
+// moment of a crash
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", dst, err)
+	}
+}
+
+func TestPagerTransactionDurability(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	p, err := Open(dbPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	page, err := p.Allocate()
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	pageNo := page.PageNo()
+	page.Data()[0] = 10
+	p.Release(page)
+	if err := p.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	tx, err := p.BeginWrite()
+	if err != nil {
+		t.Fatalf("BeginWrite failed: %v", err)
+	}
+	page, err = p.Get(pageNo)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	page.Data()[0] = 20
+	p.Release(page)
+	added, err := p.Allocate()
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	added.Data()[0] = 30
+	p.Release(added)
+
+	// Nothing reaches the database file before the commit
+	fileData, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("failed to read database file: %v", err)
+	}
+	if fileData[int(pageNo)*4096] != 10 {
+		t.Errorf("uncommitted change reached the database file")
+	}
+	crashDir := t.TempDir()
+	copyFile(t, dbPath, filepath.Join(crashDir, "before.db"))
+	copyFile(t, dbPath, filepath.Join(crashDir, "during.db"))
+	copyFile(t, dbPath+"-wal", filepath.Join(crashDir, "during.db-wal"))
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if p.wal.FrameCount() == 0 {
+		t.Fatal("expected the commit to write WAL frames")
+	}
+
+	// A crash after the commit, before the database file is written back,
+	// is recovered from the WAL
+	copyFile(t, dbPath+"-wal", filepath.Join(crashDir, "before.db-wal"))
+	recovered, err := Open(filepath.Join(crashDir, "before.db"), Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open crash image: %v", err)
+	}
+	defer recovered.Close()
+	if recovered.PageCount() != p.PageCount() {
+		t.Errorf("recovered %d pages, want %d", recovered.PageCount(), p.PageCount())
+	}
+	for no, want := range map[uint32]byte{pageNo: 20, added.PageNo(): 30} {
+		page, err := recovered.Get(no)
+		if err != nil {
+			t.Fatalf("Get %d after recovery failed: %v", no, err)
+		}
+		if page.Data()[0] != want {
+			t.Errorf("page %d: expected %d after recovery, got %d", no, want, page.Data()[0])
+		}
+		recovered.Release(page)
+	}
+
+	// A crash in the middle of the transaction keeps the old state
+	during, err := Open(filepath.Join(crashDir, "during.db"), Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open crash image: %v", err)
+	}
+	defer during.Close()
+	if during.PageCount() != pageNo+1 {
+		t.Errorf("expected %d pages without the uncommitted transaction, got %d", pageNo+1, during.PageCount())
+	}
+	page, err = during.Get(pageNo)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if page.Data()[0] != 10 {
+		t.Errorf("expected the committed value 10, got %d", page.Data()[0])
+	}
+	during.Release(page)
+}
+
+func TestPagerTransactionRollbackRestoresAllocation(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Open(filepath.Join(dir, "test.db"), Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var pages []uint32
+	for i := 0; i < 4; i++ {
+		page, err := p.Allocate()
+		if err != nil {
+			t.Fatalf("Allocate failed: %v", err)
+		}
+		page.Data()[0] = byte(i + 1)
+		pages = append(pages, page.PageNo())
+		p.Release(page)
+	}
+	if err := p.Free(pages[3]); err != nil {
+		t.Fatalf("Free failed: %v", err)
+	}
+	count, free := p.PageCount(), p.FreePageCount()
+
+	tx, err := p.BeginWrite()
+	if err != nil {
+		t.Fatalf("BeginWrite failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		page, err := p.Allocate()
+		if err != nil {
+			t.Fatalf("Allocate failed: %v", err)
+		}
+		page.Data()[0] = 99
+		p.Release(page)
+	}
+	if err := p.Free(pages[0]); err != nil {
+		t.Fatalf("Free failed: %v", err)
+	}
+	tx.Rollback()
+
+	if p.PageCount() != count || p.FreePageCount() != free {
+		t.Errorf("after rollback: %d pages, %d free; want %d, %d", p.PageCount(), p.FreePageCount(), count, free)
+	}
+	for i, pageNo := range pages[:3] {
+		page, err := p.Get(pageNo)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if page.Data()[0] != byte(i+1) {
+			t.Errorf("page %d: expected %d after rollback, got %d", pageNo, i+1, page.Data()[0])
+		}
+		p.Release(page)
+	}
+
+	// The freed page is handed out again, as before the transaction
+	page, err := p.Allocate()
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if page.PageNo() != pages[3] {
+		t.Errorf("expected to reuse page %d, got %d", pages[3], page.PageNo())
+	}
+	p.Release(page)
+}
@@ -0,0 +1,245 @@
+// pkg/pager/walindex.go
+package pager
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"tur/pkg/wal"
+)
+
+// ErrReadTxActive is returned by operations that would change pages that
+// readers of other connections still read from the WAL
+var ErrReadTxActive = errors.New("read transaction active")
+
+// walIndex maps each page committed to the WAL to the frames that hold its
+// versions. Commits only append frames, so the latest committed version of a
+// page is its last frame, and the version a reader sees is the last frame at
+// or below the frame count it pinned when it began.
+type walIndex struct {
+	// frames lists, per pager page number, the 1-based WAL frames holding
+	// the page, oldest first
+	frames map[uint32][]uint32
+}
+
+// add records that frame holds a new version of pageNo. Frames must be added
+// in increasing order.
+func (x *walIndex) add(pageNo, frame uint32) {
+	if x.frames == nil {
+		x.frames = make(map[uint32][]uint32)
+	}
+	x.frames[pageNo] = append(x.frames[pageNo], frame)
+}
+
+// latest returns the last frame holding pageNo, or 0 if the page is not in
+// the WAL
+func (x *walIndex) latest(pageNo uint32) uint32 {
+	frames := x.frames[pageNo]
+	if len(frames) == 0 {
+		return 0
+	}
+	return frames[len(frames)-1]
+}
+
+// lookup returns the last frame at or below mark holding pageNo, or 0 if the
+// snapshot ending at mark reads the page from the database file
+func (x *walIndex) lookup(pageNo, mark uint32) uint32 {
+	frames := x.frames[pageNo]
+	i := sort.Search(len(frames), func(i int) bool { return frames[i] > mark })
+	if i == 0 {
+		return 0
+	}
+	return frames[i-1]
+}
+
+// pages returns the page numbers in the index in ascending order
+func (x *walIndex) pages() []uint32 {
+	pages := make([]uint32, 0, len(x.frames))
+	for pageNo := range x.frames {
+		pages = append(pages, pageNo)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	return pages
+}
+
+// reset empties the index after the WAL has started over
+func (x *walIndex) reset() {
+	x.frames = nil
+}
+
+// loadWALIndexLocked builds the WAL index from the committed frames of the WAL
+// at open. Frames of a transaction that never committed are dropped. The
+// database file is left as it is: the pages are read from the WAL until the
+// next checkpoint.
+func (p *Pager) loadWALIndexLocked() error {
+	p.walIndex.reset()
+	frames, err := p.wal.DiscardUncommitted()
+	if err != nil || frames == 0 {
+		return err
+	}
+	return p.wal.ForEachFrame(func(frame *wal.Frame) error {
+		// WAL page numbers are 1-based, pager page numbers 0-based
+		p.walIndex.add(frame.PageNo-1, frame.Index)
+		return nil
+	})
+}
+
+// committedDataLocked returns the latest committed content of a page: the
+// content it had when the active write transaction began, else its last WAL
+// frame, else the database file. The result must not be modified; the storage
+// slice it may be is only valid until the storage is resized.
+func (p *Pager) committedDataLocked(pageNo uint32) ([]byte, error) {
+	if data, ok := p.txOrig[pageNo]; ok {
+		return data, nil
+	}
+	if frame := p.walIndex.latest(pageNo); frame != 0 {
+		return p.readFrameLocked(frame)
+	}
+	storage := p.getStorage()
+	if storage == nil {
+		return nil, ErrPageNotFound
+	}
+	data := storage.Slice(int(pageNo)*p.pageSize, p.pageSize)
+	if data == nil {
+		return nil, ErrPageNotFound
+	}
+	return data, nil
+}
+
+// readFrameLocked returns a new buffer holding the page stored in a WAL frame
+func (p *Pager) readFrameLocked(frame uint32) ([]byte, error) {
+	f, err := p.wal.ReadFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
+
+// backfillLocked copies into the database file, for every page in the WAL,
+// its last version at or below frame limit, then syncs the file. Frames below
+// walBackfilled are in the file already.
+func (p *Pager) backfillLocked(limit uint32) error {
+	storage := p.getStorage()
+	for _, pageNo := range p.walIndex.pages() {
+		frame := p.walIndex.lookup(pageNo, limit)
+		if frame <= p.walBackfilled {
+			continue
+		}
+		data, err := p.readFrameLocked(frame)
+		if err != nil {
+			return err
+		}
+		if required := int64(pageNo+1) * int64(p.pageSize); required > storage.Size() {
+			if err := storage.Grow(required); err != nil {
+				return err
+			}
+			p.refreshCacheAfterGrow()
+		}
+		copy(storage.Slice(int(pageNo)*p.pageSize, p.pageSize), data)
+	}
+	return storage.Sync()
+}
+
+// readLimitLocked returns the frame count up to which the WAL can be copied
+// into the database file without changing a page under an active reader of
+// another connection
+func (p *Pager) readLimitLocked() uint32 {
+	limit := p.wal.FrameCount()
+	if p.shm != nil {
+		limit = p.shm.readLimit(limit)
+	}
+	return limit
+}
+
+// restartWALLocked starts the WAL over once the database file holds every
+// frame, truncating the file if truncate is set. Readers keep reading the same
+// content, from the file from then on, and cached pages read from the WAL are
+// pointed back at the storage. Readers of other connections cannot, so while
+// one of them reads frames it fails with ErrReadTxActive.
+func (p *Pager) restartWALLocked(truncate bool) error {
+	if p.shm != nil {
+		if err := p.shm.wait(shmLockHeader, true); err != nil {
+			return err
+		}
+		defer p.shm.unlock(shmLockHeader)
+		if p.shm.readersInWAL() {
+			return ErrReadTxActive
+		}
+	}
+
+	var err error
+	if truncate {
+		err = p.wal.Reset()
+	} else {
+		err = p.wal.Restart()
+	}
+	if err != nil {
+		return err
+	}
+	p.walBackfilled = 0
+	p.walIndex.reset()
+	p.refreshCacheAfterGrow()
+	if p.shm != nil {
+		p.shmStale = true
+		p.shareLocked(0, nil)
+	}
+	return nil
+}
+
+// checkpointAllLocked copies every committed frame into the database file and
+// empties the WAL, so that the file can be changed in place by writes made
+// outside of a transaction. It fails with ErrReadTxActive while a reader still
+// needs frames that are not in the file.
+func (p *Pager) checkpointAllLocked() error {
+	if p.wal == nil || p.wal.FrameCount() == 0 {
+		return nil
+	}
+	frames := p.wal.FrameCount()
+	if p.readLimitLocked() < frames {
+		return ErrReadTxActive
+	}
+	if frames > p.walBackfilled {
+		if err := p.backfillLocked(frames); err != nil {
+			return err
+		}
+		p.walBackfilled = frames
+	}
+	return p.restartWALLocked(true)
+}
+
+// readHeaderLocked rereads the page count, auto-vacuum mode and freelist from
+// the latest committed header
+func (p *Pager) readHeaderLocked() error {
+	header, err := p.committedDataLocked(0)
+	if err != nil {
+		return err
+	}
+	p.pageCount = binary.LittleEndian.Uint32(header[20:24])
+	p.autoVacuum = AutoVacuumMode(binary.LittleEndian.Uint32(header[offsetAutoVacuum:]))
+	p.freelist = NewFreelist(p.pageSize)
+	return p.loadFreelistLocked(GetFreelistHead(header), GetFreePageCount(header))
+}
+
+// loadFreelistLocked loads the freelist trunk pages from their latest
+// committed content
+func (p *Pager) loadFreelistLocked(headPage uint32, freeCount uint32) error {
+	if headPage == 0 || freeCount == 0 {
+		return nil
+	}
+
+	p.freelist.trunks = nil
+	p.freelist.headPage = headPage
+	p.freelist.freeCount = freeCount
+
+	for trunkPage := headPage; trunkPage != 0; {
+		data, err := p.committedDataLocked(trunkPage)
+		if err != nil {
+			return err
+		}
+		trunk := DecodeFreelistTrunkPage(data)
+		p.freelist.trunks = append(p.freelist.trunks, trunk)
+		trunkPage = trunk.NextTrunk
+	}
+	return nil
+}
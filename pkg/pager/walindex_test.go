@@ -0,0 +1,84 @@
+// pkg/pager/walindex_test.go
+package pager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sameFileContent reports whether a database file still holds the content it
+// had before; growing it with zeroed pages for new ones does not count
+func sameFileContent(before, after []byte) bool {
+	if len(after) < len(before) || !bytes.Equal(before, after[:len(before)]) {
+		return false
+	}
+	return bytes.Count(after[len(before):], []byte{0}) == len(after)-len(before)
+}
+
+func TestPagerCommitOnlyAppendsToWAL(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	p, err := Open(dbPath, Options{PageSize: 4096, WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+	for i := 1; i <= 3; i++ {
+		commitPage(t, p, uint32(i), byte(i))
+	}
+
+	before, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitPage(t, p, 2, 20)
+	commitPage(t, p, 4, 40)
+	after, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameFileContent(before, after) {
+		t.Error("commit changed the database file")
+	}
+
+	// A fresh pager on the same files reads the new pages from the WAL
+	crashPath := filepath.Join(t.TempDir(), "crash.db")
+	copyFile(t, dbPath, crashPath)
+	copyFile(t, dbPath+"-wal", crashPath+"-wal")
+	cp, err := Open(crashPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open copy: %v", err)
+	}
+	defer cp.Close()
+	if cp.PageCount() != 5 {
+		t.Errorf("expected 5 pages from the WAL header, got %d", cp.PageCount())
+	}
+	for pageNo, want := range map[uint32]byte{1: 1, 2: 20, 3: 3, 4: 40} {
+		page, err := cp.Get(pageNo)
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", pageNo, err)
+		}
+		if page.Data()[0] != want {
+			t.Errorf("page %d holds %d, want %d", pageNo, page.Data()[0], want)
+		}
+		cp.Release(page)
+	}
+	if copied, err := os.ReadFile(crashPath); err != nil || !sameFileContent(before, copied) {
+		t.Errorf("opening the database changed its file: %v", err)
+	}
+
+	// The checkpoint is what writes the file
+	if _, err := p.Checkpoint(CheckpointPassive); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	after, err = os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after[2*4096] != 20 || after[4*4096] != 40 {
+		t.Errorf("checkpoint did not copy the pages: %d, %d", after[2*4096], after[4*4096])
+	}
+}
@@ -3,6 +3,7 @@ package schema
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -175,11 +176,41 @@ func (m DistanceMetric) String() string {
 	}
 }
 
+// VectorQuantization selects how an HNSW index stores vectors
+type VectorQuantization int
+
+const (
+	// QuantizationNone stores full-precision vectors
+	QuantizationNone VectorQuantization = iota
+	// QuantizationInt8 stores one byte per dimension (scalar quantization)
+	QuantizationInt8
+	// QuantizationPQ stores one byte per subvector (product quantization)
+	QuantizationPQ
+)
+
+// String returns the string representation of the quantization
+func (q VectorQuantization) String() string {
+	switch q {
+	case QuantizationNone:
+		return "none"
+	case QuantizationInt8:
+		return "int8"
+	case QuantizationPQ:
+		return "pq"
+	default:
+		return "unknown"
+	}
+}
+
 // HNSWParams holds HNSW-specific index parameters
 type HNSWParams struct {
-	M              int            // Maximum number of connections per node (default: 16)
-	EfConstruction int            // Size of the dynamic candidate list during construction (default: 200)
-	DistanceMetric DistanceMetric // Distance metric to use (default: Cosine)
+	M              int                // Maximum number of connections per node (default: 16)
+	EfConstruction int                // Size of the dynamic candidate list during construction (default: 200)
+	DistanceMetric DistanceMetric     // Distance metric to use (default: Cosine)
+	UseHeuristic   bool               // Use heuristic neighbor selection (default: false)
+	Quantization   VectorQuantization // Vector storage (default: None)
+	PQSegments     int                // Number of PQ subvectors (0 = derived from the dimension)
+	Rerank         bool               // Re-rank quantized results against the full-precision column
 }
 
 // DefaultHNSWParams returns HNSW parameters with SQLite vec extension defaults
@@ -191,6 +222,19 @@ func DefaultHNSWParams() *HNSWParams {
 	}
 }
 
+// QuantizationOptions returns the CREATE INDEX ... WITH options describing the
+// quantization, with a leading ", ", or "" for a full-precision index
+func (p *HNSWParams) QuantizationOptions() string {
+	switch p.Quantization {
+	case QuantizationNone:
+		return ""
+	case QuantizationPQ:
+		return fmt.Sprintf(", quantization='%s', pq_segments=%d, rerank=%t", p.Quantization, p.PQSegments, p.Rerank)
+	default:
+		return fmt.Sprintf(", quantization='%s', rerank=%t", p.Quantization, p.Rerank)
+	}
+}
+
 // IndexDef defines an index schema
 type IndexDef struct {
 	Name        string      // Index name
@@ -251,6 +295,15 @@ func (c *ColumnDef) GetConstraint(ct ConstraintType) *Constraint {
 	return nil
 }
 
+// DefaultValue returns the value of the column's DEFAULT constraint, or NULL
+// if it has none
+func (c *ColumnDef) DefaultValue() types.Value {
+	if d := c.GetConstraint(ConstraintDefault); d != nil && d.DefaultValue != nil {
+		return *d.DefaultValue
+	}
+	return types.NewNull()
+}
+
 // TableDef defines a table schema
 type TableDef struct {
 	Name             string
@@ -405,6 +458,16 @@ func (t *TableDef) ColumnCount() int {
 	return len(t.Columns)
 }
 
+// FillMissingColumns completes a row stored before columns were added to the
+// table. ADD COLUMN leaves the rows as they are, so the columns they lack
+// read as their defaults.
+func (t *TableDef) FillMissingColumns(row []types.Value) []types.Value {
+	for i := len(row); i < len(t.Columns); i++ {
+		row = append(row, t.Columns[i].DefaultValue())
+	}
+	return row
+}
+
 // ViewDef defines a view schema
 type ViewDef struct {
 	Name    string   // View name
@@ -486,6 +549,105 @@ func NewCatalog() *Catalog {
 	}
 }
 
+// CatalogSnapshot holds the contents of a catalog at one point in time, so
+// that a rolled back transaction can bring them back
+type CatalogSnapshot struct {
+	tables     map[string]*TableDef
+	tableDefs  map[string]TableDef
+	indexes    map[string]*IndexDef
+	indexDefs  map[string]IndexDef
+	views      map[string]*ViewDef
+	triggers   map[string]*TriggerDef
+	procedures map[string]*ProcedureDef
+	statistics map[string]*TableStatistics
+}
+
+// Snapshot captures the contents of the catalog. Table and index definitions
+// are copied, since statements update them in place; views, triggers,
+// procedures and statistics are only ever replaced, so they are shared.
+func (c *Catalog) Snapshot() *CatalogSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := &CatalogSnapshot{
+		tables:     make(map[string]*TableDef, len(c.tables)),
+		tableDefs:  make(map[string]TableDef, len(c.tables)),
+		indexes:    make(map[string]*IndexDef, len(c.indexes)),
+		indexDefs:  make(map[string]IndexDef, len(c.indexes)),
+		views:      make(map[string]*ViewDef, len(c.views)),
+		triggers:   make(map[string]*TriggerDef, len(c.triggers)),
+		procedures: make(map[string]*ProcedureDef, len(c.procedures)),
+		statistics: make(map[string]*TableStatistics, len(c.statistics)),
+	}
+	for name, table := range c.tables {
+		def := *table
+		def.Columns = append([]ColumnDef(nil), table.Columns...)
+		def.TableConstraints = append([]TableConstraint(nil), table.TableConstraints...)
+		s.tables[name] = table
+		s.tableDefs[name] = def
+	}
+	for name, index := range c.indexes {
+		def := *index
+		def.Columns = append([]string(nil), index.Columns...)
+		def.Expressions = append([]string(nil), index.Expressions...)
+		s.indexes[name] = index
+		s.indexDefs[name] = def
+	}
+	for name, view := range c.views {
+		s.views[name] = view
+	}
+	for name, trigger := range c.triggers {
+		s.triggers[name] = trigger
+	}
+	for name, proc := range c.procedures {
+		s.procedures[name] = proc
+	}
+	for name, stats := range c.statistics {
+		s.statistics[name] = stats
+	}
+	return s
+}
+
+// Restore brings the catalog back to the contents of a snapshot. Table and
+// index definitions keep their identity, so pointers obtained before the
+// snapshot see the restored contents.
+func (c *Catalog) Restore(s *CatalogSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tables = make(map[string]*TableDef, len(s.tables))
+	for name, table := range s.tables {
+		*table = s.tableDefs[name]
+		table.Columns = append([]ColumnDef(nil), table.Columns...)
+		table.TableConstraints = append([]TableConstraint(nil), table.TableConstraints...)
+		table.BuildColumnCache()
+		c.tables[name] = table
+	}
+	c.indexes = make(map[string]*IndexDef, len(s.indexes))
+	for name, index := range s.indexes {
+		*index = s.indexDefs[name]
+		index.Columns = append([]string(nil), index.Columns...)
+		index.Expressions = append([]string(nil), index.Expressions...)
+		c.indexes[name] = index
+	}
+	c.views = make(map[string]*ViewDef, len(s.views))
+	for name, view := range s.views {
+		c.views[name] = view
+	}
+	c.triggers = make(map[string]*TriggerDef, len(s.triggers))
+	for name, trigger := range s.triggers {
+		c.triggers[name] = trigger
+	}
+	c.procedures = make(map[string]*ProcedureDef, len(s.procedures))
+	for name, proc := range s.procedures {
+		c.procedures[name] = proc
+	}
+	c.statistics = make(map[string]*TableStatistics, len(s.statistics))
+	for name, stats := range s.statistics {
+		c.statistics[name] = stats
+	}
+}
+
 // CreateTable adds a table to the catalog
 func (c *Catalog) CreateTable(table *TableDef) error {
 	c.mu.Lock()
@@ -570,6 +732,57 @@ func (c *Catalog) DropColumn(tableName, columnName string) error {
 	return nil
 }
 
+// RenameColumn renames a column of an existing table
+func (c *Catalog) RenameColumn(tableName, oldName, newName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, exists := c.tables[tableName]
+	if !exists {
+		return ErrTableNotFound
+	}
+
+	found := -1
+	for i, col := range table.Columns {
+		if col.Name == newName {
+			return ErrColumnExists
+		}
+		if col.Name == oldName {
+			found = i
+		}
+	}
+	if found < 0 {
+		return ErrColumnNotFound
+	}
+
+	table.Columns[found].Name = newName
+	// Rebuild column cache after modification
+	table.BuildColumnCache()
+	return nil
+}
+
+// ReplaceColumn replaces the definition of the column of an existing table
+// that has the name of column
+func (c *Catalog) ReplaceColumn(tableName string, column ColumnDef) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, exists := c.tables[tableName]
+	if !exists {
+		return ErrTableNotFound
+	}
+
+	for i := range table.Columns {
+		if table.Columns[i].Name == column.Name {
+			table.Columns[i] = column
+			// Rebuild column cache after modification
+			table.BuildColumnCache()
+			return nil
+		}
+	}
+	return ErrColumnNotFound
+}
+
 // RenameTable renames a table
 func (c *Catalog) RenameTable(oldName, newName string) error {
 	c.mu.Lock()
@@ -166,6 +166,40 @@ func TestCatalog_DropTable_NotExists(t *testing.T) {
 	}
 }
 
+func TestCatalog_SnapshotRestore(t *testing.T) {
+	catalog := NewCatalog()
+
+	users := &TableDef{Name: "users", Columns: []ColumnDef{{Name: "id", Type: types.TypeInt32}}, RootPage: 2}
+	catalog.CreateTable(users)
+	catalog.CreateIndex(&IndexDef{Name: "idx_id", TableName: "users", Columns: []string{"id"}, RootPage: 3})
+
+	snapshot := catalog.Snapshot()
+
+	// Changes made after the snapshot
+	catalog.AddColumn("users", ColumnDef{Name: "name", Type: types.TypeText})
+	users.RootPage = 7
+	catalog.DropIndex("idx_id")
+	catalog.CreateTable(&TableDef{Name: "orders", Columns: []ColumnDef{{Name: "id", Type: types.TypeInt32}}})
+
+	catalog.Restore(snapshot)
+
+	if catalog.GetTable("orders") != nil {
+		t.Error("Restore: table created after the snapshot still exists")
+	}
+	if got := catalog.GetTable("users"); got != users {
+		t.Error("Restore: expected the same table definition")
+	}
+	if len(users.Columns) != 1 || users.RootPage != 2 {
+		t.Errorf("Restore: got %d columns and root page %d, want 1 and 2", len(users.Columns), users.RootPage)
+	}
+	if _, idx := users.GetColumn("name"); idx != -1 {
+		t.Error("Restore: added column still found")
+	}
+	if idx := catalog.GetIndex("idx_id"); idx == nil || idx.RootPage != 3 {
+		t.Errorf("Restore: expected the dropped index back, got %+v", idx)
+	}
+}
+
 func TestCatalog_ListTables(t *testing.T) {
 	catalog := NewCatalog()
 
@@ -1030,6 +1064,70 @@ func TestCatalog_DropColumn_ColumnNotFound(t *testing.T) {
 	}
 }
 
+func TestCatalog_RenameColumn(t *testing.T) {
+	catalog := NewCatalog()
+	catalog.CreateTable(&TableDef{
+		Name: "users",
+		Columns: []ColumnDef{
+			{Name: "id", Type: types.TypeInt32},
+			{Name: "name", Type: types.TypeText},
+		},
+	})
+
+	if err := catalog.RenameColumn("users", "name", "full_name"); err != nil {
+		t.Fatalf("RenameColumn: %v", err)
+	}
+	table := catalog.GetTable("users")
+	if table.GetColumnIndex("full_name") != 1 || table.GetColumnIndex("name") != -1 {
+		t.Errorf("columns after rename: %+v", table.Columns)
+	}
+
+	if err := catalog.RenameColumn("users", "full_name", "id"); err != ErrColumnExists {
+		t.Errorf("Error = %v, want ErrColumnExists", err)
+	}
+	if err := catalog.RenameColumn("users", "name", "other"); err != ErrColumnNotFound {
+		t.Errorf("Error = %v, want ErrColumnNotFound", err)
+	}
+}
+
+func TestCatalog_ReplaceColumn(t *testing.T) {
+	catalog := NewCatalog()
+	catalog.CreateTable(&TableDef{
+		Name: "users",
+		Columns: []ColumnDef{
+			{Name: "id", Type: types.TypeInt32},
+			{Name: "age", Type: types.TypeInt32},
+		},
+	})
+
+	if err := catalog.ReplaceColumn("users", ColumnDef{Name: "age", Type: types.TypeBigInt}); err != nil {
+		t.Fatalf("ReplaceColumn: %v", err)
+	}
+	if col, idx := catalog.GetTable("users").GetColumn("age"); idx != 1 || col.Type != types.TypeBigInt {
+		t.Errorf("age = %+v at %d, want BIGINT at 1", col, idx)
+	}
+	if err := catalog.ReplaceColumn("users", ColumnDef{Name: "email"}); err != ErrColumnNotFound {
+		t.Errorf("Error = %v, want ErrColumnNotFound", err)
+	}
+}
+
+func TestTableDef_FillMissingColumns(t *testing.T) {
+	zero := types.NewInt(0)
+	table := &TableDef{
+		Name: "users",
+		Columns: []ColumnDef{
+			{Name: "id", Type: types.TypeInt32},
+			{Name: "score", Type: types.TypeInt32, Constraints: []Constraint{{Type: ConstraintDefault, DefaultValue: &zero}}},
+			{Name: "note", Type: types.TypeText},
+		},
+	}
+
+	row := table.FillMissingColumns([]types.Value{types.NewInt(7)})
+	if len(row) != 3 || row[0].Int() != 7 || row[1].Int() != 0 || !row[2].IsNull() {
+		t.Errorf("FillMissingColumns = %v, want [7 0 NULL]", row)
+	}
+}
+
 func TestCatalog_RenameTable(t *testing.T) {
 	catalog := NewCatalog()
 	catalog.CreateTable(&TableDef{
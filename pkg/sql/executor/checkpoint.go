@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"fmt"
+
+	"tur/pkg/pager"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// pragmaWALCheckpoint runs PRAGMA wal_checkpoint[(PASSIVE|FULL|RESTART|TRUNCATE)],
+// passive by default. Like SQLite it returns a single row: whether the mode
+// could not complete because a write transaction is active, the number of
+// frames copied into the database file and the number still waiting.
+func (e *Executor) pragmaWALCheckpoint(stmt *parser.PragmaStmt) (*Result, error) {
+	mode := pager.CheckpointPassive
+	if stmt.Value != nil {
+		val, err := e.evaluateExpr(stmt.Value, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wal_checkpoint mode: %w", err)
+		}
+		if val.Type() != types.TypeText {
+			return nil, fmt.Errorf("wal_checkpoint mode must be PASSIVE, FULL, RESTART or TRUNCATE, got %v", val)
+		}
+		mode, err = pager.ParseCheckpointMode(val.Text())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := e.pager.Checkpoint(mode)
+	if err != nil {
+		return nil, err
+	}
+	busy := int64(0)
+	if result.Busy {
+		busy = 1
+	}
+	return &Result{
+		Columns: []string{"busy", "frames_written", "frames_remaining"},
+		Rows: [][]types.Value{
+			{types.NewInt(busy), types.NewInt(int64(result.FramesWritten)), types.NewInt(int64(result.FramesRemaining))},
+		},
+	}, nil
+}
+
+// pragmaWALAutoCheckpoint gets or sets the number of WAL frames after which a
+// commit runs a passive checkpoint (PRAGMA wal_autocheckpoint). A value of
+// zero or less turns automatic checkpoints off.
+func (e *Executor) pragmaWALAutoCheckpoint(stmt *parser.PragmaStmt) (*Result, error) {
+	if stmt.Value != nil {
+		val, err := e.evaluateExpr(stmt.Value, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wal_autocheckpoint value: %w", err)
+		}
+		if !types.IsIntegerType(val.Type()) {
+			return nil, fmt.Errorf("wal_autocheckpoint must be an integer, got %v", val)
+		}
+		e.pager.SetWALAutoCheckpoint(int(val.Int()))
+		return &Result{RowsAffected: 0}, nil
+	}
+
+	return &Result{
+		Columns: []string{"wal_autocheckpoint"},
+		Rows: [][]types.Value{
+			{types.NewInt(int64(e.pager.WALAutoCheckpoint()))},
+		},
+	}, nil
+}
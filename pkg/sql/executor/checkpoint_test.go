@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tur/pkg/pager"
+	"tur/pkg/wal"
+)
+
+func TestPragmaWALCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	p, err := pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	if _, err := exec.Execute("PRAGMA wal_autocheckpoint = 0"); err != nil {
+		t.Fatalf("PRAGMA wal_autocheckpoint failed: %v", err)
+	}
+	if _, err := exec.Execute("CREATE TABLE items (id INT PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	for i := 1; i <= 20; i++ {
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO items VALUES (%d, 'item %d')", i, i)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+
+	result, err := exec.Execute("PRAGMA wal_checkpoint(TRUNCATE)")
+	if err != nil {
+		t.Fatalf("PRAGMA wal_checkpoint failed: %v", err)
+	}
+	if len(result.Columns) != 3 || result.Columns[1] != "frames_written" || result.Columns[2] != "frames_remaining" {
+		t.Fatalf("unexpected columns %v", result.Columns)
+	}
+	row := result.Rows[0]
+	if row[0].Int() != 0 || row[1].Int() < 20 || row[2].Int() != 0 {
+		t.Errorf("expected at least 20 frames written and none remaining, got %v", row)
+	}
+	if info, err := os.Stat(path + "-wal"); err != nil || info.Size() != wal.HeaderSize {
+		t.Errorf("expected an empty WAL file: %v, %v", info, err)
+	}
+
+	// Nothing left to copy; without a mode the checkpoint is passive
+	result, err = exec.Execute("PRAGMA wal_checkpoint")
+	if err != nil {
+		t.Fatalf("PRAGMA wal_checkpoint failed: %v", err)
+	}
+	if got := result.Rows[0][1].Int(); got != 0 {
+		t.Errorf("expected no frames written, got %d", got)
+	}
+
+	// Inside a transaction only a passive checkpoint completes
+	if _, err := exec.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := exec.Execute("INSERT INTO items VALUES (100, 'pending')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	result, err = exec.Execute("PRAGMA wal_checkpoint(FULL)")
+	if err != nil {
+		t.Fatalf("PRAGMA wal_checkpoint(FULL) failed: %v", err)
+	}
+	if result.Rows[0][0].Int() != 1 {
+		t.Errorf("expected a busy checkpoint during a transaction, got %v", result.Rows[0])
+	}
+	if _, err := exec.Execute("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	if got := countTxRows(t, exec, "items"); got != 21 {
+		t.Errorf("expected 21 rows, got %d", got)
+	}
+
+	if _, err := exec.Execute("PRAGMA wal_checkpoint(SOMETIMES)"); err == nil {
+		t.Error("expected an unknown checkpoint mode to fail")
+	}
+}
+
+func TestPragmaWALAutoCheckpoint(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	result, err := exec.Execute("PRAGMA wal_autocheckpoint")
+	if err != nil {
+		t.Fatalf("PRAGMA wal_autocheckpoint failed: %v", err)
+	}
+	if got := result.Rows[0][0].Int(); got != pager.DefaultWALAutoCheckpoint {
+		t.Errorf("expected the default %d, got %d", pager.DefaultWALAutoCheckpoint, got)
+	}
+
+	if _, err := exec.Execute("PRAGMA wal_autocheckpoint = 10"); err != nil {
+		t.Fatalf("PRAGMA wal_autocheckpoint = 10 failed: %v", err)
+	}
+	if _, err := exec.Execute("CREATE TABLE items (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	for i := 1; i <= 200; i++ {
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO items VALUES (%d)", i)); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	// Without a checkpoint every insert would still be in the WAL
+	result, err = exec.Execute("PRAGMA wal_checkpoint")
+	if err != nil {
+		t.Fatalf("PRAGMA wal_checkpoint failed: %v", err)
+	}
+	if got := result.Rows[0][1].Int(); got >= 200 {
+		t.Errorf("expected automatic checkpoints to have copied most frames, %d were left", got)
+	}
+
+	if _, err := exec.Execute("PRAGMA wal_autocheckpoint = 'often'"); err == nil {
+		t.Error("expected a non-integer wal_autocheckpoint to fail")
+	}
+}
@@ -2,9 +2,12 @@ package executor
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"tur/pkg/pager"
-	// "tur/pkg/types"
+	"tur/pkg/types"
+	"tur/pkg/vdbe"
 )
 
 func TestExecutor_Join_EndToEnd(t *testing.T) {
@@ -98,6 +101,39 @@ func mustExec(t *testing.T, exec *Executor, sql string) {
 	}
 }
 
+// queryRows runs a query and renders its rows as "a,b;c,d", with vectors and
+// JSON values written as JSON text
+func queryRows(t *testing.T, exec *Executor, sql string) string {
+	t.Helper()
+	result, err := exec.Execute(sql)
+	if err != nil {
+		t.Fatalf("query failed (%s): %v", sql, err)
+	}
+	toJSON := vdbe.DefaultFunctionRegistry().Lookup("VECTOR_TO_JSON")
+	rows := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		values := make([]string, len(row))
+		for j, v := range row {
+			switch {
+			case v.IsNull():
+				values[j] = "NULL"
+			case types.IsIntegerType(v.Type()):
+				values[j] = strconv.FormatInt(v.Int(), 10)
+			case v.Type() == types.TypeFloat:
+				values[j] = strconv.FormatFloat(v.Float(), 'f', -1, 64)
+			case v.Type() == types.TypeBlob:
+				values[j] = toJSON.Call([]types.Value{v}).JSON()
+			case v.Type() == types.TypeJSON:
+				values[j] = v.JSON()
+			default:
+				values[j] = v.Text()
+			}
+		}
+		rows[i] = strings.Join(values, ",")
+	}
+	return strings.Join(rows, ";")
+}
+
 func TestExecutor_BacktickEscapedKeywords(t *testing.T) {
 	// Test that reserved keywords can be used as identifiers when backtick-escaped
 	tmpFile := "test_backtick.db"
@@ -2,6 +2,7 @@
 package executor
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"strings"
@@ -40,7 +41,9 @@ type Executor struct {
 	maxRowid    map[string]int64        // table name -> max INT PRIMARY KEY value (for AUTOINCREMENT)
 	txManager   *mvcc.TransactionManager
 	currentTx   *mvcc.Transaction      // current active transaction (nil if none)
-	hnswIndexes map[string]*hnsw.Index // HNSW index name -> index
+	versions    *mvcc.SnapshotStore    // row versions kept for the snapshots of active transactions
+	writeTx     *writeTx               // active pager write transaction (nil if none)
+	hnswIndexes map[string]hnsw.VectorIndex // HNSW index name -> index
 	queryCache  *cache.QueryCache      // optional query result cache
 	schemaBTree tree.ExtendedTree      // schema metadata B-tree (page 1)
 	// valuesContext holds the would-be-inserted values for VALUES() function
@@ -48,12 +51,19 @@ type Executor struct {
 	valuesContext map[string]types.Value
 	// sessionVars holds session-level variables (@var)
 	sessionVars map[string]types.Value
+	// params holds the values bound to the placeholders of the statement
+	// being executed (ExecuteAST)
+	params []types.Value
+	// ctx is the context of the statement being executed (ExecuteASTContext),
+	// nil if it cannot be canceled
+	ctx context.Context
 	// VDBE configuration
 	vdbeMaxRegisters int  // default register count for VDBE VMs
 	vdbeMaxCursors   int  // default cursor count for VDBE VMs
 	resultStreaming  bool // enable streaming result mode
-	// Reusable buffers to avoid allocations in hot paths
-	keyBuffer [8]byte // Reusable key buffer for PK lookups
+	// hnswEfSearch is the session default ef_search for vector searches (PRAGMA hnsw_ef_search).
+	// 0 means each index uses its own configured EfSearch.
+	hnswEfSearch int
 }
 
 // New creates a new Executor with classic B+ tree (default)
@@ -80,6 +90,7 @@ func NewWithTreeType(p *pager.Pager, treeType tree.TreeType) *Executor {
 		vdbeMaxRegisters: 16, // default (matches VDBE VM default)
 		vdbeMaxCursors:   8,  // default (matches VDBE VM default)
 	}
+	e.versions = mvcc.NewSnapshotStore(e.txManager)
 
 	// Initialize schema B-tree on page 1
 	if err := e.initSchemaBTree(); err != nil {
@@ -90,14 +101,22 @@ func NewWithTreeType(p *pager.Pager, treeType tree.TreeType) *Executor {
 	return e
 }
 
-// Close closes the executor and syncs data
+// Close closes the executor and syncs data. An unfinished write transaction
+// is rolled back.
 func (e *Executor) Close() error {
+	if e.writeTx != nil {
+		_ = e.rollbackWrite()
+	}
+
 	// Sync all btree root pages to schema entries before closing
-	// This ensures root page changes from splits are persisted
-	if err := e.syncAllRootPages(); err != nil {
-		// Log error but continue with close
-		// The database file might be corrupted if this fails
-		_ = err
+	// This ensures root page changes from splits are persisted. Committed
+	// pages may be in the WAL, so the change goes through a transaction.
+	if err := e.BeginWrite(); err == nil {
+		if err := e.CommitWrite(); err != nil {
+			// Log error but continue with close
+			// The database file might be corrupted if this fails
+			_ = e.rollbackWrite()
+		}
 	}
 	return e.pager.Close()
 }
@@ -169,77 +188,17 @@ func (e *Executor) Execute(sql string) (*Result, error) {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
 
-	switch s := stmt.(type) {
-	case *parser.CreateTableStmt:
-		return e.executeCreateTable(s)
-	case *parser.DropTableStmt:
-		return e.executeDropTable(s)
-	case *parser.CreateIndexStmt:
-		return e.executeCreateIndex(s)
-	case *parser.DropIndexStmt:
-		return e.executeDropIndex(s)
-	case *parser.InsertStmt:
-		return e.executeInsert(s)
-	case *parser.SelectStmt:
-		return e.executeSelect(s)
-	case *parser.UpdateStmt:
-		return e.executeUpdate(s)
-	case *parser.DeleteStmt:
-		return e.executeDelete(s)
-	case *parser.TruncateStmt:
-		return e.executeTruncate(s)
-	case *parser.AnalyzeStmt:
-		return e.executeAnalyze(s)
-	case *parser.AlterTableStmt:
-		return e.executeAlterTable(s)
-	case *parser.BeginStmt:
-		return e.executeBegin(s)
-	case *parser.CommitStmt:
-		return e.executeCommit(s)
-	case *parser.RollbackStmt:
-		return e.executeRollback(s)
-	case *parser.SavepointStmt:
-		return e.executeSavepoint(s)
-	case *parser.RollbackToStmt:
-		return e.executeRollbackTo(s)
-	case *parser.ReleaseStmt:
-		return e.executeRelease(s)
-	case *parser.SetOperation:
-		return e.executeSetOperation(s)
-	case *parser.CreateViewStmt:
-		return e.executeCreateView(s)
-	case *parser.DropViewStmt:
-		return e.executeDropView(s)
-	case *parser.ExplainStmt:
-		return e.executeExplain(s)
-	case *parser.CreateTriggerStmt:
-		return e.executeCreateTrigger(s)
-	case *parser.DropTriggerStmt:
-		return e.executeDropTrigger(s)
-	case *parser.IfStmt:
-		return e.executeIfStmt(s)
-	case *parser.CreateProcedureStmt:
-		return e.executeCreateProcedure(s)
-	case *parser.DropProcedureStmt:
-		return e.executeDropProcedure(s)
-	case *parser.CallStmt:
-		return e.executeCall(s)
-	case *parser.SetStmt:
-		return e.executeSetStmt(s)
-	case *parser.PragmaStmt:
-		return e.executePragma(s)
-	default:
-		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
-	}
+	return e.executeStatement(stmt)
 }
 
-// ExecuteAST executes a pre-parsed AST with parameter values.
-// This is used by prepared statements to skip the parsing step.
-func (e *Executor) ExecuteAST(stmt parser.Statement, params []types.Value) (*Result, error) {
-	// Substitute placeholder values in the AST
-	substitutedStmt := e.substituteParams(stmt, params)
+// ExecuteStatement executes a parsed statement
+func (e *Executor) ExecuteStatement(stmt parser.Statement) (*Result, error) {
+	return e.executeStatement(stmt)
+}
 
-	switch s := substitutedStmt.(type) {
+// dispatch executes a parsed statement according to its type
+func (e *Executor) dispatch(stmt parser.Statement) (*Result, error) {
+	switch s := stmt.(type) {
 	case *parser.CreateTableStmt:
 		return e.executeCreateTable(s)
 	case *parser.DropTableStmt:
@@ -298,151 +257,99 @@ func (e *Executor) ExecuteAST(stmt parser.Statement, params []types.Value) (*Res
 		return e.executeSetStmt(s)
 	case *parser.PragmaStmt:
 		return e.executePragma(s)
+	case *parser.VacuumStmt:
+		return e.executeVacuum(s)
 	default:
 		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
 	}
 }
 
-// substituteParams creates a copy of the statement with Placeholder nodes replaced by Literal nodes
-func (e *Executor) substituteParams(stmt parser.Statement, params []types.Value) parser.Statement {
-	switch s := stmt.(type) {
-	case *parser.SelectStmt:
-		return e.substituteSelectParams(s, params)
-	case *parser.InsertStmt:
-		return e.substituteInsertParams(s, params)
-	case *parser.UpdateStmt:
-		return e.substituteUpdateParams(s, params)
-	case *parser.DeleteStmt:
-		return e.substituteDeleteParams(s, params)
-	default:
-		// For other statements, return as-is (no parameter substitution needed)
-		return stmt
-	}
+// ExecuteAST executes a pre-parsed AST with parameter values.
+// This is used by prepared statements to skip the parsing step. The
+// placeholders stay in the AST and evaluate to params, so that the same AST
+// is executed with other values next time. A @name parameter past the end of
+// params reads the session variable of that name.
+func (e *Executor) ExecuteAST(stmt parser.Statement, params []types.Value) (*Result, error) {
+	return e.ExecuteASTContext(context.Background(), stmt, params)
 }
 
-// substituteSelectParams substitutes placeholders in a SELECT statement
-func (e *Executor) substituteSelectParams(stmt *parser.SelectStmt, params []types.Value) *parser.SelectStmt {
-	result := *stmt // shallow copy
-	if stmt.Where != nil {
-		result.Where = e.substituteExprParams(stmt.Where, params)
+// ExecuteASTContext is ExecuteAST with a context. Once the context is
+// canceled or past its deadline, the statement stops with the error of the
+// context, and its changes are rolled back unless a transaction is active.
+func (e *Executor) ExecuteASTContext(ctx context.Context, stmt parser.Statement, params []types.Value) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if stmt.Limit != nil {
-		result.Limit = e.substituteExprParams(stmt.Limit, params)
+	if ctx.Done() == nil {
+		// Never canceled, so not worth checking
+		ctx = nil
 	}
-	if stmt.Offset != nil {
-		result.Offset = e.substituteExprParams(stmt.Offset, params)
+
+	if e.ReadOnly(stmt) {
+		// Queries may run concurrently and leave the executor as it is, so
+		// each one binds its values on a copy of it
+		bound := *e
+		bound.params = params
+		bound.ctx = ctx
+		return bound.executeStatement(stmt)
 	}
-	return &result
+
+	e.params = params
+	e.ctx = ctx
+	defer func() {
+		e.params = nil
+		e.ctx = nil
+	}()
+	return e.executeStatement(stmt)
 }
 
-// substituteInsertParams substitutes placeholders in an INSERT statement
-func (e *Executor) substituteInsertParams(stmt *parser.InsertStmt, params []types.Value) *parser.InsertStmt {
-	result := *stmt // shallow copy
-	if stmt.Values != nil {
-		result.Values = make([][]parser.Expression, len(stmt.Values))
-		for i, row := range stmt.Values {
-			result.Values[i] = make([]parser.Expression, len(row))
-			for j, expr := range row {
-				result.Values[i][j] = e.substituteExprParams(expr, params)
-			}
-		}
+// contextErr returns the error of the context of the statement being
+// executed, or nil while the statement may go on
+func (e *Executor) contextErr() error {
+	if e.ctx == nil {
+		return nil
 	}
-	return &result
+	return e.ctx.Err()
 }
 
-// substituteUpdateParams substitutes placeholders in an UPDATE statement
-func (e *Executor) substituteUpdateParams(stmt *parser.UpdateStmt, params []types.Value) *parser.UpdateStmt {
-	result := *stmt // shallow copy
-	if stmt.Where != nil {
-		result.Where = e.substituteExprParams(stmt.Where, params)
-	}
-	if stmt.Assignments != nil {
-		result.Assignments = make([]parser.Assignment, len(stmt.Assignments))
-		for i, assign := range stmt.Assignments {
-			result.Assignments[i] = parser.Assignment{
-				Column: assign.Column,
-				Value:  e.substituteExprParams(assign.Value, params),
-			}
-		}
+// statementContext returns the context of the statement being executed
+func (e *Executor) statementContext() context.Context {
+	if e.ctx == nil {
+		return context.Background()
 	}
-	return &result
+	return e.ctx
 }
 
-// substituteDeleteParams substitutes placeholders in a DELETE statement
-func (e *Executor) substituteDeleteParams(stmt *parser.DeleteStmt, params []types.Value) *parser.DeleteStmt {
-	result := *stmt // shallow copy
-	if stmt.Where != nil {
-		result.Where = e.substituteExprParams(stmt.Where, params)
-	}
-	return &result
+// withoutParams runs fn with no parameters bound, for the statements of
+// triggers and procedures whose placeholders are not the ones of the
+// statement being executed
+func (e *Executor) withoutParams(fn func() error) error {
+	params := e.params
+	e.params = nil
+	defer func() { e.params = params }()
+	return fn()
 }
 
-// substituteExprParams recursively substitutes Placeholder nodes with Literal nodes
-func (e *Executor) substituteExprParams(expr parser.Expression, params []types.Value) parser.Expression {
-	if expr == nil {
-		return nil
+// paramValue returns the value bound to a placeholder. A @name parameter no
+// value is bound to reads the session variable of that name, as it does in
+// statements executed without parameters; other unbound parameters are NULL.
+func (e *Executor) paramValue(ph *parser.Placeholder) types.Value {
+	if v, ok := parser.ConstantValue(ph, e.params); ok {
+		return v
 	}
-
-	switch ex := expr.(type) {
-	case *parser.Placeholder:
-		// Replace placeholder with literal value
-		if ex.Index > 0 && ex.Index <= len(params) {
-			return &parser.Literal{Value: params[ex.Index-1]}
-		}
-		// If index out of range, return NULL
-		return &parser.Literal{Value: types.NewNull()}
-	case *parser.BinaryExpr:
-		return &parser.BinaryExpr{
-			Left:  e.substituteExprParams(ex.Left, params),
-			Op:    ex.Op,
-			Right: e.substituteExprParams(ex.Right, params),
+	if strings.HasPrefix(ph.Name, "@") {
+		if v, ok := e.sessionVars[ph.Name[1:]]; ok {
+			return v
 		}
-	case *parser.UnaryExpr:
-		return &parser.UnaryExpr{
-			Op:    ex.Op,
-			Right: e.substituteExprParams(ex.Right, params),
-		}
-	case *parser.FunctionCall:
-		args := make([]parser.Expression, len(ex.Args))
-		for i, arg := range ex.Args {
-			args[i] = e.substituteExprParams(arg, params)
-		}
-		return &parser.FunctionCall{
-			Name: ex.Name,
-			Args: args,
-		}
-	case *parser.InExpr:
-		values := make([]parser.Expression, len(ex.Values))
-		for i, val := range ex.Values {
-			values[i] = e.substituteExprParams(val, params)
-		}
-		return &parser.InExpr{
-			Left:   e.substituteExprParams(ex.Left, params),
-			Not:    ex.Not,
-			Values: values,
-		}
-	case *parser.LikeExpr:
-		return &parser.LikeExpr{
-			Left:    e.substituteExprParams(ex.Left, params),
-			Not:     ex.Not,
-			Pattern: e.substituteExprParams(ex.Pattern, params),
-		}
-	case *parser.CaseExpr:
-		result := &parser.CaseExpr{
-			Operand: e.substituteExprParams(ex.Operand, params),
-			Else:    e.substituteExprParams(ex.Else, params),
-		}
-		for _, when := range ex.Whens {
-			result.Whens = append(result.Whens, &parser.WhenClause{
-				Condition: e.substituteExprParams(when.Condition, params),
-				Then:      e.substituteExprParams(when.Then, params),
-			})
-		}
-		return result
-	default:
-		// For Literal, ColumnRef, and other nodes, return as-is
-		return expr
 	}
+	return types.NewNull()
+}
+
+// SessionVariable returns the value of the session variable set with
+// SET @name, or false if it was never set
+func (e *Executor) SessionVariable(name string) (types.Value, bool) {
+	v, ok := e.sessionVars[name]
+	return v, ok
 }
 
 // executeCreateTable handles CREATE TABLE
@@ -593,7 +500,7 @@ func (e *Executor) executeCreateTable(stmt *parser.CreateTableStmt) (*Result, er
 	e.rowid[stmt.TableName] = 1
 
 	// Persist schema to disk
-	sql := reconstructCreateTableSQL(stmt)
+	sql := reconstructCreateTableSQL(table)
 	schemaEntry := &dbfile.SchemaEntry{
 		Type:      dbfile.SchemaEntryTable,
 		Name:      stmt.TableName,
@@ -802,18 +709,20 @@ func (e *Executor) fireTriggers(tableName string, timing schema.TriggerTiming, e
 
 // executeTriggerActions executes the action statements of a trigger
 func (e *Executor) executeTriggerActions(trigger *schema.TriggerDef, ctx *TriggerContext) error {
-	for _, action := range trigger.Actions {
-		stmt, ok := action.(parser.Statement)
-		if !ok {
-			continue
-		}
+	return e.withoutParams(func() error {
+		for _, action := range trigger.Actions {
+			stmt, ok := action.(parser.Statement)
+			if !ok {
+				continue
+			}
 
-		// Execute the trigger action statement
-		if err := e.executeTriggerStatement(stmt, ctx); err != nil {
-			return err
+			// Execute the trigger action statement
+			if err := e.executeTriggerStatement(stmt, ctx); err != nil {
+				return err
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // executeTriggerStatement executes a single trigger action statement with NEW/OLD context
@@ -857,6 +766,17 @@ func selectStmtToSQL(stmt *parser.SelectStmt) string {
 	var sql string
 	sql = "SELECT "
 
+	// DISTINCT [ON (...)]
+	if stmt.Distinct {
+		sql += "DISTINCT "
+	} else if len(stmt.DistinctOn) > 0 {
+		on := make([]string, len(stmt.DistinctOn))
+		for i, expr := range stmt.DistinctOn {
+			on[i] = exprToString(expr)
+		}
+		sql += "DISTINCT ON (" + strings.Join(on, ", ") + ") "
+	}
+
 	// Columns
 	for i, col := range stmt.Columns {
 		if i > 0 {
@@ -900,16 +820,7 @@ func selectStmtToSQL(stmt *parser.SelectStmt) string {
 
 	// ORDER BY
 	if len(stmt.OrderBy) > 0 {
-		sql += " ORDER BY "
-		for i, ob := range stmt.OrderBy {
-			if i > 0 {
-				sql += ", "
-			}
-			sql += exprToString(ob.Expr)
-			if ob.Direction == parser.OrderDesc {
-				sql += " DESC"
-			}
-		}
+		sql += " ORDER BY " + orderByToString(stmt.OrderBy)
 	}
 
 	// LIMIT
@@ -1021,7 +932,7 @@ func (e *Executor) validateForeignKeyValue(refTable, refColumn string, value typ
 
 	for cursor.First(); cursor.Valid(); cursor.Next() {
 		rowData := cursor.Value()
-		rowValues := record.Decode(rowData)
+		rowValues := decodeRow(table, rowData)
 
 		if colIdx >= len(rowValues) {
 			continue
@@ -1038,6 +949,18 @@ func (e *Executor) validateForeignKeyValue(refTable, refColumn string, value typ
 	return fmt.Errorf("no matching value found in '%s.%s'", refTable, refColumn)
 }
 
+// orderByToString converts an ORDER BY list back to SQL
+func orderByToString(orderBy []parser.OrderByExpr) string {
+	parts := make([]string, len(orderBy))
+	for i, ob := range orderBy {
+		parts[i] = exprToString(ob.Expr)
+		if ob.Direction == parser.OrderDesc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
 // exprToString converts an expression to a string representation
 func exprToString(expr parser.Expression) string {
 	if expr == nil {
@@ -1059,6 +982,11 @@ func exprToString(expr parser.Expression) string {
 		default:
 			return "?"
 		}
+	case *parser.Placeholder:
+		if e.Name != "" {
+			return e.Name
+		}
+		return "?"
 	case *parser.ColumnRef:
 		return e.Name
 	case *parser.BinaryExpr:
@@ -1068,6 +996,9 @@ func exprToString(expr parser.Expression) string {
 		return fmt.Sprintf("(%s %s %s)", left, op, right)
 	case *parser.UnaryExpr:
 		right := exprToString(e.Right)
+		if e.Op == lexer.NOT {
+			return fmt.Sprintf("(NOT %s)", right)
+		}
 		op := tokenToOp(e.Op)
 		return fmt.Sprintf("%s%s", op, right)
 	case *parser.FunctionCall:
@@ -1075,7 +1006,40 @@ func exprToString(expr parser.Expression) string {
 		for i, arg := range e.Args {
 			args[i] = exprToString(arg)
 		}
-		return fmt.Sprintf("%s(%s)", e.Name, strings.Join(args, ", "))
+		argList := strings.Join(args, ", ")
+		if e.Distinct {
+			argList = "DISTINCT " + argList
+		}
+		if len(e.OrderBy) > 0 && !e.WithinGroup {
+			argList += " ORDER BY " + orderByToString(e.OrderBy)
+		}
+		call := fmt.Sprintf("%s(%s)", e.Name, argList)
+		if e.WithinGroup {
+			call += " WITHIN GROUP (ORDER BY " + orderByToString(e.OrderBy) + ")"
+		}
+		if e.Filter != nil {
+			call += " FILTER (WHERE " + exprToString(e.Filter) + ")"
+		}
+		return call
+	case *parser.IsNullExpr:
+		if e.Not {
+			return fmt.Sprintf("(%s IS NOT NULL)", exprToString(e.Expr))
+		}
+		return fmt.Sprintf("(%s IS NULL)", exprToString(e.Expr))
+	case *parser.IsDistinctExpr:
+		op := "IS DISTINCT FROM"
+		if e.Not {
+			op = "IS NOT DISTINCT FROM"
+		}
+		return fmt.Sprintf("(%s %s %s)", exprToString(e.Left), op, exprToString(e.Right))
+	case *parser.BetweenExpr:
+		op := "BETWEEN"
+		if e.Not {
+			op = "NOT BETWEEN"
+		}
+		return fmt.Sprintf("(%s %s %s AND %s)", exprToString(e.Expr), op, exprToString(e.Low), exprToString(e.High))
+	case *parser.CastExpr:
+		return fmt.Sprintf("CAST(%s AS %s)", exprToString(e.Expr), columnTypeSQL(castColumn(e.Type)))
 	default:
 		return ""
 	}
@@ -1181,6 +1145,7 @@ func (e *Executor) executeDropTable(stmt *parser.DropTableStmt) (*Result, error)
 			}
 			// Clean up in-memory index tree
 			delete(e.trees, "index:"+idx.Name)
+			e.dropHNSWIndex(idx.Name)
 			if err := e.deleteSchemaEntry(idx.Name); err != nil {
 				// Best effort - continue
 			}
@@ -1226,6 +1191,17 @@ func (e *Executor) executeCreateIndex(stmt *parser.CreateIndexStmt) (*Result, er
 		return nil, fmt.Errorf("table %s not found", stmt.TableName)
 	}
 
+	switch stmt.Using {
+	case "", "BTREE":
+		if len(stmt.Options) > 0 {
+			return nil, fmt.Errorf("index options are not supported for B-tree index %s", stmt.IndexName)
+		}
+	case "HNSW":
+		return e.executeCreateHNSWIndex(stmt, table)
+	default:
+		return nil, fmt.Errorf("unknown index method: %s", stmt.Using)
+	}
+
 	// Validate all columns exist in the table and build column index map
 	colIndexes := make([]int, len(stmt.Columns))
 	for i, colName := range stmt.Columns {
@@ -1292,7 +1268,7 @@ func (e *Executor) executeCreateIndex(stmt *parser.CreateIndexStmt) (*Result, er
 			rowID := binary.BigEndian.Uint64(key)
 
 			// Decode row values
-			values := record.Decode(value)
+			values := decodeRow(table, value)
 
 			// For partial indexes, check if row matches predicate
 			if whereExpr != nil {
@@ -1335,7 +1311,7 @@ func (e *Executor) executeCreateIndex(stmt *parser.CreateIndexStmt) (*Result, er
 				indexValue = buf
 			} else {
 				// Non-unique index: Key = Columns + RowID, Value = empty
-				keyValues = append(keyValues, types.NewInt(int64(rowID)))
+				keyValues = append(keyValues, indexRowID(rowID))
 				indexKey = record.Encode(keyValues)
 				indexValue = []byte{}
 			}
@@ -1405,9 +1381,10 @@ func (e *Executor) executeDropIndex(stmt *parser.DropIndexStmt) (*Result, error)
 		return nil, err
 	}
 
-	// Clean up in-memory B-tree structure
+	// Clean up in-memory B-tree and HNSW structures
 	idxTreeName := "index:" + stmt.IndexName
 	delete(e.trees, idxTreeName)
+	e.dropHNSWIndex(stmt.IndexName)
 
 	// Remove from schema B-tree
 	if err := e.deleteSchemaEntry(stmt.IndexName); err != nil {
@@ -1492,6 +1469,9 @@ func (e *Executor) executeInsertInternal(stmt *parser.InsertStmt, fireTriggers b
 
 	// Insert each row
 	for _, inputValues := range rowsToInsert {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		// Map input values to full column list
 		values := e.mapInputToColumns(inputValues, colMapping, table)
 
@@ -1713,11 +1693,14 @@ func (e *Executor) executeUpdate(stmt *parser.UpdateStmt) (*Result, error) {
 	defer cursor.Close()
 
 	for cursor.First(); cursor.Valid(); cursor.Next() {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		key := cursor.Key()
 		value := cursor.Value()
 
 		// Decode row
-		values := record.Decode(value)
+		values := decodeRow(table, value)
 
 		// Evaluate WHERE clause if present
 		if stmt.Where != nil {
@@ -1743,6 +1726,9 @@ func (e *Executor) executeUpdate(stmt *parser.UpdateStmt) (*Result, error) {
 	// Apply updates
 	var rowsAffected int64
 	for _, entry := range toUpdate {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		// Create new row values based on old values and assignments
 		newValues := make([]types.Value, len(entry.oldValues))
 		copy(newValues, entry.oldValues)
@@ -1793,8 +1779,11 @@ func (e *Executor) executeUpdate(stmt *parser.UpdateStmt) (*Result, error) {
 					return nil, fmt.Errorf("column %s expects VECTOR(%d), got dimension %d", colDef.Name, colDef.VectorDim, vec.Dimension())
 				}
 
-				vec.Normalize()
-				newValues[idx] = types.NewBlob(vec.ToBytes())
+				// Normalize unless NONORMALIZE is set, as INSERT does
+				if !colDef.NoNormalize {
+					vec.Normalize()
+					newValues[idx] = types.NewBlob(vec.ToBytes())
+				}
 			}
 		}
 
@@ -1893,11 +1882,14 @@ func (e *Executor) executeDelete(stmt *parser.DeleteStmt) (*Result, error) {
 	defer cursor.Close()
 
 	for cursor.First(); cursor.Valid(); cursor.Next() {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		key := cursor.Key()
 		value := cursor.Value()
 
 		// Decode row
-		values := record.Decode(value)
+		values := decodeRow(table, value)
 
 		// Evaluate WHERE clause if present
 		if stmt.Where != nil {
@@ -1919,6 +1911,9 @@ func (e *Executor) executeDelete(stmt *parser.DeleteStmt) (*Result, error) {
 	// Delete collected rows
 	var rowsAffected int64
 	for _, entry := range entriesToDelete {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		// Extract rowid from key for index deletion
 		rowid := binary.BigEndian.Uint64(entry.key)
 
@@ -2033,38 +2028,8 @@ func (e *Executor) executeTruncate(stmt *parser.TruncateStmt) (*Result, error) {
 	}
 
 	// Clear all indexes for this table
-	indexes := e.catalog.GetIndexesForTable(stmt.TableName)
-	for _, idx := range indexes {
-		idxTreeName := "index:" + idx.Name
-		idxTree := e.trees[idxTreeName]
-		if idxTree == nil {
-			if idx.RootPage == 0 {
-				continue
-			}
-			var err error
-			idxTree, err = e.treeFactory.Open(idx.RootPage)
-			if err != nil {
-				return nil, fmt.Errorf("failed to open btree for index %s: %w", idx.Name, err)
-			}
-			e.trees[idxTreeName] = idxTree
-		}
-
-		// Collect and delete all index entries
-		var idxKeysToDelete [][]byte
-		idxCursor := idxTree.Cursor()
-		for idxCursor.First(); idxCursor.Valid(); idxCursor.Next() {
-			key := idxCursor.Key()
-			keyCopy := make([]byte, len(key))
-			copy(keyCopy, key)
-			idxKeysToDelete = append(idxKeysToDelete, keyCopy)
-		}
-		idxCursor.Close()
-
-		for _, key := range idxKeysToDelete {
-			if err := idxTree.Delete(key); err != nil {
-				return nil, fmt.Errorf("failed to delete index entry during truncate: %w", err)
-			}
-		}
+	if err := e.clearIndexes(stmt.TableName); err != nil {
+		return nil, err
 	}
 
 	// Reset auto-increment sequence to 1
@@ -2202,7 +2167,7 @@ func (e *Executor) checkForeignKeyOnDelete(table *schema.TableDef, values []type
 			cursor := refTree.Cursor()
 			for cursor.First(); cursor.Valid(); cursor.Next() {
 				refRowData := cursor.Value()
-				refRowValues := record.Decode(refRowData)
+				refRowValues := decodeRow(refTable, refRowData)
 
 				if refColIdx >= len(refRowValues) {
 					continue
@@ -2341,7 +2306,7 @@ func (e *Executor) checkForeignKeyOnUpdate(table *schema.TableDef, oldValues, ne
 			cursor := refTree.Cursor()
 			for cursor.First(); cursor.Valid(); cursor.Next() {
 				refRowData := cursor.Value()
-				refRowValues := record.Decode(refRowData)
+				refRowValues := decodeRow(refTable, refRowData)
 
 				if refColIdx >= len(refRowValues) {
 					continue
@@ -2625,13 +2590,14 @@ func (e *Executor) validateCheckConstraint(checkExpr string, values []types.Valu
 		return nil
 	}
 
-	// Evaluate the expression
-	result, err := e.evaluateCondition(selectStmt.Where, values, colMap)
+	// Evaluate the expression; like NOT NULL, it is only violated by a
+	// definite false, so an UNKNOWN result passes
+	result, err := e.evaluateExpr(selectStmt.Where, values, colMap)
 	if err != nil {
 		return err
 	}
 
-	if !result {
+	if isFalse(result) {
 		return fmt.Errorf("check expression '%s' evaluated to false", checkExpr)
 	}
 
@@ -2720,9 +2686,9 @@ func (e *Executor) tryFastPathPKLookup(stmt *parser.SelectStmt) (*Result, bool)
 		return nil, false
 	}
 
-	// Right side should be a literal value
-	if lit, ok := binExpr.Right.(*parser.Literal); ok {
-		lookupValue = lit.Value
+	// Right side should be a literal value or a bound parameter
+	if v, ok := parser.ConstantValue(binExpr.Right, e.params); ok {
+		lookupValue = v
 	} else {
 		return nil, false
 	}
@@ -2741,17 +2707,18 @@ func (e *Executor) tryFastPathPKLookup(stmt *parser.SelectStmt) (*Result, bool)
 		e.trees[tableDef.Name] = tableTree
 	}
 
-	// Build the key for lookup using reusable buffer
+	// Build the key for lookup on the stack, queries may run concurrently
 	// Key format: 8-byte big-endian rowid (which is the PK value for INT PRIMARY KEY)
+	var keyBuffer [8]byte
 	switch lookupValue.Type() {
 	case types.TypeSmallInt, types.TypeInt32, types.TypeBigInt, types.TypeSerial, types.TypeBigSerial:
-		binary.BigEndian.PutUint64(e.keyBuffer[:], uint64(lookupValue.Int()))
+		binary.BigEndian.PutUint64(keyBuffer[:], uint64(lookupValue.Int()))
 	default:
 		// For non-integer keys, we need to encode differently
 		// For now, fall back to regular path for non-integer PKs
 		return nil, false
 	}
-	key := e.keyBuffer[:]
+	key := keyBuffer[:]
 
 	// Direct B-tree lookup
 	data, err := tableTree.Get(key)
@@ -2825,8 +2792,9 @@ func (e *Executor) DirectPKLookup(tableName string, pkValue int64) (*Result, err
 		e.trees[tableDef.Name] = tableTree
 	}
 
-	// Build the key using reusable buffer
-	binary.BigEndian.PutUint64(e.keyBuffer[:], uint64(pkValue))
+	// Build the key on the stack, queries may run concurrently
+	var keyBuffer [8]byte
+	binary.BigEndian.PutUint64(keyBuffer[:], uint64(pkValue))
 
 	// Get cached column names (avoids allocation)
 	columns := tableDef.GetCachedColumnNames()
@@ -2835,7 +2803,7 @@ func (e *Executor) DirectPKLookup(tableName string, pkValue int64) (*Result, err
 	}
 
 	// Direct B-tree lookup
-	data, err := tableTree.Get(e.keyBuffer[:])
+	data, err := tableTree.Get(keyBuffer[:])
 	if err != nil {
 		// Key not found - return empty result
 		return &Result{
@@ -3063,6 +3031,8 @@ func (e *Executor) executeSelectWithCTEs(stmt *parser.SelectStmt, cteData map[st
 
 	// 2. Optimize Plan
 	opt := optimizer.NewOptimizer()
+	opt.SetCatalog(e.catalog)
+	opt.SetParams(e.params)
 	plan = opt.Optimize(plan)
 
 	// 3. Execute Plan (with CTE data context)
@@ -3146,6 +3116,7 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 		}
 
 		iterator := NewTableScanIteratorWithSchema(tableTree, node.Table)
+		iterator.cancel = e.canceler()
 
 		// Build column names (with alias prefix if alias exists)
 		var cols []string
@@ -3280,6 +3251,7 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 			right:          rightIter,
 			condition:      node.Condition,
 			executor:       e,
+			cancel:         e.canceler(),
 			joinType:       node.JoinType,
 			combinedMap:    colMap,
 			leftSchemaLen:  len(leftCols),
@@ -3350,6 +3322,7 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 			orderBy:  node.OrderBy,
 			colMap:   colMap,
 			executor: e,
+			cancel:   e.canceler(),
 		}, inputCols, nil
 
 	case *optimizer.LimitNode:
@@ -3396,8 +3369,10 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 
 		// Build output column names: groupBy columns + aggregate results
 		var outputCols []string
-		for _, expr := range node.GroupBy {
-			if colRef, ok := expr.(*parser.ColumnRef); ok {
+		for i, expr := range node.GroupBy {
+			if i < len(node.GroupNames) && node.GroupNames[i] != "" {
+				outputCols = append(outputCols, node.GroupNames[i])
+			} else if colRef, ok := expr.(*parser.ColumnRef); ok {
 				outputCols = append(outputCols, colRef.Name)
 			} else {
 				outputCols = append(outputCols, "?")
@@ -3409,7 +3384,11 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 			outputCols = append(outputCols, "COUNT(*)")
 		} else {
 			for _, agg := range node.Aggregates {
-				outputCols = append(outputCols, agg.FuncName)
+				if agg.Name != "" {
+					outputCols = append(outputCols, agg.Name)
+				} else {
+					outputCols = append(outputCols, agg.FuncName)
+				}
 			}
 		}
 
@@ -3420,8 +3399,27 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 			having:     node.Having,
 			colMap:     colMap,
 			executor:   e,
+			cancel:     e.canceler(),
 		}, outputCols, nil
 
+	case *optimizer.DistinctNode:
+		inputIter, inputCols, err := e.executePlanWithCTEs(node.Input, cteData)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &DistinctIterator{
+			child:    inputIter,
+			on:       node.On,
+			sorted:   node.Sorted,
+			colMap:   e.buildColMap(inputCols),
+			executor: e,
+			cancel:   e.canceler(),
+		}, inputCols, nil
+
+	case *optimizer.IndexScanNode:
+		return e.executeIndexScan(node)
+
 	case *optimizer.DualNode:
 		// DualNode produces a single row with no columns (for SELECT without FROM)
 		return &DualIterator{}, nil, nil
@@ -3451,26 +3449,68 @@ func (e *Executor) executePlanWithCTEs(plan optimizer.PlanNode, cteData map[stri
 	case *optimizer.TableFunctionNode:
 		return e.executeTableFunction(node, cteData)
 
+	case *optimizer.VectorIndexScanNode:
+		return e.executeVectorIndexScan(node)
+
+	case *optimizer.TopKNode:
+		inputIter, inputCols, err := e.executePlanWithCTEs(node.Input, cteData)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		k, err := e.evaluateLimitOffset(node.Limit, node.Offset)
+		if err != nil {
+			inputIter.Close()
+			return nil, nil, err
+		}
+
+		return &TopKIterator{
+			child:    inputIter,
+			orderBy:  node.OrderBy,
+			k:        k,
+			colMap:   e.buildColMap(inputCols),
+			executor: e,
+		}, inputCols, nil
+
 	default:
 		return nil, nil, fmt.Errorf("unsupported plan node: %T", plan)
 	}
 }
 
+// evaluateLimitOffset returns the number of rows a LIMIT/OFFSET pair needs from its input (limit + offset)
+func (e *Executor) evaluateLimitOffset(limitExpr, offsetExpr parser.Expression) (int, error) {
+	limit, err := e.evaluateLiteralExpr(limitExpr)
+	if err != nil {
+		return 0, fmt.Errorf("evaluating LIMIT: %w", err)
+	}
+	var offset int64
+	if offsetExpr != nil {
+		offset, err = e.evaluateLiteralExpr(offsetExpr)
+		if err != nil {
+			return 0, fmt.Errorf("evaluating OFFSET: %w", err)
+		}
+	}
+	if limit < 0 || offset < 0 {
+		return 0, fmt.Errorf("LIMIT and OFFSET must not be negative")
+	}
+	return int(limit + offset), nil
+}
+
 // evaluateLiteralExpr evaluates an expression that should be a literal integer
+// or a parameter bound to one
 func (e *Executor) evaluateLiteralExpr(expr parser.Expression) (int64, error) {
-	switch ex := expr.(type) {
-	case *parser.Literal:
-		switch ex.Value.Type() {
-		case types.TypeInt32, types.TypeSmallInt, types.TypeBigInt, types.TypeSerial, types.TypeBigSerial:
-			return ex.Value.Int(), nil
-		case types.TypeFloat:
-			return int64(ex.Value.Float()), nil
-		default:
-			return 0, fmt.Errorf("expected integer literal, got %v", ex.Value.Type())
-		}
-	default:
+	value, ok := parser.ConstantValue(expr, e.params)
+	if !ok {
 		return 0, fmt.Errorf("expected literal expression for LIMIT/OFFSET, got %T", expr)
 	}
+	switch value.Type() {
+	case types.TypeInt32, types.TypeSmallInt, types.TypeBigInt, types.TypeSerial, types.TypeBigSerial:
+		return value.Int(), nil
+	case types.TypeFloat:
+		return int64(value.Float()), nil
+	default:
+		return 0, fmt.Errorf("expected integer literal, got %v", value.Type())
+	}
 }
 
 // buildColMap creates a mapping from column names to indices, handling short names
@@ -3526,6 +3566,8 @@ func (e *Executor) evaluateExpr(expr parser.Expression, rowValues []types.Value,
 	switch ex := expr.(type) {
 	case *parser.Literal:
 		return ex.Value, nil
+	case *parser.Placeholder:
+		return e.paramValue(ex), nil
 	case *parser.ColumnRef:
 		if colMap == nil {
 			return types.NewNull(), fmt.Errorf("column reference not allowed here")
@@ -3543,6 +3585,9 @@ func (e *Executor) evaluateExpr(expr parser.Expression, rowValues []types.Value,
 		if err != nil {
 			return types.NewNull(), err
 		}
+		if ex.Op == lexer.NOT {
+			return logicalNot(right), nil
+		}
 		if ex.Op == lexer.MINUS {
 			switch right.Type() {
 			case types.TypeInt32:
@@ -3614,6 +3659,24 @@ func (e *Executor) evaluateExpr(expr parser.Expression, rowValues []types.Value,
 		return types.NewNull(), nil
 	case *parser.CaseExpr:
 		return e.evaluateCaseExpr(ex, rowValues, colMap)
+	case *parser.InExpr:
+		return e.evaluateInExpr(ex, rowValues, colMap)
+	case *parser.LikeExpr:
+		return e.evaluateLikeExpr(ex, rowValues, colMap)
+	case *parser.ExistsExpr:
+		exists, err := e.evaluateExistsExpr(ex, rowValues, colMap)
+		if err != nil {
+			return types.NewNull(), err
+		}
+		return sqlBool(exists), nil
+	case *parser.IsNullExpr:
+		return e.evaluateIsNullExpr(ex, rowValues, colMap)
+	case *parser.IsDistinctExpr:
+		return e.evaluateIsDistinctExpr(ex, rowValues, colMap)
+	case *parser.BetweenExpr:
+		return e.evaluateBetweenExpr(ex, rowValues, colMap)
+	case *parser.CastExpr:
+		return e.evaluateCastExpr(ex, rowValues, colMap)
 	default:
 		return types.NewNull(), fmt.Errorf("unsupported expression type: %T", expr)
 	}
@@ -3621,6 +3684,10 @@ func (e *Executor) evaluateExpr(expr parser.Expression, rowValues []types.Value,
 
 // evaluateBinaryExpr evaluates a binary expression
 func (e *Executor) evaluateBinaryExpr(expr *parser.BinaryExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	if expr.Op == lexer.AND || expr.Op == lexer.OR {
+		return e.evaluateLogicalExpr(expr, rowValues, colMap)
+	}
+
 	left, err := e.evaluateExpr(expr.Left, rowValues, colMap)
 	if err != nil {
 		return types.NewNull(), err
@@ -3640,88 +3707,31 @@ func (e *Executor) evaluateBinaryExpr(expr *parser.BinaryExpr, rowValues []types
 	case lexer.SLASH:
 		return e.divideValues(left, right)
 	default:
-		// Comparison operators return 0 or 1
-		cmp := e.compareValues(left, right)
-		var result bool
-		switch expr.Op {
-		case lexer.EQ:
-			result = cmp == 0
-		case lexer.NEQ:
-			result = cmp != 0
-		case lexer.LT:
-			result = cmp < 0
-		case lexer.GT:
-			result = cmp > 0
-		case lexer.LTE:
-			result = cmp <= 0
-		case lexer.GTE:
-			result = cmp >= 0
-		default:
-			return types.NewNull(), fmt.Errorf("unsupported operator: %v", expr.Op)
-		}
-		if result {
-			return types.NewInt(1), nil
-		}
-		return types.NewInt(0), nil
+		return e.evaluateComparison(expr.Op, left, right)
 	}
 }
 
-// evaluateCondition evaluates a WHERE condition and returns true/false
+// evaluateCondition evaluates a WHERE condition and returns true/false.
+// A condition that is NULL (UNKNOWN) does not hold.
 func (e *Executor) evaluateCondition(expr parser.Expression, rowValues []types.Value, colMap map[string]int) (bool, error) {
-	switch ex := expr.(type) {
-	case *parser.BinaryExpr:
-		if ex.Op == lexer.AND {
-			left, err := e.evaluateCondition(ex.Left, rowValues, colMap)
-			if err != nil {
-				return false, err
-			}
-			if !left {
-				return false, nil
-			}
-			return e.evaluateCondition(ex.Right, rowValues, colMap)
-		}
-		if ex.Op == lexer.OR {
-			left, err := e.evaluateCondition(ex.Left, rowValues, colMap)
-			if err != nil {
-				return false, err
-			}
-			if left {
-				return true, nil
-			}
-			return e.evaluateCondition(ex.Right, rowValues, colMap)
-		}
-		// Comparison
-		val, err := e.evaluateBinaryExpr(ex, rowValues, colMap)
-		if err != nil {
-			return false, err
-		}
-		return val.Int() != 0, nil
-	case *parser.InExpr:
-		return e.evaluateInExpr(ex, rowValues, colMap)
-	case *parser.ExistsExpr:
-		return e.evaluateExistsExpr(ex, rowValues, colMap)
-	case *parser.LikeExpr:
-		return e.evaluateLikeExpr(ex, rowValues, colMap)
-	default:
-		val, err := e.evaluateExpr(expr, rowValues, colMap)
-		if err != nil {
-			return false, err
-		}
-		return !val.IsNull() && val.Int() != 0, nil
+	val, err := e.evaluateExpr(expr, rowValues, colMap)
+	if err != nil {
+		return false, err
 	}
+	return isTrue(val), nil
 }
 
-// evaluateInExpr evaluates an IN expression
-func (e *Executor) evaluateInExpr(expr *parser.InExpr, rowValues []types.Value, colMap map[string]int) (bool, error) {
+// evaluateInExpr evaluates an IN expression. The result is NULL when the left
+// side is NULL, or when it matches nothing and the list contains a NULL.
+func (e *Executor) evaluateInExpr(expr *parser.InExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
 	// Evaluate the left side
 	leftVal, err := e.evaluateExpr(expr.Left, rowValues, colMap)
 	if err != nil {
-		return false, err
+		return types.NewNull(), err
 	}
 
-	// If left value is NULL, result is NULL (which is false in boolean context)
 	if leftVal.IsNull() {
-		return false, nil
+		return types.NewNull(), nil
 	}
 
 	// Get the values to check against
@@ -3730,7 +3740,7 @@ func (e *Executor) evaluateInExpr(expr *parser.InExpr, rowValues []types.Value,
 		// Execute the subquery
 		result, err := e.executeSelect(expr.Subquery)
 		if err != nil {
-			return false, fmt.Errorf("IN subquery error: %w", err)
+			return types.NewNull(), fmt.Errorf("IN subquery error: %w", err)
 		}
 		// Collect all first-column values
 		for _, row := range result.Rows {
@@ -3743,47 +3753,51 @@ func (e *Executor) evaluateInExpr(expr *parser.InExpr, rowValues []types.Value,
 		for _, valExpr := range expr.Values {
 			val, err := e.evaluateExpr(valExpr, rowValues, colMap)
 			if err != nil {
-				return false, err
+				return types.NewNull(), err
 			}
 			checkValues = append(checkValues, val)
 		}
 	}
 
 	// Check if leftVal is in checkValues
-	found := false
+	found, sawNull := false, false
 	for _, v := range checkValues {
+		if v.IsNull() {
+			sawNull = true
+			continue
+		}
 		if e.compareValues(leftVal, v) == 0 {
 			found = true
 			break
 		}
 	}
 
-	// Handle NOT IN
-	if expr.Not {
-		return !found, nil
+	if !found && sawNull {
+		return types.NewNull(), nil
 	}
-	return found, nil
+	// Handle NOT IN
+	return sqlBool(found != expr.Not), nil
 }
 
 // evaluateLikeExpr evaluates a LIKE expression using SQL LIKE pattern matching
 // % matches any sequence of characters (including empty)
 // _ matches any single character
-func (e *Executor) evaluateLikeExpr(expr *parser.LikeExpr, rowValues []types.Value, colMap map[string]int) (bool, error) {
+func (e *Executor) evaluateLikeExpr(expr *parser.LikeExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
 	// Evaluate the left side (the value to test)
 	leftVal, err := e.evaluateExpr(expr.Left, rowValues, colMap)
 	if err != nil {
-		return false, err
+		return types.NewNull(), err
 	}
 
 	// Evaluate the pattern
 	patternVal, err := e.evaluateExpr(expr.Pattern, rowValues, colMap)
 	if err != nil {
-		return false, err
+		return types.NewNull(), err
 	}
 
-	// If either is NULL, result is NULL (false in boolean context)
+	// If either is NULL, result is NULL
 	if leftVal.IsNull() || patternVal.IsNull() {
-		return false, nil
+		return types.NewNull(), nil
 	}
 
 	// Convert to strings
@@ -3794,10 +3808,7 @@ func (e *Executor) evaluateLikeExpr(expr *parser.LikeExpr, rowValues []types.Val
 	matched := matchLikePattern(str, pattern)
 
 	// Handle NOT LIKE
-	if expr.Not {
-		return !matched, nil
-	}
-	return matched, nil
+	return sqlBool(matched != expr.Not), nil
 }
 
 // asciiEqualFold compares two bytes for equality, ignoring ASCII case.
@@ -3989,6 +4000,29 @@ func (e *Executor) substituteExprOuterRefs(expr parser.Expression, outerRow []ty
 			Op:    ex.Op,
 			Right: e.substituteExprOuterRefs(ex.Right, outerRow, outerColMap),
 		}
+	case *parser.IsNullExpr:
+		return &parser.IsNullExpr{
+			Expr: e.substituteExprOuterRefs(ex.Expr, outerRow, outerColMap),
+			Not:  ex.Not,
+		}
+	case *parser.IsDistinctExpr:
+		return &parser.IsDistinctExpr{
+			Left:  e.substituteExprOuterRefs(ex.Left, outerRow, outerColMap),
+			Not:   ex.Not,
+			Right: e.substituteExprOuterRefs(ex.Right, outerRow, outerColMap),
+		}
+	case *parser.BetweenExpr:
+		return &parser.BetweenExpr{
+			Expr: e.substituteExprOuterRefs(ex.Expr, outerRow, outerColMap),
+			Not:  ex.Not,
+			Low:  e.substituteExprOuterRefs(ex.Low, outerRow, outerColMap),
+			High: e.substituteExprOuterRefs(ex.High, outerRow, outerColMap),
+		}
+	case *parser.CastExpr:
+		return &parser.CastExpr{
+			Expr: e.substituteExprOuterRefs(ex.Expr, outerRow, outerColMap),
+			Type: ex.Type,
+		}
 	default:
 		return expr
 	}
@@ -4350,7 +4384,7 @@ func (e *Executor) scanAllRows(tableName string, table *schema.TableDef) ([][]ty
 		valBytes := cursor.Value()
 		if valBytes != nil {
 			// Decode the record - returns []types.Value directly
-			row := record.Decode(valBytes)
+			row := decodeRow(table, valBytes)
 			if row != nil {
 				// Copy to avoid any potential buffer reuse issues
 				rowCopy := make([]types.Value, len(row))
@@ -4378,100 +4412,6 @@ func convertRowTypesForSchema(row []types.Value, table *schema.TableDef) []types
 	return row
 }
 
-// executeAlterTable handles ALTER TABLE statements
-func (e *Executor) executeAlterTable(stmt *parser.AlterTableStmt) (*Result, error) {
-	switch stmt.Action {
-	case parser.AlterActionAddColumn:
-		return e.executeAlterTableAddColumn(stmt)
-	case parser.AlterActionDropColumn:
-		return e.executeAlterTableDropColumn(stmt)
-	case parser.AlterActionRenameTable:
-		return e.executeAlterTableRename(stmt)
-	default:
-		return nil, fmt.Errorf("unsupported ALTER TABLE action")
-	}
-}
-
-// executeAlterTableAddColumn handles ALTER TABLE ADD COLUMN
-func (e *Executor) executeAlterTableAddColumn(stmt *parser.AlterTableStmt) (*Result, error) {
-	// Convert parser column def to schema column def
-	col := schema.ColumnDef{
-		Name:       stmt.NewColumn.Name,
-		Type:       stmt.NewColumn.Type,
-		PrimaryKey: stmt.NewColumn.PrimaryKey,
-		NotNull:    stmt.NewColumn.NotNull,
-		VectorDim:  stmt.NewColumn.VectorDim,
-	}
-
-	// Build constraints if any
-	var constraints []schema.Constraint
-
-	if stmt.NewColumn.PrimaryKey {
-		constraints = append(constraints, schema.Constraint{Type: schema.ConstraintPrimaryKey})
-	}
-	if stmt.NewColumn.NotNull {
-		constraints = append(constraints, schema.Constraint{Type: schema.ConstraintNotNull})
-	}
-	if stmt.NewColumn.Unique {
-		constraints = append(constraints, schema.Constraint{Type: schema.ConstraintUnique})
-	}
-	if stmt.NewColumn.CheckExpr != nil {
-		constraints = append(constraints, schema.Constraint{
-			Type:            schema.ConstraintCheck,
-			CheckExpression: exprToString(stmt.NewColumn.CheckExpr),
-		})
-	}
-	if stmt.NewColumn.ForeignKey != nil {
-		constraints = append(constraints, schema.Constraint{
-			Type:      schema.ConstraintForeignKey,
-			RefTable:  stmt.NewColumn.ForeignKey.RefTable,
-			RefColumn: stmt.NewColumn.ForeignKey.RefColumn,
-			OnDelete:  convertFKAction(stmt.NewColumn.ForeignKey.OnDelete),
-			OnUpdate:  convertFKAction(stmt.NewColumn.ForeignKey.OnUpdate),
-		})
-	}
-
-	col.Constraints = constraints
-
-	// Add column to catalog
-	if err := e.catalog.AddColumn(stmt.TableName, col); err != nil {
-		return nil, fmt.Errorf("failed to add column: %w", err)
-	}
-
-	return &Result{}, nil
-}
-
-// executeAlterTableDropColumn handles ALTER TABLE DROP COLUMN
-func (e *Executor) executeAlterTableDropColumn(stmt *parser.AlterTableStmt) (*Result, error) {
-	if err := e.catalog.DropColumn(stmt.TableName, stmt.ColumnName); err != nil {
-		return nil, fmt.Errorf("failed to drop column: %w", err)
-	}
-
-	return &Result{}, nil
-}
-
-// executeAlterTableRename handles ALTER TABLE RENAME TO
-func (e *Executor) executeAlterTableRename(stmt *parser.AlterTableStmt) (*Result, error) {
-	// Update B-tree reference
-	if tree, exists := e.trees[stmt.TableName]; exists {
-		delete(e.trees, stmt.TableName)
-		e.trees[stmt.NewName] = tree
-	}
-
-	// Update rowid reference
-	if rowid, exists := e.rowid[stmt.TableName]; exists {
-		delete(e.rowid, stmt.TableName)
-		e.rowid[stmt.NewName] = rowid
-	}
-
-	// Rename in catalog
-	if err := e.catalog.RenameTable(stmt.TableName, stmt.NewName); err != nil {
-		return nil, fmt.Errorf("failed to rename table: %w", err)
-	}
-
-	return &Result{}, nil
-}
-
 // HasActiveTransaction returns true if there is an active transaction
 func (e *Executor) HasActiveTransaction() bool {
 	return e.currentTx != nil && e.currentTx.IsActive()
@@ -4484,8 +4424,15 @@ func (e *Executor) executeBegin(_ *parser.BeginStmt) (*Result, error) {
 		return nil, fmt.Errorf("cannot start a transaction within a transaction")
 	}
 
+	// It holds a pager write transaction until COMMIT, and its changes go
+	// to the trees as they are made
+	if err := e.BeginWrite(); err != nil {
+		return nil, fmt.Errorf("cannot start a transaction: %w", err)
+	}
+
 	// Start a new transaction
 	e.currentTx = e.txManager.Begin()
+	e.writeTx.owner = e.currentTx
 
 	return &Result{}, nil
 }
@@ -4497,8 +4444,8 @@ func (e *Executor) executeCommit(_ *parser.CommitStmt) (*Result, error) {
 		return nil, fmt.Errorf("cannot commit: no transaction is active")
 	}
 
-	// Commit the transaction
-	if err := e.txManager.Commit(e.currentTx); err != nil {
+	// Make the changes durable; on failure the transaction stays open for ROLLBACK
+	if err := e.CommitTransaction(e.currentTx); err != nil {
 		return nil, fmt.Errorf("commit failed: %w", err)
 	}
 
@@ -4514,14 +4461,8 @@ func (e *Executor) executeRollback(_ *parser.RollbackStmt) (*Result, error) {
 		return nil, fmt.Errorf("cannot rollback: no transaction is active")
 	}
 
-	// Apply undo operations in reverse order to restore previous state
-	if err := e.applyUndoOperations(e.currentTx.UndoLog().GetAllOperations()); err != nil {
-		// Log error but continue with rollback - best effort
-		// In production, you might want to handle this differently
-	}
-
-	// Rollback the transaction (marks it as aborted, clears undo log)
-	if err := e.txManager.Rollback(e.currentTx); err != nil {
+	// Undo the changes and discard the pages written by the transaction
+	if err := e.RollbackTransaction(e.currentTx); err != nil {
 		return nil, fmt.Errorf("rollback failed: %w", err)
 	}
 
@@ -4540,6 +4481,9 @@ func (e *Executor) applyUndoOperations(ops []mvcc.UndoOperation) error {
 			continue // Table might have been dropped
 		}
 
+		// Keep HNSW indexes in step with the restored row
+		e.undoHNSWIndexes(op)
+
 		switch op.Type {
 		case mvcc.UndoInsert:
 			// Undo INSERT by DELETE
@@ -4615,6 +4559,8 @@ func (e *Executor) executeRollbackTo(stmt *parser.RollbackToStmt) (*Result, erro
 			continue
 		}
 
+		e.undoHNSWIndexes(op)
+
 		switch op.Type {
 		case mvcc.UndoInsert:
 			tableTree.Delete(op.Key)
@@ -4723,7 +4669,7 @@ func rowKey(row []types.Value) string {
 			case val.Type() == types.TypeBlob:
 				key += fmt.Sprintf("B:%x", val.Blob())
 			default:
-				key += "?"
+				key += fmt.Sprintf("?:%v", val)
 			}
 		}
 	}
@@ -4947,6 +4893,16 @@ func (e *Executor) generateOpcodeComment(instr *vdbe.Instruction) string {
 		return fmt.Sprintf("Insert into cursor %d", instr.P1)
 	case vdbe.OpGoto:
 		return fmt.Sprintf("Goto %d", instr.P2)
+	case vdbe.OpIsNull:
+		return fmt.Sprintf("if r[%d] IS NULL goto %d", instr.P1, instr.P2)
+	case vdbe.OpNotNull:
+		return fmt.Sprintf("if r[%d] IS NOT NULL goto %d", instr.P1, instr.P2)
+	case vdbe.OpAnd:
+		return fmt.Sprintf("r[%d] = r[%d] AND r[%d]", instr.P3, instr.P1, instr.P2)
+	case vdbe.OpOr:
+		return fmt.Sprintf("r[%d] = r[%d] OR r[%d]", instr.P3, instr.P1, instr.P2)
+	case vdbe.OpNot:
+		return fmt.Sprintf("r[%d] = NOT r[%d]", instr.P2, instr.P1)
 	default:
 		return ""
 	}
@@ -4981,6 +4937,10 @@ func (e *Executor) explainSelectPlan(stmt *parser.SelectStmt, result *Result) (*
 		// Fall back to simple plan if optimizer fails
 		return e.explainSelectSimple(stmt, result)
 	}
+	opt := optimizer.NewOptimizer()
+	opt.SetCatalog(e.catalog)
+	opt.SetParams(e.params)
+	plan = opt.Optimize(plan)
 
 	// Recursively explain the plan nodes
 	e.explainPlanNode(plan, 0, &rowID, result)
@@ -5011,6 +4971,29 @@ func (e *Executor) explainPlanNode(node optimizer.PlanNode, parentID int, rowID
 			tableName = n.Table.Name
 		}
 		detail = fmt.Sprintf("SEARCH TABLE %s USING INDEX %s", tableName, n.IndexName)
+		if len(n.Columns) > 0 {
+			detail = fmt.Sprintf("SCAN TABLE %s USING COVERING INDEX %s", tableName, n.IndexName)
+		}
+
+	case *optimizer.DistinctNode:
+		detail = "DISTINCT"
+		if len(n.On) > 0 {
+			detail = "DISTINCT ON"
+		}
+		if n.Sorted {
+			detail += " (SORTED INPUT)"
+		} else {
+			detail += " (HASH)"
+		}
+		row := []types.Value{
+			types.NewInt(int64(currentID)),
+			types.NewInt(int64(parentID)),
+			types.NewInt(0),
+			types.NewText(detail),
+		}
+		result.Rows = append(result.Rows, row)
+		e.explainPlanNode(n.Input, currentID, rowID, result)
+		return
 
 	case *optimizer.FilterNode:
 		detail = fmt.Sprintf("FILTER")
@@ -5106,6 +5089,38 @@ func (e *Executor) explainPlanNode(node optimizer.PlanNode, parentID int, rowID
 		*rowID-- // Don't count this as a row
 		return
 
+	case *optimizer.TableFunctionNode:
+		detail = e.explainTableFunction(n)
+
+	case *optimizer.VectorIndexScanNode:
+		detail = fmt.Sprintf("SEARCH TABLE %s USING HNSW INDEX %s (%s)", n.Table.Name, n.IndexName, n.Column)
+		if n.Alias != "" && n.Alias != n.Table.Name {
+			detail += " AS " + n.Alias
+		}
+		if idx := e.hnswIndexes[n.IndexName]; idx != nil {
+			if k, err := e.evaluateLimitOffset(n.Limit, n.Offset); err == nil {
+				detail += fmt.Sprintf(" (k=%d, ef=%d)", k, e.vectorScanEf(&vectorScanArgs{k: k}, idx))
+			}
+		}
+		if n.Filter != nil {
+			detail += " WITH FILTER"
+		}
+
+	case *optimizer.TopKNode:
+		detail = "TOP-K SORT"
+		if k, err := e.evaluateLimitOffset(n.Limit, n.Offset); err == nil {
+			detail = fmt.Sprintf("TOP-K SORT (k=%d)", k)
+		}
+		row := []types.Value{
+			types.NewInt(int64(currentID)),
+			types.NewInt(int64(parentID)),
+			types.NewInt(0),
+			types.NewText(detail),
+		}
+		result.Rows = append(result.Rows, row)
+		e.explainPlanNode(n.Input, currentID, rowID, result)
+		return
+
 	default:
 		detail = fmt.Sprintf("OPERATION (%T)", node)
 	}
@@ -5119,6 +5134,41 @@ func (e *Executor) explainPlanNode(node optimizer.PlanNode, parentID int, rowID
 	result.Rows = append(result.Rows, row)
 }
 
+// explainTableFunction describes a table-valued function call in a query plan
+func (e *Executor) explainTableFunction(n *optimizer.TableFunctionNode) string {
+	if !strings.EqualFold(n.Name, "vector_quantize_scan") || len(n.Args) < 2 {
+		return fmt.Sprintf("SCAN TABLE FUNCTION %s", n.Name)
+	}
+
+	tableName, columnName := n.Name, ""
+	if lit, ok := n.Args[0].(*parser.Literal); ok && lit.Value.Type() == types.TypeText {
+		tableName = lit.Value.Text()
+	}
+	if lit, ok := n.Args[1].(*parser.Literal); ok && lit.Value.Type() == types.TypeText {
+		columnName = lit.Value.Text()
+	}
+
+	detail := fmt.Sprintf("SEARCH TABLE %s USING HNSW INDEX", tableName)
+	var idx hnsw.VectorIndex
+	if indexDef := optimizer.FindVectorIndex(e.catalog, tableName, columnName); indexDef != nil {
+		detail += " " + indexDef.Name
+		idx = e.hnswIndexes[indexDef.Name]
+	}
+	detail += fmt.Sprintf(" (%s)", columnName)
+	if n.Alias != "" {
+		detail += " AS " + n.Alias
+	}
+	if idx != nil {
+		if args, err := e.evaluateVectorScanArgs(n); err == nil {
+			detail += fmt.Sprintf(" (k=%d, ef=%d)", args.k, e.vectorScanEf(args, idx))
+		}
+	}
+	if n.Filter != nil {
+		detail += " WITH FILTER"
+	}
+	return detail
+}
+
 // explainSelectSimple generates a simple plan when optimizer is not available
 func (e *Executor) explainSelectSimple(stmt *parser.SelectStmt, result *Result) (*Result, error) {
 	rowID := 0
@@ -5213,7 +5263,7 @@ func (e *Executor) executeExplainAnalyze(stmt *parser.ExplainStmt) (*Result, err
 	vm.SetProfiler(profiler)
 
 	// Execute the query to collect actual statistics
-	err := vm.Run()
+	err := vm.RunContext(e.statementContext())
 	if err != nil {
 		return nil, fmt.Errorf("execution failed during EXPLAIN ANALYZE: %w", err)
 	}
@@ -5334,14 +5384,8 @@ func (e *Executor) mapInputToColumns(inputValues []types.Value, colMapping []int
 
 	// Create a full row with DEFAULT values or NULLs for all columns
 	values := make([]types.Value, len(table.Columns))
-	for i, col := range table.Columns {
-		// Check for DEFAULT constraint
-		defaultConstraint := col.GetConstraint(schema.ConstraintDefault)
-		if defaultConstraint != nil && defaultConstraint.DefaultValue != nil {
-			values[i] = *defaultConstraint.DefaultValue
-		} else {
-			values[i] = types.NewNull()
-		}
+	for i := range table.Columns {
+		values[i] = table.Columns[i].DefaultValue()
 	}
 
 	// Map input values to their table positions (overrides defaults)
@@ -5893,17 +5937,23 @@ func (e *Executor) executeCall(stmt *parser.CallStmt) (*Result, error) {
 
 	// Execute procedure body with local scope
 	var lastResult *Result
-	for _, bodyStmt := range proc.Body {
-		stmt, ok := bodyStmt.(parser.Statement)
-		if !ok {
-			return nil, fmt.Errorf("invalid statement in procedure body")
-		}
+	err := e.withoutParams(func() error {
+		for _, bodyStmt := range proc.Body {
+			stmt, ok := bodyStmt.(parser.Statement)
+			if !ok {
+				return fmt.Errorf("invalid statement in procedure body")
+			}
 
-		result, err := e.executeProcedureStatement(stmt, localVars)
-		if err != nil {
-			return nil, err
+			result, err := e.executeProcedureStatement(stmt, localVars)
+			if err != nil {
+				return err
+			}
+			lastResult = result
 		}
-		lastResult = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Copy OUT/INOUT parameter values back to session variables
@@ -6137,36 +6187,21 @@ func (e *Executor) evaluateExprWithLocals(expr parser.Expression, row []types.Va
 			return e.multiplyValues(left, right)
 		case lexer.SLASH:
 			return e.divideValues(left, right)
+		case lexer.AND:
+			return logicalAnd(left, right), nil
+		case lexer.OR:
+			return logicalOr(left, right), nil
 		default:
-			// Comparison operators
-			cmp := e.compareValues(left, right)
-			var result bool
-			switch ex.Op {
-			case lexer.EQ:
-				result = cmp == 0
-			case lexer.NEQ:
-				result = cmp != 0
-			case lexer.LT:
-				result = cmp < 0
-			case lexer.GT:
-				result = cmp > 0
-			case lexer.LTE:
-				result = cmp <= 0
-			case lexer.GTE:
-				result = cmp >= 0
-			default:
-				return types.NewNull(), fmt.Errorf("unsupported binary operator: %v", ex.Op)
-			}
-			if result {
-				return types.NewInt(1), nil
-			}
-			return types.NewInt(0), nil
+			return e.evaluateComparison(ex.Op, left, right)
 		}
 	case *parser.UnaryExpr:
 		right, err := e.evaluateExprWithLocals(ex.Right, row, colMap, localVars)
 		if err != nil {
 			return types.NewNull(), err
 		}
+		if ex.Op == lexer.NOT {
+			return logicalNot(right), nil
+		}
 		if ex.Op == lexer.MINUS {
 			switch right.Type() {
 			case types.TypeInt32:
@@ -6297,6 +6332,42 @@ func (e *Executor) executePragma(stmt *parser.PragmaStmt) (*Result, error) {
 			},
 		}, nil
 
+	case "auto_vacuum":
+		return e.pragmaAutoVacuum(stmt)
+
+	case "incremental_vacuum":
+		return e.pragmaIncrementalVacuum(stmt)
+
+	case "wal_checkpoint":
+		return e.pragmaWALCheckpoint(stmt)
+
+	case "wal_autocheckpoint":
+		return e.pragmaWALAutoCheckpoint(stmt)
+
+	case "hnsw_ef_search":
+		if stmt.Value != nil {
+			// SET hnsw_ef_search = value (0 restores each index's own default)
+			val, err := e.evaluateExpr(stmt.Value, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hnsw_ef_search value: %w", err)
+			}
+
+			ef := val.Int()
+			if !types.IsIntegerType(val.Type()) || ef < 0 {
+				return nil, fmt.Errorf("hnsw_ef_search must be a non-negative integer, got %v", val)
+			}
+
+			e.hnswEfSearch = int(ef)
+			return &Result{RowsAffected: 0}, nil
+		}
+		// GET hnsw_ef_search
+		return &Result{
+			Columns: []string{"hnsw_ef_search"},
+			Rows: [][]types.Value{
+				{types.NewInt(int64(e.hnswEfSearch))},
+			},
+		}, nil
+
 	case "memory_budget":
 		memBudget := e.pager.MemoryBudget()
 		if stmt.Value != nil {
@@ -0,0 +1,250 @@
+// pkg/sql/executor/executor_aggregate.go
+// Aggregates beyond COUNT, SUM, AVG, MIN and MAX, which are computed with the
+// vdbe aggregate functions, and the FILTER and ORDER BY clauses that GROUP BY,
+// HAVING and window aggregates share.
+package executor
+
+import (
+	"fmt"
+	"sort"
+
+	"tur/pkg/sql/optimizer"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+	"tur/pkg/vdbe"
+)
+
+// aggregateArgCounts gives the smallest and largest number of arguments of
+// the aggregates computed by computeAggregate, counting the WITHIN GROUP
+// expression of an ordered-set aggregate
+var aggregateArgCounts = map[string][2]int{
+	"VAR_POP":               {1, 1},
+	"VAR_SAMP":              {1, 1},
+	"VARIANCE":              {1, 1},
+	"STDDEV_POP":            {1, 1},
+	"STDDEV_SAMP":           {1, 1},
+	"STDDEV":                {1, 1},
+	"GROUP_CONCAT":          {1, 2},
+	"STRING_AGG":            {2, 2},
+	"JSON_ARRAYAGG":         {1, 1},
+	"JSON_OBJECTAGG":        {2, 2},
+	"BOOL_AND":              {1, 1},
+	"BOOL_OR":               {1, 1},
+	"EVERY":                 {1, 1},
+	"PERCENTILE_CONT":       {2, 2},
+	"PERCENTILE_DISC":       {2, 2},
+	"MODE":                  {1, 1},
+	"APPROX_COUNT_DISTINCT": {1, 1},
+	"VECTOR_SUM":            {1, 1},
+	"VECTOR_AVG":            {1, 1},
+}
+
+// checkAggregateArgs validates the arguments of an aggregate and its use of
+// WITHIN GROUP, which percentiles require and only they and MODE accept
+func checkAggregateArgs(agg optimizer.AggregateExpr) error {
+	switch agg.FuncName {
+	case "PERCENTILE_CONT", "PERCENTILE_DISC":
+		if !agg.WithinGroup {
+			return fmt.Errorf("%s() requires WITHIN GROUP (ORDER BY ...)", agg.FuncName)
+		}
+	case "MODE":
+	default:
+		if agg.WithinGroup {
+			return fmt.Errorf("%s() does not support WITHIN GROUP", agg.FuncName)
+		}
+	}
+
+	if counts, ok := aggregateArgCounts[agg.FuncName]; ok {
+		if len(agg.Args) < counts[0] || len(agg.Args) > counts[1] {
+			return fmt.Errorf("wrong number of arguments to %s()", agg.FuncName)
+		}
+	}
+	return nil
+}
+
+// filterAggregateRows returns the rows for which the FILTER condition of an
+// aggregate is true, or all rows if it has none
+func (e *Executor) filterAggregateRows(filter parser.Expression, rows [][]types.Value, colMap map[string]int) ([][]types.Value, error) {
+	if filter == nil {
+		return rows, nil
+	}
+	var kept [][]types.Value
+	for _, row := range rows {
+		match, err := e.evaluateCondition(filter, row, colMap)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+// sortAggregateRows returns the rows in the ORDER BY order of an aggregate.
+// The sort is stable, so rows that compare equal keep their input order.
+func (e *Executor) sortAggregateRows(orderBy []parser.OrderByExpr, rows [][]types.Value, colMap map[string]int) ([][]types.Value, error) {
+	if len(orderBy) == 0 || len(rows) < 2 {
+		return rows, nil
+	}
+
+	keys := make([][]types.Value, len(rows))
+	for i, row := range rows {
+		keys[i] = make([]types.Value, len(orderBy))
+		for j, ob := range orderBy {
+			val, err := e.evaluateExpr(ob.Expr, row, colMap)
+			if err != nil {
+				return nil, err
+			}
+			keys[i][j] = val
+		}
+	}
+
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for j, ob := range orderBy {
+			cmp := compareValuesForSort(keys[order[a]][j], keys[order[b]][j])
+			if cmp == 0 {
+				continue
+			}
+			if ob.Direction == parser.OrderDesc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	sorted := make([][]types.Value, len(rows))
+	for i, idx := range order {
+		sorted[i] = rows[idx]
+	}
+	return sorted, nil
+}
+
+// computeAggregate computes an aggregate with its vdbe implementation. The
+// rows are those left after FILTER and DISTINCT; they are stepped in the
+// aggregate's ORDER BY order.
+func (e *Executor) computeAggregate(agg optimizer.AggregateExpr, rows [][]types.Value, colMap map[string]int) (types.Value, error) {
+	fn := vdbe.GetAggregate(agg.FuncName)
+	if fn == nil {
+		return types.NewNull(), fmt.Errorf("unknown aggregate function %s()", agg.FuncName)
+	}
+
+	if agg.FuncName == "PERCENTILE_CONT" || agg.FuncName == "PERCENTILE_DISC" {
+		fraction, err := e.evaluateExpr(agg.Args[1], nil, nil)
+		if err != nil {
+			return types.NewNull(), fmt.Errorf("%s() fraction must be a constant: %w", agg.FuncName, err)
+		}
+		numeric := isIntegerTypeForSort(fraction.Type()) || fraction.Type() == types.TypeFloat
+		if !numeric || toFloat(fraction) < 0 || toFloat(fraction) > 1 {
+			return types.NewNull(), fmt.Errorf("%s() fraction must be between 0 and 1", agg.FuncName)
+		}
+	}
+
+	rows, err := e.sortAggregateRows(agg.OrderBy, rows, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+
+	fn.Init()
+	args := make([]types.Value, len(agg.Args))
+	for _, row := range rows {
+		for i, argExpr := range agg.Args {
+			val, err := e.evaluateExpr(argExpr, row, colMap)
+			if err != nil {
+				return types.NewNull(), err
+			}
+			args[i] = val
+		}
+		vdbe.StepAggregate(fn, args)
+	}
+	return fn.Finalize(), nil
+}
+
+// resolveHavingAggregates replaces the aggregates in a HAVING condition with
+// their values over the rows of a group, leaving an expression the group's
+// output row can be evaluated against
+func (it *HashGroupByIterator) resolveHavingAggregates(expr parser.Expression, rows [][]types.Value) (parser.Expression, error) {
+	resolve := func(ex parser.Expression) (parser.Expression, error) {
+		return it.resolveHavingAggregates(ex, rows)
+	}
+
+	switch ex := expr.(type) {
+	case *parser.FunctionCall:
+		if agg, ok := optimizer.AggregateFromCall(ex); ok {
+			val, err := it.computeSingleAggregate(agg, rows)
+			if err != nil {
+				return nil, err
+			}
+			return &parser.Literal{Value: val}, nil
+		}
+		call := *ex
+		call.Args = make([]parser.Expression, len(ex.Args))
+		for i, arg := range ex.Args {
+			resolved, err := resolve(arg)
+			if err != nil {
+				return nil, err
+			}
+			call.Args[i] = resolved
+		}
+		return &call, nil
+	case *parser.BinaryExpr:
+		left, err := resolve(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := resolve(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.BinaryExpr{Left: left, Op: ex.Op, Right: right}, nil
+	case *parser.UnaryExpr:
+		right, err := resolve(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.UnaryExpr{Op: ex.Op, Right: right}, nil
+	case *parser.IsNullExpr:
+		inner, err := resolve(ex.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.IsNullExpr{Expr: inner, Not: ex.Not}, nil
+	case *parser.IsDistinctExpr:
+		left, err := resolve(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := resolve(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.IsDistinctExpr{Left: left, Not: ex.Not, Right: right}, nil
+	case *parser.BetweenExpr:
+		inner, err := resolve(ex.Expr)
+		if err != nil {
+			return nil, err
+		}
+		low, err := resolve(ex.Low)
+		if err != nil {
+			return nil, err
+		}
+		high, err := resolve(ex.High)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.BetweenExpr{Expr: inner, Not: ex.Not, Low: low, High: high}, nil
+	case *parser.CastExpr:
+		inner, err := resolve(ex.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.CastExpr{Expr: inner, Type: ex.Type}, nil
+	default:
+		return expr, nil
+	}
+}
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+// setupAggregateSales creates a sales table with two regions; one sale has
+// no amount
+func setupAggregateSales(t *testing.T, exec *Executor) {
+	t.Helper()
+	mustExec(t, exec, "CREATE TABLE sales (id INT PRIMARY KEY, region TEXT, rep TEXT, amount INT, returned INT)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (1, 'east', 'ann', 10, 0)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (2, 'east', 'bob', 20, 1)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (3, 'east', 'ann', 30, 0)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (4, 'west', 'cat', 40, 0)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (5, 'west', 'dan', NULL, 1)")
+	mustExec(t, exec, "INSERT INTO sales VALUES (6, 'west', 'cat', 60, 0)")
+}
+
+func TestAggregates_Statistical(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	got := queryRows(t, exec, "SELECT region, VAR_SAMP(amount), STDDEV_SAMP(amount), VAR_POP(amount), STDDEV_POP(amount) FROM sales GROUP BY region ORDER BY region")
+	if got != "east,100,10,66.66666666666667,8.16496580927726;west,200,14.142135623730951,100,10" {
+		t.Errorf("variance and stddev = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT stddev(amount), variance(amount) FROM sales WHERE id = 1"); got != "NULL,NULL" {
+		t.Errorf("sample stddev of one value = %q", got)
+	}
+}
+
+func TestAggregates_StringAgg(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	got := queryRows(t, exec, "SELECT region, STRING_AGG(rep, '-' ORDER BY amount DESC), GROUP_CONCAT(DISTINCT rep) FROM sales GROUP BY region ORDER BY region")
+	if got != "east,ann-bob-ann,ann,bob;west,cat-cat-dan,cat,dan" {
+		t.Errorf("STRING_AGG and GROUP_CONCAT = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT GROUP_CONCAT(amount, ' + ' ORDER BY rep DESC, id) FROM sales"); got != "40 + 60 + 20 + 10 + 30" {
+		t.Errorf("GROUP_CONCAT with a separator = %q", got)
+	}
+}
+
+func TestAggregates_BoolAndJSON(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	if got := queryRows(t, exec, "SELECT region, BOOL_AND(amount > 5), BOOL_OR(amount > 50), EVERY(returned = 0) FROM sales GROUP BY region ORDER BY region"); got != "east,1,0,0;west,1,1,0" {
+		t.Errorf("BOOL_AND and BOOL_OR = %q", got)
+	}
+
+	result, err := exec.Execute("SELECT JSON_ARRAYAGG(amount ORDER BY id), JSON_OBJECTAGG(rep, amount) FROM sales WHERE region = 'west'")
+	if err != nil {
+		t.Fatalf("JSON aggregates failed: %v", err)
+	}
+	row := result.Rows[0]
+	if row[0].JSON() != "[40,null,60]" {
+		t.Errorf("JSON_ARRAYAGG = %q", row[0].JSON())
+	}
+	if row[1].JSON() != `{"cat":60,"dan":null}` {
+		t.Errorf("JSON_OBJECTAGG = %q", row[1].JSON())
+	}
+}
+
+func TestAggregates_OrderedSet(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	got := queryRows(t, exec, "SELECT region, PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY amount), PERCENTILE_DISC(0.5) WITHIN GROUP (ORDER BY amount DESC), MODE() WITHIN GROUP (ORDER BY rep) FROM sales GROUP BY region ORDER BY region")
+	if got != "east,20,20,ann;west,50,60,cat" {
+		t.Errorf("ordered-set aggregates = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT PERCENTILE_CONT(0.25) WITHIN GROUP (ORDER BY amount), APPROX_COUNT_DISTINCT(rep), COUNT(DISTINCT rep) FROM sales"); got != "20,4,4" {
+		t.Errorf("PERCENTILE_CONT and APPROX_COUNT_DISTINCT = %q", got)
+	}
+}
+
+func TestAggregates_Filter(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	got := queryRows(t, exec, "SELECT region, COUNT(*) FILTER (WHERE returned = 1), SUM(amount) FILTER (WHERE returned = 0), STRING_AGG(rep, '/') FILTER (WHERE amount > 15) FROM sales GROUP BY region ORDER BY region")
+	if got != "east,1,40,bob/ann;west,1,100,cat/cat" {
+		t.Errorf("FILTER = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(*) FILTER (WHERE amount > 1000), MODE() WITHIN GROUP (ORDER BY rep) FILTER (WHERE amount > 1000) FROM sales"); got != "0,NULL" {
+		t.Errorf("FILTER matching nothing = %q", got)
+	}
+
+	// A view keeps the aggregate's ORDER BY and FILTER
+	mustExec(t, exec, "CREATE VIEW kept_reps AS SELECT region, STRING_AGG(rep, '/' ORDER BY id DESC) FILTER (WHERE returned = 0) FROM sales GROUP BY region")
+	if got := queryRows(t, exec, "SELECT * FROM kept_reps WHERE region = 'east'"); got != "east,ann/ann" {
+		t.Errorf("view with FILTER = %q", got)
+	}
+}
+
+func TestAggregates_Having(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	if got := queryRows(t, exec, "SELECT region, COUNT(*) FROM sales GROUP BY region HAVING SUM(amount) > 60"); got != "west,3" {
+		t.Errorf("HAVING SUM = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT region FROM sales GROUP BY region HAVING STDDEV_POP(amount) < 9 AND COUNT(*) FILTER (WHERE returned = 1) = 1"); got != "east,3" {
+		t.Errorf("HAVING with STDDEV_POP and FILTER = %q", got)
+	}
+}
+
+func TestAggregates_Nested(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	if got := queryRows(t, exec, "SELECT COUNT(*) + 1, ABS(-SUM(amount)), UPPER(MAX(rep)) FROM sales"); got != "7,160,DAN" {
+		t.Errorf("aggregates in expressions = %q", got)
+	}
+	got := queryRows(t, exec, "SELECT region, MAX(amount) - MIN(amount) AS spread, AVG(amount) * COUNT(amount) FROM sales GROUP BY region ORDER BY region DESC")
+	if got != "west,20,100;east,20,60" {
+		t.Errorf("aggregates in expressions per group = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(*) AS n FROM sales GROUP BY rep ORDER BY n DESC, rep"); got != "2;2;1;1" {
+		t.Errorf("ORDER BY an aliased aggregate = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT total FROM (SELECT region, SUM(amount) AS total FROM sales GROUP BY region) t ORDER BY total"); got != "60;100" {
+		t.Errorf("aliased aggregate in a derived table = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT CASE WHEN COUNT(*) > 1 THEN 'many' ELSE 'one' END FROM sales"); got != "many" {
+		t.Errorf("aggregate in CASE = %q", got)
+	}
+	got = queryRows(t, exec, "SELECT rep, CASE WHEN COUNT(*) > 1 THEN 'many' ELSE 'one' END, SUM(amount) IN (20, 40) FROM sales GROUP BY rep ORDER BY rep")
+	if got != "ann,many,1;bob,one,1;cat,many,0;dan,one,NULL" {
+		t.Errorf("aggregates in CASE and IN per group = %q", got)
+	}
+}
+
+func TestAggregates_SelectList(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	// Every select item is kept next to the aggregates, in its place
+	if got := queryRows(t, exec, "SELECT 5, COUNT(*) FROM sales"); got != "5,6" {
+		t.Errorf("literal next to an aggregate = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(*), ABS(-3) FROM sales"); got != "6,3" {
+		t.Errorf("scalar function next to an aggregate = %q", got)
+	}
+	result, err := exec.Execute("SELECT COUNT(*), region FROM sales GROUP BY region ORDER BY region")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if strings.Join(result.Columns, ",") != "COUNT,region" {
+		t.Errorf("columns = %v, want [COUNT region]", result.Columns)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(*), region FROM sales GROUP BY region ORDER BY region"); got != "3,east;3,west" {
+		t.Errorf("group column after an aggregate = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT (id / 3) * 10 + COUNT(*) FROM sales GROUP BY id / 3 ORDER BY id / 3"); got != "2;13;21" {
+		t.Errorf("grouped expression with an aggregate = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT region FROM sales GROUP BY region ORDER BY SUM(amount) DESC"); got != "west,3;east,3" {
+		t.Errorf("ORDER BY an aggregate not selected = %q", got)
+	}
+}
+
+func TestAggregates_Window(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	got := queryRows(t, exec, "SELECT id, STRING_AGG(rep, '/') OVER (PARTITION BY region ORDER BY id), PERCENTILE_DISC(0.5) WITHIN GROUP (ORDER BY amount) OVER (PARTITION BY region), COUNT(*) FILTER (WHERE returned = 0) OVER (ORDER BY id) FROM sales ORDER BY id")
+	want := "1,ann,20,1;2,ann/bob,20,1;3,ann/bob/ann,20,2;4,cat,40,3;5,cat/dan,40,3;6,cat/dan/cat,40,4"
+	if got != want {
+		t.Errorf("window aggregates = %q, want %q", got, want)
+	}
+	got = queryRows(t, exec, "SELECT id, VAR_POP(amount) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND CURRENT ROW), BOOL_OR(returned = 1) FILTER (WHERE amount IS NOT NULL) OVER (PARTITION BY region) FROM sales ORDER BY id")
+	want = "1,0,1;2,25,1;3,25,1;4,25,0;5,0,0;6,0,0"
+	if got != want {
+		t.Errorf("window frames and FILTER = %q, want %q", got, want)
+	}
+}
+
+func TestAggregates_Errors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAggregateSales(t, exec)
+
+	for sql, want := range map[string]string{
+		"SELECT PERCENTILE_CONT(0.5) FROM sales":                                  "requires WITHIN GROUP",
+		"SELECT PERCENTILE_CONT(1.5) WITHIN GROUP (ORDER BY amount) FROM sales":   "between 0 and 1",
+		"SELECT STRING_AGG(rep) FROM sales":                                       "wrong number of arguments",
+		"SELECT SUM(amount) WITHIN GROUP (ORDER BY amount) FROM sales":            "does not support WITHIN GROUP",
+		"SELECT id, STDDEV(amount, 1) OVER () FROM sales":                         "wrong number of arguments",
+		"SELECT SUM(COUNT(*)) FROM sales":                                         "cannot be nested",
+		"SELECT region, RANK() OVER (ORDER BY region) FROM sales GROUP BY region": "window functions are not supported",
+	} {
+		_, err := exec.Execute(sql)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want one containing %q", sql, err, want)
+		}
+	}
+}
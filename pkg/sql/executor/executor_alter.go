@@ -0,0 +1,1181 @@
+// pkg/sql/executor/executor_alter.go
+// ALTER TABLE: schema changes that reconcile the stored rows, the indexes,
+// views and triggers that refer to the table, and the schema B-tree.
+package executor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tur/pkg/dbfile"
+	"tur/pkg/record"
+	"tur/pkg/schema"
+	"tur/pkg/sql/lexer"
+	"tur/pkg/sql/parser"
+	"tur/pkg/tree"
+	"tur/pkg/types"
+)
+
+// executeAlterTable handles ALTER TABLE statements
+func (e *Executor) executeAlterTable(stmt *parser.AlterTableStmt) (*Result, error) {
+	if e.catalog.GetTable(stmt.TableName) == nil {
+		return nil, fmt.Errorf("table %s not found", stmt.TableName)
+	}
+	// Cached results no longer match the rows or the name of the table
+	defer e.InvalidateQueryCache(stmt.TableName)
+
+	switch stmt.Action {
+	case parser.AlterActionAddColumn:
+		return e.executeAlterTableAddColumn(stmt)
+	case parser.AlterActionDropColumn:
+		return e.executeAlterTableDropColumn(stmt)
+	case parser.AlterActionRenameTable:
+		defer e.InvalidateQueryCache(stmt.NewName)
+		return e.executeAlterTableRename(stmt)
+	case parser.AlterActionRenameColumn:
+		return e.executeAlterTableRenameColumn(stmt)
+	case parser.AlterActionAlterColumnType:
+		return e.executeAlterColumnType(stmt)
+	case parser.AlterActionSetDefault, parser.AlterActionDropDefault:
+		return e.executeAlterColumnDefault(stmt)
+	case parser.AlterActionSetNotNull:
+		return e.executeAlterColumnSetNotNull(stmt)
+	case parser.AlterActionDropNotNull:
+		return e.executeAlterColumnDropNotNull(stmt)
+	default:
+		return nil, fmt.Errorf("unsupported ALTER TABLE action")
+	}
+}
+
+// executeAlterTableAddColumn handles ALTER TABLE ADD COLUMN. The rows stay as
+// they are stored; the column reads as its default in the rows that lack it.
+func (e *Executor) executeAlterTableAddColumn(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	def := stmt.NewColumn
+
+	if def.PrimaryKey {
+		return nil, fmt.Errorf("cannot add PRIMARY KEY column %s to an existing table", def.Name)
+	}
+
+	// Convert parser column def to schema column def
+	col := schema.ColumnDef{
+		Name:        def.Name,
+		Type:        def.Type,
+		NotNull:     def.NotNull,
+		VectorDim:   def.VectorDim,
+		NoNormalize: def.NoNormalize,
+		MaxLength:   def.MaxLength,
+		Precision:   def.Precision,
+		Scale:       def.Scale,
+	}
+
+	// Build constraints if any
+	var constraints []schema.Constraint
+
+	if def.NotNull {
+		constraints = append(constraints, schema.Constraint{Type: schema.ConstraintNotNull})
+	}
+	if def.Unique {
+		constraints = append(constraints, schema.Constraint{Type: schema.ConstraintUnique})
+	}
+	if def.DefaultExpr != nil {
+		defaultVal, err := e.evaluateExpr(def.DefaultExpr, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate DEFAULT of column %s: %w", def.Name, err)
+		}
+		defaultVal, err = e.convertToColumnType(defaultVal, col)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT for column %s: %w", def.Name, err)
+		}
+		constraints = append(constraints, schema.Constraint{
+			Type:         schema.ConstraintDefault,
+			DefaultValue: &defaultVal,
+		})
+	}
+	if def.CheckExpr != nil {
+		constraints = append(constraints, schema.Constraint{
+			Type:            schema.ConstraintCheck,
+			CheckExpression: exprToString(def.CheckExpr),
+		})
+	}
+	if def.ForeignKey != nil {
+		if err := e.validateForeignKeyReference(def.ForeignKey.RefTable, def.ForeignKey.RefColumn); err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, schema.Constraint{
+			Type:      schema.ConstraintForeignKey,
+			RefTable:  def.ForeignKey.RefTable,
+			RefColumn: def.ForeignKey.RefColumn,
+			OnDelete:  convertFKAction(def.ForeignKey.OnDelete),
+			OnUpdate:  convertFKAction(def.ForeignKey.OnUpdate),
+		})
+	}
+
+	col.Constraints = constraints
+
+	// The existing rows take the default, which NOT NULL needs to be a value
+	if def.NotNull && col.DefaultValue().IsNull() && e.tableHasRows(table.Name) {
+		return nil, fmt.Errorf("cannot add NOT NULL column %s without a default to table %s, which has rows", def.Name, table.Name)
+	}
+
+	// Add column to catalog
+	if err := e.catalog.AddColumn(stmt.TableName, col); err != nil {
+		return nil, fmt.Errorf("failed to add column: %w", err)
+	}
+
+	if def.Unique {
+		if err := e.createUniqueConstraintIndexes(table); err != nil {
+			return nil, err
+		}
+		if err := e.rebuildIndexes(table); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterTableDropColumn handles ALTER TABLE DROP COLUMN. The column is
+// removed from every row, and the indexes and constraints on it are dropped.
+func (e *Executor) executeAlterTableDropColumn(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	col, pos := table.GetColumn(stmt.ColumnName)
+	if col == nil {
+		return nil, fmt.Errorf("failed to drop column: %w", schema.ErrColumnNotFound)
+	}
+	name := col.Name
+
+	if len(table.Columns) == 1 {
+		return nil, fmt.Errorf("cannot drop column %s: it is the only column of table %s", name, table.Name)
+	}
+	if isPrimaryKeyColumn(table, name) {
+		return nil, fmt.Errorf("cannot drop column %s: it is part of the PRIMARY KEY", name)
+	}
+	for _, ref := range e.catalog.GetForeignKeyReferences(table.Name, name) {
+		if ref.ReferencingTable != table.Name || ref.ReferencingColumn != name {
+			return nil, fmt.Errorf("cannot drop column %s: a foreign key of table %s references it", name, ref.ReferencingTable)
+		}
+	}
+	var viewNames []string
+	for _, view := range e.catalog.GetViewsDependingOn(table.Name) {
+		if viewReferencesColumn(view, table.Name, name) {
+			viewNames = append(viewNames, view.Name)
+		}
+	}
+	if len(viewNames) > 0 {
+		return nil, fmt.Errorf("cannot drop column %s: view(s) %s depend on it", name, strings.Join(viewNames, ", "))
+	}
+
+	// Rewrite the rows without the column before the schema changes
+	err := e.rewriteRows(table, func(stored []types.Value) ([]types.Value, error) {
+		row := table.FillMissingColumns(stored)
+		return append(row[:pos:pos], row[pos+1:]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the indexes that use the column
+	for _, idx := range e.catalog.GetIndexesForTable(table.Name) {
+		if !indexUsesColumn(idx, name) {
+			continue
+		}
+		if err := e.catalog.DropIndex(idx.Name); err != nil {
+			return nil, fmt.Errorf("failed to drop index %s: %w", idx.Name, err)
+		}
+		delete(e.trees, "index:"+idx.Name)
+		e.dropHNSWIndex(idx.Name)
+		if err := e.deleteSchemaEntry(idx.Name); err != nil {
+			// Best effort - indexes created with the table have no entry
+		}
+	}
+
+	// Drop the constraints that involve the column
+	var tableConstraints []schema.TableConstraint
+	for _, tc := range table.TableConstraints {
+		if containsString(tc.Columns, name) || referencesColumn(tc.CheckExpression, name) {
+			continue
+		}
+		tableConstraints = append(tableConstraints, tc)
+	}
+	table.TableConstraints = tableConstraints
+	for i := range table.Columns {
+		var constraints []schema.Constraint
+		for _, c := range table.Columns[i].Constraints {
+			if c.Type == schema.ConstraintCheck && i != pos && referencesColumn(c.CheckExpression, name) {
+				continue
+			}
+			constraints = append(constraints, c)
+		}
+		table.Columns[i].Constraints = constraints
+	}
+
+	if err := e.catalog.DropColumn(stmt.TableName, name); err != nil {
+		return nil, fmt.Errorf("failed to drop column: %w", err)
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterTableRename handles ALTER TABLE RENAME TO. Indexes, foreign
+// keys, views and triggers that refer to the table follow it.
+func (e *Executor) executeAlterTableRename(stmt *parser.AlterTableStmt) (*Result, error) {
+	oldName, newName := stmt.TableName, stmt.NewName
+	if e.catalog.GetView(newName) != nil {
+		return nil, fmt.Errorf("failed to rename table: view %s already exists", newName)
+	}
+	views := e.catalog.GetViewsDependingOn(oldName)
+	indexes := e.catalog.GetIndexesForTable(oldName)
+
+	// Rename in catalog
+	if err := e.catalog.RenameTable(oldName, newName); err != nil {
+		return nil, fmt.Errorf("failed to rename table: %w", err)
+	}
+	table := e.catalog.GetTable(newName)
+
+	// Update B-tree reference
+	if tree, exists := e.trees[oldName]; exists {
+		delete(e.trees, oldName)
+		e.trees[newName] = tree
+	}
+
+	// Update rowid references
+	if rowid, exists := e.rowid[oldName]; exists {
+		delete(e.rowid, oldName)
+		e.rowid[newName] = rowid
+	}
+	if maxRowid, exists := e.maxRowid[oldName]; exists {
+		delete(e.maxRowid, oldName)
+		e.maxRowid[newName] = maxRowid
+	}
+
+	// The schema entry is keyed by the table name
+	if err := e.deleteSchemaEntry(oldName); err != nil && err != tree.ErrKeyNotFound {
+		return nil, fmt.Errorf("failed to remove schema of table %s: %w", oldName, err)
+	}
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	for _, idx := range indexes {
+		idx.TableName = newName
+		if err := e.persistIndexSchema(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	e.renameForeignKeyTargets(oldName, "", newName, "")
+
+	// Views and triggers name the table unqualified or as the qualifier of
+	// its columns
+	renameTable := func(id sqlIdentifier) (string, bool) {
+		if id.name == oldName && id.qualifier == "" {
+			return newName, true
+		}
+		return "", false
+	}
+	for _, view := range views {
+		if err := e.replaceView(view, renameIdentifiers(view.SQL, renameTable)); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range e.catalog.ListTriggers() {
+		trigger := e.catalog.GetTrigger(name)
+		if err := e.replaceTrigger(trigger, renameIdentifiers(trigger.SQL, renameTable)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterTableRenameColumn handles ALTER TABLE RENAME COLUMN.
+// Constraints, indexes, foreign keys, views and triggers that refer to the
+// column follow it.
+func (e *Executor) executeAlterTableRenameColumn(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	oldName, newName := stmt.ColumnName, stmt.NewName
+
+	if err := e.catalog.RenameColumn(table.Name, oldName, newName); err != nil {
+		return nil, fmt.Errorf("failed to rename column: %w", err)
+	}
+
+	// Expressions on the table name the column unqualified or qualified by
+	// the table name
+	renameColumn := func(id sqlIdentifier) (string, bool) {
+		if id.name == oldName && !id.qualifies && (id.qualifier == "" || id.qualifier == table.Name) {
+			return newName, true
+		}
+		return "", false
+	}
+
+	for i := range table.Columns {
+		constraints := make([]schema.Constraint, len(table.Columns[i].Constraints))
+		copy(constraints, table.Columns[i].Constraints)
+		for j := range constraints {
+			if constraints[j].Type == schema.ConstraintCheck {
+				constraints[j].CheckExpression = renameIdentifiers(constraints[j].CheckExpression, renameColumn)
+			}
+		}
+		table.Columns[i].Constraints = constraints
+	}
+	for i := range table.TableConstraints {
+		tc := &table.TableConstraints[i]
+		tc.Columns = renameString(tc.Columns, oldName, newName)
+		if tc.CheckExpression != "" {
+			tc.CheckExpression = renameIdentifiers(tc.CheckExpression, renameColumn)
+		}
+	}
+
+	for _, idx := range e.catalog.GetIndexesForTable(table.Name) {
+		if !indexUsesColumn(idx, oldName) {
+			continue
+		}
+		idx.Columns = renameString(idx.Columns, oldName, newName)
+		expressions := make([]string, len(idx.Expressions))
+		for i, expr := range idx.Expressions {
+			expressions[i] = renameIdentifiers(expr, renameColumn)
+		}
+		idx.Expressions = expressions
+		idx.WhereClause = renameIdentifiers(idx.WhereClause, renameColumn)
+		if err := e.persistIndexSchema(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	e.renameForeignKeyTargets(table.Name, oldName, table.Name, newName)
+
+	for _, view := range e.catalog.GetViewsDependingOn(table.Name) {
+		isColumn := viewColumnMatcher(view, table.Name)
+		sql := renameIdentifiers(view.SQL, func(id sqlIdentifier) (string, bool) {
+			if !isColumn(id, oldName) {
+				return "", false
+			}
+			// Columns of the view keep their names
+			if id.selectItem {
+				return newName + " AS " + oldName, true
+			}
+			return newName, true
+		})
+		if err := e.replaceView(view, sql); err != nil {
+			return nil, err
+		}
+	}
+
+	// Triggers name the column qualified by the table, or by NEW and OLD
+	// if they are on it
+	for _, name := range e.catalog.ListTriggers() {
+		trigger := e.catalog.GetTrigger(name)
+		onTable := trigger.TableName == table.Name
+		sql := renameIdentifiers(trigger.SQL, func(id sqlIdentifier) (string, bool) {
+			if id.name != oldName || id.qualifies {
+				return "", false
+			}
+			if id.qualifier == table.Name ||
+				(onTable && (strings.EqualFold(id.qualifier, "NEW") || strings.EqualFold(id.qualifier, "OLD"))) {
+				return newName, true
+			}
+			return "", false
+		})
+		if err := e.replaceTrigger(trigger, sql); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterColumnType handles ALTER TABLE ALTER COLUMN TYPE. Every value
+// of the column, or what USING computes from its row, is converted to the
+// new type; the statement fails without changes if one cannot be.
+func (e *Executor) executeAlterColumnType(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	col, pos := table.GetColumn(stmt.ColumnName)
+	if col == nil {
+		return nil, fmt.Errorf("failed to alter column: %w", schema.ErrColumnNotFound)
+	}
+
+	newCol := *col
+	newCol.Type = stmt.NewColumn.Type
+	newCol.VectorDim = stmt.NewColumn.VectorDim
+	newCol.MaxLength = stmt.NewColumn.MaxLength
+	newCol.Precision = stmt.NewColumn.Precision
+	newCol.Scale = stmt.NewColumn.Scale
+
+	// Integer primary keys are the rowids of their rows
+	primaryKey := isPrimaryKeyColumn(table, col.Name)
+	if primaryKey && !(types.IsIntegerType(col.Type) && types.IsIntegerType(newCol.Type)) {
+		return nil, fmt.Errorf("cannot change the type of PRIMARY KEY column %s to %s", col.Name, columnTypeSQL(newCol))
+	}
+	for _, idx := range e.catalog.GetIndexesForTable(table.Name) {
+		if idx.Type == schema.IndexTypeHNSW && containsString(idx.Columns, col.Name) {
+			return nil, fmt.Errorf("cannot change the type of column %s: HNSW index %s uses it", col.Name, idx.Name)
+		}
+	}
+
+	if d := col.GetConstraint(schema.ConstraintDefault); d != nil && d.DefaultValue != nil {
+		defaultVal, err := e.convertToColumnType(*d.DefaultValue, newCol)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert DEFAULT of column %s: %w", col.Name, err)
+		}
+		newCol.Constraints = withConstraint(col.Constraints, schema.Constraint{
+			Type:         schema.ConstraintDefault,
+			DefaultValue: &defaultVal,
+		})
+	}
+
+	var colMap map[string]int
+	if stmt.Using != nil {
+		colMap = e.buildColMap(qualifiedColumnNames(table, ""))
+	}
+	notNull := primaryKey || col.HasConstraint(schema.ConstraintNotNull)
+	err := e.rewriteRows(table, func(stored []types.Value) ([]types.Value, error) {
+		row := table.FillMissingColumns(stored)
+		applyColumnTypes(table, row)
+		val := row[pos]
+		if stmt.Using != nil {
+			var err error
+			val, err = e.evaluateExpr(stmt.Using, row, colMap)
+			if err != nil {
+				return nil, err
+			}
+		}
+		val, err := e.convertToColumnType(val, newCol)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		if val.IsNull() && notNull {
+			return nil, fmt.Errorf("column %s: %w", col.Name, schema.ErrNotNullViolation)
+		}
+		row[pos] = val
+		return row, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.catalog.ReplaceColumn(table.Name, newCol); err != nil {
+		return nil, fmt.Errorf("failed to alter column: %w", err)
+	}
+	if err := e.rebuildIndexes(table); err != nil {
+		return nil, err
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterColumnDefault handles ALTER TABLE ALTER COLUMN SET DEFAULT and
+// DROP DEFAULT
+func (e *Executor) executeAlterColumnDefault(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	col, _ := table.GetColumn(stmt.ColumnName)
+	if col == nil {
+		return nil, fmt.Errorf("failed to alter column: %w", schema.ErrColumnNotFound)
+	}
+
+	newCol := *col
+	if stmt.Action == parser.AlterActionSetDefault {
+		defaultVal, err := e.evaluateExpr(stmt.DefaultExpr, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate DEFAULT of column %s: %w", col.Name, err)
+		}
+		defaultVal, err = e.convertToColumnType(defaultVal, *col)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT for column %s: %w", col.Name, err)
+		}
+		newCol.Constraints = withConstraint(col.Constraints, schema.Constraint{
+			Type:         schema.ConstraintDefault,
+			DefaultValue: &defaultVal,
+		})
+	} else {
+		newCol.Constraints = withoutConstraint(col.Constraints, schema.ConstraintDefault)
+	}
+
+	// Rows stored before ADD COLUMN read the current default, which they
+	// keep
+	err := e.rewriteRows(table, func(stored []types.Value) ([]types.Value, error) {
+		if len(stored) >= len(table.Columns) {
+			return nil, nil
+		}
+		return table.FillMissingColumns(stored), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.catalog.ReplaceColumn(table.Name, newCol); err != nil {
+		return nil, fmt.Errorf("failed to alter column: %w", err)
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterColumnSetNotNull handles ALTER TABLE ALTER COLUMN SET NOT NULL
+func (e *Executor) executeAlterColumnSetNotNull(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	col, pos := table.GetColumn(stmt.ColumnName)
+	if col == nil {
+		return nil, fmt.Errorf("failed to alter column: %w", schema.ErrColumnNotFound)
+	}
+	if col.HasConstraint(schema.ConstraintNotNull) {
+		return &Result{}, nil
+	}
+
+	if tableTree := e.GetTableTree(table.Name); tableTree != nil {
+		cursor := tableTree.Cursor()
+		defer cursor.Close()
+		for cursor.First(); cursor.Valid(); cursor.Next() {
+			if err := e.contextErr(); err != nil {
+				return nil, err
+			}
+			if decodeRow(table, cursor.Value())[pos].IsNull() {
+				return nil, fmt.Errorf("cannot set NOT NULL on column %s: it contains NULL values", col.Name)
+			}
+		}
+	}
+
+	newCol := *col
+	newCol.NotNull = true
+	newCol.Constraints = withConstraint(col.Constraints, schema.Constraint{Type: schema.ConstraintNotNull})
+	if err := e.catalog.ReplaceColumn(table.Name, newCol); err != nil {
+		return nil, fmt.Errorf("failed to alter column: %w", err)
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// executeAlterColumnDropNotNull handles ALTER TABLE ALTER COLUMN DROP NOT NULL
+func (e *Executor) executeAlterColumnDropNotNull(stmt *parser.AlterTableStmt) (*Result, error) {
+	table := e.catalog.GetTable(stmt.TableName)
+	col, _ := table.GetColumn(stmt.ColumnName)
+	if col == nil {
+		return nil, fmt.Errorf("failed to alter column: %w", schema.ErrColumnNotFound)
+	}
+	if isPrimaryKeyColumn(table, col.Name) {
+		return nil, fmt.Errorf("cannot drop NOT NULL of column %s: it is part of the PRIMARY KEY", col.Name)
+	}
+
+	newCol := *col
+	newCol.NotNull = false
+	newCol.Constraints = withoutConstraint(col.Constraints, schema.ConstraintNotNull)
+	if err := e.catalog.ReplaceColumn(table.Name, newCol); err != nil {
+		return nil, fmt.Errorf("failed to alter column: %w", err)
+	}
+
+	if err := e.persistTableSchema(table); err != nil {
+		return nil, err
+	}
+
+	return &Result{}, nil
+}
+
+// rewriteRows replaces every row of a table with what rewrite returns for
+// its values as stored, which may lack the columns added since; a nil result
+// leaves the row as it is. Nothing is written unless every row rewrites.
+func (e *Executor) rewriteRows(table *schema.TableDef, rewrite func(stored []types.Value) ([]types.Value, error)) error {
+	tableTree := e.GetTableTree(table.Name)
+	if tableTree == nil {
+		return nil
+	}
+
+	var keys [][]byte
+	var rows [][]types.Value
+	cursor := tableTree.Cursor()
+	for cursor.First(); cursor.Valid(); cursor.Next() {
+		if err := e.contextErr(); err != nil {
+			cursor.Close()
+			return err
+		}
+		row, err := rewrite(record.Decode(cursor.Value()))
+		if err != nil {
+			cursor.Close()
+			return err
+		}
+		if row == nil {
+			continue
+		}
+		key := cursor.Key()
+		keyCopy := make([]byte, len(key))
+		copy(keyCopy, key)
+		keys = append(keys, keyCopy)
+		rows = append(rows, row)
+	}
+	cursor.Close()
+
+	for i, key := range keys {
+		if err := tableTree.Insert(key, record.Encode(rows[i])); err != nil {
+			return fmt.Errorf("failed to rewrite row of table %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+// rebuildIndexes empties the indexes of a table and indexes its rows again
+func (e *Executor) rebuildIndexes(table *schema.TableDef) error {
+	if err := e.clearIndexes(table.Name); err != nil {
+		return err
+	}
+	tableTree := e.GetTableTree(table.Name)
+	if tableTree == nil {
+		return nil
+	}
+
+	cursor := tableTree.Cursor()
+	defer cursor.Close()
+	for cursor.First(); cursor.Valid(); cursor.Next() {
+		key := cursor.Key()
+		if len(key) < 8 {
+			continue
+		}
+		values := decodeRow(table, cursor.Value())
+		applyColumnTypes(table, values)
+		if err := e.updateIndexes(table, binary.BigEndian.Uint64(key), values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableHasRows reports whether a table has at least one row
+func (e *Executor) tableHasRows(tableName string) bool {
+	tableTree := e.GetTableTree(tableName)
+	if tableTree == nil {
+		return false
+	}
+	cursor := tableTree.Cursor()
+	defer cursor.Close()
+	cursor.First()
+	return cursor.Valid()
+}
+
+// persistTableSchema rewrites the schema entry of a table from its definition
+func (e *Executor) persistTableSchema(table *schema.TableDef) error {
+	entry := &dbfile.SchemaEntry{
+		Type:      dbfile.SchemaEntryTable,
+		Name:      table.Name,
+		TableName: table.Name,
+		RootPage:  table.RootPage,
+		SQL:       reconstructCreateTableSQL(table),
+	}
+	if err := e.persistSchemaEntry(entry); err != nil {
+		return fmt.Errorf("failed to persist schema of table %s: %w", table.Name, err)
+	}
+	return nil
+}
+
+// persistIndexSchema rewrites the schema entry of an index from its
+// definition. Indexes created along with their table have no entry.
+func (e *Executor) persistIndexSchema(idx *schema.IndexDef) error {
+	if _, err := e.schemaBTree.Get([]byte(idx.Name)); err == tree.ErrKeyNotFound {
+		return nil
+	}
+	entry, err := e.getSchemaEntry(idx.Name)
+	if err != nil {
+		return err
+	}
+
+	entry.TableName = idx.TableName
+	if idx.Type == schema.IndexTypeHNSW {
+		entry.SQL = reconstructCreateHNSWIndexSQL(idx)
+	} else {
+		entry.SQL = reconstructCreateIndexSQL(&parser.CreateIndexStmt{
+			IndexName: idx.Name,
+			TableName: idx.TableName,
+			Columns:   idx.Columns,
+			Unique:    idx.Unique,
+		})
+	}
+	if err := e.persistSchemaEntry(entry); err != nil {
+		return fmt.Errorf("failed to persist schema of index %s: %w", idx.Name, err)
+	}
+	return nil
+}
+
+// replaceView redefines a view as the query sql, if it differs from its
+// definition
+func (e *Executor) replaceView(view *schema.ViewDef, sql string) error {
+	if sql == view.SQL {
+		return nil
+	}
+	stmt, err := parser.New(sql).Parse()
+	if err != nil {
+		return fmt.Errorf("failed to update view %s: %w", view.Name, err)
+	}
+	query, ok := stmt.(*parser.SelectStmt)
+	if !ok {
+		return fmt.Errorf("failed to update view %s: not a SELECT", view.Name)
+	}
+
+	if err := e.catalog.DropView(view.Name); err != nil {
+		return err
+	}
+	_, err = e.executeCreateView(&parser.CreateViewStmt{
+		ViewName: view.Name,
+		Columns:  view.Columns,
+		Query:    query,
+	})
+	return err
+}
+
+// replaceTrigger redefines a trigger as the CREATE TRIGGER statement sql, if
+// it differs from its definition
+func (e *Executor) replaceTrigger(trigger *schema.TriggerDef, sql string) error {
+	if sql == trigger.SQL {
+		return nil
+	}
+	stmt, err := parser.New(sql).Parse()
+	if err != nil {
+		return fmt.Errorf("failed to update trigger %s: %w", trigger.Name, err)
+	}
+	createStmt, ok := stmt.(*parser.CreateTriggerStmt)
+	if !ok {
+		return fmt.Errorf("failed to update trigger %s: not a CREATE TRIGGER", trigger.Name)
+	}
+
+	if err := e.catalog.DropTrigger(trigger.Name); err != nil {
+		return err
+	}
+	_, err = e.executeCreateTrigger(createStmt)
+	return err
+}
+
+// renameForeignKeyTargets points the foreign keys that reference table at
+// newTable or, if oldColumn is not empty, the ones that reference its column
+// oldColumn at newColumn
+func (e *Executor) renameForeignKeyTargets(table, oldColumn, newTable, newColumn string) {
+	for _, name := range e.catalog.ListTables() {
+		t := e.catalog.GetTable(name)
+		for i := range t.Columns {
+			if !t.Columns[i].HasConstraint(schema.ConstraintForeignKey) {
+				continue
+			}
+			constraints := make([]schema.Constraint, len(t.Columns[i].Constraints))
+			copy(constraints, t.Columns[i].Constraints)
+			for j := range constraints {
+				c := &constraints[j]
+				if c.Type != schema.ConstraintForeignKey || c.RefTable != table {
+					continue
+				}
+				if oldColumn == "" {
+					c.RefTable = newTable
+				} else if c.RefColumn == oldColumn {
+					c.RefColumn = newColumn
+				}
+			}
+			t.Columns[i].Constraints = constraints
+		}
+		for i := range t.TableConstraints {
+			tc := &t.TableConstraints[i]
+			if tc.Type != schema.ConstraintForeignKey || tc.RefTable != table {
+				continue
+			}
+			if oldColumn == "" {
+				tc.RefTable = newTable
+			} else {
+				tc.RefColumns = renameString(tc.RefColumns, oldColumn, newColumn)
+			}
+		}
+	}
+}
+
+// convertToColumnType converts a value to the type of a column, as ALTER
+// COLUMN TYPE does with the values of the column and CAST does with its operand
+func (e *Executor) convertToColumnType(val types.Value, col schema.ColumnDef) (types.Value, error) {
+	if val.IsNull() {
+		return val, nil
+	}
+
+	src := val.Type()
+	switch {
+	case types.IsIntegerType(col.Type):
+		switch {
+		case types.IsIntegerType(src):
+		case src == types.TypeFloat:
+			val = types.NewInt(int64(val.Float()))
+		case isTextType(src):
+			n, err := strconv.ParseInt(strings.TrimSpace(val.Text()), 10, 64)
+			if err != nil {
+				return types.Value{}, fmt.Errorf("cannot convert '%s' to %s", val.Text(), columnTypeSQL(col))
+			}
+			val = types.NewInt(n)
+		default:
+			return types.Value{}, cannotConvert(src, col)
+		}
+
+	case col.Type == types.TypeFloat:
+		switch {
+		case src == types.TypeFloat:
+		case types.IsIntegerType(src):
+			val = types.NewFloat(float64(val.Int()))
+		case isTextType(src) || src == types.TypeDecimal:
+			text := val.Text()
+			if src == types.TypeDecimal {
+				text = val.DecimalString()
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return types.Value{}, fmt.Errorf("cannot convert '%s' to %s", text, columnTypeSQL(col))
+			}
+			val = types.NewFloat(f)
+		default:
+			return types.Value{}, cannotConvert(src, col)
+		}
+
+	case isTextType(col.Type):
+		text, ok := valueText(val)
+		if !ok {
+			return types.Value{}, cannotConvert(src, col)
+		}
+		val = types.NewText(text)
+
+	case col.Type == types.TypeDecimal:
+		switch {
+		case src == types.TypeDecimal:
+			val = types.NewText(val.DecimalString())
+		case types.IsIntegerType(src), src == types.TypeFloat, isTextType(src):
+		default:
+			return types.Value{}, cannotConvert(src, col)
+		}
+
+	case col.Type == types.TypeGUID:
+		if src != types.TypeGUID && !isTextType(src) {
+			return types.Value{}, cannotConvert(src, col)
+		}
+
+	case col.Type == types.TypeJSON:
+		text, ok := valueText(val)
+		if !ok {
+			return types.Value{}, cannotConvert(src, col)
+		}
+		val = types.NewJSON(text)
+
+	case col.Type == types.TypeBlob:
+		switch {
+		case src == types.TypeBlob:
+		case isTextType(src):
+			val = types.NewBlob([]byte(val.Text()))
+		default:
+			return types.Value{}, cannotConvert(src, col)
+		}
+
+	case col.Type == types.TypeVector:
+		if src != types.TypeBlob {
+			return types.Value{}, cannotConvert(src, col)
+		}
+		vec, err := types.VectorFromBytes(val.Blob())
+		if err != nil {
+			return types.Value{}, fmt.Errorf("invalid vector data: %w", err)
+		}
+		if vec.Dimension() != col.VectorDim {
+			return types.Value{}, fmt.Errorf("expected VECTOR(%d), got dimension %d", col.VectorDim, vec.Dimension())
+		}
+
+	default:
+		if src != col.Type {
+			return types.Value{}, cannotConvert(src, col)
+		}
+	}
+
+	return e.validateAndConvertStrictType(val, col)
+}
+
+// cannotConvert returns the error for a value of type src that a column
+// cannot take
+func cannotConvert(src types.ValueType, col schema.ColumnDef) error {
+	return fmt.Errorf("cannot convert %s to %s", src, columnTypeSQL(col))
+}
+
+// isTextType reports whether t holds text
+func isTextType(t types.ValueType) bool {
+	return t == types.TypeText || t == types.TypeVarchar || t == types.TypeChar
+}
+
+// valueText returns the text of a value that has a textual form
+func valueText(v types.Value) (string, bool) {
+	switch t := v.Type(); {
+	case isTextType(t):
+		return v.Text(), true
+	case types.IsIntegerType(t):
+		return strconv.FormatInt(v.Int(), 10), true
+	case t == types.TypeFloat:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case t == types.TypeDecimal:
+		return v.DecimalString(), true
+	case t == types.TypeGUID:
+		return v.GUIDString(), true
+	case t == types.TypeJSON:
+		return v.JSON(), true
+	default:
+		return "", false
+	}
+}
+
+// isPrimaryKeyColumn reports whether a column is part of the primary key of
+// its table
+func isPrimaryKeyColumn(table *schema.TableDef, name string) bool {
+	if col, _ := table.GetColumn(name); col != nil && (col.PrimaryKey || col.HasConstraint(schema.ConstraintPrimaryKey)) {
+		return true
+	}
+	for _, tc := range table.TableConstraints {
+		if tc.Type == schema.ConstraintPrimaryKey && containsString(tc.Columns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexUsesColumn reports whether an index keys on a column or filters by it
+func indexUsesColumn(idx *schema.IndexDef, name string) bool {
+	if containsString(idx.Columns, name) || referencesColumn(idx.WhereClause, name) {
+		return true
+	}
+	for _, expr := range idx.Expressions {
+		if referencesColumn(expr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// renameString returns a copy of list with oldName replaced by newName
+func renameString(list []string, oldName, newName string) []string {
+	if list == nil {
+		return nil
+	}
+	renamed := make([]string, len(list))
+	for i, item := range list {
+		if item == oldName {
+			item = newName
+		}
+		renamed[i] = item
+	}
+	return renamed
+}
+
+// withConstraint returns a copy of constraints in which c replaces the
+// constraint of its type, or is added if there is none
+func withConstraint(constraints []schema.Constraint, c schema.Constraint) []schema.Constraint {
+	result := withoutConstraint(constraints, c.Type)
+	return append(result, c)
+}
+
+// withoutConstraint returns a copy of constraints without those of type ct
+func withoutConstraint(constraints []schema.Constraint, ct schema.ConstraintType) []schema.Constraint {
+	var result []schema.Constraint
+	for _, c := range constraints {
+		if c.Type != ct {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// sqlIdentifier is an identifier of SQL text, with what surrounds it
+type sqlIdentifier struct {
+	name       string // the identifier
+	qualifier  string // the identifier before it in qualifier.name, or empty
+	qualifies  bool   // whether it is the qualifier of the identifier after it
+	selectItem bool   // whether it (qualified) is a whole item of a select list
+}
+
+// renameIdentifiers replaces the identifiers of SQL text for which rename
+// returns a new name. The names that the text gives to indexes, views and
+// triggers are not passed to rename, and neither are strings or quoted
+// identifiers.
+func renameIdentifiers(sql string, rename func(id sqlIdentifier) (string, bool)) string {
+	if sql == "" {
+		return sql
+	}
+
+	l := lexer.New(sql)
+	var tokens []lexer.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == lexer.EOF {
+			break
+		}
+		tokens = append(tokens, tok)
+	}
+
+	// Mark the tokens at the level of a select list, between SELECT and FROM
+	// outside of parentheses
+	type selectList struct {
+		depth int
+		open  bool
+	}
+	var lists []selectList
+	inList := make([]bool, len(tokens))
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.Type {
+		case lexer.LPAREN:
+			depth++
+		case lexer.RPAREN:
+			depth--
+			for len(lists) > 0 && lists[len(lists)-1].depth > depth {
+				lists = lists[:len(lists)-1]
+			}
+		case lexer.SELECT:
+			lists = append(lists, selectList{depth: depth, open: true})
+			inList[i] = true
+		default:
+			if n := len(lists); n > 0 && lists[n-1].open && lists[n-1].depth == depth {
+				inList[i] = true
+				if tok.Type == lexer.FROM {
+					lists[n-1].open = false
+				}
+			}
+		}
+	}
+
+	isIdentifier := func(tok lexer.Token) bool {
+		end := tok.Pos + len(tok.Literal)
+		if tok.Literal == "" || end > len(sql) || sql[tok.Pos:end] != tok.Literal {
+			return false
+		}
+		ch := tok.Literal[0]
+		return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+	}
+	tokenType := func(i int) lexer.TokenType {
+		if i < 0 || i >= len(tokens) {
+			return lexer.EOF
+		}
+		return tokens[i].Type
+	}
+
+	var sb strings.Builder
+	last := 0
+	for i, tok := range tokens {
+		if !isIdentifier(tok) {
+			continue
+		}
+		switch tokenType(i - 1) {
+		case lexer.INDEX, lexer.VIEW, lexer.TRIGGER:
+			continue
+		}
+
+		id := sqlIdentifier{name: tok.Literal, qualifies: tokenType(i+1) == lexer.DOT}
+		start := i
+		if tokenType(i-1) == lexer.DOT && i >= 2 {
+			id.qualifier = tokens[i-2].Literal
+			start = i - 2
+		}
+		if !id.qualifies && inList[start] {
+			prev, next := tokenType(start-1), tokenType(i+1)
+			id.selectItem = (prev == lexer.SELECT || prev == lexer.COMMA) && inList[start-1] &&
+				(next == lexer.EOF || ((next == lexer.COMMA || next == lexer.FROM) && inList[i+1]))
+		}
+
+		newName, ok := rename(id)
+		if !ok {
+			continue
+		}
+		sb.WriteString(sql[last:tok.Pos])
+		sb.WriteString(newName)
+		last = tok.Pos + len(tok.Literal)
+	}
+	if last == 0 {
+		return sql
+	}
+	sb.WriteString(sql[last:])
+	return sb.String()
+}
+
+// referencesColumn reports whether SQL text mentions a column by its name
+func referencesColumn(sql, column string) bool {
+	found := false
+	renameIdentifiers(sql, func(id sqlIdentifier) (string, bool) {
+		if id.name == column && !id.qualifies {
+			found = true
+		}
+		return "", false
+	})
+	return found
+}
+
+// viewColumnMatcher returns a function reporting whether an identifier of a
+// view is a column of table. Columns are qualified by the table name or its
+// aliases, or unqualified if the view selects from the table alone.
+func viewColumnMatcher(view *schema.ViewDef, table string) func(id sqlIdentifier, column string) bool {
+	qualifiers := map[string]bool{table: true}
+	alone := false
+	if stmt, err := parser.New(view.SQL).Parse(); err == nil {
+		if query, ok := stmt.(*parser.SelectStmt); ok {
+			if from, ok := query.From.(*parser.Table); ok && from.Name == table {
+				alone = true
+			}
+			collectTableAliases(query.From, table, qualifiers)
+		}
+	}
+	return func(id sqlIdentifier, column string) bool {
+		if id.name != column || id.qualifies {
+			return false
+		}
+		if id.qualifier == "" {
+			return alone
+		}
+		return qualifiers[id.qualifier]
+	}
+}
+
+// viewReferencesColumn reports whether a view uses a column of table
+func viewReferencesColumn(view *schema.ViewDef, table, column string) bool {
+	isColumn := viewColumnMatcher(view, table)
+	found := false
+	renameIdentifiers(view.SQL, func(id sqlIdentifier) (string, bool) {
+		if isColumn(id, column) {
+			found = true
+		}
+		return "", false
+	})
+	return found
+}
+
+// collectTableAliases adds the aliases that a FROM clause gives to table
+func collectTableAliases(ref parser.TableReference, table string, aliases map[string]bool) {
+	switch r := ref.(type) {
+	case *parser.Table:
+		if r.Name == table && r.Alias != "" {
+			aliases[r.Alias] = true
+		}
+	case *parser.Join:
+		collectTableAliases(r.Left, table, aliases)
+		collectTableAliases(r.Right, table, aliases)
+	}
+}
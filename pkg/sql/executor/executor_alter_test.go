@@ -0,0 +1,302 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tur/pkg/pager"
+	"tur/pkg/types"
+)
+
+func TestAlterTable_AddColumnFillsDefaultLazily(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT)")
+	mustExec(t, exec, "INSERT INTO users VALUES (1, 'alice')")
+	mustExec(t, exec, "INSERT INTO users VALUES (2, 'bob')")
+	mustExec(t, exec, "ALTER TABLE users ADD COLUMN score INT DEFAULT 10")
+	mustExec(t, exec, "ALTER TABLE users ADD COLUMN note TEXT")
+
+	if got := queryRows(t, exec, "SELECT * FROM users ORDER BY id"); got != "1,alice,10,NULL;2,bob,10,NULL" {
+		t.Errorf("rows = %q", got)
+	}
+
+	// The rows read as complete everywhere
+	mustExec(t, exec, "INSERT INTO users VALUES (3, 'carol', 5, 'new')")
+	mustExec(t, exec, "UPDATE users SET note = 'changed' WHERE id = 1")
+	if got := queryRows(t, exec, "SELECT id, note FROM users WHERE score = 10 ORDER BY id"); got != "1,changed;2,NULL" {
+		t.Errorf("rows with the default = %q", got)
+	}
+	mustExec(t, exec, "CREATE INDEX idx_score ON users (score)")
+	if got := queryRows(t, exec, "SELECT id FROM users WHERE score = 5"); got != "3" {
+		t.Errorf("rows through the index = %q", got)
+	}
+
+	// NOT NULL needs a default for the existing rows
+	if _, err := exec.Execute("ALTER TABLE users ADD COLUMN email TEXT NOT NULL"); err == nil {
+		t.Error("expected NOT NULL without a default to fail on a table with rows")
+	}
+	mustExec(t, exec, "ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT 'none'")
+	if got := queryRows(t, exec, "SELECT email FROM users WHERE id = 2"); got != "none" {
+		t.Errorf("email = %q, want none", got)
+	}
+}
+
+func TestAlterTable_DropColumnRewritesRows(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE items (id INT PRIMARY KEY, name TEXT, price INT, qty INT)")
+	mustExec(t, exec, "INSERT INTO items VALUES (1, 'pen', 3, 10)")
+	mustExec(t, exec, "INSERT INTO items VALUES (2, 'ink', 7, 20)")
+	mustExec(t, exec, "CREATE INDEX idx_price ON items (price)")
+	mustExec(t, exec, "CREATE INDEX idx_qty ON items (qty)")
+
+	mustExec(t, exec, "ALTER TABLE items DROP COLUMN price")
+
+	if got := queryRows(t, exec, "SELECT * FROM items ORDER BY id"); got != "1,pen,10;2,ink,20" {
+		t.Errorf("rows = %q", got)
+	}
+	if exec.catalog.GetIndex("idx_price") != nil {
+		t.Error("index on the dropped column still exists")
+	}
+	if got := queryRows(t, exec, "SELECT name FROM items WHERE qty = 20"); got != "ink" {
+		t.Errorf("rows through the remaining index = %q", got)
+	}
+	mustExec(t, exec, "INSERT INTO items VALUES (3, 'cap', 30)")
+	if got := queryRows(t, exec, "SELECT COUNT(*) FROM items"); got != "3" {
+		t.Errorf("count = %q", got)
+	}
+
+	if _, err := exec.Execute("ALTER TABLE items DROP COLUMN id"); err == nil {
+		t.Error("expected dropping the PRIMARY KEY column to fail")
+	}
+	mustExec(t, exec, "CREATE VIEW names AS SELECT name FROM items")
+	if _, err := exec.Execute("ALTER TABLE items DROP COLUMN name"); err == nil {
+		t.Error("expected dropping a column a view uses to fail")
+	}
+}
+
+func TestAlterTable_RenameColumn(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT, age INT CHECK (age >= 0))")
+	mustExec(t, exec, "CREATE TABLE log (msg TEXT)")
+	mustExec(t, exec, "INSERT INTO users VALUES (1, 'alice', 30)")
+	mustExec(t, exec, "CREATE INDEX idx_age ON users (age)")
+	mustExec(t, exec, "CREATE VIEW adults AS SELECT name, age FROM users WHERE age >= 18")
+	mustExec(t, exec, "CREATE TRIGGER trg AFTER INSERT ON users BEGIN INSERT INTO log SELECT users.name FROM users WHERE users.age > 35; END")
+	before, err := exec.Execute("SELECT * FROM adults")
+	if err != nil {
+		t.Fatalf("query on the view failed: %v", err)
+	}
+
+	mustExec(t, exec, "ALTER TABLE users RENAME COLUMN age TO years")
+	mustExec(t, exec, "ALTER TABLE users RENAME name TO full_name")
+
+	if got := queryRows(t, exec, "SELECT full_name, years FROM users"); got != "alice,30" {
+		t.Errorf("rows = %q", got)
+	}
+	if idx := exec.catalog.GetIndex("idx_age"); idx == nil || idx.Columns[0] != "years" {
+		t.Errorf("index columns not renamed: %+v", idx)
+	}
+	if got := queryRows(t, exec, "SELECT full_name FROM users WHERE years = 30"); got != "alice" {
+		t.Errorf("rows through the index = %q", got)
+	}
+	if _, err := exec.Execute("INSERT INTO users VALUES (2, 'bob', -1)"); err == nil {
+		t.Error("expected the renamed CHECK constraint to hold")
+	}
+
+	// The view keeps its column names
+	result, err := exec.Execute("SELECT * FROM adults")
+	if err != nil {
+		t.Fatalf("query on the view failed: %v", err)
+	}
+	if strings.Join(result.Columns, ",") != strings.Join(before.Columns, ",") {
+		t.Errorf("view columns = %v, want %v", result.Columns, before.Columns)
+	}
+
+	mustExec(t, exec, "INSERT INTO users VALUES (3, 'carol', 40)")
+	if got := queryRows(t, exec, "SELECT msg FROM log"); got != "carol" {
+		t.Errorf("trigger logged %q", got)
+	}
+
+	if _, err := exec.Execute("ALTER TABLE users RENAME COLUMN id TO years"); err == nil {
+		t.Error("expected renaming to an existing column to fail")
+	}
+}
+
+func TestAlterTable_AlterColumnType(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE t (id INT PRIMARY KEY, code INT DEFAULT 0, label TEXT)")
+	mustExec(t, exec, "INSERT INTO t VALUES (1, 42, '7')")
+	mustExec(t, exec, "INSERT INTO t VALUES (2, 5, '8')")
+	mustExec(t, exec, "CREATE INDEX idx_code ON t (code)")
+
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN code TYPE TEXT")
+	if got := queryRows(t, exec, "SELECT id FROM t WHERE code = '42'"); got != "1" {
+		t.Errorf("rows through the rebuilt index = %q", got)
+	}
+	if col, _ := exec.catalog.GetTable("t").GetColumn("code"); col.DefaultValue().Text() != "0" {
+		t.Errorf("default not converted: %+v", col.DefaultValue())
+	}
+
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN label SET DATA TYPE INT USING id * 10")
+	if got := queryRows(t, exec, "SELECT label FROM t ORDER BY id"); got != "10;20" {
+		t.Errorf("labels = %q", got)
+	}
+
+	// A value that does not convert leaves the table as it was
+	mustExec(t, exec, "INSERT INTO t VALUES (3, 'abc', 9)")
+	if _, err := exec.Execute("ALTER TABLE t ALTER COLUMN code TYPE INT"); err == nil {
+		t.Fatal("expected converting 'abc' to INT to fail")
+	}
+	if col, _ := exec.catalog.GetTable("t").GetColumn("code"); col.Type != types.TypeText {
+		t.Errorf("column type changed to %v", col.Type)
+	}
+	if got := queryRows(t, exec, "SELECT code FROM t ORDER BY id"); got != "42;5;abc" {
+		t.Errorf("codes = %q", got)
+	}
+
+	if _, err := exec.Execute("ALTER TABLE t ALTER COLUMN id TYPE TEXT"); err == nil {
+		t.Error("expected changing an integer PRIMARY KEY to TEXT to fail")
+	}
+}
+
+func TestAlterTable_DefaultAndNotNull(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE t (id INT PRIMARY KEY, v TEXT)")
+	mustExec(t, exec, "INSERT INTO t VALUES (1, 'a')")
+	mustExec(t, exec, "ALTER TABLE t ADD COLUMN status TEXT DEFAULT 'old'")
+
+	// Rows stored before ADD COLUMN keep the default they read
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN status SET DEFAULT 'new'")
+	mustExec(t, exec, "INSERT INTO t (id, v) VALUES (2, NULL)")
+	if got := queryRows(t, exec, "SELECT status FROM t ORDER BY id"); got != "old;new" {
+		t.Errorf("status = %q", got)
+	}
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN status DROP DEFAULT")
+	mustExec(t, exec, "INSERT INTO t (id, v) VALUES (3, 'c')")
+	if got := queryRows(t, exec, "SELECT status FROM t WHERE id = 3"); got != "NULL" {
+		t.Errorf("status without a default = %q", got)
+	}
+
+	if _, err := exec.Execute("ALTER TABLE t ALTER COLUMN v SET NOT NULL"); err == nil {
+		t.Fatal("expected SET NOT NULL to fail on a column with NULLs")
+	}
+	mustExec(t, exec, "UPDATE t SET v = 'b' WHERE id = 2")
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN v SET NOT NULL")
+	if _, err := exec.Execute("INSERT INTO t VALUES (4, NULL, 'x')"); err == nil {
+		t.Error("expected NOT NULL to hold")
+	}
+	mustExec(t, exec, "ALTER TABLE t ALTER v DROP NOT NULL")
+	mustExec(t, exec, "INSERT INTO t VALUES (4, NULL, 'x')")
+
+	if _, err := exec.Execute("ALTER TABLE t ALTER COLUMN id DROP NOT NULL"); err == nil {
+		t.Error("expected DROP NOT NULL on the PRIMARY KEY to fail")
+	}
+}
+
+func TestAlterTable_RenameTableFollowsDependents(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT)")
+	mustExec(t, exec, "CREATE TABLE log (msg TEXT)")
+	mustExec(t, exec, "CREATE INDEX idx_name ON users (name)")
+	mustExec(t, exec, "CREATE VIEW names AS SELECT users.name FROM users")
+	mustExec(t, exec, "CREATE TRIGGER trg AFTER INSERT ON users BEGIN INSERT INTO log SELECT users.name FROM users WHERE users.id = 2; END")
+	mustExec(t, exec, "INSERT INTO users VALUES (1, 'alice')")
+
+	mustExec(t, exec, "ALTER TABLE users RENAME TO people")
+	if _, err := exec.Execute("ALTER TABLE people RENAME TO log"); err == nil {
+		t.Error("expected renaming to an existing table to fail")
+	}
+
+	mustExec(t, exec, "INSERT INTO people VALUES (2, 'bob')")
+	if got := queryRows(t, exec, "SELECT name FROM names ORDER BY name"); got != "alice;bob" {
+		t.Errorf("view rows = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT msg FROM log"); got != "bob" {
+		t.Errorf("trigger logged %q", got)
+	}
+	if idx := exec.catalog.GetIndex("idx_name"); idx == nil || idx.TableName != "people" {
+		t.Errorf("index not moved to the new name: %+v", idx)
+	}
+	if got := exec.catalog.GetTrigger("trg").TableName; got != "people" {
+		t.Errorf("trigger table = %s, want people", got)
+	}
+}
+
+func TestAlterTable_RollsBackWithTransaction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE t (id INT PRIMARY KEY, a TEXT, b INT)")
+	mustExec(t, exec, "INSERT INTO t VALUES (1, 'x', 2)")
+
+	mustExec(t, exec, "BEGIN")
+	mustExec(t, exec, "ALTER TABLE t DROP COLUMN a")
+	mustExec(t, exec, "ALTER TABLE t ALTER COLUMN b TYPE TEXT")
+	mustExec(t, exec, "ALTER TABLE t RENAME COLUMN b TO c")
+	mustExec(t, exec, "ROLLBACK")
+
+	if got := queryRows(t, exec, "SELECT id, a, b FROM t"); got != "1,x,2" {
+		t.Errorf("rows after ROLLBACK = %q", got)
+	}
+	if col, _ := exec.catalog.GetTable("t").GetColumn("b"); col == nil || col.Type != types.TypeInt32 {
+		t.Errorf("column b after ROLLBACK = %+v", col)
+	}
+}
+
+func TestAlterTable_PersistsAcrossReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	mustExec(t, exec, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT, age INT)")
+	mustExec(t, exec, "INSERT INTO users VALUES (1, 'alice', 30)")
+	mustExec(t, exec, "CREATE INDEX idx_name ON users (name)")
+	mustExec(t, exec, "ALTER TABLE users ADD COLUMN city TEXT DEFAULT 'oslo'")
+	mustExec(t, exec, "ALTER TABLE users DROP COLUMN age")
+	mustExec(t, exec, "ALTER TABLE users RENAME COLUMN name TO full_name")
+	mustExec(t, exec, "ALTER TABLE users RENAME TO people")
+	mustExec(t, exec, "ALTER TABLE people ALTER COLUMN full_name SET NOT NULL")
+	if err := exec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	p, err = pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open reopen: %v", err)
+	}
+	exec = New(p)
+	defer exec.Close()
+
+	if exec.catalog.GetTable("users") != nil {
+		t.Error("old table name reloaded")
+	}
+	if got := queryRows(t, exec, "SELECT * FROM people"); got != "1,alice,oslo" {
+		t.Errorf("rows after reopen = %q", got)
+	}
+	if idx := exec.catalog.GetIndex("idx_name"); idx == nil || idx.TableName != "people" || idx.Columns[0] != "full_name" {
+		t.Errorf("index after reopen = %+v", idx)
+	}
+	if _, err := exec.Execute("INSERT INTO people (id, full_name) VALUES (2, NULL)"); err == nil {
+		t.Error("expected NOT NULL to be reloaded")
+	}
+	mustExec(t, exec, "INSERT INTO people (id, full_name) VALUES (2, 'bob')")
+	if got := queryRows(t, exec, "SELECT city FROM people WHERE full_name = 'bob'"); got != "oslo" {
+		t.Errorf("city default after reopen = %q", got)
+	}
+}
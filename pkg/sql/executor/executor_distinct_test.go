@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// setupDistinctDocs creates a docs table with repeated categories and users
+func setupDistinctDocs(t *testing.T, exec *Executor) {
+	t.Helper()
+	mustExec(t, exec, "CREATE TABLE docs (id INT PRIMARY KEY, category TEXT, title TEXT, created INT, user_id INT)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (1, 'news', 'a', 10, 1)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (2, 'blog', 'b', 20, 2)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (3, 'news', 'c', 30, 1)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (4, 'blog', 'd', 5, 3)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (5, 'news', 'e', 25, 2)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (6, NULL, 'f', 1, NULL)")
+	mustExec(t, exec, "INSERT INTO docs VALUES (7, NULL, 'g', 2, 3)")
+}
+
+// explainPlan returns the details of EXPLAIN QUERY PLAN joined by "|"
+func explainPlan(t *testing.T, exec *Executor, sql string) string {
+	t.Helper()
+	result, err := exec.Execute("EXPLAIN QUERY PLAN " + sql)
+	if err != nil {
+		t.Fatalf("explain failed (%s): %v", sql, err)
+	}
+	details := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		details[i] = row[len(row)-1].Text()
+	}
+	return strings.Join(details, "|")
+}
+
+func TestDistinct_SelectList(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupDistinctDocs(t, exec)
+
+	if got := queryRows(t, exec, "SELECT DISTINCT category FROM docs ORDER BY category"); got != "NULL;blog;news" {
+		t.Errorf("distinct categories = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT category, user_id FROM docs WHERE category = 'news' ORDER BY user_id"); got != "news,1;news,2" {
+		t.Errorf("distinct pairs = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT category FROM docs ORDER BY category LIMIT 1 OFFSET 1"); got != "blog" {
+		t.Errorf("distinct with limit = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT ALL category FROM docs WHERE category = 'blog'"); got != "blog;blog" {
+		t.Errorf("select all = %q", got)
+	}
+
+	mustExec(t, exec, "CREATE VIEW categories AS SELECT DISTINCT category FROM docs WHERE id < 6")
+	if got := queryRows(t, exec, "SELECT * FROM categories"); got != "news;blog" && got != "blog;news" {
+		t.Errorf("distinct view = %q", got)
+	}
+}
+
+func TestDistinct_Aggregates(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupDistinctDocs(t, exec)
+
+	got := queryRows(t, exec, "SELECT COUNT(DISTINCT user_id), SUM(DISTINCT user_id), AVG(DISTINCT user_id), MIN(DISTINCT user_id), MAX(DISTINCT user_id), COUNT(user_id) FROM docs")
+	if got != "3,6,2,1,3,6" {
+		t.Errorf("distinct aggregates = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(ALL category) FROM docs"); got != "5" {
+		t.Errorf("count all = %q", got)
+	}
+
+	got = queryRows(t, exec, "SELECT category, COUNT(DISTINCT user_id), COUNT(*) FROM docs GROUP BY category ORDER BY category")
+	if got != "NULL,1,2;blog,2,2;news,2,3" {
+		t.Errorf("distinct aggregates per group = %q", got)
+	}
+
+	// No rows: COUNT(DISTINCT) is 0 and the others NULL
+	if got := queryRows(t, exec, "SELECT COUNT(DISTINCT user_id), SUM(DISTINCT user_id) FROM docs WHERE id > 100"); got != "0,NULL" {
+		t.Errorf("distinct aggregates of no rows = %q", got)
+	}
+
+	for _, sql := range []string{
+		"SELECT COUNT(DISTINCT *) FROM docs",
+		"SELECT COUNT(DISTINCT user_id) OVER () FROM docs",
+	} {
+		if _, err := exec.Execute(sql); err == nil {
+			t.Errorf("expected an error for %s", sql)
+		}
+	}
+}
+
+func TestDistinct_On(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupDistinctDocs(t, exec)
+
+	// The newest document of each category; created is not selected
+	got := queryRows(t, exec, "SELECT DISTINCT ON (category) category, title FROM docs ORDER BY category, created DESC")
+	if got != "NULL,g;blog,b;news,c" {
+		t.Errorf("distinct on = %q", got)
+	}
+	if !strings.Contains(explainPlan(t, exec, "SELECT DISTINCT ON (category) category, title FROM docs ORDER BY category, created DESC"), "DISTINCT ON (SORTED INPUT)") {
+		t.Errorf("expected DISTINCT ON to use the sorted input")
+	}
+
+	// Select-list aliases resolve in DISTINCT ON and ORDER BY
+	got = queryRows(t, exec, "SELECT DISTINCT ON (c) category AS c, created FROM docs WHERE id < 6 ORDER BY c, created")
+	if got != "blog,5;news,10" {
+		t.Errorf("distinct on alias = %q", got)
+	}
+
+	// Without ORDER BY the first row read for each value is kept
+	if got := queryRows(t, exec, "SELECT DISTINCT ON (user_id) user_id FROM docs WHERE user_id > 0 ORDER BY user_id LIMIT 2"); got != "1;2" {
+		t.Errorf("distinct on with limit = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT COUNT(*) FROM (SELECT DISTINCT ON (user_id) id FROM docs) AS firsts"); got != "4" {
+		t.Errorf("distinct on without order = %q", got)
+	}
+
+	if _, err := exec.Execute("SELECT DISTINCT ON (category) title FROM docs ORDER BY created"); err == nil ||
+		!strings.Contains(err.Error(), "must match initial ORDER BY") {
+		t.Errorf("expected ORDER BY mismatch error, got %v", err)
+	}
+}
+
+func TestDistinct_UsesUniqueIndex(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupDistinctDocs(t, exec)
+
+	mustExec(t, exec, "CREATE TABLE tags (id INT PRIMARY KEY, code TEXT NOT NULL UNIQUE, label TEXT UNIQUE)")
+	mustExec(t, exec, "INSERT INTO tags VALUES (3, 'c', NULL)")
+	mustExec(t, exec, "INSERT INTO tags VALUES (1, 'a', NULL)")
+	mustExec(t, exec, "INSERT INTO tags VALUES (2, 'b', 'x')")
+
+	// The primary key index holds every id once: read it instead of the table
+	plan := explainPlan(t, exec, "SELECT DISTINCT id FROM tags")
+	if !strings.Contains(plan, "COVERING INDEX") || strings.Contains(plan, "DISTINCT") {
+		t.Errorf("plan = %q, want a covering index scan without DISTINCT", plan)
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT id FROM tags ORDER BY id"); got != "1;2;3" {
+		t.Errorf("distinct ids = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT code FROM tags ORDER BY code"); got != "a;b;c" {
+		t.Errorf("distinct codes = %q", got)
+	}
+
+	// Columns that include a unique NOT NULL column need no DISTINCT either
+	plan = explainPlan(t, exec, "SELECT DISTINCT code, label FROM tags WHERE id > 1")
+	if strings.Contains(plan, "DISTINCT") || strings.Contains(plan, "INDEX") {
+		t.Errorf("plan = %q, want a table scan without DISTINCT", plan)
+	}
+
+	// A unique column that allows NULLs can still repeat NULL, and its index
+	// keeps the NULL entries together
+	if !strings.Contains(explainPlan(t, exec, "SELECT DISTINCT label FROM tags"), "DISTINCT (SORTED INPUT)") {
+		t.Errorf("expected DISTINCT on a nullable unique column to read the index in order")
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT label FROM tags ORDER BY label"); got != "NULL;x" {
+		t.Errorf("distinct labels = %q", got)
+	}
+
+	// The index scan sees the changes of the current transaction
+	mustExec(t, exec, "BEGIN")
+	mustExec(t, exec, "INSERT INTO tags VALUES (4, 'd', NULL)")
+	mustExec(t, exec, "DELETE FROM tags WHERE id = 1")
+	if got := queryRows(t, exec, "SELECT DISTINCT id FROM tags ORDER BY id"); got != "2;3;4" {
+		t.Errorf("distinct ids in transaction = %q", got)
+	}
+	mustExec(t, exec, "ROLLBACK")
+	if got := queryRows(t, exec, "SELECT DISTINCT id FROM tags ORDER BY id"); got != "1;2;3" {
+		t.Errorf("distinct ids after rollback = %q", got)
+	}
+}
+
+func TestDistinct_UsesOrderedIndex(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	// Rowids of different widths between the duplicates of each value
+	mustExec(t, exec, "CREATE TABLE events (id INT PRIMARY KEY, kind TEXT, level INT)")
+	mustExec(t, exec, "CREATE INDEX idx_kind_level ON events (kind, level)")
+	kinds := []string{"'view'", "'read'", "NULL", "'sent'"}
+	for i := 1; i <= 400; i++ {
+		mustExec(t, exec, "INSERT INTO events VALUES ("+strconv.Itoa(i)+", "+kinds[i%4]+", "+strconv.Itoa(i/4%2)+")")
+	}
+
+	sql := "SELECT DISTINCT level, kind FROM events"
+	plan := explainPlan(t, exec, sql)
+	if !strings.Contains(plan, "DISTINCT (SORTED INPUT)") || !strings.Contains(plan, "COVERING INDEX idx_kind_level") {
+		t.Errorf("plan = %q, want DISTINCT over the index in order", plan)
+	}
+	want := "0,NULL;1,NULL;0,read;1,read;0,sent;1,sent;0,view;1,view"
+	if got := queryRows(t, exec, sql+" ORDER BY kind, level"); got != want {
+		t.Errorf("distinct pairs = %q", got)
+	}
+
+	// Duplicates stay out after changes, also those of a transaction
+	mustExec(t, exec, "DELETE FROM events WHERE kind = 'sent'")
+	mustExec(t, exec, "BEGIN")
+	mustExec(t, exec, "INSERT INTO events VALUES (100000, 'read', 1)")
+	mustExec(t, exec, "INSERT INTO events VALUES (100001, 'zoom', 0)")
+	want = "0,NULL;1,NULL;0,read;1,read;0,view;1,view;0,zoom"
+	if got := queryRows(t, exec, sql+" ORDER BY kind, level"); got != want {
+		t.Errorf("distinct pairs in transaction = %q", got)
+	}
+	mustExec(t, exec, "ROLLBACK")
+
+	// Other columns or a filter need the table, and the hash
+	for _, sql := range []string{
+		"SELECT DISTINCT kind FROM events",
+		"SELECT DISTINCT kind, level FROM events WHERE id > 10",
+	} {
+		if plan := explainPlan(t, exec, sql); !strings.Contains(plan, "DISTINCT (HASH)") {
+			t.Errorf("plan for %s = %q, want a hashed DISTINCT", sql, plan)
+		}
+	}
+	if got := queryRows(t, exec, "SELECT DISTINCT kind FROM events ORDER BY kind"); got != "NULL;read;view" {
+		t.Errorf("distinct kinds = %q", got)
+	}
+}
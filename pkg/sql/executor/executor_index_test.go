@@ -2,6 +2,8 @@ package executor
 
 import (
 	"encoding/binary"
+	"fmt"
+	"strings"
 	"testing"
 	"tur/pkg/record"
 	"tur/pkg/types"
@@ -32,7 +34,7 @@ func TestExecutor_Insert_UpdatesIndexes(t *testing.T) {
 	// Construct key for 'Alice'
 	// Key = Encode('Alice') + RowID(1) since it's non-unique
 	nameVal := types.NewText("Alice")
-	rowIDVal := types.NewInt(1)
+	rowIDVal := indexRowID(1)
 	key := record.Encode([]types.Value{nameVal, rowIDVal})
 
 	// Check existence
@@ -82,6 +84,47 @@ func TestExecutor_Insert_UpdatesUniqueIndex(t *testing.T) {
 
 // TestCreateIndex_PopulatesExistingData tests that CREATE INDEX populates
 // the index with existing data from the table.
+func TestExecutor_Insert_LongIndexKeys(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE docs (id INT, body TEXT)")
+	mustExec(t, exec, "CREATE INDEX idx_body ON docs (body)")
+	mustExec(t, exec, "CREATE UNIQUE INDEX idx_body_unique ON docs (id, body)")
+
+	// 2 KB keys that share all but their last characters, with duplicates
+	prefix := strings.Repeat("x", 2048)
+	for i := 0; i < 60; i++ {
+		mustExec(t, exec, fmt.Sprintf("INSERT INTO docs VALUES (%d, '%s%02d')", i, prefix, i%20))
+	}
+
+	result, err := exec.Execute(fmt.Sprintf("SELECT id FROM docs WHERE body = '%s07' ORDER BY id", prefix))
+	if err != nil {
+		t.Fatalf("select by long key: %v", err)
+	}
+	if got := len(result.Rows); got != 3 {
+		t.Fatalf("expected 3 rows for the long key, got %d", got)
+	}
+	for i, want := range []int64{7, 27, 47} {
+		if got := result.Rows[i][0].Int(); got != want {
+			t.Errorf("row %d: expected id %d, got %d", i, want, got)
+		}
+	}
+
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO docs VALUES (7, '%s07')", prefix)); err == nil {
+		t.Error("expected a unique violation for a duplicate long key")
+	}
+
+	mustExec(t, exec, fmt.Sprintf("DELETE FROM docs WHERE body = '%s07'", prefix))
+	result, err = exec.Execute(fmt.Sprintf("SELECT COUNT(*) FROM docs WHERE body >= '%s07'", prefix))
+	if err != nil {
+		t.Fatalf("count after delete: %v", err)
+	}
+	if got := result.Rows[0][0].Int(); got != 36 {
+		t.Errorf("expected 36 rows at or after the deleted key, got %d", got)
+	}
+}
+
 func TestCreateIndex_PopulatesExistingData(t *testing.T) {
 	exec, cleanup := setupTestExecutor(t)
 	defer cleanup()
@@ -122,21 +165,21 @@ func TestCreateIndex_PopulatesExistingData(t *testing.T) {
 	// Non-unique index: Key = Columns + RowID
 	// Note: INT columns now store values as TypeInt32
 	// Check entry for price=100, rowid=1
-	key1 := record.Encode([]types.Value{types.NewInt32(100), types.NewInt(1)})
+	key1 := record.Encode([]types.Value{types.NewInt32(100), indexRowID(1)})
 	_, err = tree.Get(key1)
 	if err != nil {
 		t.Errorf("Index entry for price=100, rowid=1 not found: %v", err)
 	}
 
 	// Check entry for price=50, rowid=2
-	key2 := record.Encode([]types.Value{types.NewInt32(50), types.NewInt(2)})
+	key2 := record.Encode([]types.Value{types.NewInt32(50), indexRowID(2)})
 	_, err = tree.Get(key2)
 	if err != nil {
 		t.Errorf("Index entry for price=50, rowid=2 not found: %v", err)
 	}
 
 	// Check entry for price=200, rowid=3
-	key3 := record.Encode([]types.Value{types.NewInt32(200), types.NewInt(3)})
+	key3 := record.Encode([]types.Value{types.NewInt32(200), indexRowID(3)})
 	_, err = tree.Get(key3)
 	if err != nil {
 		t.Errorf("Index entry for price=200, rowid=3 not found: %v", err)
@@ -712,7 +755,7 @@ func TestExpressionIndex_Insert_UpdatesIndex(t *testing.T) {
 
 	// For non-unique index, key = encoded(exprValue, rowID)
 	// exprValue = UPPER('Alice') = 'ALICE'
-	key := record.Encode([]types.Value{types.NewText("ALICE"), types.NewInt(1)})
+	key := record.Encode([]types.Value{types.NewText("ALICE"), indexRowID(1)})
 
 	_, err = tree.Get(key)
 	if err != nil {
@@ -749,7 +792,7 @@ func TestExpressionIndex_Insert_BinaryExpr(t *testing.T) {
 	}
 
 	// For non-unique index, key = encoded(exprValue, rowID)
-	key := record.Encode([]types.Value{types.NewInt(500), types.NewInt(1)})
+	key := record.Encode([]types.Value{types.NewInt(500), indexRowID(1)})
 
 	_, err = tree.Get(key)
 	if err != nil {
@@ -803,7 +846,7 @@ func TestExpressionIndex_Insert_MultipleRows(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		key := record.Encode([]types.Value{types.NewText(tc.name), types.NewInt(tc.rowID)})
+		key := record.Encode([]types.Value{types.NewText(tc.name), indexRowID(uint64(tc.rowID))})
 		_, err = tree.Get(key)
 		if err != nil {
 			t.Errorf("Index entry not found for LOWER('%s'): %v", tc.name, err)
@@ -8,6 +8,7 @@ import (
 	"tur/pkg/record"
 	"tur/pkg/schema"
 	"tur/pkg/sql/lexer"
+	"tur/pkg/sql/optimizer"
 	"tur/pkg/sql/parser"
 	"tur/pkg/types"
 	"tur/pkg/vdbe"
@@ -61,6 +62,14 @@ func (e *Executor) updateIndexes(table *schema.TableDef, rowID uint64, values []
 	}
 
 	for _, idx := range indexes {
+		// HNSW indexes live outside the B-tree layer
+		if idx.Type == schema.IndexTypeHNSW {
+			if err := e.insertIntoHNSWIndex(idx, table, rowID, values); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// For partial indexes, check if row matches the predicate
 		matches, err := e.matchesPartialIndexPredicate(idx, table, values)
 		if err != nil {
@@ -120,7 +129,7 @@ func (e *Executor) updateIndexes(table *schema.TableDef, rowID uint64, values []
 				// For rows with NULL values, we need to include rowID in key
 				// to allow multiple NULLs (since each gets a unique key)
 				keyValuesWithRowID := append([]types.Value{}, keyValues...)
-				keyValuesWithRowID = append(keyValuesWithRowID, types.NewInt(int64(rowID)))
+				keyValuesWithRowID = append(keyValuesWithRowID, indexRowID(rowID))
 				key = record.Encode(keyValuesWithRowID)
 				// Value is empty since rowID is in the key
 				value = []byte{}
@@ -143,7 +152,7 @@ func (e *Executor) updateIndexes(table *schema.TableDef, rowID uint64, values []
 		} else {
 			// Non-unique index: Key = Columns + RowID, Value = empty
 			// Append RowID to key values to make it unique
-			keyValues = append(keyValues, types.NewInt(int64(rowID)))
+			keyValues = append(keyValues, indexRowID(rowID))
 			key = record.Encode(keyValues)
 			value = []byte{}
 		}
@@ -156,6 +165,57 @@ func (e *Executor) updateIndexes(table *schema.TableDef, rowID uint64, values []
 	return nil
 }
 
+// indexRowID returns the rowid as it ends the key of an index entry. It is
+// always 8 bytes wide, so that the entries of equal column values share a
+// record header and are adjacent in index order.
+func indexRowID(rowID uint64) types.Value {
+	return types.NewBigInt(int64(rowID))
+}
+
+// clearIndexes removes every entry from the indexes of a table
+func (e *Executor) clearIndexes(tableName string) error {
+	if err := e.resetHNSWIndexes(tableName); err != nil {
+		return err
+	}
+	indexes := e.catalog.GetIndexesForTable(tableName)
+	for _, idx := range indexes {
+		if idx.Type == schema.IndexTypeHNSW {
+			continue
+		}
+		idxTreeName := "index:" + idx.Name
+		idxTree := e.trees[idxTreeName]
+		if idxTree == nil {
+			if idx.RootPage == 0 {
+				continue
+			}
+			var err error
+			idxTree, err = e.treeFactory.Open(idx.RootPage)
+			if err != nil {
+				return fmt.Errorf("failed to open btree for index %s: %w", idx.Name, err)
+			}
+			e.trees[idxTreeName] = idxTree
+		}
+
+		// Collect and delete all index entries
+		var idxKeysToDelete [][]byte
+		idxCursor := idxTree.Cursor()
+		for idxCursor.First(); idxCursor.Valid(); idxCursor.Next() {
+			key := idxCursor.Key()
+			keyCopy := make([]byte, len(key))
+			copy(keyCopy, key)
+			idxKeysToDelete = append(idxKeysToDelete, keyCopy)
+		}
+		idxCursor.Close()
+
+		for _, key := range idxKeysToDelete {
+			if err := idxTree.Delete(key); err != nil {
+				return fmt.Errorf("failed to delete index entry of %s: %w", idx.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // evaluateIndexExpressions parses and evaluates expression strings against row values
 func evaluateIndexExpressions(exprStrings []string, valMap map[string]types.Value) ([]types.Value, error) {
 	funcRegistry := vdbe.DefaultFunctionRegistry()
@@ -351,6 +411,12 @@ func (e *Executor) deleteFromIndexes(table *schema.TableDef, rowID uint64, value
 	}
 
 	for _, idx := range indexes {
+		// HNSW indexes live outside the B-tree layer
+		if idx.Type == schema.IndexTypeHNSW {
+			e.deleteFromHNSWIndex(idx, rowID)
+			continue
+		}
+
 		// For partial indexes, check if row matches the predicate
 		// Only need to delete if the row was in the index
 		matches, err := e.matchesPartialIndexPredicate(idx, table, values)
@@ -409,7 +475,7 @@ func (e *Executor) deleteFromIndexes(table *schema.TableDef, rowID uint64, value
 			if hasNull {
 				// For rows with NULL values, rowID is part of the key
 				keyValuesWithRowID := append([]types.Value{}, keyValues...)
-				keyValuesWithRowID = append(keyValuesWithRowID, types.NewInt(int64(rowID)))
+				keyValuesWithRowID = append(keyValuesWithRowID, indexRowID(rowID))
 				key = record.Encode(keyValuesWithRowID)
 			} else {
 				// Unique index with no NULLs: Key = Columns only
@@ -417,7 +483,7 @@ func (e *Executor) deleteFromIndexes(table *schema.TableDef, rowID uint64, value
 			}
 		} else {
 			// Non-unique index: Key = Columns + RowID
-			keyValues = append(keyValues, types.NewInt(int64(rowID)))
+			keyValues = append(keyValues, indexRowID(rowID))
 			key = record.Encode(keyValues)
 		}
 
@@ -431,3 +497,44 @@ func (e *Executor) deleteFromIndexes(table *schema.TableDef, rowID uint64, value
 
 	return nil
 }
+
+// executeIndexScan reads the indexed columns of a table from the entries of a
+// B-tree index, in index order
+func (e *Executor) executeIndexScan(node *optimizer.IndexScanNode) (RowIterator, []string, error) {
+	idx := e.catalog.GetIndex(node.IndexName)
+	if idx == nil {
+		return nil, nil, fmt.Errorf("index %s not found", node.IndexName)
+	}
+
+	idxTreeName := "index:" + idx.Name
+	idxTree := e.trees[idxTreeName]
+	if idxTree == nil {
+		var err error
+		idxTree, err = e.treeFactory.Open(idx.RootPage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open index btree %s: %w", idx.Name, err)
+		}
+		e.trees[idxTreeName] = idxTree
+	}
+
+	prefix := node.Table.Name
+	if node.Alias != "" {
+		prefix = node.Alias
+	}
+	cols := make([]string, len(node.Columns))
+	for i, name := range node.Columns {
+		if col, _ := node.Table.GetColumn(name); col != nil {
+			name = col.Name
+		}
+		cols[i] = prefix + "." + name
+	}
+
+	cursor := idxTree.Cursor()
+	cursor.First()
+	return &IndexScanIterator{
+		cursor:  cursor,
+		table:   node.Table,
+		columns: node.Columns,
+		cancel:  e.canceler(),
+	}, cols, nil
+}
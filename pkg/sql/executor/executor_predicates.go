@@ -0,0 +1,208 @@
+// pkg/sql/executor/executor_predicates.go
+// Predicates and SQL three-valued logic: a predicate is true (1), false (0)
+// or UNKNOWN, which is represented by NULL.
+package executor
+
+import (
+	"fmt"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/lexer"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// sqlBool converts a Go bool to the value a predicate yields
+func sqlBool(b bool) types.Value {
+	if b {
+		return types.NewInt(1)
+	}
+	return types.NewInt(0)
+}
+
+// isTrue reports whether a predicate value is true. NULL (UNKNOWN) is not.
+func isTrue(v types.Value) bool {
+	return !v.IsNull() && v.Int() != 0
+}
+
+// isFalse reports whether a predicate value is false. NULL (UNKNOWN) is not.
+func isFalse(v types.Value) bool {
+	return !v.IsNull() && v.Int() == 0
+}
+
+// logicalNot negates a predicate value; NOT UNKNOWN is UNKNOWN
+func logicalNot(v types.Value) types.Value {
+	if v.IsNull() {
+		return v
+	}
+	return sqlBool(v.Int() == 0)
+}
+
+// logicalAnd combines two predicate values: false if either is false,
+// otherwise UNKNOWN if either is UNKNOWN
+func logicalAnd(left, right types.Value) types.Value {
+	if isFalse(left) || isFalse(right) {
+		return sqlBool(false)
+	}
+	if left.IsNull() || right.IsNull() {
+		return types.NewNull()
+	}
+	return sqlBool(true)
+}
+
+// logicalOr combines two predicate values: true if either is true,
+// otherwise UNKNOWN if either is UNKNOWN
+func logicalOr(left, right types.Value) types.Value {
+	if isTrue(left) || isTrue(right) {
+		return sqlBool(true)
+	}
+	if left.IsNull() || right.IsNull() {
+		return types.NewNull()
+	}
+	return sqlBool(false)
+}
+
+// evaluateLogicalExpr evaluates AND and OR, skipping the right operand when
+// the left one decides the result
+func (e *Executor) evaluateLogicalExpr(expr *parser.BinaryExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	left, err := e.evaluateExpr(expr.Left, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	if expr.Op == lexer.AND && isFalse(left) {
+		return left, nil
+	}
+	if expr.Op == lexer.OR && isTrue(left) {
+		return left, nil
+	}
+
+	right, err := e.evaluateExpr(expr.Right, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	if expr.Op == lexer.AND {
+		return logicalAnd(left, right), nil
+	}
+	return logicalOr(left, right), nil
+}
+
+// evaluateComparison applies a comparison operator to two values.
+// Comparing anything with NULL is UNKNOWN.
+func (e *Executor) evaluateComparison(op lexer.TokenType, left, right types.Value) (types.Value, error) {
+	if left.IsNull() || right.IsNull() {
+		switch op {
+		case lexer.EQ, lexer.NEQ, lexer.LT, lexer.GT, lexer.LTE, lexer.GTE:
+			return types.NewNull(), nil
+		}
+	}
+
+	cmp := e.compareValues(left, right)
+	switch op {
+	case lexer.EQ:
+		return sqlBool(cmp == 0), nil
+	case lexer.NEQ:
+		return sqlBool(cmp != 0), nil
+	case lexer.LT:
+		return sqlBool(cmp < 0), nil
+	case lexer.GT:
+		return sqlBool(cmp > 0), nil
+	case lexer.LTE:
+		return sqlBool(cmp <= 0), nil
+	case lexer.GTE:
+		return sqlBool(cmp >= 0), nil
+	default:
+		return types.NewNull(), fmt.Errorf("unsupported operator: %v", op)
+	}
+}
+
+// evaluateIsNullExpr evaluates expr IS [NOT] NULL, which is never UNKNOWN
+func (e *Executor) evaluateIsNullExpr(expr *parser.IsNullExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	val, err := e.evaluateExpr(expr.Expr, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	return sqlBool(val.IsNull() != expr.Not), nil
+}
+
+// evaluateIsDistinctExpr evaluates a IS [NOT] DISTINCT FROM b. Two NULLs are
+// not distinct, and a NULL is distinct from any other value.
+func (e *Executor) evaluateIsDistinctExpr(expr *parser.IsDistinctExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	left, err := e.evaluateExpr(expr.Left, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	right, err := e.evaluateExpr(expr.Right, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+
+	var distinct bool
+	if left.IsNull() || right.IsNull() {
+		distinct = left.IsNull() != right.IsNull()
+	} else {
+		distinct = e.compareValues(left, right) != 0
+	}
+	return sqlBool(distinct != expr.Not), nil
+}
+
+// evaluateBetweenExpr evaluates x [NOT] BETWEEN low AND high as
+// [NOT] (x >= low AND x <= high)
+func (e *Executor) evaluateBetweenExpr(expr *parser.BetweenExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	val, err := e.evaluateExpr(expr.Expr, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	low, err := e.evaluateExpr(expr.Low, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	high, err := e.evaluateExpr(expr.High, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+
+	aboveLow, err := e.evaluateComparison(lexer.GTE, val, low)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	belowHigh, err := e.evaluateComparison(lexer.LTE, val, high)
+	if err != nil {
+		return types.NewNull(), err
+	}
+
+	result := logicalAnd(aboveLow, belowHigh)
+	if expr.Not {
+		return logicalNot(result), nil
+	}
+	return result, nil
+}
+
+// evaluateCastExpr evaluates CAST(expr AS type). The value is converted the
+// way a column of that type converts it, so out-of-range numbers, over-long
+// VARCHARs and DECIMALs that exceed their precision are errors.
+func (e *Executor) evaluateCastExpr(expr *parser.CastExpr, rowValues []types.Value, colMap map[string]int) (types.Value, error) {
+	val, err := e.evaluateExpr(expr.Expr, rowValues, colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	if val.IsNull() {
+		return val, nil
+	}
+
+	result, err := e.convertToColumnType(val, castColumn(expr.Type))
+	if err != nil {
+		return types.NewNull(), fmt.Errorf("CAST: %w", err)
+	}
+	return result, nil
+}
+
+// castColumn describes the target type of a CAST as a column definition
+func castColumn(info *parser.TypeInfo) schema.ColumnDef {
+	return schema.ColumnDef{
+		Type:      info.Type,
+		VectorDim: info.VectorDim,
+		MaxLength: info.MaxLength,
+		Precision: info.Precision,
+		Scale:     info.Scale,
+	}
+}
@@ -0,0 +1,189 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"tur/pkg/types"
+)
+
+// setupPredicateItems creates an items table where some prices and
+// quantities are NULL
+func setupPredicateItems(t *testing.T, exec *Executor) {
+	t.Helper()
+	mustExec(t, exec, "CREATE TABLE items (id INT PRIMARY KEY, name TEXT, price INT, qty INT)")
+	mustExec(t, exec, "INSERT INTO items VALUES (1, 'apple', 10, 5)")
+	mustExec(t, exec, "INSERT INTO items VALUES (2, 'pear', NULL, 3)")
+	mustExec(t, exec, "INSERT INTO items VALUES (3, 'plum', 30, NULL)")
+	mustExec(t, exec, "INSERT INTO items VALUES (4, NULL, 40, 0)")
+}
+
+func TestPredicates_IsNull(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price IS NULL"); got != "2" {
+		t.Errorf("IS NULL = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE name IS NOT NULL AND qty IS NOT NULL ORDER BY id"); got != "1;2" {
+		t.Errorf("IS NOT NULL = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id, price IS NULL FROM items ORDER BY id"); got != "1,0;2,1;3,0;4,0" {
+		t.Errorf("IS NULL in select list = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price = NULL"); got != "" {
+		t.Errorf("= NULL should match nothing, got %q", got)
+	}
+}
+
+func TestPredicates_ThreeValuedLogic(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	tests := []struct {
+		where string
+		want  string
+	}{
+		// NOT of UNKNOWN is UNKNOWN, so rows with a NULL price never match
+		{"price > 20", "3;4"},
+		{"NOT price > 20", "1"},
+		{"NOT (price > 20)", "1"},
+		{"price <> 10", "3;4"},
+		// UNKNOWN AND false is false; UNKNOWN OR true is true
+		{"NOT (price > 20 AND qty > 4)", "1;2;4"},
+		{"price > 20 OR qty = 3", "2;3;4"},
+		{"NOT (price > 100 OR qty > 4)", "4"},
+		// IN with a NULL in the list is UNKNOWN unless the value matches
+		{"price IN (10, NULL)", "1"},
+		{"price NOT IN (10, NULL)", ""},
+		{"price NOT IN (10, 30)", "4"},
+		{"name LIKE 'p%'", "2;3"},
+		{"NOT name LIKE 'p%'", "1"},
+	}
+	for _, tt := range tests {
+		if got := queryRows(t, exec, "SELECT id FROM items WHERE "+tt.where+" ORDER BY id"); got != tt.want {
+			t.Errorf("WHERE %s = %q, want %q", tt.where, got, tt.want)
+		}
+	}
+
+	if got := queryRows(t, exec, "SELECT id, price > 20 AND qty > 4, price > 20 OR qty > 4, NOT qty > 4 FROM items ORDER BY id"); got != "1,0,1,0;2,0,NULL,1;3,NULL,1,NULL;4,0,1,1" {
+		t.Errorf("predicate values = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id, CASE WHEN NOT price > 20 THEN 'low' ELSE 'other' END FROM items ORDER BY id"); got != "1,low;2,other;3,other;4,other" {
+		t.Errorf("CASE with UNKNOWN condition = %q", got)
+	}
+}
+
+func TestPredicates_IsDistinctFrom(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price IS DISTINCT FROM 10 ORDER BY id"); got != "2;3;4" {
+		t.Errorf("IS DISTINCT FROM = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price IS NOT DISTINCT FROM NULL"); got != "2" {
+		t.Errorf("IS NOT DISTINCT FROM NULL = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id, price IS DISTINCT FROM qty FROM items ORDER BY id"); got != "1,1;2,1;3,1;4,1" {
+		t.Errorf("IS DISTINCT FROM values = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT NULL IS NOT DISTINCT FROM NULL, 1 IS DISTINCT FROM 1"); got != "1,0" {
+		t.Errorf("IS DISTINCT FROM literals = %q", got)
+	}
+}
+
+func TestPredicates_Between(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price BETWEEN 10 AND 30 ORDER BY id"); got != "1;3" {
+		t.Errorf("BETWEEN = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price NOT BETWEEN 20 AND 35 ORDER BY id"); got != "1;4" {
+		t.Errorf("NOT BETWEEN = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE price BETWEEN 10 AND 40 AND qty > 1 ORDER BY id"); got != "1" {
+		t.Errorf("BETWEEN followed by AND = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE name BETWEEN 'b' AND 'pl' ORDER BY id"); got != "2" {
+		t.Errorf("BETWEEN on text = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT 5 BETWEEN 1 AND NULL, 5 BETWEEN 6 AND NULL"); got != "NULL,0" {
+		t.Errorf("BETWEEN with a NULL bound = %q", got)
+	}
+}
+
+func TestPredicates_Cast(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	if got := queryRows(t, exec, "SELECT CAST('42' AS INT) + 1, CAST(price AS TEXT), CAST(NULL AS INT) FROM items WHERE id = 1"); got != "43,10,NULL" {
+		t.Errorf("CAST = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT id FROM items WHERE CAST(price AS FLOAT) / 4 > 7 ORDER BY id"); got != "3;4" {
+		t.Errorf("CAST in WHERE = %q", got)
+	}
+
+	result, err := exec.Execute("SELECT CAST(price AS DECIMAL(10,2)), CAST('3.5' AS DECIMAL(5,3)) FROM items WHERE id = 3")
+	if err != nil {
+		t.Fatalf("CAST to DECIMAL failed: %v", err)
+	}
+	row := result.Rows[0]
+	if row[0].Type() != types.TypeDecimal || row[0].DecimalString() != "30.00" {
+		t.Errorf("CAST(30 AS DECIMAL(10,2)) = %v %q", row[0].Type(), row[0].DecimalString())
+	}
+	if row[1].DecimalString() != "3.500" {
+		t.Errorf("CAST('3.5' AS DECIMAL(5,3)) = %q", row[1].DecimalString())
+	}
+
+	for sql, want := range map[string]string{
+		"SELECT CAST('abc' AS INT)":            "cannot convert",
+		"SELECT CAST(100000 AS SMALLINT)":      "out of range",
+		"SELECT CAST('toolong' AS VARCHAR(3))": "VARCHAR",
+		"SELECT CAST(12345.6 AS DECIMAL(4,1))": "precision",
+		"SELECT CAST(X'01' AS INT) FROM items": "cannot convert",
+	} {
+		_, err := exec.Execute(sql)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want one containing %q", sql, err, want)
+		}
+	}
+}
+
+func TestPredicates_CheckConstraintAllowsUnknown(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	mustExec(t, exec, "CREATE TABLE stock (id INT PRIMARY KEY, qty INT CHECK (qty >= 0))")
+	mustExec(t, exec, "INSERT INTO stock VALUES (1, NULL)")
+	if _, err := exec.Execute("INSERT INTO stock VALUES (2, -1)"); err == nil {
+		t.Error("expected CHECK violation for a negative qty")
+	}
+	if got := queryRows(t, exec, "SELECT id FROM stock WHERE qty IS NULL"); got != "1" {
+		t.Errorf("rows with NULL qty = %q", got)
+	}
+}
+
+func TestPredicates_ViewAndJoin(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupPredicateItems(t, exec)
+
+	mustExec(t, exec, "CREATE VIEW priced AS SELECT id, CAST(price AS TEXT) AS label FROM items WHERE price IS NOT NULL AND price NOT BETWEEN 20 AND 35")
+	if got := queryRows(t, exec, "SELECT * FROM priced ORDER BY id"); got != "1,10;4,40" {
+		t.Errorf("view with predicates = %q", got)
+	}
+
+	// NULL join keys equal nothing
+	mustExec(t, exec, "CREATE TABLE tags (price INT, tag TEXT)")
+	mustExec(t, exec, "INSERT INTO tags VALUES (10, 'cheap')")
+	mustExec(t, exec, "INSERT INTO tags VALUES (NULL, 'unknown')")
+	if got := queryRows(t, exec, "SELECT items.id, tags.tag FROM items JOIN tags ON items.price = tags.price ORDER BY items.id"); got != "1,cheap" {
+		t.Errorf("join on nullable key = %q", got)
+	}
+}
@@ -41,6 +41,9 @@ func (e *Executor) executeRecursiveCTE(cte parser.CTE, op *parser.SetOperation,
 	depth := 0
 
 	for len(workingRows) > 0 {
+		if err := e.contextErr(); err != nil {
+			return nil, err
+		}
 		if depth > maxDepth {
 			return nil, fmt.Errorf("recursion limit exceeded (%d)", maxDepth)
 		}
@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +12,7 @@ import (
 	"tur/pkg/cache"
 	"tur/pkg/pager"
 	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
 	"tur/pkg/types"
 )
 
@@ -3884,3 +3887,175 @@ func TestPragmaOptimizeMemory(t *testing.T) {
 		t.Errorf("Expected result_streaming = ON, got %s", result.Rows[0][0].Text())
 	}
 }
+
+func TestExecuteAST_Placeholders(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, sql := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT)",
+		"CREATE TABLE audit (tag TEXT)",
+		"CREATE TRIGGER users_audit AFTER INSERT ON users BEGIN INSERT INTO audit VALUES (@tag); END",
+		"SET @tag = 'session'",
+	} {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	// The AST keeps its placeholders and takes new values every time
+	insert, err := parser.New("INSERT INTO users VALUES (?, @name)").Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	for i, name := range []string{"alice", "bob"} {
+		params := []types.Value{types.NewInt(int64(i + 1)), types.NewText(name)}
+		if _, err := exec.ExecuteAST(insert, params); err != nil {
+			t.Fatalf("INSERT failed: %v", err)
+		}
+	}
+	result, err := exec.Execute("SELECT name FROM users ORDER BY id")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0][0].Text() != "alice" || result.Rows[1][0].Text() != "bob" {
+		t.Errorf("expected alice and bob, got %v", result.Rows)
+	}
+
+	// Trigger statements have no parameters, their @tag is the session variable
+	result, err = exec.Execute("SELECT tag FROM audit")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0][0].Text() != "session" {
+		t.Errorf("expected the session variable in the audit rows, got %v", result.Rows)
+	}
+
+	// Without parameters, @name reads the session variable
+	query, err := parser.New("SELECT @tag").Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	for _, tt := range []struct {
+		params []types.Value
+		want   string
+	}{
+		{nil, "session"},
+		{[]types.Value{types.NewText("bound")}, "bound"},
+	} {
+		result, err := exec.ExecuteAST(query, tt.params)
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		if len(result.Rows) != 1 || result.Rows[0][0].Text() != tt.want {
+			t.Errorf("expected %q, got %v", tt.want, result.Rows)
+		}
+	}
+
+	// So does a @name parameter given no value next to other parameters
+	mixed, err := parser.New("SELECT ?, @tag").Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	result, err = exec.ExecuteAST(mixed, []types.Value{types.NewText("first")})
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Text() != "first" || result.Rows[0][1].Text() != "session" {
+		t.Errorf("expected first and the session variable, got %v", result.Rows)
+	}
+}
+
+// cancelAfter is a context canceled on the given call of Err, so that
+// statements stop at a known point
+type cancelAfter struct {
+	context.Context
+	calls int
+	done  chan struct{}
+}
+
+func newCancelAfter(calls int) *cancelAfter {
+	return &cancelAfter{Context: context.Background(), calls: calls, done: make(chan struct{})}
+}
+
+func (c *cancelAfter) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *cancelAfter) Err() error {
+	if c.calls--; c.calls > 0 {
+		return nil
+	}
+	return context.Canceled
+}
+
+func TestExecuteASTContext_Cancel(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if _, err := exec.Execute("CREATE TABLE t (id INT PRIMARY KEY, v INT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	countRows := func() int64 {
+		t.Helper()
+		result, err := exec.Execute("SELECT COUNT(*) FROM t")
+		if err != nil {
+			t.Fatalf("SELECT COUNT failed: %v", err)
+		}
+		return result.Rows[0][0].Int()
+	}
+	parse := func(sql string) parser.Statement {
+		t.Helper()
+		stmt, err := parser.New(sql).Parse()
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		return stmt
+	}
+
+	// A statement stopped halfway takes back the rows it wrote
+	insert := parse("INSERT INTO t VALUES (1, 1), (2, 2), (3, 3), (4, 4)")
+	if _, err := exec.ExecuteASTContext(newCancelAfter(3), insert, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := countRows(); n != 0 {
+		t.Errorf("%d rows left after the canceled INSERT, want 0", n)
+	}
+	if _, err := exec.ExecuteASTContext(context.Background(), insert, nil); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	// In a transaction, only the changes of the statement go
+	for _, sql := range []string{"BEGIN", "INSERT INTO t VALUES (5, 5)"} {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	update := parse("UPDATE t SET v = v * 10")
+	if _, err := exec.ExecuteASTContext(newCancelAfter(8), update, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := exec.Execute("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	result, err := exec.Execute("SELECT SUM(v) FROM t")
+	if err != nil {
+		t.Fatalf("SELECT SUM failed: %v", err)
+	}
+	if sum := result.Rows[0][0].Int(); sum != 15 {
+		t.Errorf("SUM(v) = %d after the canceled UPDATE, want 15", sum)
+	}
+
+	// Queries stop inside the iterators and the VDBE
+	for _, sql := range []string{
+		"SELECT * FROM t a JOIN t b ON 1 = 1 JOIN t c ON 1 = 1 JOIN t d ON 1 = 1 JOIN t e ON 1 = 1",
+		"SELECT a.id FROM t a JOIN t b ON 1 = 1 JOIN t c ON 1 = 1 JOIN t d ON 1 = 1 ORDER BY a.v",
+		"SELECT a.v, COUNT(*) FROM t a JOIN t b ON 1 = 1 JOIN t c ON 1 = 1 JOIN t d ON 1 = 1 GROUP BY a.v",
+		"WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r WHERE n < 90) SELECT n FROM r",
+		"EXPLAIN ANALYZE SELECT * FROM t",
+	} {
+		if _, err := exec.ExecuteASTContext(newCancelAfter(2), parse(sql), nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", sql, err)
+		}
+	}
+}
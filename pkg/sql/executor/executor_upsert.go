@@ -91,9 +91,7 @@ func (e *Executor) getRowByID(table *schema.TableDef, rowID int64) ([]types.Valu
 		return nil, fmt.Errorf("row not found: %w", err)
 	}
 
-	values := record.Decode(data)
-
-	return values, nil
+	return decodeRow(table, data), nil
 }
 
 // executeOnDuplicateUpdate performs the UPDATE part of ON DUPLICATE KEY UPDATE
@@ -5,24 +5,30 @@ package executor
 import (
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"strings"
 
+	"tur/pkg/dbfile"
 	"tur/pkg/hnsw"
+	"tur/pkg/mvcc"
+	"tur/pkg/pager"
 	"tur/pkg/record"
 	"tur/pkg/schema"
 	"tur/pkg/sql/optimizer"
 	"tur/pkg/sql/parser"
+	"tur/pkg/tree"
 	"tur/pkg/types"
 )
 
-// executeVectorQuantize implements the vector_quantize(table_name, column_name [, distance_metric]) function.
-// It builds an HNSW index on the specified VECTOR column.
+// executeVectorQuantize implements the vector_quantize(table_name, column_name [, distance_metric [, quantization]])
+// function. It builds an HNSW index on the specified VECTOR column.
 // Returns the number of vectors indexed.
 // Optional distance_metric can be: 'cosine' (default), 'euclidean'/'l2', 'manhattan'/'l1'
+// Optional quantization can be: 'none' (default), 'int8', 'pq'
 func (e *Executor) executeVectorQuantize(args []types.Value) (types.Value, error) {
-	// Validate arguments: need 2 or 3 string arguments
-	if len(args) < 2 || len(args) > 3 {
-		return types.NewNull(), fmt.Errorf("vector_quantize requires 2-3 arguments: table_name, column_name [, distance_metric]")
+	// Validate arguments: need 2 to 4 string arguments
+	if len(args) < 2 || len(args) > 4 {
+		return types.NewNull(), fmt.Errorf("vector_quantize requires 2-4 arguments: table_name, column_name [, distance_metric [, quantization]]")
 	}
 
 	// Extract table name (first argument)
@@ -39,7 +45,7 @@ func (e *Executor) executeVectorQuantize(args []types.Value) (types.Value, error
 
 	// Extract optional distance metric (third argument)
 	distanceMetric := types.DistanceMetricCosine // default
-	if len(args) == 3 {
+	if len(args) >= 3 {
 		if args[2].Type() != types.TypeText {
 			return types.NewNull(), fmt.Errorf("vector_quantize: distance_metric must be a string")
 		}
@@ -56,74 +62,310 @@ func (e *Executor) executeVectorQuantize(args []types.Value) (types.Value, error
 		return types.NewNull(), fmt.Errorf("vector_quantize: table %q not found", tableName)
 	}
 
-	// Find the column and validate it's a VECTOR type
-	var vecColumn *schema.ColumnDef
-	var colIndex int = -1
+	// Find the column; createHNSWIndex validates that it's a VECTOR type
+	colIndex := -1
 	for i, col := range table.Columns {
 		if strings.EqualFold(col.Name, columnName) {
-			vecColumn = &table.Columns[i]
 			colIndex = i
 			break
 		}
 	}
-	if vecColumn == nil {
+	if colIndex < 0 {
 		return types.NewNull(), fmt.Errorf("vector_quantize: column %q not found in table %q", columnName, tableName)
 	}
+
+	params := schema.DefaultHNSWParams()
+	params.DistanceMetric = schema.DistanceMetric(distanceMetric)
+
+	// Extract optional quantization (fourth argument)
+	if len(args) == 4 {
+		if args[3].Type() != types.TypeText {
+			return types.NewNull(), fmt.Errorf("vector_quantize: quantization must be a string")
+		}
+		quantization, err := hnsw.ParseQuantization(args[3].Text())
+		if err != nil {
+			return types.NewNull(), fmt.Errorf("vector_quantize: %w", err)
+		}
+		params.Quantization = schema.VectorQuantization(quantization)
+		params.Rerank = quantization != hnsw.QuantizationNone
+	}
+
+	indexName := fmt.Sprintf("hnsw_%s_%s", tableName, columnName)
+	count, err := e.createHNSWIndex(indexName, table, colIndex, params)
+	if err != nil {
+		return types.NewNull(), fmt.Errorf("vector_quantize: %w", err)
+	}
+
+	return types.NewInt(int64(count)), nil
+}
+
+// executeCreateHNSWIndex handles CREATE INDEX name ON table USING HNSW (column) [WITH (...)]
+func (e *Executor) executeCreateHNSWIndex(stmt *parser.CreateIndexStmt, table *schema.TableDef) (*Result, error) {
+	if stmt.Unique {
+		return nil, fmt.Errorf("HNSW index %s cannot be UNIQUE", stmt.IndexName)
+	}
+	if stmt.Where != nil {
+		return nil, fmt.Errorf("HNSW index %s cannot be partial", stmt.IndexName)
+	}
+	if len(stmt.Expressions) > 0 || len(stmt.Columns) != 1 {
+		return nil, fmt.Errorf("HNSW index %s must be on exactly one VECTOR column", stmt.IndexName)
+	}
+
+	_, colIndex := table.GetColumn(stmt.Columns[0])
+	if colIndex < 0 {
+		return nil, fmt.Errorf("column %s not found in table %s", stmt.Columns[0], stmt.TableName)
+	}
+
+	params, err := e.parseHNSWOptions(stmt.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := e.createHNSWIndex(stmt.IndexName, table, colIndex, params); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
+
+// parseHNSWOptions converts CREATE INDEX ... WITH (...) options into HNSW parameters.
+// Recognized options are m, ef_construction, metric, heuristic, quantization,
+// pq_segments and rerank. Quantized indexes re-rank unless rerank is false.
+func (e *Executor) parseHNSWOptions(options []parser.IndexOption) (*schema.HNSWParams, error) {
+	params := schema.DefaultHNSWParams()
+	rerankSet := false
+	for _, opt := range options {
+		val, err := e.evaluateExpr(opt.Value, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for HNSW option %s: %w", opt.Name, err)
+		}
+
+		switch opt.Name {
+		case "m":
+			if !types.IsIntegerType(val.Type()) || val.Int() < 2 || val.Int() > maxHNSWM {
+				return nil, fmt.Errorf("HNSW option m must be an integer between 2 and %d", maxHNSWM)
+			}
+			params.M = int(val.Int())
+		case "ef_construction":
+			if !types.IsIntegerType(val.Type()) || val.Int() < 1 {
+				return nil, fmt.Errorf("HNSW option ef_construction must be a positive integer")
+			}
+			params.EfConstruction = int(val.Int())
+		case "metric":
+			if val.Type() != types.TypeText {
+				return nil, fmt.Errorf("HNSW option metric must be a string")
+			}
+			metric, err := types.ParseDistanceMetric(val.Text())
+			if err != nil {
+				return nil, err
+			}
+			params.DistanceMetric = schema.DistanceMetric(metric)
+		case "heuristic":
+			if !types.IsIntegerType(val.Type()) {
+				return nil, fmt.Errorf("HNSW option heuristic must be true or false")
+			}
+			params.UseHeuristic = val.Int() != 0
+		case "quantization":
+			if val.Type() != types.TypeText {
+				return nil, fmt.Errorf("HNSW option quantization must be a string")
+			}
+			quantization, err := hnsw.ParseQuantization(val.Text())
+			if err != nil {
+				return nil, err
+			}
+			params.Quantization = schema.VectorQuantization(quantization)
+		case "pq_segments":
+			if !types.IsIntegerType(val.Type()) || val.Int() < 1 || val.Int() > maxPQSegments {
+				return nil, fmt.Errorf("HNSW option pq_segments must be an integer between 1 and %d", maxPQSegments)
+			}
+			params.PQSegments = int(val.Int())
+		case "rerank":
+			if !types.IsIntegerType(val.Type()) {
+				return nil, fmt.Errorf("HNSW option rerank must be true or false")
+			}
+			params.Rerank = val.Int() != 0
+			rerankSet = true
+		default:
+			return nil, fmt.Errorf("unknown HNSW option: %s", opt.Name)
+		}
+	}
+
+	if params.PQSegments != 0 && params.Quantization != schema.QuantizationPQ {
+		return nil, fmt.Errorf("HNSW option pq_segments requires quantization = 'pq'")
+	}
+	if !rerankSet {
+		params.Rerank = params.Quantization != schema.QuantizationNone
+	}
+	return params, nil
+}
+
+// maxHNSWM bounds the m parameter so that a node's neighbor lists always fit in its page.
+const maxHNSWM = 64
+
+// maxPQSegments is the largest number of PQ subvectors the index meta page can record.
+const maxPQSegments = 65535
+
+// createHNSWIndex builds a persistent HNSW index over the existing rows of a VECTOR
+// column, registers it in the catalog and persists its schema entry.
+// The index is registered even for an empty table so that later inserts are picked up,
+// except for quantized indexes, which train their codebook on the existing rows.
+// Returns the number of vectors indexed.
+func (e *Executor) createHNSWIndex(indexName string, table *schema.TableDef, colIndex int, params *schema.HNSWParams) (int, error) {
+	vecColumn := &table.Columns[colIndex]
 	if vecColumn.Type != types.TypeVector && vecColumn.Type != types.TypeBlob {
-		return types.NewNull(), fmt.Errorf("vector_quantize: column %q is not a VECTOR type", columnName)
+		return 0, fmt.Errorf("column %q is not a VECTOR type", vecColumn.Name)
 	}
 	if vecColumn.VectorDim <= 0 {
-		return types.NewNull(), fmt.Errorf("vector_quantize: column %q has invalid vector dimension", columnName)
+		return 0, fmt.Errorf("column %q has invalid vector dimension", vecColumn.Name)
+	}
+	if e.catalog.GetIndex(indexName) != nil {
+		return 0, fmt.Errorf("failed to register index: index %s already exists", indexName)
+	}
+	if params.PQSegments != 0 && vecColumn.VectorDim%params.PQSegments != 0 {
+		return 0, fmt.Errorf("pq_segments (%d) must divide the dimension of column %q (%d)",
+			params.PQSegments, vecColumn.Name, vecColumn.VectorDim)
 	}
 
 	// Scan table to collect all vectors
 	vectors, rowIDs, err := e.scanVectorColumn(table, colIndex, vecColumn.VectorDim)
 	if err != nil {
-		return types.NewNull(), fmt.Errorf("vector_quantize: failed to scan table: %w", err)
+		return 0, fmt.Errorf("failed to scan table: %w", err)
 	}
 
-	if len(vectors) == 0 {
-		return types.NewInt(0), nil
+	config := hnsw.DefaultConfig(vecColumn.VectorDim).WithM(params.M)
+	config.EfConstruction = params.EfConstruction
+	config.DistanceMetric = types.DistanceMetric(params.DistanceMetric)
+	config.UseHeuristic = params.UseHeuristic
+	config.ExtendCandidates = params.UseHeuristic
+	config.Quantization = hnsw.Quantization(params.Quantization)
+	config.PQSegments = params.PQSegments
+	config.Rerank = params.Rerank
+	if config.Quantization != hnsw.QuantizationNone && len(vectors) == 0 {
+		return 0, fmt.Errorf("%s quantization trains on the rows of %s, which is empty; insert rows before creating the index",
+			config.Quantization, table.Name)
+	}
+	idx, err := hnsw.CreatePersistent(e.pager, config)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create index: %w", err)
 	}
 
-	// Build HNSW index with configured distance metric
-	config := hnsw.DefaultConfig(vecColumn.VectorDim)
-	config.DistanceMetric = distanceMetric
-	idx := hnsw.NewIndex(config)
+	if err := idx.Train(vectors); err != nil {
+		e.freePages(idx.CollectPages())
+		return 0, fmt.Errorf("failed to train quantizer: %w", err)
+	}
+	params.PQSegments = idx.Config().PQSegments
 
 	for i, vec := range vectors {
 		if err := idx.Insert(rowIDs[i], vec); err != nil {
-			return types.NewNull(), fmt.Errorf("vector_quantize: failed to insert vector: %w", err)
+			e.freePages(idx.CollectPages())
+			return 0, fmt.Errorf("failed to insert vector: %w", err)
 		}
 	}
 
 	// Store index metadata in catalog (including distance metric)
-	indexName := fmt.Sprintf("hnsw_%s_%s", tableName, columnName)
 	indexDef := &schema.IndexDef{
+		Name:       indexName,
+		TableName:  table.Name,
+		Columns:    []string{vecColumn.Name},
+		Type:       schema.IndexTypeHNSW,
+		Unique:     false,
+		RootPage:   idx.MetaPage(),
+		HNSWParams: params,
+	}
+
+	if err := e.catalog.CreateIndex(indexDef); err != nil {
+		e.freePages(idx.CollectPages())
+		return 0, fmt.Errorf("failed to register index: %w", err)
+	}
+
+	// Persist the index; its schema entry points at the HNSW meta page
+	schemaEntry := &dbfile.SchemaEntry{
+		Type:      dbfile.SchemaEntryIndex,
 		Name:      indexName,
-		TableName: tableName,
-		Columns:   []string{columnName},
+		TableName: table.Name,
+		RootPage:  idx.MetaPage(),
+		SQL:       reconstructCreateHNSWIndexSQL(indexDef),
+	}
+	if err := e.persistSchemaEntry(schemaEntry); err != nil {
+		e.catalog.DropIndex(indexName)
+		e.freePages(idx.CollectPages())
+		return 0, fmt.Errorf("failed to persist index schema: %w", err)
+	}
+
+	// Store the HNSW index in executor's index map
+	if e.hnswIndexes == nil {
+		e.hnswIndexes = make(map[string]hnsw.VectorIndex)
+	}
+	e.hnswIndexes[indexName] = idx
+
+	return len(vectors), nil
+}
+
+// isHNSWMetaPage reports whether a schema entry's root page is an HNSW meta page
+// rather than a B-tree root.
+func (e *Executor) isHNSWMetaPage(pageNo uint32) bool {
+	if pageNo == 0 || pageNo >= e.pager.PageCount() {
+		return false
+	}
+	page, err := e.pager.Get(pageNo)
+	if err != nil {
+		return false
+	}
+	defer e.pager.Release(page)
+	return page.Type() == pager.PageTypeHNSWMeta
+}
+
+// loadHNSWIndexSchema reopens a persisted HNSW index and registers it in the catalog.
+func (e *Executor) loadHNSWIndexSchema(entry *dbfile.SchemaEntry, columns []string) error {
+	idx, err := hnsw.OpenPersistent(e.pager, entry.RootPage)
+	if err != nil {
+		return fmt.Errorf("failed to open HNSW index: %w", err)
+	}
+
+	config := idx.Config()
+	indexDef := &schema.IndexDef{
+		Name:      entry.Name,
+		TableName: entry.TableName,
+		Columns:   columns,
 		Type:      schema.IndexTypeHNSW,
-		Unique:    false,
-		RootPage:  0, // HNSW indexes are in-memory for now
+		RootPage:  entry.RootPage,
 		HNSWParams: &schema.HNSWParams{
 			M:              config.M,
 			EfConstruction: config.EfConstruction,
-			DistanceMetric: schema.DistanceMetric(distanceMetric),
+			DistanceMetric: schema.DistanceMetric(config.DistanceMetric),
+			UseHeuristic:   config.UseHeuristic,
+			Quantization:   schema.VectorQuantization(config.Quantization),
+			PQSegments:     config.PQSegments,
+			Rerank:         config.Rerank,
 		},
 	}
-
 	if err := e.catalog.CreateIndex(indexDef); err != nil {
-		return types.NewNull(), fmt.Errorf("vector_quantize: failed to register index: %w", err)
+		return err
 	}
 
-	// Store the HNSW index in executor's index map
 	if e.hnswIndexes == nil {
-		e.hnswIndexes = make(map[string]*hnsw.Index)
+		e.hnswIndexes = make(map[string]hnsw.VectorIndex)
 	}
-	e.hnswIndexes[indexName] = idx
+	e.hnswIndexes[entry.Name] = idx
+	return nil
+}
+
+// dropHNSWIndex forgets an HNSW index and frees its pages.
+func (e *Executor) dropHNSWIndex(name string) {
+	idx, ok := e.hnswIndexes[name]
+	if !ok {
+		return
+	}
+	delete(e.hnswIndexes, name)
+	if persistent, ok := idx.(*hnsw.PersistentIndex); ok {
+		e.freePages(persistent.CollectPages())
+	}
+}
 
-	return types.NewInt(int64(len(vectors))), nil
+// freePages returns pages to the pager's freelist, best effort.
+func (e *Executor) freePages(pages []uint32) {
+	for _, pageNo := range pages {
+		_ = e.pager.Free(pageNo)
+	}
 }
 
 // scanVectorColumn scans a table and extracts all vectors from the specified column.
@@ -153,7 +395,7 @@ func (e *Executor) scanVectorColumn(table *schema.TableDef, colIndex int, dimens
 		rowID := int64(binary.BigEndian.Uint64(key))
 
 		// Value is stored as record.Encode format
-		values := record.Decode(val)
+		values := decodeRow(table, val)
 		if len(values) == 0 {
 			cursor.Next()
 			continue
@@ -175,6 +417,91 @@ func (e *Executor) scanVectorColumn(table *schema.TableDef, colIndex int, dimens
 	return vectors, rowIDs, nil
 }
 
+// hnswColumnIndex returns the position of the indexed VECTOR column in the table.
+func hnswColumnIndex(idx *schema.IndexDef, table *schema.TableDef) int {
+	if len(idx.Columns) == 0 {
+		return -1
+	}
+	_, colIdx := table.GetColumn(idx.Columns[0])
+	return colIdx
+}
+
+// insertIntoHNSWIndex adds the row's vector to an HNSW index.
+// NULL vectors are not indexed.
+func (e *Executor) insertIntoHNSWIndex(idx *schema.IndexDef, table *schema.TableDef, rowID uint64, values []types.Value) error {
+	hnswIdx := e.hnswIndexes[idx.Name]
+	if hnswIdx == nil {
+		return nil
+	}
+
+	colIdx := hnswColumnIndex(idx, table)
+	if colIdx < 0 || colIdx >= len(values) || values[colIdx].IsNull() {
+		return nil
+	}
+
+	vec, err := extractVectorFromValue(values[colIdx])
+	if err != nil {
+		return fmt.Errorf("failed to update index %s: %w", idx.Name, err)
+	}
+	if err := hnswIdx.Insert(int64(rowID), vec); err != nil {
+		return fmt.Errorf("failed to update index %s: %w", idx.Name, err)
+	}
+	return nil
+}
+
+// deleteFromHNSWIndex removes the row's vector from an HNSW index, if present.
+func (e *Executor) deleteFromHNSWIndex(idx *schema.IndexDef, rowID uint64) {
+	if hnswIdx := e.hnswIndexes[idx.Name]; hnswIdx != nil {
+		hnswIdx.Delete(int64(rowID))
+	}
+}
+
+// undoHNSWIndexes reverts the HNSW index changes made by a logged table operation.
+// Table undo entries carry the rowid key and the old record, which is enough to
+// restore the vector graph without separate index undo entries.
+func (e *Executor) undoHNSWIndexes(op mvcc.UndoOperation) {
+	if len(op.Key) < 8 {
+		return
+	}
+	table := e.catalog.GetTable(op.TableName)
+	if table == nil {
+		return
+	}
+
+	rowID := binary.BigEndian.Uint64(op.Key)
+	for _, idx := range e.catalog.GetIndexesForTable(op.TableName) {
+		if idx.Type != schema.IndexTypeHNSW {
+			continue
+		}
+
+		// Drop whatever the statement left in the index for this row
+		e.deleteFromHNSWIndex(idx, rowID)
+
+		// Restore the pre-statement vector for UPDATE and DELETE
+		if op.Type == mvcc.UndoUpdate || op.Type == mvcc.UndoDelete {
+			_ = e.insertIntoHNSWIndex(idx, table, rowID, record.Decode(op.OldData))
+		}
+	}
+}
+
+// resetHNSWIndexes empties every HNSW index on the table.
+func (e *Executor) resetHNSWIndexes(tableName string) error {
+	for _, idx := range e.catalog.GetIndexesForTable(tableName) {
+		if idx.Type != schema.IndexTypeHNSW {
+			continue
+		}
+		switch hnswIdx := e.hnswIndexes[idx.Name].(type) {
+		case *hnsw.PersistentIndex:
+			if err := hnswIdx.Clear(); err != nil {
+				return fmt.Errorf("failed to clear index %s: %w", idx.Name, err)
+			}
+		case *hnsw.Index:
+			e.hnswIndexes[idx.Name] = hnsw.NewIndex(hnswIdx.Config())
+		}
+	}
+	return nil
+}
+
 // extractVectorFromValue extracts a Vector from a types.Value.
 func extractVectorFromValue(val types.Value) (*types.Vector, error) {
 	switch val.Type() {
@@ -191,69 +518,154 @@ func extractVectorFromValue(val types.Value) (*types.Vector, error) {
 func (e *Executor) executeTableFunction(node *optimizer.TableFunctionNode, cteData map[string]*cteResult) (RowIterator, []string, error) {
 	switch strings.ToUpper(node.Name) {
 	case "VECTOR_QUANTIZE_SCAN":
-		return e.executeVectorQuantizeScan(node.Args)
+		return e.executeVectorQuantizeScan(node)
 	default:
 		return nil, nil, fmt.Errorf("unknown table function: %s", node.Name)
 	}
 }
 
-// executeVectorQuantizeScan implements the vector_quantize_scan(table, column, query_vec, k) function.
-// Returns an iterator over (rowid, distance) pairs.
-func (e *Executor) executeVectorQuantizeScan(args []parser.Expression) (RowIterator, []string, error) {
-	// Validate arguments: need exactly 4 arguments
-	if len(args) != 4 {
-		return nil, nil, fmt.Errorf("vector_quantize_scan requires 4 arguments: table_name, column_name, query_vector, k")
+// vectorScanArgs holds the evaluated arguments of a vector_quantize_scan call
+type vectorScanArgs struct {
+	table  string
+	column string
+	query  *types.Vector
+	k      int
+	ef     int // Per-query ef_search, 0 if not given
+}
+
+// evaluateVectorScanArgs evaluates the arguments of
+// vector_quantize_scan(table, column, query_vec, k [, ef]), where ef may also be
+// passed by name as ef => N.
+func (e *Executor) evaluateVectorScanArgs(node *optimizer.TableFunctionNode) (*vectorScanArgs, error) {
+	args := node.Args
+
+	// Validate arguments: need 4 arguments plus an optional ef
+	if len(args) != 4 && len(args) != 5 {
+		return nil, fmt.Errorf("vector_quantize_scan requires 4-5 arguments: table_name, column_name, query_vector, k [, ef]")
 	}
 
 	// Evaluate arguments
-	argValues := make([]types.Value, 4)
+	argValues := make([]types.Value, len(args))
 	for i, arg := range args {
 		val, err := e.evaluateExpr(arg, nil, nil)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to evaluate argument %d: %w", i, err)
+			return nil, fmt.Errorf("failed to evaluate argument %d: %w", i, err)
 		}
 		argValues[i] = val
 	}
 
 	// Extract table name (first argument)
 	if argValues[0].Type() != types.TypeText {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: table_name must be a string")
+		return nil, fmt.Errorf("vector_quantize_scan: table_name must be a string")
 	}
-	tableName := argValues[0].Text()
 
 	// Extract column name (second argument)
 	if argValues[1].Type() != types.TypeText {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: column_name must be a string")
+		return nil, fmt.Errorf("vector_quantize_scan: column_name must be a string")
 	}
-	columnName := argValues[1].Text()
 
 	// Extract query vector (third argument)
 	queryVec, err := extractVectorFromValue(argValues[2])
 	if err != nil {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: invalid query vector: %w", err)
+		return nil, fmt.Errorf("vector_quantize_scan: invalid query vector: %w", err)
 	}
 
 	// Extract k (fourth argument) - accept all integer types
 	if !types.IsIntegerType(argValues[3].Type()) {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: k must be an integer")
+		return nil, fmt.Errorf("vector_quantize_scan: k must be an integer")
 	}
 	k := int(argValues[3].Int())
 	if k <= 0 {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: k must be positive")
+		return nil, fmt.Errorf("vector_quantize_scan: k must be positive")
+	}
+
+	scanArgs := &vectorScanArgs{
+		table:  argValues[0].Text(),
+		column: argValues[1].Text(),
+		query:  queryVec,
+		k:      k,
+	}
+
+	// Extract ef (optional fifth argument or ef => N)
+	var efValue *types.Value
+	if len(argValues) == 5 {
+		efValue = &argValues[4]
+	}
+	for _, named := range node.NamedArgs {
+		if named.Name != "ef" {
+			return nil, fmt.Errorf("vector_quantize_scan: unknown argument %q", named.Name)
+		}
+		if efValue != nil {
+			return nil, fmt.Errorf("vector_quantize_scan: ef specified more than once")
+		}
+		val, err := e.evaluateExpr(named.Value, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate argument ef: %w", err)
+		}
+		efValue = &val
+	}
+	if efValue != nil {
+		if !types.IsIntegerType(efValue.Type()) || efValue.Int() <= 0 {
+			return nil, fmt.Errorf("vector_quantize_scan: ef must be a positive integer")
+		}
+		scanArgs.ef = int(efValue.Int())
+	}
+
+	return scanArgs, nil
+}
+
+// vectorScanEf returns the ef_search used by a vector_quantize_scan: the per-query
+// value if given, else PRAGMA hnsw_ef_search, else the index default. It is never
+// less than k, since a search cannot return more candidates than it keeps.
+func (e *Executor) vectorScanEf(args *vectorScanArgs, idx hnsw.VectorIndex) int {
+	ef := args.ef
+	if ef == 0 {
+		ef = e.hnswEfSearch
+	}
+	if ef == 0 {
+		ef = idx.Config().EfSearch
+	}
+	if ef < args.k {
+		ef = args.k
+	}
+	return ef
+}
+
+// executeVectorQuantizeScan implements the vector_quantize_scan(table, column, query_vec, k [, ef]) function.
+// Returns an iterator over (rowid, distance) pairs. When the optimizer pushed a filter into the
+// node, only rows of the searched table that satisfy it are returned.
+func (e *Executor) executeVectorQuantizeScan(node *optimizer.TableFunctionNode) (RowIterator, []string, error) {
+	args, err := e.evaluateVectorScanArgs(node)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Find the HNSW index for this table/column
-	indexName := fmt.Sprintf("hnsw_%s_%s", tableName, columnName)
-	idx := e.hnswIndexes[indexName]
+	var idx hnsw.VectorIndex
+	if indexDef := optimizer.FindVectorIndex(e.catalog, args.table, args.column); indexDef != nil {
+		idx = e.hnswIndexes[indexDef.Name]
+	}
 	if idx == nil {
-		return nil, nil, fmt.Errorf("vector_quantize_scan: no HNSW index found for %s.%s (run vector_quantize first)", tableName, columnName)
+		return nil, nil, fmt.Errorf("vector_quantize_scan: no HNSW index found for %s.%s (create one with CREATE INDEX ... USING HNSW or vector_quantize)", args.table, args.column)
 	}
 
-	// Execute KNN search
-	results, err := idx.SearchKNN(queryVec, k)
+	// Execute KNN search, restricted to matching rows if a filter was pushed down
+	var filter *vectorRowFilter
+	var rowFilter hnsw.Filter
+	if node.Filter != nil && node.FilterTable != nil {
+		filter, err = e.newVectorRowFilter(node.FilterTable, node.FilterAlias, node.Filter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("vector_quantize_scan: %w", err)
+		}
+		rowFilter = filter.match
+	}
+	results, err := e.searchHNSW(idx, args.table, args.column, args.query, args.k, e.vectorScanEf(args, idx), rowFilter)
 	if err != nil {
 		return nil, nil, fmt.Errorf("vector_quantize_scan: search failed: %w", err)
 	}
+	if filter != nil && filter.err != nil {
+		return nil, nil, fmt.Errorf("vector_quantize_scan: evaluating filter: %w", filter.err)
+	}
 
 	// Build rows from search results
 	rows := make([][]types.Value, len(results))
@@ -264,11 +676,223 @@ func (e *Executor) executeVectorQuantizeScan(args []parser.Expression) (RowItera
 		}
 	}
 
-	// Return iterator with columns
+	// Return iterator with columns (with alias prefix if alias exists)
 	columns := []string{"rowid", "distance"}
+	if node.Alias != "" {
+		for i, col := range columns {
+			columns[i] = node.Alias + "." + col
+		}
+	}
 	return &SliceIterator{rows: rows, pos: 0}, columns, nil
 }
 
+// rerankFactor is the number of candidates per requested row that a re-ranking
+// search takes from a quantized index
+const rerankFactor = 4
+
+// searchHNSW finds the k rows nearest to query in an HNSW index on table.column.
+// Quantized indexes with rerank enabled are searched for rerankFactor*k candidates
+// on their compressed codes, which are then re-ranked by their exact distance to
+// the full-precision vectors stored in the column.
+func (e *Executor) searchHNSW(idx hnsw.VectorIndex, table, column string, query *types.Vector, k, ef int, filter hnsw.Filter) ([]hnsw.SearchResult, error) {
+	config := idx.Config()
+	if config.Quantization == hnsw.QuantizationNone || !config.Rerank {
+		return idx.SearchKNNFiltered(query, k, ef, filter)
+	}
+
+	tableDef := e.catalog.GetTable(table)
+	tableTree := e.trees[table]
+	if tableDef == nil || tableTree == nil {
+		return nil, fmt.Errorf("table %q not found", table)
+	}
+	_, colIndex := tableDef.GetColumn(column)
+	if colIndex < 0 {
+		return nil, fmt.Errorf("column %q not found in table %q", column, table)
+	}
+
+	candidates := k * rerankFactor
+	if ef < candidates {
+		ef = candidates
+	}
+	results, err := idx.SearchKNNFiltered(query, candidates, ef, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := results[:0]
+	key := make([]byte, 8)
+	for _, result := range results {
+		binary.BigEndian.PutUint64(key, uint64(result.RowID))
+		data, err := tableTree.Get(key)
+		if err == tree.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values := decodeRow(tableDef, data)
+		if colIndex >= len(values) {
+			continue
+		}
+		vec, err := extractVectorFromValue(values[colIndex])
+		if err != nil || vec.Dimension() != query.Dimension() {
+			continue
+		}
+		result.Distance = query.Distance(vec, config.DistanceMetric)
+		reranked = append(reranked, result)
+	}
+
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Distance < reranked[j].Distance
+	})
+	if len(reranked) > k {
+		reranked = reranked[:k]
+	}
+	return reranked, nil
+}
+
+// vectorRowFilter evaluates a pushed-down WHERE clause against rows of the table
+// searched by an HNSW index, looking each candidate row up by its rowid.
+type vectorRowFilter struct {
+	executor  *Executor
+	table     *schema.TableDef
+	tree      tree.Tree
+	condition parser.Expression
+	colMap    map[string]int
+	key       []byte
+	err       error // First error hit while evaluating; the search treats the row as a non-match
+}
+
+// newVectorRowFilter prepares a filter on the rows of table, whose columns the
+// condition qualifies with alias (or the table name if alias is empty).
+func (e *Executor) newVectorRowFilter(table *schema.TableDef, alias string, condition parser.Expression) (*vectorRowFilter, error) {
+	tableTree := e.trees[table.Name]
+	if tableTree == nil {
+		return nil, fmt.Errorf("table B-tree not found for %q", table.Name)
+	}
+
+	return &vectorRowFilter{
+		executor:  e,
+		table:     table,
+		tree:      tableTree,
+		condition: condition,
+		colMap:    e.buildColMap(qualifiedColumnNames(table, alias)),
+		key:       make([]byte, 8),
+	}, nil
+}
+
+// qualifiedColumnNames returns the table's column names prefixed with alias
+// (or the table name if alias is empty), as a table scan outputs them.
+func qualifiedColumnNames(table *schema.TableDef, alias string) []string {
+	prefix := alias
+	if prefix == "" {
+		prefix = table.Name
+	}
+	cols := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		cols[i] = prefix + "." + col.Name
+	}
+	return cols
+}
+
+// match reports whether the row with the given rowid satisfies the filter.
+func (f *vectorRowFilter) match(rowID int64) bool {
+	if f.err != nil {
+		return false
+	}
+
+	binary.BigEndian.PutUint64(f.key, uint64(rowID))
+	data, err := f.tree.Get(f.key)
+	if err != nil {
+		if err != tree.ErrKeyNotFound {
+			f.err = err
+		}
+		return false
+	}
+
+	ok, err := f.executor.evaluateCondition(f.condition, decodeRow(f.table, data), f.colMap)
+	if err != nil {
+		f.err = err
+		return false
+	}
+	return ok
+}
+
+// executeVectorIndexScan returns the rows of a table nearest to the query vector,
+// in order of increasing distance, by searching its HNSW index. The optimizer plans
+// it for ORDER BY vector_distance(column, query) LIMIT k.
+func (e *Executor) executeVectorIndexScan(node *optimizer.VectorIndexScanNode) (RowIterator, []string, error) {
+	idx := e.hnswIndexes[node.IndexName]
+	if idx == nil {
+		return nil, nil, fmt.Errorf("HNSW index %s not found", node.IndexName)
+	}
+	tableTree := e.trees[node.Table.Name]
+	if tableTree == nil {
+		return nil, nil, fmt.Errorf("table B-tree not found for %q", node.Table.Name)
+	}
+	cols := qualifiedColumnNames(node.Table, node.Alias)
+
+	queryVal, err := e.evaluateExpr(node.Query, nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("evaluating query vector: %w", err)
+	}
+	if queryVal.IsNull() {
+		// vector_distance with a NULL argument is NULL for every row, so no row is nearest
+		return &SliceIterator{}, cols, nil
+	}
+	queryVec, err := extractVectorFromValue(queryVal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid query vector: %w", err)
+	}
+	if queryVec.Dimension() != idx.Config().Dimension {
+		return nil, nil, fmt.Errorf("query vector has dimension %d, index %s expects %d", queryVec.Dimension(), node.IndexName, idx.Config().Dimension)
+	}
+
+	k, err := e.evaluateLimitOffset(node.Limit, node.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k == 0 {
+		return &SliceIterator{}, cols, nil
+	}
+
+	var filter *vectorRowFilter
+	var rowFilter hnsw.Filter
+	if node.Filter != nil {
+		filter, err = e.newVectorRowFilter(node.Table, node.Alias, node.Filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		rowFilter = filter.match
+	}
+	results, err := e.searchHNSW(idx, node.Table.Name, node.Column, queryVec, k, e.vectorScanEf(&vectorScanArgs{k: k}, idx), rowFilter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("HNSW search failed: %w", err)
+	}
+	if filter != nil && filter.err != nil {
+		return nil, nil, fmt.Errorf("evaluating filter: %w", filter.err)
+	}
+
+	// Fetch the rows in distance order
+	rows := make([][]types.Value, 0, len(results))
+	key := make([]byte, 8)
+	for _, result := range results {
+		binary.BigEndian.PutUint64(key, uint64(result.RowID))
+		data, err := tableTree.Get(key)
+		if err == tree.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row := decodeRow(node.Table, data)
+		applyColumnTypes(node.Table, row)
+		rows = append(rows, row)
+	}
+
+	return &SliceIterator{rows: rows}, cols, nil
+}
+
 // SliceIterator implements RowIterator over a slice of rows
 type SliceIterator struct {
 	rows [][]types.Value
@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"testing"
+)
+
+// setupVectorLikes creates items with 2-dimensional embeddings and the items
+// each user liked
+func setupVectorLikes(t *testing.T, exec *Executor) {
+	t.Helper()
+	mustExec(t, exec, "CREATE TABLE items (id INT PRIMARY KEY, embedding VECTOR(2) NONORMALIZE)")
+	mustExec(t, exec, "INSERT INTO items VALUES (1, VECTOR('[1, 0]'))")
+	mustExec(t, exec, "INSERT INTO items VALUES (2, VECTOR('[0, 2]'))")
+	mustExec(t, exec, "INSERT INTO items VALUES (3, VECTOR('[3, 4]'))")
+	mustExec(t, exec, "INSERT INTO items VALUES (4, VECTOR('[-1, -1]'))")
+	mustExec(t, exec, "INSERT INTO items VALUES (5, NULL)")
+
+	mustExec(t, exec, "CREATE TABLE likes (user_name TEXT, item_id INT)")
+	mustExec(t, exec, "INSERT INTO likes VALUES ('ann', 1)")
+	mustExec(t, exec, "INSERT INTO likes VALUES ('ann', 3)")
+	mustExec(t, exec, "INSERT INTO likes VALUES ('ann', 5)")
+	mustExec(t, exec, "INSERT INTO likes VALUES ('bob', 2)")
+	mustExec(t, exec, "INSERT INTO likes VALUES ('bob', 4)")
+}
+
+func TestVectorFunctions_SQL(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupVectorLikes(t, exec)
+
+	// NONORMALIZE keeps the vectors as written
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(embedding) FROM items ORDER BY id"); got != "[1,0];[0,2];[3,4];[-1,-1];NULL" {
+		t.Errorf("stored vectors = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT VECTOR_DIMS(embedding), VECTOR_NORM(embedding) FROM items WHERE id = 3"); got != "2,5" {
+		t.Errorf("VECTOR_DIMS and VECTOR_NORM = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(VECTOR_SCALE(VECTOR_SUB(VECTOR_ADD(embedding, VECTOR('[1, 1]')), VECTOR('[0, 1]')), 0.5)) FROM items WHERE id = 3"); got != "[2,2]" {
+		t.Errorf("vector arithmetic = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(VECTOR_NORMALIZE(embedding)) FROM items WHERE id = 3"); got != "[0.6,0.8]" {
+		t.Errorf("VECTOR_NORMALIZE = %q", got)
+	}
+
+	// Vectors of the wrong dimension are still rejected
+	if _, err := exec.Execute("INSERT INTO items VALUES (6, VECTOR('[1, 2, 3]'))"); err == nil {
+		t.Error("expected an error inserting a 3-dimensional vector into VECTOR(2)")
+	}
+}
+
+func TestVectorAggregates_SQL(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupVectorLikes(t, exec)
+
+	got := queryRows(t, exec, "SELECT l.user_name, VECTOR_AVG(i.embedding), VECTOR_SUM(i.embedding) FROM likes l JOIN items i ON l.item_id = i.id GROUP BY l.user_name ORDER BY l.user_name")
+	if got != "ann,[2,2],[4,4];bob,[-0.5,0.5],[-1,1]" {
+		t.Errorf("VECTOR_AVG and VECTOR_SUM per user = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT VECTOR_AVG(embedding) FROM items WHERE id > 100"); got != "NULL" {
+		t.Errorf("VECTOR_AVG of no rows = %q", got)
+	}
+
+	// A running centroid as a window aggregate
+	if got := queryRows(t, exec, "SELECT id, VECTOR_AVG(embedding) OVER (ORDER BY id) FROM items WHERE id <= 3 ORDER BY id"); got != "1,[1,0];2,[0.5,1];3,[1.3333334,2]" {
+		t.Errorf("windowed VECTOR_AVG = %q", got)
+	}
+}
+
+func TestVectorAggregates_Nested(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupVectorLikes(t, exec)
+
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(VECTOR_AVG(embedding)) FROM items"); got != "[0.75,1.25]" {
+		t.Errorf("VECTOR_TO_JSON of VECTOR_AVG = %q", got)
+	}
+	got := queryRows(t, exec, "SELECT l.user_name, VECTOR_TO_JSON(VECTOR_SUM(i.embedding)), VECTOR_DIMS(VECTOR_AVG(i.embedding)) FROM likes l JOIN items i ON l.item_id = i.id GROUP BY l.user_name ORDER BY l.user_name")
+	if got != "ann,[4,4],2;bob,[-1,1],2" {
+		t.Errorf("nested vector aggregates per user = %q", got)
+	}
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(c) FROM (SELECT VECTOR_AVG(embedding) AS c FROM items) t"); got != "[0.75,1.25]" {
+		t.Errorf("aliased VECTOR_AVG in a derived table = %q", got)
+	}
+}
+
+func TestVectorAggregates_NearestToCentroid(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	// A normalized column, so VECTOR_DISTANCE is the cosine distance
+	mustExec(t, exec, "CREATE TABLE docs (id INT PRIMARY KEY, topic TEXT, embedding VECTOR(2))")
+	mustExec(t, exec, "INSERT INTO docs VALUES (1, 'a', VECTOR('[1, 0]'))")
+	mustExec(t, exec, "INSERT INTO docs VALUES (2, 'a', VECTOR('[0, 1]'))")
+	mustExec(t, exec, "INSERT INTO docs VALUES (3, 'b', VECTOR('[1, 1]'))")
+	mustExec(t, exec, "INSERT INTO docs VALUES (4, 'b', VECTOR('[-1, 0]'))")
+
+	got := queryRows(t, exec, "SELECT id FROM (SELECT id, VECTOR_DISTANCE(embedding, (SELECT VECTOR_AVG(embedding) FROM docs WHERE topic = 'a')) AS dist FROM docs ORDER BY dist LIMIT 1)")
+	if got != "3" {
+		t.Errorf("nearest to the centroid of topic a = %q", got)
+	}
+}
+
+func TestVectorUpdate_NoNormalize(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupVectorLikes(t, exec)
+
+	mustExec(t, exec, "UPDATE items SET embedding = VECTOR('[0, 3]') WHERE id = 1")
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(embedding) FROM items WHERE id = 1"); got != "[0,3]" {
+		t.Errorf("NONORMALIZE vector after UPDATE = %q", got)
+	}
+
+	// Columns without NONORMALIZE are still normalized
+	mustExec(t, exec, "CREATE TABLE unit (id INT PRIMARY KEY, embedding VECTOR(2))")
+	mustExec(t, exec, "INSERT INTO unit VALUES (1, VECTOR('[1, 0]'))")
+	mustExec(t, exec, "UPDATE unit SET embedding = VECTOR('[0, 3]') WHERE id = 1")
+	if got := queryRows(t, exec, "SELECT VECTOR_TO_JSON(embedding) FROM unit"); got != "[0,1]" {
+		t.Errorf("normalized vector after UPDATE = %q", got)
+	}
+}
@@ -4,9 +4,15 @@ package executor
 import (
 	"encoding/hex"
 	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"tur/pkg/pager"
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
 	"tur/pkg/types"
 )
 
@@ -449,3 +455,852 @@ func TestVectorInsert_NormalizeByDefault(t *testing.T) {
 		t.Errorf("expected y ~0.8 (normalized), got %f", data[1])
 	}
 }
+
+// vectorScanRowIDs runs vector_quantize_scan and returns the matching rowids in order
+func vectorScanRowIDs(t *testing.T, exec *Executor, query []float32, k int) []int64 {
+	t.Helper()
+	result, err := exec.Execute(fmt.Sprintf("SELECT * FROM vector_quantize_scan('embeddings', 'embedding', x'%s', %d)", vectorToHex(query), k))
+	if err != nil {
+		t.Fatalf("vector_quantize_scan failed: %v", err)
+	}
+	rowIDs := make([]int64, len(result.Rows))
+	for i, row := range result.Rows {
+		rowIDs[i] = row[0].Int()
+	}
+	return rowIDs
+}
+
+// setupIndexedEmbeddings creates the embeddings table with two rows and an HNSW index
+func setupIndexedEmbeddings(t *testing.T, exec *Executor) {
+	t.Helper()
+	if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, embedding VECTOR(3))"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (1, x'%s')", vectorToHex([]float32{1, 0, 0}))); err != nil {
+		t.Fatalf("failed to insert row 1: %v", err)
+	}
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (2, x'%s')", vectorToHex([]float32{0, 1, 0}))); err != nil {
+		t.Fatalf("failed to insert row 2: %v", err)
+	}
+	if _, err := exec.Execute("SELECT vector_quantize('embeddings', 'embedding')"); err != nil {
+		t.Fatalf("vector_quantize failed: %v", err)
+	}
+}
+
+// TestVectorQuantizeScan_SeesInsertedRows tests that rows inserted after vector_quantize are searchable
+func TestVectorQuantizeScan_SeesInsertedRows(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupIndexedEmbeddings(t, exec)
+
+	_, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (3, x'%s')", vectorToHex([]float32{0, 0, 1})))
+	if err != nil {
+		t.Fatalf("failed to insert row 3: %v", err)
+	}
+
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 0, 1}, 1)
+	if len(rowIDs) != 1 || rowIDs[0] != 3 {
+		t.Errorf("expected newly inserted rowid 3, got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantizeScan_EmptyTableThenInsert tests indexing a table that was empty at vector_quantize time
+func TestVectorQuantizeScan_EmptyTableThenInsert(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, embedding VECTOR(3))"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := exec.Execute("SELECT vector_quantize('embeddings', 'embedding')"); err != nil {
+		t.Fatalf("vector_quantize failed: %v", err)
+	}
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (7, x'%s')", vectorToHex([]float32{0, 1, 0}))); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 1, 0}, 5)
+	if len(rowIDs) != 1 || rowIDs[0] != 7 {
+		t.Errorf("expected [7], got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantizeScan_UpdateAndDelete tests that UPDATE and DELETE are reflected in the index
+func TestVectorQuantizeScan_UpdateAndDelete(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupIndexedEmbeddings(t, exec)
+
+	// Move row 1 onto the Z axis
+	_, err := exec.Execute(fmt.Sprintf("UPDATE embeddings SET embedding = x'%s' WHERE id = 1", vectorToHex([]float32{0, 0, 1})))
+	if err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 0, 1}, 1)
+	if len(rowIDs) != 1 || rowIDs[0] != 1 {
+		t.Errorf("expected updated rowid 1 closest to Z axis, got %v", rowIDs)
+	}
+	if got := vectorScanRowIDs(t, exec, []float32{1, 0, 0}, 5); len(got) != 2 {
+		t.Errorf("expected 2 indexed rows after update, got %v", got)
+	}
+
+	// Delete row 2
+	if _, err := exec.Execute("DELETE FROM embeddings WHERE id = 2"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	rowIDs = vectorScanRowIDs(t, exec, []float32{0, 1, 0}, 5)
+	if len(rowIDs) != 1 || rowIDs[0] != 1 {
+		t.Errorf("expected only rowid 1 after delete, got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantizeScan_Truncate tests that TRUNCATE empties the index
+func TestVectorQuantizeScan_Truncate(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupIndexedEmbeddings(t, exec)
+
+	if _, err := exec.Execute("TRUNCATE TABLE embeddings"); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{1, 0, 0}, 5); len(rowIDs) != 0 {
+		t.Errorf("expected empty index after truncate, got %v", rowIDs)
+	}
+
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (5, x'%s')", vectorToHex([]float32{1, 0, 0}))); err != nil {
+		t.Fatalf("failed to insert after truncate: %v", err)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{1, 0, 0}, 5); len(rowIDs) != 1 || rowIDs[0] != 5 {
+		t.Errorf("expected [5] after re-insert, got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantizeScan_Rollback tests that ROLLBACK restores the index state
+func TestVectorQuantizeScan_Rollback(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupIndexedEmbeddings(t, exec)
+
+	statements := []string{
+		"BEGIN",
+		fmt.Sprintf("INSERT INTO embeddings VALUES (3, x'%s')", vectorToHex([]float32{0, 0, 1})),
+		fmt.Sprintf("UPDATE embeddings SET embedding = x'%s' WHERE id = 1", vectorToHex([]float32{0, 1, 0})),
+		"DELETE FROM embeddings WHERE id = 2",
+		"ROLLBACK",
+	}
+	for _, sql := range statements {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{1, 0, 0}, 5); len(rowIDs) != 2 || rowIDs[0] != 1 {
+		t.Errorf("expected [1 2] after rollback, got %v", rowIDs)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{0, 1, 0}, 1); len(rowIDs) != 1 || rowIDs[0] != 2 {
+		t.Errorf("expected rowid 2 restored after rollback, got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantizeScan_RollbackToSavepoint tests partial rollback of index changes
+func TestVectorQuantizeScan_RollbackToSavepoint(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupIndexedEmbeddings(t, exec)
+
+	statements := []string{
+		"BEGIN",
+		fmt.Sprintf("INSERT INTO embeddings VALUES (3, x'%s')", vectorToHex([]float32{0, 0, 1})),
+		"SAVEPOINT sp1",
+		fmt.Sprintf("INSERT INTO embeddings VALUES (4, x'%s')", vectorToHex([]float32{0, 0, 1})),
+		"ROLLBACK TO sp1",
+		"COMMIT",
+	}
+	for _, sql := range statements {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 0, 1}, 5)
+	if len(rowIDs) != 3 || rowIDs[0] != 3 {
+		t.Errorf("expected rowid 3 kept and rowid 4 rolled back, got %v", rowIDs)
+	}
+}
+
+// TestVectorQuantize_PersistsAcrossReopen tests that HNSW indexes are reloaded from the database file
+func TestVectorQuantize_PersistsAcrossReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Phase 1: Build the index and keep writing after it exists
+	{
+		p, err := pager.Open(dbPath, pager.Options{})
+		if err != nil {
+			t.Fatalf("pager.Open: %v", err)
+		}
+		exec := New(p)
+		setupIndexedEmbeddings(t, exec)
+
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (3, x'%s')", vectorToHex([]float32{0, 0, 1}))); err != nil {
+			exec.Close()
+			t.Fatalf("failed to insert row 3: %v", err)
+		}
+		if _, err := exec.Execute("DELETE FROM embeddings WHERE id = 2"); err != nil {
+			exec.Close()
+			t.Fatalf("failed to delete row 2: %v", err)
+		}
+		if err := exec.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	// Phase 2: Reopen and query without calling vector_quantize again
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open reopen: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	idx := exec.catalog.GetIndex("hnsw_embeddings_embedding")
+	if idx == nil || idx.Type != schema.IndexTypeHNSW {
+		t.Fatalf("expected HNSW index in catalog after reopen, got %+v", idx)
+	}
+	if idx.HNSWParams == nil || idx.HNSWParams.M != 16 {
+		t.Errorf("expected HNSW params restored from meta page, got %+v", idx.HNSWParams)
+	}
+
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 0, 1}, 5)
+	if len(rowIDs) != 2 || rowIDs[0] != 3 {
+		t.Errorf("expected [3 1] after reopen, got %v", rowIDs)
+	}
+
+	// The reloaded index keeps tracking writes
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (4, x'%s')", vectorToHex([]float32{0, 1, 0}))); err != nil {
+		t.Fatalf("failed to insert after reopen: %v", err)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{0, 1, 0}, 1); len(rowIDs) != 1 || rowIDs[0] != 4 {
+		t.Errorf("expected rowid 4 after reopen insert, got %v", rowIDs)
+	}
+
+	// Dropping the index releases its pages
+	freeBefore := p.FreePageCount()
+	if _, err := exec.Execute("DROP INDEX hnsw_embeddings_embedding"); err != nil {
+		t.Fatalf("DROP INDEX failed: %v", err)
+	}
+	if p.FreePageCount() <= freeBefore {
+		t.Errorf("expected HNSW pages to be freed, free count %d -> %d", freeBefore, p.FreePageCount())
+	}
+}
+
+// TestCreateIndexUsingHNSW tests building an HNSW index with CREATE INDEX ... USING HNSW WITH (...)
+func TestCreateIndexUsingHNSW(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, embedding VECTOR(3))"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (1, x'%s')", vectorToHex([]float32{1, 0, 0}))); err != nil {
+		t.Fatalf("failed to insert row 1: %v", err)
+	}
+
+	_, err := exec.Execute("CREATE INDEX emb_idx ON embeddings USING HNSW (embedding) WITH (m=32, ef_construction=400, metric='l2', heuristic=true)")
+	if err != nil {
+		t.Fatalf("CREATE INDEX USING HNSW failed: %v", err)
+	}
+
+	idx := exec.catalog.GetIndex("emb_idx")
+	if idx == nil || idx.Type != schema.IndexTypeHNSW {
+		t.Fatalf("expected HNSW index in catalog, got %+v", idx)
+	}
+	want := schema.HNSWParams{M: 32, EfConstruction: 400, DistanceMetric: schema.DistanceMetricEuclidean, UseHeuristic: true}
+	if idx.HNSWParams == nil || *idx.HNSWParams != want {
+		t.Errorf("HNSWParams = %+v, want %+v", idx.HNSWParams, want)
+	}
+
+	// Rows inserted after CREATE INDEX are found through the index
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (2, x'%s')", vectorToHex([]float32{0, 1, 0}))); err != nil {
+		t.Fatalf("failed to insert row 2: %v", err)
+	}
+	rowIDs := vectorScanRowIDs(t, exec, []float32{0, 1, 0}, 1)
+	if len(rowIDs) != 1 || rowIDs[0] != 2 {
+		t.Errorf("expected rowid 2, got %v", rowIDs)
+	}
+
+	if _, err := exec.Execute("DROP INDEX emb_idx"); err != nil {
+		t.Fatalf("DROP INDEX failed: %v", err)
+	}
+	if exec.catalog.GetIndex("emb_idx") != nil || exec.hnswIndexes["emb_idx"] != nil {
+		t.Error("expected index to be gone after DROP INDEX")
+	}
+}
+
+// TestCreateIndexUsingHNSW_Invalid tests that bad HNSW index definitions are rejected
+func TestCreateIndexUsingHNSW_Invalid(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, name TEXT, embedding VECTOR(3))"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	tests := []string{
+		"CREATE INDEX bad ON embeddings USING HNSW (name)",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding, name)",
+		"CREATE UNIQUE INDEX bad ON embeddings USING HNSW (embedding)",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (m=1)",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (metric='hamming')",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (ef_search=10)",
+		"CREATE INDEX bad ON embeddings USING IVF (embedding)",
+		"CREATE INDEX bad ON embeddings (name) WITH (m=16)",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (quantization='fp16')",
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (pq_segments=3)",
+		// Quantized indexes train on existing rows, and the table is empty
+		"CREATE INDEX bad ON embeddings USING HNSW (embedding) WITH (quantization='int8')",
+	}
+	for _, sql := range tests {
+		if _, err := exec.Execute(sql); err == nil {
+			t.Errorf("expected error for %q", sql)
+		}
+	}
+	if exec.catalog.GetIndex("bad") != nil {
+		t.Error("no index should have been registered")
+	}
+}
+
+// TestCreateIndexUsingHNSW_PersistsAcrossReopen tests that DDL-created HNSW indexes keep their parameters after reopen
+func TestCreateIndexUsingHNSW_PersistsAcrossReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	{
+		p, err := pager.Open(dbPath, pager.Options{})
+		if err != nil {
+			t.Fatalf("pager.Open: %v", err)
+		}
+		exec := New(p)
+		if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, embedding VECTOR(3))"); err != nil {
+			exec.Close()
+			t.Fatalf("failed to create table: %v", err)
+		}
+		if _, err := exec.Execute("CREATE INDEX emb_idx ON embeddings USING HNSW (embedding) WITH (m=8, metric='manhattan')"); err != nil {
+			exec.Close()
+			t.Fatalf("CREATE INDEX USING HNSW failed: %v", err)
+		}
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (1, x'%s')", vectorToHex([]float32{1, 0, 0}))); err != nil {
+			exec.Close()
+			t.Fatalf("failed to insert row 1: %v", err)
+		}
+		if err := exec.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open reopen: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	idx := exec.catalog.GetIndex("emb_idx")
+	if idx == nil || idx.Type != schema.IndexTypeHNSW {
+		t.Fatalf("expected HNSW index in catalog after reopen, got %+v", idx)
+	}
+	want := schema.HNSWParams{M: 8, EfConstruction: 200, DistanceMetric: schema.DistanceMetricManhattan}
+	if idx.HNSWParams == nil || *idx.HNSWParams != want {
+		t.Errorf("HNSWParams = %+v, want %+v", idx.HNSWParams, want)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, []float32{1, 0, 0}, 1); len(rowIDs) != 1 || rowIDs[0] != 1 {
+		t.Errorf("expected rowid 1 after reopen, got %v", rowIDs)
+	}
+}
+
+// setupTenantDocs creates a docs table of 200 points on a line, where row i has tenant i%5,
+// and indexes it with a euclidean HNSW index
+func setupTenantDocs(t *testing.T, exec *Executor) {
+	t.Helper()
+	if _, err := exec.Execute("CREATE TABLE docs (id INT PRIMARY KEY, tenant INT, lang TEXT, embedding VECTOR(2) NONORMALIZE)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		lang := "en"
+		if i%2 == 1 {
+			lang = "fr"
+		}
+		sql := fmt.Sprintf("INSERT INTO docs VALUES (%d, %d, '%s', x'%s')", i, i%5, lang, vectorToHex([]float32{float32(i), 0}))
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("failed to insert row %d: %v", i, err)
+		}
+	}
+	if _, err := exec.Execute("CREATE INDEX idx_docs_embedding ON docs USING HNSW (embedding) WITH (metric = 'euclidean')"); err != nil {
+		t.Fatalf("failed to create HNSW index: %v", err)
+	}
+}
+
+func TestVectorQuantizeScan_FilterPushdown(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupTenantDocs(t, exec)
+
+	query := vectorToHex([]float32{100.4, 0})
+	tests := []struct {
+		name  string
+		where string
+		want  []int64
+	}{
+		// Without pushdown the 3 nearest rows (100, 101, 99) would all be filtered out
+		{"selective", "d.tenant = 2", []int64{102, 97, 107}},
+		{"multiple predicates", "d.tenant = 2 AND d.lang = 'en'", []int64{102, 92, 112}},
+		{"very selective", "d.id = 7", []int64{7}},
+		{"no match", "d.tenant = 9", []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := fmt.Sprintf("SELECT d.id, v.distance FROM vector_quantize_scan('docs', 'embedding', x'%s', 3) v JOIN docs d ON d.id = v.rowid WHERE %s", query, tt.where)
+			result, err := exec.Execute(sql)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			got := make([]int64, len(result.Rows))
+			for i, row := range result.Rows {
+				got[i] = row[0].Int()
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected ids %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVectorQuantizeScan_FilterPushdownExplain(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupTenantDocs(t, exec)
+
+	sql := fmt.Sprintf("EXPLAIN QUERY PLAN SELECT d.id FROM vector_quantize_scan('docs', 'embedding', x'%s', 3) v JOIN docs d ON d.id = v.rowid WHERE d.tenant = 2", vectorToHex([]float32{1, 0}))
+	result, err := exec.Execute(sql)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+
+	found := false
+	for _, row := range result.Rows {
+		if row[3].Text() == "SEARCH TABLE docs USING HNSW INDEX idx_docs_embedding (embedding) AS v (k=3, ef=50) WITH FILTER" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected filtered HNSW search in plan, got %v", result.Rows)
+	}
+}
+
+// explainVectorScan returns the EXPLAIN QUERY PLAN detail of the HNSW search in sql
+func explainVectorScan(t *testing.T, exec *Executor, sql string) string {
+	t.Helper()
+	result, err := exec.Execute("EXPLAIN QUERY PLAN " + sql)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	for _, row := range result.Rows {
+		if detail := row[3].Text(); strings.Contains(detail, "USING HNSW INDEX") {
+			return detail
+		}
+	}
+	t.Fatalf("no HNSW search in plan: %v", result.Rows)
+	return ""
+}
+
+func TestVectorQuantizeScan_EfSearch(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupTenantDocs(t, exec)
+
+	query := vectorToHex([]float32{100.4, 0})
+	tests := []struct {
+		name   string
+		pragma string
+		args   string
+		wantEf string
+	}{
+		{"index default", "", "3", "ef=50"},
+		{"fifth argument", "", "3, 200", "ef=200"},
+		{"named argument", "", "3, ef => 120", "ef=120"},
+		{"session pragma", "PRAGMA hnsw_ef_search = 80", "3", "ef=80"},
+		{"argument overrides pragma", "PRAGMA hnsw_ef_search = 80", "3, ef => 150", "ef=150"},
+		{"never below k", "PRAGMA hnsw_ef_search = 0", "20, ef => 5", "ef=20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.pragma != "" {
+				if _, err := exec.Execute(tt.pragma); err != nil {
+					t.Fatalf("%s: %v", tt.pragma, err)
+				}
+			}
+			sql := fmt.Sprintf("SELECT * FROM vector_quantize_scan('docs', 'embedding', x'%s', %s)", query, tt.args)
+
+			if detail := explainVectorScan(t, exec, sql); !strings.Contains(detail, tt.wantEf) {
+				t.Errorf("expected %s in plan, got %q", tt.wantEf, detail)
+			}
+
+			result, err := exec.Execute(sql)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(result.Rows) == 0 || result.Rows[0][0].Int() != 100 {
+				t.Errorf("expected nearest row 100, got %v", result.Rows)
+			}
+		})
+	}
+}
+
+func TestVectorQuantizeScan_EfSearchInvalid(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupTenantDocs(t, exec)
+
+	query := vectorToHex([]float32{1, 0})
+	for _, args := range []string{"3, 0", "3, -5", "3, 'high'", "3, 100, ef => 100", "3, ef => 10, ef => 20", "3, beam => 10"} {
+		sql := fmt.Sprintf("SELECT * FROM vector_quantize_scan('docs', 'embedding', x'%s', %s)", query, args)
+		if _, err := exec.Execute(sql); err == nil {
+			t.Errorf("expected error for arguments (%s)", args)
+		}
+	}
+
+	if _, err := exec.Execute("PRAGMA hnsw_ef_search = -1"); err == nil {
+		t.Error("expected error for negative hnsw_ef_search")
+	}
+}
+
+func TestVectorQuantizeScan_EfSearchPlaceholders(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupTenantDocs(t, exec)
+
+	stmt, err := parser.New("SELECT * FROM vector_quantize_scan('docs', 'embedding', ?, ?, ef => ?)").Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	query := types.NewVector([]float32{10.2, 0})
+	params := []types.Value{types.NewBlob(query.ToBytes()), types.NewInt(2), types.NewInt(100)}
+
+	result, err := exec.ExecuteAST(stmt, params)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0][0].Int() != 10 || result.Rows[1][0].Int() != 11 {
+		t.Errorf("expected rows [10 11], got %v", result.Rows)
+	}
+}
+
+func TestPragmaHNSWEfSearch(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	result, err := exec.Execute("PRAGMA hnsw_ef_search")
+	if err != nil {
+		t.Fatalf("PRAGMA hnsw_ef_search: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Int() != 0 {
+		t.Errorf("expected default 0, got %v", result.Rows)
+	}
+
+	if _, err := exec.Execute("PRAGMA hnsw_ef_search = 200"); err != nil {
+		t.Fatalf("PRAGMA hnsw_ef_search = 200: %v", err)
+	}
+	result, err = exec.Execute("PRAGMA hnsw_ef_search")
+	if err != nil {
+		t.Fatalf("PRAGMA hnsw_ef_search: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Int() != 200 {
+		t.Errorf("expected 200, got %v", result.Rows)
+	}
+}
+
+// setupAngleDocs creates a docs table of 180 unit vectors, row i at angle i degrees with
+// tenant i%5, optionally indexed with an HNSW index using the given WITH options
+func setupAngleDocs(t *testing.T, exec *Executor, indexOptions string) {
+	t.Helper()
+	if _, err := exec.Execute("CREATE TABLE docs (id INT PRIMARY KEY, tenant INT, embedding VECTOR(2))"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i := 0; i < 180; i++ {
+		rad := float64(i) * math.Pi / 180
+		vec := vectorToHex([]float32{float32(math.Cos(rad)), float32(math.Sin(rad))})
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO docs VALUES (%d, %d, x'%s')", i, i%5, vec)); err != nil {
+			t.Fatalf("failed to insert row %d: %v", i, err)
+		}
+	}
+	if indexOptions != "" {
+		if _, err := exec.Execute("CREATE INDEX idx_docs_embedding ON docs USING HNSW (embedding) " + indexOptions); err != nil {
+			t.Fatalf("failed to create HNSW index: %v", err)
+		}
+	}
+}
+
+// angleQuery returns the hex literal of the unit vector at the given angle in degrees
+func angleQuery(degrees float64) string {
+	rad := degrees * math.Pi / 180
+	return vectorToHex([]float32{float32(math.Cos(rad)), float32(math.Sin(rad))})
+}
+
+func TestVectorOrderBy(t *testing.T) {
+	tests := []struct {
+		name         string
+		indexOptions string
+		wantPlan     string
+	}{
+		{"no index", "", "TOP-K SORT"},
+		{"cosine index", "WITH (metric = 'cosine')", "SEARCH TABLE docs USING HNSW INDEX idx_docs_embedding (embedding)"},
+		// vector_distance is cosine, so a euclidean index cannot answer it
+		{"euclidean index", "WITH (metric = 'euclidean')", "TOP-K SORT"},
+		{"int8 index", "WITH (quantization = 'int8')", "SEARCH TABLE docs USING HNSW INDEX idx_docs_embedding (embedding)"},
+		{"pq index", "WITH (quantization = 'pq')", "SEARCH TABLE docs USING HNSW INDEX idx_docs_embedding (embedding)"},
+	}
+
+	query := angleQuery(50.3)
+	queries := []struct {
+		sql  string
+		want []int64
+	}{
+		{fmt.Sprintf("SELECT id FROM docs ORDER BY vector_distance(embedding, x'%s') LIMIT 4", query), []int64{50, 51, 49, 52}},
+		{fmt.Sprintf("SELECT id FROM docs ORDER BY vector_distance(x'%s', embedding) LIMIT 2 OFFSET 1", query), []int64{51, 49}},
+		{fmt.Sprintf("SELECT d.id FROM docs d WHERE d.tenant = 2 ORDER BY vector_distance(d.embedding, x'%s') LIMIT 3", query), []int64{52, 47, 57}},
+		{fmt.Sprintf("SELECT id FROM docs ORDER BY vector_distance(embedding, x'%s') LIMIT 0", query), []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, cleanup := setupTestExecutor(t)
+			defer cleanup()
+			setupAngleDocs(t, exec, tt.indexOptions)
+
+			for _, q := range queries {
+				result, err := exec.Execute(q.sql)
+				if err != nil {
+					t.Fatalf("%s: %v", q.sql, err)
+				}
+				got := make([]int64, len(result.Rows))
+				for i, row := range result.Rows {
+					got[i] = row[0].Int()
+				}
+				if fmt.Sprint(got) != fmt.Sprint(q.want) {
+					t.Errorf("%s: expected ids %v, got %v", q.sql, q.want, got)
+				}
+
+				plan, err := exec.Execute("EXPLAIN QUERY PLAN " + q.sql)
+				if err != nil {
+					t.Fatalf("EXPLAIN QUERY PLAN %s: %v", q.sql, err)
+				}
+				found := false
+				for _, row := range plan.Rows {
+					detail := row[3].Text()
+					if strings.HasPrefix(detail, tt.wantPlan) {
+						found = true
+					}
+					if detail == "SORT" {
+						t.Errorf("%s: expected no full sort, got plan %v", q.sql, plan.Rows)
+					}
+				}
+				if !found {
+					t.Errorf("%s: expected %q in plan, got %v", q.sql, tt.wantPlan, plan.Rows)
+				}
+			}
+		})
+	}
+}
+
+func TestVectorOrderBy_HNSWFilterAndEf(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAngleDocs(t, exec, "")
+	if _, err := exec.Execute("CREATE INDEX idx_docs_embedding ON docs USING HNSW (embedding)"); err != nil {
+		t.Fatalf("failed to create HNSW index: %v", err)
+	}
+	if _, err := exec.Execute("PRAGMA hnsw_ef_search = 64"); err != nil {
+		t.Fatalf("PRAGMA hnsw_ef_search: %v", err)
+	}
+
+	sql := fmt.Sprintf("SELECT id FROM docs WHERE tenant = 2 ORDER BY vector_distance(embedding, x'%s') LIMIT 3", angleQuery(50.3))
+	want := "SEARCH TABLE docs USING HNSW INDEX idx_docs_embedding (embedding) (k=3, ef=64) WITH FILTER"
+	if detail := explainVectorScan(t, exec, sql); detail != want {
+		t.Errorf("expected plan %q, got %q", want, detail)
+	}
+}
+
+func TestVectorOrderBy_HNSWResidualFilter(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupAngleDocs(t, exec, "WITH (metric = 'cosine')")
+
+	// The subquery rejects the nearest rows (50, 49, 51, ...) and cannot run
+	// inside the index search; the query must still return 3 rows
+	sql := fmt.Sprintf("SELECT id FROM docs WHERE tenant = 2 AND id IN (SELECT id FROM docs WHERE id > 55) ORDER BY vector_distance(embedding, x'%s') LIMIT 3", angleQuery(50.3))
+	result, err := exec.Execute(sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	got := make([]int64, len(result.Rows))
+	for i, row := range result.Rows {
+		got[i] = row[0].Int()
+	}
+	if fmt.Sprint(got) != "[57 62 67]" {
+		t.Errorf("expected ids [57 62 67], got %v", got)
+	}
+	plan, err := exec.Execute("EXPLAIN QUERY PLAN " + sql)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	found := false
+	for _, row := range plan.Rows {
+		found = found || strings.HasPrefix(row[3].Text(), "TOP-K SORT")
+	}
+	if !found {
+		t.Errorf("expected a top-k scan instead of an index search, got plan %v", plan.Rows)
+	}
+}
+
+// setupRandomEmbeddings creates an embeddings table of 8-dimensional vectors with
+// reproducible random values and returns the vectors by rowid
+func setupRandomEmbeddings(t *testing.T, exec *Executor, n int) map[int64]*types.Vector {
+	t.Helper()
+	if _, err := exec.Execute("CREATE TABLE embeddings (id INT PRIMARY KEY, embedding VECTOR(8) NONORMALIZE)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	rng := rand.New(rand.NewSource(42))
+	vectors := make(map[int64]*types.Vector, n)
+	for i := 1; i <= n; i++ {
+		data := make([]float32, 8)
+		for j := range data {
+			data[j] = rng.Float32()*2 - 1
+		}
+		vectors[int64(i)] = types.NewVector(data)
+		if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (%d, x'%s')", i, vectorToHex(data))); err != nil {
+			t.Fatalf("failed to insert row %d: %v", i, err)
+		}
+	}
+	return vectors
+}
+
+// TestCreateIndexUsingHNSW_Quantization tests int8 and PQ indexes with and without re-ranking
+func TestCreateIndexUsingHNSW_Quantization(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		exact   bool // distances are re-ranked against the column
+	}{
+		{"int8", "WITH (metric='l2', quantization='int8')", true},
+		{"int8 without rerank", "WITH (metric='l2', quantization='int8', rerank=false)", false},
+		{"pq", "WITH (metric='l2', quantization='pq', pq_segments=4)", true},
+		{"pq without rerank", "WITH (metric='l2', quantization='pq', pq_segments=2, rerank=false)", false},
+	}
+
+	query := []float32{0.5, -0.25, 0.1, 0.8, -0.6, 0.3, 0, -0.9}
+	queryVec := types.NewVector(query)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, cleanup := setupTestExecutor(t)
+			defer cleanup()
+			vectors := setupRandomEmbeddings(t, exec, 300)
+
+			if _, err := exec.Execute("CREATE INDEX emb_idx ON embeddings USING HNSW (embedding) " + tt.options); err != nil {
+				t.Fatalf("CREATE INDEX failed: %v", err)
+			}
+
+			result, err := exec.Execute(fmt.Sprintf("SELECT * FROM vector_quantize_scan('embeddings', 'embedding', x'%s', 5, 100)", vectorToHex(query)))
+			if err != nil {
+				t.Fatalf("vector_quantize_scan failed: %v", err)
+			}
+			if len(result.Rows) != 5 {
+				t.Fatalf("expected 5 results, got %d", len(result.Rows))
+			}
+			mismatched := 0
+			for i, row := range result.Rows {
+				exact := queryVec.EuclideanDistance(vectors[row[0].Int()])
+				got := row[1].Float()
+				if math.Abs(got-float64(exact)) > 1e-6 {
+					mismatched++
+				}
+				if i > 0 && got < result.Rows[i-1][1].Float() {
+					t.Errorf("results not sorted by distance: %v", result.Rows)
+				}
+			}
+			if tt.exact && mismatched > 0 {
+				t.Errorf("expected re-ranked exact distances, %d of 5 differ", mismatched)
+			}
+			if !tt.exact && mismatched == 0 {
+				t.Errorf("expected approximate distances computed on the codes")
+			}
+		})
+	}
+}
+
+// TestCreateIndexUsingHNSW_QuantizationPersists tests that a quantized index keeps its
+// codebook and parameters after reopen and keeps indexing new rows
+func TestCreateIndexUsingHNSW_QuantizationPersists(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	{
+		p, err := pager.Open(dbPath, pager.Options{})
+		if err != nil {
+			t.Fatalf("pager.Open: %v", err)
+		}
+		exec := New(p)
+		setupRandomEmbeddings(t, exec, 100)
+		if _, err := exec.Execute("CREATE INDEX emb_idx ON embeddings USING HNSW (embedding) WITH (metric='l2', quantization='pq')"); err != nil {
+			exec.Close()
+			t.Fatalf("CREATE INDEX failed: %v", err)
+		}
+		if err := exec.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	p, err := pager.Open(dbPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open reopen: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	idx := exec.catalog.GetIndex("emb_idx")
+	if idx == nil {
+		t.Fatal("expected emb_idx in catalog after reopen")
+	}
+	want := schema.HNSWParams{M: 16, EfConstruction: 200, DistanceMetric: schema.DistanceMetricEuclidean,
+		Quantization: schema.QuantizationPQ, PQSegments: 1, Rerank: true}
+	if idx.HNSWParams == nil || *idx.HNSWParams != want {
+		t.Errorf("HNSWParams = %+v, want %+v", idx.HNSWParams, want)
+	}
+
+	far := []float32{5, 5, 5, 5, 5, 5, 5, 5}
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO embeddings VALUES (1000, x'%s')", vectorToHex(far))); err != nil {
+		t.Fatalf("insert after reopen failed: %v", err)
+	}
+	if rowIDs := vectorScanRowIDs(t, exec, far, 1); len(rowIDs) != 1 || rowIDs[0] != 1000 {
+		t.Errorf("expected rowid 1000 nearest to the new vector, got %v", rowIDs)
+	}
+}
+
+func TestVectorQuantize_Quantization(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupRandomEmbeddings(t, exec, 50)
+
+	if _, err := exec.Execute("SELECT vector_quantize('embeddings', 'embedding', 'l2', 'fp16')"); err == nil {
+		t.Error("expected error for unknown quantization")
+	}
+	if _, err := exec.Execute("SELECT vector_quantize('embeddings', 'embedding', 'l2', 'int8')"); err != nil {
+		t.Fatalf("vector_quantize failed: %v", err)
+	}
+	params := exec.catalog.GetIndex("hnsw_embeddings_embedding").HNSWParams
+	if params.Quantization != schema.QuantizationInt8 || !params.Rerank {
+		t.Errorf("expected a re-ranking int8 index, got %+v", params)
+	}
+	sql := reconstructCreateHNSWIndexSQL(exec.catalog.GetIndex("hnsw_embeddings_embedding"))
+	if !strings.HasSuffix(sql, "quantization='int8', rerank=true)") {
+		t.Errorf("reconstructed SQL does not record quantization: %s", sql)
+	}
+}
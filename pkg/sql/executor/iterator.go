@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"container/heap"
+	"context"
 	"fmt"
 	"sort"
 
@@ -27,11 +29,44 @@ type RowIterator interface {
 	Close()
 }
 
+// cancelCheckRows is the number of rows an iterator handles between two looks
+// at the context of the statement
+const cancelCheckRows = 256
+
+// canceler stops the row loops of an iterator once the context of the
+// statement is canceled or past its deadline. Rows come much faster than
+// cancellations, so it only looks at the context every cancelCheckRows rows.
+type canceler struct {
+	ctx  context.Context
+	rows int
+}
+
+// canceler returns a canceler for the context of the statement being executed
+func (e *Executor) canceler() canceler {
+	return canceler{ctx: e.ctx}
+}
+
+// check counts a row and returns the error of the context if the statement
+// has to stop
+func (c *canceler) check() error {
+	if c.ctx == nil {
+		return nil
+	}
+	c.rows++
+	if c.rows < cancelCheckRows {
+		return nil
+	}
+	c.rows = 0
+	return c.ctx.Err()
+}
+
 // TableScanIterator iterates over a table using a B-tree cursor
 type TableScanIterator struct {
 	cursor tree.Cursor
 	table  *schema.TableDef // Optional: for type conversion (JSON columns)
 	val    []types.Value
+	cancel canceler
+	err    error
 }
 
 func NewTableScanIterator(t tree.Tree) *TableScanIterator {
@@ -54,6 +89,12 @@ func NewTableScanIteratorWithSchema(t tree.Tree, table *schema.TableDef) *TableS
 
 // Next advances to the next row
 func (it *TableScanIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.cancel.check(); it.err != nil {
+		return false
+	}
 	if it.val == nil { // First call
 		if !it.cursor.Valid() {
 			return false
@@ -83,14 +124,26 @@ func (it *TableScanIterator) Next() bool {
 	return true
 }
 
-// applyTypeConversions converts TEXT back to JSON for JSON columns
+// applyTypeConversions completes rows stored before ADD COLUMN and converts
+// TEXT back to JSON for JSON columns
 func (it *TableScanIterator) applyTypeConversions() {
 	if it.table == nil || it.val == nil {
 		return
 	}
-	for i, col := range it.table.Columns {
-		if i < len(it.val) && col.Type == types.TypeJSON && it.val[i].Type() == types.TypeText {
-			it.val[i] = types.NewJSON(it.val[i].Text())
+	it.val = it.table.FillMissingColumns(it.val)
+	applyColumnTypes(it.table, it.val)
+}
+
+// decodeRow decodes a row of a table, completing rows stored before ADD COLUMN
+func decodeRow(table *schema.TableDef, data []byte) []types.Value {
+	return table.FillMissingColumns(record.Decode(data))
+}
+
+// applyColumnTypes converts decoded row values back to their column types (TEXT to JSON)
+func applyColumnTypes(table *schema.TableDef, row []types.Value) {
+	for i, col := range table.Columns {
+		if i < len(row) && col.Type == types.TypeJSON && row[i].Type() == types.TypeText {
+			row[i] = types.NewJSON(row[i].Text())
 		}
 	}
 }
@@ -100,7 +153,7 @@ func (it *TableScanIterator) Value() []types.Value {
 }
 
 func (it *TableScanIterator) Err() error {
-	return nil
+	return it.err
 }
 
 func (it *TableScanIterator) Close() {
@@ -211,6 +264,8 @@ type NestedLoopJoinIterator struct {
 	right     RowIterator
 	condition parser.Expression
 	executor  *Executor
+	cancel    canceler
+	err       error
 
 	// Join type (INNER, LEFT, RIGHT, FULL)
 	joinType parser.JoinType
@@ -253,6 +308,10 @@ type NestedLoopJoinIterator struct {
 // Materialization is standard for simple NLJ.
 
 func (it *NestedLoopJoinIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
 	// Phase 1: emit unmatched right rows (for RIGHT/FULL joins)
 	if it.phase == 1 {
 		return it.nextUnmatchedRight()
@@ -267,8 +326,12 @@ func (it *NestedLoopJoinIterator) Next() bool {
 			copy(clone, row)
 			it.rightRows = append(it.rightRows, clone)
 		}
+		it.err = it.right.Err()
 		it.right.Close() // Close original right iterator
 		it.rightMaterialized = true
+		if it.err != nil {
+			return false
+		}
 
 		// Initialize rightMatched tracking for RIGHT/FULL joins
 		if it.joinType == parser.JoinRight || it.joinType == parser.JoinFull {
@@ -297,6 +360,13 @@ func (it *NestedLoopJoinIterator) Next() bool {
 	}
 
 	for {
+		// A cross join of large inputs runs here for long without
+		// producing rows
+		if err := it.cancel.check(); err != nil {
+			it.err = err
+			return false
+		}
+
 		// Iterate right rows
 		if it.rightIdx < len(it.rightRows) {
 			rightRow := it.rightRows[it.rightIdx]
@@ -413,7 +483,10 @@ func (it *NestedLoopJoinIterator) Value() []types.Value {
 }
 
 func (it *NestedLoopJoinIterator) Err() error {
-	return nil
+	if it.err != nil {
+		return it.err
+	}
+	return it.left.Err()
 }
 
 func (it *NestedLoopJoinIterator) Close() {
@@ -459,8 +532,8 @@ func (it *HashJoinIterator) buildHashTable() {
 		clone := make([]types.Value, len(row))
 		copy(clone, row)
 
-		// Get key value
-		if it.leftKeyIdx >= len(clone) {
+		// Get key value; a NULL key equals nothing, so the row never joins
+		if it.leftKeyIdx >= len(clone) || clone[it.leftKeyIdx].IsNull() {
 			continue
 		}
 		keyVal := clone[it.leftKeyIdx]
@@ -524,7 +597,7 @@ func (it *HashJoinIterator) Next() bool {
 		}
 
 		// Get key value from right row
-		if it.rightKeyIdx >= len(rightRow) {
+		if it.rightKeyIdx >= len(rightRow) || rightRow[it.rightKeyIdx].IsNull() {
 			it.matchingLeftRows = nil
 			it.matchingIdx = 0
 			continue
@@ -543,7 +616,10 @@ func (it *HashJoinIterator) Value() []types.Value {
 }
 
 func (it *HashJoinIterator) Err() error {
-	return nil
+	if err := it.left.Err(); err != nil {
+		return err
+	}
+	return it.right.Err()
 }
 
 func (it *HashJoinIterator) Close() {
@@ -576,15 +652,22 @@ type SortIterator struct {
 	orderBy  []parser.OrderByExpr
 	colMap   map[string]int
 	executor *Executor
+	cancel   canceler
 
 	// Sorted rows
 	rows     [][]types.Value
 	idx      int
 	prepared bool
+	err      error
 }
 
 func (it *SortIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
 	if !it.prepared {
+		it.prepared = true
+
 		// Materialize all rows from child
 		for it.child.Next() {
 			row := it.child.Value()
@@ -592,13 +675,18 @@ func (it *SortIterator) Next() bool {
 			copy(clone, row)
 			it.rows = append(it.rows, clone)
 		}
+		it.err = it.child.Err()
 		it.child.Close()
+		if it.err != nil {
+			return false
+		}
 
 		// Sort rows using ORDER BY expressions
-		it.sortRows()
+		if it.err = it.sortRows(); it.err != nil {
+			return false
+		}
 
 		it.idx = 0
-		it.prepared = true
 	}
 
 	if it.idx < len(it.rows) {
@@ -616,28 +704,33 @@ func (it *SortIterator) Value() []types.Value {
 }
 
 func (it *SortIterator) Err() error {
-	return nil
+	return it.err
 }
 
 func (it *SortIterator) Close() {
 	// Child already closed
 }
 
-// sortRows sorts the materialized rows by ORDER BY expressions
-func (it *SortIterator) sortRows() {
+// sortRows sorts the materialized rows by ORDER BY expressions. It stops with
+// the error of the context of the statement if that is canceled meanwhile.
+func (it *SortIterator) sortRows() error {
 	if len(it.rows) == 0 || len(it.orderBy) == 0 {
-		return
+		return nil
 	}
 
 	// Simple bubble sort for correctness (can optimize later)
 	n := len(it.rows)
 	for i := 0; i < n-1; i++ {
 		for j := 0; j < n-i-1; j++ {
+			if err := it.cancel.check(); err != nil {
+				return err
+			}
 			if it.compare(it.rows[j], it.rows[j+1]) > 0 {
 				it.rows[j], it.rows[j+1] = it.rows[j+1], it.rows[j]
 			}
 		}
 	}
+	return nil
 }
 
 // compare compares two rows based on ORDER BY expressions
@@ -664,6 +757,131 @@ func (it *SortIterator) compare(a, b []types.Value) int {
 	return 0
 }
 
+// TopKIterator returns the first k rows of its child in ORDER BY order.
+// It keeps at most k rows in a heap instead of materializing and sorting the
+// whole input. Rows with equal sort keys keep their input order.
+type TopKIterator struct {
+	child    RowIterator
+	orderBy  []parser.OrderByExpr
+	k        int
+	colMap   map[string]int
+	executor *Executor
+
+	// Selected rows, in sorted order
+	rows     [][]types.Value
+	idx      int
+	prepared bool
+	err      error
+}
+
+func (it *TopKIterator) Next() bool {
+	if !it.prepared {
+		it.prepared = true
+		it.selectRows()
+	}
+
+	if it.idx < len(it.rows) {
+		it.idx++
+		return true
+	}
+	return false
+}
+
+// selectRows consumes the child and keeps the best k rows
+func (it *TopKIterator) selectRows() {
+	h := &topKHeap{orderBy: it.orderBy}
+	seq := 0
+	for it.child.Next() {
+		row := it.child.Value()
+		entry := topKEntry{keys: make([]types.Value, len(it.orderBy)), seq: seq}
+		seq++
+		for i, ob := range it.orderBy {
+			val, err := it.executor.evaluateExpr(ob.Expr, row, it.colMap)
+			if err != nil {
+				// Like SortIterator, rows whose key cannot be evaluated sort as NULL
+				val = types.NewNull()
+			}
+			entry.keys[i] = val
+		}
+
+		if h.Len() < it.k {
+			entry.row = cloneRow(row)
+			heap.Push(h, entry)
+		} else if it.k > 0 && h.compare(&entry, &h.entries[0]) < 0 {
+			entry.row = cloneRow(row)
+			h.entries[0] = entry
+			heap.Fix(h, 0)
+		}
+	}
+	it.err = it.child.Err()
+	it.child.Close()
+
+	// Pop the worst row first to fill the result from the back
+	it.rows = make([][]types.Value, h.Len())
+	for i := len(it.rows) - 1; i >= 0; i-- {
+		it.rows[i] = heap.Pop(h).(topKEntry).row
+	}
+}
+
+func (it *TopKIterator) Value() []types.Value {
+	if it.idx > 0 && it.idx <= len(it.rows) {
+		return it.rows[it.idx-1]
+	}
+	return nil
+}
+
+func (it *TopKIterator) Err() error {
+	return it.err
+}
+
+func (it *TopKIterator) Close() {
+	// Child already closed
+}
+
+// cloneRow copies a row so it survives the child iterator advancing
+func cloneRow(row []types.Value) []types.Value {
+	clone := make([]types.Value, len(row))
+	copy(clone, row)
+	return clone
+}
+
+// topKEntry is a row held by TopKIterator along with its evaluated sort keys
+type topKEntry struct {
+	row  []types.Value
+	keys []types.Value
+	seq  int // input position, used to keep equal rows in input order
+}
+
+// topKHeap is a max-heap of topKEntry: the root is the row that sorts last
+type topKHeap struct {
+	entries []topKEntry
+	orderBy []parser.OrderByExpr
+}
+
+// compare orders two entries by their sort keys, then by input position
+func (h *topKHeap) compare(a, b *topKEntry) int {
+	for i, ob := range h.orderBy {
+		cmp := compareValuesForSort(a.keys[i], b.keys[i])
+		if cmp != 0 {
+			if ob.Direction == parser.OrderDesc {
+				return -cmp
+			}
+			return cmp
+		}
+	}
+	return a.seq - b.seq
+}
+
+func (h *topKHeap) Len() int           { return len(h.entries) }
+func (h *topKHeap) Less(i, j int) bool { return h.compare(&h.entries[i], &h.entries[j]) > 0 }
+func (h *topKHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *topKHeap) Push(x interface{}) { h.entries = append(h.entries, x.(topKEntry)) }
+func (h *topKHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
 // isIntegerTypeForSort returns true if the type is any integer type
 func isIntegerTypeForSort(t types.ValueType) bool {
 	switch t {
@@ -808,6 +1026,147 @@ func (it *LimitIterator) Close() {
 	it.child.Close()
 }
 
+// IndexScanIterator reads the key columns of the entries of a B-tree index in
+// index order. The table itself is not visited.
+type IndexScanIterator struct {
+	cursor  tree.Cursor
+	table   *schema.TableDef
+	columns []string // Indexed columns, the leading values of every key
+	started bool
+	val     []types.Value
+	cancel  canceler
+	err     error
+}
+
+func (it *IndexScanIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.cancel.check(); it.err != nil {
+		return false
+	}
+	if it.started {
+		it.cursor.Next()
+	}
+	it.started = true
+	if !it.cursor.Valid() {
+		it.val = nil
+		return false
+	}
+
+	// Keys are the column values, followed by the rowid for non-unique entries
+	key := record.Decode(it.cursor.Key())
+	if len(key) < len(it.columns) {
+		it.err = fmt.Errorf("index entry has %d values, expected %d", len(key), len(it.columns))
+		return false
+	}
+	it.val = key[:len(it.columns)]
+	for i, name := range it.columns {
+		if col, _ := it.table.GetColumn(name); col != nil && col.Type == types.TypeJSON && it.val[i].Type() == types.TypeText {
+			it.val[i] = types.NewJSON(it.val[i].Text())
+		}
+	}
+	return true
+}
+
+func (it *IndexScanIterator) Value() []types.Value {
+	return it.val
+}
+
+func (it *IndexScanIterator) Err() error {
+	return it.err
+}
+
+func (it *IndexScanIterator) Close() {
+	it.cursor.Close()
+}
+
+// DistinctIterator drops duplicate rows (SELECT DISTINCT) or every row after the
+// first for each value of the DISTINCT ON expressions. Rows stream through: with
+// sorted input only the key of the previous row is kept, otherwise the keys seen
+// so far.
+type DistinctIterator struct {
+	child    RowIterator
+	on       []parser.Expression // DISTINCT ON expressions (nil compares whole rows)
+	sorted   bool                // Input is ordered by the distinct key
+	colMap   map[string]int      // Input schema mapping
+	executor *Executor
+	cancel   canceler
+
+	seen    map[string]bool
+	lastKey string
+	hasLast bool
+	val     []types.Value
+	err     error
+}
+
+func (it *DistinctIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.child.Next() {
+		if it.err = it.cancel.check(); it.err != nil {
+			return false
+		}
+		row := it.child.Value()
+		key, err := it.distinctKey(row)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if it.sorted {
+			if it.hasLast && key == it.lastKey {
+				continue
+			}
+			it.lastKey, it.hasLast = key, true
+		} else {
+			if it.seen == nil {
+				it.seen = make(map[string]bool)
+			}
+			if it.seen[key] {
+				continue
+			}
+			it.seen[key] = true
+		}
+
+		it.val = row
+		return true
+	}
+	it.err = it.child.Err()
+	it.val = nil
+	return false
+}
+
+// distinctKey returns the key rows are compared by: the whole row, or the
+// values of the DISTINCT ON expressions
+func (it *DistinctIterator) distinctKey(row []types.Value) (string, error) {
+	if len(it.on) == 0 {
+		return rowKey(row), nil
+	}
+	values := make([]types.Value, len(it.on))
+	for i, expr := range it.on {
+		val, err := it.executor.evaluateExpr(expr, row, it.colMap)
+		if err != nil {
+			return "", err
+		}
+		values[i] = val
+	}
+	return rowKey(values), nil
+}
+
+func (it *DistinctIterator) Value() []types.Value {
+	return it.val
+}
+
+func (it *DistinctIterator) Err() error {
+	return it.err
+}
+
+func (it *DistinctIterator) Close() {
+	it.child.Close()
+}
+
 // HashGroupByIterator performs GROUP BY with hash-based grouping
 type HashGroupByIterator struct {
 	child      RowIterator
@@ -816,11 +1175,13 @@ type HashGroupByIterator struct {
 	having     parser.Expression         // Optional HAVING clause
 	colMap     map[string]int            // Input schema mapping
 	executor   *Executor
+	cancel     canceler
 
 	// State
 	groups   []groupEntry // Collected groups after materialization
 	idx      int          // Current position in groups
 	prepared bool
+	err      error
 }
 
 // groupEntry represents a single group with its key and accumulated aggregates
@@ -833,7 +1194,9 @@ type groupEntry struct {
 
 func (it *HashGroupByIterator) Next() bool {
 	if !it.prepared {
-		it.prepare()
+		if it.err = it.prepare(); it.err != nil {
+			return false
+		}
 	}
 
 	for it.idx < len(it.groups) {
@@ -842,9 +1205,15 @@ func (it *HashGroupByIterator) Next() bool {
 
 		// Check HAVING clause if present
 		if it.having != nil {
-			// Create a row with group key values and aggregate results for HAVING evaluation
+			// Aggregates in HAVING are computed over the group's rows; the rest
+			// is evaluated against the group key values and aggregate results
+			having, err := it.resolveHavingAggregates(it.having, group.rows)
+			if err != nil {
+				it.err = err
+				return false
+			}
 			havingRow := it.buildOutputRow(&group)
-			match, err := it.executor.evaluateCondition(it.having, havingRow, it.buildHavingColMap(&group))
+			match, err := it.executor.evaluateCondition(having, havingRow, it.buildHavingColMap(&group))
 			if err != nil || !match {
 				continue
 			}
@@ -856,11 +1225,16 @@ func (it *HashGroupByIterator) Next() bool {
 }
 
 // prepare materializes input and builds groups
-func (it *HashGroupByIterator) prepare() {
+func (it *HashGroupByIterator) prepare() error {
+	it.prepared = true
 	groupMap := make(map[string]*groupEntry)
 
 	// Materialize all input rows and group them
 	for it.child.Next() {
+		if err := it.cancel.check(); err != nil {
+			it.child.Close()
+			return err
+		}
 		row := it.child.Value()
 		clone := make([]types.Value, len(row))
 		copy(clone, row)
@@ -880,7 +1254,11 @@ func (it *HashGroupByIterator) prepare() {
 		}
 		group.rows = append(group.rows, clone)
 	}
+	err := it.child.Err()
 	it.child.Close()
+	if err != nil {
+		return err
+	}
 
 	// Handle aggregate without GROUP BY that has no matching rows
 	// SQL semantics: SELECT COUNT(*) FROM t WHERE 1=0 should return 1 row with COUNT=0
@@ -896,12 +1274,14 @@ func (it *HashGroupByIterator) prepare() {
 
 	// Convert map to slice and compute final aggregates
 	for _, group := range groupMap {
-		it.computeAggregates(group)
+		if err := it.computeAggregates(group); err != nil {
+			return err
+		}
 		it.groups = append(it.groups, *group)
 	}
 
 	it.idx = 0
-	it.prepared = true
+	return nil
 }
 
 // computeGroupKey computes a string key for grouping
@@ -943,11 +1323,11 @@ func (it *HashGroupByIterator) computeGroupKey(row []types.Value) (string, []typ
 }
 
 // computeAggregates computes aggregate values for a group based on specified aggregate expressions
-func (it *HashGroupByIterator) computeAggregates(group *groupEntry) {
+func (it *HashGroupByIterator) computeAggregates(group *groupEntry) error {
 	// If no explicit aggregates, add implicit COUNT(*)
 	if len(it.aggregates) == 0 {
 		group.aggregateValues = []types.Value{types.NewInt(int64(len(group.rows)))}
-		return
+		return nil
 	}
 
 	// Initialize aggregate values slice
@@ -955,17 +1335,33 @@ func (it *HashGroupByIterator) computeAggregates(group *groupEntry) {
 
 	// For each aggregate function, compute its value over the group's rows
 	for i, agg := range it.aggregates {
-		group.aggregateValues[i] = it.computeSingleAggregate(agg, group.rows)
+		val, err := it.computeSingleAggregate(agg, group.rows)
+		if err != nil {
+			return err
+		}
+		group.aggregateValues[i] = val
 	}
+	return nil
 }
 
 // computeSingleAggregate computes a single aggregate function over a set of rows
-func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExpr, rows [][]types.Value) types.Value {
+func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExpr, rows [][]types.Value) (types.Value, error) {
+	if err := checkAggregateArgs(agg); err != nil {
+		return types.NewNull(), err
+	}
+	rows, err := it.executor.filterAggregateRows(agg.Filter, rows, it.colMap)
+	if err != nil {
+		return types.NewNull(), err
+	}
+	if agg.Distinct && agg.Arg != nil {
+		rows = it.distinctArgRows(agg.Arg, rows)
+	}
+
 	switch agg.FuncName {
 	case "COUNT":
 		// COUNT(*) if no arg, otherwise count non-null values
 		if agg.Arg == nil {
-			return types.NewInt(int64(len(rows)))
+			return types.NewInt(int64(len(rows))), nil
 		}
 		count := int64(0)
 		for _, row := range rows {
@@ -974,11 +1370,11 @@ func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExp
 				count++
 			}
 		}
-		return types.NewInt(count)
+		return types.NewInt(count), nil
 
 	case "SUM":
 		if agg.Arg == nil {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
 		sum := float64(0)
 		hasValue := false
@@ -996,13 +1392,13 @@ func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExp
 			}
 		}
 		if !hasValue {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
-		return types.NewInt(int64(sum))
+		return types.NewInt(int64(sum)), nil
 
 	case "AVG":
 		if agg.Arg == nil {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
 		sum := float64(0)
 		count := int64(0)
@@ -1020,13 +1416,13 @@ func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExp
 			}
 		}
 		if count == 0 {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
-		return types.NewFloat(sum / float64(count))
+		return types.NewFloat(sum / float64(count)), nil
 
 	case "MIN":
 		if agg.Arg == nil {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
 		var minVal types.Value
 		hasValue := false
@@ -1041,13 +1437,13 @@ func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExp
 			}
 		}
 		if !hasValue {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
-		return minVal
+		return minVal, nil
 
 	case "MAX":
 		if agg.Arg == nil {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
 		var maxVal types.Value
 		hasValue := false
@@ -1062,13 +1458,33 @@ func (it *HashGroupByIterator) computeSingleAggregate(agg optimizer.AggregateExp
 			}
 		}
 		if !hasValue {
-			return types.NewNull()
+			return types.NewNull(), nil
 		}
-		return maxVal
+		return maxVal, nil
 
 	default:
-		return types.NewNull()
+		return it.executor.computeAggregate(agg, rows, it.colMap)
+	}
+}
+
+// distinctArgRows returns the first row for each distinct non-NULL value of arg,
+// so that an aggregate over them sees every value once
+func (it *HashGroupByIterator) distinctArgRows(arg parser.Expression, rows [][]types.Value) [][]types.Value {
+	seen := make(map[string]bool)
+	var distinct [][]types.Value
+	for _, row := range rows {
+		val, err := it.executor.evaluateExpr(arg, row, it.colMap)
+		if err != nil || val.IsNull() {
+			continue
+		}
+		key := valueToHashKey(val)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		distinct = append(distinct, row)
 	}
+	return distinct
 }
 
 // buildOutputRow builds an output row for a group (key values + aggregate results)
@@ -1108,7 +1524,7 @@ func (it *HashGroupByIterator) Value() []types.Value {
 }
 
 func (it *HashGroupByIterator) Err() error {
-	return nil
+	return it.err
 }
 
 func (it *HashGroupByIterator) Close() {
@@ -1234,7 +1650,7 @@ func (it *WindowIterator) Value() []types.Value {
 }
 
 func (it *WindowIterator) Err() error {
-	return nil
+	return it.child.Err()
 }
 
 func (it *WindowIterator) Close() {
@@ -1488,6 +1904,7 @@ type WindowFunctionIterator struct {
 	computedRows [][]types.Value          // Output rows with computed window values
 	index        int                      // Current row index
 	prepared     bool                     // Whether we've computed window values
+	err          error                    // Error of the input, if it stopped on one
 }
 
 func NewWindowFunctionIterator(
@@ -1505,6 +1922,7 @@ func NewWindowFunctionIterator(
 		copy(rowCopy, row)
 		inputRows = append(inputRows, rowCopy)
 	}
+	err := child.Err()
 	child.Close()
 
 	return &WindowFunctionIterator{
@@ -1515,13 +1933,20 @@ func NewWindowFunctionIterator(
 		inputRows:   inputRows,
 		index:       -1,
 		prepared:    false,
+		err:         err,
 	}
 }
 
 func (it *WindowFunctionIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
 	if !it.prepared {
 		it.computeWindowValues()
 		it.prepared = true
+		if it.err != nil {
+			return false
+		}
 	}
 
 	it.index++
@@ -1536,7 +1961,7 @@ func (it *WindowFunctionIterator) Value() []types.Value {
 }
 
 func (it *WindowFunctionIterator) Err() error {
-	return nil
+	return it.err
 }
 
 func (it *WindowFunctionIterator) Close() {
@@ -1654,6 +2079,10 @@ func (it *WindowFunctionIterator) computeSingleWindowFunction(
 	case "MAX":
 		it.computeAggregateWindowFunc(funcCall, wf.Over, sortedIndices, wfIdx, windowResults, "MAX")
 	default:
+		if agg, ok := optimizer.AggregateFromCall(funcCall); ok {
+			it.computeAggregateWindowFunc(funcCall, wf.Over, sortedIndices, wfIdx, windowResults, agg.FuncName)
+			return
+		}
 		// Unknown window function, set NULL
 		for i := range windowResults {
 			windowResults[i][wfIdx] = types.NewNull()
@@ -2115,7 +2544,13 @@ func (it *WindowFunctionIterator) computeAggregateWindowFunc(
 		frameStart, frameEnd := it.computeFrameBounds(spec, i, partStart, partEnd)
 
 		// Compute aggregate over frame
-		result := it.computeFrameAggregate(sortedIndices, frameStart, frameEnd, aggExpr, isCountStar, aggFunc)
+		var result types.Value
+		switch aggFunc {
+		case "SUM", "AVG", "COUNT", "MIN", "MAX":
+			result = it.computeFrameAggregate(sortedIndices, frameStart, frameEnd, aggExpr, isCountStar, aggFunc, funcCall.Filter)
+		default:
+			result = it.computeFrameAggregateFunc(funcCall, sortedIndices, frameStart, frameEnd)
+		}
 		windowResults[origIdx][wfIdx] = result
 	}
 }
@@ -2215,6 +2650,7 @@ func (it *WindowFunctionIterator) computeFrameAggregate(
 	aggExpr parser.Expression,
 	isCountStar bool,
 	aggFunc string,
+	filter parser.Expression,
 ) types.Value {
 	if frameStart > frameEnd {
 		// Empty frame
@@ -2236,6 +2672,13 @@ func (it *WindowFunctionIterator) computeFrameAggregate(
 		origIdx := sortedIndices[i]
 		row := it.inputRows[origIdx]
 
+		// FILTER (WHERE ...) leaves out rows where it is not true
+		if filter != nil {
+			if match, err := it.executor.evaluateCondition(filter, row, it.colMap); err != nil || !match {
+				continue
+			}
+		}
+
 		if isCountStar {
 			count++
 			continue
@@ -2302,3 +2745,38 @@ func (it *WindowFunctionIterator) computeFrameAggregate(
 		return types.NewNull()
 	}
 }
+
+// computeFrameAggregateFunc computes an aggregate other than SUM, AVG, COUNT,
+// MIN and MAX over a frame. An error stops the iterator and yields NULL.
+func (it *WindowFunctionIterator) computeFrameAggregateFunc(funcCall *parser.FunctionCall, sortedIndices []int, frameStart, frameEnd int) types.Value {
+	agg, _ := optimizer.AggregateFromCall(funcCall)
+	if err := checkAggregateArgs(agg); err != nil {
+		it.setErr(err)
+		return types.NewNull()
+	}
+
+	var rows [][]types.Value
+	for i := frameStart; i <= frameEnd; i++ {
+		if i >= 0 && i < len(sortedIndices) {
+			rows = append(rows, it.inputRows[sortedIndices[i]])
+		}
+	}
+	rows, err := it.executor.filterAggregateRows(agg.Filter, rows, it.colMap)
+	if err != nil {
+		it.setErr(err)
+		return types.NewNull()
+	}
+	result, err := it.executor.computeAggregate(agg, rows, it.colMap)
+	if err != nil {
+		it.setErr(err)
+		return types.NewNull()
+	}
+	return result
+}
+
+// setErr records the first error met while computing window values
+func (it *WindowFunctionIterator) setErr(err error) {
+	if it.err == nil {
+		it.err = err
+	}
+}
@@ -2,13 +2,17 @@ package executor
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"tur/pkg/dbfile"
+	"tur/pkg/hnsw"
 	"tur/pkg/schema"
 	"tur/pkg/sql/parser"
 	"tur/pkg/tree"
+	"tur/pkg/types"
 )
 
 // initSchemaBTree initializes or opens the schema metadata B-tree on page 1
@@ -26,6 +30,11 @@ func (e *Executor) initSchemaBTree() error {
 		return e.loadSchemaFromBTree()
 	}
 
+	// A read-only connection waits for the writer to create it
+	if e.pager.ReadOnly() {
+		return nil
+	}
+
 	// New database - create schema B-tree on page 1
 	schemaTree, err := e.treeFactory.CreateAtPage(1)
 	if err != nil {
@@ -35,6 +44,22 @@ func (e *Executor) initSchemaBTree() error {
 	return nil
 }
 
+// Reload rebuilds the catalog, trees and indexes from the schema B-tree, for
+// a read-only pager whose writer committed changes. It must not be called
+// during a transaction.
+func (e *Executor) Reload() error {
+	e.catalog = schema.NewCatalog()
+	e.trees = make(map[string]tree.ExtendedTree)
+	e.rowid = make(map[string]uint64)
+	e.maxRowid = make(map[string]int64)
+	e.hnswIndexes = nil
+	e.schemaBTree = nil
+	if e.queryCache != nil {
+		e.queryCache.InvalidateAll()
+	}
+	return e.initSchemaBTree()
+}
+
 // persistSchemaEntry writes a schema entry to the schema B-tree
 func (e *Executor) persistSchemaEntry(entry *dbfile.SchemaEntry) error {
 	if e.schemaBTree == nil {
@@ -118,20 +143,35 @@ func (e *Executor) syncTableRootPage(tableName string) error {
 	return nil
 }
 
-// syncIndexRootPage checks if an index's btree root page has changed and updates the schema
+// syncIndexRootPage checks if an index's btree root page (or HNSW meta page)
+// has changed and updates the schema
 func (e *Executor) syncIndexRootPage(indexName string, idx *schema.IndexDef) error {
-	idxTreeName := "idx_" + indexName
-	indexTree := e.trees[idxTreeName]
-	if indexTree == nil {
-		return nil // No tree to sync
+	var currentRootPage uint32
+	if idx.Type == schema.IndexTypeHNSW {
+		persistent, ok := e.hnswIndexes[indexName].(*hnsw.PersistentIndex)
+		if !ok {
+			return nil // In-memory index, nothing to sync
+		}
+		currentRootPage = persistent.MetaPage()
+	} else {
+		indexTree := e.trees["index:"+indexName]
+		if indexTree == nil {
+			return nil // No tree to sync
+		}
+		currentRootPage = indexTree.RootPage()
 	}
 
 	// Check if root page changed
-	currentRootPage := indexTree.RootPage()
 	if currentRootPage == idx.RootPage {
 		return nil // No change
 	}
 
+	// Indexes created implicitly for constraints have no schema entry
+	if _, err := e.schemaBTree.Get([]byte(indexName)); err == tree.ErrKeyNotFound {
+		idx.RootPage = currentRootPage
+		return nil
+	}
+
 	// Root page changed - update schema entry
 	entry, err := e.getSchemaEntry(indexName)
 	if err != nil {
@@ -156,8 +196,8 @@ func (e *Executor) syncIndexRootPage(indexName string, idx *schema.IndexDef) err
 func (e *Executor) syncAllRootPages() error {
 	// Sync all tables
 	for tableName := range e.trees {
-		// Skip index trees (they start with "idx_")
-		if len(tableName) > 4 && tableName[:4] == "idx_" {
+		// Skip index trees (they start with "index:")
+		if strings.HasPrefix(tableName, "index:") {
 			continue
 		}
 		if err := e.syncTableRootPage(tableName); err != nil {
@@ -179,36 +219,95 @@ func (e *Executor) syncAllRootPages() error {
 	return nil
 }
 
-// reconstructCreateTableSQL rebuilds CREATE TABLE SQL from parsed statement
-func reconstructCreateTableSQL(stmt *parser.CreateTableStmt) string {
+// reconstructCreateTableSQL rebuilds CREATE TABLE SQL from a table definition
+func reconstructCreateTableSQL(table *schema.TableDef) string {
 	var sb strings.Builder
 	sb.WriteString("CREATE TABLE ")
-	sb.WriteString(stmt.TableName)
+	sb.WriteString(table.Name)
 	sb.WriteString(" (")
 
-	for i, col := range stmt.Columns {
+	for i, col := range table.Columns {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
 		sb.WriteString(col.Name)
 		sb.WriteString(" ")
-		sb.WriteString(col.Type.String())
+		sb.WriteString(columnTypeSQL(col))
 
 		if col.PrimaryKey {
 			sb.WriteString(" PRIMARY KEY")
 		}
-		if col.NotNull {
+		if col.HasConstraint(schema.ConstraintNotNull) {
 			sb.WriteString(" NOT NULL")
 		}
-		if col.Unique {
+		if col.HasConstraint(schema.ConstraintUnique) {
 			sb.WriteString(" UNIQUE")
 		}
+		if def := col.GetConstraint(schema.ConstraintDefault); def != nil && def.DefaultValue != nil {
+			if literal, ok := valueToSQL(*def.DefaultValue); ok {
+				sb.WriteString(" DEFAULT ")
+				sb.WriteString(literal)
+			}
+		}
+		if col.NoNormalize {
+			sb.WriteString(" NONORMALIZE")
+		}
 	}
 
 	sb.WriteString(")")
 	return sb.String()
 }
 
+// columnTypeSQL renders a column type including its type parameters
+// (VECTOR dimension, VARCHAR/CHAR length, DECIMAL precision and scale)
+func columnTypeSQL(col schema.ColumnDef) string {
+	switch col.Type {
+	case types.TypeVector:
+		return fmt.Sprintf("VECTOR(%d)", col.VectorDim)
+	case types.TypeVarchar, types.TypeChar:
+		if col.MaxLength > 0 {
+			return fmt.Sprintf("%s(%d)", col.Type.String(), col.MaxLength)
+		}
+	case types.TypeDecimal:
+		return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+	}
+	return col.Type.String()
+}
+
+// valueToSQL renders a value as a SQL literal. ok is false for values that
+// have no literal, such as dates.
+func valueToSQL(v types.Value) (literal string, ok bool) {
+	switch v.Type() {
+	case types.TypeNull:
+		return "NULL", true
+	case types.TypeSmallInt, types.TypeInt32, types.TypeBigInt, types.TypeSerial, types.TypeBigSerial:
+		return strconv.FormatInt(v.Int(), 10), true
+	case types.TypeFloat:
+		literal = strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.Contains(literal, ".") {
+			literal += ".0"
+		}
+		return literal, true
+	case types.TypeText, types.TypeVarchar, types.TypeChar:
+		return quoteSQLString(v.Text()), true
+	case types.TypeJSON:
+		return quoteSQLString(v.JSON()), true
+	case types.TypeDecimal:
+		return quoteSQLString(v.DecimalString()), true
+	case types.TypeGUID:
+		return quoteSQLString(v.GUIDString()), true
+	case types.TypeBlob:
+		return "X'" + hex.EncodeToString(v.Blob()) + "'", true
+	default:
+		return "", false
+	}
+}
+
+// quoteSQLString renders s as a SQL string literal
+func quoteSQLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // reconstructCreateIndexSQL rebuilds CREATE INDEX SQL from parsed statement
 func reconstructCreateIndexSQL(stmt *parser.CreateIndexStmt) string {
 	var sb strings.Builder
@@ -226,6 +325,18 @@ func reconstructCreateIndexSQL(stmt *parser.CreateIndexStmt) string {
 	return sb.String()
 }
 
+// reconstructCreateHNSWIndexSQL builds CREATE INDEX ... USING HNSW SQL from an index
+// definition, spelling out every HNSW parameter
+func reconstructCreateHNSWIndexSQL(idx *schema.IndexDef) string {
+	params := idx.HNSWParams
+	if params == nil {
+		params = schema.DefaultHNSWParams()
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s USING HNSW (%s) WITH (m=%d, ef_construction=%d, metric='%s', heuristic=%t%s)",
+		idx.Name, idx.TableName, strings.Join(idx.Columns, ", "),
+		params.M, params.EfConstruction, params.DistanceMetric, params.UseHeuristic, params.QuantizationOptions())
+}
+
 // reconstructCreateViewSQL rebuilds CREATE VIEW SQL from parsed statement
 func reconstructCreateViewSQL(stmt *parser.CreateViewStmt, selectSQL string) string {
 	var sb strings.Builder
@@ -314,6 +425,12 @@ func insertStmtToSQL(stmt *parser.InsertStmt) string {
 		sb.WriteString(")")
 	}
 
+	if stmt.SelectStmt != nil {
+		sb.WriteString(" ")
+		sb.WriteString(selectStmtToSQL(stmt.SelectStmt))
+		return sb.String()
+	}
+
 	sb.WriteString(" VALUES ")
 	for i, row := range stmt.Values {
 		if i > 0 {
@@ -437,11 +554,15 @@ func (e *Executor) loadTableSchema(entry *dbfile.SchemaEntry) error {
 	columns := make([]schema.ColumnDef, len(createStmt.Columns))
 	for i, col := range createStmt.Columns {
 		columns[i] = schema.ColumnDef{
-			Name:       col.Name,
-			Type:       col.Type,
-			PrimaryKey: col.PrimaryKey,
-			NotNull:    col.NotNull,
-			VectorDim:  col.VectorDim,
+			Name:        col.Name,
+			Type:        col.Type,
+			PrimaryKey:  col.PrimaryKey,
+			NotNull:     col.NotNull,
+			VectorDim:   col.VectorDim,
+			NoNormalize: col.NoNormalize,
+			MaxLength:   col.MaxLength,
+			Precision:   col.Precision,
+			Scale:       col.Scale,
 		}
 
 		// Build constraints
@@ -455,6 +576,16 @@ func (e *Executor) loadTableSchema(entry *dbfile.SchemaEntry) error {
 		if col.Unique {
 			constraints = append(constraints, schema.Constraint{Type: schema.ConstraintUnique})
 		}
+		if col.DefaultExpr != nil {
+			defaultVal, err := e.evaluateExpr(col.DefaultExpr, nil, nil)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate DEFAULT of column %s: %w", col.Name, err)
+			}
+			constraints = append(constraints, schema.Constraint{
+				Type:         schema.ConstraintDefault,
+				DefaultValue: &defaultVal,
+			})
+		}
 		columns[i].Constraints = constraints
 	}
 
@@ -476,6 +607,9 @@ func (e *Executor) loadTableSchema(entry *dbfile.SchemaEntry) error {
 		return fmt.Errorf("failed to open table B-tree: %w", err)
 	}
 	e.trees[entry.Name] = tableTree
+	if e.pager.ReadOnly() {
+		return nil
+	}
 
 	// Scan B-tree to find the maximum rowid for proper ID continuation on inserts
 	maxRowid := uint64(0)
@@ -509,6 +643,11 @@ func (e *Executor) loadIndexSchema(entry *dbfile.SchemaEntry) error {
 		return fmt.Errorf("expected CREATE INDEX statement")
 	}
 
+	// HNSW indexes point at an HNSW meta page instead of a B-tree root
+	if e.isHNSWMetaPage(entry.RootPage) {
+		return e.loadHNSWIndexSchema(entry, createStmt.Columns)
+	}
+
 	// Reconstruct index definition
 	idx := &schema.IndexDef{
 		Name:      entry.Name,
@@ -0,0 +1,432 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"tur/pkg/hnsw"
+	"tur/pkg/mvcc"
+	"tur/pkg/pager"
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
+	"tur/pkg/tree"
+)
+
+// ErrNoWriteTransaction is returned when committing or rolling back a write
+// transaction that was never begun
+var ErrNoWriteTransaction = errors.New("no write transaction is active")
+
+// writeTx is the pager write transaction behind the current SQL transaction
+// or autocommit statement, together with the in-memory state of the executor
+// when it began. Rolling back the pager restores the pages; the snapshot
+// brings the catalog, trees and indexes back in line with them.
+type writeTx struct {
+	pagerTx *pager.Transaction
+	// owner is the SQL transaction holding the write transaction until it
+	// ends, nil for one statement
+	owner *mvcc.Transaction
+
+	catalog    *schema.CatalogSnapshot
+	trees      map[string]tree.ExtendedTree
+	roots      map[string]uint32
+	schemaRoot uint32
+	hnsw       map[string]hnsw.VectorIndex
+	hnswMeta   map[string]uint32
+	rowid      map[string]uint64
+	maxRowid   map[string]int64
+}
+
+// BeginWrite starts the pager write transaction that makes the statements
+// executed until CommitWrite or RollbackWrite atomic and durable. Statements
+// executed while it is active do not commit on their own.
+func (e *Executor) BeginWrite() error {
+	if e.writeTx != nil {
+		return pager.ErrTxAlreadyActive
+	}
+	pagerTx, err := e.pager.BeginWrite()
+	if err != nil {
+		return err
+	}
+
+	tx := &writeTx{
+		pagerTx:    pagerTx,
+		catalog:    e.catalog.Snapshot(),
+		trees:      make(map[string]tree.ExtendedTree, len(e.trees)),
+		roots:      make(map[string]uint32, len(e.trees)),
+		schemaRoot: e.schemaBTree.RootPage(),
+		hnsw:       make(map[string]hnsw.VectorIndex, len(e.hnswIndexes)),
+		hnswMeta:   make(map[string]uint32),
+		rowid:      make(map[string]uint64, len(e.rowid)),
+		maxRowid:   make(map[string]int64, len(e.maxRowid)),
+	}
+	for name, t := range e.trees {
+		tx.trees[name] = t
+		tx.roots[name] = t.RootPage()
+	}
+	for name, idx := range e.hnswIndexes {
+		tx.hnsw[name] = idx
+		if persistent, ok := idx.(*hnsw.PersistentIndex); ok {
+			tx.hnswMeta[name] = persistent.MetaPage()
+		}
+	}
+	for name, rowid := range e.rowid {
+		tx.rowid[name] = rowid
+	}
+	for name, maxRowid := range e.maxRowid {
+		tx.maxRowid[name] = maxRowid
+	}
+
+	e.writeTx = tx
+	return nil
+}
+
+// CommitWrite commits the write transaction: its pages reach the WAL, which is
+// synced, before the call returns. If it fails, the transaction is still
+// active and should be rolled back.
+func (e *Executor) CommitWrite() error {
+	if e.writeTx == nil {
+		return ErrNoWriteTransaction
+	}
+
+	// Root pages moved by splits must reach the schema in the same transaction
+	if err := e.syncAllRootPages(); err != nil {
+		return err
+	}
+	if err := e.writeTx.pagerTx.Commit(); err != nil {
+		return err
+	}
+	e.writeTx = nil
+	return nil
+}
+
+// rollbackWrite rolls the pager back and restores the executor state saved
+// by BeginWrite
+func (e *Executor) rollbackWrite() error {
+	tx := e.writeTx
+	e.writeTx = nil
+	tx.pagerTx.Rollback()
+
+	e.catalog.Restore(tx.catalog)
+	e.trees = tx.trees
+	for name, t := range e.trees {
+		if resettable, ok := t.(tree.TreeWithRootReset); ok {
+			resettable.ResetRootPage(tx.roots[name])
+		}
+	}
+	if resettable, ok := e.schemaBTree.(tree.TreeWithRootReset); ok {
+		resettable.ResetRootPage(tx.schemaRoot)
+	}
+	e.rowid = tx.rowid
+	e.maxRowid = tx.maxRowid
+
+	// Persistent HNSW indexes cache their graph layout, reload it from the
+	// restored pages
+	e.hnswIndexes = tx.hnsw
+	for name, metaPage := range tx.hnswMeta {
+		idx, err := hnsw.OpenPersistent(e.pager, metaPage)
+		if err != nil {
+			return fmt.Errorf("failed to reopen index %s: %w", name, err)
+		}
+		e.hnswIndexes[name] = idx
+	}
+
+	if e.queryCache != nil {
+		e.queryCache.InvalidateAll()
+	}
+	return nil
+}
+
+// InWriteTransaction reports whether a write transaction is active
+func (e *Executor) InWriteTransaction() bool {
+	return e.writeTx != nil
+}
+
+// executeStatement runs a statement in the active SQL transaction, or, if it
+// may modify the database, in a write transaction of its own (autocommit).
+// While SQL transactions are active, the autocommit statement is one too, so
+// that it leaves their snapshots alone.
+func (e *Executor) executeStatement(stmt parser.Statement) (*Result, error) {
+	if e.pager.ReadOnly() && !readsOnly(stmt) {
+		return nil, pager.ErrReadOnly
+	}
+	if tx := e.currentTx; tx != nil && tx.IsActive() {
+		return e.executeInTransaction(tx, stmt)
+	}
+	if !writesDatabase(stmt) {
+		return e.dispatch(stmt)
+	}
+
+	if err := e.BeginWrite(); err != nil {
+		return nil, err
+	}
+	var tx *mvcc.Transaction
+	if len(e.txManager.ActiveTransactions()) > 0 {
+		tx = e.txManager.Begin()
+		e.writeTx.owner = tx
+	}
+	result, err := e.withVersions(tx, func() (*Result, error) {
+		return e.dispatch(stmt)
+	})
+	if err == nil {
+		if tx != nil {
+			err = e.CommitTransaction(tx)
+		} else {
+			err = e.CommitWrite()
+		}
+	}
+	if err != nil {
+		var rbErr error
+		if tx != nil {
+			rbErr = e.RollbackTransaction(tx)
+		} else {
+			rbErr = e.rollbackWrite()
+		}
+		if rbErr != nil {
+			return nil, fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// writesDatabase reports whether a statement may modify the database.
+// Queries and transaction control never do; VACUUM and PRAGMA manage the
+// file outside of transactions.
+func writesDatabase(stmt parser.Statement) bool {
+	switch stmt.(type) {
+	case *parser.SelectStmt, *parser.SetOperation, *parser.ExplainStmt,
+		*parser.BeginStmt, *parser.CommitStmt, *parser.RollbackStmt,
+		*parser.SavepointStmt, *parser.RollbackToStmt, *parser.ReleaseStmt,
+		*parser.SetStmt, *parser.PragmaStmt, *parser.VacuumStmt:
+		return false
+	default:
+		return true
+	}
+}
+
+// readsOnly reports whether a statement can run on a read-only pager. PRAGMAs
+// that change the file fail in the pager.
+func readsOnly(stmt parser.Statement) bool {
+	switch s := stmt.(type) {
+	case *parser.VacuumStmt:
+		return false
+	case *parser.ExplainStmt:
+		return !s.Analyze || readsOnly(s.Statement)
+	default:
+		return !writesDatabase(stmt)
+	}
+}
+
+// ReadOnly reports whether a statement only reads the database and leaves
+// the executor as it is, so that it can run concurrently with other
+// statements for which ReadOnly is true. Every other statement needs the
+// executor to itself. Statements of the current SQL transaction are never
+// read-only, as they see the trees through its versions.
+func (e *Executor) ReadOnly(stmt parser.Statement) bool {
+	if tx := e.currentTx; tx != nil && tx.IsActive() {
+		return false
+	}
+	switch s := stmt.(type) {
+	case *parser.SelectStmt, *parser.SetOperation:
+		return true
+	case *parser.ExplainStmt:
+		return !s.Analyze || e.ReadOnly(s.Statement)
+	default:
+		return false
+	}
+}
+
+// BeginTransaction starts a SQL transaction on a snapshot of the database.
+// Statements executed with it as the current transaction (SetTransaction) see
+// the rows committed before it began and its own changes, which stay in the
+// snapshot store until CommitTransaction. Any number of them can be active.
+//
+// Statements that cannot be versioned, schema changes and writes to tables
+// with HNSW indexes, make the transaction take the write transaction until
+// it ends; their changes to the schema are not isolated.
+func (e *Executor) BeginTransaction() *mvcc.Transaction {
+	return e.txManager.Begin()
+}
+
+// CommitTransaction commits a SQL transaction. If another transaction that
+// committed after it began wrote one of the rows it wrote, it fails with a
+// *mvcc.WriteConflictError matching mvcc.ErrWriteConflict. On failure the
+// transaction is still active and should be rolled back.
+func (e *Executor) CommitTransaction(tx *mvcc.Transaction) error {
+	if err := e.versions.CheckConflicts(tx); err != nil {
+		return err
+	}
+
+	changes := e.versions.Changes(tx)
+	owned := e.writeTx != nil && e.writeTx.owner == tx
+	if owned || len(changes) > 0 {
+		began := false
+		if !owned {
+			if err := e.BeginWrite(); err != nil {
+				return err
+			}
+			e.writeTx.owner = tx
+			began = true
+		}
+		err := e.applyChanges(changes)
+		if err == nil {
+			err = e.CommitWrite()
+		}
+		if err != nil {
+			if began {
+				_ = e.rollbackWrite()
+			}
+			return err
+		}
+	}
+
+	if err := e.txManager.Commit(tx); err != nil {
+		return err
+	}
+	e.versions.Collect()
+	return nil
+}
+
+// RollbackTransaction discards the changes of a SQL transaction
+func (e *Executor) RollbackTransaction(tx *mvcc.Transaction) error {
+	var err error
+	if e.writeTx != nil && e.writeTx.owner == tx {
+		// Best effort, as for ROLLBACK: the pages are restored regardless.
+		// Trees kept in memory and HNSW indexes need the undo log.
+		_, _ = e.withVersions(tx, func() (*Result, error) {
+			return nil, e.applyUndoOperations(tx.UndoLog().GetAllOperations())
+		})
+		err = e.rollbackWrite()
+	}
+	e.versions.Discard(tx)
+	if rbErr := e.txManager.Rollback(tx); err == nil {
+		err = rbErr
+	}
+	e.versions.Collect()
+	return err
+}
+
+// applyChanges writes the changes a transaction kept in the snapshot store to
+// the trees
+func (e *Executor) applyChanges(changes []mvcc.SnapshotChange) error {
+	for _, c := range changes {
+		t := e.trees[c.Space]
+		if t == nil {
+			return fmt.Errorf("cannot commit changes to %s: it no longer exists", c.Space)
+		}
+		if c.Deleted {
+			if err := t.Delete(c.Key); err != nil && err != tree.ErrKeyNotFound {
+				return err
+			}
+		} else if err := t.Insert(c.Key, c.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeInTransaction runs a statement in a SQL transaction, taking the
+// write transaction first if the statement needs it
+func (e *Executor) executeInTransaction(tx *mvcc.Transaction, stmt parser.Statement) (*Result, error) {
+	switch stmt.(type) {
+	case *parser.BeginStmt, *parser.CommitStmt, *parser.RollbackStmt,
+		*parser.SavepointStmt, *parser.ReleaseStmt:
+		return e.dispatch(stmt)
+	}
+
+	if e.needsWriteLock(stmt) && (e.writeTx == nil || e.writeTx.owner != tx) {
+		if err := e.BeginWrite(); err != nil {
+			return nil, err
+		}
+		e.writeTx.owner = tx
+	}
+	if e.ctx != nil && writesDatabase(stmt) {
+		return e.executeCancelable(tx, stmt)
+	}
+	return e.withVersions(tx, func() (*Result, error) {
+		return e.dispatch(stmt)
+	})
+}
+
+// statementSavepoint names the savepoint that cancelable statements of SQL
+// transactions start with. No SQL identifier can name it.
+const statementSavepoint = "\x00statement"
+
+// executeCancelable runs a statement of a SQL transaction that its context
+// may stop. A stopped statement takes back its own changes and leaves the
+// transaction active, for the caller to go on with or roll back.
+func (e *Executor) executeCancelable(tx *mvcc.Transaction, stmt parser.Statement) (*Result, error) {
+	if _, err := e.executeSavepoint(&parser.SavepointStmt{Name: statementSavepoint}); err != nil {
+		return nil, err
+	}
+	result, err := e.withVersions(tx, func() (*Result, error) {
+		return e.dispatch(stmt)
+	})
+	if err != nil && e.ctx.Err() != nil {
+		if _, rbErr := e.executeRollbackTo(&parser.RollbackToStmt{Name: statementSavepoint}); rbErr != nil {
+			return nil, fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+	if tx.IsActive() {
+		if _, relErr := e.executeRelease(&parser.ReleaseStmt{Name: statementSavepoint}); relErr != nil && err == nil {
+			return nil, relErr
+		}
+	}
+	return result, err
+}
+
+// needsWriteLock reports whether a statement in a SQL transaction changes
+// the database in ways the snapshot store cannot version
+func (e *Executor) needsWriteLock(stmt parser.Statement) bool {
+	switch s := stmt.(type) {
+	case *parser.SelectStmt, *parser.SetOperation, *parser.ExplainStmt,
+		*parser.RollbackToStmt, *parser.SetStmt, *parser.IfStmt,
+		*parser.PragmaStmt, *parser.VacuumStmt:
+		return false
+	case *parser.InsertStmt:
+		return e.hasHNSWIndex(s.TableName)
+	case *parser.UpdateStmt:
+		return e.hasHNSWIndex(s.TableName)
+	case *parser.DeleteStmt:
+		return e.hasHNSWIndex(s.TableName)
+	default:
+		return true
+	}
+}
+
+// hasHNSWIndex reports whether a table has an HNSW index, whose graph is
+// changed in place by writes
+func (e *Executor) hasHNSWIndex(tableName string) bool {
+	for _, idx := range e.catalog.GetIndexesForTable(tableName) {
+		if idx.Type == schema.IndexTypeHNSW {
+			return true
+		}
+	}
+	return false
+}
+
+// withVersions runs fn with every tree seen through the versions of tx, if
+// not nil. Trees that fn creates, drops or renames are kept as it leaves them.
+func (e *Executor) withVersions(tx *mvcc.Transaction, fn func() (*Result, error)) (*Result, error) {
+	if tx == nil {
+		return fn()
+	}
+	direct := e.writeTx != nil && e.writeTx.owner == tx
+	views := make(map[string]tree.ExtendedTree, len(e.trees))
+	for name, t := range e.trees {
+		views[name] = &versionedTree{ExtendedTree: t, name: name, store: e.versions, tx: tx, direct: direct}
+	}
+	e.trees = views
+	defer func() {
+		trees := make(map[string]tree.ExtendedTree, len(e.trees))
+		for name, t := range e.trees {
+			if view, ok := t.(*versionedTree); ok {
+				t = view.ExtendedTree
+			}
+			trees[name] = t
+		}
+		e.trees = trees
+	}()
+	return fn()
+}
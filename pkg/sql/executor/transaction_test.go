@@ -0,0 +1,382 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tur/pkg/mvcc"
+	"tur/pkg/pager"
+)
+
+// copyDBFile copies a database or WAL file, simulating the state left by a crash
+func copyDBFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", dst, err)
+	}
+}
+
+// countTxRows returns the number of rows in a table
+func countTxRows(t *testing.T, exec *Executor, table string) int64 {
+	t.Helper()
+	result, err := exec.Execute("SELECT COUNT(*) FROM " + table)
+	if err != nil {
+		t.Fatalf("count on %s failed: %v", table, err)
+	}
+	return result.Rows[0][0].Int()
+}
+
+func TestTransaction_CommitIsDurable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	p, err := pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	if _, err := exec.Execute("CREATE TABLE items (id INT PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := exec.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	// Enough rows to split the root page
+	for i := 1; i <= 300; i++ {
+		sql := fmt.Sprintf("INSERT INTO items VALUES (%d, '%s')", i, strings.Repeat("n", 100))
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+
+	// The database file as a crash would leave it: nothing of the
+	// transaction was written back
+	crashDir := t.TempDir()
+	crashPath := filepath.Join(crashDir, "crash.db")
+	copyDBFile(t, path, crashPath)
+
+	if _, err := exec.Execute("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	copyDBFile(t, path+"-wal", crashPath+"-wal")
+
+	cp, err := pager.Open(crashPath, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open crash image: %v", err)
+	}
+	recovered := New(cp)
+	defer recovered.Close()
+	if got := countTxRows(t, recovered, "items"); got != 300 {
+		t.Errorf("expected 300 rows after recovery, got %d", got)
+	}
+	result, err := recovered.Execute("SELECT name FROM items WHERE id = 250")
+	if err != nil || len(result.Rows) != 1 {
+		t.Fatalf("lookup after recovery: %v, %v", result, err)
+	}
+}
+
+func TestTransaction_RollbackRestoresDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	p, err := pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+
+	stmts := []string{
+		"CREATE TABLE items (id INT PRIMARY KEY, name TEXT)",
+		"CREATE INDEX idx_items_name ON items (name)",
+		"INSERT INTO items VALUES (1, 'one')",
+		"INSERT INTO items VALUES (2, 'two')",
+	}
+	for _, sql := range stmts {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	pageCount := p.PageCount()
+
+	if _, err := exec.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	for i := 3; i <= 300; i++ {
+		sql := fmt.Sprintf("INSERT INTO items VALUES (%d, 'name %s')", i, strings.Repeat("n", 100))
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("insert %d failed: %v", i, err)
+		}
+	}
+	if _, err := exec.Execute("UPDATE items SET name = 'changed' WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	if _, err := exec.Execute("CREATE TABLE extra (id INT)"); err != nil {
+		t.Fatalf("CREATE TABLE in transaction failed: %v", err)
+	}
+	if _, err := exec.Execute("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+
+	check := func(exec *Executor) {
+		t.Helper()
+		if got := countTxRows(t, exec, "items"); got != 2 {
+			t.Errorf("expected 2 rows after rollback, got %d", got)
+		}
+		result, err := exec.Execute("SELECT id FROM items WHERE name = 'one'")
+		if err != nil || len(result.Rows) != 1 {
+			t.Errorf("expected row 1 through the index, got %v, %v", result, err)
+		}
+		if exec.GetCatalog().GetTable("extra") != nil {
+			t.Error("table created in the rolled back transaction still exists")
+		}
+	}
+	check(exec)
+	if got := p.PageCount(); got != pageCount {
+		t.Errorf("page count %d after rollback, want %d", got, pageCount)
+	}
+
+	// The database keeps working, and the rollback survives a reopen
+	if _, err := exec.Execute("INSERT INTO items VALUES (3, 'three')"); err != nil {
+		t.Fatalf("insert after rollback failed: %v", err)
+	}
+	if _, err := exec.Execute("DELETE FROM items WHERE id = 3"); err != nil {
+		t.Fatalf("delete after rollback failed: %v", err)
+	}
+	if err := exec.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	p, err = pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	exec = New(p)
+	defer exec.Close()
+	check(exec)
+}
+
+func TestTransaction_FailedStatementIsAtomic(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	if _, err := exec.Execute("CREATE TABLE items (id INT PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := exec.Execute("INSERT INTO items VALUES (5, 'five')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	// The third row collides with an existing key: none of the rows stay
+	if _, err := exec.Execute("INSERT INTO items VALUES (1, 'a'), (2, 'b'), (5, 'c')"); err == nil {
+		t.Fatal("expected the duplicate key to fail the insert")
+	}
+	if exec.InWriteTransaction() {
+		t.Error("autocommit statement left a write transaction open")
+	}
+	if got := countTxRows(t, exec, "items"); got != 1 {
+		t.Errorf("expected 1 row after the failed insert, got %d", got)
+	}
+}
+
+func TestTransaction_BeginTwice(t *testing.T) {
+	storage, err := pager.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("NewMemoryStorage: %v", err)
+	}
+	p, err := pager.OpenWithStorage(storage, pager.Options{})
+	if err != nil {
+		t.Fatalf("OpenWithStorage: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	if _, err := exec.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if !exec.InWriteTransaction() {
+		t.Error("expected BEGIN to start a write transaction")
+	}
+	if _, err := exec.Execute("BEGIN"); err == nil {
+		t.Error("expected a nested BEGIN to fail")
+	}
+	if _, err := exec.Execute("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	if exec.InWriteTransaction() {
+		t.Error("expected COMMIT to end the write transaction")
+	}
+}
+
+// execIn executes a statement in a SQL transaction begun with BeginTransaction,
+// or in the current one if tx is nil
+func execIn(t *testing.T, exec *Executor, tx *mvcc.Transaction, sql string) *Result {
+	t.Helper()
+	if tx != nil {
+		prev := exec.GetTransaction()
+		exec.SetTransaction(tx)
+		defer exec.SetTransaction(prev)
+	}
+	result, err := exec.Execute(sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return result
+}
+
+// intColumn returns the first column of the rows of a result
+func intColumn(result *Result) []int64 {
+	ids := make([]int64, len(result.Rows))
+	for i, row := range result.Rows {
+		ids[i] = row[0].Int()
+	}
+	return ids
+}
+
+func TestTransaction_SnapshotsSeeOwnChanges(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE items (id INT PRIMARY KEY, name TEXT)",
+		"CREATE INDEX idx_items_name ON items (name)",
+		"INSERT INTO items VALUES (2, 'b'), (4, 'd'), (6, 'f')",
+	} {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	writer := exec.BeginTransaction()
+	reader := exec.BeginTransaction()
+	execIn(t, exec, writer, "INSERT INTO items VALUES (1, 'a'), (5, 'e'), (7, 'g')")
+	execIn(t, exec, writer, "DELETE FROM items WHERE id = 4")
+	execIn(t, exec, writer, "UPDATE items SET name = 'bb' WHERE id = 2")
+
+	// Scans in both directions merge the tree with the versions
+	got := intColumn(execIn(t, exec, writer, "SELECT id FROM items ORDER BY id DESC"))
+	if fmt.Sprint(got) != "[7 6 5 2 1]" {
+		t.Errorf("writer sees ids %v", got)
+	}
+	got = intColumn(execIn(t, exec, reader, "SELECT id FROM items ORDER BY id"))
+	if fmt.Sprint(got) != "[2 4 6]" {
+		t.Errorf("reader sees ids %v", got)
+	}
+
+	// So do index lookups
+	if got := execIn(t, exec, writer, "SELECT id FROM items WHERE name = 'e'"); fmt.Sprint(intColumn(got)) != "[5]" {
+		t.Errorf("writer finds %v through the index", intColumn(got))
+	}
+	if got := execIn(t, exec, writer, "SELECT id FROM items WHERE name = 'b'"); len(got.Rows) != 0 {
+		t.Errorf("writer finds the old name through the index: %v", intColumn(got))
+	}
+	if got := execIn(t, exec, reader, "SELECT id FROM items WHERE name = 'b'"); fmt.Sprint(intColumn(got)) != "[2]" {
+		t.Errorf("reader finds %v through the index", intColumn(got))
+	}
+	if got := execIn(t, exec, reader, "SELECT id FROM items WHERE name = 'e'"); len(got.Rows) != 0 {
+		t.Errorf("reader finds an uncommitted row through the index: %v", intColumn(got))
+	}
+
+	if err := exec.CommitTransaction(writer); err != nil {
+		t.Fatalf("CommitTransaction failed: %v", err)
+	}
+	// The reader still needs the old versions
+	if exec.versions.Collect() == 0 {
+		t.Error("expected versions to be kept for the reader")
+	}
+	got = intColumn(execIn(t, exec, reader, "SELECT id FROM items ORDER BY id"))
+	if fmt.Sprint(got) != "[2 4 6]" {
+		t.Errorf("reader sees ids %v after the commit", got)
+	}
+	if err := exec.RollbackTransaction(reader); err != nil {
+		t.Fatalf("RollbackTransaction failed: %v", err)
+	}
+	if n := exec.versions.Collect(); n != 0 {
+		t.Errorf("expected every version to be collected, %d keys left", n)
+	}
+	if n := len(exec.txManager.ActiveTransactions()); n != 0 {
+		t.Errorf("expected no active transactions, got %d", n)
+	}
+
+	got = intColumn(execIn(t, exec, nil, "SELECT id FROM items ORDER BY id"))
+	if fmt.Sprint(got) != "[1 2 5 6 7]" {
+		t.Errorf("committed ids %v", got)
+	}
+	if got := execIn(t, exec, nil, "SELECT id FROM items WHERE name = 'bb'"); fmt.Sprint(intColumn(got)) != "[2]" {
+		t.Errorf("committed index finds %v", intColumn(got))
+	}
+}
+
+func TestTransaction_LockingTransactionsKeepSnapshots(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "test.db"), pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE items (id INT PRIMARY KEY, name TEXT)",
+		"INSERT INTO items VALUES (1, 'a'), (2, 'b')",
+	} {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	// BEGIN writes to the trees as it goes, and keeps the old versions for
+	// the snapshot
+	reader := exec.BeginTransaction()
+	for _, sql := range []string{"BEGIN", "UPDATE items SET name = 'z' WHERE id = 1", "INSERT INTO items VALUES (3, 'c')"} {
+		execIn(t, exec, nil, sql)
+	}
+	if got := execIn(t, exec, reader, "SELECT name FROM items WHERE id = 1"); got.Rows[0][0].Text() != "a" {
+		t.Errorf("reader sees name %v during BEGIN", got.Rows[0][0])
+	}
+
+	// Other transactions cannot change the schema while BEGIN holds the
+	// write transaction
+	blocked := exec.BeginTransaction()
+	sqlTx := exec.GetTransaction()
+	exec.SetTransaction(blocked)
+	if _, err := exec.Execute("CREATE TABLE other (id INT)"); err != pager.ErrTxAlreadyActive {
+		t.Errorf("expected the schema change to wait for BEGIN, got %v", err)
+	}
+	exec.SetTransaction(sqlTx)
+	if err := exec.RollbackTransaction(blocked); err != nil {
+		t.Fatalf("RollbackTransaction failed: %v", err)
+	}
+
+	// The reader's update of the same row conflicts
+	execIn(t, exec, reader, "UPDATE items SET name = 'y' WHERE id = 1")
+	execIn(t, exec, nil, "COMMIT")
+	if got := intColumn(execIn(t, exec, reader, "SELECT id FROM items ORDER BY id")); fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("reader sees ids %v after COMMIT", got)
+	}
+	if err := exec.CommitTransaction(reader); !errors.Is(err, mvcc.ErrWriteConflict) {
+		t.Fatalf("expected a write conflict, got %v", err)
+	}
+	if err := exec.RollbackTransaction(reader); err != nil {
+		t.Fatalf("RollbackTransaction failed: %v", err)
+	}
+	if n := exec.versions.Collect(); n != 0 {
+		t.Errorf("expected every version to be collected, %d keys left", n)
+	}
+	if got := execIn(t, exec, nil, "SELECT name FROM items WHERE id = 1"); got.Rows[0][0].Text() != "z" {
+		t.Errorf("expected the committed name, got %v", got.Rows[0][0])
+	}
+}
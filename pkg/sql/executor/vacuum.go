@@ -0,0 +1,404 @@
+// pkg/sql/executor/vacuum.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"tur/pkg/dbfile"
+	"tur/pkg/hnsw"
+	"tur/pkg/pager"
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
+	"tur/pkg/tree"
+	"tur/pkg/types"
+)
+
+// executeVacuum rebuilds the database into a new file that holds only live
+// pages and swaps it in. VACUUM INTO writes that copy to another file instead
+// and leaves the database unchanged.
+func (e *Executor) executeVacuum(stmt *parser.VacuumStmt) (*Result, error) {
+	if e.currentTx != nil || e.writeTx != nil {
+		return nil, fmt.Errorf("cannot VACUUM from within a transaction")
+	}
+	if stmt.Into != "" {
+		return e.vacuumInto(stmt.Into)
+	}
+
+	// Build the copy next to the database file, or in memory
+	var dst *pager.Pager
+	var path string
+	var err error
+	if e.pager.IsInMemory() {
+		var storage *pager.MemoryStorage
+		if storage, err = pager.NewMemoryStorage(int64(e.pager.PageSize())); err != nil {
+			return nil, err
+		}
+		dst, err = pager.OpenWithStorage(storage, pager.Options{PageSize: e.pager.PageSize()})
+	} else {
+		path = e.pager.Path() + "-vacuum"
+		removeDatabaseFiles(path)
+		dst, err = pager.Open(path, pager.Options{PageSize: e.pager.PageSize()})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vacuum target: %w", err)
+	}
+
+	roots, err := e.vacuumCopy(dst)
+	if err != nil {
+		dst.Close()
+		removeDatabaseFiles(path)
+		return nil, err
+	}
+	if err := e.pager.Replace(dst); err != nil {
+		removeDatabaseFiles(path)
+		return nil, fmt.Errorf("failed to replace database with its vacuumed copy: %w", err)
+	}
+	if err := e.reopenAfterVacuum(roots); err != nil {
+		return nil, err
+	}
+
+	return &Result{RowsAffected: 0}, nil
+}
+
+// vacuumInto writes a compacted copy of the database to a new file at path
+func (e *Executor) vacuumInto(path string) (*Result, error) {
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		return nil, fmt.Errorf("output file already exists: %s", path)
+	}
+
+	dst, err := pager.Open(path, pager.Options{PageSize: e.pager.PageSize()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := e.vacuumCopy(dst); err != nil {
+		dst.Close()
+		removeDatabaseFiles(path)
+		return nil, err
+	}
+	if err := dst.Close(); err != nil {
+		return nil, err
+	}
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+
+	return &Result{RowsAffected: 0}, nil
+}
+
+// removeDatabaseFiles removes a database file, its WAL and its shared WAL
+// index, if path is not empty
+func removeDatabaseFiles(path string) {
+	if path == "" {
+		return
+	}
+	os.Remove(path)
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+}
+
+// vacuumCopy writes every table, index and schema entry into dst, an empty
+// database, packed from the start of the file. It returns the new root page of
+// each table and index (the meta page for HNSW indexes), keyed by table name
+// or by "index:" and the index name, as in e.trees.
+func (e *Executor) vacuumCopy(dst *pager.Pager) (map[string]uint32, error) {
+	if err := e.syncAllRootPages(); err != nil {
+		return nil, err
+	}
+	if err := dst.SetAutoVacuum(e.pager.AutoVacuum()); err != nil {
+		return nil, err
+	}
+
+	factory := tree.NewFactory(dst, e.treeFactory.TreeType())
+	schemaTree, err := factory.CreateAtPage(1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema B-tree: %w", err)
+	}
+	written := []tree.ExtendedTree{schemaTree}
+	roots := make(map[string]uint32)
+
+	copyTree := func(name string, src tree.ExtendedTree) error {
+		t, err := factory.Create()
+		if err != nil {
+			return err
+		}
+		cursor := src.Cursor()
+		defer cursor.Close()
+		for cursor.First(); cursor.Valid(); cursor.Next() {
+			if err := t.Insert(cursor.Key(), cursor.Value()); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", name, err)
+			}
+		}
+		// Splits may have moved the root
+		roots[name] = t.RootPage()
+		written = append(written, t)
+		return nil
+	}
+
+	for _, tableName := range e.catalog.ListTables() {
+		if src := e.GetTableTree(tableName); src != nil {
+			if err := copyTree(tableName, src); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, idx := range e.catalog.GetIndexesForTable(tableName) {
+			name := "index:" + idx.Name
+			if idx.Type == schema.IndexTypeHNSW {
+				persistent, ok := e.hnswIndexes[idx.Name].(*hnsw.PersistentIndex)
+				if !ok {
+					continue
+				}
+				copied, err := persistent.CopyTo(dst)
+				if err != nil {
+					return nil, fmt.Errorf("failed to copy index %s: %w", idx.Name, err)
+				}
+				roots[name] = copied.MetaPage()
+				continue
+			}
+			if src := e.trees[name]; src != nil {
+				if err := copyTree(name, src); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Copy the schema entries, pointing them at the new roots
+	cursor := e.schemaBTree.Cursor()
+	defer cursor.Close()
+	for cursor.First(); cursor.Valid(); cursor.Next() {
+		value := cursor.Value()
+		if value == nil {
+			continue
+		}
+		entry, err := dbfile.DecodeSchemaEntry(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode schema entry: %w", err)
+		}
+
+		name := entry.Name
+		if entry.Type == dbfile.SchemaEntryIndex {
+			name = "index:" + entry.Name
+		}
+		if entry.Type == dbfile.SchemaEntryTable || entry.Type == dbfile.SchemaEntryIndex {
+			root, ok := roots[name]
+			if !ok {
+				return nil, fmt.Errorf("cannot VACUUM: no data found for %s", entry.Name)
+			}
+			entry.RootPage = root
+		}
+		if err := schemaTree.Insert([]byte(entry.Name), entry.Encode()); err != nil {
+			return nil, fmt.Errorf("failed to copy schema entry %s: %w", entry.Name, err)
+		}
+	}
+
+	// CoW trees only reach their pages on checkpoint
+	for _, t := range written {
+		if cp, ok := t.(interface{ Checkpoint() error }); ok {
+			if err := cp.Checkpoint(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return roots, dst.Sync()
+}
+
+// reopenAfterVacuum reopens the schema, every tree and every HNSW index at the
+// roots they were given in the vacuumed file
+func (e *Executor) reopenAfterVacuum(roots map[string]uint32) error {
+	schemaTree, err := e.treeFactory.Open(1)
+	if err != nil {
+		return fmt.Errorf("failed to open schema B-tree: %w", err)
+	}
+	e.schemaBTree = schemaTree
+
+	trees := make(map[string]tree.ExtendedTree, len(roots))
+	for _, tableName := range e.catalog.ListTables() {
+		if root, ok := roots[tableName]; ok {
+			t, err := e.treeFactory.Open(root)
+			if err != nil {
+				return fmt.Errorf("failed to open table %s: %w", tableName, err)
+			}
+			trees[tableName] = t
+			e.catalog.GetTable(tableName).RootPage = root
+		}
+
+		for _, idx := range e.catalog.GetIndexesForTable(tableName) {
+			name := "index:" + idx.Name
+			root, ok := roots[name]
+			if !ok {
+				continue
+			}
+			idx.RootPage = root
+
+			if idx.Type == schema.IndexTypeHNSW {
+				persistent, err := hnsw.OpenPersistent(e.pager, root)
+				if err != nil {
+					return fmt.Errorf("failed to open index %s: %w", idx.Name, err)
+				}
+				e.hnswIndexes[idx.Name] = persistent
+				continue
+			}
+			t, err := e.treeFactory.Open(root)
+			if err != nil {
+				return fmt.Errorf("failed to open index %s: %w", idx.Name, err)
+			}
+			trees[name] = t
+		}
+	}
+	e.trees = trees
+	return nil
+}
+
+// incrementalVacuum removes up to n free pages (all of them when n <= 0) from
+// the end of the file, moving pages still in use into free pages nearer the
+// start. Returns the number of pages removed.
+func (e *Executor) incrementalVacuum(n int) (int, error) {
+	owners, err := e.pageOwners()
+	if err != nil {
+		return 0, err
+	}
+
+	move := func(from, to uint32) error {
+		relocate, ok := owners[from]
+		if !ok {
+			return pager.ErrPageNotMovable
+		}
+		if err := relocate(from, to); err != nil {
+			return err
+		}
+		delete(owners, from)
+		owners[to] = relocate
+		return nil
+	}
+	removed, err := e.pager.IncrementalVacuum(n, move)
+
+	// Moved roots and HNSW meta pages must reach the schema
+	if syncErr := e.syncAllRootPages(); err == nil {
+		err = syncErr
+	}
+	return removed, err
+}
+
+// pageOwners maps every page used by the schema, a table, an index or an
+// HNSW index to the function that relocates it. Pages of trees that cannot
+// relocate their pages are left out, so incremental vacuum stops at them.
+func (e *Executor) pageOwners() (map[uint32]func(from, to uint32) error, error) {
+	owners := make(map[uint32]func(from, to uint32) error)
+	claim := func(name string, pages []uint32, relocate func(from, to uint32) error) error {
+		for _, pageNo := range pages {
+			if _, ok := owners[pageNo]; ok {
+				return fmt.Errorf("page %d of %s is also used by another object", pageNo, name)
+			}
+			owners[pageNo] = relocate
+		}
+		return nil
+	}
+	claimTree := func(name string, t tree.ExtendedTree) error {
+		r, ok := t.(tree.TreeWithRelocation)
+		if !ok {
+			return nil
+		}
+		return claim(name, t.CollectPages(), r.RelocatePage)
+	}
+
+	if err := claimTree("the schema", e.schemaBTree); err != nil {
+		return nil, err
+	}
+	for _, tableName := range e.catalog.ListTables() {
+		if t := e.GetTableTree(tableName); t != nil {
+			if err := claimTree(tableName, t); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, idx := range e.catalog.GetIndexesForTable(tableName) {
+			if idx.Type == schema.IndexTypeHNSW {
+				persistent, ok := e.hnswIndexes[idx.Name].(*hnsw.PersistentIndex)
+				if !ok {
+					continue
+				}
+				if err := claim(idx.Name, persistent.CollectPages(), persistent.RelocatePage); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if t := e.trees["index:"+idx.Name]; t != nil {
+				if err := claimTree(idx.Name, t); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return owners, nil
+}
+
+// pragmaAutoVacuum gets or sets the auto-vacuum mode (PRAGMA auto_vacuum).
+// Unlike SQLite, the mode can be changed at any time, since moving pages does
+// not depend on anything recorded while they were written.
+func (e *Executor) pragmaAutoVacuum(stmt *parser.PragmaStmt) (*Result, error) {
+	if stmt.Value == nil {
+		return &Result{
+			Columns: []string{"auto_vacuum"},
+			Rows: [][]types.Value{
+				{types.NewInt(int64(e.pager.AutoVacuum()))},
+			},
+		}, nil
+	}
+
+	val, err := e.evaluateExpr(stmt.Value, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auto_vacuum value: %w", err)
+	}
+
+	var mode pager.AutoVacuumMode
+	switch {
+	case val.Type() == types.TypeText && strings.EqualFold(val.Text(), "none"):
+		mode = pager.AutoVacuumNone
+	case val.Type() == types.TypeText && strings.EqualFold(val.Text(), "incremental"):
+		mode = pager.AutoVacuumIncremental
+	case types.IsIntegerType(val.Type()) && val.Int() == int64(pager.AutoVacuumNone):
+		mode = pager.AutoVacuumNone
+	case types.IsIntegerType(val.Type()) && val.Int() == int64(pager.AutoVacuumIncremental):
+		mode = pager.AutoVacuumIncremental
+	case (val.Type() == types.TypeText && strings.EqualFold(val.Text(), "full")) ||
+		(types.IsIntegerType(val.Type()) && val.Int() == 1):
+		return nil, fmt.Errorf("auto_vacuum = full is not supported, use incremental")
+	default:
+		return nil, fmt.Errorf("auto_vacuum must be NONE (0) or INCREMENTAL (2), got %v", val)
+	}
+
+	if err := e.pager.SetAutoVacuum(mode); err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: 0}, nil
+}
+
+// pragmaIncrementalVacuum runs PRAGMA incremental_vacuum[(N)], which frees up
+// to N pages, or all free pages without N. It does nothing unless auto_vacuum
+// is incremental. RowsAffected is the number of pages removed from the file.
+func (e *Executor) pragmaIncrementalVacuum(stmt *parser.PragmaStmt) (*Result, error) {
+	n := 0
+	if stmt.Value != nil {
+		val, err := e.evaluateExpr(stmt.Value, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid incremental_vacuum value: %w", err)
+		}
+		if !types.IsIntegerType(val.Type()) {
+			return nil, fmt.Errorf("incremental_vacuum page count must be an integer, got %v", val)
+		}
+		n = int(val.Int())
+	}
+
+	if e.pager.AutoVacuum() != pager.AutoVacuumIncremental {
+		return &Result{RowsAffected: 0}, nil
+	}
+
+	removed, err := e.incrementalVacuum(n)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: int64(removed)}, nil
+}
@@ -0,0 +1,324 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tur/pkg/pager"
+)
+
+// fillVacuumTable creates a table with a secondary index and an HNSW index,
+// inserts n rows and deletes all but every tenth, leaving many free pages
+func fillVacuumTable(t *testing.T, exec *Executor, n int) {
+	t.Helper()
+	stmts := []string{
+		"CREATE TABLE docs (id INT PRIMARY KEY, title TEXT, body TEXT, embedding VECTOR(3))",
+		"CREATE INDEX idx_docs_title ON docs (title)",
+		"CREATE VIEW doc_titles AS SELECT id, title FROM docs",
+	}
+	for _, sql := range stmts {
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	for i := 1; i <= n; i++ {
+		sql := fmt.Sprintf("INSERT INTO docs VALUES (%d, 'title %05d', '%s', x'%s')",
+			i, i, strings.Repeat("x", 200), vectorToHex([]float32{float32(i), 1, 0}))
+		if _, err := exec.Execute(sql); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+	if _, err := exec.Execute("CREATE INDEX emb_idx ON docs USING HNSW (embedding)"); err != nil {
+		t.Fatalf("CREATE INDEX USING HNSW failed: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if i%10 == 0 {
+			continue
+		}
+		if _, err := exec.Execute(fmt.Sprintf("DELETE FROM docs WHERE id = %d", i)); err != nil {
+			t.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+}
+
+// checkVacuumTable verifies the rows left by fillVacuumTable through the
+// table, the secondary index, the view and the HNSW index
+func checkVacuumTable(t *testing.T, exec *Executor, n int) {
+	t.Helper()
+	result, err := exec.Execute("SELECT COUNT(*) FROM docs")
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if got := result.Rows[0][0].Int(); got != int64(n/10) {
+		t.Errorf("expected %d rows, got %d", n/10, got)
+	}
+
+	result, err = exec.Execute("SELECT id FROM docs WHERE title = 'title 00050'")
+	if err != nil {
+		t.Fatalf("index lookup failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Int() != 50 {
+		t.Errorf("expected row 50 by title, got %v", result.Rows)
+	}
+
+	result, err = exec.Execute("SELECT COUNT(*) FROM doc_titles")
+	if err != nil {
+		t.Fatalf("view query failed: %v", err)
+	}
+	if got := result.Rows[0][0].Int(); got != int64(n/10) {
+		t.Errorf("expected %d rows through the view, got %d", n/10, got)
+	}
+
+	result, err = exec.Execute(fmt.Sprintf("SELECT * FROM vector_quantize_scan('docs', 'embedding', x'%s', 1)", vectorToHex([]float32{70, 1, 0})))
+	if err != nil {
+		t.Fatalf("vector_quantize_scan failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Int() != 70 {
+		t.Errorf("expected rowid 70 from the HNSW index, got %v", result.Rows)
+	}
+}
+
+func TestVacuum_ShrinksFileAndKeepsData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	p, err := pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+
+	const n = 1000
+	fillVacuumTable(t, exec, n)
+	before := p.PageCount()
+	if p.FreePageCount() == 0 {
+		t.Fatal("expected free pages after the delete")
+	}
+	infoBefore, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+
+	if _, err := exec.Execute("VACUUM"); err != nil {
+		t.Fatalf("VACUUM failed: %v", err)
+	}
+	if got := p.PageCount(); got >= before/2 {
+		t.Errorf("expected VACUUM to at least halve the file, %d -> %d pages", before, got)
+	}
+	if got := p.FreePageCount(); got != 0 {
+		t.Errorf("expected no free pages after VACUUM, got %d", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() >= infoBefore.Size()/2 {
+		t.Errorf("expected the file to shrink from %d bytes: %v, %v", infoBefore.Size(), info, err)
+	}
+	if _, err := os.Stat(path + "-vacuum"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary vacuum file to be gone, stat returned %v", err)
+	}
+	checkVacuumTable(t, exec, n)
+
+	// The database keeps working, and everything survives a reopen
+	if _, err := exec.Execute(fmt.Sprintf("INSERT INTO docs VALUES (5001, 'new', 'body', x'%s')", vectorToHex([]float32{1, 2, 3}))); err != nil {
+		t.Fatalf("insert after VACUUM failed: %v", err)
+	}
+	if err := exec.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	p, err = pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	exec = New(p)
+	defer exec.Close()
+	if _, err := exec.Execute("DELETE FROM docs WHERE id = 5001"); err != nil {
+		t.Fatalf("delete after reopen failed: %v", err)
+	}
+	checkVacuumTable(t, exec, n)
+}
+
+func TestVacuum_CowTree(t *testing.T) {
+	exec, cleanup := setupTestExecutorWithCowTree(t)
+	defer cleanup()
+
+	const n = 300
+	fillVacuumTable(t, exec, n)
+	if _, err := exec.Execute("VACUUM"); err != nil {
+		t.Fatalf("VACUUM failed: %v", err)
+	}
+	checkVacuumTable(t, exec, n)
+}
+
+func TestVacuum_InMemory(t *testing.T) {
+	storage, err := pager.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("NewMemoryStorage: %v", err)
+	}
+	p, err := pager.OpenWithStorage(storage, pager.Options{})
+	if err != nil {
+		t.Fatalf("OpenWithStorage: %v", err)
+	}
+	exec := New(p)
+	defer exec.Close()
+
+	const n = 300
+	fillVacuumTable(t, exec, n)
+	before := p.PageCount()
+	if _, err := exec.Execute("VACUUM"); err != nil {
+		t.Fatalf("VACUUM failed: %v", err)
+	}
+	if p.PageCount() >= before {
+		t.Errorf("expected VACUUM to shrink the database, %d -> %d pages", before, p.PageCount())
+	}
+	checkVacuumTable(t, exec, n)
+}
+
+func TestVacuum_Into(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	const n = 500
+	fillVacuumTable(t, exec, n)
+	before := exec.pager.PageCount()
+
+	target := filepath.Join(t.TempDir(), "backup.db")
+	if _, err := exec.Execute(fmt.Sprintf("VACUUM INTO '%s'", target)); err != nil {
+		t.Fatalf("VACUUM INTO failed: %v", err)
+	}
+
+	// The source is untouched
+	if exec.pager.PageCount() != before {
+		t.Errorf("VACUUM INTO changed the source from %d to %d pages", before, exec.pager.PageCount())
+	}
+	checkVacuumTable(t, exec, n)
+
+	// The copy is compact and opens as a database of its own
+	p, err := pager.Open(target, pager.Options{})
+	if err != nil {
+		t.Fatalf("failed to open the copy: %v", err)
+	}
+	copied := New(p)
+	defer copied.Close()
+	if p.PageCount() >= before || p.FreePageCount() != 0 {
+		t.Errorf("expected a compact copy, got %d pages (%d free) from %d", p.PageCount(), p.FreePageCount(), before)
+	}
+	checkVacuumTable(t, copied, n)
+
+	// An existing database is never overwritten
+	if _, err := exec.Execute(fmt.Sprintf("VACUUM INTO '%s'", target)); err == nil {
+		t.Error("expected VACUUM INTO an existing file to fail")
+	}
+}
+
+func TestVacuum_NotInTransaction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if _, err := exec.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := exec.Execute("VACUUM"); err == nil || !strings.Contains(err.Error(), "transaction") {
+		t.Errorf("expected VACUUM inside a transaction to fail, got %v", err)
+	}
+	if _, err := exec.Execute("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+}
+
+func TestIncrementalVacuum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	p, err := pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("pager.Open: %v", err)
+	}
+	exec := New(p)
+
+	result, err := exec.Execute("PRAGMA auto_vacuum")
+	if err != nil || result.Rows[0][0].Int() != 0 {
+		t.Fatalf("expected auto_vacuum 0 by default, got %v, %v", result, err)
+	}
+
+	const n = 1000
+	fillVacuumTable(t, exec, n)
+	free := p.FreePageCount()
+
+	// Without auto_vacuum = incremental the pragma does nothing
+	result, err = exec.Execute("PRAGMA incremental_vacuum")
+	if err != nil || result.RowsAffected != 0 || p.FreePageCount() != free {
+		t.Fatalf("expected incremental_vacuum to do nothing, got %v, %v", result, err)
+	}
+
+	if _, err := exec.Execute("PRAGMA auto_vacuum = incremental"); err != nil {
+		t.Fatalf("setting auto_vacuum failed: %v", err)
+	}
+	before := p.PageCount()
+	result, err = exec.Execute("PRAGMA incremental_vacuum(10)")
+	if err != nil {
+		t.Fatalf("incremental_vacuum(10) failed: %v", err)
+	}
+	if result.RowsAffected != 10 || p.PageCount() != before-10 || p.FreePageCount() != free-10 {
+		t.Errorf("expected 10 pages removed, got %d (%d -> %d pages, %d free)",
+			result.RowsAffected, before, p.PageCount(), p.FreePageCount())
+	}
+	checkVacuumTable(t, exec, n)
+
+	result, err = exec.Execute("PRAGMA incremental_vacuum")
+	if err != nil {
+		t.Fatalf("incremental_vacuum failed: %v", err)
+	}
+	if p.FreePageCount() != 0 || result.RowsAffected != int64(free-10) {
+		t.Errorf("expected all %d remaining free pages removed, got %d (%d still free)",
+			free-10, result.RowsAffected, p.FreePageCount())
+	}
+	checkVacuumTable(t, exec, n)
+	if err := exec.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(before-uint32(free))*int64(p.PageSize()) {
+		t.Errorf("expected the file truncated to %d pages: %v", before-uint32(free), err)
+	}
+
+	// The mode and the data survive a reopen
+	p, err = pager.Open(path, pager.Options{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	exec = New(p)
+	defer exec.Close()
+	result, err = exec.Execute("PRAGMA auto_vacuum")
+	if err != nil || result.Rows[0][0].Int() != 2 {
+		t.Errorf("expected auto_vacuum 2 after reopen, got %v, %v", result, err)
+	}
+	checkVacuumTable(t, exec, n)
+}
+
+func TestPragmaAutoVacuum_Values(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, tt := range []struct {
+		sql  string
+		want int64
+	}{
+		{"PRAGMA auto_vacuum = 2", 2},
+		{"PRAGMA auto_vacuum = NONE", 0},
+		{"PRAGMA auto_vacuum = 'incremental'", 2},
+		{"PRAGMA auto_vacuum = 0", 0},
+	} {
+		if _, err := exec.Execute(tt.sql); err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := int64(exec.pager.AutoVacuum()); got != tt.want {
+			t.Errorf("%s: auto_vacuum = %d, want %d", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{"PRAGMA auto_vacuum = full", "PRAGMA auto_vacuum = 1", "PRAGMA auto_vacuum = 7"} {
+		if _, err := exec.Execute(sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
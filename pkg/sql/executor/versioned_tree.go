@@ -0,0 +1,236 @@
+package executor
+
+import (
+	"bytes"
+	"sort"
+
+	"tur/pkg/mvcc"
+	"tur/pkg/tree"
+)
+
+// versionedTree is a table or index tree as seen by a SQL transaction: the
+// versions the snapshot store keeps for its keys take precedence over the
+// tree, which holds the latest committed values. Writes become versions of
+// the transaction; direct writes also go to the tree, which the transaction
+// may only do while it holds the write transaction.
+type versionedTree struct {
+	tree.ExtendedTree
+	name   string
+	store  *mvcc.SnapshotStore
+	tx     *mvcc.Transaction
+	direct bool
+}
+
+// Get returns the value of a key in the transaction's snapshot
+func (t *versionedTree) Get(key []byte) ([]byte, error) {
+	if value, deleted, ok := t.store.Lookup(t.tx, t.name, key); ok {
+		if deleted {
+			return nil, tree.ErrKeyNotFound
+		}
+		return value, nil
+	}
+	return t.ExtendedTree.Get(key)
+}
+
+// Insert inserts or updates a key in the transaction
+func (t *versionedTree) Insert(key, value []byte) error {
+	if err := t.track(key); err != nil {
+		return err
+	}
+	if t.direct {
+		if err := t.ExtendedTree.Insert(key, value); err != nil {
+			return err
+		}
+	}
+	t.store.Put(t.tx, t.name, key, value, !t.direct)
+	return nil
+}
+
+// Delete removes a key in the transaction
+func (t *versionedTree) Delete(key []byte) error {
+	if _, err := t.Get(key); err != nil {
+		return err
+	}
+	if err := t.track(key); err != nil {
+		return err
+	}
+	// A key the transaction inserted without writing the tree is not in it
+	if t.direct {
+		if err := t.ExtendedTree.Delete(key); err != nil && err != tree.ErrKeyNotFound {
+			return err
+		}
+	}
+	t.store.Delete(t.tx, t.name, key, !t.direct)
+	return nil
+}
+
+// track has the store version a key before its first write, with the value
+// every active transaction sees in the tree
+func (t *versionedTree) track(key []byte) error {
+	if t.store.Tracked(t.name, key) {
+		return nil
+	}
+	value, err := t.ExtendedTree.Get(key)
+	switch err {
+	case nil:
+		t.store.Track(t.name, key, value, true)
+	case tree.ErrKeyNotFound:
+		t.store.Track(t.name, key, nil, false)
+	default:
+		return err
+	}
+	return nil
+}
+
+// Cursor iterates over the transaction's snapshot of the tree
+func (t *versionedTree) Cursor() tree.Cursor {
+	entries, tracked := t.store.Scan(t.tx, t.name)
+	if len(tracked) == 0 {
+		return t.ExtendedTree.Cursor()
+	}
+	return &versionedCursor{base: t.ExtendedTree.Cursor(), entries: entries, tracked: tracked}
+}
+
+// versionedCursor merges a tree cursor, minus the keys the store versions,
+// with the versioned keys that exist for the transaction
+type versionedCursor struct {
+	base    tree.Cursor
+	entries []mvcc.SnapshotEntry
+	tracked map[string]struct{}
+
+	// i is the next entry in the direction of the cursor
+	i        int
+	forward  bool
+	fromBase bool
+	valid    bool
+	key      []byte
+	value    []byte
+}
+
+func (c *versionedCursor) First() {
+	c.base.First()
+	c.skipForward()
+	c.i = 0
+	c.forward = true
+	c.pick()
+}
+
+func (c *versionedCursor) Last() {
+	c.base.Last()
+	c.skipBackward()
+	c.i = len(c.entries) - 1
+	c.forward = false
+	c.pick()
+}
+
+func (c *versionedCursor) Seek(key []byte) {
+	c.base.Seek(key)
+	c.skipForward()
+	c.i = c.search(key)
+	c.forward = true
+	c.pick()
+}
+
+func (c *versionedCursor) Next() {
+	if !c.valid {
+		return
+	}
+	if !c.forward {
+		// Both sides move past the current key
+		key := append([]byte(nil), c.key...)
+		c.base.Seek(key)
+		if c.base.Valid() && bytes.Equal(c.base.Key(), key) {
+			c.base.Next()
+		}
+		c.skipForward()
+		c.i = c.search(key)
+		if c.i < len(c.entries) && bytes.Equal(c.entries[c.i].Key, key) {
+			c.i++
+		}
+		c.forward = true
+	} else if c.fromBase {
+		c.base.Next()
+		c.skipForward()
+	} else {
+		c.i++
+	}
+	c.pick()
+}
+
+func (c *versionedCursor) Prev() {
+	if !c.valid {
+		return
+	}
+	if c.forward {
+		// Both sides move before the current key
+		key := append([]byte(nil), c.key...)
+		c.base.Seek(key)
+		if c.base.Valid() {
+			c.base.Prev()
+		} else {
+			c.base.Last()
+		}
+		c.skipBackward()
+		c.i = c.search(key) - 1
+		c.forward = false
+	} else if c.fromBase {
+		c.base.Prev()
+		c.skipBackward()
+	} else {
+		c.i--
+	}
+	c.pick()
+}
+
+func (c *versionedCursor) Valid() bool   { return c.valid }
+func (c *versionedCursor) Key() []byte   { return c.key }
+func (c *versionedCursor) Value() []byte { return c.value }
+func (c *versionedCursor) Close()        { c.base.Close() }
+
+// search returns the index of the first entry at or after key
+func (c *versionedCursor) search(key []byte) int {
+	return sort.Search(len(c.entries), func(i int) bool {
+		return bytes.Compare(c.entries[i].Key, key) >= 0
+	})
+}
+
+// skipForward and skipBackward move the tree cursor off versioned keys
+func (c *versionedCursor) skipForward() {
+	for c.base.Valid() && c.isTracked(c.base.Key()) {
+		c.base.Next()
+	}
+}
+
+func (c *versionedCursor) skipBackward() {
+	for c.base.Valid() && c.isTracked(c.base.Key()) {
+		c.base.Prev()
+	}
+}
+
+func (c *versionedCursor) isTracked(key []byte) bool {
+	_, ok := c.tracked[string(key)]
+	return ok
+}
+
+// pick positions the cursor on the nearer of the tree key and the next entry
+func (c *versionedCursor) pick() {
+	hasEntry := c.i >= 0 && c.i < len(c.entries)
+	switch {
+	case c.base.Valid() && hasEntry:
+		cmp := bytes.Compare(c.base.Key(), c.entries[c.i].Key)
+		c.fromBase = (cmp < 0) == c.forward
+	case c.base.Valid():
+		c.fromBase = true
+	case hasEntry:
+		c.fromBase = false
+	default:
+		c.valid, c.key, c.value = false, nil, nil
+		return
+	}
+	c.valid = true
+	if c.fromBase {
+		c.key, c.value = c.base.Key(), c.base.Value()
+	} else {
+		c.key, c.value = c.entries[c.i].Key, c.entries[c.i].Value
+	}
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"tur/pkg/mvcc"
+	"tur/pkg/pager"
+	"tur/pkg/tree"
+)
+
+// cursorKeys walks a cursor from start with step and returns the first byte
+// of each key it visits
+func cursorKeys(c tree.Cursor, start, step func()) []byte {
+	var keys []byte
+	for start(); c.Valid(); step() {
+		keys = append(keys, c.Key()[0])
+	}
+	return keys
+}
+
+func TestVersionedTreeCursor(t *testing.T) {
+	storage, err := pager.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("NewMemoryStorage: %v", err)
+	}
+	p, err := pager.OpenWithStorage(storage, pager.Options{})
+	if err != nil {
+		t.Fatalf("OpenWithStorage: %v", err)
+	}
+	defer p.Close()
+	base, err := tree.NewFactory(p, tree.TreeTypeClassic).Create()
+	if err != nil {
+		t.Fatalf("failed to create tree: %v", err)
+	}
+	for _, k := range []byte{2, 4, 6, 8} {
+		if err := base.Insert([]byte{k}, []byte{k}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	mgr := mvcc.NewTransactionManager()
+	store := mvcc.NewSnapshotStore(mgr)
+	tx := mgr.Begin()
+	view := &versionedTree{ExtendedTree: base, name: "t", store: store, tx: tx}
+	for _, k := range []byte{1, 5, 9} {
+		if err := view.Insert([]byte{k}, []byte{k * 10}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := view.Delete([]byte{4}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := view.Insert([]byte{6}, []byte{60}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := view.Delete([]byte{3}); err != tree.ErrKeyNotFound {
+		t.Errorf("expected deleting a missing key to fail, got %v", err)
+	}
+
+	c := view.Cursor()
+	defer c.Close()
+	if got := cursorKeys(c, c.First, c.Next); fmt.Sprint(got) != "[1 2 5 6 8 9]" {
+		t.Errorf("forward scan: %v", got)
+	}
+	if got := cursorKeys(c, c.Last, c.Prev); fmt.Sprint(got) != "[9 8 6 5 2 1]" {
+		t.Errorf("backward scan: %v", got)
+	}
+	c.Seek([]byte{3})
+	if !c.Valid() || c.Key()[0] != 5 || c.Value()[0] != 50 {
+		t.Fatalf("Seek(3) landed on %v", c.Key())
+	}
+	c.Seek([]byte{6})
+	if c.Value()[0] != 60 {
+		t.Errorf("expected the updated value, got %d", c.Value()[0])
+	}
+
+	// Turning around steps to the neighbours of the current key
+	var got []byte
+	c.Seek([]byte{5})
+	for _, step := range []func(){c.Prev, c.Next, c.Next, c.Prev, c.Prev, c.Prev} {
+		step()
+		if !c.Valid() {
+			break
+		}
+		got = append(got, c.Key()[0])
+	}
+	if fmt.Sprint(got) != "[2 5 6 5 2 1]" {
+		t.Errorf("direction changes visited %v", got)
+	}
+
+	// Another transaction sees the tree alone
+	other := &versionedTree{ExtendedTree: base, name: "t", store: store, tx: mgr.Begin()}
+	oc := other.Cursor()
+	defer oc.Close()
+	if got := cursorKeys(oc, oc.First, oc.Next); fmt.Sprint(got) != "[2 4 6 8]" {
+		t.Errorf("other transaction scans %v", got)
+	}
+	if value, err := other.Get([]byte{6}); err != nil || value[0] != 6 {
+		t.Errorf("other transaction reads %v, %v", value, err)
+	}
+}
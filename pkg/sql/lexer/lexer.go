@@ -66,7 +66,12 @@ func (l *Lexer) NextToken() Token {
 	case '/':
 		tok = l.newToken(SLASH, "/")
 	case '=':
-		tok = l.newToken(EQ, "=")
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = Token{Type: FAT_ARROW, Literal: "=>", Pos: tok.Pos}
+		} else {
+			tok = l.newToken(EQ, "=")
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -109,6 +114,8 @@ func (l *Lexer) NextToken() Token {
 		tok = l.newToken(RBRACKET, "]")
 	case '?':
 		tok = l.newToken(QUESTION, "?")
+	case '$':
+		tok = l.newToken(DOLLAR, "$")
 	case '.':
 		if isDigit(l.peekChar()) {
 			tok.Literal = l.readNumber()
@@ -62,6 +62,34 @@ func TestLexer_ComparisonOperators(t *testing.T) {
 	}
 }
 
+func TestLexer_FatArrow(t *testing.T) {
+	input := "ef => 200, a=>b"
+	expected := []struct {
+		typ     TokenType
+		literal string
+	}{
+		{IDENT, "ef"},
+		{FAT_ARROW, "=>"},
+		{INT, "200"},
+		{COMMA, ","},
+		{IDENT, "a"},
+		{FAT_ARROW, "=>"},
+		{IDENT, "b"},
+		{EOF, ""},
+	}
+
+	l := New(input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Type != exp.typ {
+			t.Errorf("token[%d]: type = %v, want %v", i, tok.Type, exp.typ)
+		}
+		if tok.Literal != exp.literal {
+			t.Errorf("token[%d]: literal = %q, want %q", i, tok.Literal, exp.literal)
+		}
+	}
+}
+
 func TestLexer_Keywords(t *testing.T) {
 	input := "SELECT FROM WHERE INSERT INTO VALUES CREATE TABLE PRIMARY KEY NOT NULL AND OR"
 	expected := []struct {
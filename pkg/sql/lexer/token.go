@@ -86,6 +86,7 @@ const (
 
 	// Index keywords
 	INDEX
+	USING
 
 	// Conditional keywords
 	IF
@@ -118,6 +119,10 @@ const (
 	AS_KW   // AS for aliases
 	LIKE_KW // LIKE for pattern matching
 
+	// Predicate keywords
+	IS_KW // IS [NOT] NULL, IS [NOT] DISTINCT FROM
+	CAST
+
 	// Transaction keywords
 	BEGIN
 	COMMIT
@@ -129,6 +134,7 @@ const (
 	INTERSECT
 	EXCEPT
 	ALL
+	DISTINCT
 
 	// CTE keywords
 	WITH
@@ -217,9 +223,15 @@ const (
 	LBRACKET      // [ for array access
 	RBRACKET      // ] for array access
 
+	FAT_ARROW // => for named function arguments
+	DOLLAR    // $ for numbered parameters ($1)
+
 	// PRAGMA keyword
 	PRAGMA
 
+	// VACUUM keyword
+	VACUUM
+
 	// Strict data type keywords
 	SMALLINT_TYPE
 	BIGINT_TYPE
@@ -375,6 +387,8 @@ func (t TokenType) String() string {
 		return "CONSTRAINT"
 	case INDEX:
 		return "INDEX"
+	case USING:
+		return "USING"
 	case IF:
 		return "IF"
 	case EXISTS:
@@ -413,6 +427,10 @@ func (t TokenType) String() string {
 		return "AS"
 	case LIKE_KW:
 		return "LIKE"
+	case IS_KW:
+		return "IS"
+	case CAST:
+		return "CAST"
 	case BEGIN:
 		return "BEGIN"
 	case COMMIT:
@@ -429,6 +447,8 @@ func (t TokenType) String() string {
 		return "EXCEPT"
 	case ALL:
 		return "ALL"
+	case DISTINCT:
+		return "DISTINCT"
 	case WITH:
 		return "WITH"
 	case RECURSIVE:
@@ -547,8 +567,14 @@ func (t TokenType) String() string {
 		return "["
 	case RBRACKET:
 		return "]"
+	case FAT_ARROW:
+		return "=>"
+	case DOLLAR:
+		return "$"
 	case PRAGMA:
 		return "PRAGMA"
+	case VACUUM:
+		return "VACUUM"
 	case SMALLINT_TYPE:
 		return "SMALLINT"
 	case BIGINT_TYPE:
@@ -619,6 +645,7 @@ var keywords = map[string]TokenType{
 	"ACTION":      ACTION,
 	"CONSTRAINT":  CONSTRAINT,
 	"INDEX":       INDEX,
+	"USING":       USING,
 	"IF":          IF,
 	"EXISTS":      EXISTS,
 	"JOIN":        JOIN,
@@ -638,10 +665,13 @@ var keywords = map[string]TokenType{
 	"INTERSECT":   INTERSECT,
 	"EXCEPT":      EXCEPT,
 	"ALL":         ALL,
+	"DISTINCT":    DISTINCT,
 	"HAVING":      HAVING,
 	"IN":          IN_KW,
 	"AS":          AS_KW,
 	"LIKE":        LIKE_KW,
+	"IS":          IS_KW,
+	"CAST":        CAST,
 	"BEGIN":       BEGIN,
 	"COMMIT":      COMMIT,
 	"ROLLBACK":    ROLLBACK,
@@ -701,6 +731,7 @@ var keywords = map[string]TokenType{
 	"SQLSTATE":     SQLSTATE,
 	"JSON":         JSON_TYPE_KW,
 	"PRAGMA":       PRAGMA,
+	"VACUUM":       VACUUM,
 	"SMALLINT":     SMALLINT_TYPE,
 	"BIGINT":       BIGINT_TYPE,
 	"SERIAL":       SERIAL_TYPE,
@@ -2,6 +2,7 @@ package optimizer
 
 import (
 	"fmt"
+	"strings"
 	"tur/pkg/schema"
 	"tur/pkg/sql/parser"
 )
@@ -44,28 +45,109 @@ func BuildPlanWithCTEs(stmt *parser.SelectStmt, catalog *schema.Catalog, ctes ma
 	}
 
 	// 3. Apply GROUP BY with aggregations
-	// Also use AggregateNode when there are aggregate functions without GROUP BY (e.g., SELECT COUNT(*) FROM t)
-	aggregates := extractAggregates(stmt.Columns)
-	hasAggregates := len(aggregates) > 0
+	// Also use AggregateNode when there are aggregate functions without GROUP BY (e.g., SELECT COUNT(*) FROM t).
+	// It computes the aggregates wherever they are nested in the select list,
+	// DISTINCT ON and ORDER BY, which then run over its output; the select
+	// list is projected from it last.
+	hasStar := false
+	for _, col := range stmt.Columns {
+		if col.Star {
+			hasStar = true
+		}
+	}
+	lifter := newAggregateLifter(stmt.GroupBy)
+	var aggProjection *ProjectionNode
+	if !hasStar {
+		aggProjection = &ProjectionNode{}
+		for _, col := range stmt.Columns {
+			expr, err := lifter.lift(col.Expr)
+			if err != nil {
+				return nil, err
+			}
+			aggProjection.Expressions = append(aggProjection.Expressions, expr)
+			aggProjection.Aliases = append(aggProjection.Aliases, aggregatedColumnName(col))
+		}
+	}
+	aggregated := len(stmt.GroupBy) > 0 || len(lifter.aggregates) > 0
+	if aggregated && aggProjection != nil && len(lifter.aggregates) == 0 {
+		// Groups selected without aggregates come with the COUNT(*) of their rows
+		lifter.aggregates = append(lifter.aggregates, AggregateExpr{FuncName: "COUNT", Name: "COUNT(*)"})
+		aggProjection.Expressions = append(aggProjection.Expressions, &parser.ColumnRef{Name: "COUNT(*)"})
+		aggProjection.Aliases = append(aggProjection.Aliases, "COUNT(*)")
+	}
+
+	// DISTINCT ON keeps the first row of each group in ORDER BY order. Both run
+	// before the projection, which may drop the columns they use.
+	orderBy := stmt.OrderBy
+	var distinctOn []parser.Expression
+	if len(stmt.DistinctOn) > 0 {
+		distinctOn, orderBy, err = resolveDistinctOn(stmt)
+		if err != nil {
+			return nil, err
+		}
+	} else if aggregated {
+		orderBy = resolveOrderByAliases(stmt.OrderBy, stmt.Columns)
+	}
+
+	if aggregated {
+		if lifter.window != nil {
+			return nil, fmt.Errorf("window functions are not supported in a query with GROUP BY or aggregates")
+		}
+		if distinctOn, err = lifter.liftAll(distinctOn); err != nil {
+			return nil, err
+		}
+		lifted := make([]parser.OrderByExpr, len(orderBy))
+		for i, ob := range orderBy {
+			lifted[i] = ob
+			if lifted[i].Expr, err = lifter.lift(ob.Expr); err != nil {
+				return nil, err
+			}
+		}
+		orderBy = lifted
 
-	if len(stmt.GroupBy) > 0 || hasAggregates {
 		node = &AggregateNode{
 			Input:      node,
 			GroupBy:    stmt.GroupBy,
-			Aggregates: aggregates,
+			GroupNames: lifter.groupNames,
+			Aggregates: lifter.aggregates,
 			Having:     stmt.Having,
 		}
 	}
 
+	if len(distinctOn) > 0 {
+		if len(orderBy) > 0 {
+			node = &SortNode{
+				Input:   node,
+				OrderBy: orderBy,
+			}
+		}
+		node = &DistinctNode{
+			Input: node,
+			On:    distinctOn,
+		}
+		orderBy = nil
+	}
+
 	// 4. Apply Projection (Select columns) or Window Functions
-	// Skip projection when GROUP BY or aggregates are present - AggregateNode handles column output
 	// Check if SELECT *
 	isStar := false
 	if len(stmt.Columns) == 1 && stmt.Columns[0].Star {
 		isStar = true
 	}
 
-	if !isStar && len(stmt.GroupBy) == 0 && !hasAggregates {
+	if aggregated {
+		if len(orderBy) > 0 {
+			node = &SortNode{
+				Input:   node,
+				OrderBy: orderBy,
+			}
+			orderBy = nil
+		}
+		if aggProjection != nil {
+			aggProjection.Input = node
+			node = aggProjection
+		}
+	} else if !isStar {
 		var exprs []parser.Expression
 		var aliases []string
 		var windowFuncs []*parser.WindowFunction
@@ -99,11 +181,16 @@ func BuildPlanWithCTEs(stmt *parser.SelectStmt, catalog *schema.Catalog, ctes ma
 		}
 	}
 
+	// SELECT DISTINCT drops duplicates of the projected rows
+	if stmt.Distinct {
+		node = &DistinctNode{Input: node}
+	}
+
 	// 5. Apply ORDER BY (Sort)
-	if len(stmt.OrderBy) > 0 {
+	if len(orderBy) > 0 {
 		node = &SortNode{
 			Input:   node,
-			OrderBy: stmt.OrderBy,
+			OrderBy: orderBy,
 		}
 	}
 
@@ -119,37 +206,320 @@ func BuildPlanWithCTEs(stmt *parser.SelectStmt, catalog *schema.Catalog, ctes ma
 	return node, nil
 }
 
-// extractAggregates extracts aggregate function expressions from SELECT columns
-func extractAggregates(columns []parser.SelectColumn) []AggregateExpr {
-	var aggregates []AggregateExpr
+// aggregateLifter rewrites the expressions of an aggregated query to be
+// evaluated over the output of its AggregateNode: aggregate calls become
+// references to the columns holding their values, which are collected in
+// aggregates, and grouped expressions references to the group columns
+type aggregateLifter struct {
+	groupBy    []parser.Expression
+	groupNames []string
+	aggregates []AggregateExpr
+	window     *parser.WindowFunction // a window function met, left as it is
+}
 
-	// For now, we detect aggregate functions by name pattern matching
-	// In a full implementation, the parser would create proper FunctionCall nodes
-	aggregateFuncs := map[string]bool{
-		"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+// newAggregateLifter returns a lifter for the groups of groupBy. A group
+// column is named after the column it groups by, others by position.
+func newAggregateLifter(groupBy []parser.Expression) *aggregateLifter {
+	l := &aggregateLifter{groupBy: groupBy, groupNames: make([]string, len(groupBy))}
+	for i, expr := range groupBy {
+		if ref, ok := expr.(*parser.ColumnRef); ok {
+			l.groupNames[i] = ref.Name
+		} else {
+			l.groupNames[i] = fmt.Sprintf("GROUP#%d", i+1)
+		}
 	}
+	return l
+}
 
-	for _, col := range columns {
-		if col.Star {
-			continue
+// lift returns expr rewritten over the output of the AggregateNode. The
+// aggregates of subqueries belong to them and are left in place. Aggregates
+// nested in the arguments of another one are an error.
+func (l *aggregateLifter) lift(expr parser.Expression) (parser.Expression, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	for i, group := range l.groupBy {
+		if groupedBy(expr, group) {
+			return &parser.ColumnRef{Name: l.groupNames[i]}, nil
 		}
+	}
 
-		// Check if the column expression is a function call
-		if funcCall, ok := col.Expr.(*parser.FunctionCall); ok {
-			if aggregateFuncs[funcCall.Name] {
-				var arg parser.Expression
-				if len(funcCall.Args) > 0 {
-					arg = funcCall.Args[0]
+	switch ex := expr.(type) {
+	case *parser.FunctionCall:
+		if agg, ok := AggregateFromCall(ex); ok {
+			nested := append([]parser.Expression{agg.Filter}, agg.Args...)
+			for _, ob := range agg.OrderBy {
+				nested = append(nested, ob.Expr)
+			}
+			for _, arg := range nested {
+				if containsAggregate(arg) {
+					return nil, fmt.Errorf("aggregate function calls cannot be nested in %s", agg.FuncName)
 				}
-				aggregates = append(aggregates, AggregateExpr{
-					FuncName: funcCall.Name,
-					Arg:      arg,
-				})
 			}
+			agg.Name = fmt.Sprintf("%s#%d", agg.FuncName, len(l.aggregates)+1)
+			l.aggregates = append(l.aggregates, agg)
+			return &parser.ColumnRef{Name: agg.Name}, nil
+		}
+		args, err := l.liftAll(ex.Args)
+		if err != nil {
+			return nil, err
+		}
+		call := *ex
+		call.Args = args
+		return &call, nil
+	case *parser.BinaryExpr:
+		left, err := l.lift(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := l.lift(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.BinaryExpr{Left: left, Op: ex.Op, Right: right}, nil
+	case *parser.UnaryExpr:
+		right, err := l.lift(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.UnaryExpr{Op: ex.Op, Right: right}, nil
+	case *parser.IsNullExpr:
+		inner, err := l.lift(ex.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.IsNullExpr{Expr: inner, Not: ex.Not}, nil
+	case *parser.IsDistinctExpr:
+		left, err := l.lift(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := l.lift(ex.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.IsDistinctExpr{Left: left, Not: ex.Not, Right: right}, nil
+	case *parser.BetweenExpr:
+		bounds, err := l.liftAll([]parser.Expression{ex.Expr, ex.Low, ex.High})
+		if err != nil {
+			return nil, err
+		}
+		return &parser.BetweenExpr{Expr: bounds[0], Not: ex.Not, Low: bounds[1], High: bounds[2]}, nil
+	case *parser.CastExpr:
+		inner, err := l.lift(ex.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.CastExpr{Expr: inner, Type: ex.Type}, nil
+	case *parser.LikeExpr:
+		left, err := l.lift(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := l.lift(ex.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.LikeExpr{Left: left, Not: ex.Not, Pattern: pattern}, nil
+	case *parser.InExpr:
+		left, err := l.lift(ex.Left)
+		if err != nil {
+			return nil, err
+		}
+		values, err := l.liftAll(ex.Values)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.InExpr{Left: left, Not: ex.Not, Values: values, Subquery: ex.Subquery}, nil
+	case *parser.CaseExpr:
+		operand, err := l.lift(ex.Operand)
+		if err != nil {
+			return nil, err
+		}
+		elseExpr, err := l.lift(ex.Else)
+		if err != nil {
+			return nil, err
+		}
+		whens := make([]*parser.WhenClause, len(ex.Whens))
+		for i, when := range ex.Whens {
+			parts, err := l.liftAll([]parser.Expression{when.Condition, when.Then})
+			if err != nil {
+				return nil, err
+			}
+			whens[i] = &parser.WhenClause{Condition: parts[0], Then: parts[1]}
+		}
+		return &parser.CaseExpr{Operand: operand, Whens: whens, Else: elseExpr}, nil
+	case *parser.WindowFunction:
+		l.window = ex
+		return expr, nil
+	default:
+		return expr, nil
+	}
+}
+
+// liftAll lifts each of exprs
+func (l *aggregateLifter) liftAll(exprs []parser.Expression) ([]parser.Expression, error) {
+	if exprs == nil {
+		return nil, nil
+	}
+	lifted := make([]parser.Expression, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if lifted[i], err = l.lift(expr); err != nil {
+			return nil, err
+		}
+	}
+	return lifted, nil
+}
+
+// containsAggregate reports whether an aggregate is computed in expr outside
+// of subqueries
+func containsAggregate(expr parser.Expression) bool {
+	l := &aggregateLifter{}
+	_, err := l.lift(expr)
+	return err != nil || len(l.aggregates) > 0
+}
+
+// groupedBy reports whether expr is the GROUP BY expression group. Only
+// expressions that can be written out are compared.
+func groupedBy(expr, group parser.Expression) bool {
+	if _, ok := expr.(*parser.ColumnRef); !ok && expressionToString(expr) == "" {
+		return false
+	}
+	return expressionsEqual(expr, group)
+}
+
+// aggregatedColumnName returns the name of a column of an aggregated query:
+// its alias, the column it reads, or the function it calls. Other
+// expressions have none and are named "?".
+func aggregatedColumnName(col parser.SelectColumn) string {
+	if col.Alias != "" {
+		return col.Alias
+	}
+	switch ex := col.Expr.(type) {
+	case *parser.ColumnRef:
+		return ex.Name
+	case *parser.FunctionCall:
+		if agg, ok := AggregateFromCall(ex); ok {
+			return agg.FuncName
+		}
+		return ex.Name
+	}
+	return "?"
+}
+
+// aggregateFuncs are the functions computed over the rows of a group rather
+// than per row
+var aggregateFuncs = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+	"VAR_POP": true, "VAR_SAMP": true, "VARIANCE": true,
+	"STDDEV_POP": true, "STDDEV_SAMP": true, "STDDEV": true,
+	"GROUP_CONCAT": true, "STRING_AGG": true,
+	"JSON_ARRAYAGG": true, "JSON_OBJECTAGG": true,
+	"BOOL_AND": true, "BOOL_OR": true, "EVERY": true,
+	"PERCENTILE_CONT": true, "PERCENTILE_DISC": true, "MODE": true,
+	"APPROX_COUNT_DISTINCT": true, "VECTOR_SUM": true, "VECTOR_AVG": true,
+}
+
+// AggregateFromCall describes the aggregate a function call computes, or
+// returns false if the function is not an aggregate. The WITHIN GROUP
+// expression of an ordered-set aggregate becomes its first argument, ahead
+// of direct arguments such as the fraction of PERCENTILE_CONT.
+func AggregateFromCall(funcCall *parser.FunctionCall) (AggregateExpr, bool) {
+	name := strings.ToUpper(funcCall.Name)
+	if !aggregateFuncs[name] {
+		return AggregateExpr{}, false
+	}
+
+	args := funcCall.Args
+	if funcCall.WithinGroup {
+		args = append([]parser.Expression{funcCall.OrderBy[0].Expr}, funcCall.Args...)
+	}
+	var arg parser.Expression
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	return AggregateExpr{
+		FuncName:    name,
+		Arg:         arg,
+		Args:        args,
+		Distinct:    funcCall.Distinct,
+		OrderBy:     funcCall.OrderBy,
+		WithinGroup: funcCall.WithinGroup,
+		Filter:      funcCall.Filter,
+	}, true
+}
+
+// resolveDistinctOn returns the DISTINCT ON expressions and the ORDER BY of stmt
+// with references to select-list aliases replaced by the aliased expressions, as
+// both are evaluated before the projection. Like PostgreSQL it requires the
+// leading ORDER BY expressions to be DISTINCT ON expressions, so that the first
+// row of each group is well defined.
+func resolveDistinctOn(stmt *parser.SelectStmt) ([]parser.Expression, []parser.OrderByExpr, error) {
+	distinctOn := make([]parser.Expression, len(stmt.DistinctOn))
+	for i, expr := range stmt.DistinctOn {
+		distinctOn[i] = resolveSelectAlias(expr, stmt.Columns)
+	}
+	orderBy := resolveOrderByAliases(stmt.OrderBy, stmt.Columns)
+
+	for i := 0; i < len(orderBy) && i < len(distinctOn); i++ {
+		if !containsExpression(distinctOn, orderBy[i].Expr) {
+			return nil, nil, fmt.Errorf("SELECT DISTINCT ON expressions must match initial ORDER BY expressions")
+		}
+	}
+	return distinctOn, orderBy, nil
+}
+
+// resolveOrderByAliases returns orderBy with references to select-list
+// aliases replaced by the aliased expressions
+func resolveOrderByAliases(orderBy []parser.OrderByExpr, columns []parser.SelectColumn) []parser.OrderByExpr {
+	resolved := make([]parser.OrderByExpr, len(orderBy))
+	for i, ob := range orderBy {
+		resolved[i] = ob
+		resolved[i].Expr = resolveSelectAlias(ob.Expr, columns)
+	}
+	return resolved
+}
+
+// resolveSelectAlias returns the expression of the select column aliased by
+// expr when expr is a bare reference to an alias, and expr otherwise
+func resolveSelectAlias(expr parser.Expression, columns []parser.SelectColumn) parser.Expression {
+	ref, ok := expr.(*parser.ColumnRef)
+	if !ok {
+		return expr
+	}
+	for _, col := range columns {
+		if col.Alias != "" && col.Expr != nil && strings.EqualFold(col.Alias, ref.Name) {
+			return col.Expr
 		}
 	}
+	return expr
+}
 
-	return aggregates
+// containsExpression reports whether exprs holds an expression equal to expr
+func containsExpression(exprs []parser.Expression, expr parser.Expression) bool {
+	for _, e := range exprs {
+		if expressionsEqual(e, expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// expressionsEqual reports whether two expressions are written the same way.
+// A qualified column matches an unqualified one with the same name.
+func expressionsEqual(a, b parser.Expression) bool {
+	if refA, ok := a.(*parser.ColumnRef); ok {
+		if refB, ok := b.(*parser.ColumnRef); ok {
+			qualA, nameA := splitColumnRef(refA.Name)
+			qualB, nameB := splitColumnRef(refB.Name)
+			if !strings.EqualFold(nameA, nameB) {
+				return false
+			}
+			return qualA == "" || qualB == "" || strings.EqualFold(qualA, qualB)
+		}
+	}
+	return normalizeExpressionString(expressionToString(a)) == normalizeExpressionString(expressionToString(b))
 }
 
 func buildTableReference(ref parser.TableReference, catalog *schema.Catalog) (PlanNode, error) {
@@ -233,9 +603,10 @@ func buildTableReferenceWithCTEs(ref parser.TableReference, catalog *schema.Cata
 
 	case *parser.TableFunction:
 		return &TableFunctionNode{
-			Name:  t.Name,
-			Args:  t.Args,
-			Alias: t.Alias,
+			Name:      t.Name,
+			Args:      t.Args,
+			NamedArgs: t.NamedArgs,
+			Alias:     t.Alias,
 		}, nil
 
 	case *parser.Join:
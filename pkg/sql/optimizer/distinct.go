@@ -0,0 +1,228 @@
+// pkg/sql/optimizer/distinct.go
+package optimizer
+
+import (
+	"strings"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
+)
+
+// ApplyDistinctOrder avoids hashing rows for DISTINCT where the input already
+// provides what it needs:
+//
+//   - DISTINCT ON over a sort whose leading keys are the DISTINCT ON expressions
+//     sees the rows of each group together, so it keeps the first row of each
+//     run of equal keys.
+//   - SELECT DISTINCT of table columns that include every column of a unique
+//     index on NOT NULL columns cannot produce duplicates, so the DISTINCT is
+//     dropped. When the columns are exactly the index columns and there is no
+//     WHERE clause, the rows are read from the index entries in index order
+//     instead of from the table.
+//   - SELECT DISTINCT of exactly the columns of any other B-tree index, without
+//     a WHERE clause, reads the index entries instead. Entries with equal
+//     values are adjacent in index order, so each row is only compared with
+//     the previous one.
+func (o *Optimizer) ApplyDistinctOrder(plan PlanNode) PlanNode {
+	switch node := plan.(type) {
+	case *DistinctNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+		if len(node.On) > 0 {
+			if sort, ok := node.Input.(*SortNode); ok && sortLeadsWith(sort.OrderBy, node.On) {
+				node.Sorted = true
+			}
+			return node
+		}
+		if input := o.distinctFromUniqueIndex(node.Input); input != nil {
+			return input
+		}
+		node.Sorted = o.distinctFromOrderedIndex(node.Input)
+
+	case *LimitNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *FilterNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *ProjectionNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *SortNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *TopKNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *AggregateNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *WindowNode:
+		node.Input = o.ApplyDistinctOrder(node.Input)
+
+	case *SubqueryScanNode:
+		node.SubqueryPlan = o.ApplyDistinctOrder(node.SubqueryPlan)
+
+	case *NestedLoopJoinNode:
+		node.Left = o.ApplyDistinctOrder(node.Left)
+		node.Right = o.ApplyDistinctOrder(node.Right)
+
+	case *HashJoinNode:
+		node.Left = o.ApplyDistinctOrder(node.Left)
+		node.Right = o.ApplyDistinctOrder(node.Right)
+	}
+
+	return plan
+}
+
+// sortLeadsWith reports whether the first len(exprs) ORDER BY expressions are
+// exactly exprs, in any order
+func sortLeadsWith(orderBy []parser.OrderByExpr, exprs []parser.Expression) bool {
+	if len(orderBy) < len(exprs) {
+		return false
+	}
+	for _, expr := range exprs {
+		found := false
+		for _, ob := range orderBy[:len(exprs)] {
+			if expressionsEqual(ob.Expr, expr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// distinctFromUniqueIndex returns the input of a SELECT DISTINCT with the
+// DISTINCT removed when a unique index proves the rows distinct, or nil if it
+// cannot. The input must be Projection([Filter](TableScan)) selecting plain
+// columns of the table.
+func (o *Optimizer) distinctFromUniqueIndex(input PlanNode) PlanNode {
+	projection, filter, scan, columns := o.distinctColumns(input)
+	if projection == nil {
+		return nil
+	}
+
+	for _, idx := range o.catalog.GetIndexesForTable(scan.Table.Name) {
+		if !isDistinctIndex(idx, scan.Table, columns) {
+			continue
+		}
+
+		// Read the rows straight from the index when it holds every selected column
+		if filter == nil && len(idx.Columns) == len(columns) {
+			projection.Input = coveringIndexScan(scan, idx)
+		}
+		return projection
+	}
+	return nil
+}
+
+// distinctFromOrderedIndex makes the input of a SELECT DISTINCT read the
+// entries of a B-tree index over exactly the selected columns, in index order,
+// and reports whether it found one. The input must be Projection(TableScan)
+// selecting plain columns of the table.
+func (o *Optimizer) distinctFromOrderedIndex(input PlanNode) bool {
+	projection, filter, scan, columns := o.distinctColumns(input)
+	if projection == nil || filter != nil {
+		return false
+	}
+
+	for _, idx := range o.catalog.GetIndexesForTable(scan.Table.Name) {
+		if idx.Type == schema.IndexTypeHNSW || idx.IsPartial() || idx.IsExpressionIndex() || len(idx.Columns) != len(columns) {
+			continue
+		}
+		covered := true
+		for _, name := range idx.Columns {
+			covered = covered && columns[strings.ToLower(name)]
+		}
+		if covered {
+			projection.Input = coveringIndexScan(scan, idx)
+			return true
+		}
+	}
+	return false
+}
+
+// distinctColumns splits the input of a SELECT DISTINCT of plain columns of
+// one table, Projection([Filter](TableScan)), and returns the lower-cased
+// names of the selected columns. The projection is nil for any other input.
+func (o *Optimizer) distinctColumns(input PlanNode) (*ProjectionNode, *FilterNode, *TableScanNode, map[string]bool) {
+	if o.catalog == nil {
+		return nil, nil, nil, nil
+	}
+	projection, ok := input.(*ProjectionNode)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+	filter, _ := projection.Input.(*FilterNode)
+	below := projection.Input
+	if filter != nil {
+		below = filter.Input
+	}
+	scan, ok := below.(*TableScanNode)
+	if !ok || scan.Table == nil {
+		return nil, nil, nil, nil
+	}
+
+	tableRef := scan.Alias
+	if tableRef == "" {
+		tableRef = scan.Table.Name
+	}
+	columns := make(map[string]bool)
+	for _, expr := range projection.Expressions {
+		ref, ok := expr.(*parser.ColumnRef)
+		if !ok {
+			return nil, nil, nil, nil
+		}
+		qualifier, name := splitColumnRef(ref.Name)
+		if qualifier != "" && !strings.EqualFold(qualifier, tableRef) {
+			return nil, nil, nil, nil
+		}
+		col, _ := scan.Table.GetColumn(name)
+		if col == nil {
+			return nil, nil, nil, nil
+		}
+		columns[strings.ToLower(col.Name)] = true
+	}
+	return projection, filter, scan, columns
+}
+
+// coveringIndexScan returns a scan of the entries of idx that replaces scan
+func coveringIndexScan(scan *TableScanNode, idx *schema.IndexDef) *IndexScanNode {
+	return &IndexScanNode{
+		Table:     scan.Table,
+		Alias:     scan.Alias,
+		IndexName: idx.Name,
+		Columns:   idx.Columns,
+		Cost:      scan.Cost / 2,
+		Rows:      scan.Rows,
+	}
+}
+
+// isDistinctIndex reports whether idx is a unique B-tree index over NOT NULL
+// columns of table, all of which are in columns. Every row of the table then
+// has different values for those columns. NULLs are excluded because a unique
+// index admits any number of rows with NULL keys.
+func isDistinctIndex(idx *schema.IndexDef, table *schema.TableDef, columns map[string]bool) bool {
+	if !idx.Unique || idx.Type == schema.IndexTypeHNSW || idx.IsPartial() || idx.IsExpressionIndex() || len(idx.Columns) == 0 {
+		return false
+	}
+	for _, name := range idx.Columns {
+		if !columns[strings.ToLower(name)] {
+			return false
+		}
+		col, _ := table.GetColumn(name)
+		if col == nil || !isNotNullColumn(col) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNotNullColumn reports whether a column can never hold NULL
+func isNotNullColumn(col *schema.ColumnDef) bool {
+	return col.PrimaryKey || col.NotNull ||
+		col.HasConstraint(schema.ConstraintPrimaryKey) || col.HasConstraint(schema.ConstraintNotNull)
+}
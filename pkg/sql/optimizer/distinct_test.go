@@ -0,0 +1,194 @@
+// pkg/sql/optimizer/distinct_test.go
+package optimizer
+
+import (
+	"testing"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// distinctCatalog returns a catalog with tags(id PRIMARY KEY, code NOT NULL UNIQUE, label UNIQUE)
+func distinctCatalog() *schema.Catalog {
+	catalog := schema.NewCatalog()
+	catalog.CreateTable(&schema.TableDef{
+		Name: "tags",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32, PrimaryKey: true},
+			{Name: "code", Type: types.TypeText, NotNull: true},
+			{Name: "label", Type: types.TypeText},
+		},
+	})
+	catalog.CreateIndex(&schema.IndexDef{Name: "pk_tags_id", TableName: "tags", Columns: []string{"id"}, Unique: true})
+	catalog.CreateIndex(&schema.IndexDef{Name: "uq_code", TableName: "tags", Columns: []string{"code"}, Unique: true})
+	catalog.CreateIndex(&schema.IndexDef{Name: "uq_label", TableName: "tags", Columns: []string{"label"}, Unique: true})
+	return catalog
+}
+
+func optimizeSQL(t *testing.T, catalog *schema.Catalog, sql string) PlanNode {
+	t.Helper()
+	stmt, err := parser.New(sql).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	plan, err := BuildPlan(stmt.(*parser.SelectStmt), catalog)
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	opt := NewOptimizer()
+	opt.SetCatalog(catalog)
+	return opt.ApplyDistinctOrder(plan)
+}
+
+func TestBuildPlan_Distinct(t *testing.T) {
+	catalog := distinctCatalog()
+
+	plan := optimizeSQL(t, catalog, "SELECT DISTINCT label FROM tags WHERE id > 1 ORDER BY label")
+	sort, ok := plan.(*SortNode)
+	if !ok {
+		t.Fatalf("expected SortNode, got %T", plan)
+	}
+	distinct, ok := sort.Input.(*DistinctNode)
+	if !ok {
+		t.Fatalf("expected DistinctNode below the sort, got %T", sort.Input)
+	}
+	if distinct.Sorted || len(distinct.On) != 0 {
+		t.Errorf("expected a hashed DISTINCT over whole rows")
+	}
+	if _, ok := distinct.Input.(*ProjectionNode); !ok {
+		t.Errorf("expected DISTINCT over the projection, got %T", distinct.Input)
+	}
+}
+
+func TestBuildPlan_DistinctOn(t *testing.T) {
+	catalog := distinctCatalog()
+
+	plan := optimizeSQL(t, catalog, "SELECT DISTINCT ON (c) code AS c FROM tags ORDER BY c, id DESC")
+	proj, ok := plan.(*ProjectionNode)
+	if !ok {
+		t.Fatalf("expected ProjectionNode, got %T", plan)
+	}
+	distinct, ok := proj.Input.(*DistinctNode)
+	if !ok {
+		t.Fatalf("expected DistinctNode below the projection, got %T", proj.Input)
+	}
+	if !distinct.Sorted {
+		t.Errorf("expected DISTINCT ON to use the sorted input")
+	}
+	if ref, ok := distinct.On[0].(*parser.ColumnRef); !ok || ref.Name != "code" {
+		t.Errorf("expected the alias to resolve to code, got %#v", distinct.On[0])
+	}
+	sort, ok := distinct.Input.(*SortNode)
+	if !ok {
+		t.Fatalf("expected SortNode below DISTINCT ON, got %T", distinct.Input)
+	}
+	if ref, ok := sort.OrderBy[0].Expr.(*parser.ColumnRef); !ok || ref.Name != "code" {
+		t.Errorf("expected ORDER BY alias to resolve to code, got %#v", sort.OrderBy[0].Expr)
+	}
+
+	// Without ORDER BY the rows are hashed
+	plan = optimizeSQL(t, catalog, "SELECT DISTINCT ON (label) id FROM tags")
+	if distinct := plan.(*ProjectionNode).Input.(*DistinctNode); distinct.Sorted {
+		t.Errorf("expected DISTINCT ON without ORDER BY to hash")
+	}
+
+	stmt, _ := parser.New("SELECT DISTINCT ON (label) id FROM tags ORDER BY id").Parse()
+	if _, err := BuildPlan(stmt.(*parser.SelectStmt), catalog); err == nil {
+		t.Errorf("expected an error when ORDER BY does not start with the DISTINCT ON expressions")
+	}
+}
+
+func TestApplyDistinctOrder_UniqueIndex(t *testing.T) {
+	catalog := distinctCatalog()
+
+	// Exactly the columns of a unique NOT NULL index: read the index
+	plan := optimizeSQL(t, catalog, "SELECT DISTINCT code FROM tags")
+	proj, ok := plan.(*ProjectionNode)
+	if !ok {
+		t.Fatalf("expected DISTINCT to be dropped, got %T", plan)
+	}
+	scan, ok := proj.Input.(*IndexScanNode)
+	if !ok {
+		t.Fatalf("expected IndexScanNode, got %T", proj.Input)
+	}
+	if scan.IndexName != "uq_code" || len(scan.Columns) != 1 || scan.Columns[0] != "code" {
+		t.Errorf("unexpected index scan %+v", scan)
+	}
+
+	// A superset of the index columns with a filter: keep the table scan
+	plan = optimizeSQL(t, catalog, "SELECT DISTINCT tags.id, label FROM tags WHERE label = 'x'")
+	proj, ok = plan.(*ProjectionNode)
+	if !ok {
+		t.Fatalf("expected DISTINCT to be dropped, got %T", plan)
+	}
+	if _, ok := proj.Input.(*FilterNode); !ok {
+		t.Errorf("expected the filter to stay, got %T", proj.Input)
+	}
+
+	// label may hold many NULLs, and expressions are not plain columns
+	for _, sql := range []string{
+		"SELECT DISTINCT label FROM tags",
+		"SELECT DISTINCT code || 'x' FROM tags",
+	} {
+		if _, ok := optimizeSQL(t, catalog, sql).(*DistinctNode); !ok {
+			t.Errorf("expected DISTINCT to be kept for %s", sql)
+		}
+	}
+}
+
+func TestApplyDistinctOrder_OrderedIndex(t *testing.T) {
+	catalog := distinctCatalog()
+	catalog.CreateTable(&schema.TableDef{
+		Name: "posts",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32, PrimaryKey: true},
+			{Name: "author", Type: types.TypeText},
+			{Name: "status", Type: types.TypeText},
+		},
+	})
+	catalog.CreateIndex(&schema.IndexDef{Name: "idx_author_status", TableName: "posts", Columns: []string{"author", "status"}})
+	catalog.CreateIndex(&schema.IndexDef{Name: "idx_status_draft", TableName: "posts", Columns: []string{"status"}, WhereClause: "status = 'draft'"})
+
+	// Exactly the columns of a non-unique index, in any order: read the index
+	// and compare each row with the previous one
+	plan := optimizeSQL(t, catalog, "SELECT DISTINCT status, p.author FROM posts p")
+	distinct, ok := plan.(*DistinctNode)
+	if !ok {
+		t.Fatalf("expected DistinctNode, got %T", plan)
+	}
+	if !distinct.Sorted {
+		t.Errorf("expected DISTINCT to use the index order")
+	}
+	scan, ok := distinct.Input.(*ProjectionNode).Input.(*IndexScanNode)
+	if !ok {
+		t.Fatalf("expected IndexScanNode below the projection, got %T", distinct.Input.(*ProjectionNode).Input)
+	}
+	if scan.IndexName != "idx_author_status" || scan.Alias != "p" {
+		t.Errorf("unexpected index scan %+v", scan)
+	}
+
+	// So does a unique index that admits NULLs
+	plan = optimizeSQL(t, catalog, "SELECT DISTINCT label FROM tags")
+	if distinct := plan.(*DistinctNode); !distinct.Sorted {
+		t.Errorf("expected DISTINCT on a nullable unique column to use the index order")
+	}
+
+	// A subset of the columns, a filter or a partial index: hash the table rows
+	for _, sql := range []string{
+		"SELECT DISTINCT author FROM posts",
+		"SELECT DISTINCT author, status FROM posts WHERE id > 1",
+		"SELECT DISTINCT status FROM posts",
+	} {
+		distinct, ok := optimizeSQL(t, catalog, sql).(*DistinctNode)
+		if !ok {
+			t.Fatalf("expected DistinctNode for %s", sql)
+		}
+		if distinct.Sorted {
+			t.Errorf("expected a hashed DISTINCT for %s", sql)
+		}
+		if _, ok := distinct.Input.(*ProjectionNode).Input.(*IndexScanNode); ok {
+			t.Errorf("expected no index scan for %s", sql)
+		}
+	}
+}
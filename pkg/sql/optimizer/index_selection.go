@@ -51,6 +51,11 @@ func FindCandidateIndexes(table *schema.TableDef, where parser.Expression, catal
 
 	var candidates []IndexCandidate
 	for _, idx := range indexes {
+		// HNSW indexes only answer nearest-neighbor searches, not comparisons
+		if idx.Type == schema.IndexTypeHNSW {
+			continue
+		}
+
 		// For partial indexes, check if query implies the index predicate
 		if idx.IsPartial() {
 			if !queryImpliesPartialIndexPredicate(idx, predicates) {
@@ -100,6 +105,21 @@ func FindCandidateIndexes(table *schema.TableDef, where parser.Expression, catal
 	return candidates
 }
 
+// FindVectorIndex returns the HNSW index on the given table column that a
+// nearest-neighbor search can use, or nil if the column has none.
+// Indexes are checked in name order so the choice is deterministic.
+func FindVectorIndex(catalog *schema.Catalog, tableName, columnName string) *schema.IndexDef {
+	for _, idx := range catalog.GetIndexesForTable(tableName) {
+		if idx.Type != schema.IndexTypeHNSW || len(idx.Columns) != 1 {
+			continue
+		}
+		if strings.EqualFold(idx.Columns[0], columnName) {
+			return idx
+		}
+	}
+	return nil
+}
+
 // queryImpliesPartialIndexPredicate checks if the query's predicates
 // imply (are at least as restrictive as) the partial index predicate.
 // For example, if index has WHERE active = 1, query must include active = 1.
@@ -454,6 +474,25 @@ func expressionToString(expr parser.Expression) string {
 		right := expressionToString(e.Right)
 		op := operatorToString(e.Op)
 		return fmt.Sprintf("%s%s", op, right)
+	case *parser.IsNullExpr:
+		if e.Not {
+			return fmt.Sprintf("(%s IS NOT NULL)", expressionToString(e.Expr))
+		}
+		return fmt.Sprintf("(%s IS NULL)", expressionToString(e.Expr))
+	case *parser.IsDistinctExpr:
+		op := "IS DISTINCT FROM"
+		if e.Not {
+			op = "IS NOT DISTINCT FROM"
+		}
+		return fmt.Sprintf("(%s %s %s)", expressionToString(e.Left), op, expressionToString(e.Right))
+	case *parser.BetweenExpr:
+		op := "BETWEEN"
+		if e.Not {
+			op = "NOT BETWEEN"
+		}
+		return fmt.Sprintf("(%s %s %s AND %s)", expressionToString(e.Expr), op, expressionToString(e.Low), expressionToString(e.High))
+	case *parser.CastExpr:
+		return fmt.Sprintf("CAST(%s AS %s)", expressionToString(e.Expr), e.Type.Type)
 	default:
 		return ""
 	}
@@ -1189,3 +1189,47 @@ func TestFindCandidateIndexes_MixedColumnAndExpression(t *testing.T) {
 		t.Errorf("expected index 'idx_status_lower_name', got '%s'", candidates[0].Index.Name)
 	}
 }
+
+func TestFindCandidateIndexes_SkipsHNSWIndex(t *testing.T) {
+	// Setup: Table with an HNSW index on "embedding"
+	catalog := schema.NewCatalog()
+	tableDef := &schema.TableDef{
+		Name: "docs",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32},
+			{Name: "embedding", Type: types.TypeVector, VectorDim: 3},
+		},
+	}
+	catalog.CreateTable(tableDef)
+	catalog.CreateIndex(&schema.IndexDef{
+		Name:       "emb_idx",
+		TableName:  "docs",
+		Columns:    []string{"embedding"},
+		Type:       schema.IndexTypeHNSW,
+		HNSWParams: schema.DefaultHNSWParams(),
+	})
+
+	// WHERE embedding = x'00'
+	whereClause := &parser.BinaryExpr{
+		Left:  &parser.ColumnRef{Name: "embedding"},
+		Op:    lexer.EQ,
+		Right: &parser.Literal{Value: types.NewBlob([]byte{0})},
+	}
+
+	// Act
+	candidates := FindCandidateIndexes(tableDef, whereClause, catalog)
+
+	// Assert: HNSW cannot answer equality predicates
+	if len(candidates) != 0 {
+		t.Errorf("expected no candidates, got %d", len(candidates))
+	}
+
+	// But it is found for nearest-neighbor searches
+	idx := FindVectorIndex(catalog, "docs", "EMBEDDING")
+	if idx == nil || idx.Name != "emb_idx" {
+		t.Errorf("expected FindVectorIndex to return emb_idx, got %+v", idx)
+	}
+	if FindVectorIndex(catalog, "docs", "id") != nil {
+		t.Error("expected no vector index on id")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"tur/pkg/schema"
 	"tur/pkg/sql/lexer"
 	"tur/pkg/sql/parser"
+	"tur/pkg/types"
 )
 
 // StatisticsProvider provides table statistics for query optimization
@@ -17,6 +18,8 @@ type StatisticsProvider interface {
 type Optimizer struct {
 	costEstimator      *CostEstimator
 	statisticsProvider StatisticsProvider
+	catalog            *schema.Catalog // used to find indexes; optional
+	params             []types.Value   // values bound to the placeholders of the query; optional
 
 	// UseDP controls join reordering algorithm selection:
 	//
@@ -54,6 +57,17 @@ func (o *Optimizer) SetStatisticsProvider(provider StatisticsProvider) {
 	o.statisticsProvider = provider
 }
 
+// SetCatalog sets the catalog used to look up indexes during optimization
+func (o *Optimizer) SetCatalog(catalog *schema.Catalog) {
+	o.catalog = catalog
+}
+
+// SetParams sets the values bound to the placeholders of the query, which
+// rewrites that depend on a value, such as LIMIT ?, use like literals
+func (o *Optimizer) SetParams(params []types.Value) {
+	o.params = params
+}
+
 // getTableCardinality returns the estimated row count for a table,
 // using statistics if available, otherwise falling back to the plan node estimate
 func (o *Optimizer) getTableCardinality(node PlanNode) int64 {
@@ -72,6 +86,15 @@ func (o *Optimizer) Optimize(plan PlanNode) PlanNode {
 	// Apply predicate pushdown
 	plan = o.ApplyPredicatePushdown(plan)
 
+	// Push filters on tables joined with a vector search into the search itself
+	plan = o.ApplyVectorFilterPushdown(plan)
+
+	// Turn ORDER BY vector_distance(...) LIMIT k into an index search or top-k scan
+	plan = o.ApplyVectorOrderBy(plan)
+
+	// Let DISTINCT use sorted input or a unique index instead of hashing rows
+	plan = o.ApplyDistinctOrder(plan)
+
 	// Apply projection pushdown
 	plan = o.ApplyProjectionPushdown(plan)
 
@@ -2,6 +2,8 @@
 package optimizer
 
 import (
+	"math"
+
 	"tur/pkg/schema"
 	"tur/pkg/sql/parser"
 )
@@ -53,11 +55,13 @@ func (n *SubqueryScanNode) EstimatedRows() int64 {
 	return n.SubqueryPlan.EstimatedRows()
 }
 
-// IndexScanNode represents an index scan
+// IndexScanNode represents an index scan. It reads the key columns of the
+// index entries in index order without visiting the table.
 type IndexScanNode struct {
 	Table     *schema.TableDef
 	Alias     string
 	IndexName string
+	Columns   []string // Indexed columns produced by the scan, in index order
 	Cost      float64
 	Rows      int64
 }
@@ -221,14 +225,21 @@ func (n *LimitNode) EstimatedRows() int64 {
 
 // AggregateExpr represents an aggregate function in a query
 type AggregateExpr struct {
-	FuncName string            // e.g., "COUNT", "SUM", "AVG"
-	Arg      parser.Expression // The argument to the aggregate (e.g., column ref)
+	FuncName    string               // e.g., "COUNT", "SUM", "AVG"
+	Name        string               // Output column name; FuncName if empty
+	Arg         parser.Expression    // The argument to the aggregate (e.g., column ref)
+	Args        []parser.Expression  // All arguments, starting with Arg (e.g., the separator of STRING_AGG)
+	Distinct    bool                 // Aggregate over distinct values of Arg only
+	OrderBy     []parser.OrderByExpr // Order the rows are aggregated in
+	WithinGroup bool                 // OrderBy is WITHIN GROUP of an ordered-set aggregate; its expression is Arg
+	Filter      parser.Expression    // FILTER (WHERE ...): only rows where it is true are aggregated
 }
 
 // AggregateNode represents a GROUP BY operation with aggregations
 type AggregateNode struct {
 	Input      PlanNode            // Input plan (filtered data)
 	GroupBy    []parser.Expression // GROUP BY expressions (e.g., column refs)
+	GroupNames []string            // Output column names of GroupBy; the column, or "?" for other expressions, if empty
 	Aggregates []AggregateExpr     // Aggregate functions to compute
 	Having     parser.Expression   // Optional HAVING filter (nil if none)
 }
@@ -258,6 +269,34 @@ func (n *AggregateNode) EstimatedRows() int64 {
 	return groupCount
 }
 
+// DistinctNode removes duplicate rows (SELECT DISTINCT) or keeps the first row
+// for each value of the DISTINCT ON expressions
+type DistinctNode struct {
+	Input PlanNode
+	On    []parser.Expression // DISTINCT ON expressions (nil compares whole rows)
+	// Sorted is set when the input arrives ordered by the distinct key, so
+	// duplicates are adjacent and are dropped without a hash table.
+	Sorted bool
+}
+
+func (n *DistinctNode) EstimatedCost() float64 {
+	// Sorted input compares each row with the previous one; otherwise every row is hashed
+	costPerRow := 0.02
+	if n.Sorted {
+		costPerRow = 0.001
+	}
+	return n.Input.EstimatedCost() + float64(n.Input.EstimatedRows())*costPerRow
+}
+
+func (n *DistinctNode) EstimatedRows() int64 {
+	// Without statistics assume half the rows are duplicates
+	rows := n.Input.EstimatedRows() / 2
+	if rows < 1 {
+		return 1
+	}
+	return rows
+}
+
 // Helper for log base 2
 func log2(x float64) float64 {
 	if x <= 1 {
@@ -319,9 +358,16 @@ func (n *WindowNode) EstimatedRows() int64 {
 // TableFunctionNode represents a table-valued function call
 // e.g., vector_quantize_scan('table', 'column', query_vec, k)
 type TableFunctionNode struct {
-	Name  string              // Function name (e.g., "vector_quantize_scan")
-	Args  []parser.Expression // Function arguments
-	Alias string              // Optional alias
+	Name      string              // Function name (e.g., "vector_quantize_scan")
+	Args      []parser.Expression // Positional function arguments
+	NamedArgs []parser.NamedArg   // Named arguments (name => value)
+	Alias     string              // Optional alias
+
+	// Filter holds WHERE predicates on the searched table pushed down by the
+	// optimizer. Rows that fail it are skipped during the vector search.
+	Filter      parser.Expression
+	FilterTable *schema.TableDef // Table the filter is evaluated against
+	FilterAlias string           // Qualifier used for FilterTable columns in Filter
 }
 
 func (n *TableFunctionNode) EstimatedCost() float64 {
@@ -335,3 +381,54 @@ func (n *TableFunctionNode) EstimatedRows() int64 {
 	// Default to 10 if we can't determine
 	return 10
 }
+
+// VectorIndexScanNode reads the rows of a table nearest to a query vector through
+// an HNSW index, in order of increasing distance. It replaces
+// ORDER BY vector_distance(column, query) LIMIT k over a table scan.
+type VectorIndexScanNode struct {
+	Table     *schema.TableDef
+	Alias     string
+	IndexName string
+	Column    string            // Indexed VECTOR column
+	Query     parser.Expression // Query vector
+	Limit     parser.Expression // LIMIT of the replaced query
+	Offset    parser.Expression // OFFSET of the replaced query (may be nil); these rows are fetched too
+	Filter    parser.Expression // WHERE predicates applied during the search (may be nil)
+}
+
+func (n *VectorIndexScanNode) EstimatedCost() float64 {
+	// An HNSW search visits a small, roughly logarithmic part of the graph
+	return 10.0
+}
+
+func (n *VectorIndexScanNode) EstimatedRows() int64 {
+	if lit, ok := n.Limit.(*parser.Literal); ok {
+		return lit.Value.Int()
+	}
+	return 10
+}
+
+// TopKNode returns the first Limit+Offset rows of its input in ORDER BY order.
+// It keeps a bounded heap instead of sorting every input row.
+type TopKNode struct {
+	Input   PlanNode
+	OrderBy []parser.OrderByExpr
+	Limit   parser.Expression
+	Offset  parser.Expression // May be nil
+}
+
+func (n *TopKNode) EstimatedCost() float64 {
+	// One heap operation per input row: O(n log k)
+	rows := float64(n.Input.EstimatedRows())
+	return n.Input.EstimatedCost() + rows*math.Log2(float64(n.EstimatedRows())+1)
+}
+
+func (n *TopKNode) EstimatedRows() int64 {
+	if lit, ok := n.Limit.(*parser.Literal); ok {
+		if rows := n.Input.EstimatedRows(); lit.Value.Int() > rows {
+			return rows
+		}
+		return lit.Value.Int()
+	}
+	return n.Input.EstimatedRows()
+}
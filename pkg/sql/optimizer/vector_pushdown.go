@@ -0,0 +1,449 @@
+// pkg/sql/optimizer/vector_pushdown.go
+package optimizer
+
+import (
+	"strings"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/lexer"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// ApplyVectorFilterPushdown pushes WHERE predicates on a table joined with
+// vector_quantize_scan by rowid into the scan node, so the HNSW search only
+// returns rows that satisfy them instead of over-fetching and filtering later.
+// The original filter is kept above the join, so the rewrite never changes results.
+func (o *Optimizer) ApplyVectorFilterPushdown(plan PlanNode) PlanNode {
+	switch node := plan.(type) {
+	case *FilterNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+		if join, ok := node.Input.(*NestedLoopJoinNode); ok {
+			pushVectorFilter(join, node.Condition)
+		}
+
+	case *ProjectionNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+
+	case *SortNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+
+	case *LimitNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+
+	case *AggregateNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+
+	case *WindowNode:
+		node.Input = o.ApplyVectorFilterPushdown(node.Input)
+
+	case *SubqueryScanNode:
+		node.SubqueryPlan = o.ApplyVectorFilterPushdown(node.SubqueryPlan)
+
+	case *NestedLoopJoinNode:
+		node.Left = o.ApplyVectorFilterPushdown(node.Left)
+		node.Right = o.ApplyVectorFilterPushdown(node.Right)
+		pushVectorFilter(node, nil)
+
+	case *HashJoinNode:
+		node.Left = o.ApplyVectorFilterPushdown(node.Left)
+		node.Right = o.ApplyVectorFilterPushdown(node.Right)
+	}
+
+	return plan
+}
+
+// pushVectorFilter attaches the predicates of an inner join between
+// vector_quantize_scan and the table it searches to the scan node.
+// Only conjuncts that reference nothing but columns of that table are pushed,
+// and only when the join equates the scan's rowid with the table's integer primary key.
+func pushVectorFilter(join *NestedLoopJoinNode, where parser.Expression) {
+	if join.JoinType != parser.JoinInner {
+		return
+	}
+
+	fn, fnOK := join.Left.(*TableFunctionNode)
+	scan, scanOK := join.Right.(*TableScanNode)
+	if !fnOK || !scanOK {
+		fn, fnOK = join.Right.(*TableFunctionNode)
+		scan, scanOK = join.Left.(*TableScanNode)
+		if !fnOK || !scanOK {
+			return
+		}
+	}
+	if !strings.EqualFold(fn.Name, "vector_quantize_scan") || len(fn.Args) == 0 || scan.Table == nil {
+		return
+	}
+	lit, ok := fn.Args[0].(*parser.Literal)
+	if !ok || lit.Value.Type() != types.TypeText || !strings.EqualFold(lit.Value.Text(), scan.Table.Name) {
+		return
+	}
+
+	var pkColumn string
+	for _, col := range scan.Table.Columns {
+		if col.PrimaryKey && types.IsIntegerType(col.Type) {
+			pkColumn = col.Name
+			break
+		}
+	}
+	if pkColumn == "" {
+		return
+	}
+
+	fnRef := fn.Alias
+	if fnRef == "" {
+		fnRef = fn.Name
+	}
+	tableRef := scan.Alias
+	if tableRef == "" {
+		tableRef = scan.Table.Name
+	}
+
+	conjuncts := append(splitConjuncts(join.Condition), splitConjuncts(where)...)
+
+	linked := false
+	var pushed []parser.Expression
+	for _, c := range conjuncts {
+		if isRowIDJoin(c, fnRef, tableRef, pkColumn) {
+			linked = true
+			continue
+		}
+		if referencesOnlyTable(c, tableRef, scan.Table) {
+			pushed = append(pushed, c)
+		}
+	}
+	if !linked || len(pushed) == 0 {
+		return
+	}
+
+	filter := pushed[0]
+	for _, c := range pushed[1:] {
+		filter = &parser.BinaryExpr{Left: filter, Op: lexer.AND, Right: c}
+	}
+	fn.Filter = filter
+	fn.FilterTable = scan.Table
+	fn.FilterAlias = tableRef
+}
+
+// splitConjuncts flattens a tree of AND expressions into its operands
+func splitConjuncts(expr parser.Expression) []parser.Expression {
+	if expr == nil {
+		return nil
+	}
+	if bin, ok := expr.(*parser.BinaryExpr); ok && bin.Op == lexer.AND {
+		return append(splitConjuncts(bin.Left), splitConjuncts(bin.Right)...)
+	}
+	return []parser.Expression{expr}
+}
+
+// isRowIDJoin reports whether expr is "<fnRef>.rowid = <tableRef>.<pk>" (in either order)
+func isRowIDJoin(expr parser.Expression, fnRef, tableRef, pkColumn string) bool {
+	bin, ok := expr.(*parser.BinaryExpr)
+	if !ok || bin.Op != lexer.EQ {
+		return false
+	}
+	left, lok := bin.Left.(*parser.ColumnRef)
+	right, rok := bin.Right.(*parser.ColumnRef)
+	if !lok || !rok {
+		return false
+	}
+
+	isRowID := func(ref *parser.ColumnRef) bool {
+		qualifier, name := splitColumnRef(ref.Name)
+		return strings.EqualFold(name, "rowid") && (qualifier == "" || strings.EqualFold(qualifier, fnRef))
+	}
+	isPK := func(ref *parser.ColumnRef) bool {
+		qualifier, name := splitColumnRef(ref.Name)
+		return strings.EqualFold(name, pkColumn) && (qualifier == "" || strings.EqualFold(qualifier, tableRef))
+	}
+
+	return (isRowID(left) && isPK(right)) || (isPK(left) && isRowID(right))
+}
+
+// referencesOnlyTable reports whether expr can be evaluated against a single row
+// of table: every column it names belongs to table, and it contains no subqueries
+// or other constructs that depend on the rest of the query.
+func referencesOnlyTable(expr parser.Expression, tableRef string, table *schema.TableDef) bool {
+	hasColumn := false
+	var check func(e parser.Expression) bool
+	check = func(e parser.Expression) bool {
+		switch ex := e.(type) {
+		case nil:
+			return true
+		case *parser.Literal, *parser.Placeholder:
+			return true
+		case *parser.ColumnRef:
+			qualifier, name := splitColumnRef(ex.Name)
+			if qualifier == "" {
+				// Unqualified names that the scan also outputs are ambiguous
+				if strings.EqualFold(name, "rowid") || strings.EqualFold(name, "distance") {
+					return false
+				}
+			} else if !strings.EqualFold(qualifier, tableRef) {
+				return false
+			}
+			if col, _ := table.GetColumn(name); col == nil {
+				return false
+			}
+			hasColumn = true
+			return true
+		case *parser.BinaryExpr:
+			return check(ex.Left) && check(ex.Right)
+		case *parser.UnaryExpr:
+			return check(ex.Right)
+		case *parser.FunctionCall:
+			for _, arg := range ex.Args {
+				if !check(arg) {
+					return false
+				}
+			}
+			return true
+		case *parser.InExpr:
+			if ex.Subquery != nil {
+				return false
+			}
+			for _, v := range ex.Values {
+				if !check(v) {
+					return false
+				}
+			}
+			return check(ex.Left)
+		case *parser.LikeExpr:
+			return check(ex.Left) && check(ex.Pattern)
+		case *parser.IsNullExpr:
+			return check(ex.Expr)
+		case *parser.IsDistinctExpr:
+			return check(ex.Left) && check(ex.Right)
+		case *parser.BetweenExpr:
+			return check(ex.Expr) && check(ex.Low) && check(ex.High)
+		case *parser.CastExpr:
+			return check(ex.Expr)
+		case *parser.CaseExpr:
+			for _, w := range ex.Whens {
+				if !check(w.Condition) || !check(w.Then) {
+					return false
+				}
+			}
+			return check(ex.Operand) && check(ex.Else)
+		default:
+			return false
+		}
+	}
+	return check(expr) && hasColumn
+}
+
+// splitColumnRef splits "qualifier.name" into its parts; qualifier is empty for bare names
+func splitColumnRef(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "."); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
+}
+
+// ApplyVectorOrderBy rewrites nearest-neighbour queries of the form
+//
+//	SELECT ... FROM t [WHERE ...] ORDER BY vector_distance(col, query) LIMIT k
+//
+// into an HNSW index search when col has a cosine HNSW index (the metric
+// vector_distance uses), or otherwise into a bounded top-k scan so the whole
+// table is never sorted.
+func (o *Optimizer) ApplyVectorOrderBy(plan PlanNode) PlanNode {
+	switch node := plan.(type) {
+	case *LimitNode:
+		if rewritten := o.rewriteVectorOrderBy(node); rewritten != nil {
+			return rewritten
+		}
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *FilterNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *ProjectionNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *SortNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *AggregateNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *DistinctNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *WindowNode:
+		node.Input = o.ApplyVectorOrderBy(node.Input)
+
+	case *SubqueryScanNode:
+		node.SubqueryPlan = o.ApplyVectorOrderBy(node.SubqueryPlan)
+
+	case *NestedLoopJoinNode:
+		node.Left = o.ApplyVectorOrderBy(node.Left)
+		node.Right = o.ApplyVectorOrderBy(node.Right)
+
+	case *HashJoinNode:
+		node.Left = o.ApplyVectorOrderBy(node.Left)
+		node.Right = o.ApplyVectorOrderBy(node.Right)
+	}
+
+	return plan
+}
+
+// rewriteVectorOrderBy returns the rewritten plan for Limit(Sort([Projection]([Filter](TableScan))))
+// ordered by ascending vector_distance on a column of the scanned table, or nil if the
+// plan does not have that shape.
+func (o *Optimizer) rewriteVectorOrderBy(limit *LimitNode) PlanNode {
+	sort, ok := limit.Input.(*SortNode)
+	if !ok || len(sort.OrderBy) != 1 || sort.OrderBy[0].Direction != parser.OrderAsc {
+		return nil
+	}
+	if !o.isRowCount(limit.Limit) || (limit.Offset != nil && !o.isRowCount(limit.Offset)) {
+		return nil
+	}
+
+	projection, _ := sort.Input.(*ProjectionNode)
+	below := sort.Input
+	if projection != nil {
+		below = projection.Input
+	}
+	filter, _ := below.(*FilterNode)
+	if filter != nil {
+		below = filter.Input
+	}
+	scan, ok := below.(*TableScanNode)
+	if !ok || scan.Table == nil {
+		return nil
+	}
+
+	tableRef := scan.Alias
+	if tableRef == "" {
+		tableRef = scan.Table.Name
+	}
+	column, query := matchVectorDistance(sort.OrderBy[0].Expr, tableRef, scan.Table)
+	if column == "" {
+		return nil
+	}
+
+	// The index search returns only k rows, so it must apply the whole WHERE
+	// clause itself: a conjunct left to the filter above it would reject some
+	// of those rows and the query would return fewer than k
+	searchable := true
+	if filter != nil {
+		for _, c := range splitConjuncts(filter.Condition) {
+			if !referencesOnlyTable(c, tableRef, scan.Table) && !isRowIndependent(c) {
+				searchable = false
+				break
+			}
+		}
+	}
+
+	// With a cosine HNSW index on the column, search the index instead of scanning
+	if o.catalog != nil && searchable {
+		if idx := FindVectorIndex(o.catalog, scan.Table.Name, column); idx != nil &&
+			(idx.HNSWParams == nil || idx.HNSWParams.DistanceMetric == schema.DistanceMetricCosine) {
+			vscan := &VectorIndexScanNode{
+				Table:     scan.Table,
+				Alias:     scan.Alias,
+				IndexName: idx.Name,
+				Column:    column,
+				Query:     query,
+				Limit:     limit.Limit,
+				Offset:    limit.Offset,
+			}
+			if filter != nil {
+				vscan.Filter = filter.Condition
+			}
+
+			// The index returns rows in distance order, so the sort is dropped
+			var input PlanNode = vscan
+			if filter != nil {
+				filter.Input = input
+				input = filter
+			}
+			if projection != nil {
+				projection.Input = input
+				input = projection
+			}
+			limit.Input = input
+			return limit
+		}
+	}
+
+	// Otherwise keep only the best k rows while scanning. The top-k runs below the
+	// projection because the distance may use columns the query does not select.
+	topK := &TopKNode{
+		OrderBy: sort.OrderBy,
+		Limit:   limit.Limit,
+		Offset:  limit.Offset,
+	}
+	if projection != nil {
+		topK.Input = projection.Input
+		projection.Input = topK
+		limit.Input = projection
+	} else {
+		topK.Input = sort.Input
+		limit.Input = topK
+	}
+	return limit
+}
+
+// matchVectorDistance checks whether expr is vector_distance(column, query) (in either
+// argument order) where column belongs to table and query does not depend on the row.
+// Returns the column name and the query expression, or "" and nil.
+func matchVectorDistance(expr parser.Expression, tableRef string, table *schema.TableDef) (string, parser.Expression) {
+	fn, ok := expr.(*parser.FunctionCall)
+	if !ok || !strings.EqualFold(fn.Name, "vector_distance") || len(fn.Args) != 2 {
+		return "", nil
+	}
+
+	for i, arg := range fn.Args {
+		ref, ok := arg.(*parser.ColumnRef)
+		if !ok {
+			continue
+		}
+		qualifier, name := splitColumnRef(ref.Name)
+		if qualifier != "" && !strings.EqualFold(qualifier, tableRef) {
+			continue
+		}
+		col, _ := table.GetColumn(name)
+		if col == nil || col.Type != types.TypeVector {
+			continue
+		}
+		query := fn.Args[1-i]
+		if !isRowIndependent(query) {
+			return "", nil
+		}
+		return col.Name, query
+	}
+	return "", nil
+}
+
+// isRowIndependent reports whether expr can be evaluated once per query:
+// it contains no column references or subqueries.
+func isRowIndependent(expr parser.Expression) bool {
+	switch ex := expr.(type) {
+	case *parser.Literal, *parser.Placeholder:
+		return true
+	case *parser.UnaryExpr:
+		return isRowIndependent(ex.Right)
+	case *parser.CastExpr:
+		return isRowIndependent(ex.Expr)
+	case *parser.BinaryExpr:
+		return isRowIndependent(ex.Left) && isRowIndependent(ex.Right)
+	case *parser.FunctionCall:
+		for _, arg := range ex.Args {
+			if !isRowIndependent(arg) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isRowCount reports whether expr is a non-negative integer literal, or a
+// parameter bound to one
+func (o *Optimizer) isRowCount(expr parser.Expression) bool {
+	value, ok := parser.ConstantValue(expr, o.params)
+	return ok && types.IsIntegerType(value.Type()) && value.Int() >= 0
+}
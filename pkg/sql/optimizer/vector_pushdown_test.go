@@ -0,0 +1,273 @@
+// pkg/sql/optimizer/vector_pushdown_test.go
+package optimizer
+
+import (
+	"testing"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/lexer"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// vectorJoinPlan builds Filter(Join(vector_quantize_scan('docs', ...) v, docs d ON on)) with the given WHERE
+func vectorJoinPlan(on, where parser.Expression) (*FilterNode, *TableFunctionNode) {
+	docs := &schema.TableDef{
+		Name: "docs",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32, PrimaryKey: true},
+			{Name: "tenant", Type: types.TypeInt32},
+			{Name: "embedding", Type: types.TypeVector, VectorDim: 2},
+		},
+	}
+	fn := &TableFunctionNode{
+		Name:  "vector_quantize_scan",
+		Args:  []parser.Expression{&parser.Literal{Value: types.NewText("docs")}, &parser.Literal{Value: types.NewText("embedding")}},
+		Alias: "v",
+	}
+	join := &NestedLoopJoinNode{
+		Left:      fn,
+		Right:     &TableScanNode{Table: docs, Alias: "d"},
+		Condition: on,
+		JoinType:  parser.JoinInner,
+	}
+	return &FilterNode{Input: join, Condition: where, Selectivity: 0.1}, fn
+}
+
+func eq(left, right parser.Expression) parser.Expression {
+	return &parser.BinaryExpr{Left: left, Op: lexer.EQ, Right: right}
+}
+
+func col(name string) parser.Expression {
+	return &parser.ColumnRef{Name: name}
+}
+
+func intLit(v int64) parser.Expression {
+	return &parser.Literal{Value: types.NewInt(v)}
+}
+
+func TestVectorFilterPushdown(t *testing.T) {
+	rowIDJoin := eq(col("d.id"), col("v.rowid"))
+	tenantFilter := eq(col("d.tenant"), intLit(7))
+
+	plan, fn := vectorJoinPlan(rowIDJoin, tenantFilter)
+	optimized := NewOptimizer().ApplyVectorFilterPushdown(plan)
+
+	if _, ok := optimized.(*FilterNode); !ok {
+		t.Fatalf("expected original filter to be kept, got %T", optimized)
+	}
+	if fn.Filter != tenantFilter {
+		t.Errorf("expected tenant predicate to be pushed, got %#v", fn.Filter)
+	}
+	if fn.FilterTable == nil || fn.FilterTable.Name != "docs" || fn.FilterAlias != "d" {
+		t.Errorf("expected filter table docs AS d, got %v AS %q", fn.FilterTable, fn.FilterAlias)
+	}
+}
+
+func TestVectorFilterPushdown_JoinInWhere(t *testing.T) {
+	// FROM vector_quantize_scan(...) v, docs d WHERE v.rowid = d.id AND d.tenant = 7 AND v.distance < 1
+	where := &parser.BinaryExpr{
+		Left: &parser.BinaryExpr{
+			Left:  eq(col("v.rowid"), col("d.id")),
+			Op:    lexer.AND,
+			Right: eq(col("d.tenant"), intLit(7)),
+		},
+		Op:    lexer.AND,
+		Right: &parser.BinaryExpr{Left: col("v.distance"), Op: lexer.LT, Right: intLit(1)},
+	}
+
+	plan, fn := vectorJoinPlan(nil, where)
+	NewOptimizer().ApplyVectorFilterPushdown(plan)
+
+	bin, ok := fn.Filter.(*parser.BinaryExpr)
+	if !ok || bin.Op != lexer.EQ {
+		t.Fatalf("expected only the tenant predicate to be pushed, got %#v", fn.Filter)
+	}
+	if ref, ok := bin.Left.(*parser.ColumnRef); !ok || ref.Name != "d.tenant" {
+		t.Errorf("expected d.tenant predicate, got %#v", bin.Left)
+	}
+}
+
+func TestVectorFilterPushdown_NotApplied(t *testing.T) {
+	tenantFilter := eq(col("d.tenant"), intLit(7))
+
+	tests := []struct {
+		name  string
+		on    parser.Expression
+		where parser.Expression
+	}{
+		{"not joined on rowid", eq(col("d.tenant"), col("v.rowid")), tenantFilter},
+		{"no join condition", nil, tenantFilter},
+		{"filter on scan output", eq(col("d.id"), col("v.rowid")), eq(col("v.distance"), intLit(0))},
+		{"filter on other table", eq(col("d.id"), col("v.rowid")), eq(col("u.tenant"), intLit(7))},
+		{"subquery", eq(col("d.id"), col("v.rowid")), &parser.InExpr{Left: col("d.tenant"), Subquery: &parser.SelectStmt{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, fn := vectorJoinPlan(tt.on, tt.where)
+			NewOptimizer().ApplyVectorFilterPushdown(plan)
+			if fn.Filter != nil {
+				t.Errorf("expected no pushdown, got %#v", fn.Filter)
+			}
+		})
+	}
+
+	// Outer joins keep unmatched rows, so their filters must not restrict the search
+	plan, fn := vectorJoinPlan(eq(col("d.id"), col("v.rowid")), tenantFilter)
+	plan.Input.(*NestedLoopJoinNode).JoinType = parser.JoinLeft
+	NewOptimizer().ApplyVectorFilterPushdown(plan)
+	if fn.Filter != nil {
+		t.Errorf("expected no pushdown through LEFT JOIN, got %#v", fn.Filter)
+	}
+}
+
+// vectorOrderByPlan builds Limit(Sort(Projection(Filter(TableScan docs)))) ordered by orderBy
+func vectorOrderByPlan(docs *schema.TableDef, orderBy parser.OrderByExpr, limit parser.Expression) *LimitNode {
+	var node PlanNode = &TableScanNode{Table: docs}
+	node = &FilterNode{Input: node, Condition: eq(col("tenant"), intLit(2)), Selectivity: 0.1}
+	node = &ProjectionNode{Input: node, Expressions: []parser.Expression{col("id")}}
+	node = &SortNode{Input: node, OrderBy: []parser.OrderByExpr{orderBy}}
+	return &LimitNode{Input: node, Limit: limit}
+}
+
+func vectorDistance(args ...parser.Expression) parser.Expression {
+	return &parser.FunctionCall{Name: "VECTOR_DISTANCE", Args: args}
+}
+
+func docsCatalog(metric schema.DistanceMetric) (*schema.Catalog, *schema.TableDef) {
+	catalog := schema.NewCatalog()
+	docs := &schema.TableDef{
+		Name: "docs",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32, PrimaryKey: true},
+			{Name: "tenant", Type: types.TypeInt32},
+			{Name: "embedding", Type: types.TypeVector, VectorDim: 2},
+		},
+	}
+	catalog.CreateTable(docs)
+	params := schema.DefaultHNSWParams()
+	params.DistanceMetric = metric
+	catalog.CreateIndex(&schema.IndexDef{
+		Name:       "emb_idx",
+		TableName:  "docs",
+		Columns:    []string{"embedding"},
+		Type:       schema.IndexTypeHNSW,
+		HNSWParams: params,
+	})
+	return catalog, docs
+}
+
+func TestVectorOrderBy_IndexSearch(t *testing.T) {
+	catalog, docs := docsCatalog(schema.DistanceMetricCosine)
+	query := &parser.Literal{Value: types.NewBlob([]byte{0})}
+	plan := vectorOrderByPlan(docs, parser.OrderByExpr{Expr: vectorDistance(col("embedding"), query)}, intLit(5))
+
+	opt := NewOptimizer()
+	opt.SetCatalog(catalog)
+	optimized := opt.ApplyVectorOrderBy(plan)
+
+	// Limit(Projection(Filter(VectorIndexScan))): the sort is gone
+	limit := optimized.(*LimitNode)
+	projection, ok := limit.Input.(*ProjectionNode)
+	if !ok {
+		t.Fatalf("expected projection under limit, got %T", limit.Input)
+	}
+	filter, ok := projection.Input.(*FilterNode)
+	if !ok {
+		t.Fatalf("expected filter under projection, got %T", projection.Input)
+	}
+	vscan, ok := filter.Input.(*VectorIndexScanNode)
+	if !ok {
+		t.Fatalf("expected VectorIndexScanNode, got %T", filter.Input)
+	}
+	if vscan.IndexName != "emb_idx" || vscan.Column != "embedding" || vscan.Query != query || vscan.Limit != limit.Limit {
+		t.Errorf("unexpected vector scan %+v", vscan)
+	}
+	if vscan.Filter != filter.Condition {
+		t.Errorf("expected WHERE clause to be evaluated during the search")
+	}
+}
+
+func TestVectorOrderBy_TopK(t *testing.T) {
+	// A euclidean index cannot order by vector_distance, which is cosine
+	catalog, docs := docsCatalog(schema.DistanceMetricEuclidean)
+	orderBy := parser.OrderByExpr{Expr: vectorDistance(&parser.Literal{Value: types.NewBlob([]byte{0})}, col("docs.embedding"))}
+	plan := vectorOrderByPlan(docs, orderBy, intLit(5))
+
+	opt := NewOptimizer()
+	opt.SetCatalog(catalog)
+	optimized := opt.ApplyVectorOrderBy(plan)
+
+	// Limit(Projection(TopK(Filter(Scan)))): top-k runs below the projection
+	limit := optimized.(*LimitNode)
+	projection, ok := limit.Input.(*ProjectionNode)
+	if !ok {
+		t.Fatalf("expected projection under limit, got %T", limit.Input)
+	}
+	topK, ok := projection.Input.(*TopKNode)
+	if !ok {
+		t.Fatalf("expected TopKNode under projection, got %T", projection.Input)
+	}
+	if _, ok := topK.Input.(*FilterNode); !ok {
+		t.Errorf("expected filter under top-k, got %T", topK.Input)
+	}
+	if topK.Limit != limit.Limit || len(topK.OrderBy) != 1 {
+		t.Errorf("unexpected top-k %+v", topK)
+	}
+}
+
+func TestVectorOrderBy_ResidualFilter(t *testing.T) {
+	catalog, docs := docsCatalog(schema.DistanceMetricCosine)
+	query := &parser.Literal{Value: types.NewBlob([]byte{0})}
+	plan := vectorOrderByPlan(docs, parser.OrderByExpr{Expr: vectorDistance(col("embedding"), query)}, intLit(5))
+
+	// The search cannot evaluate the subquery, and filtering its k rows
+	// afterwards could return fewer than k, so the plan scans for the top k
+	filter := plan.Input.(*SortNode).Input.(*ProjectionNode).Input.(*FilterNode)
+	inSubquery := &parser.InExpr{Left: col("id"), Subquery: &parser.SelectStmt{}}
+	filter.Condition = &parser.BinaryExpr{Left: filter.Condition, Op: lexer.AND, Right: inSubquery}
+
+	opt := NewOptimizer()
+	opt.SetCatalog(catalog)
+	optimized := opt.ApplyVectorOrderBy(plan)
+
+	projection := optimized.(*LimitNode).Input.(*ProjectionNode)
+	topK, ok := projection.Input.(*TopKNode)
+	if !ok {
+		t.Fatalf("expected TopKNode under projection, got %T", projection.Input)
+	}
+	if topK.Input != filter {
+		t.Errorf("expected the whole filter under top-k, got %T", topK.Input)
+	}
+}
+
+func TestVectorOrderBy_NotApplied(t *testing.T) {
+	catalog, docs := docsCatalog(schema.DistanceMetricCosine)
+	query := &parser.Literal{Value: types.NewBlob([]byte{0})}
+
+	tests := []struct {
+		name    string
+		orderBy parser.OrderByExpr
+		limit   parser.Expression
+	}{
+		{"descending", parser.OrderByExpr{Expr: vectorDistance(col("embedding"), query), Direction: parser.OrderDesc}, intLit(5)},
+		{"not a vector column", parser.OrderByExpr{Expr: vectorDistance(col("tenant"), query)}, intLit(5)},
+		{"query depends on row", parser.OrderByExpr{Expr: vectorDistance(col("embedding"), col("embedding"))}, intLit(5)},
+		{"other table", parser.OrderByExpr{Expr: vectorDistance(col("u.embedding"), query)}, intLit(5)},
+		{"other function", parser.OrderByExpr{Expr: &parser.FunctionCall{Name: "LENGTH", Args: []parser.Expression{col("embedding")}}}, intLit(5)},
+		{"negative limit", parser.OrderByExpr{Expr: vectorDistance(col("embedding"), query)}, intLit(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := vectorOrderByPlan(docs, tt.orderBy, tt.limit)
+			opt := NewOptimizer()
+			opt.SetCatalog(catalog)
+			optimized := opt.ApplyVectorOrderBy(plan)
+			if _, ok := optimized.(*LimitNode).Input.(*SortNode); !ok {
+				t.Errorf("expected sort to be kept, got %T", optimized.(*LimitNode).Input)
+			}
+		})
+	}
+}
@@ -131,15 +131,17 @@ func (j *Join) tableRefNode() {}
 
 // SelectStmt represents a SELECT statement
 type SelectStmt struct {
-	With    *WithClause    // optional WITH clause for CTEs
-	Columns []SelectColumn // * or column list
-	From    TableReference
-	Where   Expression    // optional WHERE clause (nil if none)
-	GroupBy []Expression  // optional GROUP BY clause
-	Having  Expression    // optional HAVING clause (nil if none)
-	OrderBy []OrderByExpr // optional ORDER BY clause
-	Limit   Expression    // optional LIMIT expression
-	Offset  Expression    // optional OFFSET expression
+	With       *WithClause    // optional WITH clause for CTEs
+	Distinct   bool           // SELECT DISTINCT: drop duplicate result rows
+	DistinctOn []Expression   // SELECT DISTINCT ON (...): keep the first row per value (nil if none)
+	Columns    []SelectColumn // * or column list
+	From       TableReference
+	Where      Expression    // optional WHERE clause (nil if none)
+	GroupBy    []Expression  // optional GROUP BY clause
+	Having     Expression    // optional HAVING clause (nil if none)
+	OrderBy    []OrderByExpr // optional ORDER BY clause
+	Limit      Expression    // optional LIMIT expression
+	Offset     Expression    // optional OFFSET expression
 }
 
 func (s *SelectStmt) statementNode() {}
@@ -162,16 +164,24 @@ func (s *DropTableStmt) statementNode() {}
 
 // CreateIndexStmt represents a CREATE INDEX statement
 type CreateIndexStmt struct {
-	IndexName   string       // Name of the index
-	TableName   string       // Table to create index on
-	Columns     []string     // Plain column names to index (for simple column references)
-	Expressions []Expression // Expression indexes (e.g., UPPER(name), price * quantity)
-	Unique      bool         // Whether this is a UNIQUE index
-	Where       Expression   // Optional WHERE clause for partial indexes (nil if none)
+	IndexName   string        // Name of the index
+	TableName   string        // Table to create index on
+	Columns     []string      // Plain column names to index (for simple column references)
+	Expressions []Expression  // Expression indexes (e.g., UPPER(name), price * quantity)
+	Unique      bool          // Whether this is a UNIQUE index
+	Where       Expression    // Optional WHERE clause for partial indexes (nil if none)
+	Using       string        // Index method from USING clause, upper-cased (e.g. "HNSW"); empty for B-tree
+	Options     []IndexOption // Index parameters from WITH (name = value, ...)
 }
 
 func (s *CreateIndexStmt) statementNode() {}
 
+// IndexOption represents a single name = value parameter in CREATE INDEX ... WITH (...)
+type IndexOption struct {
+	Name  string     // Parameter name, lower-cased
+	Value Expression // Parameter value
+}
+
 // DropIndexStmt represents a DROP INDEX statement
 type DropIndexStmt struct {
 	IndexName string
@@ -187,13 +197,31 @@ type Literal struct {
 
 func (l *Literal) expressionNode() {}
 
-// Placeholder represents a ? parameter placeholder in prepared statements
+// Placeholder represents a parameter placeholder in prepared statements:
+// ?, a named parameter (:name or @name) or a numbered one ($1)
 type Placeholder struct {
-	Index int // 1-based parameter index
+	Index int    // 1-based parameter index
+	Name  string // parameter name with its prefix, empty for ?
 }
 
 func (p *Placeholder) expressionNode() {}
 
+// ConstantValue returns the value of expr if it is known before any row is
+// read: the value of a literal, or the value bound to a placeholder in
+// params. ok is false for other expressions and for placeholders past the
+// end of params.
+func ConstantValue(expr Expression, params []types.Value) (value types.Value, ok bool) {
+	switch e := expr.(type) {
+	case *Literal:
+		return e.Value, true
+	case *Placeholder:
+		if e.Index > 0 && e.Index <= len(params) {
+			return params[e.Index-1], true
+		}
+	}
+	return types.Value{}, false
+}
+
 // ColumnRef represents a column reference
 type ColumnRef struct {
 	Name string
@@ -220,8 +248,12 @@ func (u *UnaryExpr) expressionNode() {}
 
 // FunctionCall represents a function call expression
 type FunctionCall struct {
-	Name string
-	Args []Expression
+	Name        string
+	Args        []Expression
+	Distinct    bool          // aggregate over distinct argument values, e.g. COUNT(DISTINCT x)
+	OrderBy     []OrderByExpr // order an aggregate sees its rows in, e.g. STRING_AGG(x, ',' ORDER BY y)
+	WithinGroup bool          // OrderBy came from WITHIN GROUP (ORDER BY ...) of an ordered-set aggregate
+	Filter      Expression    // FILTER (WHERE ...): only rows where it is true are aggregated
 }
 
 func (f *FunctionCall) expressionNode() {}
@@ -387,6 +419,15 @@ type PragmaStmt struct {
 
 func (s *PragmaStmt) statementNode() {}
 
+// VacuumStmt represents a VACUUM statement, which rebuilds the database
+// without free pages. With INTO, the compacted copy is written to a new file
+// and the database itself is left unchanged.
+type VacuumStmt struct {
+	Into string // Optional target file for VACUUM INTO
+}
+
+func (s *VacuumStmt) statementNode() {}
+
 // Assignment represents a column = expression assignment in UPDATE
 type Assignment struct {
 	Column string     // Column name to update
@@ -431,15 +472,23 @@ const (
 	AlterActionAddColumn AlterAction = iota
 	AlterActionDropColumn
 	AlterActionRenameTable
+	AlterActionRenameColumn
+	AlterActionAlterColumnType
+	AlterActionSetDefault
+	AlterActionDropDefault
+	AlterActionSetNotNull
+	AlterActionDropNotNull
 )
 
 // AlterTableStmt represents an ALTER TABLE statement
 type AlterTableStmt struct {
-	TableName  string      // Table to alter
-	Action     AlterAction // Type of alteration
-	NewColumn  *ColumnDef  // For ADD COLUMN: the new column definition
-	ColumnName string      // For DROP COLUMN: column to drop
-	NewName    string      // For RENAME TO: new table name
+	TableName   string      // Table to alter
+	Action      AlterAction // Type of alteration
+	NewColumn   *ColumnDef  // For ADD COLUMN: the new column definition; for ALTER COLUMN TYPE: the column with its new type
+	ColumnName  string      // For DROP, RENAME and ALTER COLUMN: column to change
+	NewName     string      // For RENAME TO: new table name; for RENAME COLUMN: new column name
+	DefaultExpr Expression  // For SET DEFAULT: the new default
+	Using       Expression  // For ALTER COLUMN TYPE: expression computing the new values, nil to convert the old ones
 }
 
 func (s *AlterTableStmt) statementNode() {}
@@ -478,6 +527,44 @@ type LikeExpr struct {
 
 func (l *LikeExpr) expressionNode() {}
 
+// IsNullExpr represents an IS NULL test (expr IS NULL or expr IS NOT NULL)
+type IsNullExpr struct {
+	Expr Expression // The expression being tested
+	Not  bool       // True for IS NOT NULL
+}
+
+func (i *IsNullExpr) expressionNode() {}
+
+// IsDistinctExpr represents a NULL-safe comparison
+// (expr IS DISTINCT FROM expr or expr IS NOT DISTINCT FROM expr).
+// Unlike = and <>, it treats two NULLs as equal and never yields NULL.
+type IsDistinctExpr struct {
+	Left  Expression
+	Not   bool // True for IS NOT DISTINCT FROM
+	Right Expression
+}
+
+func (i *IsDistinctExpr) expressionNode() {}
+
+// BetweenExpr represents a range test (expr BETWEEN low AND high or
+// expr NOT BETWEEN low AND high). Both bounds are inclusive.
+type BetweenExpr struct {
+	Expr Expression // The expression being tested
+	Not  bool       // True for NOT BETWEEN
+	Low  Expression
+	High Expression
+}
+
+func (b *BetweenExpr) expressionNode() {}
+
+// CastExpr represents a type conversion: CAST(expr AS type)
+type CastExpr struct {
+	Expr Expression
+	Type *TypeInfo // Target type, with its length, precision and scale
+}
+
+func (c *CastExpr) expressionNode() {}
+
 // WhenClause represents a WHEN clause in a CASE expression
 type WhenClause struct {
 	Condition Expression // WHEN condition (searched CASE) or value (simple CASE)
@@ -504,15 +591,22 @@ type DerivedTable struct {
 func (d *DerivedTable) tableRefNode() {}
 
 // TableFunction represents a table-valued function call in FROM clause
-// e.g., vector_quantize_scan('table', 'column', query_vec, k)
+// e.g., vector_quantize_scan('table', 'column', query_vec, k, ef => 200)
 type TableFunction struct {
-	Name  string       // Function name
-	Args  []Expression // Function arguments
-	Alias string       // Optional alias
+	Name      string       // Function name
+	Args      []Expression // Positional function arguments
+	NamedArgs []NamedArg   // Named arguments (name => value), written after positional ones
+	Alias     string       // Optional alias
 }
 
 func (tf *TableFunction) tableRefNode() {}
 
+// NamedArg represents a named function argument: name => value
+type NamedArg struct {
+	Name  string     // Argument name (lower-cased)
+	Value Expression // Argument value
+}
+
 // BeginStmt represents a BEGIN [TRANSACTION] statement
 type BeginStmt struct{}
 
@@ -15,7 +15,9 @@ type Parser struct {
 	lexer            *lexer.Lexer
 	cur              lexer.Token
 	peek             lexer.Token
-	placeholderIndex int // tracks the current ? placeholder index (1-based)
+	placeholderIndex int      // highest parameter index found so far (1-based)
+	placeholderNames []string // names of the parameters by index, empty for ?
+	callArgs         bool     // parsing the arguments of CALL, where @name is a session variable
 }
 
 // New creates a new Parser for the given SQL input
@@ -33,11 +35,41 @@ func (p *Parser) nextToken() {
 	p.peek = p.lexer.NextToken()
 }
 
-// PlaceholderCount returns the number of ? placeholders found during parsing
+// PlaceholderCount returns the number of parameters found during parsing
 func (p *Parser) PlaceholderCount() int {
 	return p.placeholderIndex
 }
 
+// PlaceholderNames returns the names of the parameters found during parsing,
+// with their prefix, by index. The name of a ? parameter is empty.
+func (p *Parser) PlaceholderNames() []string {
+	return p.placeholderNames
+}
+
+// placeholder returns the placeholder of the parameter at index, which may
+// be past the parameters found so far
+func (p *Parser) placeholder(index int, name string) *Placeholder {
+	for p.placeholderIndex < index {
+		p.placeholderIndex++
+		p.placeholderNames = append(p.placeholderNames, "")
+	}
+	if name != "" {
+		p.placeholderNames[index-1] = name
+	}
+	return &Placeholder{Index: index, Name: name}
+}
+
+// namedPlaceholder returns the placeholder of a named parameter. Every
+// occurrence of a name refers to the same parameter.
+func (p *Parser) namedPlaceholder(name string) *Placeholder {
+	for i, n := range p.placeholderNames {
+		if n == name {
+			return &Placeholder{Index: i + 1, Name: name}
+		}
+	}
+	return p.placeholder(p.placeholderIndex+1, name)
+}
+
 // Parse parses the input and returns a Statement
 func (p *Parser) Parse() (Statement, error) {
 	switch p.cur.Type {
@@ -81,6 +113,8 @@ func (p *Parser) Parse() (Statement, error) {
 		return p.parseSetStmt()
 	case lexer.PRAGMA:
 		return p.parsePragma()
+	case lexer.VACUUM:
+		return p.parseVacuum()
 	default:
 		return nil, fmt.Errorf("unexpected token: %s", p.cur.Literal)
 	}
@@ -1035,6 +1069,24 @@ func (p *Parser) parseSetOperations(left *SelectStmt) (Statement, error) {
 func (p *Parser) parseSelectBody() (*SelectStmt, error) {
 	stmt := &SelectStmt{}
 
+	// Optional DISTINCT [ON (expr, ...)] or ALL
+	switch p.cur.Type {
+	case lexer.DISTINCT:
+		if p.peekIs(lexer.ON) {
+			p.nextToken() // ON
+			distinctOn, err := p.parseDistinctOn()
+			if err != nil {
+				return nil, err
+			}
+			stmt.DistinctOn = distinctOn
+		} else {
+			stmt.Distinct = true
+		}
+		p.nextToken() // move to the first column
+	case lexer.ALL:
+		p.nextToken()
+	}
+
 	// Columns
 	cols, err := p.parseSelectColumns()
 	if err != nil {
@@ -1286,14 +1338,30 @@ func (p *Parser) parseTableFactor() (TableReference, error) {
 
 		// Parse arguments
 		var args []Expression
+		var namedArgs []NamedArg
 		if !p.peekIs(lexer.RPAREN) {
 			p.nextToken() // move to first argument
 			for {
-				arg, err := p.parseExpression(LOWEST)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse table function argument: %w", err)
+				if p.curIs(lexer.IDENT) && p.peekIs(lexer.FAT_ARROW) {
+					// Named argument: name => value
+					argName := strings.ToLower(p.cur.Literal)
+					p.nextToken() // consume =>
+					p.nextToken() // move to value
+					value, err := p.parseExpression(LOWEST)
+					if err != nil {
+						return nil, fmt.Errorf("failed to parse table function argument %s: %w", argName, err)
+					}
+					namedArgs = append(namedArgs, NamedArg{Name: argName, Value: value})
+				} else {
+					if len(namedArgs) > 0 {
+						return nil, fmt.Errorf("positional argument cannot follow named arguments in table function %s", name)
+					}
+					arg, err := p.parseExpression(LOWEST)
+					if err != nil {
+						return nil, fmt.Errorf("failed to parse table function argument: %w", err)
+					}
+					args = append(args, arg)
 				}
-				args = append(args, arg)
 
 				if !p.peekIs(lexer.COMMA) {
 					break
@@ -1307,7 +1375,7 @@ func (p *Parser) parseTableFactor() (TableReference, error) {
 			return nil, fmt.Errorf("expected ')' after table function arguments")
 		}
 
-		tableFunc := &TableFunction{Name: name, Args: args}
+		tableFunc := &TableFunction{Name: name, Args: args, NamedArgs: namedArgs}
 
 		// Parse alias
 		if p.peekIs(lexer.AS_KW) {
@@ -1397,6 +1465,34 @@ func (p *Parser) parseJoinType() JoinType {
 	}
 }
 
+// parseDistinctOn parses the expression list of DISTINCT ON: (expr, expr, ...)
+// Called with ON as the current token; leaves the closing parenthesis current.
+func (p *Parser) parseDistinctOn() ([]Expression, error) {
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil, fmt.Errorf("expected '(' after DISTINCT ON, got %s", p.peek.Literal)
+	}
+
+	var exprs []Expression
+	for {
+		p.nextToken() // move to expression start
+		expr, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		if !p.peekIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil, fmt.Errorf("expected ')' after DISTINCT ON expressions, got %s", p.peek.Literal)
+	}
+	return exprs, nil
+}
+
 // parseSelectColumns parses: * | column, column, ... | function(args), ...
 func (p *Parser) parseSelectColumns() ([]SelectColumn, error) {
 	var cols []SelectColumn
@@ -1498,6 +1594,15 @@ func (p *Parser) parseCreateIndex(unique bool) (*CreateIndexStmt, error) {
 	}
 	stmt.TableName = p.cur.Literal
 
+	// Optional USING method
+	if p.peekIs(lexer.USING) {
+		p.nextToken() // consume USING
+		if !p.expectPeek(lexer.IDENT) {
+			return nil, fmt.Errorf("expected index method after USING, got %s", p.peek.Literal)
+		}
+		stmt.Using = strings.ToUpper(p.cur.Literal)
+	}
+
 	// (
 	if !p.expectPeek(lexer.LPAREN) {
 		return nil, fmt.Errorf("expected '(', got %s", p.peek.Literal)
@@ -1516,6 +1621,16 @@ func (p *Parser) parseCreateIndex(unique bool) (*CreateIndexStmt, error) {
 		return nil, fmt.Errorf("expected ')' or ',', got %s", p.peek.Literal)
 	}
 
+	// Optional WITH (name = value, ...) index parameters
+	if p.peekIs(lexer.WITH) {
+		p.nextToken() // consume WITH
+		options, err := p.parseIndexOptions()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Options = options
+	}
+
 	// Optional WHERE clause for partial indexes
 	if p.peekIs(lexer.WHERE) {
 		p.nextToken() // consume WHERE
@@ -1530,6 +1645,42 @@ func (p *Parser) parseCreateIndex(unique bool) (*CreateIndexStmt, error) {
 	return stmt, nil
 }
 
+// parseIndexOptions parses: (name = value [, name = value ...])
+// Called when current token is WITH
+func (p *Parser) parseIndexOptions() ([]IndexOption, error) {
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil, fmt.Errorf("expected '(' after WITH, got %s", p.peek.Literal)
+	}
+
+	var options []IndexOption
+	for {
+		if !p.expectPeek(lexer.IDENT) {
+			return nil, fmt.Errorf("expected index option name, got %s", p.peek.Literal)
+		}
+		name := strings.ToLower(p.cur.Literal)
+
+		if !p.expectPeek(lexer.EQ) {
+			return nil, fmt.Errorf("expected '=' after %s, got %s", name, p.peek.Literal)
+		}
+		p.nextToken() // move to value
+		value, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for index option %s: %v", name, err)
+		}
+		options = append(options, IndexOption{Name: name, Value: value})
+
+		if !p.peekIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil, fmt.Errorf("expected ')' after index options, got %s", p.peek.Literal)
+	}
+	return options, nil
+}
+
 // parseIndexElements parses a list of index elements which can be:
 // - Plain column name: name
 // - Function call: UPPER(name), LOWER(email)
@@ -1683,7 +1834,9 @@ const (
 	LOWEST
 	OR_PREC  // OR
 	AND_PREC // AND
-	IN_PREC  // IN, NOT IN
+	NOT_PREC // NOT x
+	IS_PREC  // IS [NOT] NULL, IS [NOT] DISTINCT FROM
+	IN_PREC  // IN, NOT IN, LIKE, BETWEEN
 	EQUALS   // =, !=, <>, <, >, <=, >=
 	SUM      // +, -
 	PRODUCT  // *, /
@@ -1697,6 +1850,9 @@ var precedences = map[lexer.TokenType]int{
 	lexer.AND:     AND_PREC,
 	lexer.IN_KW:   IN_PREC,
 	lexer.LIKE_KW: IN_PREC, // LIKE has same precedence as IN
+	lexer.BETWEEN: IN_PREC,
+	lexer.NOT:     IN_PREC, // infix NOT IN, NOT LIKE, NOT BETWEEN
+	lexer.IS_KW:   IS_PREC,
 	lexer.EQ:      EQUALS,
 	lexer.NEQ:   EQUALS,
 	lexer.LT:    EQUALS,
@@ -1754,8 +1910,22 @@ func (p *Parser) parsePrefixExpression() (Expression, error) {
 		return &Literal{Value: types.NewNull()}, nil
 	case lexer.QUESTION:
 		// Parameter placeholder ?
-		p.placeholderIndex++
-		return &Placeholder{Index: p.placeholderIndex}, nil
+		return p.placeholder(p.placeholderIndex+1, ""), nil
+	case lexer.COLON:
+		// Named parameter :name
+		p.nextToken() // consume :
+		if p.cur.Type != lexer.IDENT {
+			return nil, fmt.Errorf("expected identifier after :, got %s", p.cur.Literal)
+		}
+		return p.namedPlaceholder(":" + p.cur.Literal), nil
+	case lexer.DOLLAR:
+		// Numbered parameter $1
+		p.nextToken() // consume $
+		index, err := strconv.Atoi(p.cur.Literal)
+		if p.cur.Type != lexer.INT || err != nil || index < 1 {
+			return nil, fmt.Errorf("expected parameter number after $, got %s", p.cur.Literal)
+		}
+		return p.placeholder(index, "$"+p.cur.Literal), nil
 	case lexer.TRUE_KW:
 		return &Literal{Value: types.NewInt(1)}, nil
 	case lexer.FALSE_KW:
@@ -1767,6 +1937,8 @@ func (p *Parser) parsePrefixExpression() (Expression, error) {
 		return p.parseExistsExpression(false)
 	case lexer.CASE:
 		return p.parseCaseExpression()
+	case lexer.CAST:
+		return p.parseCastExpression()
 	case lexer.IF:
 		// IF can be either an IF statement (in stored procedures) or IF() function
 		// If followed by '(', it's the IF() function
@@ -1781,10 +1953,11 @@ func (p *Parser) parsePrefixExpression() (Expression, error) {
 			p.nextToken() // consume NOT, move to EXISTS
 			return p.parseExistsExpression(true)
 		}
-		// NOT followed by expression
+		// NOT followed by expression; it binds looser than comparisons,
+		// so NOT a = b is NOT (a = b)
 		op := p.cur.Type
 		p.nextToken()
-		right, err := p.parsePrefixExpression()
+		right, err := p.parseExpression(NOT_PREC)
 		if err != nil {
 			return nil, err
 		}
@@ -1795,13 +1968,24 @@ func (p *Parser) parsePrefixExpression() (Expression, error) {
 			return p.parseFunctionCall()
 		}
 		return nil, fmt.Errorf("VALUES must be followed by '('")
+	case lexer.VECTOR:
+		// Handle VECTOR('[...]') function; VECTOR is otherwise a column type
+		if p.peekIs(lexer.LPAREN) {
+			return p.parseFunctionCall()
+		}
+		return nil, fmt.Errorf("VECTOR must be followed by '('")
 	case lexer.AT:
-		// Session variable: @var
+		// Named parameter @name, which reads the session variable of that
+		// name when the statement is executed without parameters
 		p.nextToken() // consume @
 		if p.cur.Type != lexer.IDENT {
 			return nil, fmt.Errorf("expected identifier after @, got %s", p.cur.Literal)
 		}
-		return &SessionVariable{Name: p.cur.Literal}, nil
+		if p.callArgs {
+			// Procedures write OUT parameters to session variables
+			return &SessionVariable{Name: p.cur.Literal}, nil
+		}
+		return p.namedPlaceholder("@" + p.cur.Literal), nil
 	case lexer.IDENT:
 		// Check if this is a function call (IDENT followed by LPAREN)
 		if p.peekIs(lexer.LPAREN) {
@@ -1873,6 +2057,17 @@ func (p *Parser) parseFunctionCall() (Expression, error) {
 		return p.maybeParseWindowFunction(funcCall)
 	}
 
+	// Handle DISTINCT/ALL before the arguments of an aggregate: COUNT(DISTINCT x)
+	if p.peekIs(lexer.DISTINCT) {
+		p.nextToken() // consume DISTINCT
+		funcCall.Distinct = true
+		if p.peekIs(lexer.STAR) || p.peekIs(lexer.RPAREN) {
+			return nil, fmt.Errorf("expected an argument after DISTINCT in %s()", funcCall.Name)
+		}
+	} else if p.peekIs(lexer.ALL) {
+		p.nextToken() // consume ALL
+	}
+
 	// Handle COUNT(*) special case
 	if p.peekIs(lexer.STAR) {
 		p.nextToken() // consume *
@@ -1900,6 +2095,19 @@ func (p *Parser) parseFunctionCall() (Expression, error) {
 		p.nextToken() // move to next argument
 	}
 
+	// Aggregates may order their input: STRING_AGG(x, ',' ORDER BY y)
+	if p.peekIs(lexer.ORDER) {
+		p.nextToken() // consume ORDER
+		if !p.expectPeek(lexer.BY) {
+			return nil, fmt.Errorf("expected BY after ORDER in %s()", funcCall.Name)
+		}
+		orderBy, err := p.parseOrderByList()
+		if err != nil {
+			return nil, err
+		}
+		funcCall.OrderBy = orderBy
+	}
+
 	if !p.expectPeek(lexer.RPAREN) {
 		return nil, fmt.Errorf("expected ')' or ',' in function call")
 	}
@@ -1922,14 +2130,74 @@ func (p *Parser) parseFunctionCall() (Expression, error) {
 // maybeParseWindowFunction checks if the function call is followed by OVER clause
 // and converts it to a WindowFunction if so
 func (p *Parser) maybeParseWindowFunction(funcCall *FunctionCall) (Expression, error) {
+	if err := p.parseAggregateClauses(funcCall); err != nil {
+		return nil, err
+	}
+
 	// Check if followed by OVER keyword
 	if !p.peekIs(lexer.OVER) {
 		return funcCall, nil
 	}
+	if funcCall.Distinct {
+		return nil, fmt.Errorf("DISTINCT is not supported in window function %s()", funcCall.Name)
+	}
 
 	return p.parseWindowFunction(funcCall)
 }
 
+// parseAggregateClauses parses the clauses that may follow the arguments of
+// an aggregate: WITHIN GROUP (ORDER BY expr) and FILTER (WHERE condition)
+func (p *Parser) parseAggregateClauses(funcCall *FunctionCall) error {
+	if p.peekIsWord("WITHIN") {
+		p.nextToken() // consume WITHIN
+		if len(funcCall.OrderBy) > 0 {
+			return fmt.Errorf("%s() cannot have both ORDER BY and WITHIN GROUP", funcCall.Name)
+		}
+		if !p.expectPeek(lexer.GROUP) {
+			return fmt.Errorf("expected GROUP after WITHIN, got %s", p.peek.Literal)
+		}
+		if !p.expectPeek(lexer.LPAREN) {
+			return fmt.Errorf("expected '(' after WITHIN GROUP, got %s", p.peek.Literal)
+		}
+		if !p.expectPeek(lexer.ORDER) || !p.expectPeek(lexer.BY) {
+			return fmt.Errorf("expected ORDER BY in WITHIN GROUP, got %s", p.peek.Literal)
+		}
+		orderBy, err := p.parseOrderByList()
+		if err != nil {
+			return err
+		}
+		if len(orderBy) != 1 {
+			return fmt.Errorf("WITHIN GROUP of %s() takes exactly one ORDER BY expression", funcCall.Name)
+		}
+		if !p.expectPeek(lexer.RPAREN) {
+			return fmt.Errorf("expected ')' after WITHIN GROUP, got %s", p.peek.Literal)
+		}
+		funcCall.OrderBy = orderBy
+		funcCall.WithinGroup = true
+	}
+
+	if p.peekIsWord("FILTER") {
+		p.nextToken() // consume FILTER
+		if !p.expectPeek(lexer.LPAREN) {
+			return fmt.Errorf("expected '(' after FILTER, got %s", p.peek.Literal)
+		}
+		if !p.expectPeek(lexer.WHERE) {
+			return fmt.Errorf("expected WHERE after FILTER (, got %s", p.peek.Literal)
+		}
+		p.nextToken() // move to the condition
+		cond, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return err
+		}
+		if !p.expectPeek(lexer.RPAREN) {
+			return fmt.Errorf("expected ')' after FILTER condition, got %s", p.peek.Literal)
+		}
+		funcCall.Filter = cond
+	}
+
+	return nil
+}
+
 // parseWindowSpec parses a window specification: (PARTITION BY ... ORDER BY ...)
 func (p *Parser) parseWindowSpec() (*WindowSpec, error) {
 	if !p.expectPeek(lexer.LPAREN) {
@@ -2257,6 +2525,24 @@ func (p *Parser) parseInfixExpression(left Expression) (Expression, error) {
 		return p.parseLikeExpression(left, true)
 	}
 
+	// Handle BETWEEN: expr [NOT] BETWEEN low AND high
+	if p.cur.Type == lexer.BETWEEN {
+		return p.parseBetweenExpression(left, false)
+	}
+	if p.cur.Type == lexer.NOT && p.peekIs(lexer.BETWEEN) {
+		p.nextToken() // consume NOT, now on BETWEEN
+		return p.parseBetweenExpression(left, true)
+	}
+
+	if p.cur.Type == lexer.NOT {
+		return nil, fmt.Errorf("expected IN, LIKE or BETWEEN after NOT, got %s", p.peek.Literal)
+	}
+
+	// Handle IS: expr IS [NOT] NULL, expr IS [NOT] DISTINCT FROM expr
+	if p.cur.Type == lexer.IS_KW {
+		return p.parseIsExpression(left)
+	}
+
 	// Handle JSON operators -> and ->>
 	// Convert them to function calls: JSON_EXTRACT and JSON_UNQUOTE(JSON_EXTRACT(...))
 	if p.cur.Type == lexer.ARROW {
@@ -2471,6 +2757,97 @@ func (p *Parser) parseLikeExpression(left Expression, notLike bool) (Expression,
 	}, nil
 }
 
+// parseBetweenExpression parses: expr [NOT] BETWEEN low AND high
+func (p *Parser) parseBetweenExpression(left Expression, notBetween bool) (Expression, error) {
+	// Current token is BETWEEN. The bounds bind tighter than AND so that
+	// the AND separating them is not parsed as a logical AND.
+	p.nextToken() // move to low bound
+	low, err := p.parseExpression(IN_PREC)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.expectPeek(lexer.AND) {
+		return nil, fmt.Errorf("expected AND in BETWEEN, got %s", p.peek.Literal)
+	}
+	p.nextToken() // move to high bound
+
+	high, err := p.parseExpression(IN_PREC)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BetweenExpr{
+		Expr: left,
+		Not:  notBetween,
+		Low:  low,
+		High: high,
+	}, nil
+}
+
+// parseIsExpression parses: expr IS [NOT] NULL or
+// expr IS [NOT] DISTINCT FROM expr
+func (p *Parser) parseIsExpression(left Expression) (Expression, error) {
+	// Current token is IS
+	not := false
+	if p.peekIs(lexer.NOT) {
+		p.nextToken() // consume NOT
+		not = true
+	}
+
+	switch {
+	case p.peekIs(lexer.NULL_KW):
+		p.nextToken() // consume NULL
+		return &IsNullExpr{Expr: left, Not: not}, nil
+
+	case p.peekIs(lexer.DISTINCT):
+		p.nextToken() // consume DISTINCT
+		if !p.expectPeek(lexer.FROM) {
+			return nil, fmt.Errorf("expected FROM after IS DISTINCT, got %s", p.peek.Literal)
+		}
+		p.nextToken() // move to right operand
+
+		right, err := p.parseExpression(IS_PREC)
+		if err != nil {
+			return nil, err
+		}
+		return &IsDistinctExpr{Left: left, Not: not, Right: right}, nil
+
+	default:
+		return nil, fmt.Errorf("expected NULL or DISTINCT FROM after IS, got %s", p.peek.Literal)
+	}
+}
+
+// parseCastExpression parses: CAST(expr AS type)
+func (p *Parser) parseCastExpression() (Expression, error) {
+	// Current token is CAST
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil, fmt.Errorf("expected '(' after CAST, got %s", p.peek.Literal)
+	}
+	p.nextToken() // move to expression
+
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.expectPeek(lexer.AS_KW) {
+		return nil, fmt.Errorf("expected AS in CAST, got %s", p.peek.Literal)
+	}
+	p.nextToken() // move to type
+
+	typeInfo, err := p.parseColumnTypeInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil, fmt.Errorf("expected ')' after CAST type, got %s", p.peek.Literal)
+	}
+
+	return &CastExpr{Expr: expr, Type: typeInfo}, nil
+}
+
 // parseIntLiteral parses an integer literal
 // Integer literals are parsed as TypeInt32 (4-byte signed integer) by default.
 // This ensures consistency with INT/INTEGER column types which also use TypeInt32.
@@ -2523,6 +2900,12 @@ func (p *Parser) peekIs(t lexer.TokenType) bool {
 	return p.peek.Type == t
 }
 
+// peekIsWord reports whether the next token is the identifier word, for
+// words that are only keywords in one place, like FILTER after an aggregate
+func (p *Parser) peekIsWord(word string) bool {
+	return p.peek.Type == lexer.IDENT && strings.EqualFold(p.peek.Literal, word)
+}
+
 func (p *Parser) expectPeek(t lexer.TokenType) bool {
 	if p.peekIs(t) {
 		p.nextToken()
@@ -2568,8 +2951,10 @@ func (p *Parser) parseAlter() (Statement, error) {
 		return p.parseAlterTableDropColumn(tableName)
 	case lexer.RENAME:
 		return p.parseAlterTableRename(tableName)
+	case lexer.ALTER:
+		return p.parseAlterTableAlterColumn(tableName)
 	default:
-		return nil, fmt.Errorf("expected ADD, DROP, or RENAME after table name, got %s", p.cur.Literal)
+		return nil, fmt.Errorf("expected ADD, DROP, RENAME, or ALTER after table name, got %s", p.cur.Literal)
 	}
 }
 
@@ -2618,12 +3003,17 @@ func (p *Parser) parseAlterTableDropColumn(tableName string) (*AlterTableStmt, e
 }
 
 // parseAlterTableRename parses: RENAME TO new_table_name
+// or RENAME [COLUMN] column_name TO new_column_name
 func (p *Parser) parseAlterTableRename(tableName string) (*AlterTableStmt, error) {
 	stmt := &AlterTableStmt{
 		TableName: tableName,
 		Action:    AlterActionRenameTable,
 	}
 
+	if !p.peekIs(lexer.TO) {
+		return p.parseAlterTableRenameColumn(tableName)
+	}
+
 	// TO keyword
 	if !p.expectPeek(lexer.TO) {
 		return nil, fmt.Errorf("expected TO after RENAME, got %s", p.peek.Literal)
@@ -2638,6 +3028,119 @@ func (p *Parser) parseAlterTableRename(tableName string) (*AlterTableStmt, error
 	return stmt, nil
 }
 
+// parseAlterTableRenameColumn parses: RENAME [COLUMN] column_name TO new_column_name
+func (p *Parser) parseAlterTableRenameColumn(tableName string) (*AlterTableStmt, error) {
+	stmt := &AlterTableStmt{
+		TableName: tableName,
+		Action:    AlterActionRenameColumn,
+	}
+
+	// Optional COLUMN keyword
+	if p.peekIs(lexer.COLUMN) {
+		p.nextToken() // consume COLUMN
+	}
+
+	// Column name
+	if !p.expectPeek(lexer.IDENT) {
+		return nil, fmt.Errorf("expected TO or column name after RENAME, got %s", p.peek.Literal)
+	}
+	stmt.ColumnName = p.cur.Literal
+
+	if !p.expectPeek(lexer.TO) {
+		return nil, fmt.Errorf("expected TO after column name, got %s", p.peek.Literal)
+	}
+
+	// New column name
+	if !p.expectPeek(lexer.IDENT) {
+		return nil, fmt.Errorf("expected new column name after TO, got %s", p.peek.Literal)
+	}
+	stmt.NewName = p.cur.Literal
+
+	return stmt, nil
+}
+
+// parseAlterTableAlterColumn parses: ALTER [COLUMN] column_name followed by
+// [SET DATA] TYPE type [USING expr], SET DEFAULT expr, DROP DEFAULT,
+// SET NOT NULL or DROP NOT NULL
+func (p *Parser) parseAlterTableAlterColumn(tableName string) (*AlterTableStmt, error) {
+	stmt := &AlterTableStmt{
+		TableName: tableName,
+	}
+
+	// Optional COLUMN keyword
+	if p.peekIs(lexer.COLUMN) {
+		p.nextToken() // consume COLUMN
+	}
+
+	// Column name
+	if !p.expectPeek(lexer.IDENT) {
+		return nil, fmt.Errorf("expected column name after ALTER, got %s", p.peek.Literal)
+	}
+	stmt.ColumnName = p.cur.Literal
+
+	p.nextToken() // move to the alteration
+	switch {
+	case p.curIs(lexer.SET) && p.peekIs(lexer.DEFAULT):
+		p.nextToken() // consume DEFAULT
+		p.nextToken() // move to value
+		expr, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, fmt.Errorf("expected expression after SET DEFAULT: %v", err)
+		}
+		stmt.Action = AlterActionSetDefault
+		stmt.DefaultExpr = expr
+	case p.curIs(lexer.DROP) && p.peekIs(lexer.DEFAULT):
+		p.nextToken() // consume DEFAULT
+		stmt.Action = AlterActionDropDefault
+	case (p.curIs(lexer.SET) || p.curIs(lexer.DROP)) && p.peekIs(lexer.NOT):
+		stmt.Action = AlterActionSetNotNull
+		if p.curIs(lexer.DROP) {
+			stmt.Action = AlterActionDropNotNull
+		}
+		p.nextToken() // consume NOT
+		if !p.expectPeek(lexer.NULL_KW) {
+			return nil, fmt.Errorf("expected NULL after NOT, got %s", p.peek.Literal)
+		}
+	default:
+		// [SET DATA] TYPE
+		if p.curIs(lexer.SET) {
+			p.nextToken() // consume SET
+			if !strings.EqualFold(p.cur.Literal, "DATA") {
+				return nil, fmt.Errorf("expected DEFAULT, NOT NULL, or DATA TYPE after SET, got %s", p.cur.Literal)
+			}
+			p.nextToken() // consume DATA
+		}
+		if !strings.EqualFold(p.cur.Literal, "TYPE") {
+			return nil, fmt.Errorf("expected TYPE, SET, or DROP after column name, got %s", p.cur.Literal)
+		}
+		p.nextToken() // move to type
+		typeInfo, err := p.parseColumnTypeInfo()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Action = AlterActionAlterColumnType
+		stmt.NewColumn = &ColumnDef{
+			Name:      stmt.ColumnName,
+			Type:      typeInfo.Type,
+			VectorDim: typeInfo.VectorDim,
+			MaxLength: typeInfo.MaxLength,
+			Precision: typeInfo.Precision,
+			Scale:     typeInfo.Scale,
+		}
+		if p.peekIs(lexer.USING) {
+			p.nextToken() // consume USING
+			p.nextToken() // move to expression
+			expr, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, fmt.Errorf("expected expression after USING: %v", err)
+			}
+			stmt.Using = expr
+		}
+	}
+
+	return stmt, nil
+}
+
 // parseBegin parses: BEGIN [TRANSACTION]
 func (p *Parser) parseBegin() (*BeginStmt, error) {
 	// consume BEGIN
@@ -3419,6 +3922,8 @@ func (p *Parser) parseCallArgs() ([]Expression, error) {
 
 	p.nextToken() // move past (
 
+	p.callArgs = true
+	defer func() { p.callArgs = false }()
 	for {
 		expr, err := p.parseExpression(LOWEST)
 		if err != nil {
@@ -3745,7 +4250,7 @@ func (p *Parser) parseCloseStmt() (*CloseStmt, error) {
 	return stmt, nil
 }
 
-// parsePragma parses: PRAGMA name [= value]
+// parsePragma parses: PRAGMA name [= value] or PRAGMA name(value)
 // Current token is PRAGMA
 func (p *Parser) parsePragma() (*PragmaStmt, error) {
 	stmt := &PragmaStmt{}
@@ -3757,16 +4262,59 @@ func (p *Parser) parsePragma() (*PragmaStmt, error) {
 	stmt.Name = p.cur.Literal
 
 	// Check for optional value assignment
-	if p.peekIs(lexer.EQ) {
-		p.nextToken() // consume =
+	if p.peekIs(lexer.EQ) || p.peekIs(lexer.LPAREN) {
+		call := p.peekIs(lexer.LPAREN)
+		p.nextToken() // consume = or (
 		p.nextToken() // move to value
 
-		// Parse the value expression
-		value, err := p.parseExpression(LOWEST)
-		if err != nil {
-			return nil, fmt.Errorf("invalid pragma value: %w", err)
+		// A bare word such as WAL or incremental is taken as text
+		if value := p.pragmaWord(); value != nil {
+			stmt.Value = value
+		} else {
+			value, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pragma value: %w", err)
+			}
+			stmt.Value = value
+		}
+
+		if call && !p.expectPeek(lexer.RPAREN) {
+			return nil, fmt.Errorf("expected ) after pragma value, got %s", p.peek.Literal)
+		}
+	}
+
+	return stmt, nil
+}
+
+// pragmaWord returns the current token as a text literal if it is a single
+// identifier or keyword ending the pragma value, or nil otherwise
+func (p *Parser) pragmaWord() Expression {
+	switch p.cur.Type {
+	case lexer.IDENT:
+	case lexer.TRUE_KW, lexer.FALSE_KW, lexer.NULL_KW, lexer.NOT, lexer.CASE, lexer.EXISTS:
+		return nil
+	default:
+		if lexer.LookupIdent(strings.ToUpper(p.cur.Literal)) != p.cur.Type {
+			return nil
+		}
+	}
+	if !p.peekIs(lexer.EOF) && !p.peekIs(lexer.SEMICOLON) && !p.peekIs(lexer.RPAREN) {
+		return nil
+	}
+	return &Literal{Value: types.NewText(p.cur.Literal)}
+}
+
+// parseVacuum parses: VACUUM [INTO 'filename']
+// Current token is VACUUM
+func (p *Parser) parseVacuum() (*VacuumStmt, error) {
+	stmt := &VacuumStmt{}
+
+	if p.peekIs(lexer.INTO) {
+		p.nextToken() // consume INTO
+		if !p.expectPeek(lexer.STRING) {
+			return nil, fmt.Errorf("expected file name after VACUUM INTO, got %s", p.peek.Literal)
 		}
-		stmt.Value = value
+		stmt.Into = p.cur.Literal
 	}
 
 	return stmt, nil
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"testing"
 
 	"tur/pkg/sql/lexer"
@@ -1086,6 +1087,59 @@ func TestParser_CreateIndex_Expression_UniqueIndex(t *testing.T) {
 	}
 }
 
+func TestParser_CreateIndex_UsingHNSW(t *testing.T) {
+	input := "CREATE INDEX emb_idx ON docs USING HNSW (embedding) WITH (m=32, ef_construction=400, metric='l2', heuristic=true)"
+	p := New(input)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	create, ok := stmt.(*CreateIndexStmt)
+	if !ok {
+		t.Fatalf("Expected *CreateIndexStmt, got %T", stmt)
+	}
+
+	if create.Using != "HNSW" {
+		t.Errorf("Using = %q, want 'HNSW'", create.Using)
+	}
+	if len(create.Columns) != 1 || create.Columns[0] != "embedding" {
+		t.Errorf("Columns = %v, want ['embedding']", create.Columns)
+	}
+
+	wantNames := []string{"m", "ef_construction", "metric", "heuristic"}
+	if len(create.Options) != len(wantNames) {
+		t.Fatalf("Options count = %d, want %d", len(create.Options), len(wantNames))
+	}
+	for i, name := range wantNames {
+		if create.Options[i].Name != name {
+			t.Errorf("Options[%d].Name = %q, want %q", i, create.Options[i].Name, name)
+		}
+	}
+
+	lit, ok := create.Options[2].Value.(*Literal)
+	if !ok || lit.Value.Text() != "l2" {
+		t.Errorf("metric value = %v, want 'l2'", create.Options[2].Value)
+	}
+}
+
+func TestParser_CreateIndex_UsingWithoutOptions(t *testing.T) {
+	input := "CREATE INDEX emb_idx ON docs USING hnsw (embedding)"
+	p := New(input)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	create := stmt.(*CreateIndexStmt)
+	if create.Using != "HNSW" {
+		t.Errorf("Using = %q, want 'HNSW'", create.Using)
+	}
+	if len(create.Options) != 0 {
+		t.Errorf("Options = %v, want none", create.Options)
+	}
+}
+
 func TestParser_DropIndex(t *testing.T) {
 	input := "DROP INDEX idx_users_email"
 	p := New(input)
@@ -1439,6 +1493,90 @@ func TestParser_Analyze_SpecificIndex(t *testing.T) {
 	}
 }
 
+// ========== VACUUM Tests ==========
+
+func TestParser_Vacuum(t *testing.T) {
+	tests := []struct {
+		input string
+		into  string
+	}{
+		{"VACUUM", ""},
+		{"VACUUM;", ""},
+		{"VACUUM INTO '/tmp/backup.db'", "/tmp/backup.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			stmt, err := New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			vacuum, ok := stmt.(*VacuumStmt)
+			if !ok {
+				t.Fatalf("Expected *VacuumStmt, got %T", stmt)
+			}
+			if vacuum.Into != tt.into {
+				t.Errorf("Into = %q, want %q", vacuum.Into, tt.into)
+			}
+		})
+	}
+
+	if _, err := New("VACUUM INTO backup").Parse(); err == nil {
+		t.Error("expected an error for VACUUM INTO without a file name string")
+	}
+}
+
+func TestParser_PragmaValueForms(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		value interface{} // nil, int64 or string
+	}{
+		{"PRAGMA auto_vacuum", "auto_vacuum", nil},
+		{"PRAGMA auto_vacuum = incremental", "auto_vacuum", "incremental"},
+		{"PRAGMA auto_vacuum = 2", "auto_vacuum", int64(2)},
+		{"PRAGMA incremental_vacuum(10)", "incremental_vacuum", int64(10)},
+		{"PRAGMA result_streaming = ON", "result_streaming", "ON"},
+		{"PRAGMA journal_mode = 'wal'", "journal_mode", "wal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			stmt, err := New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			pragma, ok := stmt.(*PragmaStmt)
+			if !ok {
+				t.Fatalf("Expected *PragmaStmt, got %T", stmt)
+			}
+			if pragma.Name != tt.name {
+				t.Errorf("Name = %q, want %q", pragma.Name, tt.name)
+			}
+			if tt.value == nil {
+				if pragma.Value != nil {
+					t.Errorf("expected no value, got %v", pragma.Value)
+				}
+				return
+			}
+			lit, ok := pragma.Value.(*Literal)
+			if !ok {
+				t.Fatalf("expected a literal value, got %T", pragma.Value)
+			}
+			switch want := tt.value.(type) {
+			case int64:
+				if lit.Value.Int() != want {
+					t.Errorf("value = %v, want %d", lit.Value, want)
+				}
+			case string:
+				if lit.Value.Text() != want {
+					t.Errorf("value = %v, want %q", lit.Value, want)
+				}
+			}
+		})
+	}
+}
+
 // ========== ALTER TABLE Tests ==========
 
 func TestParser_AlterTable_AddColumn_Simple(t *testing.T) {
@@ -1592,6 +1730,77 @@ func TestParser_AlterTable_RenameTo(t *testing.T) {
 	}
 }
 
+func TestParser_AlterTable_RenameColumn(t *testing.T) {
+	for _, input := range []string{
+		"ALTER TABLE users RENAME COLUMN name TO full_name",
+		"ALTER TABLE users RENAME name TO full_name",
+	} {
+		stmt, err := New(input).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse error: %v", input, err)
+		}
+		alter, ok := stmt.(*AlterTableStmt)
+		if !ok {
+			t.Fatalf("%s: Expected *AlterTableStmt, got %T", input, stmt)
+		}
+		if alter.Action != AlterActionRenameColumn {
+			t.Errorf("%s: Action = %v, want AlterActionRenameColumn", input, alter.Action)
+		}
+		if alter.ColumnName != "name" || alter.NewName != "full_name" {
+			t.Errorf("%s: renames %q to %q, want name to full_name", input, alter.ColumnName, alter.NewName)
+		}
+	}
+}
+
+func TestParser_AlterTable_AlterColumn(t *testing.T) {
+	tests := []struct {
+		input  string
+		action AlterAction
+	}{
+		{"ALTER TABLE users ALTER COLUMN age TYPE BIGINT", AlterActionAlterColumnType},
+		{"ALTER TABLE users ALTER age SET DATA TYPE VARCHAR(20)", AlterActionAlterColumnType},
+		{"ALTER TABLE users ALTER COLUMN age TYPE TEXT USING age * 2", AlterActionAlterColumnType},
+		{"ALTER TABLE users ALTER COLUMN age SET DEFAULT 18", AlterActionSetDefault},
+		{"ALTER TABLE users ALTER COLUMN age DROP DEFAULT", AlterActionDropDefault},
+		{"ALTER TABLE users ALTER COLUMN age SET NOT NULL", AlterActionSetNotNull},
+		{"ALTER TABLE users ALTER COLUMN age DROP NOT NULL", AlterActionDropNotNull},
+	}
+
+	for _, tt := range tests {
+		stmt, err := New(tt.input).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse error: %v", tt.input, err)
+		}
+		alter, ok := stmt.(*AlterTableStmt)
+		if !ok {
+			t.Fatalf("%s: Expected *AlterTableStmt, got %T", tt.input, stmt)
+		}
+		if alter.Action != tt.action {
+			t.Errorf("%s: Action = %v, want %v", tt.input, alter.Action, tt.action)
+		}
+		if alter.ColumnName != "age" {
+			t.Errorf("%s: ColumnName = %q, want 'age'", tt.input, alter.ColumnName)
+		}
+	}
+
+	stmt, _ := New("ALTER TABLE users ALTER age SET DATA TYPE VARCHAR(20)").Parse()
+	if col := stmt.(*AlterTableStmt).NewColumn; col == nil || col.Type != types.TypeVarchar || col.MaxLength != 20 {
+		t.Errorf("NewColumn = %+v, want VARCHAR(20)", col)
+	}
+	stmt, _ = New("ALTER TABLE users ALTER COLUMN age TYPE TEXT USING age * 2").Parse()
+	if stmt.(*AlterTableStmt).Using == nil {
+		t.Error("Using = nil, want the USING expression")
+	}
+	stmt, _ = New("ALTER TABLE users ALTER COLUMN age SET DEFAULT 18").Parse()
+	if stmt.(*AlterTableStmt).DefaultExpr == nil {
+		t.Error("DefaultExpr = nil, want 18")
+	}
+
+	if _, err := New("ALTER TABLE users ALTER COLUMN age SET NULL").Parse(); err == nil {
+		t.Error("Expected error for SET NULL")
+	}
+}
+
 func TestParser_Select_OrderBy(t *testing.T) {
 	input := "SELECT * FROM users ORDER BY name"
 	p := New(input)
@@ -3271,6 +3480,29 @@ func TestParseValuesFunction(t *testing.T) {
 	}
 }
 
+func TestParseVectorFunction(t *testing.T) {
+	input := "SELECT VECTOR('[1, 2]') FROM t"
+	p := New(input)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	selectStmt := stmt.(*SelectStmt)
+	fn, ok := selectStmt.Columns[0].Expr.(*FunctionCall)
+	if !ok {
+		t.Fatalf("expected FunctionCall, got %T", selectStmt.Columns[0].Expr)
+	}
+	if fn.Name != "VECTOR" || len(fn.Args) != 1 {
+		t.Errorf("expected VECTOR with 1 argument, got %s with %d", fn.Name, len(fn.Args))
+	}
+
+	// VECTOR alone is still a column type, not an expression
+	if _, err := New("SELECT VECTOR FROM t").Parse(); err == nil {
+		t.Error("expected an error for VECTOR without arguments")
+	}
+}
+
 func TestParseInsertOnDuplicateKeyUpdate(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -4292,3 +4524,337 @@ func TestParser_BacktickInsert(t *testing.T) {
 		t.Errorf("Columns[1] = %q, want 'where'", insert.Columns[1])
 	}
 }
+
+func TestParser_TableFunction_NamedArgs(t *testing.T) {
+	input := "SELECT * FROM vector_quantize_scan('docs', 'emb', ?, 10, EF => 200) v"
+	p := New(input)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	sel, ok := stmt.(*SelectStmt)
+	if !ok {
+		t.Fatalf("Expected *SelectStmt, got %T", stmt)
+	}
+	tf, ok := sel.From.(*TableFunction)
+	if !ok {
+		t.Fatalf("Expected *TableFunction, got %T", sel.From)
+	}
+
+	if len(tf.Args) != 4 {
+		t.Errorf("Args count = %d, want 4", len(tf.Args))
+	}
+	if tf.Alias != "v" {
+		t.Errorf("Alias = %q, want 'v'", tf.Alias)
+	}
+	if len(tf.NamedArgs) != 1 || tf.NamedArgs[0].Name != "ef" {
+		t.Fatalf("NamedArgs = %v, want [ef]", tf.NamedArgs)
+	}
+	lit, ok := tf.NamedArgs[0].Value.(*Literal)
+	if !ok || lit.Value.Int() != 200 {
+		t.Errorf("ef value = %v, want 200", tf.NamedArgs[0].Value)
+	}
+}
+
+func TestParser_TableFunction_PositionalAfterNamed(t *testing.T) {
+	p := New("SELECT * FROM vector_quantize_scan('docs', 'emb', x'00', ef => 200, 10)")
+	if _, err := p.Parse(); err == nil {
+		t.Error("expected error for positional argument after named argument")
+	}
+}
+
+func TestParser_Placeholders(t *testing.T) {
+	p := New("SELECT ? FROM t WHERE a = :id AND b = @name AND c = :id AND d = $4 AND e = ?")
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// ? takes the next index, a name keeps its first index, $N is index N
+	if p.PlaceholderCount() != 5 {
+		t.Fatalf("PlaceholderCount = %d, want 5", p.PlaceholderCount())
+	}
+	want := []string{"", ":id", "@name", "$4", ""}
+	names := p.PlaceholderNames()
+	for i, name := range want {
+		if names[i] != name {
+			t.Errorf("PlaceholderNames()[%d] = %q, want %q", i, names[i], name)
+		}
+	}
+
+	sel := stmt.(*SelectStmt)
+	if ph, ok := sel.Columns[0].Expr.(*Placeholder); !ok || ph.Index != 1 {
+		t.Errorf("column = %#v, want placeholder 1", sel.Columns[0].Expr)
+	}
+	var got []int
+	var collect func(expr Expression)
+	collect = func(expr Expression) {
+		switch e := expr.(type) {
+		case *BinaryExpr:
+			collect(e.Left)
+			collect(e.Right)
+		case *Placeholder:
+			got = append(got, e.Index)
+		}
+	}
+	collect(sel.Where)
+	if fmt.Sprint(got) != "[2 3 2 4 5]" {
+		t.Errorf("WHERE placeholder indices = %v, want [2 3 2 4 5]", got)
+	}
+
+	for _, input := range []string{"SELECT $0", "SELECT $x", "SELECT :1"} {
+		if _, err := New(input).Parse(); err == nil {
+			t.Errorf("expected %q to fail", input)
+		}
+	}
+}
+
+func TestParser_Select_Distinct(t *testing.T) {
+	tests := []struct {
+		input      string
+		distinct   bool
+		distinctOn int
+	}{
+		{"SELECT DISTINCT category FROM docs", true, 0},
+		{"SELECT ALL category FROM docs", false, 0},
+		{"SELECT category FROM docs", false, 0},
+		{"SELECT DISTINCT ON (category) category, title FROM docs ORDER BY category", false, 1},
+		{"SELECT DISTINCT ON (category, user_id) * FROM docs", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			stmt, err := New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			sel, ok := stmt.(*SelectStmt)
+			if !ok {
+				t.Fatalf("Expected *SelectStmt, got %T", stmt)
+			}
+			if sel.Distinct != tt.distinct {
+				t.Errorf("Distinct = %v, want %v", sel.Distinct, tt.distinct)
+			}
+			if len(sel.DistinctOn) != tt.distinctOn {
+				t.Errorf("DistinctOn count = %d, want %d", len(sel.DistinctOn), tt.distinctOn)
+			}
+			if len(sel.Columns) == 0 {
+				t.Errorf("expected select columns")
+			}
+		})
+	}
+}
+
+func TestParser_Select_DistinctAggregate(t *testing.T) {
+	stmt, err := New("SELECT COUNT(DISTINCT user_id), SUM(ALL score) FROM docs").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	sel := stmt.(*SelectStmt)
+
+	count, ok := sel.Columns[0].Expr.(*FunctionCall)
+	if !ok {
+		t.Fatalf("Columns[0] type = %T, want *FunctionCall", sel.Columns[0].Expr)
+	}
+	if !count.Distinct || len(count.Args) != 1 {
+		t.Errorf("COUNT Distinct = %v with %d args, want true with 1", count.Distinct, len(count.Args))
+	}
+	if sum := sel.Columns[1].Expr.(*FunctionCall); sum.Distinct {
+		t.Errorf("SUM(ALL ...) should not be distinct")
+	}
+
+	for _, input := range []string{
+		"SELECT COUNT(DISTINCT *) FROM docs",
+		"SELECT COUNT(DISTINCT) FROM docs",
+		"SELECT COUNT(DISTINCT x) OVER () FROM docs",
+		"SELECT DISTINCT ON category FROM docs",
+	} {
+		if _, err := New(input).Parse(); err == nil {
+			t.Errorf("expected parse error for %s", input)
+		}
+	}
+}
+
+func TestParser_Predicates(t *testing.T) {
+	stmt, err := New("SELECT a IS NULL, b IS NOT NULL, c IS DISTINCT FROM d, e IS NOT DISTINCT FROM NULL, f BETWEEN 1 AND 10, g NOT BETWEEN 1 AND 10 FROM t").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	cols := stmt.(*SelectStmt).Columns
+
+	if e, ok := cols[0].Expr.(*IsNullExpr); !ok || e.Not {
+		t.Errorf("Columns[0] = %#v, want IS NULL", cols[0].Expr)
+	}
+	if e, ok := cols[1].Expr.(*IsNullExpr); !ok || !e.Not {
+		t.Errorf("Columns[1] = %#v, want IS NOT NULL", cols[1].Expr)
+	}
+	if e, ok := cols[2].Expr.(*IsDistinctExpr); !ok || e.Not {
+		t.Errorf("Columns[2] = %#v, want IS DISTINCT FROM", cols[2].Expr)
+	}
+	if e, ok := cols[3].Expr.(*IsDistinctExpr); !ok || !e.Not {
+		t.Errorf("Columns[3] = %#v, want IS NOT DISTINCT FROM", cols[3].Expr)
+	}
+	between, ok := cols[4].Expr.(*BetweenExpr)
+	if !ok || between.Not {
+		t.Fatalf("Columns[4] = %#v, want BETWEEN", cols[4].Expr)
+	}
+	if low := between.Low.(*Literal); low.Value.Int() != 1 {
+		t.Errorf("BETWEEN low = %d, want 1", low.Value.Int())
+	}
+	if high := between.High.(*Literal); high.Value.Int() != 10 {
+		t.Errorf("BETWEEN high = %d, want 10", high.Value.Int())
+	}
+	if e, ok := cols[5].Expr.(*BetweenExpr); !ok || !e.Not {
+		t.Errorf("Columns[5] = %#v, want NOT BETWEEN", cols[5].Expr)
+	}
+}
+
+func TestParser_Predicates_Precedence(t *testing.T) {
+	// The AND of BETWEEN is not a logical AND
+	stmt, err := New("SELECT * FROM t WHERE x BETWEEN 1 AND 5 AND y = 2").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	and, ok := stmt.(*SelectStmt).Where.(*BinaryExpr)
+	if !ok || and.Op != lexer.AND {
+		t.Fatalf("WHERE = %#v, want AND", stmt.(*SelectStmt).Where)
+	}
+	if _, ok := and.Left.(*BetweenExpr); !ok {
+		t.Errorf("AND left = %T, want *BetweenExpr", and.Left)
+	}
+
+	// NOT binds looser than comparisons and tighter than AND
+	stmt, err = New("SELECT * FROM t WHERE NOT x = 1 AND y IS NULL").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	and, ok = stmt.(*SelectStmt).Where.(*BinaryExpr)
+	if !ok || and.Op != lexer.AND {
+		t.Fatalf("WHERE = %#v, want AND", stmt.(*SelectStmt).Where)
+	}
+	not, ok := and.Left.(*UnaryExpr)
+	if !ok || not.Op != lexer.NOT {
+		t.Fatalf("AND left = %#v, want NOT", and.Left)
+	}
+	if cmp, ok := not.Right.(*BinaryExpr); !ok || cmp.Op != lexer.EQ {
+		t.Errorf("NOT operand = %#v, want x = 1", not.Right)
+	}
+	if _, ok := and.Right.(*IsNullExpr); !ok {
+		t.Errorf("AND right = %T, want *IsNullExpr", and.Right)
+	}
+
+	// IS binds looser than comparisons
+	stmt, err = New("SELECT a = b IS NOT NULL FROM t").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	isNull, ok := stmt.(*SelectStmt).Columns[0].Expr.(*IsNullExpr)
+	if !ok {
+		t.Fatalf("column = %T, want *IsNullExpr", stmt.(*SelectStmt).Columns[0].Expr)
+	}
+	if _, ok := isNull.Expr.(*BinaryExpr); !ok {
+		t.Errorf("IS NOT NULL operand = %T, want *BinaryExpr", isNull.Expr)
+	}
+}
+
+func TestParser_Cast(t *testing.T) {
+	stmt, err := New("SELECT CAST(price AS DECIMAL(10,2)), CAST('42' AS INT), CAST(x + 1 AS VARCHAR(5)) FROM t").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	cols := stmt.(*SelectStmt).Columns
+
+	dec, ok := cols[0].Expr.(*CastExpr)
+	if !ok {
+		t.Fatalf("Columns[0] type = %T, want *CastExpr", cols[0].Expr)
+	}
+	if dec.Type.Type != types.TypeDecimal || dec.Type.Precision != 10 || dec.Type.Scale != 2 {
+		t.Errorf("CAST type = %+v, want DECIMAL(10,2)", dec.Type)
+	}
+	if ref, ok := dec.Expr.(*ColumnRef); !ok || ref.Name != "price" {
+		t.Errorf("CAST operand = %#v, want price", dec.Expr)
+	}
+	if c := cols[1].Expr.(*CastExpr); c.Type.Type != types.TypeInt32 {
+		t.Errorf("CAST type = %v, want INT", c.Type.Type)
+	}
+	varchar := cols[2].Expr.(*CastExpr)
+	if varchar.Type.Type != types.TypeVarchar || varchar.Type.MaxLength != 5 {
+		t.Errorf("CAST type = %+v, want VARCHAR(5)", varchar.Type)
+	}
+	if _, ok := varchar.Expr.(*BinaryExpr); !ok {
+		t.Errorf("CAST operand = %T, want *BinaryExpr", varchar.Expr)
+	}
+
+	for _, input := range []string{
+		"SELECT CAST(x) FROM t",
+		"SELECT CAST(x AS) FROM t",
+		"SELECT CAST(x AS INT FROM t",
+		"SELECT x IS 1 FROM t",
+		"SELECT x BETWEEN 1 FROM t",
+		"SELECT * FROM t WHERE x NOT 5",
+	} {
+		if _, err := New(input).Parse(); err == nil {
+			t.Errorf("expected parse error for %s", input)
+		}
+	}
+}
+
+func TestParser_AggregateOrderByAndFilter(t *testing.T) {
+	stmt, err := New("SELECT STRING_AGG(name, ', ' ORDER BY id DESC, name), COUNT(*) FILTER (WHERE price > 10 AND qty IS NOT NULL) FROM t").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	cols := stmt.(*SelectStmt).Columns
+
+	agg := cols[0].Expr.(*FunctionCall)
+	if len(agg.Args) != 2 || len(agg.OrderBy) != 2 || agg.WithinGroup {
+		t.Fatalf("STRING_AGG = %+v, want 2 args and 2 ORDER BY terms", agg)
+	}
+	if agg.OrderBy[0].Direction != OrderDesc || agg.OrderBy[1].Direction != OrderAsc {
+		t.Errorf("ORDER BY directions = %v, %v", agg.OrderBy[0].Direction, agg.OrderBy[1].Direction)
+	}
+
+	count := cols[1].Expr.(*FunctionCall)
+	filter, ok := count.Filter.(*BinaryExpr)
+	if !ok || filter.Op != lexer.AND {
+		t.Errorf("FILTER = %#v, want an AND expression", count.Filter)
+	}
+}
+
+func TestParser_WithinGroup(t *testing.T) {
+	stmt, err := New("SELECT PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY price DESC) FILTER (WHERE qty > 0) OVER (PARTITION BY cat), MODE() WITHIN GROUP (ORDER BY cat) FROM t").Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	cols := stmt.(*SelectStmt).Columns
+
+	wf, ok := cols[0].Expr.(*WindowFunction)
+	if !ok {
+		t.Fatalf("Columns[0] type = %T, want *WindowFunction", cols[0].Expr)
+	}
+	pct := wf.Function.(*FunctionCall)
+	if !pct.WithinGroup || len(pct.Args) != 1 || len(pct.OrderBy) != 1 || pct.OrderBy[0].Direction != OrderDesc {
+		t.Errorf("PERCENTILE_CONT = %+v", pct)
+	}
+	if pct.Filter == nil || len(wf.Over.PartitionBy) != 1 {
+		t.Errorf("expected FILTER and PARTITION BY, got %+v and %+v", pct.Filter, wf.Over)
+	}
+
+	mode := cols[1].Expr.(*FunctionCall)
+	if !mode.WithinGroup || len(mode.Args) != 0 {
+		t.Errorf("MODE = %+v", mode)
+	}
+
+	for _, input := range []string{
+		"SELECT MODE() WITHIN GROUP (ORDER BY a, b) FROM t",
+		"SELECT MODE() WITHIN (ORDER BY a) FROM t",
+		"SELECT STRING_AGG(a, ',' ORDER BY a) WITHIN GROUP (ORDER BY a) FROM t",
+		"SELECT COUNT(*) FILTER (price > 1) FROM t",
+		"SELECT COUNT(*) FILTER (WHERE price > 1 FROM t",
+	} {
+		if _, err := New(input).Parse(); err == nil {
+			t.Errorf("expected parse error for %s", input)
+		}
+	}
+}
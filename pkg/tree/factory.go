@@ -128,11 +128,18 @@ func (a *btreeAdapter) Insert(key, value []byte) error {
 }
 
 func (a *btreeAdapter) Get(key []byte) ([]byte, error) {
-	return a.tree.Get(key)
+	value, err := a.tree.Get(key)
+	if err == btree.ErrKeyNotFound {
+		return nil, ErrKeyNotFound
+	}
+	return value, err
 }
 
 func (a *btreeAdapter) Delete(key []byte) error {
-	return a.tree.Delete(key)
+	if err := a.tree.Delete(key); err != btree.ErrKeyNotFound {
+		return err
+	}
+	return ErrKeyNotFound
 }
 
 func (a *btreeAdapter) Cursor() Cursor {
@@ -151,6 +158,18 @@ func (a *btreeAdapter) CollectPages() []uint32 {
 	return a.tree.CollectPages()
 }
 
+func (a *btreeAdapter) CheckIntegrity() []error {
+	return a.tree.CheckOverflowPages()
+}
+
+func (a *btreeAdapter) RelocatePage(from, to uint32) error {
+	return a.tree.RelocatePage(from, to)
+}
+
+func (a *btreeAdapter) ResetRootPage(rootPage uint32) {
+	a.tree.ResetRootPage(rootPage)
+}
+
 // btreeCursorAdapter adapts btree.Cursor to the Cursor interface
 type btreeCursorAdapter struct {
 	cursor *btree.Cursor
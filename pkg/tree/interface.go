@@ -66,6 +66,28 @@ type TreeWithRootPage interface {
 	RootPage() uint32
 }
 
+// TreeWithIntegrityCheck is an extension for trees that can verify their
+// on-disk structure, such as the overflow pages of large records.
+type TreeWithIntegrityCheck interface {
+	Tree
+	CheckIntegrity() []error
+}
+
+// TreeWithRelocation is an extension for page-based trees whose pages can be
+// moved, as incremental vacuum does to free the end of the file.
+type TreeWithRelocation interface {
+	Tree
+	RelocatePage(from, to uint32) error
+}
+
+// TreeWithRootReset is an extension for page-based trees that keep no state
+// besides their pages and root page number, so that undoing a pager
+// transaction only requires pointing them back at their earlier root page.
+type TreeWithRootReset interface {
+	Tree
+	ResetRootPage(rootPage uint32)
+}
+
 // TreeWithStats is an extension for trees that provide statistics.
 type TreeWithStats interface {
 	Tree
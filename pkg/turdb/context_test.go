@@ -3,7 +3,10 @@ package turdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -546,3 +549,115 @@ func TestTx_ExecContext_WithDeadline(t *testing.T) {
 		t.Fatalf("commit failed: %v", err)
 	}
 }
+
+// openRunawayDB opens a database with a table whose joins with itself take
+// far longer to run than the tests wait for
+func openRunawayDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "runaway.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE big (id INT PRIMARY KEY, v INT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("(%d, %d)", i+1, i%7)
+	}
+	if _, err := db.Exec("INSERT INTO big VALUES " + strings.Join(values, ", ")); err != nil {
+		t.Fatalf("failed to insert rows: %v", err)
+	}
+	return db
+}
+
+// expectStopped checks that a statement stopped promptly with the error of
+// its context
+func expectStopped(t *testing.T, what string, start time.Time, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: expected %v, got %v", what, want, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("%s: stopped after %v", what, elapsed)
+	}
+}
+
+func TestDB_ExecContext_StopsRunningStatement(t *testing.T) {
+	db := openRunawayDB(t)
+	const crossJoin = "big a JOIN big b ON 1 = 1 JOIN big c ON 1 = 1 JOIN big d ON 1 = 1"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := db.ExecContext(ctx, "SELECT COUNT(*) FROM "+crossJoin)
+	expectStopped(t, "cross join", start, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = db.ExecContext(ctx, "UPDATE big SET v = (SELECT COUNT(*) FROM "+crossJoin+")")
+	expectStopped(t, "UPDATE", start, err, context.DeadlineExceeded)
+
+	// The statement left nothing behind
+	result, err := db.Exec("SELECT SUM(v) FROM big")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if sum := result.Rows[0][0]; sum != int64(295) {
+		t.Errorf("SUM(v) = %v after the stopped UPDATE, want 295", sum)
+	}
+}
+
+func TestStmt_QueryContext_StopsRunningQuery(t *testing.T) {
+	db := openRunawayDB(t)
+
+	stmt, err := db.Prepare("WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT r.n + 1 FROM r JOIN big ON 1 = 1 WHERE r.n < ?) SELECT COUNT(*) FROM r")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer stmt.Close()
+	stmt.BindInt(1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = stmt.QueryContext(ctx)
+	expectStopped(t, "recursive CTE", start, err, context.Canceled)
+}
+
+func TestTx_ExecContext_StopsRunningStatement(t *testing.T) {
+	db := openRunawayDB(t)
+	if _, err := db.Exec("CREATE TABLE copy (a INT, b INT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO copy VALUES (0, 0)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = tx.ExecContext(ctx, "INSERT INTO copy SELECT a.id, b.id FROM big a JOIN big b ON 1 = 1 JOIN big c ON 1 = 1 JOIN big d ON 1 = 1")
+	expectStopped(t, "INSERT", start, err, context.DeadlineExceeded)
+
+	// The transaction goes on with what it did before the statement
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	result, err := db.Exec("SELECT COUNT(*) FROM copy")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if n := result.Rows[0][0]; n != int64(1) {
+		t.Errorf("%v rows in copy, want 1", n)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 
@@ -15,6 +14,7 @@ import (
 	"tur/pkg/pager"
 	"tur/pkg/schema"
 	"tur/pkg/sql/executor"
+	"tur/pkg/sql/parser"
 	"tur/pkg/types"
 )
 
@@ -22,20 +22,55 @@ var (
 	// ErrDatabaseClosed is returned when attempting operations on a closed database
 	ErrDatabaseClosed = errors.New("database is closed")
 
-	// ErrDatabaseLocked is returned when the database file is already locked
-	ErrDatabaseLocked = errors.New("database is locked by another connection")
+	// ErrDatabaseLocked is returned when opening a database file that
+	// another read-write connection, in this process or another, has open
+	ErrDatabaseLocked = pager.ErrDatabaseLocked
+
+	// ErrReadOnly is returned by statements that would change a database
+	// opened with Options.ReadOnly
+	ErrReadOnly = pager.ErrReadOnly
 )
 
 // DB represents an open database connection.
 // It provides the main entry point for database operations.
+//
+// A DB is safe for concurrent use. Queries share it and run in parallel,
+// while other statements take it exclusively and run one at a time.
+//
+// A database file has at most one read-write DB at a time. Any number of
+// read-only ones, in the same process or others on the same host, can read
+// it alongside: see Options.ReadOnly. The connections of a Pool share one
+// engine instead, and each has a SQL transaction of its own.
 type DB struct {
-	mu sync.RWMutex
+	*engine
 
 	// path is the file path of the database
 	path string
 
-	// lockFile holds the lock file to prevent concurrent access
-	lockFile *os.File
+	// tx is the SQL transaction begun on this connection with BEGIN, if any
+	tx *mvcc.Transaction
+
+	// txSnapshot is set while a read-only database holds a pager snapshot
+	// for a transaction begun with BEGIN
+	txSnapshot bool
+
+	// stmtCache caches prepared statements by SQL text
+	stmtCache map[string]*Stmt
+
+	// closed indicates if the connection has been closed
+	closed bool
+}
+
+// engine is the database state connections share: the pager, and the
+// executor with its catalog, trees and HNSW indexes
+type engine struct {
+	// mu is held shared by read-only queries and exclusively by everything
+	// that changes the database or the executor
+	mu sync.RWMutex
+
+	// refs counts the connections, and the pool, using the engine. The last
+	// one to let go of it closes it.
+	refs int
 
 	// pager manages page-level I/O and caching
 	pager *pager.Pager
@@ -52,20 +87,52 @@ type DB struct {
 	// maxRowid tracks max INT PRIMARY KEY value per table (for AUTOINCREMENT)
 	maxRowid map[string]int64
 
-	// txManager handles MVCC transactions
-	txManager *mvcc.TransactionManager
-
 	// hnswIndexes holds HNSW indexes for vector columns
 	hnswIndexes map[string]*hnsw.Index
 
 	// executor handles SQL execution
 	executor *executor.Executor
 
-	// stmtCache caches prepared statements by SQL text
-	stmtCache map[string]*Stmt
+	// pooled is set for the engine of a Pool, whose connections begin their
+	// SQL transactions on a snapshot
+	pooled bool
+}
 
-	// closed indicates if the database has been closed
-	closed bool
+// newEngine creates the engine of a database opened with pager p
+func newEngine(p *pager.Pager) *engine {
+	return &engine{
+		pager:       p,
+		catalog:     schema.NewCatalog(),
+		trees:       make(map[string]*btree.BTree),
+		rowid:       make(map[string]uint64),
+		maxRowid:    make(map[string]int64),
+		hnswIndexes: make(map[string]*hnsw.Index),
+		executor:    executor.New(p),
+	}
+}
+
+// connect returns a new connection to the engine
+func (e *engine) connect(path string) *DB {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refs++
+	return &DB{
+		engine:    e,
+		path:      path,
+		stmtCache: make(map[string]*Stmt),
+	}
+}
+
+// releaseLocked lets go of a reference to the engine, and closes it once
+// nothing uses it anymore. The caller holds mu.
+func (e *engine) releaseLocked() error {
+	e.refs--
+	if e.refs > 0 {
+		return nil
+	}
+	// Syncs the btree root pages to the schema and closes the pager, which
+	// releases its locks on the database
+	return e.executor.Close()
 }
 
 // MemoryPath is the special path that indicates an in-memory database
@@ -87,8 +154,18 @@ type Options struct {
 	// CacheSize specifies the number of pages to cache (default 1000)
 	CacheSize int
 
-	// ReadOnly opens the database in read-only mode
+	// ReadOnly opens an existing database file for reading only, alongside
+	// the read-write connection of another process, or of this one, that may
+	// be writing it. Each statement, or each Tx, reads the database as of the
+	// last commit before it began; the WAL keeps the pages it reads until it
+	// ends. Statements that would change the database fail with ErrReadOnly.
+	// In-memory databases ignore it.
 	ReadOnly bool
+
+	// WALAutoCheckpoint is the number of WAL frames after which a commit
+	// runs a passive checkpoint (default 1000, negative disables it). It can
+	// be changed later with PRAGMA wal_autocheckpoint.
+	WALAutoCheckpoint int
 }
 
 // OpenWithOptions opens a database file with the specified options.
@@ -100,51 +177,30 @@ func OpenWithOptions(path string, opts Options) (*DB, error) {
 		return openInMemory(opts)
 	}
 
-	// Acquire exclusive lock on lock file to prevent concurrent access
-	lockPath := path + ".lock"
-	lf, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return nil, err
-	}
-
-	// Try to acquire exclusive lock (non-blocking)
-	if err := lockFile(lf); err != nil {
-		lf.Close()
-		return nil, err
-	}
-
 	// Configure pager options
 	pagerOpts := pager.Options{
-		PageSize:  opts.PageSize,
-		CacheSize: opts.CacheSize,
-		ReadOnly:  opts.ReadOnly,
+		PageSize:          opts.PageSize,
+		CacheSize:         opts.CacheSize,
+		ReadOnly:          opts.ReadOnly,
+		WALAutoCheckpoint: opts.WALAutoCheckpoint,
 	}
 
-	// Open the pager (handles file creation if needed)
+	// Open the pager (handles file creation if needed). It locks the
+	// database against a second read-write connection.
 	p, err := pager.Open(path, pagerOpts)
 	if err != nil {
-		// Release lock and close lock file on error
-		unlockFile(lf)
-		lf.Close()
 		return nil, err
 	}
-
-	db := &DB{
-		path:        path,
-		lockFile:    lf,
-		pager:       p,
-		catalog:     schema.NewCatalog(),
-		trees:       make(map[string]*btree.BTree),
-		rowid:       make(map[string]uint64),
-		maxRowid:    make(map[string]int64),
-		txManager:   mvcc.NewTransactionManager(),
-		hnswIndexes: make(map[string]*hnsw.Index),
-		executor:    executor.New(p),
-		stmtCache:   make(map[string]*Stmt),
-		closed:      false,
+	if opts.ReadOnly {
+		// Load the schema from a single state of the database
+		if _, err := p.BeginSnapshot(); err != nil {
+			p.Close()
+			return nil, err
+		}
+		defer p.EndSnapshot()
 	}
 
-	return db, nil
+	return newEngine(p).connect(path), nil
 }
 
 // openInMemory creates a new in-memory database.
@@ -181,22 +237,7 @@ func openInMemory(opts Options) (*DB, error) {
 		return nil, err
 	}
 
-	db := &DB{
-		path:        MemoryPath,
-		lockFile:    nil, // No lock file for in-memory
-		pager:       p,
-		catalog:     schema.NewCatalog(),
-		trees:       make(map[string]*btree.BTree),
-		rowid:       make(map[string]uint64),
-		maxRowid:    make(map[string]int64),
-		txManager:   mvcc.NewTransactionManager(),
-		hnswIndexes: make(map[string]*hnsw.Index),
-		executor:    executor.New(p),
-		stmtCache:   make(map[string]*Stmt),
-		closed:      false,
-	}
-
-	return db, nil
+	return newEngine(p).connect(MemoryPath), nil
 }
 
 // Path returns the file path of the database.
@@ -224,29 +265,22 @@ func (db *DB) Close() error {
 	}
 	db.stmtCache = nil
 
+	// Roll back the SQL transaction of the connection, which other
+	// connections of the engine may be waiting for
 	var closeErr error
-
-	// Close the executor first (syncs btree root pages to schema)
-	// This must happen before pager.Close() to persist changes
-	if db.executor != nil {
-		if err := db.executor.Close(); err != nil && closeErr == nil {
-			closeErr = err
-		}
+	if db.inTransaction() {
+		closeErr = db.executor.RollbackTransaction(db.tx)
 	}
-
-	// Close the pager (syncs and closes the file)
-	// Note: executor.Close() already calls pager.Close(), so check if pager is still open
-	// Actually, executor.Close() returns pager.Close() result, so we don't need to call it again
-	// But let's be safe and check if the pager wasn't already closed
-	// The pager.Close() is idempotent so this is safe
-
-	// Release the file lock and close lock file
-	if db.lockFile != nil {
-		unlockFile(db.lockFile)
-		db.lockFile.Close()
-		db.lockFile = nil
+	db.tx = nil
+	if db.txSnapshot {
+		db.pager.EndSnapshot()
+		db.txSnapshot = false
 	}
 
+	// Close the engine once no other connection uses it
+	if err := db.releaseLocked(); err != nil && closeErr == nil {
+		closeErr = err
+	}
 	return closeErr
 }
 
@@ -264,6 +298,35 @@ func (db *DB) IsInMemory() bool {
 	return db.path == MemoryPath
 }
 
+// CheckpointMode selects how far a checkpoint goes, as for PRAGMA wal_checkpoint
+type CheckpointMode = pager.CheckpointMode
+
+// Checkpoint modes, from least to most work
+const (
+	CheckpointPassive  = pager.CheckpointPassive
+	CheckpointFull     = pager.CheckpointFull
+	CheckpointRestart  = pager.CheckpointRestart
+	CheckpointTruncate = pager.CheckpointTruncate
+)
+
+// CheckpointResult reports the frames a checkpoint copied into the database
+// file and the frames still waiting in the WAL
+type CheckpointResult = pager.CheckpointResult
+
+// Checkpoint copies the committed WAL frames into the database file. The
+// restart and truncate modes also empty the WAL. While a transaction is open
+// every mode but passive reports Busy. In-memory databases have no WAL and
+// return an empty result.
+func (db *DB) Checkpoint(mode CheckpointMode) (CheckpointResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return CheckpointResult{}, ErrDatabaseClosed
+	}
+	return db.pager.Checkpoint(mode)
+}
+
 // Pager returns the underlying pager for advanced operations.
 // This is primarily for internal use.
 func (db *DB) Pager() *pager.Pager {
@@ -273,10 +336,11 @@ func (db *DB) Pager() *pager.Pager {
 }
 
 // Catalog returns the schema catalog for inspecting metadata.
+// This is the executor's catalog, so it reflects all executed DDL.
 func (db *DB) Catalog() *schema.Catalog {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	return db.catalog
+	return db.executor.GetCatalog()
 }
 
 // Exec executes a SQL statement and returns the result.
@@ -287,15 +351,24 @@ func (db *DB) Exec(sql string) (*QueryResult, error) {
 
 // ExecContext executes a SQL statement with context support.
 // The context can be used for cancellation and timeout control.
-// If the context is canceled or times out, the operation returns the context's error.
+// If the context is canceled or times out, the operation returns the context's error,
+// also while the statement runs, which then stops and takes back its changes.
 func (db *DB) ExecContext(ctx context.Context, sql string) (*QueryResult, error) {
 	// Check context before acquiring lock
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	stmt, err := parser.New(sql).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	unlock, err := db.lockFor(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Check context again after acquiring lock
 	if err := ctx.Err(); err != nil {
@@ -307,7 +380,7 @@ func (db *DB) ExecContext(ctx context.Context, sql string) (*QueryResult, error)
 	}
 
 	// Use the executor directly for non-parameterized queries
-	result, err := db.executor.Execute(sql)
+	result, err := db.executeLocked(ctx, stmt, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +388,113 @@ func (db *DB) ExecContext(ctx context.Context, sql string) (*QueryResult, error)
 	return convertQueryResult(result), nil
 }
 
+// lockFor locks the database for executing a statement, shared if the
+// executor can run it alongside other queries and exclusively otherwise, and
+// returns the matching unlock function. A nil statement stands for reading
+// pages directly, which is shared.
+//
+// A read-only database also starts a pager snapshot, which catches up with
+// the commits of the writer, and ends it on unlock. The executor is reloaded
+// first if the writer changed the database, which takes the lock
+// exclusively even for a query.
+func (db *DB) lockFor(stmt parser.Statement) (func(), error) {
+	db.mu.RLock()
+	if db.closed {
+		return db.mu.RUnlock, nil
+	}
+	shared := stmt == nil || (!db.inTransaction() && db.executor.ReadOnly(stmt))
+	if shared && !db.pager.ReadOnly() {
+		return db.mu.RUnlock, nil
+	}
+	db.mu.RUnlock()
+	db.mu.Lock()
+	if db.closed {
+		return db.mu.Unlock, nil
+	}
+
+	snapshot := db.pager.ReadOnly()
+	if snapshot {
+		if err := db.beginSnapshotLocked(); err != nil {
+			db.mu.Unlock()
+			return nil, err
+		}
+		if shared {
+			// Until the snapshot ends, later ones do not reload the executor
+			db.mu.Unlock()
+			db.mu.RLock()
+			return func() {
+				db.pager.EndSnapshot()
+				db.mu.RUnlock()
+			}, nil
+		}
+	}
+
+	// The statement runs in the SQL transaction of this connection; the
+	// executor has none between statements
+	db.executor.SetTransaction(db.tx)
+	return func() {
+		db.tx = db.executor.GetTransaction()
+		db.executor.SetTransaction(nil)
+		if snapshot {
+			db.syncTxSnapshotLocked()
+			db.pager.EndSnapshot()
+		}
+		db.mu.Unlock()
+	}, nil
+}
+
+// executeLocked executes a statement under the lock taken by lockFor. BEGIN
+// on a connection of a Pool starts the transaction on a snapshot, as
+// DB.Begin does, so that the other connections see its changes only once it
+// commits; COMMIT and ROLLBACK end it like any other.
+func (db *DB) executeLocked(ctx context.Context, stmt parser.Statement, params []types.Value) (*executor.Result, error) {
+	if _, ok := stmt.(*parser.BeginStmt); ok && db.pooled && !db.inTransaction() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		db.executor.SetTransaction(db.executor.BeginTransaction())
+		return &executor.Result{}, nil
+	}
+	return db.executor.ExecuteASTContext(ctx, stmt, params)
+}
+
+// inTransaction reports whether a SQL transaction begun with BEGIN is active
+// on the connection
+func (db *DB) inTransaction() bool {
+	return db.tx != nil && db.tx.IsActive()
+}
+
+// beginSnapshotLocked starts a pager snapshot of a read-only database and
+// reloads the executor if the writer changed the database since the last one
+func (db *DB) beginSnapshotLocked() error {
+	changed, err := db.pager.BeginSnapshot()
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := db.executor.Reload(); err != nil {
+			db.pager.EndSnapshot()
+			return err
+		}
+	}
+	return nil
+}
+
+// syncTxSnapshotLocked keeps a pager snapshot of a read-only database for as
+// long as a transaction begun with BEGIN is active, so that its statements
+// all read the same state
+func (db *DB) syncTxSnapshotLocked() {
+	active := db.inTransaction()
+	if active && !db.txSnapshot {
+		// Nested in the snapshot of the statement, which cannot fail
+		db.pager.BeginSnapshot()
+		db.txSnapshot = true
+	} else if !active && db.txSnapshot {
+		db.pager.EndSnapshot()
+		db.txSnapshot = false
+	}
+}
+
 // ExecResult represents the result of an Exec operation (for prepared statements)
 type ExecResult struct {
 	lastInsertID int64
@@ -427,6 +607,14 @@ func valueToGo(v types.Value) interface{} {
 		return v.Text()
 	case types.TypeBlob:
 		return v.Blob()
+	case types.TypeVarchar, types.TypeChar:
+		return v.Text()
+	case types.TypeJSON:
+		return v.JSON()
+	case types.TypeGUID:
+		return v.GUIDString()
+	case types.TypeDecimal:
+		return v.DecimalString()
 	case types.TypeVector:
 		return v.Vector()
 	case types.TypeDate:
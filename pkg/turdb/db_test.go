@@ -2,8 +2,10 @@
 package turdb
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -179,6 +181,263 @@ func TestDB_ReopenAfterClose(t *testing.T) {
 	}
 }
 
+func TestDB_ReadOnlyAlongsideWriter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "shared.db")
+
+	writer, err := OpenWithOptions(dbPath, Options{WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open writer: %v", err)
+	}
+	defer writer.Close()
+	if _, err := writer.Exec("CREATE TABLE items (id INT PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := writer.Exec("INSERT INTO items VALUES (1, 'one')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	reader, err := OpenWithOptions(dbPath, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	defer reader.Close()
+
+	count := func() string {
+		t.Helper()
+		result, err := reader.Exec("SELECT COUNT(*) FROM items")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		return fmt.Sprint(result.Rows)
+	}
+	if got := count(); got != "[[1]]" {
+		t.Errorf("reader counts %s, want [[1]]", got)
+	}
+	if _, err := reader.Exec("INSERT INTO items VALUES (2, 'two')"); err != ErrReadOnly {
+		t.Errorf("expected INSERT on the reader to fail with ErrReadOnly, got %v", err)
+	}
+
+	// Each statement sees the last commit, schema changes included
+	if _, err := writer.Exec("INSERT INTO items VALUES (2, 'two')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	if _, err := writer.Exec("CREATE TABLE tags (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if got := count(); got != "[[2]]" {
+		t.Errorf("reader counts %s after the insert, want [[2]]", got)
+	}
+	if _, err := reader.Exec("SELECT * FROM tags"); err != nil {
+		t.Errorf("reader does not see the new table: %v", err)
+	}
+	stmt, err := reader.Prepare("SELECT name FROM items WHERE id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer stmt.Close()
+	stmt.BindInt(1, 2)
+	rows, err := stmt.Query()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var name string
+	if !rows.Next() || rows.Scan(&name) != nil || name != "two" {
+		t.Errorf("expected the prepared lookup to find the new row, got %q", name)
+	}
+	rows.Close()
+
+	// A transaction keeps its snapshot
+	tx, err := reader.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := writer.Exec("INSERT INTO items VALUES (3, 'three')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	result, err := tx.Exec("SELECT COUNT(*) FROM items")
+	if err != nil {
+		t.Fatalf("SELECT in transaction failed: %v", err)
+	}
+	if got := fmt.Sprint(result.Rows); got != "[[2]]" {
+		t.Errorf("transaction counts %s, want its snapshot [[2]]", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if got := count(); got != "[[3]]" {
+		t.Errorf("reader counts %s after the transaction, want [[3]]", got)
+	}
+
+	// The reader outlives the writer
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	if got := count(); got != "[[3]]" {
+		t.Errorf("reader counts %s after the writer closed, want [[3]]", got)
+	}
+	if errs := reader.IntegrityCheck(); len(errs) != 0 {
+		t.Errorf("integrity check failed: %v", errs)
+	}
+}
+
+func TestDB_Checkpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := OpenWithOptions(dbPath, Options{WALAutoCheckpoint: -1})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	result, err := db.Checkpoint(CheckpointTruncate)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if result.Busy || result.FramesWritten == 0 || result.FramesRemaining != 0 {
+		t.Errorf("unexpected checkpoint result %+v", result)
+	}
+	if db.Pager().HasWAL() {
+		if info, err := os.Stat(dbPath + "-wal"); err != nil || info.Size() > 32 {
+			t.Errorf("expected an empty WAL file after truncate: %v, %v", info, err)
+		}
+	}
+
+	// A transaction holding the write lock makes every mode but passive busy
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("CREATE TABLE other (id INT)"); err != nil {
+		t.Fatalf("CREATE TABLE in transaction failed: %v", err)
+	}
+	if result, err := db.Checkpoint(CheckpointFull); err != nil || !result.Busy {
+		t.Errorf("expected a busy checkpoint during a transaction: %+v, %v", result, err)
+	}
+	if result, err := db.Checkpoint(CheckpointPassive); err != nil || result.Busy {
+		t.Errorf("expected a passive checkpoint to complete: %+v, %v", result, err)
+	}
+	tx.Rollback()
+
+	db.Close()
+	if _, err := db.Checkpoint(CheckpointPassive); err != ErrDatabaseClosed {
+		t.Errorf("expected ErrDatabaseClosed, got %v", err)
+	}
+}
+
+func TestDB_ConcurrentQueries(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE items (id INT PRIMARY KEY, grp INT, name TEXT)",
+		"CREATE INDEX idx_items_grp ON items (grp)",
+		"CREATE TABLE groups (id INT PRIMARY KEY, label TEXT)",
+		"INSERT INTO groups VALUES (0, 'even'), (1, 'odd')",
+	} {
+		if _, err := db.Exec(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := db.Exec(fmt.Sprintf("INSERT INTO items VALUES (%d, %d, 'item%d')", i, i%2, i)); err != nil {
+			t.Fatalf("INSERT failed: %v", err)
+		}
+	}
+
+	// Rows inserted during the test all have ids from 1000 on, so the
+	// queries keep their answers
+	queries := []string{
+		"SELECT name FROM items WHERE id = 17",
+		"SELECT id FROM items WHERE grp = 1 AND id < 200 ORDER BY id DESC LIMIT 5",
+		"SELECT grp, COUNT(*) FROM items WHERE id < 200 GROUP BY grp ORDER BY grp",
+		"SELECT g.label, COUNT(*) FROM items i JOIN groups g ON i.grp = g.id WHERE i.id < 200 GROUP BY g.label ORDER BY g.label",
+		"SELECT id FROM groups UNION SELECT id FROM items WHERE id < 3",
+		"EXPLAIN QUERY PLAN SELECT * FROM items WHERE grp = 0",
+	}
+	want := make([]string, len(queries))
+	for i, sql := range queries {
+		result, err := db.Exec(sql)
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		want[i] = fmt.Sprint(result.Rows)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			stmt, err := db.Prepare("SELECT name FROM items WHERE grp = ? AND id < 10 ORDER BY id")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer stmt.Close()
+			for n := 0; n < 20; n++ {
+				i := (r + n) % len(queries)
+				result, err := db.Exec(queries[i])
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", queries[i], err)
+					return
+				}
+				if got := fmt.Sprint(result.Rows); got != want[i] {
+					errs <- fmt.Errorf("%s returned %s, want %s", queries[i], got, want[i])
+					return
+				}
+				stmt.BindInt(1, int64(n%2))
+				rows, err := stmt.Query()
+				if err != nil {
+					errs <- err
+					return
+				}
+				count := 0
+				for rows.Next() {
+					count++
+				}
+				rows.Close()
+				if count != 5 {
+					errs <- fmt.Errorf("prepared query returned %d rows, want 5", count)
+					return
+				}
+			}
+		}(r)
+	}
+	// A writer runs alongside the readers
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; i < 1050; i++ {
+			if _, err := db.Exec(fmt.Sprintf("INSERT INTO items VALUES (%d, %d, 'new')", i, i%2)); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	result, err := db.Exec("SELECT COUNT(*) FROM items")
+	if err != nil || result.Rows[0][0] != int64(250) {
+		t.Errorf("expected 250 rows after the writer, got %v (%v)", result, err)
+	}
+}
+
 func TestValueToGo_DateTimeTypes(t *testing.T) {
 	// Test that valueToGo correctly converts date/time types
 	// These types should NOT return nil
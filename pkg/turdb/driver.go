@@ -0,0 +1,600 @@
+// pkg/turdb/driver.go
+package turdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tur/pkg/schema"
+	"tur/pkg/sql/parser"
+	"tur/pkg/types"
+)
+
+// DriverName is the name the database/sql driver registers under
+const DriverName = "turdb"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver for TurDB. A data source name is a
+// database path, or ":memory:", optionally followed by options as URL query
+// parameters:
+//
+//	sql.Open("turdb", "app.db?cache=1000&page_size=4096&wal_autocheckpoint=500")
+//	sql.Open("turdb", "app.db?mode=ro")
+//
+// The connections of a sql.DB share one engine, as those of a Pool do, and
+// each has its own transaction. Transactions have snapshot isolation.
+//
+// Besides the standard driver.Value types, statements take types.Value,
+// *types.Vector and []float32 for vectors, [16]byte arrays such as UUIDs for
+// GUIDs, *big.Int and *big.Float for decimals, and any integer type. Decimal
+// types that implement driver.Valuer as strings work too. VECTOR columns
+// come back as *types.Vector, GUIDs and decimals as strings.
+type Driver struct{}
+
+// Open opens a connection of its own to the database named by dsn
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector parses dsn once for all the connections of a sql.DB
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	path, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{driver: d, path: path, opts: opts}, nil
+}
+
+// NewConnector returns a connector for sql.OpenDB that opens the database at
+// path with opts
+func NewConnector(path string, opts Options) driver.Connector {
+	return &connector{driver: &Driver{}, path: path, opts: opts}
+}
+
+// parseDSN splits a data source name into the database path and options
+func parseDSN(dsn string) (string, Options, error) {
+	var opts Options
+	path, query, _ := strings.Cut(dsn, "?")
+	if path == "" {
+		return "", opts, errors.New("turdb: data source name has no database path")
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", opts, fmt.Errorf("turdb: invalid data source name: %w", err)
+	}
+	for name, values := range params {
+		value := values[len(values)-1]
+		switch name {
+		case "cache", "page_size", "wal_autocheckpoint":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", opts, fmt.Errorf("turdb: invalid %s %q", name, value)
+			}
+			switch name {
+			case "cache":
+				opts.CacheSize = n
+			case "page_size":
+				opts.PageSize = n
+			default:
+				opts.WALAutoCheckpoint = n
+			}
+		case "mode":
+			switch value {
+			case "ro":
+				opts.ReadOnly = true
+			case "rw":
+				opts.ReadOnly = false
+			default:
+				return "", opts, fmt.Errorf("turdb: invalid mode %q, want ro or rw", value)
+			}
+		default:
+			return "", opts, fmt.Errorf("turdb: unknown data source option %q", name)
+		}
+	}
+	return path, opts, nil
+}
+
+// connector opens the connections of a sql.DB on one engine, opened with the
+// first of them and closed with the sql.DB and the last of them
+type connector struct {
+	driver *Driver
+	path   string
+	opts   Options
+
+	mu     sync.Mutex
+	engine *engine
+	closed bool
+}
+
+// Connect opens a new connection
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrDatabaseClosed
+	}
+	if c.engine != nil {
+		return &conn{db: c.engine.connect(c.path)}, nil
+	}
+	db, err := OpenWithOptions(c.path, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	c.engine = db.engine
+	c.engine.mu.Lock()
+	c.engine.refs++
+	c.engine.mu.Unlock()
+	return &conn{db: db}, nil
+}
+
+// Driver returns the driver of the connector
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close lets go of the engine; connections still open keep it until they
+// close. database/sql calls it from DB.Close.
+func (c *connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.engine == nil {
+		c.closed = true
+		return nil
+	}
+	c.closed = true
+	c.engine.mu.Lock()
+	defer c.engine.mu.Unlock()
+	return c.engine.releaseLocked()
+}
+
+// conn is a database/sql connection
+type conn struct {
+	db *DB
+}
+
+var (
+	_ driver.Conn               = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+)
+
+// Prepare prepares a statement
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a statement
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s, err := c.db.Prepare(query)
+	if err != nil {
+		if errors.Is(err, ErrDatabaseClosed) {
+			return nil, driver.ErrBadConn
+		}
+		return nil, err
+	}
+	return &stmt{conn: c, stmt: s}, nil
+}
+
+// Close closes the connection, rolling back its transaction
+func (c *conn) Close() error {
+	return c.db.Close()
+}
+
+// Begin starts a transaction
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a transaction with snapshot isolation, the only level
+// TurDB has. Read-only transactions are not enforced.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelSnapshot:
+	default:
+		return nil, fmt.Errorf("turdb: unsupported isolation level %v", sql.IsolationLevel(opts.Isolation))
+	}
+	if err := c.db.beginConnTx(); err != nil {
+		if errors.Is(err, ErrDatabaseClosed) {
+			return nil, driver.ErrBadConn
+		}
+		return nil, err
+	}
+	return &connTx{db: c.db}, nil
+}
+
+// Ping reports whether the connection is open
+func (c *conn) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.db.IsClosed() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// IsValid reports whether database/sql may reuse the connection
+func (c *conn) IsValid() bool {
+	return !c.db.IsClosed()
+}
+
+// CheckNamedValue converts a statement argument to a types.Value, leaving
+// the types it does not know to the default conversion of database/sql
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, ok, err := namedValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return driver.ErrSkip
+	}
+	nv.Value = v
+	return nil
+}
+
+// namedValue converts an argument of a type TurDB has a column type for. ok
+// is false for the other types.
+func namedValue(arg interface{}) (types.Value, bool, error) {
+	if _, ok := arg.(driver.Valuer); ok {
+		// Converted by its Value method first
+		return types.Value{}, false, nil
+	}
+	switch v := arg.(type) {
+	case nil:
+		return types.NewNull(), true, nil
+	case types.Value:
+		return v, true, nil
+	case *types.Vector:
+		// VECTOR columns hold vectors encoded as blobs
+		if v == nil {
+			return types.NewNull(), true, nil
+		}
+		return types.NewBlob(v.ToBytes()), true, nil
+	case []float32:
+		if v == nil {
+			return types.NewNull(), true, nil
+		}
+		return types.NewBlob(types.NewVector(v).ToBytes()), true, nil
+	case *big.Int:
+		if v == nil {
+			return types.NewNull(), true, nil
+		}
+		return types.NewText(v.String()), true, nil
+	case *big.Float:
+		if v == nil {
+			return types.NewNull(), true, nil
+		}
+		return types.NewText(v.Text('f', -1)), true, nil
+	case bool:
+		if v {
+			return types.NewInt(1), true, nil
+		}
+		return types.NewInt(0), true, nil
+	case time.Time:
+		return types.NewTimestampTZ(v), true, nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.NewInt(rv.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return types.Value{}, false, fmt.Errorf("turdb: uint64 value %d overflows BIGINT", rv.Uint())
+		}
+		return types.NewInt(int64(rv.Uint())), true, nil
+	case reflect.Float32, reflect.Float64:
+		return types.NewFloat(rv.Float()), true, nil
+	case reflect.String:
+		return types.NewText(rv.String()), true, nil
+	case reflect.Array:
+		// UUID types are [16]byte arrays
+		if rv.Len() == 16 && rv.Type().Elem().Kind() == reflect.Uint8 {
+			var uuid [16]byte
+			reflect.Copy(reflect.ValueOf(uuid[:]), rv)
+			return types.NewGUID(uuid), true, nil
+		}
+	}
+	return types.Value{}, false, nil
+}
+
+// driverValue converts a statement argument that went through the default
+// conversion of database/sql, or through CheckNamedValue
+func driverValue(arg interface{}) (types.Value, error) {
+	if v, ok, err := namedValue(arg); ok || err != nil {
+		return v, err
+	}
+	if b, ok := arg.([]byte); ok {
+		return types.NewBlob(b), nil
+	}
+	return types.Value{}, fmt.Errorf("turdb: unsupported argument type %T", arg)
+}
+
+// connTx is a transaction begun with BeginTx
+type connTx struct {
+	db *DB
+}
+
+// Commit commits the transaction
+func (tx *connTx) Commit() error {
+	return tx.db.endConnTx(true)
+}
+
+// Rollback rolls the transaction back
+func (tx *connTx) Rollback() error {
+	return tx.db.endConnTx(false)
+}
+
+// stmt is a database/sql prepared statement
+type stmt struct {
+	conn *conn
+	stmt *Stmt
+}
+
+var (
+	_ driver.StmtExecContext  = (*stmt)(nil)
+	_ driver.StmtQueryContext = (*stmt)(nil)
+)
+
+// Close closes the statement
+func (s *stmt) Close() error {
+	return s.stmt.Close()
+}
+
+// NumInput returns the number of placeholders
+func (s *stmt) NumInput() int {
+	return s.stmt.NumParams()
+}
+
+// Exec executes the statement
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+// Query executes the query
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// ExecContext executes the statement
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.bind(args); err != nil {
+		return nil, err
+	}
+	result, err := s.stmt.ExecContext(ctx)
+	if err != nil {
+		return nil, s.conn.err(err)
+	}
+	return execResult{result}, nil
+}
+
+// execResult is the driver.Result of an ExecResult
+type execResult struct {
+	result ExecResult
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return r.result.LastInsertId(), nil
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.result.RowsAffected(), nil
+}
+
+// QueryContext executes the query
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.bind(args); err != nil {
+		return nil, err
+	}
+	r, err := s.stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, s.conn.err(err)
+	}
+	return &rows{rows: r, types: s.conn.db.columnTypes(s.stmt.cachedAST, r)}, nil
+}
+
+// bind binds the arguments to the placeholders, by name for sql.Named
+// arguments and by position otherwise
+func (s *stmt) bind(args []driver.NamedValue) error {
+	s.stmt.ClearBindings()
+	for _, arg := range args {
+		v, err := driverValue(arg.Value)
+		if err != nil {
+			return err
+		}
+		if arg.Name != "" {
+			if err := s.stmt.BindNamed(arg.Name, v); err != nil {
+				return fmt.Errorf("turdb: %w: %s", err, arg.Name)
+			}
+			continue
+		}
+		if err := s.stmt.BindValue(arg.Ordinal, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// err maps an error of a closed connection to driver.ErrBadConn, so that
+// database/sql retries on another connection
+func (c *conn) err(err error) error {
+	if errors.Is(err, ErrDatabaseClosed) {
+		return driver.ErrBadConn
+	}
+	return err
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// columnType describes a result column for database/sql
+type columnType struct {
+	// name is the database type name, or "" if unknown
+	name string
+	// nullable and nullableKnown report whether the column may be NULL, as
+	// far as its table declares it
+	nullable      bool
+	nullableKnown bool
+}
+
+// columnTypes describes the columns of a query result. Columns that select
+// a column of a single table have its declared type; the others have the
+// type of their first value that is not NULL.
+func (db *DB) columnTypes(stmt parser.Statement, r *Rows) []columnType {
+	values := r.rows
+	if r.singleRow != nil {
+		values = [][]types.Value{r.singleRow}
+	}
+	cols := make([]columnType, len(r.columns))
+	for i := range cols {
+		for _, row := range values {
+			if i < len(row) && !row[i].IsNull() {
+				cols[i].name = row[i].Type().String()
+				break
+			}
+		}
+	}
+
+	sel, ok := stmt.(*parser.SelectStmt)
+	if !ok {
+		return cols
+	}
+	table, ok := sel.From.(*parser.Table)
+	if !ok {
+		return cols
+	}
+	db.mu.RLock()
+	def := db.executor.GetCatalog().GetTable(table.Name)
+	db.mu.RUnlock()
+	if def == nil {
+		return cols
+	}
+
+	var declared []*schema.ColumnDef
+	for _, c := range sel.Columns {
+		switch {
+		case c.Star:
+			for i := range def.Columns {
+				declared = append(declared, &def.Columns[i])
+			}
+		default:
+			var col *schema.ColumnDef
+			if ref, ok := c.Expr.(*parser.ColumnRef); ok {
+				name := ref.Name
+				if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+					name = name[dot+1:]
+				}
+				if i := def.GetColumnIndex(name); i >= 0 {
+					col = &def.Columns[i]
+				}
+			}
+			declared = append(declared, col)
+		}
+	}
+	if len(declared) != len(cols) {
+		return cols
+	}
+	for i, col := range declared {
+		if col == nil {
+			continue
+		}
+		notNull := col.NotNull || col.PrimaryKey ||
+			col.HasConstraint(schema.ConstraintNotNull) || col.HasConstraint(schema.ConstraintPrimaryKey)
+		cols[i] = columnType{name: col.Type.String(), nullable: !notNull, nullableKnown: true}
+	}
+	return cols
+}
+
+// rows is a database/sql result set
+type rows struct {
+	rows  *Rows
+	types []columnType
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rows)(nil)
+)
+
+// Columns returns the column names
+func (r *rows) Columns() []string {
+	return r.rows.Columns()
+}
+
+// Close closes the result set
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+// Next moves to the next row and copies its values into dest
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	for i := range dest {
+		v := r.rows.ColumnValue(i)
+		if r.types[i].name == "VECTOR" && v.Type() == types.TypeBlob {
+			vec, err := types.VectorFromBytes(v.Blob())
+			if err != nil {
+				return err
+			}
+			dest[i] = vec
+			continue
+		}
+		dest[i] = valueToGo(v)
+	}
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns the type name of a column, such as
+// "INT", "TEXT" or "VECTOR", or "" if unknown
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.types[index].name
+}
+
+// ColumnTypeNullable reports whether a column may be NULL, if known
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return r.types[index].nullable, r.types[index].nullableKnown
+}
@@ -0,0 +1,212 @@
+// pkg/turdb/driver_test.go
+package turdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"tur/pkg/types"
+)
+
+func openSQL(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDriver_ExecAndQuery(t *testing.T) {
+	db := openSQL(t, filepath.Join(t.TempDir(), "test.db")+"?cache=500&page_size=4096")
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	if _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY, name TEXT NOT NULL, price DECIMAL(10, 2), ref GUID, embedding VECTOR(3), note TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	uuid := [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 1, 2, 3, 4, 5, 6, 7, 8}
+	result, err := db.Exec("INSERT INTO items VALUES (?, ?, ?, ?, ?, ?)",
+		uint8(1), "widget", big.NewFloat(9.5), uuid, []float32{1, 0, 0}, nil)
+	if err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 1 {
+		t.Errorf("RowsAffected = %d, %v; want 1", n, err)
+	}
+	if _, err := db.Exec("INSERT INTO items (id, name) VALUES (?, ?)", 2, "gadget"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var (
+		name, price, ref string
+		embedding        *types.Vector
+		note             sql.NullString
+	)
+	row := db.QueryRow("SELECT name, price, ref, embedding, note FROM items WHERE id = ?", 1)
+	if err := row.Scan(&name, &price, &ref, &embedding, &note); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if name != "widget" || price != "9.50" || ref != "12345678-9abc-def0-0102-030405060708" || note.Valid {
+		t.Errorf("unexpected row %q %q %q %v", name, price, ref, note)
+	}
+	if embedding == nil || embedding.Dimension() != 3 {
+		t.Errorf("unexpected vector %v", embedding)
+	}
+
+	rows, err := db.Query("SELECT id, name, note, LENGTH(name) FROM items ORDER BY id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes failed: %v", err)
+	}
+	want := []struct {
+		name             string
+		nullable, nullOK bool
+	}{
+		{"INT", false, true},
+		{"TEXT", false, true},
+		{"TEXT", true, true},
+		// Computed columns have the type of their values
+		{"INT", false, false},
+	}
+	for i, c := range columns {
+		nullable, ok := c.Nullable()
+		if c.DatabaseTypeName() != want[i].name {
+			t.Errorf("column %d type %q, want %q", i, c.DatabaseTypeName(), want[i].name)
+		}
+		if nullable != want[i].nullable || ok != want[i].nullOK {
+			t.Errorf("column %d nullable %v, %v; want %v, %v", i, nullable, ok, want[i].nullable, want[i].nullOK)
+		}
+	}
+	var ids []int64
+	for rows.Next() {
+		var id, length int64
+		var name string
+		var note sql.NullString
+		if err := rows.Scan(&id, &name, &note, &length); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows failed: %v", err)
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+
+	// Named arguments bind the parameters of that name
+	if err := db.QueryRow("SELECT name FROM items WHERE id = :id OR id = :id * 10", sql.Named("id", 2)).Scan(&name); err != nil || name != "gadget" {
+		t.Errorf("named query returned %q, %v; want gadget", name, err)
+	}
+	if _, err := db.Exec("SELECT * FROM items WHERE id = :id", sql.Named("key", 1)); err == nil {
+		t.Error("expected an argument for a missing name to be rejected")
+	}
+}
+
+func TestDriver_Transactions(t *testing.T) {
+	db := openSQL(t, filepath.Join(t.TempDir(), "test.db"))
+	if _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY, qty INT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO items VALUES (1, 10)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	count := func(q interface {
+		QueryRow(string, ...interface{}) *sql.Row
+	}) int64 {
+		t.Helper()
+		var n int64
+		if err := q.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+			t.Fatalf("COUNT failed: %v", err)
+		}
+		return n
+	}
+
+	// Changes stay in the transaction until it commits; other connections
+	// keep working meanwhile
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO items VALUES (?, ?)", 2, 20); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	var qty int64
+	if err := tx.QueryRow("SELECT qty FROM items WHERE id = ?", 2).Scan(&qty); err != nil || qty != 20 {
+		t.Errorf("transaction does not see its own row: %d, %v", qty, err)
+	}
+	if n := count(tx); n != 2 {
+		t.Errorf("transaction counts %d rows, want 2", n)
+	}
+	if n := count(db); n != 1 {
+		t.Errorf("other connections count %d rows, want 1", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if n := count(db); n != 2 {
+		t.Errorf("counted %d rows after commit, want 2", n)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM items"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if n := count(db); n != 2 {
+		t.Errorf("counted %d rows after rollback, want 2", n)
+	}
+
+	if _, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}); err == nil {
+		t.Error("expected serializable isolation to be rejected")
+	}
+}
+
+func TestDriver_DSN(t *testing.T) {
+	path, opts, err := parseDSN("app.db?cache=200&page_size=8192&wal_autocheckpoint=-1&mode=ro")
+	if err != nil {
+		t.Fatalf("parseDSN failed: %v", err)
+	}
+	if path != "app.db" || opts.CacheSize != 200 || opts.PageSize != 8192 || opts.WALAutoCheckpoint != -1 || !opts.ReadOnly {
+		t.Errorf("unexpected options %q %+v", path, opts)
+	}
+	for _, dsn := range []string{"", "app.db?cache=lots", "app.db?mode=rwc", "app.db?journal=off"} {
+		if _, _, err := parseDSN(dsn); err == nil {
+			t.Errorf("expected %q to be rejected", dsn)
+		}
+	}
+
+	// A read-only sql.DB opens alongside a writer
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	writer := openSQL(t, dbPath)
+	if _, err := writer.Exec("CREATE TABLE items (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	reader := openSQL(t, dbPath+"?mode=ro")
+	if _, err := writer.Exec("INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	var n int64
+	if err := reader.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil || n != 1 {
+		t.Errorf("reader counts %d, %v; want 1", n, err)
+	}
+	if _, err := reader.Exec("INSERT INTO items VALUES (2)"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+}
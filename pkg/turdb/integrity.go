@@ -8,6 +8,7 @@ import (
 	"tur/pkg/pager"
 	"tur/pkg/record"
 	"tur/pkg/schema"
+	"tur/pkg/tree"
 	"tur/pkg/types"
 )
 
@@ -68,8 +69,12 @@ func (e IntegrityError) Error() string {
 //
 // Returns a slice of IntegrityError. Empty slice means no errors found.
 func (db *DB) IntegrityCheck() []IntegrityError {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	// A read-only database checks the last commit of the writer
+	unlock, err := db.lockFor(nil)
+	if err != nil {
+		return []IntegrityError{{Type: "database", Message: err.Error()}}
+	}
+	defer unlock()
 
 	if db.closed {
 		return []IntegrityError{{
@@ -86,6 +91,7 @@ func (db *DB) IntegrityCheck() []IntegrityError {
 			errors = append(errors, btreeErrors...)
 		}
 	}
+	errors = append(errors, db.checkTableTrees()...)
 
 	// Check index consistency with table data
 	if indexErrors := db.checkIndexConsistency(); len(indexErrors) > 0 {
@@ -103,8 +109,12 @@ func (db *DB) IntegrityCheck() []IntegrityError {
 // QuickCheck performs a faster integrity check that skips some validations.
 // It checks B-tree structure but skips foreign key and full index verification.
 func (db *DB) QuickCheck() []IntegrityError {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	// A read-only database checks the last commit of the writer
+	unlock, err := db.lockFor(nil)
+	if err != nil {
+		return []IntegrityError{{Type: "database", Message: err.Error()}}
+	}
+	defer unlock()
 
 	if db.closed {
 		return []IntegrityError{{
@@ -121,6 +131,7 @@ func (db *DB) QuickCheck() []IntegrityError {
 			errors = append(errors, btreeErrors...)
 		}
 	}
+	errors = append(errors, db.checkTableTrees()...)
 
 	return errors
 }
@@ -139,6 +150,42 @@ func (db *DB) checkBTreeIntegrity(tableName string, tree *btree.BTree) []Integri
 		})
 	}
 
+	// Validate the overflow page chains of large records
+	for _, err := range tree.CheckOverflowPages() {
+		errors = append(errors, IntegrityError{
+			Type:    "btree",
+			Table:   tableName,
+			Page:    tree.RootPage(),
+			Message: err.Error(),
+		})
+	}
+
+	return errors
+}
+
+// checkTableTrees runs the storage checks of the executor's table trees, which
+// cover the overflow page chains holding large records
+func (db *DB) checkTableTrees() []IntegrityError {
+	if db.executor == nil {
+		return nil
+	}
+
+	var errors []IntegrityError
+	for _, tableName := range db.executor.GetCatalog().ListTables() {
+		tableTree := db.executor.GetTableTree(tableName)
+		checker, ok := tableTree.(tree.TreeWithIntegrityCheck)
+		if !ok {
+			continue
+		}
+		for _, err := range checker.CheckIntegrity() {
+			errors = append(errors, IntegrityError{
+				Type:    "btree",
+				Table:   tableName,
+				Page:    tableTree.RootPage(),
+				Message: err.Error(),
+			})
+		}
+	}
 	return errors
 }
 
@@ -334,7 +381,7 @@ func (db *DB) verifyForeignKeyValues(
 			for cursor.Valid() {
 				value := cursor.Value()
 				if value != nil {
-					values := record.Decode(value)
+					values := table.FillMissingColumns(record.Decode(value))
 					if len(values) > colIdx {
 						fkValue := values[colIdx]
 						if fkValue.Type() != types.TypeNull {
@@ -383,7 +430,7 @@ func (db *DB) verifyForeignKeyValues(
 	for refCursor.Valid() {
 		value := refCursor.Value()
 		if value != nil {
-			values := record.Decode(value)
+			values := refTable.FillMissingColumns(record.Decode(value))
 			if len(values) > refColIdx {
 				refValue := values[refColIdx]
 				// Use string representation as map key
@@ -403,7 +450,7 @@ func (db *DB) verifyForeignKeyValues(
 		rowNum++
 		value := cursor.Value()
 		if value != nil {
-			values := record.Decode(value)
+			values := table.FillMissingColumns(record.Decode(value))
 			if len(values) > colIdx {
 				fkValue := values[colIdx]
 				// NULL values are always valid (no reference)
@@ -475,8 +522,12 @@ func compareBytes(a, b []byte) int {
 // - Torn page writes
 // Returns a slice of IntegrityError. Empty slice means no corruption found.
 func (db *DB) CorruptionCheck() []IntegrityError {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	// A read-only database checks the last commit of the writer
+	unlock, err := db.lockFor(nil)
+	if err != nil {
+		return []IntegrityError{{Type: "database", Message: err.Error()}}
+	}
+	defer unlock()
 
 	if db.closed {
 		return []IntegrityError{{
@@ -508,8 +559,11 @@ func (db *DB) CorruptionCheck() []IntegrityError {
 // CheckPage checks a specific page for corruption.
 // Returns nil if the page is not corrupted.
 func (db *DB) CheckPage(pageNo uint32) *IntegrityError {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	unlock, err := db.lockFor(nil)
+	if err != nil {
+		return &IntegrityError{Type: "database", Page: pageNo, Message: err.Error()}
+	}
+	defer unlock()
 
 	if db.closed {
 		return &IntegrityError{
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -415,5 +416,63 @@ func TestCheckPage_ClosedDatabase(t *testing.T) {
 func cleanupFiles(path string) {
 	os.Remove(path)
 	os.Remove(path + "-wal")
-	os.Remove(path + ".lock")
+	os.Remove(path + "-shm")
+}
+
+func TestIntegrityCheck_OverflowRecords(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	_, err = db.Exec("CREATE TABLE docs (id INT PRIMARY KEY, body TEXT)")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// Rows far larger than a page are stored on overflow pages
+	body := strings.Repeat("overflow ", 3000)
+	for i := 1; i <= 5; i++ {
+		_, err = db.Exec(fmt.Sprintf("INSERT INTO docs (id, body) VALUES (%d, '%s')", i, body))
+		if err != nil {
+			t.Fatalf("Failed to insert row %d: %v", i, err)
+		}
+	}
+	_, err = db.Exec("UPDATE docs SET body = 'short' WHERE id = 2")
+	if err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	_, err = db.Exec("DELETE FROM docs WHERE id = 3")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	if errors := db.IntegrityCheck(); len(errors) != 0 {
+		t.Errorf("Expected no integrity errors, got %d: %v", len(errors), errors)
+	}
+	db.Close()
+
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	result, err := db.Exec("SELECT body FROM docs WHERE id = 4")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(result.Rows))
+	}
+	if got, _ := result.Rows[0][0].(string); got != body {
+		t.Errorf("Expected %d byte body after reopen, got %v", len(body), len(got))
+	}
+
+	if errors := db.IntegrityCheck(); len(errors) != 0 {
+		t.Errorf("Expected no integrity errors after reopen, got %d: %v", len(errors), errors)
+	}
 }
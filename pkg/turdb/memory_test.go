@@ -20,8 +20,8 @@ func TestOpenMemoryDatabase(t *testing.T) {
 	if _, err := os.Stat(":memory:"); !os.IsNotExist(err) {
 		t.Error("In-memory database should not create files on disk")
 	}
-	if _, err := os.Stat(":memory:.lock"); !os.IsNotExist(err) {
-		t.Error("In-memory database should not create lock files")
+	if _, err := os.Stat(":memory:-shm"); !os.IsNotExist(err) {
+		t.Error("In-memory database should not create shared-memory files")
 	}
 	if _, err := os.Stat(":memory:-wal"); !os.IsNotExist(err) {
 		t.Error("In-memory database should not create WAL files")
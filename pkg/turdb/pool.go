@@ -3,6 +3,7 @@ package turdb
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"sync"
 	"time"
@@ -61,11 +62,30 @@ type PoolStats struct {
 
 	// MissCount is the number of Get() calls that required creating a new connection
 	MissCount int64
+
+	// NumWaiting is the number of Get() calls waiting for a connection
+	NumWaiting int
+
+	// WaitCount is the total number of Get() calls that had to wait for a
+	// connection
+	WaitCount int64
+
+	// WaitDuration is the total time Get() calls spent waiting, including
+	// those that gave up when their context ended
+	WaitDuration time.Duration
 }
 
 // Pool manages a pool of database connections for concurrent access.
 // It maintains a queue of idle connections and creates new ones as needed,
-// up to the configured maximum.
+// up to the configured maximum. When every connection is in use, Get waits
+// for one to be returned.
+//
+// The connections share one pager, catalog and set of HNSW indexes, opened
+// with the first of them and closed with the pool and the last of them.
+// Each has its own SQL transaction: BEGIN on one connection does not apply
+// to the statements of the others. Like transactions begun with DB.Begin, it
+// reads a snapshot of the database and the other connections see its
+// changes only once it commits.
 type Pool struct {
 	mu sync.Mutex
 
@@ -87,6 +107,15 @@ type Pool struct {
 	// connMeta tracks metadata for all open connections by DB pointer
 	connMeta map[*DB]*poolConn
 
+	// engine is the state the connections share, nil until the first one
+	// opens. The pool holds a reference to it until it closes.
+	engine *engine
+
+	// waiters holds a channel for each Get waiting for a connection, in
+	// arrival order. Put hands a connection over through it, and a nil one
+	// tells the waiter to look again.
+	waiters *list.List
+
 	// numOpen is the total number of open connections (idle + in-use)
 	numOpen int
 
@@ -100,6 +129,8 @@ type Pool struct {
 	totalClosed  int64
 	hitCount     int64
 	missCount    int64
+	waitCount    int64
+	waitDuration time.Duration
 }
 
 // OpenPool creates a new connection pool for the given database path.
@@ -127,6 +158,7 @@ func OpenPoolWithPoolOptions(path string, maxConns int, poolOpts PoolOptions) (*
 		maxConns: maxConns,
 		idle:     list.New(),
 		connMeta: make(map[*DB]*poolConn),
+		waiters:  list.New(),
 		numOpen:  0,
 		closed:   false,
 	}
@@ -170,6 +202,21 @@ func (p *Pool) Close() error {
 	p.idle.Init() // Clear the list
 	p.numOpen = 0
 
+	// Waiters find the pool closed
+	for p.waiters.Len() > 0 {
+		p.wakeLocked()
+	}
+
+	// Connections still in use keep the engine open until they are put back
+	if p.engine != nil {
+		p.engine.mu.Lock()
+		if err := p.engine.releaseLocked(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+		p.engine.mu.Unlock()
+		p.engine = nil
+	}
+
 	return closeErr
 }
 
@@ -183,9 +230,20 @@ type poolConn struct {
 
 // Get retrieves a connection from the pool.
 // If an idle connection is available, it is returned immediately.
-// Otherwise, a new connection is created if under the maxConns limit.
+// Otherwise, a new connection is created if under the maxConns limit, or Get
+// waits for a connection to be returned.
 // The returned connection must be returned to the pool using Put when done.
 func (p *Pool) Get() (*DB, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext is Get with a context bounding the wait for a connection. If the
+// context is canceled or times out first, it returns the context's error.
+func (p *Pool) GetContext(ctx context.Context) (*DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -195,37 +253,118 @@ func (p *Pool) Get() (*DB, error) {
 
 	p.totalGets++
 
-	// Try to get an idle connection
-	if p.idle.Len() > 0 {
-		elem := p.idle.Front()
-		p.idle.Remove(elem)
-		pc := elem.Value.(*poolConn)
-		p.hitCount++
-		return pc.db, nil
-	}
+	var waitStart time.Time
+	for {
+		// Try to get an idle connection
+		if p.idle.Len() > 0 {
+			elem := p.idle.Front()
+			p.idle.Remove(elem)
+			pc := elem.Value.(*poolConn)
+			p.hitCount++
+			return pc.db, nil
+		}
 
-	// No idle connection, create a new one if under limit
-	if p.numOpen < p.maxConns {
-		db, err := OpenWithOptions(p.path, p.opts)
+		// No idle connection, create a new one if under limit
+		if p.numOpen < p.maxConns {
+			db, err := p.connectLocked()
+			if err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			pc := &poolConn{
+				db:        db,
+				pool:      p,
+				createdAt: now,
+			}
+			p.connMeta[db] = pc
+			p.numOpen++
+			p.totalCreated++
+			p.missCount++
+			return db, nil
+		}
+
+		// At max connections, wait for one to be returned
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+			p.waitCount++
+		}
+		wake := make(chan *poolConn, 1)
+		elem := p.waiters.PushBack(wake)
+		p.mu.Unlock()
+
+		var pc *poolConn
+		var err error
+		select {
+		case pc = <-wake:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		p.mu.Lock()
 		if err != nil {
+			p.waiters.Remove(elem)
+			// Put may have handed over a connection meanwhile
+			select {
+			case pc := <-wake:
+				if pc != nil {
+					p.releaseLocked(pc)
+				} else {
+					p.wakeLocked()
+				}
+			default:
+			}
+			p.waitDuration += time.Since(waitStart)
 			return nil, err
 		}
-		now := time.Now()
-		pc := &poolConn{
-			db:        db,
-			pool:      p,
-			createdAt: now,
+		if pc != nil {
+			p.waitDuration += time.Since(waitStart)
+			p.hitCount++
+			return pc.db, nil
+		}
+		if p.closed {
+			p.waitDuration += time.Since(waitStart)
+			return nil, ErrPoolClosed
 		}
-		p.connMeta[db] = pc
-		p.numOpen++
-		p.totalCreated++
-		p.missCount++
-		return db, nil
 	}
+}
 
-	// At max connections - for now, return an error
-	// (blocking wait could be added later)
-	return nil, errors.New("connection pool exhausted")
+// connectLocked opens a new connection to the engine of the pool, opening
+// the engine with the first one
+func (p *Pool) connectLocked() (*DB, error) {
+	if p.engine != nil {
+		return p.engine.connect(p.path), nil
+	}
+	db, err := OpenWithOptions(p.path, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	p.engine = db.engine
+	p.engine.mu.Lock()
+	p.engine.refs++
+	p.engine.pooled = true
+	p.engine.mu.Unlock()
+	return db, nil
+}
+
+// releaseLocked hands a connection over to the first waiting Get, or makes
+// it idle
+func (p *Pool) releaseLocked(pc *poolConn) {
+	if elem := p.waiters.Front(); elem != nil {
+		p.waiters.Remove(elem)
+		elem.Value.(chan *poolConn) <- pc
+		return
+	}
+	pc.idleSince = time.Now()
+	p.idle.PushBack(pc)
+}
+
+// wakeLocked tells the first waiting Get, if any, to look again, after a
+// connection closed or the pool did
+func (p *Pool) wakeLocked() {
+	if elem := p.waiters.Front(); elem != nil {
+		p.waiters.Remove(elem)
+		elem.Value.(chan *poolConn) <- nil
+	}
 }
 
 // Put returns a connection to the pool.
@@ -266,12 +405,12 @@ func (p *Pool) Put(conn *DB) {
 		delete(p.connMeta, conn)
 		p.numOpen--
 		p.totalClosed++
+		p.wakeLocked()
 		return
 	}
 
-	// Update idle timestamp and return to pool
-	pc.idleSince = now
-	p.idle.PushBack(pc)
+	// Hand the connection over to a waiting Get, or return it to the pool
+	p.releaseLocked(pc)
 }
 
 // NumOpen returns the number of open connections (idle + in-use).
@@ -337,5 +476,8 @@ func (p *Pool) Stats() PoolStats {
 		TotalClosed:  p.totalClosed,
 		HitCount:     p.hitCount,
 		MissCount:    p.missCount,
+		NumWaiting:   p.waiters.Len(),
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
 	}
 }
@@ -2,6 +2,8 @@
 package turdb
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -135,9 +137,6 @@ func TestPool_Get_RespectsMaxConns(t *testing.T) {
 
 	dbPath := filepath.Join(tmpDir, "test.db")
 
-	// Note: Due to exclusive file locking, only one connection can be open
-	// at a time to a given database file. The pool still provides value
-	// by managing connection reuse and lifecycle.
 	pool, err := OpenPool(dbPath, 1)
 	if err != nil {
 		t.Fatalf("OpenPool failed: %v", err)
@@ -470,3 +469,218 @@ func TestPool_Stats_NumInUse(t *testing.T) {
 		t.Errorf("expected NumIdle=1 after Put, got %d", stats.NumIdle)
 	}
 }
+
+func TestPool_GetContext_Waits(t *testing.T) {
+	pool, err := OpenPool(filepath.Join(t.TempDir(), "test.db"), 1)
+	if err != nil {
+		t.Fatalf("OpenPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// The deadline passes while the only connection is in use
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GetContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	// A waiting Get receives the connection that is put back
+	got := make(chan *DB, 1)
+	go func() {
+		db, err := pool.GetContext(context.Background())
+		if err != nil {
+			t.Errorf("GetContext failed: %v", err)
+		}
+		got <- db
+	}()
+	for pool.Stats().NumWaiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Put(conn)
+	select {
+	case db := <-got:
+		if db != conn {
+			t.Error("expected the waiting Get to receive the returned connection")
+		}
+		pool.Put(db)
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting Get did not receive the connection")
+	}
+
+	stats := pool.Stats()
+	if stats.WaitCount != 2 || stats.WaitDuration < 20*time.Millisecond || stats.NumWaiting != 0 {
+		t.Errorf("unexpected wait stats %+v", stats)
+	}
+	if stats.NumOpen != 1 {
+		t.Errorf("expected waiting not to open connections, NumOpen=%d", stats.NumOpen)
+	}
+
+	// Closing the pool ends the wait
+	conn, _ = pool.Get()
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.Get()
+		done <- err
+	}()
+	for pool.Stats().NumWaiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Close()
+	if err := <-done; err != ErrPoolClosed {
+		t.Errorf("expected ErrPoolClosed, got %v", err)
+	}
+	pool.Put(conn)
+}
+
+func TestPool_SharedEngine(t *testing.T) {
+	pool, err := OpenPool(filepath.Join(t.TempDir(), "test.db"), 2)
+	if err != nil {
+		t.Fatalf("OpenPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	conn1, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get #1 failed: %v", err)
+	}
+	conn2, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get #2 failed: %v", err)
+	}
+	if conn1 == conn2 || conn1.Pager() != conn2.Pager() {
+		t.Fatal("expected two connections sharing one pager")
+	}
+
+	// The catalog is shared
+	if _, err := conn1.Exec("CREATE TABLE items (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := conn2.Exec("INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatalf("INSERT on the second connection failed: %v", err)
+	}
+
+	// Transactions begun with BEGIN belong to their connection
+	if _, err := conn1.Exec("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := conn2.Exec("COMMIT"); err == nil {
+		t.Error("expected COMMIT on the other connection to find no transaction")
+	}
+	if _, err := conn1.Exec("INSERT INTO items VALUES (2)"); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	if _, err := conn1.Exec("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+	result, err := conn2.Exec("SELECT COUNT(*) FROM items")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != int64(1) {
+		t.Errorf("expected the rollback to leave 1 row, got %v", result.Rows)
+	}
+
+	// A connection closed in a transaction rolls it back, and the engine
+	// stays open for the others
+	if _, err := conn1.Exec("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := conn1.Exec("INSERT INTO items VALUES (3)"); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	conn1.Close()
+	if _, err := conn2.Exec("INSERT INTO items VALUES (4)"); err != nil {
+		t.Fatalf("INSERT after the other connection closed failed: %v", err)
+	}
+	result, err = conn2.Exec("SELECT id FROM items ORDER BY id")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 2 {
+		t.Errorf("expected rows 1 and 4, got %v", result.Rows)
+	}
+	pool.Put(conn1)
+	pool.Put(conn2)
+}
+
+func TestPool_TransactionIsolation(t *testing.T) {
+	pool, err := OpenPool(filepath.Join(t.TempDir(), "test.db"), 2)
+	if err != nil {
+		t.Fatalf("OpenPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	conn1, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get #1 failed: %v", err)
+	}
+	defer pool.Put(conn1)
+	conn2, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get #2 failed: %v", err)
+	}
+	defer pool.Put(conn2)
+
+	if _, err := conn1.Exec("CREATE TABLE items (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	count := func(conn *DB) int64 {
+		t.Helper()
+		result, err := conn.Exec("SELECT COUNT(*) FROM items")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		return result.Rows[0][0].(int64)
+	}
+
+	// The rows a transaction inserts stay its own until it commits
+	if _, err := conn1.Exec("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := conn1.Exec("INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	if n := count(conn2); n != 0 {
+		t.Errorf("other connection sees %d uncommitted rows", n)
+	}
+	if n := count(conn1); n != 1 {
+		t.Errorf("transaction sees %d rows, want its own 1", n)
+	}
+
+	// The other connection writes meanwhile, outside of the snapshot
+	if _, err := conn2.Exec("INSERT INTO items VALUES (2)"); err != nil {
+		t.Fatalf("INSERT on the other connection failed: %v", err)
+	}
+	if n := count(conn1); n != 1 {
+		t.Errorf("transaction sees %d rows after the other commit, want 1", n)
+	}
+
+	if _, err := conn1.Exec("COMMIT"); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	if n := count(conn2); n != 2 {
+		t.Errorf("expected 2 rows after the commit, got %d", n)
+	}
+
+	// A rolled back transaction leaves nothing behind
+	if _, err := conn2.Exec("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := conn2.Exec("DELETE FROM items"); err != nil {
+		t.Fatalf("DELETE in transaction failed: %v", err)
+	}
+	if n := count(conn1); n != 2 {
+		t.Errorf("other connection sees %d rows during the delete, want 2", n)
+	}
+	if _, err := conn2.Exec("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+	if n := count(conn1); n != 2 {
+		t.Errorf("expected 2 rows after the rollback, got %d", n)
+	}
+}
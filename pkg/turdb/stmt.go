@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"tur/pkg/record"
 	"tur/pkg/schema"
@@ -23,6 +24,9 @@ var (
 
 	// ErrInvalidParamIndex is returned when binding to an invalid parameter index
 	ErrInvalidParamIndex = errors.New("invalid parameter index")
+
+	// ErrInvalidParamName is returned when binding to a name the statement has no parameter for
+	ErrInvalidParamName = errors.New("invalid parameter name")
 )
 
 // Stmt represents a prepared statement.
@@ -43,6 +47,14 @@ type Stmt struct {
 	// params holds the bound parameter values (1-indexed internally stored as 0-indexed)
 	params []types.Value
 
+	// bound records which parameters have been bound since Prepare or
+	// ClearBindings
+	bound []bool
+
+	// paramNames holds the names of the parameters with their prefix (:name,
+	// @name or $1), empty for ?
+	paramNames []string
+
 	// closed indicates if the statement has been closed
 	closed bool
 
@@ -74,6 +86,69 @@ func (s *Stmt) NumParams() int {
 	return s.numParams
 }
 
+// ParamName returns the name of the parameter at the given index with its
+// prefix, such as ":id", "@id" or "$1". It returns "" for ? parameters and
+// invalid indices. Parameter indices are 1-based (like SQLite).
+func (s *Stmt) ParamName(index int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < 1 || index > s.numParams {
+		return ""
+	}
+	return s.paramNames[index-1]
+}
+
+// ParamIndex returns the index of the named parameter, or 0 if the
+// statement has none of that name. The name may leave out the prefix, in
+// which case it matches :name, @name and $name.
+func (s *Stmt) ParamIndex(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paramIndex(name)
+}
+
+// paramIndex returns the index of the named parameter, or 0
+func (s *Stmt) paramIndex(name string) int {
+	if name == "" {
+		return 0
+	}
+	for i, paramName := range s.paramNames {
+		if paramName == "" {
+			continue
+		}
+		if paramName == name || paramName[1:] == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// args returns the values to execute the statement with: the bound ones,
+// and for @name parameters never bound the session variable of that name, as
+// in statements executed without parameters. The caller holds the database
+// lock.
+func (s *Stmt) args() []types.Value {
+	var args []types.Value
+	for i, name := range s.paramNames {
+		if s.bound[i] || !strings.HasPrefix(name, "@") {
+			continue
+		}
+		v, ok := s.db.executor.SessionVariable(name[1:])
+		if !ok {
+			continue
+		}
+		if args == nil {
+			args = append([]types.Value(nil), s.params...)
+		}
+		args[i] = v
+	}
+	if args == nil {
+		return s.params
+	}
+	return args
+}
+
 // Close closes the prepared statement and releases its resources.
 // It is an error to call Close more than once.
 func (s *Stmt) Close() error {
@@ -86,6 +161,7 @@ func (s *Stmt) Close() error {
 
 	s.closed = true
 	s.params = nil
+	s.bound = nil
 	return nil
 }
 
@@ -107,6 +183,32 @@ func (s *Stmt) BindFloat(index int, value float64) error {
 	return s.BindValue(index, types.NewFloat(value))
 }
 
+// BindBlob binds a blob value to the parameter at the given index.
+// Parameter indices are 1-based (like SQLite).
+func (s *Stmt) BindBlob(index int, value []byte) error {
+	if value == nil {
+		return s.BindNull(index)
+	}
+	return s.BindValue(index, types.NewBlob(value))
+}
+
+// BindVector binds a vector to the parameter at the given index, encoded
+// the way VECTOR columns store it. A nil vector binds NULL.
+// Parameter indices are 1-based (like SQLite).
+func (s *Stmt) BindVector(index int, value *types.Vector) error {
+	if value == nil {
+		return s.BindNull(index)
+	}
+	return s.BindValue(index, types.NewBlob(value.ToBytes()))
+}
+
+// BindTime binds a time to the parameter at the given index as a
+// TIMESTAMPTZ value.
+// Parameter indices are 1-based (like SQLite).
+func (s *Stmt) BindTime(index int, value time.Time) error {
+	return s.BindValue(index, types.NewTimestampTZ(value))
+}
+
 // BindNull binds a NULL value to the parameter at the given index.
 // Parameter indices are 1-based (like SQLite).
 func (s *Stmt) BindNull(index int) error {
@@ -130,6 +232,26 @@ func (s *Stmt) BindValue(index int, value types.Value) error {
 
 	// Store at 0-based index
 	s.params[index-1] = value
+	s.bound[index-1] = true
+	return nil
+}
+
+// BindNamed binds a generic Value to the named parameter. The name may
+// leave out the prefix, as for ParamIndex.
+func (s *Stmt) BindNamed(name string, value types.Value) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStmtClosed
+	}
+
+	index := s.paramIndex(name)
+	if index == 0 {
+		return ErrInvalidParamName
+	}
+	s.params[index-1] = value
+	s.bound[index-1] = true
 	return nil
 }
 
@@ -144,6 +266,7 @@ func (s *Stmt) ClearBindings() {
 
 	for i := range s.params {
 		s.params[i] = types.NewNull()
+		s.bound[i] = false
 	}
 }
 
@@ -171,7 +294,8 @@ func (s *Stmt) Exec() (ExecResult, error) {
 
 // ExecContext executes the prepared statement with context support.
 // The context can be used for cancellation and timeout control.
-// If the context is canceled or times out, the operation returns the context's error.
+// If the context is canceled or times out, the operation returns the context's error,
+// also while the statement runs, which then stops and takes back its changes.
 func (s *Stmt) ExecContext(ctx context.Context) (ExecResult, error) {
 	// Check context before acquiring lock
 	if err := ctx.Err(); err != nil {
@@ -191,8 +315,11 @@ func (s *Stmt) ExecContext(ctx context.Context) (ExecResult, error) {
 	}
 
 	// Lock the database and execute
-	s.db.mu.Lock()
-	defer s.db.mu.Unlock()
+	unlock, err := s.db.lockFor(s.cachedAST)
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer unlock()
 
 	// Check context after acquiring database lock
 	if err := ctx.Err(); err != nil {
@@ -203,20 +330,8 @@ func (s *Stmt) ExecContext(ctx context.Context) (ExecResult, error) {
 		return ExecResult{}, ErrDatabaseClosed
 	}
 
-	// Use cached AST with parameter substitution (faster path)
-	if s.cachedAST != nil {
-		result, err := s.db.executor.ExecuteAST(s.cachedAST, s.params)
-		if err != nil {
-			return ExecResult{}, err
-		}
-		return ExecResult{
-			rowsAffected: result.RowsAffected,
-		}, nil
-	}
-
-	// Fallback: Build the SQL with bound parameters substituted
-	sqlWithParams := s.substituteParams()
-	result, err := s.db.executor.Execute(sqlWithParams)
+	// Execute the cached AST with the bound values
+	result, err := s.db.executeLocked(ctx, s.cachedAST, s.args())
 	if err != nil {
 		return ExecResult{}, err
 	}
@@ -226,52 +341,6 @@ func (s *Stmt) ExecContext(ctx context.Context) (ExecResult, error) {
 	}, nil
 }
 
-// substituteParams replaces ? placeholders with the bound parameter values.
-// This produces a valid SQL string that can be executed by the executor.
-func (s *Stmt) substituteParams() string {
-	if s.numParams == 0 {
-		return s.sql
-	}
-
-	result := strings.Builder{}
-	paramIdx := 0
-	inString := false
-	stringChar := byte(0)
-
-	for i := 0; i < len(s.sql); i++ {
-		ch := s.sql[i]
-
-		if inString {
-			result.WriteByte(ch)
-			if ch == stringChar {
-				if i+1 < len(s.sql) && s.sql[i+1] == stringChar {
-					i++
-					result.WriteByte(s.sql[i])
-				} else {
-					inString = false
-				}
-			}
-		} else {
-			switch ch {
-			case '\'', '"':
-				inString = true
-				stringChar = ch
-				result.WriteByte(ch)
-			case '?':
-				if paramIdx < len(s.params) {
-					result.WriteString(valueToSQL(s.params[paramIdx]))
-					paramIdx++
-				} else {
-					result.WriteByte(ch)
-				}
-			default:
-				result.WriteByte(ch)
-			}
-		}
-	}
-
-	return result.String()
-}
 
 // Query executes the prepared statement with the current bound parameters
 // and returns a Rows iterator for the result set.
@@ -283,7 +352,8 @@ func (s *Stmt) Query() (*Rows, error) {
 // QueryContext executes the prepared statement with context support
 // and returns a Rows iterator for the result set.
 // The context can be used for cancellation and timeout control.
-// If the context is canceled or times out, the operation returns the context's error.
+// If the context is canceled or times out, the operation returns the context's error,
+// also while the query runs.
 func (s *Stmt) QueryContext(ctx context.Context) (*Rows, error) {
 	// Check context before acquiring lock
 	if err := ctx.Err(); err != nil {
@@ -303,8 +373,9 @@ func (s *Stmt) QueryContext(ctx context.Context) (*Rows, error) {
 	}
 
 	// ULTRA-FAST PATH: Direct PK lookup with cached references (inlined for speed)
-	// Uses RLock since this is a read-only operation
-	if s.isFastPathPK && s.fastPathTree != nil && s.fastPathTableDef != nil {
+	// Uses RLock since this is a read-only operation. A read-only database
+	// takes the regular path, which catches up with the writer first.
+	if s.isFastPathPK && s.fastPathTree != nil && s.fastPathTableDef != nil && !s.db.pager.ReadOnly() {
 		pkValue := s.params[s.fastPathPKParam]
 		if isIntegerType(pkValue.Type()) {
 			// Use RLock for read operations
@@ -313,35 +384,49 @@ func (s *Stmt) QueryContext(ctx context.Context) (*Rows, error) {
 				s.db.mu.RUnlock()
 				return nil, ErrDatabaseClosed
 			}
+			// The transaction of the connection, if any, has its own view of
+			// the rows and takes the regular path
+			if !s.db.inTransaction() {
+				// Build key using pre-allocated buffer
+				binary.BigEndian.PutUint64(s.keyBuffer[:], uint64(pkValue.Int()))
+
+				// Direct B-tree lookup
+				data, err := s.fastPathTree.Get(s.keyBuffer[:])
+				s.db.mu.RUnlock()
 
-			// Build key using pre-allocated buffer
-			binary.BigEndian.PutUint64(s.keyBuffer[:], uint64(pkValue.Int()))
-
-			// Direct B-tree lookup
-			data, err := s.fastPathTree.Get(s.keyBuffer[:])
-			s.db.mu.RUnlock()
-
-			if err != nil {
-				// Key not found - return empty result with cached column names
-				columns := s.fastPathTableDef.GetCachedColumnNames()
-				return NewRows(columns, nil), nil
-			}
+				if err != nil {
+					// Key not found - return empty result with cached column names
+					columns := s.fastPathTableDef.GetCachedColumnNames()
+					return NewRows(columns, nil), nil
+				}
 
-			// Use pooled RecordView with unsafe strings for zero-copy decoding
-			view := record.AcquireRecordView(data)
-			row := view.ToValuesPooledUnsafe()
+				// Use pooled RecordView with unsafe strings for zero-copy decoding
+				view := record.AcquireRecordView(data)
+				if view.ColumnCount() >= s.fastPathTableDef.ColumnCount() {
+					row := view.ToValuesPooledUnsafe()
 
-			// Get cached column names (no allocation - direct reference)
-			columns := s.fastPathTableDef.GetCachedColumnNames()
+					// Get cached column names (no allocation - direct reference)
+					columns := s.fastPathTableDef.GetCachedColumnNames()
 
-			// Use single-row fast path with direct pool references (avoids closure allocation)
-			return NewSingleRowRowsPooled(columns, row, view, row), nil
+					// Use single-row fast path with direct pool references (avoids closure allocation)
+					return NewSingleRowRowsPooled(columns, row, view, row), nil
+				}
+				// Rows stored before ADD COLUMN take the regular path,
+				// which completes them
+				record.ReleaseRecordView(view)
+			} else {
+				s.db.mu.RUnlock()
+			}
 		}
 	}
 
-	// Regular path - needs write lock due to potential executor state changes
-	s.db.mu.Lock()
-	defer s.db.mu.Unlock()
+	// Regular path - queries share the database, other statements may change
+	// the executor state
+	unlock, err := s.db.lockFor(s.cachedAST)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Check context after acquiring database lock
 	if err := ctx.Err(); err != nil {
@@ -352,18 +437,8 @@ func (s *Stmt) QueryContext(ctx context.Context) (*Rows, error) {
 		return nil, ErrDatabaseClosed
 	}
 
-	// Use cached AST with parameter substitution (faster path)
-	if s.cachedAST != nil {
-		result, err := s.db.executor.ExecuteAST(s.cachedAST, s.params)
-		if err != nil {
-			return nil, err
-		}
-		return NewRows(result.Columns, result.Rows), nil
-	}
-
-	// Fallback: Build the SQL with bound parameters substituted
-	sqlWithParams := s.substituteParams()
-	result, err := s.db.executor.Execute(sqlWithParams)
+	// Execute the cached AST with the bound values
+	result, err := s.db.executeLocked(ctx, s.cachedAST, s.args())
 	if err != nil {
 		return nil, err
 	}
@@ -371,31 +446,13 @@ func (s *Stmt) QueryContext(ctx context.Context) (*Rows, error) {
 	return NewRows(result.Columns, result.Rows), nil
 }
 
-// valueToSQL converts a types.Value to its SQL literal representation.
-func valueToSQL(v types.Value) string {
-	switch v.Type() {
-	case types.TypeNull:
-		return "NULL"
-	case types.TypeSmallInt, types.TypeInt32, types.TypeBigInt, types.TypeSerial, types.TypeBigSerial:
-		return fmt.Sprintf("%d", v.Int())
-	case types.TypeFloat:
-		return fmt.Sprintf("%g", v.Float())
-	case types.TypeText:
-		// Escape single quotes by doubling them
-		text := v.Text()
-		escaped := strings.ReplaceAll(text, "'", "''")
-		return "'" + escaped + "'"
-	case types.TypeBlob:
-		// Convert blob to hex string
-		blob := v.Blob()
-		return "x'" + fmt.Sprintf("%x", blob) + "'"
-	default:
-		return "NULL"
-	}
-}
 
 // Prepare prepares a SQL statement for execution.
-// The statement can contain parameter placeholders (?) which are bound later.
+// The statement can contain parameter placeholders which are bound later:
+// ?, named parameters (:name or @name) and numbered ones ($1).
+// Parameters are numbered in the order they first appear, named ones
+// included: in "SELECT @lim, ?" @lim is parameter 1 and ? parameter 2. A
+// @name parameter left unbound reads the session variable of that name.
 // The SQL is parsed once during Prepare and the AST is cached for faster execution.
 func (db *DB) Prepare(sql string) (*Stmt, error) {
 	db.mu.Lock()
@@ -420,6 +477,8 @@ func (db *DB) Prepare(sql string) (*Stmt, error) {
 		sql:           sql,
 		numParams:     numParams,
 		params:        make([]types.Value, numParams),
+		bound:         make([]bool, numParams),
+		paramNames:    p.PlaceholderNames(),
 		closed:        false,
 		cachedAST:     ast,
 		fastPathPKParam: -1,
@@ -512,11 +571,11 @@ func (s *Stmt) detectFastPath(db *DB) {
 	}
 
 	// Right side should be a placeholder (parameter)
-	if _, ok := binExpr.Right.(*parser.Placeholder); ok {
+	if ph, ok := binExpr.Right.(*parser.Placeholder); ok {
 		// Fast path eligible!
 		s.isFastPathPK = true
 		s.fastPathTable = table.Name
-		s.fastPathPKParam = 0 // First (and only) parameter
+		s.fastPathPKParam = ph.Index - 1 // Only parameter, possibly numbered
 
 		// Cache tree and table definition for ultra-fast access
 		s.fastPathTableDef = tableDef
@@ -532,111 +591,3 @@ func isIntegerType(t types.ValueType) bool {
 	}
 	return false
 }
-
-// countParams counts the number of ? placeholders in the SQL string.
-// It properly handles strings and comments.
-func countParams(sql string) int {
-	count := 0
-	inString := false
-	stringChar := byte(0)
-
-	for i := 0; i < len(sql); i++ {
-		ch := sql[i]
-
-		if inString {
-			if ch == stringChar {
-				// Check for escaped quote
-				if i+1 < len(sql) && sql[i+1] == stringChar {
-					i++ // Skip escaped quote
-				} else {
-					inString = false
-				}
-			}
-		} else {
-			switch ch {
-			case '\'', '"':
-				inString = true
-				stringChar = ch
-			case '?':
-				count++
-			case '-':
-				// Check for -- comment
-				if i+1 < len(sql) && sql[i+1] == '-' {
-					// Skip to end of line
-					for i < len(sql) && sql[i] != '\n' {
-						i++
-					}
-				}
-			case '/':
-				// Check for /* comment
-				if i+1 < len(sql) && sql[i+1] == '*' {
-					i += 2
-					for i+1 < len(sql) && !(sql[i] == '*' && sql[i+1] == '/') {
-						i++
-					}
-					i++ // Skip past */
-				}
-			}
-		}
-	}
-
-	return count
-}
-
-// validateSQL performs validation of the SQL statement by attempting to parse it.
-func validateSQL(sql string) error {
-	trimmed := strings.TrimSpace(sql)
-	if trimmed == "" {
-		return errors.New("empty SQL statement")
-	}
-
-	// Use the actual parser to validate the SQL
-	// We need to replace ? placeholders with dummy values for parsing
-	sqlForParse := replaceParamsForValidation(trimmed)
-
-	p := parser.New(sqlForParse)
-	_, err := p.Parse()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// replaceParamsForValidation replaces ? placeholders with dummy values
-// so the parser can validate the SQL structure.
-func replaceParamsForValidation(sql string) string {
-	result := strings.Builder{}
-	inString := false
-	stringChar := byte(0)
-
-	for i := 0; i < len(sql); i++ {
-		ch := sql[i]
-
-		if inString {
-			result.WriteByte(ch)
-			if ch == stringChar {
-				if i+1 < len(sql) && sql[i+1] == stringChar {
-					i++
-					result.WriteByte(sql[i])
-				} else {
-					inString = false
-				}
-			}
-		} else {
-			switch ch {
-			case '\'', '"':
-				inString = true
-				stringChar = ch
-				result.WriteByte(ch)
-			case '?':
-				// Replace ? with a dummy value (NULL works for any type)
-				result.WriteString("NULL")
-			default:
-				result.WriteByte(ch)
-			}
-		}
-	}
-
-	return result.String()
-}
@@ -2,8 +2,11 @@
 package turdb
 
 import (
+	"bytes"
+	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"tur/pkg/types"
 )
@@ -890,3 +893,291 @@ func TestDB_ClearStmtCache(t *testing.T) {
 		t.Error("expected new statement after cache clear")
 	}
 }
+
+// queryStmt runs a prepared query and returns its rows
+func queryStmt(t *testing.T, stmt *Stmt) [][]types.Value {
+	t.Helper()
+	rows, err := stmt.Query()
+	if err != nil {
+		t.Fatalf("Query %q failed: %v", stmt.SQL(), err)
+	}
+	defer rows.Close()
+	var result [][]types.Value
+	for rows.Next() {
+		row := make([]types.Value, len(rows.Columns()))
+		for i := range row {
+			row[i] = rows.ColumnValue(i)
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// TestStmt_BoundPlaceholders verifies that placeholders take their values
+// in every kind of statement, and new values on each execution
+func TestStmt_BoundPlaceholders(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT, score FLOAT)",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, amount INT)",
+		"INSERT INTO users VALUES (1, 'alice', 1.5), (2, 'bob', 2.5), (3, 'carol', 3.5)",
+		"INSERT INTO orders VALUES (1, 1, 10), (2, 1, 20), (3, 2, 30)",
+	} {
+		if _, err := db.Exec(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		sql  string
+		args [][]types.Value
+		want []int64 // first column of the rows, per execution
+	}{
+		{
+			name: "projection, join, ORDER BY and LIMIT",
+			sql:  "SELECT u.id + ?, o.amount FROM users u JOIN orders o ON o.user_id = u.id AND o.amount > ? WHERE u.id < ? ORDER BY o.amount LIMIT ? OFFSET ?",
+			args: [][]types.Value{
+				{types.NewInt(100), types.NewInt(10), types.NewInt(5), types.NewInt(1), types.NewInt(0)},
+				{types.NewInt(200), types.NewInt(0), types.NewInt(2), types.NewInt(2), types.NewInt(1)},
+			},
+			want: []int64{101, 201},
+		},
+		{
+			name: "GROUP BY",
+			sql:  "SELECT user_id, SUM(amount) FROM orders WHERE amount >= ? GROUP BY user_id ORDER BY user_id",
+			args: [][]types.Value{
+				{types.NewInt(20)},
+				{types.NewInt(25)},
+			},
+			want: []int64{1, 2, 2},
+		},
+		{
+			name: "subqueries",
+			sql:  "SELECT id FROM users WHERE id IN (SELECT user_id FROM orders WHERE amount >= ?) AND EXISTS (SELECT 1 FROM orders WHERE amount = ?) ORDER BY id",
+			args: [][]types.Value{
+				{types.NewInt(20), types.NewInt(10)},
+				{types.NewInt(30), types.NewInt(30)},
+			},
+			want: []int64{1, 2, 2},
+		},
+		{
+			name: "CTE",
+			sql:  "WITH big AS (SELECT user_id, amount FROM orders WHERE amount > ?) SELECT user_id FROM big ORDER BY amount LIMIT ?",
+			args: [][]types.Value{
+				{types.NewInt(10), types.NewInt(1)},
+				{types.NewInt(0), types.NewInt(2)},
+			},
+			want: []int64{1, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := db.Prepare(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: Prepare failed: %v", tt.name, err)
+		}
+		var got []int64
+		for _, args := range tt.args {
+			for i, arg := range args {
+				if err := stmt.BindValue(i+1, arg); err != nil {
+					t.Fatalf("%s: BindValue failed: %v", tt.name, err)
+				}
+			}
+			for _, row := range queryStmt(t, stmt) {
+				got = append(got, row[0].Int())
+			}
+		}
+		stmt.Close()
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Upserts take the parameters of their values and of their update
+	upsert, err := db.Prepare("INSERT INTO orders (id, user_id, amount) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE amount = amount + ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer upsert.Close()
+	for _, amount := range []int64{5, 7} {
+		upsert.BindInt(1, 3)
+		upsert.BindInt(2, 2)
+		upsert.BindInt(3, 0)
+		upsert.BindInt(4, amount)
+		if _, err := upsert.Exec(); err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+	}
+	total, err := db.Prepare("SELECT amount FROM orders WHERE id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer total.Close()
+	total.BindInt(1, 3)
+	if rows := queryStmt(t, total); len(rows) != 1 || rows[0][0].Int() != 42 {
+		t.Errorf("expected the upsert to add up to 42, got %v", rows)
+	}
+}
+
+// TestStmt_BindTypes verifies that values keep their type through binding
+func TestStmt_BindTypes(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY, ratio FLOAT, data BLOB, embedding VECTOR(2))"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+
+	insert, err := db.Prepare("INSERT INTO items VALUES (?, ?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer insert.Close()
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	ratio := 1.0 / 3
+	data := []byte{0, 1, 0x27, 0xff}
+	insert.BindInt(1, 1)
+	insert.BindFloat(2, ratio)
+	insert.BindBlob(3, data)
+	insert.BindVector(4, types.NewVector([]float32{1, 0}))
+	if _, err := insert.Exec(); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	insert.BindInt(1, 2)
+	insert.BindFloat(2, 1)
+	insert.BindBlob(3, nil)
+	insert.BindVector(4, types.NewVector([]float32{0, 1}))
+	if _, err := insert.Exec(); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	query, err := db.Prepare("SELECT id, ratio, data, ? FROM items WHERE ratio = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer query.Close()
+	query.BindTime(1, at)
+	query.BindFloat(2, ratio)
+	rows := queryStmt(t, query)
+	if len(rows) != 1 || rows[0][1].Float() != ratio || !bytes.Equal(rows[0][2].Blob(), data) || !rows[0][3].TimestampTZValue().Equal(at) {
+		t.Fatalf("expected row 1 with the bound values, got %v", rows)
+	}
+
+	nearest, err := db.Prepare("SELECT id FROM items ORDER BY vector_distance(embedding, ?) LIMIT ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer nearest.Close()
+	nearest.BindVector(1, types.NewVector([]float32{0.1, 0.9}))
+	nearest.BindInt(2, 1)
+	if rows := queryStmt(t, nearest); len(rows) != 1 || rows[0][0].Int() != 2 {
+		t.Errorf("expected the nearest item to be 2, got %v", rows)
+	}
+}
+
+// TestStmt_NamedParams verifies :name, @name and $N parameters
+func TestStmt_NamedParams(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE users (id INT PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO users VALUES (1, 'alice'), (2, 'bob'), (3, 'carol')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	// A name used twice is one parameter
+	stmt, err := db.Prepare("SELECT id FROM users WHERE id = :id OR id = :id + @step OR name = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer stmt.Close()
+	if stmt.NumParams() != 3 {
+		t.Fatalf("expected 3 parameters, got %d", stmt.NumParams())
+	}
+	if stmt.ParamName(1) != ":id" || stmt.ParamName(2) != "@step" || stmt.ParamName(3) != "" {
+		t.Errorf("unexpected names %q %q %q", stmt.ParamName(1), stmt.ParamName(2), stmt.ParamName(3))
+	}
+	if stmt.ParamIndex(":id") != 1 || stmt.ParamIndex("step") != 2 || stmt.ParamIndex("name") != 0 {
+		t.Error("unexpected parameter indices")
+	}
+	if err := stmt.BindNamed("id", types.NewInt(1)); err != nil {
+		t.Fatalf("BindNamed failed: %v", err)
+	}
+	if err := stmt.BindNamed("@step", types.NewInt(1)); err != nil {
+		t.Fatalf("BindNamed failed: %v", err)
+	}
+	if err := stmt.BindNamed(":missing", types.NewInt(1)); err != ErrInvalidParamName {
+		t.Errorf("expected ErrInvalidParamName, got %v", err)
+	}
+	stmt.BindText(3, "carol")
+	if rows := queryStmt(t, stmt); len(rows) != 3 {
+		t.Errorf("expected 3 rows, got %v", rows)
+	}
+
+	// Numbered parameters can come in any order and repeat
+	numbered, err := db.Prepare("SELECT name FROM users WHERE id = $2 - $1 AND $1 > 0")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer numbered.Close()
+	if numbered.NumParams() != 2 || numbered.ParamIndex("$2") != 2 {
+		t.Fatalf("expected 2 numbered parameters, got %d", numbered.NumParams())
+	}
+	numbered.BindInt(1, 1)
+	numbered.BindInt(2, 3)
+	if rows := queryStmt(t, numbered); len(rows) != 1 || rows[0][0].Text() != "bob" {
+		t.Errorf("expected bob, got %v", rows)
+	}
+
+	// Without parameters, @name is the session variable
+	if _, err := db.Exec("SET @wanted = 3"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	result, err := db.Exec("SELECT name FROM users WHERE id = @wanted")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "carol" {
+		t.Errorf("expected carol, got %v", result.Rows)
+	}
+
+	// Alongside other parameters, an unbound @name is the session variable
+	// too, and takes its place in the numbering
+	if _, err := db.Exec("SET @lim = 2"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	mixed, err := db.Prepare("SELECT name FROM users WHERE id <= @lim AND name != ? ORDER BY id")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer mixed.Close()
+	if mixed.ParamIndex("@lim") != 1 || mixed.ParamName(2) != "" {
+		t.Fatalf("expected @lim as parameter 1 and ? as 2, got %q %q", mixed.ParamName(1), mixed.ParamName(2))
+	}
+	mixed.BindText(2, "alice")
+	if rows := queryStmt(t, mixed); len(rows) != 1 || rows[0][0].Text() != "bob" {
+		t.Errorf("expected bob under the session @lim, got %v", rows)
+	}
+	mixed.BindInt(1, 3)
+	if rows := queryStmt(t, mixed); len(rows) != 2 || rows[1][0].Text() != "carol" {
+		t.Errorf("expected bob and carol under the bound @lim, got %v", rows)
+	}
+	mixed.ClearBindings()
+	mixed.BindText(2, "bob")
+	if rows := queryStmt(t, mixed); len(rows) != 1 || rows[0][0].Text() != "alice" {
+		t.Errorf("expected alice once @lim is unbound again, got %v", rows)
+	}
+}
@@ -4,19 +4,37 @@ package turdb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"tur/pkg/mvcc"
+	"tur/pkg/sql/parser"
 )
 
 var (
 	// ErrTxDone is returned when a transaction has already been committed or rolled back.
 	ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+	// ErrWriteConflict is returned by Commit when a row the transaction wrote
+	// was also written by a transaction that committed after it began. The
+	// transaction is rolled back and can be retried.
+	ErrWriteConflict = mvcc.ErrWriteConflict
 )
 
 // Tx represents a database transaction.
 // A Tx must end with a call to Commit or Rollback.
 //
+// Transactions run with snapshot isolation: a Tx sees the rows committed
+// before it began and its own changes, never the uncommitted changes of
+// another Tx. Any number of them can be active at once. When two write the
+// same row, the first to commit wins and the other fails to commit with
+// ErrWriteConflict. Schema changes, and writes to tables with HNSW indexes,
+// lock the database for writes until the Tx ends.
+//
+// On a database opened with Options.ReadOnly, a Tx reads the database as of
+// the last commit of the writer before it began, and statements outside of
+// it see that state too until it ends.
+//
 // After a call to Commit or Rollback, all operations on the
 // transaction will fail with ErrTxDone.
 type Tx struct {
@@ -24,6 +42,9 @@ type Tx struct {
 	db   *DB
 	mvcc *mvcc.Transaction
 	done bool
+	// snapshot is set when the Tx holds a pager snapshot of a read-only
+	// database
+	snapshot bool
 }
 
 // Begin starts a new database transaction.
@@ -68,13 +89,24 @@ func (db *DB) BeginContext(ctx context.Context) (*Tx, error) {
 		return nil, ErrDatabaseClosed
 	}
 
-	// Start a new MVCC transaction
-	mvccTx := db.txManager.Begin()
+	// A read-only database first catches up with the writer and keeps
+	// reading that state until the Tx ends
+	snapshot := db.pager.ReadOnly()
+	if snapshot {
+		if err := db.beginSnapshotLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Start a new MVCC transaction on a snapshot of the database. Its
+	// changes reach the file atomically when it commits.
+	mvccTx := db.executor.BeginTransaction()
 
 	return &Tx{
-		db:   db,
-		mvcc: mvccTx,
-		done: false,
+		db:       db,
+		mvcc:     mvccTx,
+		done:     false,
+		snapshot: snapshot,
 	}, nil
 }
 
@@ -92,12 +124,27 @@ func (tx *Tx) Commit() error {
 		return ErrTxDone
 	}
 
-	// Commit the MVCC transaction
-	if err := tx.db.txManager.Commit(tx.mvcc); err != nil {
-		return err
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	if tx.db.closed {
+		// Discard the changes, unless closing the engine already did
+		tx.done = true
+		if tx.db.refs > 0 {
+			tx.db.executor.RollbackTransaction(tx.mvcc)
+		}
+		tx.endSnapshotLocked()
+		return ErrDatabaseClosed
 	}
 
+	// Make the changes durable; a failed commit, such as one that conflicts
+	// with a transaction that committed first, discards them
 	tx.done = true
+	defer tx.endSnapshotLocked()
+	if err := tx.db.executor.CommitTransaction(tx.mvcc); err != nil {
+		tx.db.executor.RollbackTransaction(tx.mvcc)
+		return err
+	}
 	return nil
 }
 
@@ -127,15 +174,30 @@ func (tx *Tx) Rollback() error {
 		return ErrTxDone
 	}
 
-	// Rollback the MVCC transaction
-	if err := tx.db.txManager.Rollback(tx.mvcc); err != nil {
-		return err
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	// Discard the changes and restore the executor state, unless closing
+	// the engine already did
+	if tx.db.refs > 0 {
+		if err := tx.db.executor.RollbackTransaction(tx.mvcc); err != nil {
+			return err
+		}
 	}
 
 	tx.done = true
+	tx.endSnapshotLocked()
 	return nil
 }
 
+// endSnapshotLocked ends the pager snapshot the Tx holds, if any
+func (tx *Tx) endSnapshotLocked() {
+	if tx.snapshot {
+		tx.db.pager.EndSnapshot()
+		tx.snapshot = false
+	}
+}
+
 // Exec executes a SQL statement within the transaction.
 // The statement is executed using the transaction's isolation context.
 //
@@ -160,12 +222,19 @@ func (tx *Tx) Exec(sql string) (*QueryResult, error) {
 // ExecContext executes a SQL statement within the transaction with context support.
 // The context can be used for cancellation and timeout control.
 // If the context is canceled or times out, the operation returns the context's error.
+// A statement stopped that way takes back its changes and leaves the
+// transaction active.
 func (tx *Tx) ExecContext(ctx context.Context, sql string) (*QueryResult, error) {
 	// Check context before acquiring lock
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
+	stmt, err := parser.New(sql).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
 
@@ -191,7 +260,7 @@ func (tx *Tx) ExecContext(ctx context.Context, sql string) (*QueryResult, error)
 	prevTx := tx.db.executor.GetTransaction()
 	tx.db.executor.SetTransaction(tx.mvcc)
 
-	execResult, err := tx.db.executor.Execute(sql)
+	execResult, err := tx.db.executor.ExecuteASTContext(ctx, stmt, nil)
 
 	// Restore previous transaction
 	tx.db.executor.SetTransaction(prevTx)
@@ -203,3 +272,59 @@ func (tx *Tx) ExecContext(ctx context.Context, sql string) (*QueryResult, error)
 
 	return convertQueryResult(execResult), nil
 }
+
+// beginConnTx starts a transaction for the statements of the connection, as
+// BEGIN does, but with the snapshot isolation of a Tx: its changes stay in
+// its snapshot until endConnTx commits them.
+func (db *DB) beginConnTx() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if db.inTransaction() {
+		return errors.New("cannot start a transaction within a transaction")
+	}
+
+	// A read-only database catches up with the writer, and keeps reading
+	// that state until the transaction ends
+	if db.pager.ReadOnly() {
+		if err := db.beginSnapshotLocked(); err != nil {
+			return err
+		}
+		defer db.pager.EndSnapshot()
+	}
+	db.tx = db.executor.BeginTransaction()
+	if db.pager.ReadOnly() {
+		db.syncTxSnapshotLocked()
+	}
+	return nil
+}
+
+// endConnTx commits or rolls back the transaction begun with beginConnTx. It
+// returns ErrTxDone if a COMMIT or ROLLBACK statement already ended it.
+func (db *DB) endConnTx(commit bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		// Closing the connection rolled the changes back
+		return ErrDatabaseClosed
+	}
+	tx := db.tx
+	if tx == nil || !tx.IsActive() {
+		return ErrTxDone
+	}
+	db.tx = nil
+	defer db.syncTxSnapshotLocked()
+
+	if !commit {
+		return db.executor.RollbackTransaction(tx)
+	}
+	if err := db.executor.CommitTransaction(tx); err != nil {
+		db.executor.RollbackTransaction(tx)
+		return err
+	}
+	return nil
+}
@@ -554,9 +554,6 @@ func TestTx_Rollback_SafeToCallMultipleTimes(t *testing.T) {
 
 func TestTx_AutoRollbackPattern(t *testing.T) {
 	// Test the idiomatic defer pattern works correctly for error handling
-	// Note: Currently the executor commits B-tree operations immediately,
-	// so rollback doesn't undo data changes. This test verifies the API pattern
-	// works correctly even if full rollback isn't implemented yet.
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
 
@@ -595,7 +592,7 @@ func TestTx_AutoRollbackPattern(t *testing.T) {
 		t.Fatalf("expected simulated error, got: %v", err)
 	}
 
-	// Verify we can still query the database (no corruption)
+	// Verify the insert was rolled back and the database still works
 	result, err := db.Exec("SELECT COUNT(*) FROM test")
 	if err != nil {
 		t.Fatalf("SELECT failed after rollback pattern: %v", err)
@@ -603,6 +600,9 @@ func TestTx_AutoRollbackPattern(t *testing.T) {
 	if len(result.Rows) != 1 {
 		t.Fatalf("expected 1 row in result, got %d", len(result.Rows))
 	}
+	if count, ok := result.Rows[0][0].(int64); !ok || count != 0 {
+		t.Errorf("expected the insert to be rolled back, got count %v", result.Rows[0][0])
+	}
 }
 
 func TestTx_SuccessfulCommitPattern(t *testing.T) {
@@ -664,5 +664,171 @@ func TestTx_SuccessfulCommitPattern(t *testing.T) {
 func cleanupTestDB(t *testing.T, path string) {
 	t.Helper()
 	os.Remove(path)
-	os.Remove(path + ".lock")
+	os.Remove(path + "-shm")
+}
+
+// txValues returns the val column of the rows of test, by id, as seen by
+// exec, which is tx.Exec or db.Exec
+func txValues(t *testing.T, exec func(string) (*QueryResult, error)) map[int64]string {
+	t.Helper()
+	result, err := exec("SELECT id, val FROM test")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	values := make(map[int64]string)
+	for _, row := range result.Rows {
+		values[row[0].(int64)] = row[1].(string)
+	}
+	return values
+}
+
+func TestTx_SnapshotIsolation(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE test (id INT PRIMARY KEY, val TEXT)",
+		"INSERT INTO test VALUES (1, 'one'), (2, 'two'), (3, 'three')",
+	} {
+		if _, err := db.Exec(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	writer, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer writer.Rollback()
+	reader, err := db.Begin()
+	if err != nil {
+		t.Fatalf("second Begin failed: %v", err)
+	}
+	defer reader.Rollback()
+
+	for _, sql := range []string{
+		"INSERT INTO test VALUES (4, 'four')",
+		"UPDATE test SET val = 'uno' WHERE id = 1",
+		"DELETE FROM test WHERE id = 2",
+	} {
+		if _, err := writer.Exec(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	before := map[int64]string{1: "one", 2: "two", 3: "three"}
+	after := map[int64]string{1: "uno", 3: "three", 4: "four"}
+	check := func(name string, got, want map[int64]string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s sees %v, want %v", name, got, want)
+			return
+		}
+		for id, val := range want {
+			if got[id] != val {
+				t.Errorf("%s sees %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+	check("writer", txValues(t, writer.Exec), after)
+	check("reader", txValues(t, reader.Exec), before)
+	check("autocommit query", txValues(t, db.Exec), before)
+
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	// The reader keeps its snapshot; later transactions see the commit
+	check("reader after the commit", txValues(t, reader.Exec), before)
+	check("autocommit query after the commit", txValues(t, db.Exec), after)
+	later, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer later.Rollback()
+	check("later transaction", txValues(t, later.Exec), after)
+
+	// Autocommit writes leave the snapshots alone too
+	if _, err := db.Exec("UPDATE test SET val = 'tres' WHERE id = 3"); err != nil {
+		t.Fatalf("autocommit UPDATE failed: %v", err)
+	}
+	check("reader after an autocommit write", txValues(t, reader.Exec), before)
+	check("later transaction after an autocommit write", txValues(t, later.Exec), after)
+}
+
+func TestTx_WriteConflict(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE test (id INT PRIMARY KEY, val TEXT)",
+		"INSERT INTO test VALUES (1, 'one'), (2, 'two')",
+	} {
+		if _, err := db.Exec(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	first, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	second, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	// Writes to different rows do not conflict
+	other, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	if _, err := first.Exec("UPDATE test SET val = 'first' WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	if _, err := second.Exec("UPDATE test SET val = 'second' WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	if _, err := other.Exec("UPDATE test SET val = 'other' WHERE id = 2"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("first Commit failed: %v", err)
+	}
+	if err := second.Commit(); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected ErrWriteConflict, got %v", err)
+	}
+	if err := second.Rollback(); err != ErrTxDone {
+		t.Errorf("expected the failed commit to end the transaction, got %v", err)
+	}
+	if err := other.Commit(); err != nil {
+		t.Fatalf("Commit of a different row failed: %v", err)
+	}
+
+	values := txValues(t, db.Exec)
+	if values[1] != "first" || values[2] != "other" {
+		t.Errorf("unexpected rows after the commits: %v", values)
+	}
+
+	// A transaction that began after the commit can write the row
+	retry, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := retry.Exec("UPDATE test SET val = 'retry' WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	if err := retry.Commit(); err != nil {
+		t.Fatalf("retry Commit failed: %v", err)
+	}
+	if values := txValues(t, db.Exec); values[1] != "retry" {
+		t.Errorf("expected the retried update, got %v", values)
+	}
 }
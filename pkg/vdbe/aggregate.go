@@ -3,6 +3,8 @@
 package vdbe
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"tur/pkg/types"
@@ -25,11 +27,52 @@ func GetAggregate(name string) AggregateFunc {
 		return NewMinAggregate()
 	case "MAX":
 		return NewMaxAggregate()
+	case "VAR_POP":
+		return NewVarPopAggregate()
+	case "VAR_SAMP", "VARIANCE":
+		return NewVarSampAggregate()
+	case "STDDEV_POP":
+		return NewStddevPopAggregate()
+	case "STDDEV_SAMP", "STDDEV":
+		return NewStddevSampAggregate()
+	case "GROUP_CONCAT", "STRING_AGG":
+		return NewStringAggAggregate()
+	case "JSON_ARRAYAGG":
+		return NewJSONArrayAggAggregate()
+	case "JSON_OBJECTAGG":
+		return NewJSONObjectAggAggregate()
+	case "BOOL_AND", "EVERY":
+		return NewBoolAndAggregate()
+	case "BOOL_OR":
+		return NewBoolOrAggregate()
+	case "PERCENTILE_CONT":
+		return NewPercentileContAggregate()
+	case "PERCENTILE_DISC":
+		return NewPercentileDiscAggregate()
+	case "MODE":
+		return NewModeAggregate()
+	case "APPROX_COUNT_DISTINCT":
+		return NewHyperLogLogAggregate()
+	case "VECTOR_SUM":
+		return NewVectorSumAggregate()
+	case "VECTOR_AVG":
+		return NewVectorAvgAggregate()
 	default:
 		return nil
 	}
 }
 
+// GetDistinctAggregate returns a new instance of an aggregate function by name
+// that only sees each distinct non-null input value once, as in COUNT(DISTINCT x).
+// Returns nil if the aggregate name is not recognized.
+func GetDistinctAggregate(name string) AggregateFunc {
+	agg := GetAggregate(name)
+	if agg == nil {
+		return nil
+	}
+	return NewDistinctAggregate(agg)
+}
+
 // AggregateFunc defines the interface for SQL aggregate functions.
 // Aggregates process multiple input values and produce a single result.
 type AggregateFunc interface {
@@ -277,6 +320,74 @@ func (m *MaxAggregate) Finalize() types.Value {
 	return m.max
 }
 
+// DistinctAggregate wraps an aggregate so it only steps each distinct
+// non-null value once. NULLs are skipped, as every aggregate ignores them.
+type DistinctAggregate struct {
+	inner AggregateFunc
+	seen  map[string]struct{}
+}
+
+// NewDistinctAggregate creates a DISTINCT variant of an aggregate
+func NewDistinctAggregate(inner AggregateFunc) *DistinctAggregate {
+	return &DistinctAggregate{inner: inner, seen: make(map[string]struct{})}
+}
+
+// Init resets the wrapped aggregate and forgets the values seen so far
+func (d *DistinctAggregate) Init() {
+	d.inner.Init()
+	d.seen = make(map[string]struct{})
+}
+
+// Step passes a value to the wrapped aggregate the first time it is seen
+func (d *DistinctAggregate) Step(value types.Value) {
+	if d.firstSeen(value) {
+		d.inner.Step(value)
+	}
+}
+
+// StepArgs passes a row's arguments to the wrapped aggregate the first time
+// its first argument is seen, as in STRING_AGG(DISTINCT x, ',')
+func (d *DistinctAggregate) StepArgs(args []types.Value) {
+	if len(args) > 0 && d.firstSeen(args[0]) {
+		StepAggregate(d.inner, args)
+	}
+}
+
+// firstSeen records a non-null value and reports whether it is new
+func (d *DistinctAggregate) firstSeen(value types.Value) bool {
+	if value.IsNull() {
+		return false
+	}
+	key := distinctKey(value)
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = struct{}{}
+	return true
+}
+
+// Finalize returns the result of the wrapped aggregate
+func (d *DistinctAggregate) Finalize() types.Value {
+	return d.inner.Finalize()
+}
+
+// distinctKey returns a key that is equal for values DISTINCT treats as equal.
+// All integer types share one format, and so do all text types.
+func distinctKey(v types.Value) string {
+	switch {
+	case types.IsIntegerType(v.Type()):
+		return "I:" + strconv.FormatInt(v.Int(), 10)
+	case v.Type() == types.TypeFloat:
+		return "F:" + strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case v.Type() == types.TypeText || v.Type() == types.TypeVarchar || v.Type() == types.TypeChar:
+		return "T:" + v.Text()
+	case v.Type() == types.TypeBlob:
+		return "B:" + string(v.Blob())
+	default:
+		return fmt.Sprintf("%d:%v", v.Type(), v)
+	}
+}
+
 // compareValues compares two values, returns -1, 0, or 1
 // Follows SQL comparison rules: NULL < number < text < blob
 func compareValues(a, b types.Value) int {
@@ -0,0 +1,521 @@
+// pkg/vdbe/aggregate_analytics.go
+// Statistical and collection aggregates: variance and standard deviation,
+// string and JSON aggregation, boolean, ordered-set and approximate aggregates.
+package vdbe
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"tur/pkg/types"
+)
+
+// MultiArgAggregate is implemented by aggregates that take more than one
+// argument per row, such as STRING_AGG(expr, separator) and
+// JSON_OBJECTAGG(key, value). Step alone receives only the first argument.
+type MultiArgAggregate interface {
+	AggregateFunc
+
+	// StepArgs processes the arguments of one input row
+	StepArgs(args []types.Value)
+}
+
+// StepAggregate passes the arguments of one input row to an aggregate,
+// using StepArgs when the aggregate takes several arguments
+func StepAggregate(agg AggregateFunc, args []types.Value) {
+	if multi, ok := agg.(MultiArgAggregate); ok {
+		multi.StepArgs(args)
+		return
+	}
+	if len(args) == 0 {
+		agg.Step(types.NewNull())
+		return
+	}
+	agg.Step(args[0])
+}
+
+// numericValue returns a numeric value as a float64, or false for NULLs and
+// values that are not numbers
+func numericValue(v types.Value) (float64, bool) {
+	switch {
+	case types.IsIntegerType(v.Type()):
+		return float64(v.Int()), true
+	case v.Type() == types.TypeFloat:
+		return v.Float(), true
+	case v.Type() == types.TypeDecimal:
+		f, err := strconv.ParseFloat(v.DecimalString(), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// VarianceAggregate implements VAR_POP, VAR_SAMP, STDDEV_POP and STDDEV_SAMP.
+// It uses Welford's online algorithm, which stays accurate when the values
+// are large compared to their spread.
+type VarianceAggregate struct {
+	sample bool // divide by n-1 instead of n
+	stddev bool // return the square root of the variance
+	count  int64
+	mean   float64
+	m2     float64 // sum of squared differences from the mean
+}
+
+// NewVarPopAggregate creates a VAR_POP aggregate
+func NewVarPopAggregate() *VarianceAggregate {
+	return &VarianceAggregate{}
+}
+
+// NewVarSampAggregate creates a VAR_SAMP aggregate
+func NewVarSampAggregate() *VarianceAggregate {
+	return &VarianceAggregate{sample: true}
+}
+
+// NewStddevPopAggregate creates a STDDEV_POP aggregate
+func NewStddevPopAggregate() *VarianceAggregate {
+	return &VarianceAggregate{stddev: true}
+}
+
+// NewStddevSampAggregate creates a STDDEV_SAMP aggregate
+func NewStddevSampAggregate() *VarianceAggregate {
+	return &VarianceAggregate{sample: true, stddev: true}
+}
+
+// Init resets the running mean and sum of squares
+func (v *VarianceAggregate) Init() {
+	v.count = 0
+	v.mean = 0
+	v.m2 = 0
+}
+
+// Step adds a value, ignoring nulls and values that are not numbers
+func (v *VarianceAggregate) Step(value types.Value) {
+	x, ok := numericValue(value)
+	if !ok {
+		return
+	}
+	v.count++
+	delta := x - v.mean
+	v.mean += delta / float64(v.count)
+	v.m2 += delta * (x - v.mean)
+}
+
+// Finalize returns the variance or standard deviation as a float. It is NULL
+// without values, and for the sample variants with fewer than two values.
+func (v *VarianceAggregate) Finalize() types.Value {
+	n := v.count
+	if v.sample {
+		n--
+	}
+	if n <= 0 {
+		return types.NewNull()
+	}
+	variance := v.m2 / float64(n)
+	if v.stddev {
+		return types.NewFloat(math.Sqrt(variance))
+	}
+	return types.NewFloat(variance)
+}
+
+// StringAggAggregate implements GROUP_CONCAT(expr [, separator]) and
+// STRING_AGG(expr, separator). Each value after the first is preceded by the
+// separator of its own row; the separator defaults to a comma.
+type StringAggAggregate struct {
+	sb       strings.Builder
+	hasValue bool
+}
+
+// NewStringAggAggregate creates a STRING_AGG or GROUP_CONCAT aggregate
+func NewStringAggAggregate() *StringAggAggregate {
+	return &StringAggAggregate{}
+}
+
+// Init discards the text collected so far
+func (s *StringAggAggregate) Init() {
+	s.sb.Reset()
+	s.hasValue = false
+}
+
+// Step appends a value using the default separator
+func (s *StringAggAggregate) Step(value types.Value) {
+	s.StepArgs([]types.Value{value})
+}
+
+// StepArgs appends args[0] preceded by the separator in args[1], ignoring nulls
+func (s *StringAggAggregate) StepArgs(args []types.Value) {
+	if len(args) == 0 || args[0].IsNull() {
+		return
+	}
+	if s.hasValue {
+		if len(args) > 1 {
+			s.sb.WriteString(valueToString(args[1]))
+		} else {
+			s.sb.WriteString(",")
+		}
+	}
+	s.sb.WriteString(valueToString(args[0]))
+	s.hasValue = true
+}
+
+// Finalize returns the concatenated text, or NULL if there were no values
+func (s *StringAggAggregate) Finalize() types.Value {
+	if !s.hasValue {
+		return types.NewNull()
+	}
+	return types.NewText(s.sb.String())
+}
+
+// JSONArrayAggAggregate implements JSON_ARRAYAGG(expr). Unlike most
+// aggregates it keeps NULLs, as JSON nulls.
+type JSONArrayAggAggregate struct {
+	values []interface{}
+	rows   int64
+}
+
+// NewJSONArrayAggAggregate creates a JSON_ARRAYAGG aggregate
+func NewJSONArrayAggAggregate() *JSONArrayAggAggregate {
+	return &JSONArrayAggAggregate{}
+}
+
+// Init discards the collected elements
+func (j *JSONArrayAggAggregate) Init() {
+	j.values = nil
+	j.rows = 0
+}
+
+// Step appends a value to the array
+func (j *JSONArrayAggAggregate) Step(value types.Value) {
+	j.values = append(j.values, valueToJSON(value))
+	j.rows++
+}
+
+// Finalize returns the JSON array, or NULL if there were no rows
+func (j *JSONArrayAggAggregate) Finalize() types.Value {
+	if j.rows == 0 {
+		return types.NewNull()
+	}
+	result, err := json.Marshal(j.values)
+	if err != nil {
+		return types.NewNull()
+	}
+	return types.NewJSON(string(result))
+}
+
+// JSONObjectAggAggregate implements JSON_OBJECTAGG(key, value). Keys keep
+// the order they were first seen in; a repeated key takes the last value.
+// Rows with a NULL key are skipped.
+type JSONObjectAggAggregate struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewJSONObjectAggAggregate creates a JSON_OBJECTAGG aggregate
+func NewJSONObjectAggAggregate() *JSONObjectAggAggregate {
+	return &JSONObjectAggAggregate{values: make(map[string]interface{})}
+}
+
+// Init discards the collected members
+func (j *JSONObjectAggAggregate) Init() {
+	j.keys = nil
+	j.values = make(map[string]interface{})
+}
+
+// Step adds a key with a null value
+func (j *JSONObjectAggAggregate) Step(value types.Value) {
+	j.StepArgs([]types.Value{value})
+}
+
+// StepArgs adds the member args[0]: args[1]
+func (j *JSONObjectAggAggregate) StepArgs(args []types.Value) {
+	if len(args) == 0 || args[0].IsNull() {
+		return
+	}
+	key := valueToString(args[0])
+	if _, ok := j.values[key]; !ok {
+		j.keys = append(j.keys, key)
+	}
+	if len(args) > 1 {
+		j.values[key] = valueToJSON(args[1])
+	} else {
+		j.values[key] = nil
+	}
+}
+
+// Finalize returns the JSON object, or NULL if there were no members
+func (j *JSONObjectAggAggregate) Finalize() types.Value {
+	if len(j.keys) == 0 {
+		return types.NewNull()
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, key := range j.keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return types.NewNull()
+		}
+		v, err := json.Marshal(j.values[key])
+		if err != nil {
+			return types.NewNull()
+		}
+		sb.Write(k)
+		sb.WriteByte(':')
+		sb.Write(v)
+	}
+	sb.WriteByte('}')
+	return types.NewJSON(sb.String())
+}
+
+// BoolAggregate implements BOOL_AND (also EVERY) and BOOL_OR. Non-zero
+// numbers are true; nulls and values that are not numbers are ignored.
+type BoolAggregate struct {
+	and      bool
+	result   bool
+	hasValue bool
+}
+
+// NewBoolAndAggregate creates a BOOL_AND aggregate
+func NewBoolAndAggregate() *BoolAggregate {
+	return &BoolAggregate{and: true}
+}
+
+// NewBoolOrAggregate creates a BOOL_OR aggregate
+func NewBoolOrAggregate() *BoolAggregate {
+	return &BoolAggregate{}
+}
+
+// Init resets the result
+func (b *BoolAggregate) Init() {
+	b.result = false
+	b.hasValue = false
+}
+
+// Step combines a value into the result
+func (b *BoolAggregate) Step(value types.Value) {
+	x, ok := numericValue(value)
+	if !ok {
+		return
+	}
+	if !b.hasValue {
+		b.result = x != 0
+		b.hasValue = true
+		return
+	}
+	if b.and {
+		b.result = b.result && x != 0
+	} else {
+		b.result = b.result || x != 0
+	}
+}
+
+// Finalize returns 1 or 0, or NULL if there were no values
+func (b *BoolAggregate) Finalize() types.Value {
+	if !b.hasValue {
+		return types.NewNull()
+	}
+	if b.result {
+		return types.NewInt(1)
+	}
+	return types.NewInt(0)
+}
+
+// PercentileAggregate implements the ordered-set aggregates
+// PERCENTILE_CONT(fraction) and PERCENTILE_DISC(fraction) WITHIN GROUP
+// (ORDER BY expr). StepArgs takes the ordered value followed by the fraction,
+// and values must be stepped in WITHIN GROUP order.
+type PercentileAggregate struct {
+	discrete    bool
+	values      []types.Value
+	fraction    float64
+	hasFraction bool
+}
+
+// NewPercentileContAggregate creates a PERCENTILE_CONT aggregate, which
+// interpolates between the two values nearest the fraction
+func NewPercentileContAggregate() *PercentileAggregate {
+	return &PercentileAggregate{}
+}
+
+// NewPercentileDiscAggregate creates a PERCENTILE_DISC aggregate, which
+// returns the first value whose position reaches the fraction
+func NewPercentileDiscAggregate() *PercentileAggregate {
+	return &PercentileAggregate{discrete: true}
+}
+
+// Init discards the collected values
+func (p *PercentileAggregate) Init() {
+	p.values = nil
+	p.fraction = 0
+	p.hasFraction = false
+}
+
+// Step adds a value; without a fraction the aggregate computes the median
+func (p *PercentileAggregate) Step(value types.Value) {
+	p.StepArgs([]types.Value{value, types.NewFloat(0.5)})
+}
+
+// StepArgs adds the value in args[0]; the first fraction seen is used
+func (p *PercentileAggregate) StepArgs(args []types.Value) {
+	if len(args) == 0 {
+		return
+	}
+	if !p.hasFraction && len(args) > 1 {
+		if f, ok := numericValue(args[1]); ok {
+			p.fraction = f
+			p.hasFraction = true
+		}
+	}
+	if args[0].IsNull() {
+		return
+	}
+	if _, ok := numericValue(args[0]); !p.discrete && !ok {
+		return
+	}
+	p.values = append(p.values, args[0])
+}
+
+// Finalize returns the percentile. It is NULL without values or when the
+// fraction is missing or outside [0, 1].
+func (p *PercentileAggregate) Finalize() types.Value {
+	n := len(p.values)
+	if n == 0 || !p.hasFraction || p.fraction < 0 || p.fraction > 1 {
+		return types.NewNull()
+	}
+
+	if p.discrete {
+		idx := int(math.Ceil(p.fraction*float64(n))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return p.values[idx]
+	}
+
+	pos := p.fraction * float64(n-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	loVal, _ := numericValue(p.values[lo])
+	hiVal, _ := numericValue(p.values[hi])
+	return types.NewFloat(loVal + (pos-float64(lo))*(hiVal-loVal))
+}
+
+// ModeAggregate implements MODE() WITHIN GROUP (ORDER BY expr), the most
+// frequent non-null value. Ties go to the value that was stepped first.
+type ModeAggregate struct {
+	counts map[string]int
+	values map[string]types.Value
+	order  []string
+}
+
+// NewModeAggregate creates a MODE aggregate
+func NewModeAggregate() *ModeAggregate {
+	return &ModeAggregate{counts: make(map[string]int), values: make(map[string]types.Value)}
+}
+
+// Init discards the counts
+func (m *ModeAggregate) Init() {
+	m.counts = make(map[string]int)
+	m.values = make(map[string]types.Value)
+	m.order = nil
+}
+
+// Step counts a value, ignoring nulls
+func (m *ModeAggregate) Step(value types.Value) {
+	if value.IsNull() {
+		return
+	}
+	key := distinctKey(value)
+	if _, ok := m.counts[key]; !ok {
+		m.values[key] = value
+		m.order = append(m.order, key)
+	}
+	m.counts[key]++
+}
+
+// Finalize returns the most frequent value, or NULL if there were no values
+func (m *ModeAggregate) Finalize() types.Value {
+	best := ""
+	for _, key := range m.order {
+		if best == "" || m.counts[key] > m.counts[best] {
+			best = key
+		}
+	}
+	if best == "" {
+		return types.NewNull()
+	}
+	return m.values[best]
+}
+
+// hllPrecision is the number of hash bits APPROX_COUNT_DISTINCT uses to pick
+// a register. 2^14 registers give a standard error of about 0.8%.
+const hllPrecision = 14
+
+// HyperLogLogAggregate implements APPROX_COUNT_DISTINCT(expr), which estimates
+// the number of distinct non-null values in fixed memory. Each register keeps
+// the longest run of leading zero bits seen among the hashes routed to it.
+type HyperLogLogAggregate struct {
+	registers []uint8
+}
+
+// NewHyperLogLogAggregate creates an APPROX_COUNT_DISTINCT aggregate
+func NewHyperLogLogAggregate() *HyperLogLogAggregate {
+	return &HyperLogLogAggregate{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// Init clears the registers
+func (h *HyperLogLogAggregate) Init() {
+	h.registers = make([]uint8, 1<<hllPrecision)
+}
+
+// Step adds a value to the sketch, ignoring nulls
+func (h *HyperLogLogAggregate) Step(value types.Value) {
+	if value.IsNull() {
+		return
+	}
+	hash := hllHash(distinctKey(value))
+	idx := hash >> (64 - hllPrecision)
+	// The marker bit bounds the run when the remaining bits are all zero
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Finalize returns the estimated number of distinct values
+func (h *HyperLogLogAggregate) Finalize() types.Value {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return types.NewInt(int64(math.Round(estimate)))
+}
+
+// hllHash hashes a distinct key with FNV-1a and mixes the result so that the
+// leading bits are spread evenly, as HyperLogLog requires
+func hllHash(key string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(key))
+	h := f.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
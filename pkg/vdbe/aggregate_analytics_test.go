@@ -0,0 +1,257 @@
+// pkg/vdbe/aggregate_analytics_test.go
+package vdbe
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"tur/pkg/types"
+)
+
+// stepAll steps one value per row through a fresh aggregate
+func stepAll(t *testing.T, name string, values ...types.Value) types.Value {
+	t.Helper()
+	agg := GetAggregate(name)
+	if agg == nil {
+		t.Fatalf("no aggregate named %s", name)
+	}
+	agg.Init()
+	for _, v := range values {
+		agg.Step(v)
+	}
+	return agg.Finalize()
+}
+
+// stepRows steps several arguments per row through a fresh aggregate
+func stepRows(t *testing.T, name string, rows ...[]types.Value) types.Value {
+	t.Helper()
+	agg := GetAggregate(name)
+	if agg == nil {
+		t.Fatalf("no aggregate named %s", name)
+	}
+	agg.Init()
+	for _, args := range rows {
+		StepAggregate(agg, args)
+	}
+	return agg.Finalize()
+}
+
+func ints(values ...int64) []types.Value {
+	result := make([]types.Value, len(values))
+	for i, v := range values {
+		result[i] = types.NewInt(v)
+	}
+	return result
+}
+
+func TestVarianceAggregates(t *testing.T) {
+	values := append(ints(2, 4, 4, 4, 5, 5, 7, 9), types.NewNull())
+	tests := []struct {
+		name     string
+		expected float64
+	}{
+		{"VAR_POP", 4},
+		{"VAR_SAMP", 32.0 / 7},
+		{"VARIANCE", 32.0 / 7},
+		{"STDDEV_POP", 2},
+		{"STDDEV_SAMP", math.Sqrt(32.0 / 7)},
+		{"STDDEV", math.Sqrt(32.0 / 7)},
+	}
+	for _, tc := range tests {
+		result := stepAll(t, tc.name, values...)
+		if result.Type() != types.TypeFloat || math.Abs(result.Float()-tc.expected) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tc.name, result.Float(), tc.expected)
+		}
+	}
+
+	// Welford's algorithm keeps the precision a naive sum of squares loses
+	big := []types.Value{types.NewFloat(1e9 + 4), types.NewFloat(1e9 + 7), types.NewFloat(1e9 + 13), types.NewFloat(1e9 + 16)}
+	if result := stepAll(t, "VAR_SAMP", big...); math.Abs(result.Float()-30) > 1e-6 {
+		t.Errorf("VAR_SAMP of large values = %v, want 30", result.Float())
+	}
+}
+
+func TestVarianceAggregates_TooFewValues(t *testing.T) {
+	if result := stepAll(t, "VAR_POP"); !result.IsNull() {
+		t.Errorf("VAR_POP of nothing = %v, want NULL", result)
+	}
+	if result := stepAll(t, "STDDEV_SAMP", types.NewInt(3)); !result.IsNull() {
+		t.Errorf("STDDEV_SAMP of one value = %v, want NULL", result)
+	}
+	if result := stepAll(t, "STDDEV_POP", types.NewInt(3)); result.Float() != 0 {
+		t.Errorf("STDDEV_POP of one value = %v, want 0", result.Float())
+	}
+}
+
+func TestStringAggAggregate(t *testing.T) {
+	comma := types.NewText(", ")
+	result := stepRows(t, "STRING_AGG",
+		[]types.Value{types.NewText("a"), comma},
+		[]types.Value{types.NewNull(), comma},
+		[]types.Value{types.NewInt(2), comma},
+		[]types.Value{types.NewText("c"), types.NewText("|")},
+	)
+	if result.Text() != "a, 2|c" {
+		t.Errorf("STRING_AGG = %q, want %q", result.Text(), "a, 2|c")
+	}
+
+	if result := stepAll(t, "GROUP_CONCAT", types.NewText("x"), types.NewText("y")); result.Text() != "x,y" {
+		t.Errorf("GROUP_CONCAT = %q, want %q", result.Text(), "x,y")
+	}
+	if result := stepAll(t, "GROUP_CONCAT", types.NewNull()); !result.IsNull() {
+		t.Errorf("GROUP_CONCAT of NULLs = %v, want NULL", result)
+	}
+}
+
+func TestJSONAggregates(t *testing.T) {
+	result := stepAll(t, "JSON_ARRAYAGG", types.NewInt(1), types.NewNull(), types.NewText("a"))
+	if result.Type() != types.TypeJSON || result.JSON() != `[1,null,"a"]` {
+		t.Errorf("JSON_ARRAYAGG = %v %q", result.Type(), result.JSON())
+	}
+	if result := stepAll(t, "JSON_ARRAYAGG"); !result.IsNull() {
+		t.Errorf("JSON_ARRAYAGG of no rows = %v, want NULL", result)
+	}
+
+	result = stepRows(t, "JSON_OBJECTAGG",
+		[]types.Value{types.NewText("b"), types.NewInt(1)},
+		[]types.Value{types.NewText("a"), types.NewJSON(`{"x":[1]}`)},
+		[]types.Value{types.NewNull(), types.NewInt(3)},
+		[]types.Value{types.NewText("b"), types.NewNull()},
+	)
+	if result.JSON() != `{"b":null,"a":{"x":[1]}}` {
+		t.Errorf("JSON_OBJECTAGG = %q", result.JSON())
+	}
+}
+
+func TestBoolAggregates(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []types.Value
+		expected string
+	}{
+		{"BOOL_AND", ints(1, 1, 1), "1"},
+		{"BOOL_AND", append(ints(1, 0), types.NewNull()), "0"},
+		{"EVERY", ints(2, 3), "1"},
+		{"BOOL_OR", ints(0, 0, 1), "1"},
+		{"BOOL_OR", append(ints(0), types.NewNull()), "0"},
+		{"BOOL_OR", []types.Value{types.NewNull()}, "NULL"},
+	}
+	for _, tc := range tests {
+		result := stepAll(t, tc.name, tc.values...)
+		got := "NULL"
+		if !result.IsNull() {
+			got = fmt.Sprint(result.Int())
+		}
+		if got != tc.expected {
+			t.Errorf("%s(%v) = %s, want %s", tc.name, tc.values, got, tc.expected)
+		}
+	}
+}
+
+func TestPercentileAggregates(t *testing.T) {
+	// Values are stepped in WITHIN GROUP order
+	rows := func(fraction float64, values ...int64) [][]types.Value {
+		var result [][]types.Value
+		for _, v := range values {
+			result = append(result, []types.Value{types.NewInt(v), types.NewFloat(fraction)})
+		}
+		return append(result, []types.Value{types.NewNull(), types.NewFloat(fraction)})
+	}
+
+	tests := []struct {
+		name     string
+		rows     [][]types.Value
+		expected float64
+	}{
+		{"PERCENTILE_CONT", rows(0.5, 1, 2, 3, 4), 2.5},
+		{"PERCENTILE_CONT", rows(0.25, 10, 20, 30, 40, 50), 20},
+		{"PERCENTILE_CONT", rows(0.1, 10, 20), 11},
+		{"PERCENTILE_CONT", rows(1, 10, 20), 20},
+		{"PERCENTILE_DISC", rows(0.5, 1, 2, 3, 4), 2},
+		{"PERCENTILE_DISC", rows(0, 7, 8), 7},
+		{"PERCENTILE_DISC", rows(0.51, 1, 2, 3, 4), 3},
+		// Descending WITHIN GROUP order counts from the top
+		{"PERCENTILE_DISC", rows(0.25, 4, 3, 2, 1), 4},
+	}
+	for _, tc := range tests {
+		result := stepRows(t, tc.name, tc.rows...)
+		got, _ := numericValue(result)
+		if result.IsNull() || got != tc.expected {
+			t.Errorf("%s(%v) = %v, want %v", tc.name, tc.rows[0][1].Float(), result, tc.expected)
+		}
+	}
+
+	if result := stepRows(t, "PERCENTILE_CONT", rows(1.5, 1, 2)...); !result.IsNull() {
+		t.Errorf("PERCENTILE_CONT(1.5) = %v, want NULL", result)
+	}
+	if result := stepRows(t, "PERCENTILE_DISC", rows(0.5)...); !result.IsNull() {
+		t.Errorf("PERCENTILE_DISC of no values = %v, want NULL", result)
+	}
+	if result := stepRows(t, "PERCENTILE_DISC", []types.Value{types.NewText("b"), types.NewFloat(0.5)}); result.Text() != "b" {
+		t.Errorf("PERCENTILE_DISC of text = %v, want b", result)
+	}
+}
+
+func TestModeAggregate(t *testing.T) {
+	if result := stepAll(t, "MODE", append(ints(3, 1, 3, 2, 1, 3), types.NewNull(), types.NewNull())...); result.Int() != 3 {
+		t.Errorf("MODE = %v, want 3", result)
+	}
+	// Ties go to the first value in WITHIN GROUP order
+	if result := stepAll(t, "MODE", ints(5, 2, 2, 5)...); result.Int() != 5 {
+		t.Errorf("MODE with a tie = %v, want 5", result)
+	}
+	if result := stepAll(t, "MODE", types.NewNull()); !result.IsNull() {
+		t.Errorf("MODE of NULLs = %v, want NULL", result)
+	}
+}
+
+func TestHyperLogLogAggregate(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 5000, 100000} {
+		agg := NewHyperLogLogAggregate()
+		agg.Init()
+		for i := 0; i < n; i++ {
+			// Every value twice, once as an integer and once as text
+			agg.Step(types.NewInt(int64(i)))
+			agg.Step(types.NewInt(int64(i)))
+			agg.Step(types.NewText(fmt.Sprintf("v%d", i)))
+		}
+		agg.Step(types.NewNull())
+
+		estimate := float64(agg.Finalize().Int())
+		want := float64(2 * n)
+		if math.Abs(estimate-want) > 0.03*want+1 {
+			t.Errorf("APPROX_COUNT_DISTINCT of %d values = %v", 2*n, estimate)
+		}
+	}
+}
+
+func TestDistinctAggregate_StepArgs(t *testing.T) {
+	agg := GetDistinctAggregate("STRING_AGG")
+	agg.Init()
+	for _, v := range []string{"b", "a", "b", "c", "a"} {
+		StepAggregate(agg, []types.Value{types.NewText(v), types.NewText("-")})
+	}
+	if result := agg.Finalize(); result.Text() != "b-a-c" {
+		t.Errorf("STRING_AGG(DISTINCT) = %q, want %q", result.Text(), "b-a-c")
+	}
+}
+
+func TestVM_StatisticalAggregate_ViaOpcodes(t *testing.T) {
+	prog := NewProgram()
+	prog.AddOp4(OpAggInit, 0, 0, 0, "STDDEV_POP")
+	for _, v := range []int64{2, 4, 4, 4, 5, 5, 7, 9} {
+		prog.AddOp(OpInteger, int(v), 1, 0)
+		prog.AddOp(OpAggStep, 0, 1, 0)
+	}
+	prog.AddOp(OpAggFinal, 0, 2, 0)
+	prog.AddOp(OpHalt, 0, 0, 0)
+
+	vm := NewVM(prog, nil)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result := vm.Register(2); result.Float() != 2 {
+		t.Errorf("STDDEV_POP via opcodes = %v, want 2", result)
+	}
+}
@@ -524,3 +524,72 @@ func TestVM_SumAggregate_ViaOpcodes(t *testing.T) {
 		t.Errorf("expected sum of 60, got %d", result.Int())
 	}
 }
+
+// Test 30: DISTINCT aggregates only see each non-null value once
+func TestDistinctAggregate_SkipsDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected types.Value
+	}{
+		{"COUNT", types.NewInt(3)},
+		{"SUM", types.NewInt(6)},
+		{"AVG", types.NewFloat(2)},
+		{"MIN", types.NewInt(1)},
+		{"MAX", types.NewInt(3)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			agg := GetDistinctAggregate(tc.name)
+			if agg == nil {
+				t.Fatalf("expected distinct aggregate for %s, got nil", tc.name)
+			}
+			agg.Init()
+			for _, v := range []int64{1, 2, 2, 3, 1, 3} {
+				agg.Step(types.NewInt(v))
+			}
+			agg.Step(types.NewNull())
+
+			result := agg.Finalize()
+			if compareValues(result, tc.expected) != 0 {
+				t.Errorf("expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+// Test 31: Init forgets the values a DISTINCT aggregate has seen
+func TestDistinctAggregate_InitResets(t *testing.T) {
+	agg := NewDistinctAggregate(NewCountAggregate())
+	agg.Init()
+	agg.Step(types.NewText("a"))
+	agg.Step(types.NewText("a"))
+	agg.Init()
+	agg.Step(types.NewText("a"))
+
+	if result := agg.Finalize(); result.Int() != 1 {
+		t.Errorf("expected count of 1 after Init, got %d", result.Int())
+	}
+}
+
+// Test 32: OpAggInit with P3=1 creates a DISTINCT aggregate
+func TestVM_DistinctAggregate_ViaOpcodes(t *testing.T) {
+	prog := NewProgram()
+	prog.AddOp4(OpAggInit, 0, 0, 1, "SUM")
+	prog.AddOp(OpInteger, 10, 1, 0)
+	prog.AddOp(OpAggStep, 0, 1, 0)
+	prog.AddOp(OpAggStep, 0, 1, 0)
+	prog.AddOp(OpInteger, 30, 1, 0)
+	prog.AddOp(OpAggStep, 0, 1, 0)
+	prog.AddOp(OpAggFinal, 0, 2, 0)
+	prog.AddOp(OpHalt, 0, 0, 0)
+
+	vm := NewVM(prog, nil)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+
+	if result := vm.Register(2); result.Int() != 40 {
+		t.Errorf("expected distinct sum of 40, got %d", result.Int())
+	}
+}
@@ -184,7 +184,8 @@ func (c *Compiler) compileSelect(stmt *parser.SelectStmt) (*Program, error) {
 	return c.program, nil
 }
 
-// compileExpr compiles an expression into a register
+// compileExpr compiles an expression into a register. Predicates yield 1, 0,
+// or NULL when their result is UNKNOWN.
 func (c *Compiler) compileExpr(expr parser.Expression, colMap map[string]int, cursorIdx, destReg int) error {
 	switch e := expr.(type) {
 	case *parser.Literal:
@@ -201,6 +202,26 @@ func (c *Compiler) compileExpr(expr parser.Expression, colMap map[string]int, cu
 	case *parser.BinaryExpr:
 		return c.compileBinaryExpr(e, colMap, cursorIdx, destReg)
 
+	case *parser.UnaryExpr:
+		if e.Op != lexer.NOT {
+			return fmt.Errorf("unsupported unary operator: %v", e.Op)
+		}
+		operandReg := c.allocReg()
+		if err := c.compileExpr(e.Right, colMap, cursorIdx, operandReg); err != nil {
+			return err
+		}
+		c.program.AddOp(OpNot, operandReg, destReg, 0)
+		return nil
+
+	case *parser.IsNullExpr:
+		return c.compileIsNull(e, colMap, cursorIdx, destReg)
+
+	case *parser.IsDistinctExpr:
+		return c.compileIsDistinct(e, colMap, cursorIdx, destReg)
+
+	case *parser.BetweenExpr:
+		return c.compileBetween(e, colMap, cursorIdx, destReg)
+
 	default:
 		return fmt.Errorf("unsupported expression type: %T", expr)
 	}
@@ -248,17 +269,38 @@ func (c *Compiler) compileBinaryExpr(expr *parser.BinaryExpr, colMap map[string]
 	case lexer.EQ, lexer.NEQ, lexer.LT, lexer.GT, lexer.LTE, lexer.GTE:
 		return c.compileComparison(expr.Op, leftReg, rightReg, destReg)
 	case lexer.AND:
-		return c.compileAnd(expr, colMap, cursorIdx, destReg)
+		c.program.AddOp(OpAnd, leftReg, rightReg, destReg)
 	case lexer.OR:
-		return c.compileOr(expr, colMap, cursorIdx, destReg)
+		c.program.AddOp(OpOr, leftReg, rightReg, destReg)
 	default:
 		return fmt.Errorf("unsupported binary operator: %v", expr.Op)
 	}
 	return nil
 }
 
-// compileComparison compiles a comparison into a 0/1 result
+// compileComparison compiles a comparison into a 0/1 result, or NULL when
+// either operand is NULL
 func (c *Compiler) compileComparison(op lexer.TokenType, leftReg, rightReg, destReg int) error {
+	// Null -> destReg
+	// IsNull leftReg, end
+	// IsNull rightReg, end
+	// [0/1 comparison result] -> destReg
+	// end: ...
+	c.program.AddOp(OpNull, 0, destReg, 0)
+	addrLeftNull := c.program.AddOp(OpIsNull, leftReg, 0, 0)
+	addrRightNull := c.program.AddOp(OpIsNull, rightReg, 0, 0)
+
+	c.compileCompareJump(comparisonOpcode(op), leftReg, rightReg, destReg)
+
+	c.program.ChangeP2(addrLeftNull, c.program.Len())
+	c.program.ChangeP2(addrRightNull, c.program.Len())
+	return nil
+}
+
+// compileCompareJump stores 1 in destReg if the comparison opcode jumps for
+// leftReg and rightReg, and 0 otherwise. The comparison opcodes order NULL
+// before every other value and treat two NULLs as equal.
+func (c *Compiler) compileCompareJump(opcode Opcode, leftReg, rightReg, destReg int) {
 	// Generate: if comparison true, set 1, else set 0
 	// We use jump-based logic:
 	// Integer 0 -> destReg
@@ -269,22 +311,6 @@ func (c *Compiler) compileComparison(op lexer.TokenType, leftReg, rightReg, dest
 
 	c.program.AddOp(OpInteger, 0, destReg, 0) // Default to false
 
-	var opcode Opcode
-	switch op {
-	case lexer.EQ:
-		opcode = OpEq
-	case lexer.NEQ:
-		opcode = OpNe
-	case lexer.LT:
-		opcode = OpLt
-	case lexer.GT:
-		opcode = OpGt
-	case lexer.LTE:
-		opcode = OpLe
-	case lexer.GTE:
-		opcode = OpGe
-	}
-
 	addrCmp := c.program.AddOp(opcode, leftReg, 0, rightReg) // Jump if true
 	addrEnd := c.program.AddOp(OpGoto, 0, 0, 0)              // Skip to end if false
 	addrTrue := c.program.Len()
@@ -293,61 +319,93 @@ func (c *Compiler) compileComparison(op lexer.TokenType, leftReg, rightReg, dest
 	// Fix jumps
 	c.program.ChangeP2(addrCmp, addrTrue)
 	c.program.ChangeP2(addrEnd, c.program.Len())
+}
 
-	return nil
+// comparisonOpcode returns the jump opcode for a comparison operator
+func comparisonOpcode(op lexer.TokenType) Opcode {
+	switch op {
+	case lexer.EQ:
+		return OpEq
+	case lexer.NEQ:
+		return OpNe
+	case lexer.LT:
+		return OpLt
+	case lexer.GT:
+		return OpGt
+	case lexer.LTE:
+		return OpLe
+	default:
+		return OpGe
+	}
 }
 
-// compileAnd compiles AND expression with short-circuit evaluation
-func (c *Compiler) compileAnd(expr *parser.BinaryExpr, colMap map[string]int, cursorIdx, destReg int) error {
-	// Evaluate left
-	leftReg := c.allocReg()
-	if err := c.compileExpr(expr.Left, colMap, cursorIdx, leftReg); err != nil {
+// compileIsNull compiles expr IS [NOT] NULL into a 0/1 result
+func (c *Compiler) compileIsNull(expr *parser.IsNullExpr, colMap map[string]int, cursorIdx, destReg int) error {
+	valueReg := c.allocReg()
+	if err := c.compileExpr(expr.Expr, colMap, cursorIdx, valueReg); err != nil {
 		return err
 	}
 
-	// If left is false, result is false
-	c.program.AddOp(OpInteger, 0, destReg, 0)
-	addrIfFalse := c.program.AddOp(OpIfNot, leftReg, 0, 0)
+	whenNull, otherwise := 1, 0
+	if expr.Not {
+		whenNull, otherwise = 0, 1
+	}
+	c.program.AddOp(OpInteger, whenNull, destReg, 0)
+	addrIsNull := c.program.AddOp(OpIsNull, valueReg, 0, 0)
+	c.program.AddOp(OpInteger, otherwise, destReg, 0)
+	c.program.ChangeP2(addrIsNull, c.program.Len())
+	return nil
+}
 
-	// Evaluate right
+// compileIsDistinct compiles a IS [NOT] DISTINCT FROM b into a 0/1 result.
+// The comparison opcodes already treat NULL as a value of its own.
+func (c *Compiler) compileIsDistinct(expr *parser.IsDistinctExpr, colMap map[string]int, cursorIdx, destReg int) error {
+	leftReg := c.allocReg()
 	rightReg := c.allocReg()
+	if err := c.compileExpr(expr.Left, colMap, cursorIdx, leftReg); err != nil {
+		return err
+	}
 	if err := c.compileExpr(expr.Right, colMap, cursorIdx, rightReg); err != nil {
 		return err
 	}
 
-	// Result is right's truth value
-	c.program.AddOp(OpCopy, rightReg, destReg, 0)
-
-	// Fix jump
-	c.program.ChangeP2(addrIfFalse, c.program.Len())
-
+	opcode := OpNe
+	if expr.Not {
+		opcode = OpEq
+	}
+	c.compileCompareJump(opcode, leftReg, rightReg, destReg)
 	return nil
 }
 
-// compileOr compiles OR expression with short-circuit evaluation
-func (c *Compiler) compileOr(expr *parser.BinaryExpr, colMap map[string]int, cursorIdx, destReg int) error {
-	// Evaluate left
-	leftReg := c.allocReg()
-	if err := c.compileExpr(expr.Left, colMap, cursorIdx, leftReg); err != nil {
+// compileBetween compiles x [NOT] BETWEEN low AND high as
+// [NOT] (x >= low AND x <= high), evaluating x once
+func (c *Compiler) compileBetween(expr *parser.BetweenExpr, colMap map[string]int, cursorIdx, destReg int) error {
+	valueReg := c.allocReg()
+	lowReg := c.allocReg()
+	highReg := c.allocReg()
+	if err := c.compileExpr(expr.Expr, colMap, cursorIdx, valueReg); err != nil {
 		return err
 	}
-
-	// If left is true, result is true
-	c.program.AddOp(OpInteger, 1, destReg, 0)
-	addrIfTrue := c.program.AddOp(OpIf, leftReg, 0, 0)
-
-	// Evaluate right
-	rightReg := c.allocReg()
-	if err := c.compileExpr(expr.Right, colMap, cursorIdx, rightReg); err != nil {
+	if err := c.compileExpr(expr.Low, colMap, cursorIdx, lowReg); err != nil {
+		return err
+	}
+	if err := c.compileExpr(expr.High, colMap, cursorIdx, highReg); err != nil {
 		return err
 	}
 
-	// Result is right's truth value
-	c.program.AddOp(OpCopy, rightReg, destReg, 0)
-
-	// Fix jump
-	c.program.ChangeP2(addrIfTrue, c.program.Len())
+	aboveLowReg := c.allocReg()
+	belowHighReg := c.allocReg()
+	if err := c.compileComparison(lexer.GTE, valueReg, lowReg, aboveLowReg); err != nil {
+		return err
+	}
+	if err := c.compileComparison(lexer.LTE, valueReg, highReg, belowHighReg); err != nil {
+		return err
+	}
+	c.program.AddOp(OpAnd, aboveLowReg, belowHighReg, destReg)
 
+	if expr.Not {
+		c.program.AddOp(OpNot, destReg, destReg, 0)
+	}
 	return nil
 }
 
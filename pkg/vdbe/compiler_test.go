@@ -276,3 +276,87 @@ func TestCompilerInsert(t *testing.T) {
 		t.Errorf("expected name='Alice', got '%s'", values[1].Text())
 	}
 }
+
+func TestCompilerSelectThreeValuedLogic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	p, err := pager.Open(path, pager.Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	// Rows 1..5; score is NULL for even ids
+	bt, _ := btree.Create(p)
+	for i := 1; i <= 5; i++ {
+		key := make([]byte, 8)
+		key[7] = byte(i)
+		score := types.NewInt(int64(i * 10))
+		if i%2 == 0 {
+			score = types.NewNull()
+		}
+		bt.Insert(key, record.Encode([]types.Value{types.NewInt(int64(i)), score}))
+	}
+
+	catalog := schema.NewCatalog()
+	catalog.CreateTable(&schema.TableDef{
+		Name: "scores",
+		Columns: []schema.ColumnDef{
+			{Name: "id", Type: types.TypeInt32},
+			{Name: "score", Type: types.TypeInt32},
+		},
+		RootPage: bt.RootPage(),
+	})
+
+	tests := []struct {
+		where string
+		want  []int64
+	}{
+		{"score IS NULL", []int64{2, 4}},
+		{"score IS NOT NULL", []int64{1, 3, 5}},
+		{"score = NULL", nil},
+		{"NOT (score > 20)", []int64{1}},
+		{"NOT (score > 20 AND id > 0)", []int64{1}},
+		{"score > 20 OR id = 2", []int64{2, 3, 5}},
+		{"NOT (score > 100 OR id = 2)", []int64{1, 3, 5}},
+		{"score BETWEEN 10 AND 30", []int64{1, 3}},
+		{"score NOT BETWEEN 20 AND 40", []int64{1, 5}},
+		{"score IS DISTINCT FROM 30", []int64{1, 2, 4, 5}},
+		{"score IS NOT DISTINCT FROM NULL", []int64{2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			stmt, err := parser.New("SELECT id FROM scores WHERE " + tt.where).Parse()
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+
+			compiler := NewCompiler(catalog, p)
+			prog, err := compiler.Compile(stmt)
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+
+			vm := NewVM(prog, p)
+			vm.SetNumRegisters(compiler.NumRegisters())
+			if err := vm.Run(); err != nil {
+				t.Fatalf("execution failed: %v", err)
+			}
+
+			var got []int64
+			for _, row := range vm.Results() {
+				got = append(got, row[0].Int())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected ids %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected ids %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package vdbe
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
@@ -120,6 +121,48 @@ func DefaultFunctionRegistry() *FunctionRegistry {
 		Function: builtinVectorDistance,
 	})
 
+	// Register vector arithmetic and conversion functions
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR",
+		NumArgs:  1,
+		Function: builtinVector,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_TO_JSON",
+		NumArgs:  1,
+		Function: builtinVectorToJSON,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_DIMS",
+		NumArgs:  1,
+		Function: builtinVectorDims,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_NORM",
+		NumArgs:  1,
+		Function: builtinVectorNorm,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_NORMALIZE",
+		NumArgs:  1,
+		Function: builtinVectorNormalize,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_ADD",
+		NumArgs:  2,
+		Function: builtinVectorAdd,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_SUB",
+		NumArgs:  2,
+		Function: builtinVectorSub,
+	})
+	r.Register(&ScalarFunction{
+		Name:     "VECTOR_SCALE",
+		NumArgs:  2,
+		Function: builtinVectorScale,
+	})
+
 	// Register CONCAT function (variadic)
 	r.Register(&ScalarFunction{
 		Name:     "CONCAT",
@@ -870,6 +913,237 @@ func extractVector(val types.Value) (*types.Vector, error) {
 	}
 }
 
+// vectorResult returns a vector as a serialized blob, the form VECTOR columns
+// store. It is not normalized here: storing it in a VECTOR column normalizes
+// it unless the column is NONORMALIZE.
+func vectorResult(v *types.Vector) types.Value {
+	return types.NewBlob(v.ToBytes())
+}
+
+// vectorPair extracts the two vector arguments of a function, or returns
+// false if either is NULL or not a vector, or their dimensions differ
+func vectorPair(args []types.Value) (*types.Vector, *types.Vector, bool) {
+	if len(args) != 2 || args[0].IsNull() || args[1].IsNull() {
+		return nil, nil, false
+	}
+	a, err := extractVector(args[0])
+	if err != nil {
+		return nil, nil, false
+	}
+	b, err := extractVector(args[1])
+	if err != nil || a.Dimension() != b.Dimension() {
+		return nil, nil, false
+	}
+	return a, b, true
+}
+
+// builtinVector implements VECTOR(text), which parses a vector written as a
+// JSON array of numbers, e.g. VECTOR('[0.1, 0.2, 0.3]'). Vectors and blobs
+// holding a serialized vector are passed through.
+// Returns NULL for NULL, malformed or empty input.
+func builtinVector(args []types.Value) types.Value {
+	if len(args) != 1 || args[0].IsNull() {
+		return types.NewNull()
+	}
+
+	var text string
+	switch args[0].Type() {
+	case types.TypeVector, types.TypeBlob:
+		vec, err := extractVector(args[0])
+		if err != nil {
+			return types.NewNull()
+		}
+		return vectorResult(vec)
+	case types.TypeJSON:
+		text = args[0].JSON()
+	default:
+		text = valueToString(args[0])
+	}
+
+	var data []float32
+	if err := json.Unmarshal([]byte(text), &data); err != nil || len(data) == 0 {
+		return types.NewNull()
+	}
+	return vectorResult(types.NewVector(data))
+}
+
+// builtinVectorToJSON implements VECTOR_TO_JSON(vec), the inverse of VECTOR()
+func builtinVectorToJSON(args []types.Value) types.Value {
+	if len(args) != 1 || args[0].IsNull() {
+		return types.NewNull()
+	}
+	vec, err := extractVector(args[0])
+	if err != nil {
+		return types.NewNull()
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, x := range vec.Data() {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return types.NewJSON(sb.String())
+}
+
+// builtinVectorDims implements VECTOR_DIMS(vec), the number of dimensions
+func builtinVectorDims(args []types.Value) types.Value {
+	if len(args) != 1 || args[0].IsNull() {
+		return types.NewNull()
+	}
+	vec, err := extractVector(args[0])
+	if err != nil {
+		return types.NewNull()
+	}
+	return types.NewInt(int64(vec.Dimension()))
+}
+
+// builtinVectorNorm implements VECTOR_NORM(vec), the Euclidean length
+func builtinVectorNorm(args []types.Value) types.Value {
+	if len(args) != 1 || args[0].IsNull() {
+		return types.NewNull()
+	}
+	vec, err := extractVector(args[0])
+	if err != nil {
+		return types.NewNull()
+	}
+	var sum float64
+	for _, x := range vec.Data() {
+		sum += float64(x) * float64(x)
+	}
+	return types.NewFloat(math.Sqrt(sum))
+}
+
+// builtinVectorNormalize implements VECTOR_NORMALIZE(vec), which scales a
+// vector to unit length. A zero vector is returned unchanged.
+func builtinVectorNormalize(args []types.Value) types.Value {
+	if len(args) != 1 || args[0].IsNull() {
+		return types.NewNull()
+	}
+	vec, err := extractVector(args[0])
+	if err != nil {
+		return types.NewNull()
+	}
+	return vectorResult(vec.NormalizedCopy())
+}
+
+// builtinVectorAdd implements VECTOR_ADD(a, b), the element-wise sum.
+// Returns NULL if the dimensions differ.
+func builtinVectorAdd(args []types.Value) types.Value {
+	a, b, ok := vectorPair(args)
+	if !ok {
+		return types.NewNull()
+	}
+	result := make([]float32, a.Dimension())
+	for i, x := range a.Data() {
+		result[i] = x + b.Data()[i]
+	}
+	return vectorResult(types.NewVector(result))
+}
+
+// builtinVectorSub implements VECTOR_SUB(a, b), the element-wise difference.
+// Returns NULL if the dimensions differ.
+func builtinVectorSub(args []types.Value) types.Value {
+	a, b, ok := vectorPair(args)
+	if !ok {
+		return types.NewNull()
+	}
+	result := make([]float32, a.Dimension())
+	for i, x := range a.Data() {
+		result[i] = x - b.Data()[i]
+	}
+	return vectorResult(types.NewVector(result))
+}
+
+// builtinVectorScale implements VECTOR_SCALE(vec, factor), which multiplies
+// every element by a number
+func builtinVectorScale(args []types.Value) types.Value {
+	if len(args) != 2 || args[0].IsNull() {
+		return types.NewNull()
+	}
+	vec, err := extractVector(args[0])
+	if err != nil {
+		return types.NewNull()
+	}
+	factor, ok := numericValue(args[1])
+	if !ok {
+		return types.NewNull()
+	}
+	result := make([]float32, vec.Dimension())
+	for i, x := range vec.Data() {
+		result[i] = float32(float64(x) * factor)
+	}
+	return vectorResult(types.NewVector(result))
+}
+
+// VectorSumAggregate implements VECTOR_SUM(vec) and VECTOR_AVG(vec), the
+// element-wise sum and mean (centroid) of a group of vectors. Sums are kept
+// in float64 so large groups do not lose precision. Like the scalar vector
+// functions it returns a serialized vector, which is not normalized.
+type VectorSumAggregate struct {
+	avg      bool
+	sum      []float64
+	count    int64
+	mismatch bool // vectors of different dimensions were stepped
+}
+
+// NewVectorSumAggregate creates a VECTOR_SUM aggregate
+func NewVectorSumAggregate() *VectorSumAggregate {
+	return &VectorSumAggregate{}
+}
+
+// NewVectorAvgAggregate creates a VECTOR_AVG aggregate
+func NewVectorAvgAggregate() *VectorSumAggregate {
+	return &VectorSumAggregate{avg: true}
+}
+
+// Init resets the running sum
+func (v *VectorSumAggregate) Init() {
+	v.sum = nil
+	v.count = 0
+	v.mismatch = false
+}
+
+// Step adds a vector to the sum, ignoring nulls and values that are not vectors
+func (v *VectorSumAggregate) Step(value types.Value) {
+	if value.IsNull() {
+		return
+	}
+	vec, err := extractVector(value)
+	if err != nil {
+		return
+	}
+	if v.sum == nil {
+		v.sum = make([]float64, vec.Dimension())
+	} else if len(v.sum) != vec.Dimension() {
+		v.mismatch = true
+		return
+	}
+	for i, x := range vec.Data() {
+		v.sum[i] += float64(x)
+	}
+	v.count++
+}
+
+// Finalize returns the sum or mean vector. It is NULL without vectors, or if
+// their dimensions differ.
+func (v *VectorSumAggregate) Finalize() types.Value {
+	if v.count == 0 || v.mismatch {
+		return types.NewNull()
+	}
+	result := make([]float32, len(v.sum))
+	for i, x := range v.sum {
+		if v.avg {
+			x /= float64(v.count)
+		}
+		result[i] = float32(x)
+	}
+	return vectorResult(types.NewVector(result))
+}
+
 // valueToString converts a Value to its string representation.
 // Used by CONCAT and CONCAT_WS functions.
 func valueToString(v types.Value) string {
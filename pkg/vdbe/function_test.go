@@ -884,6 +884,122 @@ func TestVectorDistance_InvalidBlob(t *testing.T) {
 	}
 }
 
+// Tests for vector arithmetic, conversion and aggregate functions
+
+// vectorData decodes the vector a vector function returned
+func vectorData(t *testing.T, result types.Value) []float32 {
+	t.Helper()
+	if result.Type() != types.TypeBlob {
+		t.Fatalf("expected a vector blob, got %v", result.Type())
+	}
+	vec, err := types.VectorFromBytes(result.Blob())
+	if err != nil {
+		t.Fatalf("invalid vector: %v", err)
+	}
+	return vec.Data()
+}
+
+func vectorValue(data ...float32) types.Value {
+	return types.NewVectorValue(types.NewVector(data))
+}
+
+func TestVectorFunctions_TextConversion(t *testing.T) {
+	registry := DefaultFunctionRegistry()
+	vector := registry.Lookup("VECTOR")
+	toJSON := registry.Lookup("VECTOR_TO_JSON")
+
+	result := vector.Call([]types.Value{types.NewText("[0.5, -2, 3e-1]")})
+	if got := vectorData(t, result); len(got) != 3 || got[0] != 0.5 || got[1] != -2 || got[2] != 0.3 {
+		t.Errorf("VECTOR = %v", got)
+	}
+	if got := toJSON.Call([]types.Value{result}); got.Type() != types.TypeJSON || got.JSON() != "[0.5,-2,0.3]" {
+		t.Errorf("VECTOR_TO_JSON = %v %q", got.Type(), got.JSON())
+	}
+
+	for _, text := range []string{"", "[]", "[1, 'a']", "{\"a\": 1}", "1, 2"} {
+		if result := vector.Call([]types.Value{types.NewText(text)}); !result.IsNull() {
+			t.Errorf("VECTOR(%q) = %v, want NULL", text, result)
+		}
+	}
+	if result := toJSON.Call([]types.Value{types.NewText("[1]")}); !result.IsNull() {
+		t.Errorf("VECTOR_TO_JSON of text = %v, want NULL", result)
+	}
+}
+
+func TestVectorFunctions_Arithmetic(t *testing.T) {
+	registry := DefaultFunctionRegistry()
+	a := vectorValue(1, 2, 3)
+	b := vectorValue(0.5, 0.5, 1)
+
+	tests := []struct {
+		name     string
+		args     []types.Value
+		expected []float32
+	}{
+		{"VECTOR_ADD", []types.Value{a, b}, []float32{1.5, 2.5, 4}},
+		{"VECTOR_SUB", []types.Value{a, b}, []float32{0.5, 1.5, 2}},
+		{"VECTOR_SCALE", []types.Value{a, types.NewInt(2)}, []float32{2, 4, 6}},
+		{"VECTOR_SCALE", []types.Value{a, types.NewFloat(-0.5)}, []float32{-0.5, -1, -1.5}},
+		{"VECTOR_NORMALIZE", []types.Value{vectorValue(3, 0, 4)}, []float32{0.6, 0, 0.8}},
+		{"VECTOR_NORMALIZE", []types.Value{vectorValue(0, 0)}, []float32{0, 0}},
+		// Blobs holding a serialized vector work like vectors
+		{"VECTOR_ADD", []types.Value{types.NewBlob(types.NewVector([]float32{1, 1, 1}).ToBytes()), b}, []float32{1.5, 1.5, 2}},
+	}
+	for _, tc := range tests {
+		got := vectorData(t, registry.Lookup(tc.name).Call(tc.args))
+		if len(got) != len(tc.expected) {
+			t.Fatalf("%s = %v, want %v", tc.name, got, tc.expected)
+		}
+		for i := range got {
+			if math.Abs(float64(got[i]-tc.expected[i])) > 1e-6 {
+				t.Errorf("%s = %v, want %v", tc.name, got, tc.expected)
+				break
+			}
+		}
+	}
+
+	if result := registry.Lookup("VECTOR_NORM").Call([]types.Value{vectorValue(3, 0, 4)}); result.Float() != 5 {
+		t.Errorf("VECTOR_NORM = %v, want 5", result)
+	}
+	if result := registry.Lookup("VECTOR_DIMS").Call([]types.Value{a}); result.Int() != 3 {
+		t.Errorf("VECTOR_DIMS = %v, want 3", result)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []types.Value
+	}{
+		{"VECTOR_ADD", []types.Value{a, vectorValue(1, 2)}},
+		{"VECTOR_SUB", []types.Value{a, types.NewNull()}},
+		{"VECTOR_SCALE", []types.Value{a, types.NewText("x")}},
+		{"VECTOR_NORM", []types.Value{types.NewInt(1)}},
+		{"VECTOR_DIMS", []types.Value{types.NewNull()}},
+	} {
+		if result := registry.Lookup(tc.name).Call(tc.args); !result.IsNull() {
+			t.Errorf("%s(%v) = %v, want NULL", tc.name, tc.args, result)
+		}
+	}
+}
+
+func TestVectorAggregates(t *testing.T) {
+	values := []types.Value{vectorValue(1, 0, 2), types.NewNull(), vectorValue(3, 4, 0)}
+
+	if got := vectorData(t, stepAll(t, "VECTOR_SUM", values...)); got[0] != 4 || got[1] != 4 || got[2] != 2 {
+		t.Errorf("VECTOR_SUM = %v, want [4 4 2]", got)
+	}
+	// The centroid is not normalized
+	if got := vectorData(t, stepAll(t, "VECTOR_AVG", values...)); got[0] != 2 || got[1] != 2 || got[2] != 1 {
+		t.Errorf("VECTOR_AVG = %v, want [2 2 1]", got)
+	}
+
+	if result := stepAll(t, "VECTOR_AVG", types.NewNull()); !result.IsNull() {
+		t.Errorf("VECTOR_AVG of NULLs = %v, want NULL", result)
+	}
+	if result := stepAll(t, "VECTOR_SUM", vectorValue(1, 2), vectorValue(1, 2, 3)); !result.IsNull() {
+		t.Errorf("VECTOR_SUM of mixed dimensions = %v, want NULL", result)
+	}
+}
+
 func TestConcat(t *testing.T) {
 	registry := DefaultFunctionRegistry()
 	concat := registry.Lookup("CONCAT")
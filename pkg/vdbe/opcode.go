@@ -9,11 +9,13 @@ type Opcode uint8
 // VDBE opcodes - following SQLite's design
 const (
 	// Control flow
-	OpInit  Opcode = iota // Initialize program, jump to P2
-	OpHalt                // Terminate execution
-	OpGoto                // Jump to P2
-	OpIf                  // Jump to P2 if r[P1] is true
-	OpIfNot               // Jump to P2 if r[P1] is false
+	OpInit    Opcode = iota // Initialize program, jump to P2
+	OpHalt                  // Terminate execution
+	OpGoto                  // Jump to P2
+	OpIf                    // Jump to P2 if r[P1] is true
+	OpIfNot                 // Jump to P2 if r[P1] is false or NULL
+	OpIsNull                // Jump to P2 if r[P1] is NULL
+	OpNotNull               // Jump to P2 if r[P1] is not NULL
 
 	// Literals and registers
 	OpInteger // Store integer P1 in register P2
@@ -36,6 +38,11 @@ const (
 	OpDivide   // r[P3] = r[P1] / r[P2]
 	OpNegate   // r[P2] = -r[P1]
 
+	// Logic with SQL three-valued semantics, where NULL is UNKNOWN
+	OpAnd // r[P3] = r[P1] AND r[P2]
+	OpOr  // r[P3] = r[P1] OR r[P2]
+	OpNot // r[P2] = NOT r[P1]
+
 	// Cursor operations
 	OpOpenRead  // Open cursor P1 for reading table with root page P2
 	OpOpenWrite // Open cursor P1 for writing table with root page P2
@@ -64,7 +71,7 @@ const (
 	OpVectorSearch // Search HNSW index for K nearest neighbors
 
 	// Aggregation
-	OpAggInit  // Initialize aggregate: P1=aggIdx, P4=name (string)
+	OpAggInit  // Initialize aggregate: P1=aggIdx, P3=1 for DISTINCT, P4=name (string)
 	OpAggStep  // Step aggregate: P1=aggIdx, P2=valueReg
 	OpAggFinal // Finalize aggregate: P1=aggIdx, P2=destReg
 
@@ -89,6 +96,10 @@ func (op Opcode) String() string {
 		return "If"
 	case OpIfNot:
 		return "IfNot"
+	case OpIsNull:
+		return "IsNull"
+	case OpNotNull:
+		return "NotNull"
 	case OpInteger:
 		return "Integer"
 	case OpString:
@@ -119,6 +130,12 @@ func (op Opcode) String() string {
 		return "Divide"
 	case OpNegate:
 		return "Negate"
+	case OpAnd:
+		return "And"
+	case OpOr:
+		return "Or"
+	case OpNot:
+		return "Not"
 	case OpOpenRead:
 		return "OpenRead"
 	case OpOpenWrite:
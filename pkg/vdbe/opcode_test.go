@@ -16,6 +16,8 @@ func TestOpcodeString(t *testing.T) {
 		{OpGoto, "Goto"},
 		{OpIf, "If"},
 		{OpIfNot, "IfNot"},
+		{OpIsNull, "IsNull"},
+		{OpNotNull, "NotNull"},
 		{OpEq, "Eq"},
 		{OpNe, "Ne"},
 		{OpLt, "Lt"},
@@ -26,6 +28,9 @@ func TestOpcodeString(t *testing.T) {
 		{OpSubtract, "Subtract"},
 		{OpMultiply, "Multiply"},
 		{OpDivide, "Divide"},
+		{OpAnd, "And"},
+		{OpOr, "Or"},
+		{OpNot, "Not"},
 		{OpOpenRead, "OpenRead"},
 		{OpOpenWrite, "OpenWrite"},
 		{OpClose, "Close"},
@@ -347,6 +347,43 @@ func (vm *VM) step(instr *Instruction) error {
 		}
 		return nil
 
+	case OpIsNull:
+		// Jump to P2 if r[P1] is NULL
+		if vm.registers[instr.P1].IsNull() {
+			vm.pc = instr.P2
+		} else {
+			vm.pc++
+		}
+		return nil
+
+	case OpNotNull:
+		// Jump to P2 if r[P1] is not NULL
+		if !vm.registers[instr.P1].IsNull() {
+			vm.pc = instr.P2
+		} else {
+			vm.pc++
+		}
+		return nil
+
+	case OpAnd:
+		return vm.execLogical(instr, true)
+
+	case OpOr:
+		return vm.execLogical(instr, false)
+
+	case OpNot:
+		// r[P2] = NOT r[P1]; NOT NULL is NULL
+		val := vm.registers[instr.P1]
+		if val.IsNull() {
+			vm.registers[instr.P2] = val
+		} else if vm.isTruthy(val) {
+			vm.registers[instr.P2] = types.NewInt(0)
+		} else {
+			vm.registers[instr.P2] = types.NewInt(1)
+		}
+		vm.pc++
+		return nil
+
 	case OpResultRow:
 		// Output registers P1 through P1+P2-1
 		row := make([]types.Value, instr.P2)
@@ -443,6 +480,13 @@ func (vm *VM) execArithmetic(instr *Instruction, op func(a, b int64) int64) erro
 	a := vm.registers[instr.P1]
 	b := vm.registers[instr.P2]
 
+	// Arithmetic with NULL is NULL
+	if a.IsNull() || b.IsNull() {
+		vm.registers[instr.P3] = types.NewNull()
+		vm.pc++
+		return nil
+	}
+
 	// Handle type coercion
 	var result types.Value
 	if a.Type() == types.TypeFloat || b.Type() == types.TypeFloat {
@@ -459,6 +503,36 @@ func (vm *VM) execArithmetic(instr *Instruction, op func(a, b int64) int64) erro
 	return nil
 }
 
+// execLogical executes AND (and is true) or OR with three-valued logic:
+// a false operand decides AND and a true one decides OR, whatever the other
+// operand is; otherwise a NULL operand makes the result NULL
+func (vm *VM) execLogical(instr *Instruction, and bool) error {
+	a := vm.registers[instr.P1]
+	b := vm.registers[instr.P2]
+
+	decisive := func(v types.Value) bool {
+		return !v.IsNull() && vm.isTruthy(v) != and
+	}
+	var result types.Value
+	switch {
+	case decisive(a) || decisive(b):
+		result = types.NewInt(0)
+		if !and {
+			result = types.NewInt(1)
+		}
+	case a.IsNull() || b.IsNull():
+		result = types.NewNull()
+	default:
+		result = types.NewInt(1)
+		if !and {
+			result = types.NewInt(0)
+		}
+	}
+	vm.registers[instr.P3] = result
+	vm.pc++
+	return nil
+}
+
 // execComparison executes a comparison operation
 func (vm *VM) execComparison(instr *Instruction, cond func(cmp int) bool) error {
 	a := vm.registers[instr.P1]
@@ -810,6 +884,10 @@ func (vm *VM) execDelete(instr *Instruction) error {
 		return fmt.Errorf("delete failed: %w", err)
 	}
 
+	// Deleting may merge or free the pages the cursor is on, so reposition it
+	// on the entry that followed the deleted one
+	cursor.Seek(keyCopy)
+
 	vm.pc++
 	return nil
 }
@@ -885,8 +963,13 @@ func (vm *VM) execAggInit(instr *Instruction) error {
 		vm.aggregates = append(vm.aggregates, nil)
 	}
 
-	// Create and initialize the aggregate
-	agg := GetAggregate(aggName)
+	// Create and initialize the aggregate; P3=1 makes it DISTINCT
+	var agg AggregateFunc
+	if instr.P3 == 1 {
+		agg = GetDistinctAggregate(aggName)
+	} else {
+		agg = GetAggregate(aggName)
+	}
 	if agg == nil {
 		return fmt.Errorf("unknown aggregate function: %s", aggName)
 	}
@@ -93,6 +93,9 @@ type WAL struct {
 
 	// Frame tracking
 	frameCount uint32 // Number of valid frames
+
+	// readOnly is set for a WAL opened by OpenReader
+	readOnly bool
 }
 
 // Open opens or creates a WAL file
@@ -128,6 +131,60 @@ func Open(path string, opts Options) (*WAL, error) {
 }
 
 // createWAL creates a new WAL file
+// OpenReader opens the WAL of a database that another connection writes, to
+// read the frames it commits. The file is never written; it is created empty
+// if it does not exist yet. No frame is visible until Refresh or Rescan.
+func OpenReader(path string, opts Options) (*WAL, error) {
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = 4096
+	}
+
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{
+		file:     file,
+		pageSize: pageSize,
+		readOnly: true,
+	}, nil
+}
+
+// Refresh rereads the header, which the writer rewrites whenever the WAL
+// starts over, and makes frames 1 to frames visible. The writer must have
+// committed them under the current header. A WAL without a valid header has
+// no frames.
+func (w *WAL) Refresh(frames uint32) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.readHeaderFields(); err != nil {
+		w.frameCount = 0
+		if frames == 0 {
+			return nil
+		}
+		return err
+	}
+	w.frameCount = frames
+	return nil
+}
+
+// Rescan rereads the header and finds the committed frames as Open does,
+// without writing the file, and returns their number. A WAL without a valid
+// header has none.
+func (w *WAL) Rescan() (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.readHeader(); err != nil {
+		w.frameCount = 0
+		return 0, nil
+	}
+	w.frameCount = w.findLastCommitFrame()
+	return w.frameCount, nil
+}
+
 func createWAL(path string, pageSize int) (*WAL, error) {
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -181,6 +238,18 @@ func (w *WAL) writeHeader() error {
 
 // readHeader reads and validates the WAL header
 func (w *WAL) readHeader() error {
+	if err := w.readHeaderFields(); err != nil {
+		return err
+	}
+
+	// Count valid frames by scanning the WAL
+	w.frameCount = w.countValidFrames()
+
+	return nil
+}
+
+// readHeaderFields reads and verifies the header
+func (w *WAL) readHeaderFields() error {
 	header := make([]byte, HeaderSize)
 	n, err := w.file.ReadAt(header, 0)
 	if err != nil {
@@ -220,9 +289,6 @@ func (w *WAL) readHeader() error {
 	w.checksum1 = storedCksum1
 	w.checksum2 = storedCksum2
 
-	// Count valid frames by scanning the WAL
-	w.frameCount = w.countValidFrames()
-
 	return nil
 }
 
@@ -336,6 +402,80 @@ func (w *WAL) WriteFrame(pageNo uint32, data []byte, isCommit bool) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	// For commit frames, set the database size (in pages)
+	// Callers that know the size use WriteTransaction; here the page number
+	// serves as a placeholder
+	dbSize := uint32(0)
+	if isCommit {
+		dbSize = pageNo
+	}
+	if err := w.writeFrameLocked(pageNo, data, dbSize); err != nil {
+		return err
+	}
+
+	// Sync on commit
+	if isCommit {
+		return w.file.Sync()
+	}
+
+	return nil
+}
+
+// PageImage is the content of one page written by a transaction
+type PageImage struct {
+	PageNo uint32
+	Data   []byte
+}
+
+// WriteTransaction appends the pages written by one transaction and syncs the
+// WAL. The last frame is the commit frame and records dbSize, the size of the
+// database in pages after the transaction. If a write fails, the WAL is
+// rewound to where the transaction started, so the partial frames are
+// overwritten by the next transaction rather than committed by it.
+func (w *WAL) WriteTransaction(pages []PageImage, dbSize uint32) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	if dbSize == 0 {
+		return errors.New("commit frame needs a non-zero database size")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frameSize := FrameHeaderSize + w.pageSize
+	for _, page := range pages {
+		if len(page.Data) != w.pageSize {
+			return errors.New("page data size mismatch")
+		}
+	}
+
+	// The frames go out in a single write, then one sync
+	frameCount, checksum1, checksum2 := w.frameCount, w.checksum1, w.checksum2
+	buf := make([]byte, len(pages)*frameSize)
+	for i, page := range pages {
+		size := uint32(0)
+		if i == len(pages)-1 {
+			size = dbSize
+		}
+		w.encodeFrameLocked(buf[i*frameSize:(i+1)*frameSize], page.PageNo, page.Data, size)
+	}
+
+	frameOffset := int64(HeaderSize) + int64(frameCount)*int64(frameSize)
+	if _, err := w.file.WriteAt(buf, frameOffset); err != nil {
+		w.checksum1, w.checksum2 = checksum1, checksum2
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.checksum1, w.checksum2 = checksum1, checksum2
+		return err
+	}
+	w.frameCount += uint32(len(pages))
+	return nil
+}
+
+// writeFrameLocked appends one frame; dbSize is non-zero for commit frames
+func (w *WAL) writeFrameLocked(pageNo uint32, data []byte, dbSize uint32) error {
 	if len(data) != w.pageSize {
 		return errors.New("page data size mismatch")
 	}
@@ -343,53 +483,36 @@ func (w *WAL) WriteFrame(pageNo uint32, data []byte, isCommit bool) error {
 	// Calculate frame offset
 	frameOffset := int64(HeaderSize) + int64(w.frameCount)*(int64(FrameHeaderSize)+int64(w.pageSize))
 
-	// Build frame header
-	frameHeader := make([]byte, FrameHeaderSize)
-	binary.LittleEndian.PutUint32(frameHeader[0:4], pageNo)
-
-	// For commit frames, set the database size (in pages)
-	// For now, we'll use a placeholder - actual value comes from pager integration
-	if isCommit {
-		binary.LittleEndian.PutUint32(frameHeader[4:8], pageNo) // placeholder: dbSize
-	} else {
-		binary.LittleEndian.PutUint32(frameHeader[4:8], 0)
+	frame := make([]byte, FrameHeaderSize+w.pageSize)
+	w.encodeFrameLocked(frame, pageNo, data, dbSize)
+	if _, err := w.file.WriteAt(frame, frameOffset); err != nil {
+		return err
 	}
 
+	w.frameCount++
+	return nil
+}
+
+// encodeFrameLocked fills frame with the header and data of the next frame,
+// advancing the running checksum
+func (w *WAL) encodeFrameLocked(frame []byte, pageNo uint32, data []byte, dbSize uint32) {
+	frameHeader := frame[:FrameHeaderSize]
+	binary.LittleEndian.PutUint32(frameHeader[0:4], pageNo)
+	binary.LittleEndian.PutUint32(frameHeader[4:8], dbSize)
+
 	// Copy salt values
 	binary.LittleEndian.PutUint32(frameHeader[8:12], w.salt1)
 	binary.LittleEndian.PutUint32(frameHeader[12:16], w.salt2)
 
 	// Compute checksum over frame header (first 8 bytes) and page data
 	// The checksum continues from the previous checksum
-	checksumData := make([]byte, 8+len(data))
-	copy(checksumData[0:8], frameHeader[0:8])
-	copy(checksumData[8:], data)
-
-	w.checksum1, w.checksum2 = walChecksum(checksumData, w.checksum1, w.checksum2)
+	w.checksum1, w.checksum2 = walChecksum(frameHeader[0:8], w.checksum1, w.checksum2)
+	w.checksum1, w.checksum2 = walChecksum(data, w.checksum1, w.checksum2)
 
 	binary.LittleEndian.PutUint32(frameHeader[16:20], w.checksum1)
 	binary.LittleEndian.PutUint32(frameHeader[20:24], w.checksum2)
 
-	// Write frame header
-	_, err := w.file.WriteAt(frameHeader, frameOffset)
-	if err != nil {
-		return err
-	}
-
-	// Write page data
-	_, err = w.file.WriteAt(data, frameOffset+FrameHeaderSize)
-	if err != nil {
-		return err
-	}
-
-	w.frameCount++
-
-	// Sync on commit
-	if isCommit {
-		return w.file.Sync()
-	}
-
-	return nil
+	copy(frame[FrameHeaderSize:], data)
 }
 
 // ReadFrame reads a frame by its 1-based index
@@ -573,18 +696,42 @@ func (w *WAL) Checkpoint(dbPath string) (int, error) {
 	return checkpointedFrames, nil
 }
 
-// reset resets the WAL for reuse after checkpoint
-func (w *WAL) reset() error {
-	// Increment checkpoint sequence and change salt
-	w.ckptSeq++
-	w.salt1++
-	w.salt2 = rand.Uint32()
+// Reset discards every frame and truncates the file to its header. The caller
+// must already have made the database file hold the content of the committed
+// frames, typically by syncing it.
+func (w *WAL) Reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	// Reset frame count and checksum
-	w.frameCount = 0
+	if w.frameCount == 0 {
+		// Frames left behind by Restart are invalid but still take up space
+		info, err := w.file.Stat()
+		if err != nil || info.Size() <= HeaderSize {
+			return err
+		}
+	}
+	return w.reset()
+}
 
-	// Write new header with updated values
-	if err := w.writeHeaderLocked(); err != nil {
+// Restart discards every frame like Reset but leaves the file at its size, so
+// that new frames overwrite the old ones in place. The old frames no longer
+// match the salt in the header and are ignored from then on.
+func (w *WAL) Restart() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.frameCount == 0 {
+		return nil
+	}
+	if err := w.restart(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// reset resets the WAL for reuse after checkpoint
+func (w *WAL) reset() error {
+	if err := w.restart(); err != nil {
 		return err
 	}
 
@@ -596,6 +743,21 @@ func (w *WAL) reset() error {
 	return w.file.Sync()
 }
 
+// restart starts a new generation of frames at the beginning of the file.
+// The caller syncs the new header.
+func (w *WAL) restart() error {
+	// Increment checkpoint sequence and change salt
+	w.ckptSeq++
+	w.salt1++
+	w.salt2 = rand.Uint32()
+
+	// Reset frame count and checksum
+	w.frameCount = 0
+
+	// Write new header with updated values
+	return w.writeHeaderLocked()
+}
+
 // writeHeaderLocked writes header without acquiring lock (for internal use)
 func (w *WAL) writeHeaderLocked() error {
 	header := make([]byte, HeaderSize)
@@ -690,6 +852,34 @@ func (w *WAL) Recover(dbPath string) (int, error) {
 	return recoveredFrames, nil
 }
 
+// DiscardUncommitted drops the frames written after the last commit frame,
+// left behind by a transaction that never committed, so that the next
+// transaction overwrites them. Returns the number of frames left.
+func (w *WAL) DiscardUncommitted() (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lastCommit := w.findLastCommitFrame()
+	if lastCommit == w.frameCount {
+		return lastCommit, nil
+	}
+
+	// The checksum chain continues from the one stored in the commit frame,
+	// or in the WAL header if no transaction committed
+	offset := int64(HeaderSize) - 8
+	if lastCommit > 0 {
+		offset = int64(HeaderSize) + int64(lastCommit-1)*(int64(FrameHeaderSize)+int64(w.pageSize)) + 16
+	}
+	checksums := make([]byte, 8)
+	if _, err := w.file.ReadAt(checksums, offset); err != nil {
+		return w.frameCount, err
+	}
+	w.checksum1 = binary.LittleEndian.Uint32(checksums[0:4])
+	w.checksum2 = binary.LittleEndian.Uint32(checksums[4:8])
+	w.frameCount = lastCommit
+	return lastCommit, nil
+}
+
 // findLastCommitFrame finds the frame index of the last commit frame
 // Returns 0 if no commit frame is found
 func (w *WAL) findLastCommitFrame() uint32 {
@@ -722,6 +912,9 @@ func (w *WAL) Close() error {
 	defer w.mu.Unlock()
 
 	if w.file != nil {
+		if w.readOnly {
+			return w.file.Close()
+		}
 		if err := w.file.Sync(); err != nil {
 			w.file.Close()
 			return err
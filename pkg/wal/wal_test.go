@@ -613,3 +613,138 @@ func TestWALRecoveryMultipleTransactions(t *testing.T) {
 		t.Errorf("page 5: expected 0 (uncommitted), got %d", dbData[4*4096])
 	}
 }
+
+func TestWALWriteTransaction(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "test.db-wal")
+	dbPath := filepath.Join(dir, "test.db")
+	if err := os.WriteFile(dbPath, make([]byte, 5*4096), 0644); err != nil {
+		t.Fatalf("failed to create db file: %v", err)
+	}
+
+	w, err := Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	err = w.WriteTransaction([]PageImage{
+		{PageNo: 1, Data: makePageData(1, 10)},
+		{PageNo: 3, Data: makePageData(3, 30)},
+	}, 5)
+	if err != nil {
+		t.Fatalf("WriteTransaction failed: %v", err)
+	}
+
+	// Only the last frame is a commit frame, and it carries the database size
+	first, err := w.ReadFrame(1)
+	if err != nil || first.IsCommit {
+		t.Fatalf("expected a non-commit first frame, got %+v, %v", first, err)
+	}
+	last, err := w.ReadFrame(2)
+	if err != nil || !last.IsCommit || last.DbSize != 5 {
+		t.Fatalf("expected a commit frame with size 5, got %+v, %v", last, err)
+	}
+
+	// An uncommitted frame after the transaction is not recovered
+	w.WriteFrame(2, makePageData(2, 20), false)
+	w.Close()
+
+	w2, err := Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer w2.Close()
+	if recovered, err := w2.Recover(dbPath); err != nil || recovered != 2 {
+		t.Fatalf("expected 2 frames recovered, got %d, %v", recovered, err)
+	}
+
+	dbData, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("failed to read db file: %v", err)
+	}
+	if dbData[0] != 10 || dbData[4096] != 0 || dbData[2*4096] != 30 {
+		t.Errorf("unexpected page contents after recovery: %d, %d, %d", dbData[0], dbData[4096], dbData[2*4096])
+	}
+}
+
+func TestWALReset(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "test.db-wal")
+
+	w, err := Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.WriteTransaction([]PageImage{{PageNo: 1, Data: makePageData(1, 10)}}, 1); err != nil {
+		t.Fatalf("WriteTransaction failed: %v", err)
+	}
+	if err := w.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if w.FrameCount() != 0 {
+		t.Errorf("expected no frames after Reset, got %d", w.FrameCount())
+	}
+	if info, err := os.Stat(walPath); err != nil || info.Size() != HeaderSize {
+		t.Errorf("expected the WAL truncated to its header: %v, %v", info, err)
+	}
+
+	// Frames written after the reset belong to the new WAL generation
+	if err := w.WriteTransaction([]PageImage{{PageNo: 2, Data: makePageData(2, 20)}}, 2); err != nil {
+		t.Fatalf("WriteTransaction after Reset failed: %v", err)
+	}
+	if w.FrameCount() != 1 {
+		t.Errorf("expected 1 frame, got %d", w.FrameCount())
+	}
+}
+
+func TestWALDiscardUncommitted(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "test.db-wal")
+
+	w, err := Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to open WAL: %v", err)
+	}
+	if err := w.WriteTransaction([]PageImage{{PageNo: 1, Data: makePageData(1, 10)}}, 1); err != nil {
+		t.Fatalf("WriteTransaction failed: %v", err)
+	}
+	// A transaction that crashed before its commit frame
+	w.WriteFrame(2, makePageData(2, 20), false)
+	w.WriteFrame(3, makePageData(3, 30), false)
+	w.Close()
+
+	w, err = Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer w.Close()
+	if w.FrameCount() != 3 {
+		t.Fatalf("expected 3 valid frames, got %d", w.FrameCount())
+	}
+	frames, err := w.DiscardUncommitted()
+	if err != nil {
+		t.Fatalf("DiscardUncommitted failed: %v", err)
+	}
+	if frames != 1 || w.FrameCount() != 1 {
+		t.Errorf("expected 1 committed frame, got %d (%d)", frames, w.FrameCount())
+	}
+
+	// The next transaction overwrites the dropped frames and stays valid
+	if err := w.WriteTransaction([]PageImage{{PageNo: 4, Data: makePageData(4, 40)}}, 4); err != nil {
+		t.Fatalf("WriteTransaction failed: %v", err)
+	}
+	w.Close()
+	w, err = Open(walPath, Options{PageSize: 4096})
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	defer w.Close()
+	if w.FrameCount() != 2 {
+		t.Fatalf("expected 2 valid frames, got %d", w.FrameCount())
+	}
+	frame, err := w.ReadFrame(2)
+	if err != nil || frame.PageNo != 4 || !frame.IsCommit {
+		t.Errorf("expected the commit frame of page 4, got %+v, %v", frame, err)
+	}
+}
@@ -2,8 +2,10 @@ package tests
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -352,6 +354,58 @@ func BenchmarkTransactionCommit_SQLite(b *testing.B) {
 	}
 }
 
+// BenchmarkConcurrentReads_TurDB benchmarks read throughput with a growing
+// number of goroutines sharing one database: a scan with an aggregate and a
+// point lookup per operation. Queries run in parallel, so queries/s should
+// grow with the goroutines up to the number of CPUs.
+func BenchmarkConcurrentReads_TurDB(b *testing.B) {
+	tmpDir := b.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := turdb.Open(dbPath)
+	if err != nil {
+		b.Fatalf("Failed to open TurDB: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec("CREATE TABLE bench (id INT PRIMARY KEY, name TEXT, value INT)")
+	db.Exec("BEGIN")
+	insertStmt, _ := db.Prepare("INSERT INTO bench VALUES (?, ?, ?)")
+	for i := 0; i < 1000; i++ {
+		insertStmt.BindInt(1, int64(i))
+		insertStmt.BindText(2, "name")
+		insertStmt.BindInt(3, int64(i*10))
+		insertStmt.Exec()
+	}
+	insertStmt.Close()
+	db.Exec("COMMIT")
+
+	for _, goroutines := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := g; i < b.N; i += goroutines {
+						if _, err := db.Exec("SELECT COUNT(*), SUM(value) FROM bench WHERE value > 5000"); err != nil {
+							b.Errorf("scan failed: %v", err)
+							return
+						}
+						if _, err := db.Exec(fmt.Sprintf("SELECT name FROM bench WHERE id = %d", i%1000)); err != nil {
+							b.Errorf("lookup failed: %v", err)
+							return
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+			b.ReportMetric(float64(2*b.N)/b.Elapsed().Seconds(), "queries/s")
+		})
+	}
+}
+
 // RunComparison runs the benchmarks and prints a comparison table
 func TestPrintBenchmarkComparison(t *testing.T) {
 	if os.Getenv("RUN_BENCHMARK_COMPARISON") != "1" {
@@ -666,11 +666,23 @@ func TestMillionRecordsCompareWithSQLite(t *testing.T) {
 		t.Fatalf("CREATE TABLE failed: %v", err)
 	}
 
+	// Every commit syncs the WAL, so the rows go in batches of one
+	// transaction each, as a bulk load would
+	const batchSize = 1000
 	turdbStart := time.Now()
-	for i := 0; i < totalRecords; i++ {
-		_, err = db.Exec(fmt.Sprintf("INSERT INTO bench VALUES (%d, 'data-%d', %d)", i, i, i*7))
+	for start := 0; start < totalRecords; start += batchSize {
+		tx, err := db.Begin()
 		if err != nil {
-			t.Fatalf("INSERT failed at %d: %v", i, err)
+			t.Fatalf("BEGIN failed at %d: %v", start, err)
+		}
+		for i := start; i < start+batchSize && i < totalRecords; i++ {
+			_, err = tx.Exec(fmt.Sprintf("INSERT INTO bench VALUES (%d, 'data-%d', %d)", i, i, i*7))
+			if err != nil {
+				t.Fatalf("INSERT failed at %d: %v", i, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("COMMIT failed at %d: %v", start, err)
 		}
 	}
 	turdbInsertDuration := time.Since(turdbStart)